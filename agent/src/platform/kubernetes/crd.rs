@@ -252,3 +252,230 @@ pub mod tkex {
         }
     }
 }
+
+pub mod argo {
+    use super::*;
+
+    use k8s_openapi::{
+        api::core::v1::PodTemplateSpec, apimachinery::pkg::apis::meta::v1::LabelSelector,
+    };
+
+    #[derive(CustomResource, Clone, Debug, Serialize, Deserialize, JsonSchema)]
+    #[kube(
+        group = "argoproj.io",
+        version = "v1alpha1",
+        kind = "Rollout",
+        namespaced
+    )]
+    #[serde(rename_all = "camelCase")]
+    pub struct RolloutSpec {
+        pub replicas: Option<i32>,
+        pub selector: Option<LabelSelector>,
+        // template is empty when the rollout references a deployment by workloadRef
+        pub template: Option<PodTemplateSpec>,
+    }
+
+    impl Trimmable for Rollout {
+        fn trim(mut self) -> Self {
+            let name = if let Some(name) = self.metadata.name.as_ref() {
+                name
+            } else {
+                ""
+            };
+            let mut ro = Self::new(name, self.spec);
+            ro.metadata = ObjectMeta {
+                uid: self.metadata.uid.take(),
+                name: self.metadata.name.take(),
+                namespace: self.metadata.namespace.take(),
+                labels: self.metadata.labels.take(),
+                ..Default::default()
+            };
+            ro
+        }
+    }
+}
+
+pub mod gateway_api {
+    use super::*;
+
+    use serde_json::Value;
+
+    #[derive(CustomResource, Clone, Debug, Serialize, Deserialize, JsonSchema)]
+    #[kube(
+        group = "gateway.networking.k8s.io",
+        version = "v1",
+        kind = "Gateway",
+        namespaced
+    )]
+    #[serde(rename_all = "camelCase")]
+    pub struct GatewaySpec {
+        pub gateway_class_name: Option<String>,
+        pub listeners: Option<Vec<Value>>,
+    }
+
+    impl Trimmable for Gateway {
+        fn trim(mut self) -> Self {
+            let name = if let Some(name) = self.metadata.name.as_ref() {
+                name
+            } else {
+                ""
+            };
+            let mut gw = Self::new(name, self.spec);
+            gw.metadata = ObjectMeta {
+                uid: self.metadata.uid.take(),
+                name: self.metadata.name.take(),
+                namespace: self.metadata.namespace.take(),
+                ..Default::default()
+            };
+            gw
+        }
+    }
+
+    #[derive(CustomResource, Clone, Debug, Serialize, Deserialize, JsonSchema)]
+    #[kube(
+        group = "gateway.networking.k8s.io",
+        version = "v1",
+        kind = "HTTPRoute",
+        namespaced
+    )]
+    #[serde(rename_all = "camelCase")]
+    pub struct HTTPRouteSpec {
+        pub parent_refs: Option<Vec<Value>>,
+        pub hostnames: Option<Vec<String>>,
+        pub rules: Option<Vec<Value>>,
+    }
+
+    impl Trimmable for HTTPRoute {
+        fn trim(mut self) -> Self {
+            let name = if let Some(name) = self.metadata.name.as_ref() {
+                name
+            } else {
+                ""
+            };
+            let mut hr = Self::new(name, self.spec);
+            hr.metadata = ObjectMeta {
+                uid: self.metadata.uid.take(),
+                name: self.metadata.name.take(),
+                namespace: self.metadata.namespace.take(),
+                ..Default::default()
+            };
+            hr
+        }
+    }
+}
+
+// VirtualService is served as v1, v1beta1 or v1alpha3 depending on the istio version,
+// the specs of the versions are the same
+pub mod istio {
+    use super::*;
+
+    use serde_json::Value;
+
+    pub mod v1beta1 {
+        use super::*;
+
+        #[derive(CustomResource, Clone, Debug, Serialize, Deserialize, JsonSchema)]
+        #[kube(
+            group = "networking.istio.io",
+            version = "v1beta1",
+            kind = "VirtualService",
+            namespaced
+        )]
+        #[serde(rename_all = "camelCase")]
+        pub struct VirtualServiceSpec {
+            pub hosts: Option<Vec<String>>,
+            pub gateways: Option<Vec<String>>,
+            pub http: Option<Vec<Value>>,
+        }
+
+        impl Trimmable for VirtualService {
+            fn trim(mut self) -> Self {
+                let name = if let Some(name) = self.metadata.name.as_ref() {
+                    name
+                } else {
+                    ""
+                };
+                let mut vs = Self::new(name, self.spec);
+                vs.metadata = ObjectMeta {
+                    uid: self.metadata.uid.take(),
+                    name: self.metadata.name.take(),
+                    namespace: self.metadata.namespace.take(),
+                    ..Default::default()
+                };
+                vs
+            }
+        }
+    }
+
+    pub mod v1 {
+        use super::*;
+
+        #[derive(CustomResource, Clone, Debug, Serialize, Deserialize, JsonSchema)]
+        #[kube(
+            group = "networking.istio.io",
+            version = "v1",
+            kind = "VirtualService",
+            namespaced
+        )]
+        #[serde(rename_all = "camelCase")]
+        pub struct VirtualServiceSpec {
+            pub hosts: Option<Vec<String>>,
+            pub gateways: Option<Vec<String>>,
+            pub http: Option<Vec<Value>>,
+        }
+
+        impl Trimmable for VirtualService {
+            fn trim(mut self) -> Self {
+                let name = if let Some(name) = self.metadata.name.as_ref() {
+                    name
+                } else {
+                    ""
+                };
+                let mut vs = Self::new(name, self.spec);
+                vs.metadata = ObjectMeta {
+                    uid: self.metadata.uid.take(),
+                    name: self.metadata.name.take(),
+                    namespace: self.metadata.namespace.take(),
+                    ..Default::default()
+                };
+                vs
+            }
+        }
+    }
+
+    pub mod v1alpha3 {
+        use super::*;
+
+        #[derive(CustomResource, Clone, Debug, Serialize, Deserialize, JsonSchema)]
+        #[kube(
+            group = "networking.istio.io",
+            version = "v1alpha3",
+            kind = "VirtualService",
+            namespaced
+        )]
+        #[serde(rename_all = "camelCase")]
+        pub struct VirtualServiceSpec {
+            pub hosts: Option<Vec<String>>,
+            pub gateways: Option<Vec<String>>,
+            pub http: Option<Vec<Value>>,
+        }
+
+        impl Trimmable for VirtualService {
+            fn trim(mut self) -> Self {
+                let name = if let Some(name) = self.metadata.name.as_ref() {
+                    name
+                } else {
+                    ""
+                };
+                let mut vs = Self::new(name, self.spec);
+                vs.metadata = ObjectMeta {
+                    uid: self.metadata.uid.take(),
+                    name: self.metadata.name.take(),
+                    namespace: self.metadata.namespace.take(),
+                    ..Default::default()
+                };
+                vs
+            }
+        }
+    }
+}
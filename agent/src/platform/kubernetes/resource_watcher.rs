@@ -31,6 +31,7 @@ use futures::StreamExt;
 use k8s_openapi::{
     api::{
         apps::v1::{DaemonSet, Deployment, ReplicaSet, ReplicaSetSpec, StatefulSet},
+        batch::v1::{CronJob, Job},
         core::v1::{
             ConfigMap, Container, ContainerStatus, Namespace, Node, NodeSpec, NodeStatus, Pod,
             PodSpec, PodStatus, ReplicationController, Service, ServiceStatus,
@@ -53,7 +54,10 @@ use serde::ser::Serialize;
 use tokio::{runtime::Handle, sync::Mutex, task::JoinHandle, time};
 
 use super::crd::{
+    argo::Rollout,
     calico::IpPool,
+    gateway_api::{Gateway, HTTPRoute},
+    istio::{v1 as istio_v1, v1alpha3 as istio_v1alpha3, v1beta1 as istio_v1beta1},
     kruise::{CloneSet, StatefulSet as KruiseStatefulSet},
     opengauss::OpenGaussCluster,
     pingan_cloud::ServiceRule,
@@ -207,6 +211,8 @@ pub enum GenericResourceWatcher {
     V1Ingress(ResourceWatcher<networking::v1::Ingress>),
     Route(ResourceWatcher<Route>),
     ConfigMap(ResourceWatcher<ConfigMap>),
+    Job(ResourceWatcher<Job>),
+    CronJob(ResourceWatcher<CronJob>),
 
     // CRDs
     ServiceRule(ResourceWatcher<ServiceRule>),
@@ -215,6 +221,12 @@ pub enum GenericResourceWatcher {
     IpPool(ResourceWatcher<IpPool>),
     OpenGaussCluster(ResourceWatcher<OpenGaussCluster>),
     StatefulSetPlus(ResourceWatcher<StatefulSetPlus>),
+    Rollout(ResourceWatcher<Rollout>),
+    Gateway(ResourceWatcher<Gateway>),
+    HTTPRoute(ResourceWatcher<HTTPRoute>),
+    VirtualService(ResourceWatcher<istio_v1beta1::VirtualService>),
+    V1VirtualService(ResourceWatcher<istio_v1::VirtualService>),
+    V1alpha3VirtualService(ResourceWatcher<istio_v1alpha3::VirtualService>),
 }
 
 #[derive(Clone, Copy, Debug, PartialEq, Eq)]
@@ -558,6 +570,76 @@ pub fn supported_resources() -> Vec<Resource> {
             selected_gv: SelectedGv::None,
             field_selector: String::new(),
         },
+        Resource {
+            name: "jobs",
+            pb_name: "*v1.Job",
+            group_versions: vec![GroupVersion {
+                group: "batch",
+                version: "v1",
+            }],
+            selected_gv: SelectedGv::None,
+            field_selector: String::new(),
+        },
+        Resource {
+            name: "cronjobs",
+            pb_name: "*v1.CronJob",
+            group_versions: vec![GroupVersion {
+                group: "batch",
+                version: "v1",
+            }],
+            selected_gv: SelectedGv::None,
+            field_selector: String::new(),
+        },
+        Resource {
+            name: "rollouts",
+            pb_name: "*v1alpha1.Rollout",
+            group_versions: vec![GroupVersion {
+                group: "argoproj.io",
+                version: "v1alpha1",
+            }],
+            selected_gv: SelectedGv::None,
+            field_selector: String::new(),
+        },
+        Resource {
+            name: "gateways",
+            pb_name: "*v1.Gateway",
+            group_versions: vec![GroupVersion {
+                group: "gateway.networking.k8s.io",
+                version: "v1",
+            }],
+            selected_gv: SelectedGv::None,
+            field_selector: String::new(),
+        },
+        Resource {
+            name: "httproutes",
+            pb_name: "*v1.HTTPRoute",
+            group_versions: vec![GroupVersion {
+                group: "gateway.networking.k8s.io",
+                version: "v1",
+            }],
+            selected_gv: SelectedGv::None,
+            field_selector: String::new(),
+        },
+        Resource {
+            name: "virtualservices",
+            pb_name: "*v1beta1.VirtualService",
+            group_versions: vec![
+                GroupVersion {
+                    group: "networking.istio.io",
+                    version: "v1beta1",
+                },
+                GroupVersion {
+                    group: "networking.istio.io",
+                    version: "v1",
+                },
+                GroupVersion {
+                    group: "networking.istio.io",
+                    version: "v1alpha3",
+                },
+            ],
+            selected_gv: SelectedGv::None,
+            field_selector: String::new(),
+        },
     ]
 }
 
@@ -1235,6 +1317,26 @@ impl Trimmable for Deployment {
     }
 }
 
+impl Trimmable for Job {
+    fn trim(self) -> Self {
+        Job {
+            metadata: self.metadata,
+            spec: self.spec,
+            ..Default::default()
+        }
+    }
+}
+
+impl Trimmable for CronJob {
+    fn trim(self) -> Self {
+        CronJob {
+            metadata: self.metadata,
+            spec: self.spec,
+            ..Default::default()
+        }
+    }
+}
+
 impl Trimmable for Service {
     fn trim(mut self) -> Self {
         let mut trim_svc = Service::default();
@@ -1480,6 +1582,72 @@ impl ResourceWatcherFactory {
             "opengaussclusters" => GenericResourceWatcher::OpenGaussCluster(
                 self.new_namespace_resource(resource, stats_collector, namespace, config),
             ),
+            "jobs" => GenericResourceWatcher::Job(self.new_namespace_resource(
+                resource,
+                stats_collector,
+                namespace,
+                config,
+            )),
+            "cronjobs" => GenericResourceWatcher::CronJob(self.new_namespace_resource(
+                resource,
+                stats_collector,
+                namespace,
+                config,
+            )),
+            "rollouts" => GenericResourceWatcher::Rollout(self.new_namespace_resource(
+                resource,
+                stats_collector,
+                namespace,
+                config,
+            )),
+            "gateways" => GenericResourceWatcher::Gateway(self.new_namespace_resource(
+                resource,
+                stats_collector,
+                namespace,
+                config,
+            )),
+            "httproutes" => GenericResourceWatcher::HTTPRoute(self.new_namespace_resource(
+                resource,
+                stats_collector,
+                namespace,
+                config,
+            )),
+            "virtualservices" => match resource.selected_gv.unwrap() {
+                GroupVersion {
+                    group: "networking.istio.io",
+                    version: "v1beta1",
+                } => GenericResourceWatcher::VirtualService(self.new_namespace_resource(
+                    resource,
+                    stats_collector,
+                    namespace,
+                    config,
+                )),
+                GroupVersion {
+                    group: "networking.istio.io",
+                    version: "v1",
+                } => GenericResourceWatcher::V1VirtualService(self.new_namespace_resource(
+                    resource,
+                    stats_collector,
+                    namespace,
+                    config,
+                )),
+                GroupVersion {
+                    group: "networking.istio.io",
+                    version: "v1alpha3",
+                } => {
+                    let vs_watcher =
+                        self.new_namespace_resource(resource, stats_collector, namespace, config);
+                    GenericResourceWatcher::V1alpha3VirtualService(vs_watcher)
+                }
+                _ => {
+                    warn!(
+                        "unsupported resource {} group version {}",
+                        resource.name,
+                        resource.selected_gv.unwrap()
+                    );
+                    return None;
+                }
+            },
             _ => {
                 warn!("unsupported resource {}", resource.name);
                 return None;
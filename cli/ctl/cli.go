@@ -68,6 +68,7 @@ func Execute(version string) {
 	root.AddCommand(RegisterPluginCommand())
 	root.AddCommand(RegisterPrometheusCommand())
 	root.AddCommand(RegisterPromQLCommand())
+	root.AddCommand(RegisterQueryCommand())
 	root.AddCommand(AgentCheckRegisterCommand())
 
 	cmd.RegisterIngesterCommand(root)
@@ -39,6 +39,18 @@ var YamlDomainHuawei []byte
 //go:embed domain_kubernetes.yaml
 var YamlDomainKubernetes []byte
 
+//go:embed domain_openstack.yaml
+var YamlDomainOpenStack []byte
+
+//go:embed domain_vsphere.yaml
+var YamlDomainVSphere []byte
+
+//go:embed domain_azure.yaml
+var YamlDomainAzure []byte
+
+//go:embed domain_gcp.yaml
+var YamlDomainGCP []byte
+
 //go:embed domain_qingcloud.yaml
 var YamlDomainQingCloud []byte
 
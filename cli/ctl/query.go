@@ -0,0 +1,306 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctl
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	simplejson "github.com/bitly/go-simplejson"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	"github.com/deepflowio/deepflow/cli/ctl/common"
+	"github.com/deepflowio/deepflow/cli/ctl/common/table"
+)
+
+type QueryOutput string
+
+const (
+	QUERY_OUTPUT_TABLE QueryOutput = "table"
+	QUERY_OUTPUT_JSON  QueryOutput = "json"
+	QUERY_OUTPUT_CSV   QueryOutput = "csv"
+	QUERY_OUTPUT_YAML  QueryOutput = "yaml"
+)
+
+func RegisterQueryCommand() *cobra.Command {
+	var db, dataPrecision string
+	query := &cobra.Command{
+		Use:   "query",
+		Short: "run sql or promql on deepflow-server querier",
+		Example: "deepflow-ctl query --db flow_log \"SELECT Count(row) FROM l7_flow_log WHERE time>=now()-300\"\n" +
+			"deepflow-ctl query promql 'sum(rate(flow_metrics__network__byte[1m]))' --range 1h -o csv",
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				fmt.Fprintln(os.Stderr, "please run with sql or 'promql'.")
+				return
+			}
+			if db == "" {
+				fmt.Fprintln(os.Stderr, "please run with '--db'.")
+				return
+			}
+			if err := sqlQuery(cmd, db, dataPrecision, args[0]); err != nil {
+				fmt.Fprintf(os.Stderr, "sql query error: %v\n", err)
+			}
+		},
+	}
+	query.Flags().StringVarP(&db, "db", "", "", "database of the sql, e.g.: flow_log, flow_metrics, event, profile, prometheus")
+	query.Flags().StringVarP(&dataPrecision, "data-precision", "", "", "data precision of flow_metrics, e.g.: 1s, 1m")
+	query.PersistentFlags().Uint32P("querier-port", "", 30416, "deepflow-server querier node port")
+	query.PersistentFlags().StringP("output", "o", string(QUERY_OUTPUT_TABLE), "output format: table, json, csv, yaml")
+	query.PersistentFlags().BoolP("debug", "d", false, "show the sql generated for clickhouse")
+
+	query.AddCommand(promQLQuerySubCommand())
+	return query
+}
+
+func promQLQuerySubCommand() *cobra.Command {
+	var rangeDuration, step time.Duration
+	var at string
+	promql := &cobra.Command{
+		Use:   "promql",
+		Short: "run promql as an instant query, or a range query if --range is set",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			end := time.Now()
+			if at != "" {
+				var err error
+				end, err = time.Parse(time.RFC3339, at)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "parse time error: %v\n", err)
+					return
+				}
+			}
+			if err := promQLQuery(cmd, args[0], end, rangeDuration, step); err != nil {
+				fmt.Fprintf(os.Stderr, "promql query error: %v\n", err)
+			}
+		},
+	}
+	promql.Flags().DurationVarP(&rangeDuration, "range", "r", 0, "query range like [5m,1h,24h] before --time, instant query if not set")
+	promql.Flags().DurationVarP(&step, "step", "", 0, "query resolution step of range query, default: range/60 and not less than 1s")
+	promql.Flags().StringVarP(&at, "time", "t", "", "evaluation time of instant query or end time of range query (RFC3339), default: now")
+	return promql
+}
+
+func getQueryOptions(cmd *cobra.Command) (QueryOutput, bool, []common.HTTPOption) {
+	output, _ := cmd.Flags().GetString("output")
+	debug, _ := cmd.Flags().GetBool("debug")
+	return QueryOutput(output), debug, []common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd)), common.WithORGID(common.GetORGID(cmd))}
+}
+
+func getQuerierURL(cmd *cobra.Command, path string) string {
+	server := common.GetServerInfo(cmd)
+	port, _ := cmd.Flags().GetUint32("querier-port")
+	return fmt.Sprintf("http://%s:%d%s", server.IP, port, path)
+}
+
+func sqlQuery(cmd *cobra.Command, db, dataPrecision, sql string) error {
+	output, debug, opts := getQueryOptions(cmd)
+	queryURL := getQuerierURL(cmd, "/v1/query/")
+	if debug {
+		queryURL += "?debug=true"
+	}
+	form := url.Values{}
+	form.Set("db", db)
+	form.Set("sql", sql)
+	if dataPrecision != "" {
+		form.Set("data_precision", dataPrecision)
+	}
+	response, err := common.CURLPerform("POST", queryURL, nil, form.Encode(), opts...)
+	if err != nil {
+		return err
+	}
+
+	if debug {
+		sqls := response.Get("debug").Get("query_sqls")
+		for i := range sqls.MustArray() {
+			d := sqls.GetIndex(i)
+			fmt.Fprintf(os.Stderr, "-- clickhouse sql (query time: %s)\n%s\n\n", d.Get("QueryTime").MustString(), d.Get("Sql").MustString())
+		}
+	}
+
+	result := response.Get("result")
+	columns := make([]string, 0, len(result.Get("columns").MustArray()))
+	for i := range result.Get("columns").MustArray() {
+		columns = append(columns, formatQueryValue(result.Get("columns").GetIndex(i).Interface()))
+	}
+	rows := make([][]string, 0, len(result.Get("values").MustArray()))
+	for i := range result.Get("values").MustArray() {
+		values := result.Get("values").GetIndex(i).MustArray()
+		row := make([]string, 0, len(values))
+		for _, v := range values {
+			row = append(row, formatQueryValue(v))
+		}
+		rows = append(rows, row)
+	}
+	return printQueryResult(output, result, columns, rows)
+}
+
+func promQLQuery(cmd *cobra.Command, promql string, end time.Time, rangeDuration, step time.Duration) error {
+	output, debug, opts := getQueryOptions(cmd)
+	form := url.Values{}
+	form.Set("query", promql)
+	if debug {
+		form.Set("debug", "true")
+	}
+	path := "/prom/api/v1/query"
+	if rangeDuration > 0 {
+		if step <= 0 {
+			step = rangeDuration / 60
+		}
+		if step < time.Second {
+			step = time.Second
+		}
+		path = "/prom/api/v1/query_range"
+		form.Set("start", strconv.FormatInt(end.Add(-rangeDuration).Unix(), 10))
+		form.Set("end", strconv.FormatInt(end.Unix(), 10))
+		form.Set("step", strconv.FormatInt(int64(step.Seconds()), 10))
+	} else {
+		form.Set("time", strconv.FormatInt(end.Unix(), 10))
+	}
+	response, err := common.CURLPerform("POST", getQuerierURL(cmd, path), nil, form.Encode(), opts...)
+	if err != nil {
+		return err
+	}
+	if status := response.Get("status").MustString(); status != "success" {
+		return fmt.Errorf("%s: %s", response.Get("errorType").MustString(), response.Get("error").MustString())
+	}
+
+	if debug {
+		stats := response.Get("stats")
+		for i := range stats.MustArray() {
+			s := stats.GetIndex(i)
+			fmt.Fprintf(os.Stderr, "-- querier sql\n%s\n-- clickhouse sql (duration: %vs)\n%s\n\n",
+				s.Get("querier_sql").MustString(), s.Get("duration").MustFloat64(), s.Get("sql").MustString())
+		}
+	}
+
+	data := response.Get("data")
+	columns := []string{"METRIC", "TIME", "VALUE"}
+	rows := [][]string{}
+	switch data.Get("resultType").MustString() {
+	case "matrix":
+		for i := range data.Get("result").MustArray() {
+			series := data.Get("result").GetIndex(i)
+			metric := formatPromMetric(series.Get("metric").MustMap())
+			for j := range series.Get("values").MustArray() {
+				rows = append(rows, append([]string{metric}, formatPromSample(series.Get("values").GetIndex(j))...))
+			}
+		}
+	case "vector":
+		for i := range data.Get("result").MustArray() {
+			series := data.Get("result").GetIndex(i)
+			rows = append(rows, append([]string{formatPromMetric(series.Get("metric").MustMap())}, formatPromSample(series.Get("value"))...))
+		}
+	default: // scalar, string
+		rows = append(rows, append([]string{""}, formatPromSample(data.Get("result"))...))
+	}
+	return printQueryResult(output, data, columns, rows)
+}
+
+func printQueryResult(output QueryOutput, raw *simplejson.Json, columns []string, rows [][]string) error {
+	switch output {
+	case QUERY_OUTPUT_JSON:
+		jData, err := raw.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		str, err := common.JsonFormat(jData)
+		if err != nil {
+			return err
+		}
+		fmt.Println(str)
+	case QUERY_OUTPUT_YAML:
+		jData, err := raw.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		yData, err := yaml.JSONToYAML(jData)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(yData))
+	case QUERY_OUTPUT_CSV:
+		w := csv.NewWriter(os.Stdout)
+		w.Write(columns)
+		w.WriteAll(rows)
+		return w.Error()
+	case QUERY_OUTPUT_TABLE, "":
+		t := table.New()
+		t.SetHeader(columns)
+		t.AppendBulk(rows)
+		t.Render()
+	default:
+		return fmt.Errorf("output format %s is not supported, supported: table, json, csv, yaml", output)
+	}
+	return nil
+}
+
+func formatQueryValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprintf("%v", val)
+		}
+		return string(b)
+	}
+}
+
+// formatPromMetric formats the labels like '__name__{k1="v1", k2="v2"}', the labels are sorted by name
+func formatPromMetric(labels map[string]interface{}) string {
+	name := formatQueryValue(labels["__name__"])
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		if k != "__name__" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", k, formatQueryValue(labels[k])))
+	}
+	return name + "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// formatPromSample formats the sample [<unix time>, "<value>"] as the RFC3339 time and the value
+func formatPromSample(sample *simplejson.Json) []string {
+	ts := sample.GetIndex(0).MustFloat64()
+	sec, frac := math.Modf(ts)
+	t := time.Unix(int64(sec), int64(frac*float64(time.Second)))
+	return []string{t.Format(time.RFC3339), formatQueryValue(sample.GetIndex(1).Interface())}
+}
@@ -37,6 +37,7 @@ import (
 	"github.com/deepflowio/deepflow/server/libs/debug"
 	"github.com/deepflowio/deepflow/server/libs/logger"
 	"github.com/deepflowio/deepflow/server/mcp"
+	prometheus "github.com/deepflowio/deepflow/server/querier/app/prometheus/service"
 	"github.com/deepflowio/deepflow/server/querier/querier"
 
 	logging "github.com/op/go-logging"
@@ -104,6 +105,7 @@ func main() {
 	}()
 
 	report.SetServerInfo(Branch, RevCount, Revision)
+	prometheus.SetBuildInfo(Branch, Revision, CompileTime)
 
 	shared := common.NewControllerIngesterShared()
 
@@ -0,0 +1,57 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"github.com/bitly/go-simplejson"
+
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// getAZLcuuid 可用区为位置及区域编号的组合，如 eastus-1，未部署在可用区的资源属于以位置命名的可用区
+func (a *Azure) getAZLcuuid(jResource *simplejson.Json) string {
+	location := jResource.Get("location").MustString()
+	name := location
+	if zones := jResource.Get("zones").MustArray(); len(zones) > 0 {
+		if zone, ok := zones[0].(string); ok && zone != "" {
+			name = location + "-" + zone
+		}
+	}
+	lcuuid := common.GetUUIDByOrgID(a.orgID, a.uuidGenerate+"_"+name)
+	if _, ok := a.azLcuuidMap[lcuuid]; !ok {
+		a.azLcuuidMap[lcuuid] = model.AZ{
+			Lcuuid:       lcuuid,
+			Label:        name,
+			Name:         name,
+			RegionLcuuid: a.getRegionLcuuid(location),
+		}
+	}
+	return lcuuid
+}
+
+// getAZs 只返回存在云服务器的可用区
+func (a *Azure) getAZs() []model.AZ {
+	log.Debug("get azs starting", logger.NewORGPrefix(a.orgID))
+	var azs []model.AZ
+	for _, az := range a.azLcuuidMap {
+		azs = append(azs, az)
+	}
+	log.Debug("get azs complete", logger.NewORGPrefix(a.orgID))
+	return azs
+}
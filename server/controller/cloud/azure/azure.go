@@ -0,0 +1,303 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"strings"
+	"time"
+
+	"github.com/bitly/go-simplejson"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	cloudconfig "github.com/deepflowio/deepflow/server/controller/cloud/config"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	metadbmodel "github.com/deepflowio/deepflow/server/controller/db/metadb/model"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+var log = logger.MustGetLogger("cloud.azure")
+
+const (
+	DEFAULT_AUTHORITY_HOST            = "https://login.microsoftonline.com"
+	DEFAULT_RESOURCE_MANAGER_ENDPOINT = "https://management.azure.com"
+)
+
+type Azure struct {
+	orgID                   int
+	teamID                  int
+	name                    string
+	lcuuid                  string
+	regionLcuuid            string
+	uuidGenerate            string
+	tenantID                string
+	clientID                string
+	clientSecret            string
+	subscriptionID          string
+	authorityHost           string
+	resourceManagerEndpoint string
+	httpTimeout             int
+	token                   string
+	tokenExpiresAt          time.Time
+	includeRegions          map[string]bool
+	includeResourceGroups   map[string]bool
+
+	// 以下属性为获取资源所用的关联关系
+	locationToDisplayName  map[string]string
+	regionLcuuidMap        map[string]string
+	azLcuuidMap            map[string]model.AZ
+	vnets                  []*simplejson.Json
+	vnetIDToLcuuid         map[string]string
+	subnetIDToNetwork      map[string]model.Network
+	networkLcuuidToSubnets map[string][]model.Subnet
+	publicIPIDToIP         map[string]string
+	publicIPToVinterface   map[string]model.VInterface
+	ipConfigIDToTarget     map[string]ipConfigTarget
+	vmIDToPrimaryIP        map[string]string
+	vmIDToVPCLcuuid        map[string]string
+}
+
+// ipConfigTarget 为网卡 IP 配置对应的云服务器及私有 IP，用于关联负载均衡后端池
+type ipConfigTarget struct {
+	vmLcuuid  string
+	ip        string
+	vpcLcuuid string
+}
+
+func NewAzure(orgID int, domain metadbmodel.Domain, cfg cloudconfig.CloudConfig) (*Azure, error) {
+	config, err := simplejson.NewJson([]byte(domain.Config))
+	if err != nil {
+		log.Error(err, logger.NewORGPrefix(orgID))
+		return nil, err
+	}
+
+	tenantID, err := config.Get("tenant_id").String()
+	if err != nil {
+		log.Error("tenant_id must be specified", logger.NewORGPrefix(orgID))
+		return nil, err
+	}
+
+	clientID, err := config.Get("client_id").String()
+	if err != nil {
+		log.Error("client_id must be specified", logger.NewORGPrefix(orgID))
+		return nil, err
+	}
+
+	clientSecret, err := config.Get("client_secret").String()
+	if err != nil {
+		log.Error("client_secret must be specified", logger.NewORGPrefix(orgID))
+		return nil, err
+	}
+
+	decryptClientSecret, err := common.DecryptSecretKey(clientSecret)
+	if err != nil {
+		log.Errorf("decrypt client_secret failed (%s)", err.Error(), logger.NewORGPrefix(orgID))
+		return nil, err
+	}
+
+	subscriptionID, err := config.Get("subscription_id").String()
+	if err != nil {
+		log.Error("subscription_id must be specified", logger.NewORGPrefix(orgID))
+		return nil, err
+	}
+
+	// 中国区等主权云需要指定认证及资源管理地址
+	authorityHost := strings.TrimSuffix(config.Get("authority_host").MustString(), "/")
+	if authorityHost == "" {
+		authorityHost = DEFAULT_AUTHORITY_HOST
+	}
+	resourceManagerEndpoint := strings.TrimSuffix(config.Get("resource_manager_endpoint").MustString(), "/")
+	if resourceManagerEndpoint == "" {
+		resourceManagerEndpoint = DEFAULT_RESOURCE_MANAGER_ENDPOINT
+	}
+
+	includeResourceGroups := map[string]bool{}
+	for rg := range cloudcommon.UniqRegions(config.Get("include_resource_groups").MustString()) {
+		includeResourceGroups[strings.ToLower(rg)] = false
+	}
+
+	return &Azure{
+		// TODO: display_name后期需要修改为uuid_generate
+		orgID:                   orgID,
+		teamID:                  domain.TeamID,
+		name:                    domain.Name,
+		lcuuid:                  domain.Lcuuid,
+		uuidGenerate:            domain.DisplayName,
+		regionLcuuid:            config.Get("region_uuid").MustString(),
+		tenantID:                tenantID,
+		clientID:                clientID,
+		clientSecret:            decryptClientSecret,
+		subscriptionID:          subscriptionID,
+		authorityHost:           authorityHost,
+		resourceManagerEndpoint: resourceManagerEndpoint,
+		httpTimeout:             cfg.HTTPTimeout,
+		includeRegions:          cloudcommon.UniqRegions(config.Get("include_regions").MustString()),
+		includeResourceGroups:   includeResourceGroups,
+	}, nil
+}
+
+func (a *Azure) CheckAuth() error {
+	_, err := a.getRawData(a.subscriptionPath("/resourcegroups"), API_VERSION_RESOURCES)
+	return err
+}
+
+func (a *Azure) ClearDebugLog() {}
+
+func (a *Azure) GetCloudData() (model.Resource, error) {
+	var resource model.Resource
+
+	a.locationToDisplayName = map[string]string{}
+	a.regionLcuuidMap = map[string]string{}
+	a.azLcuuidMap = map[string]model.AZ{}
+	a.vnets = []*simplejson.Json{}
+	a.vnetIDToLcuuid = map[string]string{}
+	a.subnetIDToNetwork = map[string]model.Network{}
+	a.networkLcuuidToSubnets = map[string][]model.Subnet{}
+	a.publicIPIDToIP = map[string]string{}
+	a.publicIPToVinterface = map[string]model.VInterface{}
+	a.ipConfigIDToTarget = map[string]ipConfigTarget{}
+	a.vmIDToPrimaryIP = map[string]string{}
+	a.vmIDToVPCLcuuid = map[string]string{}
+
+	err := a.getLocations()
+	if err != nil {
+		return model.Resource{}, err
+	}
+
+	vpcs, err := a.getVPCs()
+	if err != nil {
+		return model.Resource{}, err
+	}
+	resource.VPCs = append(resource.VPCs, vpcs...)
+
+	networks, subnets := a.getNetworks()
+	resource.Networks = append(resource.Networks, networks...)
+	resource.Subnets = append(resource.Subnets, subnets...)
+
+	err = a.getPublicIPs()
+	if err != nil {
+		return model.Resource{}, err
+	}
+
+	vinterfaces, ips, vNatRules, err := a.getVInterfacesAndIPs()
+	if err != nil {
+		return model.Resource{}, err
+	}
+	resource.VInterfaces = append(resource.VInterfaces, vinterfaces...)
+	resource.IPs = append(resource.IPs, ips...)
+	resource.NATRules = append(resource.NATRules, vNatRules...)
+
+	vms, err := a.getVMs()
+	if err != nil {
+		return model.Resource{}, err
+	}
+	resource.VMs = append(resource.VMs, vms...)
+
+	// 附属容器集群，节点为节点资源组中虚拟机规模集的实例
+	sDomains, nodeVMs, nodeVinterfaces, nodeIPs, err := a.getSubDomains()
+	if err != nil {
+		return model.Resource{}, err
+	}
+	resource.SubDomains = append(resource.SubDomains, sDomains...)
+	resource.VMs = append(resource.VMs, nodeVMs...)
+	resource.VInterfaces = append(resource.VInterfaces, nodeVinterfaces...)
+	resource.IPs = append(resource.IPs, nodeIPs...)
+
+	lbs, lbListeners, lbTargetServers, err := a.getLoadBalances()
+	if err != nil {
+		return model.Resource{}, err
+	}
+	resource.LBs = append(resource.LBs, lbs...)
+	resource.LBListeners = append(resource.LBListeners, lbListeners...)
+	resource.LBTargetServers = append(resource.LBTargetServers, lbTargetServers...)
+
+	natGateways, natVinterfaces, natIPs, err := a.getNatGateways()
+	if err != nil {
+		return model.Resource{}, err
+	}
+	resource.NATGateways = append(resource.NATGateways, natGateways...)
+	resource.VInterfaces = append(resource.VInterfaces, natVinterfaces...)
+	resource.IPs = append(resource.IPs, natIPs...)
+
+	resource.FloatingIPs = append(resource.FloatingIPs, a.getFloatingIPs()...)
+	resource.AZs = append(resource.AZs, a.getAZs()...)
+	resource.Regions = append(resource.Regions, a.getRegions()...)
+	return resource, nil
+}
+
+func (a *Azure) subscriptionPath(path string) string {
+	return "/subscriptions/" + a.subscriptionID + path
+}
+
+// inScope 判断资源所在的位置及资源组是否在白名单中，白名单为空时不过滤
+func (a *Azure) inScope(jResource *simplejson.Json) bool {
+	id := jResource.Get("id").MustString()
+	location := jResource.Get("location").MustString()
+	if len(a.includeRegions) > 0 {
+		if _, ok := a.includeRegions[location]; !ok {
+			log.Debugf("resource (%s) location (%s) not in include_regions", id, location, logger.NewORGPrefix(a.orgID))
+			return false
+		}
+	}
+	if len(a.includeResourceGroups) > 0 {
+		if _, ok := a.includeResourceGroups[resourceGroupOf(id)]; !ok {
+			log.Debugf("resource (%s) not in include_resource_groups", id, logger.NewORGPrefix(a.orgID))
+			return false
+		}
+	}
+	return true
+}
+
+func (a *Azure) getCloudTags(jResource *simplejson.Json) map[string]string {
+	tags := map[string]string{}
+	for k, v := range jResource.Get("tags").MustMap() {
+		if s, ok := v.(string); ok {
+			tags[k] = s
+		}
+	}
+	return tags
+}
+
+// normalizeID 资源 ID 大小写不敏感，不同资源中引用的 ID 大小写可能不一致，统一转为小写
+func normalizeID(id string) string {
+	return strings.ToLower(id)
+}
+
+// resourceGroupOf 返回资源 ID 中的资源组名称，如 /subscriptions/xxx/resourceGroups/<rg>/providers/...
+func resourceGroupOf(id string) string {
+	parts := strings.Split(normalizeID(id), "/")
+	for i := 0; i < len(parts)-1; i++ {
+		if parts[i] == "resourcegroups" {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
+
+// parentID 返回子资源的父资源 ID，如子网 ID 对应的虚拟网络 ID
+func parentID(id string, childType string) string {
+	id = normalizeID(id)
+	if index := strings.LastIndex(id, "/"+childType+"/"); index > 0 {
+		return id[:index]
+	}
+	return ""
+}
+
+// formatMac 将 00-0D-3A-12-34-56 格式的 MAC 地址转换为 00:0d:3a:12:34:56
+func formatMac(mac string) string {
+	return strings.ToLower(strings.ReplaceAll(mac, "-", ":"))
+}
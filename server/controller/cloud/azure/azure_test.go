@@ -0,0 +1,206 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/agiledragon/gomonkey/v2"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/deepflowio/deepflow/server/controller/cloud/config"
+	cloudtest "github.com/deepflowio/deepflow/server/controller/cloud/test"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	metadbcommon "github.com/deepflowio/deepflow/server/controller/db/metadb/common"
+	metadbmodel "github.com/deepflowio/deepflow/server/controller/db/metadb/model"
+)
+
+const (
+	testTenantID     = "tenant-1"
+	testClientSecret = "secret"
+	testToken        = "test-token"
+)
+
+// newFixtureServer serves both the token endpoint and the recorded Azure Resource Manager responses in testfiles,
+// '${ENDPOINT}' in the responses is replaced with the server address
+func newFixtureServer(t *testing.T) *httptest.Server {
+	vmss := "/subscriptions/sub-1/resourceGroups/MC_rg-aks_aks-1_eastus/providers/Microsoft.Compute/virtualMachineScaleSets"
+	return cloudtest.NewFixtureServer(t, cloudtest.Fixtures{
+		Files: map[string]string{
+			"/subscriptions/sub-1/locations":                                            "locations.json",
+			"/subscriptions/sub-1/resourcegroups":                                       "resource_groups.json",
+			"/subscriptions/sub-1/providers/Microsoft.Network/virtualNetworks":          "virtual_networks.json",
+			"/subscriptions/sub-1/providers/Microsoft.Network/publicIPAddresses":        "public_ip_addresses.json",
+			"/subscriptions/sub-1/providers/Microsoft.Network/networkInterfaces":        "network_interfaces.json",
+			"/subscriptions/sub-1/providers/Microsoft.Network/loadBalancers":            "load_balancers.json",
+			"/subscriptions/sub-1/providers/Microsoft.Network/natGateways":              "nat_gateways.json",
+			"/subscriptions/sub-1/providers/Microsoft.Compute/virtualMachines":          "virtual_machines.json",
+			"/subscriptions/sub-1/providers/Microsoft.ContainerService/managedClusters": "managed_clusters.json",
+			vmss: "virtual_machine_scale_sets.json",
+			vmss + "/aks-nodepool1-12345-vmss/networkInterfaces": "vmss_network_interfaces.json",
+			vmss + "/aks-nodepool1-12345-vmss/virtualMachines":   "vmss_virtual_machines.json",
+		},
+		PageParam:    "$skiptoken",
+		PageSuffix:   "_",
+		NotFoundBody: `{"error": {"code": "ResourceNotFound", "message": "not found"}}`,
+		Route: func(w http.ResponseWriter, r *http.Request) (string, bool) {
+			if r.URL.Path == "/"+testTenantID+"/oauth2/v2.0/token" && r.Method == http.MethodPost {
+				if r.PostFormValue("grant_type") != "client_credentials" || r.PostFormValue("client_secret") != testClientSecret {
+					w.WriteHeader(http.StatusUnauthorized)
+					w.Write([]byte(`{"error": "invalid_client", "error_description": "AADSTS7000215: Invalid client secret provided."}`))
+					return "", true
+				}
+				return "token.json", false
+			}
+			if r.Header.Get("Authorization") != "Bearer "+testToken {
+				w.WriteHeader(http.StatusUnauthorized)
+				return "", true
+			}
+			if r.URL.Query().Get("api-version") == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return "", true
+			}
+			return "", false
+		},
+	})
+}
+
+func newTestAzure(endpoint, clientSecret string) (*Azure, error) {
+	domain := metadbmodel.Domain{
+		Name:        "test_azure",
+		DisplayName: "test_azure",
+		Config: fmt.Sprintf(`{"tenant_id": "%s", "client_id": "client-1", "client_secret": "%s", "subscription_id": "sub-1", "include_regions": "eastus", "authority_host": "%s", "resource_manager_endpoint": "%s"}`,
+			testTenantID, clientSecret, endpoint, endpoint),
+	}
+	return NewAzure(metadbcommon.DEFAULT_ORG_ID, domain, config.CloudConfig{HTTPTimeout: 5})
+}
+
+func TestAzure(t *testing.T) {
+	Convey("TestAzure", t, func() {
+		patches := gomonkey.ApplyFunc(common.DecryptSecretKey, func(secretKey string) (string, error) {
+			return secretKey, nil
+		})
+		defer patches.Reset()
+
+		server := newFixtureServer(t)
+
+		Convey("wrong client secret should fail the auth check", func() {
+			azure, err := newTestAzure(server.URL, "wrong")
+			So(err, ShouldBeNil)
+			So(azure.CheckAuth(), ShouldNotBeNil)
+		})
+
+		azure, err := newTestAzure(server.URL, testClientSecret)
+		So(err, ShouldBeNil)
+		So(azure.CheckAuth(), ShouldBeNil)
+
+		data, err := azure.GetCloudData()
+		So(err, ShouldBeNil)
+
+		vpcNames := map[string]string{}
+		for _, vpc := range data.VPCs {
+			vpcNames[vpc.Lcuuid] = vpc.Name
+		}
+		vms := map[string]int{}
+		for i, vm := range data.VMs {
+			vms[vm.Name] = i
+		}
+
+		Convey("resource number should be equal", func() {
+			So(len(data.Regions), ShouldEqual, 1)
+			So(data.Regions[0].Name, ShouldEqual, "East US")
+			So(len(data.AZs), ShouldEqual, 3)
+			So(len(data.VPCs), ShouldEqual, 2)
+			So(len(data.Networks), ShouldEqual, 3)
+			So(len(data.Subnets), ShouldEqual, 4)
+			So(len(data.VMs), ShouldEqual, 5)
+			So(len(data.VInterfaces), ShouldEqual, 7)
+			So(len(data.IPs), ShouldEqual, 7)
+			So(len(data.FloatingIPs), ShouldEqual, 1)
+			So(len(data.NATRules), ShouldEqual, 1)
+			So(len(data.NATGateways), ShouldEqual, 1)
+			So(len(data.LBs), ShouldEqual, 2)
+			So(len(data.LBListeners), ShouldEqual, 2)
+			So(len(data.LBTargetServers), ShouldEqual, 4)
+			So(len(data.SubDomains), ShouldEqual, 1)
+		})
+
+		Convey("vms should be related to the vpcs of their nics", func() {
+			web := data.VMs[vms["vm-web-1"]]
+			So(vpcNames[web.VPCLcuuid], ShouldEqual, "vnet-prod")
+			So(web.IP, ShouldEqual, "10.0.1.4")
+			So(web.State, ShouldEqual, common.VM_STATE_RUNNING)
+			So(web.CloudTags, ShouldResemble, map[string]string{"env": "prod"})
+			db := data.VMs[vms["vm-db-1"]]
+			So(db.State, ShouldEqual, common.VM_STATE_STOPPED)
+			So(db.IP, ShouldEqual, "10.0.2.4")
+			So(data.VMs[vms["vm-web-2"]].AZLcuuid, ShouldNotEqual, web.AZLcuuid)
+		})
+
+		Convey("aks nodes should only keep the primary ip", func() {
+			node := data.VMs[vms["aks-nodepool1-12345-vmss000000"]]
+			So(vpcNames[node.VPCLcuuid], ShouldEqual, "aks-vnet-12345")
+			So(node.IP, ShouldEqual, "10.224.0.4")
+			So(data.VMs[vms["aks-nodepool1-12345-vmss000001"]].IP, ShouldEqual, "10.224.0.33")
+			So(data.SubDomains[0].ClusterID, ShouldEqual, "aks-1")
+			So(vpcNames[data.SubDomains[0].VpcUUID], ShouldEqual, "aks-vnet-12345")
+			ips := map[string]bool{}
+			for _, ip := range data.IPs {
+				ips[ip.IP] = true
+			}
+			So(ips["10.224.0.5"], ShouldBeFalse)
+		})
+
+		Convey("public ips should generate floating ips and dnat rules", func() {
+			So(data.FloatingIPs[0].IP, ShouldEqual, "20.1.1.1")
+			So(data.FloatingIPs[0].VMLcuuid, ShouldEqual, data.VMs[vms["vm-web-1"]].Lcuuid)
+			So(data.NATRules[0].FixedIP, ShouldEqual, "10.0.1.4")
+			So(data.NATGateways[0].FloatingIPs, ShouldEqual, "20.1.1.200")
+			So(vpcNames[data.NATGateways[0].VPCLcuuid], ShouldEqual, "vnet-prod")
+		})
+
+		Convey("lb backend pools should be related to vms", func() {
+			lbs := map[string]int{}
+			for _, lb := range data.LBs {
+				lbs[lb.Lcuuid] = lb.Model
+				if lb.Name == "lb-web" {
+					So(lb.Model, ShouldEqual, common.LB_MODEL_EXTERNAL)
+					So(lb.VIP, ShouldEqual, "20.1.1.100")
+					So(vpcNames[lb.VPCLcuuid], ShouldEqual, "vnet-prod")
+				} else {
+					So(lb.Model, ShouldEqual, common.LB_MODEL_INTERNAL)
+				}
+			}
+			servers := map[string]string{}
+			for _, ts := range data.LBTargetServers {
+				servers[ts.IP] = fmt.Sprintf("%d:%d", ts.Type, ts.Port)
+				if ts.Type == common.LB_SERVER_TYPE_VM {
+					So(ts.VMLcuuid, ShouldNotBeEmpty)
+				}
+			}
+			So(servers, ShouldResemble, map[string]string{
+				"10.0.1.4":    fmt.Sprintf("%d:8080", common.LB_SERVER_TYPE_VM),
+				"10.0.1.5":    fmt.Sprintf("%d:8080", common.LB_SERVER_TYPE_VM),
+				"10.224.0.4":  fmt.Sprintf("%d:8443", common.LB_SERVER_TYPE_VM),
+				"10.224.0.50": fmt.Sprintf("%d:8443", common.LB_SERVER_TYPE_IP),
+			})
+		})
+	})
+}
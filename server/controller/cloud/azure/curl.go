@@ -0,0 +1,147 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bitly/go-simplejson"
+
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+const (
+	API_VERSION_RESOURCES         = "2021-04-01"
+	API_VERSION_SUBSCRIPTIONS     = "2022-12-01"
+	API_VERSION_NETWORK           = "2023-09-01"
+	API_VERSION_COMPUTE           = "2023-09-01"
+	API_VERSION_CONTAINER_SERVICE = "2023-10-01"
+	API_VERSION_VMSS_NETWORK      = "2018-10-01"
+
+	TOKEN_EXPIRE_MARGIN = 60 * time.Second
+)
+
+func newErr(url, msg string) error {
+	return errors.New(fmt.Sprintf("request url: %s, %s", url, msg))
+}
+
+func (a *Azure) doRequest(req *http.Request) (*simplejson.Json, error) {
+	client := &http.Client{Timeout: time.Second * time.Duration(a.httpTimeout)}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, newErr(req.URL.String(), fmt.Sprintf("failed: %s", err.Error()))
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, newErr(req.URL.String(), fmt.Sprintf("read failed: %s", err.Error()))
+	}
+	jsonResp, err := simplejson.NewJson(respBody)
+	if resp.StatusCode != http.StatusOK {
+		// azure 的错误信息格式为 {"error": {"code": "xxx", "message": "xxx"}}，认证接口为 {"error": "xxx", "error_description": "xxx"}
+		msg := fmt.Sprintf("status code: %d", resp.StatusCode)
+		if err == nil {
+			if code := jsonResp.Get("error").Get("code").MustString(); code != "" {
+				msg += fmt.Sprintf(", %s: %s", code, jsonResp.Get("error").Get("message").MustString())
+			} else if description := jsonResp.Get("error_description").MustString(); description != "" {
+				msg += ", " + description
+			}
+		}
+		return nil, newErr(req.URL.String(), msg)
+	}
+	if err != nil {
+		return nil, newErr(req.URL.String(), fmt.Sprintf("JSONiz failed: %s", err.Error()))
+	}
+	return jsonResp, nil
+}
+
+// getToken 使用服务主体的 client credentials 获取 Azure Resource Manager 的访问令牌，令牌过期前复用
+func (a *Azure) getToken() (string, error) {
+	if a.token != "" && time.Now().Before(a.tokenExpiresAt) {
+		return a.token, nil
+	}
+
+	tokenURL := fmt.Sprintf("%s/%s/oauth2/v2.0/token", a.authorityHost, a.tenantID)
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", a.clientID)
+	form.Set("client_secret", a.clientSecret)
+	form.Set("scope", a.resourceManagerEndpoint+"/.default")
+	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", newErr(tokenURL, fmt.Sprintf("new request failed: %s", err.Error()))
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	jToken, err := a.doRequest(req)
+	if err != nil {
+		log.Errorf("get token failed (%s)", err.Error(), logger.NewORGPrefix(a.orgID))
+		return "", err
+	}
+	token := jToken.Get("access_token").MustString()
+	if token == "" {
+		err = newErr(tokenURL, "access_token not found")
+		log.Error(err.Error(), logger.NewORGPrefix(a.orgID))
+		return "", err
+	}
+	a.token = token
+	a.tokenExpiresAt = time.Now().Add(time.Duration(jToken.Get("expires_in").MustInt(3600))*time.Second - TOKEN_EXPIRE_MARGIN)
+	return a.token, nil
+}
+
+// getRawData 获取资源列表，path 中可以带有其他查询参数，会根据 nextLink 获取所有分页
+func (a *Azure) getRawData(path, apiVersion string) ([]*simplejson.Json, error) {
+	var results []*simplejson.Json
+	token, err := a.getToken()
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("api-version", apiVersion)
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	nextLink := a.resourceManagerEndpoint + path + separator + query.Encode()
+	for nextLink != "" {
+		log.Debugf("url: %s", nextLink, logger.NewORGPrefix(a.orgID))
+		req, err := http.NewRequest("GET", nextLink, nil)
+		if err != nil {
+			return nil, newErr(nextLink, fmt.Sprintf("new request failed: %s", err.Error()))
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Accept", "application/json")
+		jResp, err := a.doRequest(req)
+		if err != nil {
+			log.Errorf("request azure api error: (%s)", err.Error(), logger.NewORGPrefix(a.orgID))
+			return nil, err
+		}
+		jValues := jResp.Get("value")
+		for i := range jValues.MustArray() {
+			results = append(results, jValues.GetIndex(i))
+		}
+		nextLink = jResp.Get("nextLink").MustString()
+	}
+	return results, nil
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// getPublicIPs 获取公网 IP 地址，供网卡、负载均衡及 NAT 网关关联
+func (a *Azure) getPublicIPs() error {
+	log.Debug("get public ips starting", logger.NewORGPrefix(a.orgID))
+	jPublicIPs, err := a.getRawData(a.subscriptionPath("/providers/Microsoft.Network/publicIPAddresses"), API_VERSION_NETWORK)
+	if err != nil {
+		log.Errorf("public ip request azure api error: (%s)", err.Error(), logger.NewORGPrefix(a.orgID))
+		return err
+	}
+	for _, jPublicIP := range jPublicIPs {
+		ip := jPublicIP.Get("properties").Get("ipAddress").MustString()
+		if ip == "" {
+			log.Debugf("public ip (%s) not allocated", jPublicIP.Get("id").MustString(), logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		a.publicIPIDToIP[normalizeID(jPublicIP.Get("id").MustString())] = ip
+	}
+	log.Debug("get public ips complete", logger.NewORGPrefix(a.orgID))
+	return nil
+}
+
+func (a *Azure) getFloatingIPs() (floatingIPs []model.FloatingIP) {
+	log.Debug("get floating ips starting", logger.NewORGPrefix(a.orgID))
+	for ip, v := range a.publicIPToVinterface {
+		floatingIP := model.FloatingIP{
+			Lcuuid:        common.GetUUIDByOrgID(a.orgID, v.Lcuuid+ip),
+			IP:            ip,
+			VMLcuuid:      v.DeviceLcuuid,
+			NetworkLcuuid: common.NETWORK_ISP_LCUUID,
+			VPCLcuuid:     v.VPCLcuuid,
+			RegionLcuuid:  v.RegionLcuuid,
+		}
+		floatingIPs = append(floatingIPs, floatingIP)
+	}
+	log.Debug("get floating ips complete", logger.NewORGPrefix(a.orgID))
+	return
+}
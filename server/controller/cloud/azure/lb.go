@@ -0,0 +1,179 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"strings"
+
+	"github.com/bitly/go-simplejson"
+
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+func (a *Azure) getLoadBalances() ([]model.LB, []model.LBListener, []model.LBTargetServer, error) {
+	log.Debug("get load balances starting", logger.NewORGPrefix(a.orgID))
+	var lbs []model.LB
+	var lbListeners []model.LBListener
+	var lbTargetServers []model.LBTargetServer
+
+	jLBs, err := a.getRawData(a.subscriptionPath("/providers/Microsoft.Network/loadBalancers"), API_VERSION_NETWORK)
+	if err != nil {
+		log.Errorf("load balance request azure api error: (%s)", err.Error(), logger.NewORGPrefix(a.orgID))
+		return []model.LB{}, []model.LBListener{}, []model.LBTargetServer{}, err
+	}
+	for _, jLB := range jLBs {
+		if !a.inScope(jLB) {
+			continue
+		}
+		lbID := normalizeID(jLB.Get("id").MustString())
+		lbName := jLB.Get("name").MustString()
+		lbLcuuid := common.GetUUIDByOrgID(a.orgID, lbID)
+		jProperties := jLB.Get("properties")
+
+		// 前端 IP 配置绑定公网 IP 时为公网负载均衡
+		lbModel := common.LB_MODEL_INTERNAL
+		var vpcLcuuid string
+		var vips []string
+		frontendIDToIPs := map[string][]string{}
+		jFrontends := jProperties.Get("frontendIPConfigurations")
+		for i := range jFrontends.MustArray() {
+			jFrontend := jFrontends.GetIndex(i)
+			jFrontendProperties := jFrontend.Get("properties")
+			var frontendIPs []string
+			if privateIP := jFrontendProperties.Get("privateIPAddress").MustString(); privateIP != "" {
+				frontendIPs = append(frontendIPs, privateIP)
+				if vpcLcuuid == "" {
+					vpcLcuuid = a.vnetIDToLcuuid[parentID(jFrontendProperties.Get("subnet").Get("id").MustString(), "subnets")]
+				}
+			}
+			if publicIPID := normalizeID(jFrontendProperties.Get("publicIPAddress").Get("id").MustString()); publicIPID != "" {
+				lbModel = common.LB_MODEL_EXTERNAL
+				if publicIP := a.publicIPIDToIP[publicIPID]; publicIP != "" {
+					frontendIPs = append(frontendIPs, publicIP)
+				}
+			}
+			frontendIDToIPs[normalizeID(jFrontend.Get("id").MustString())] = frontendIPs
+			vips = append(vips, frontendIPs...)
+		}
+
+		poolIDToTargets := map[string][]ipConfigTarget{}
+		jPools := jProperties.Get("backendAddressPools")
+		for i := range jPools.MustArray() {
+			jPool := jPools.GetIndex(i)
+			targets := a.getBackendPoolTargets(jPool)
+			for _, target := range targets {
+				if vpcLcuuid == "" {
+					vpcLcuuid = target.vpcLcuuid
+				}
+			}
+			poolIDToTargets[normalizeID(jPool.Get("id").MustString())] = targets
+		}
+		if vpcLcuuid == "" {
+			log.Infof("load balance (%s) vpc not found", lbID, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		lbs = append(lbs, model.LB{
+			Lcuuid:       lbLcuuid,
+			Name:         lbName,
+			Label:        resourceGroupOf(lbID) + "/" + lbName,
+			Model:        lbModel,
+			VIP:          strings.Join(vips, ","),
+			VPCLcuuid:    vpcLcuuid,
+			RegionLcuuid: a.getRegionLcuuid(jLB.Get("location").MustString()),
+		})
+
+		jRules := jProperties.Get("loadBalancingRules")
+		for i := range jRules.MustArray() {
+			jRule := jRules.GetIndex(i)
+			jRuleProperties := jRule.Get("properties")
+			protocol := strings.ToUpper(jRuleProperties.Get("protocol").MustString())
+			listenerLcuuid := common.GetUUIDByOrgID(a.orgID, normalizeID(jRule.Get("id").MustString()))
+			lbListeners = append(lbListeners, model.LBListener{
+				Lcuuid:   listenerLcuuid,
+				LBLcuuid: lbLcuuid,
+				IPs:      strings.Join(frontendIDToIPs[normalizeID(jRuleProperties.Get("frontendIPConfiguration").Get("id").MustString())], ","),
+				Name:     jRule.Get("name").MustString(),
+				Port:     jRuleProperties.Get("frontendPort").MustInt(),
+				Protocol: protocol,
+			})
+
+			// 规则可以关联一个或多个后端池
+			poolIDs := []string{normalizeID(jRuleProperties.Get("backendAddressPool").Get("id").MustString())}
+			jRulePools := jRuleProperties.Get("backendAddressPools")
+			for j := range jRulePools.MustArray() {
+				poolIDs = append(poolIDs, normalizeID(jRulePools.GetIndex(j).Get("id").MustString()))
+			}
+			targetIPs := map[string]bool{}
+			for _, poolID := range poolIDs {
+				for _, target := range poolIDToTargets[poolID] {
+					if targetIPs[target.ip] {
+						continue
+					}
+					targetIPs[target.ip] = true
+					serverType := common.LB_SERVER_TYPE_VM
+					if target.vmLcuuid == "" {
+						serverType = common.LB_SERVER_TYPE_IP
+					}
+					lbTargetServers = append(lbTargetServers, model.LBTargetServer{
+						Lcuuid:           common.GetUUIDByOrgID(a.orgID, listenerLcuuid+target.ip),
+						LBLcuuid:         lbLcuuid,
+						LBListenerLcuuid: listenerLcuuid,
+						Type:             serverType,
+						IP:               target.ip,
+						VMLcuuid:         target.vmLcuuid,
+						Port:             jRuleProperties.Get("backendPort").MustInt(),
+						VPCLcuuid:        vpcLcuuid,
+						Protocol:         protocol,
+					})
+				}
+			}
+		}
+	}
+	log.Debug("get load balances complete", logger.NewORGPrefix(a.orgID))
+	return lbs, lbListeners, lbTargetServers, nil
+}
+
+// getBackendPoolTargets 后端池可以关联网卡的 IP 配置，也可以直接指定虚拟网络中的 IP 地址
+func (a *Azure) getBackendPoolTargets(jPool *simplejson.Json) []ipConfigTarget {
+	var targets []ipConfigTarget
+	jPoolProperties := jPool.Get("properties")
+	jIPConfigs := jPoolProperties.Get("backendIPConfigurations")
+	for i := range jIPConfigs.MustArray() {
+		ipConfigID := normalizeID(jIPConfigs.GetIndex(i).Get("id").MustString())
+		target, ok := a.ipConfigIDToTarget[ipConfigID]
+		if !ok {
+			log.Debugf("lb target server ip configuration (%s) not found", ipConfigID, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		targets = append(targets, target)
+	}
+	jAddresses := jPoolProperties.Get("loadBalancerBackendAddresses")
+	for i := range jAddresses.MustArray() {
+		jAddressProperties := jAddresses.GetIndex(i).Get("properties")
+		ip := jAddressProperties.Get("ipAddress").MustString()
+		if ip == "" {
+			continue
+		}
+		targets = append(targets, ipConfigTarget{
+			ip:        ip,
+			vpcLcuuid: a.vnetIDToLcuuid[normalizeID(jAddressProperties.Get("virtualNetwork").Get("id").MustString())],
+		})
+	}
+	return targets
+}
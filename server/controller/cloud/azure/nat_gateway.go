@@ -0,0 +1,102 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"strings"
+
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+func (a *Azure) getNatGateways() ([]model.NATGateway, []model.VInterface, []model.IP, error) {
+	log.Debug("get nat gateways starting", logger.NewORGPrefix(a.orgID))
+	var natGateways []model.NATGateway
+	var natVinterfaces []model.VInterface
+	var natIPs []model.IP
+
+	jNatGateways, err := a.getRawData(a.subscriptionPath("/providers/Microsoft.Network/natGateways"), API_VERSION_NETWORK)
+	if err != nil {
+		log.Errorf("nat gateway request azure api error: (%s)", err.Error(), logger.NewORGPrefix(a.orgID))
+		return []model.NATGateway{}, []model.VInterface{}, []model.IP{}, err
+	}
+	for _, jNatGateway := range jNatGateways {
+		if !a.inScope(jNatGateway) {
+			continue
+		}
+		natGatewayID := normalizeID(jNatGateway.Get("id").MustString())
+		jProperties := jNatGateway.Get("properties")
+		if jProperties.Get("provisioningState").MustString() != "Succeeded" {
+			log.Infof("nat gateway (%s) is not available", natGatewayID, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		// NAT 网关关联的子网均在同一虚拟网络中
+		var vpcLcuuid string
+		jSubnets := jProperties.Get("subnets")
+		for i := range jSubnets.MustArray() {
+			if vpcLcuuid = a.vnetIDToLcuuid[parentID(jSubnets.GetIndex(i).Get("id").MustString(), "subnets")]; vpcLcuuid != "" {
+				break
+			}
+		}
+		if vpcLcuuid == "" {
+			log.Infof("nat gateway (%s) not associated with any subnet", natGatewayID, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		floatingIPs := []string{}
+		jPublicIPs := jProperties.Get("publicIpAddresses")
+		for i := range jPublicIPs.MustArray() {
+			if ip := a.publicIPIDToIP[normalizeID(jPublicIPs.GetIndex(i).Get("id").MustString())]; ip != "" {
+				floatingIPs = append(floatingIPs, ip)
+			}
+		}
+		natGatewayLcuuid := common.GetUUIDByOrgID(a.orgID, natGatewayID)
+		regionLcuuid := a.getRegionLcuuid(jNatGateway.Get("location").MustString())
+		natGatewayName := jNatGateway.Get("name").MustString()
+		natGateways = append(natGateways, model.NATGateway{
+			Lcuuid:       natGatewayLcuuid,
+			Name:         natGatewayName,
+			Label:        resourceGroupOf(natGatewayID) + "/" + natGatewayName,
+			FloatingIPs:  strings.Join(floatingIPs, ","),
+			VPCLcuuid:    vpcLcuuid,
+			RegionLcuuid: regionLcuuid,
+		})
+
+		vinterfaceLcuuid := common.GetUUIDByOrgID(a.orgID, natGatewayLcuuid)
+		natVinterfaces = append(natVinterfaces, model.VInterface{
+			Lcuuid:        vinterfaceLcuuid,
+			Type:          common.VIF_TYPE_WAN,
+			Mac:           common.VIF_DEFAULT_MAC,
+			DeviceLcuuid:  natGatewayLcuuid,
+			DeviceType:    common.VIF_DEVICE_TYPE_NAT_GATEWAY,
+			NetworkLcuuid: common.NETWORK_ISP_LCUUID,
+			VPCLcuuid:     vpcLcuuid,
+			RegionLcuuid:  regionLcuuid,
+		})
+
+		for _, ip := range floatingIPs {
+			natIPs = append(natIPs, model.IP{
+				IP:               ip,
+				VInterfaceLcuuid: vinterfaceLcuuid,
+				RegionLcuuid:     regionLcuuid,
+				Lcuuid:           common.GetUUIDByOrgID(a.orgID, vinterfaceLcuuid+ip),
+			})
+		}
+	}
+	log.Debug("get nat gateways complete", logger.NewORGPrefix(a.orgID))
+	return natGateways, natVinterfaces, natIPs, nil
+}
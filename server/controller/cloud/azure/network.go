@@ -0,0 +1,87 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"net"
+
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// getNetworks 虚拟网络中的每个子网对应一个网络，子网的每个地址前缀对应一个子网
+func (a *Azure) getNetworks() ([]model.Network, []model.Subnet) {
+	log.Debug("get networks starting", logger.NewORGPrefix(a.orgID))
+	var networks []model.Network
+	var subnets []model.Subnet
+
+	for _, jVNet := range a.vnets {
+		vpcLcuuid := a.vnetIDToLcuuid[normalizeID(jVNet.Get("id").MustString())]
+		regionLcuuid := a.getRegionLcuuid(jVNet.Get("location").MustString())
+		jSubnets := jVNet.Get("properties").Get("subnets")
+		for i := range jSubnets.MustArray() {
+			jSubnet := jSubnets.GetIndex(i)
+			subnetID := normalizeID(jSubnet.Get("id").MustString())
+			networkName := jSubnet.Get("name").MustString()
+			networkLcuuid := common.GetUUIDByOrgID(a.orgID, subnetID)
+			network := model.Network{
+				Lcuuid:         networkLcuuid,
+				Name:           networkName,
+				SegmentationID: 1,
+				VPCLcuuid:      vpcLcuuid,
+				Shared:         false,
+				External:       false,
+				NetType:        common.NETWORK_TYPE_LAN,
+				RegionLcuuid:   regionLcuuid,
+			}
+			networks = append(networks, network)
+			a.subnetIDToNetwork[subnetID] = network
+
+			jProperties := jSubnet.Get("properties")
+			cidrs := jProperties.Get("addressPrefixes").MustStringArray()
+			if prefix := jProperties.Get("addressPrefix").MustString(); prefix != "" {
+				cidrs = append(cidrs, prefix)
+			}
+			for _, cidr := range cidrs {
+				subnet := model.Subnet{
+					Lcuuid:        common.GetUUIDByOrgID(a.orgID, networkLcuuid+cidr),
+					Name:          networkName,
+					CIDR:          cidr,
+					VPCLcuuid:     vpcLcuuid,
+					NetworkLcuuid: networkLcuuid,
+				}
+				subnets = append(subnets, subnet)
+				a.networkLcuuidToSubnets[networkLcuuid] = append(a.networkLcuuidToSubnets[networkLcuuid], subnet)
+			}
+		}
+	}
+	log.Debug("get networks complete", logger.NewORGPrefix(a.orgID))
+	return networks, subnets
+}
+
+// getSubnetLcuuid 返回网络中包含该 IP 的子网
+func (a *Azure) getSubnetLcuuid(networkLcuuid, ip string) string {
+	netIP := net.ParseIP(ip)
+	for _, subnet := range a.networkLcuuidToSubnets[networkLcuuid] {
+		_, ipNet, err := net.ParseCIDR(subnet.CIDR)
+		if err == nil && ipNet.Contains(netIP) {
+			return subnet.Lcuuid
+		}
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+func (a *Azure) getLocations() error {
+	log.Debug("get locations starting", logger.NewORGPrefix(a.orgID))
+	jLocations, err := a.getRawData(a.subscriptionPath("/locations"), API_VERSION_SUBSCRIPTIONS)
+	if err != nil {
+		return err
+	}
+	for _, jLocation := range jLocations {
+		a.locationToDisplayName[jLocation.Get("name").MustString()] = jLocation.Get("displayName").MustString()
+	}
+	log.Debug("get locations complete", logger.NewORGPrefix(a.orgID))
+	return nil
+}
+
+// getRegionLcuuid 未指定 region_uuid 时，每个位置（如 eastus）对应一个区域
+func (a *Azure) getRegionLcuuid(location string) string {
+	if a.regionLcuuid != "" {
+		return a.regionLcuuid
+	}
+	lcuuid := common.GetUUIDByOrgID(a.orgID, a.uuidGenerate+"_"+location)
+	a.regionLcuuidMap[location] = lcuuid
+	return lcuuid
+}
+
+// getRegions 只返回存在资源的区域
+func (a *Azure) getRegions() []model.Region {
+	log.Debug("get regions starting", logger.NewORGPrefix(a.orgID))
+	var regions []model.Region
+	for location, lcuuid := range a.regionLcuuidMap {
+		name := a.locationToDisplayName[location]
+		if name == "" {
+			name = location
+		}
+		regions = append(regions, model.Region{
+			Lcuuid: lcuuid,
+			Label:  location,
+			Name:   name,
+		})
+	}
+	log.Debug("get regions complete", logger.NewORGPrefix(a.orgID))
+	return regions
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"encoding/json"
+
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// getSubDomains 获取 AKS 集群作为附属容器集群，并同步集群节点资源组中虚拟机规模集的实例作为云服务器
+func (a *Azure) getSubDomains() ([]model.SubDomain, []model.VM, []model.VInterface, []model.IP, error) {
+	log.Debug("get sub_domains starting", logger.NewORGPrefix(a.orgID))
+	var retSubDomains []model.SubDomain
+	var nodeVMs []model.VM
+	var nodeVinterfaces []model.VInterface
+	var nodeIPs []model.IP
+
+	jClusters, err := a.getRawData(a.subscriptionPath("/providers/Microsoft.ContainerService/managedClusters"), API_VERSION_CONTAINER_SERVICE)
+	if err != nil {
+		log.Errorf("subdomains request azure api error: (%s)", err.Error(), logger.NewORGPrefix(a.orgID))
+		return []model.SubDomain{}, []model.VM{}, []model.VInterface{}, []model.IP{}, err
+	}
+	for _, jCluster := range jClusters {
+		if !a.inScope(jCluster) {
+			continue
+		}
+		name := jCluster.Get("name").MustString()
+		jProperties := jCluster.Get("properties")
+		vms, vinterfaces, ips, err := a.getAKSNodes(jProperties.Get("nodeResourceGroup").MustString())
+		if err != nil {
+			return []model.SubDomain{}, []model.VM{}, []model.VInterface{}, []model.IP{}, err
+		}
+		nodeVMs = append(nodeVMs, vms...)
+		nodeVinterfaces = append(nodeVinterfaces, vinterfaces...)
+		nodeIPs = append(nodeIPs, ips...)
+
+		// 使用自定义虚拟网络时，节点池指定了子网，否则虚拟网络位于节点资源组中，以节点所在的 VPC 为准
+		var vpcLcuuid string
+		jPools := jProperties.Get("agentPoolProfiles")
+		for i := range jPools.MustArray() {
+			if subnetID := jPools.GetIndex(i).Get("vnetSubnetID").MustString(); subnetID != "" {
+				vpcLcuuid = a.vnetIDToLcuuid[parentID(subnetID, "subnets")]
+				break
+			}
+		}
+		if vpcLcuuid == "" && len(vms) > 0 {
+			vpcLcuuid = vms[0].VPCLcuuid
+		}
+		if vpcLcuuid == "" {
+			log.Debugf("cluster (%s) vpc not found", name, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		config := map[string]interface{}{
+			"cluster_id":                 name,
+			"region_uuid":                a.getRegionLcuuid(jCluster.Get("location").MustString()),
+			"vpc_uuid":                   vpcLcuuid,
+			"port_name_regex":            common.DEFAULT_PORT_NAME_REGEX,
+			"pod_net_ipv4_cidr_max_mask": common.K8S_POD_IPV4_NETMASK,
+			"pod_net_ipv6_cidr_max_mask": common.K8S_POD_IPV6_NETMASK,
+		}
+		configJson, _ := json.Marshal(config)
+		retSubDomains = append(retSubDomains, model.SubDomain{
+			TeamID:      a.teamID,
+			Lcuuid:      common.GetUUIDByOrgID(a.orgID, normalizeID(jCluster.Get("id").MustString())),
+			Name:        name,
+			DisplayName: name,
+			ClusterID:   name,
+			VpcUUID:     vpcLcuuid,
+			Config:      string(configJson),
+		})
+	}
+	log.Debug("get sub_domains complete", logger.NewORGPrefix(a.orgID))
+	return retSubDomains, nodeVMs, nodeVinterfaces, nodeIPs, nil
+}
+
+// getAKSNodes 虚拟机规模集的网卡不在订阅的网卡列表中，需要按规模集获取
+func (a *Azure) getAKSNodes(nodeResourceGroup string) ([]model.VM, []model.VInterface, []model.IP, error) {
+	var vms []model.VM
+	var vinterfaces []model.VInterface
+	var ips []model.IP
+	if nodeResourceGroup == "" {
+		return vms, vinterfaces, ips, nil
+	}
+
+	jScaleSets, err := a.getRawData(a.subscriptionPath("/resourceGroups/"+nodeResourceGroup+"/providers/Microsoft.Compute/virtualMachineScaleSets"), API_VERSION_COMPUTE)
+	if err != nil {
+		log.Errorf("vmss request azure api error: (%s)", err.Error(), logger.NewORGPrefix(a.orgID))
+		return nil, nil, nil, err
+	}
+	for _, jScaleSet := range jScaleSets {
+		scaleSetID := jScaleSet.Get("id").MustString()
+		jNICs, err := a.getRawData(scaleSetID+"/networkInterfaces", API_VERSION_VMSS_NETWORK)
+		if err != nil {
+			log.Errorf("vmss vinterface request azure api error: (%s)", err.Error(), logger.NewORGPrefix(a.orgID))
+			return nil, nil, nil, err
+		}
+		for _, jNIC := range jNICs {
+			nicVinterfaces, nicIPs, _ := a.formatVInterfaceAndIPs(jNIC, true)
+			vinterfaces = append(vinterfaces, nicVinterfaces...)
+			ips = append(ips, nicIPs...)
+		}
+
+		jInstances, err := a.getRawData(scaleSetID+"/virtualMachines?$expand=instanceView", API_VERSION_COMPUTE)
+		if err != nil {
+			log.Errorf("vmss vm request azure api error: (%s)", err.Error(), logger.NewORGPrefix(a.orgID))
+			return nil, nil, nil, err
+		}
+		for _, jInstance := range jInstances {
+			// 实例名称为序号，使用与 kubernetes 节点名称一致的计算机名称
+			name := jInstance.Get("properties").Get("osProfile").Get("computerName").MustString()
+			if name == "" {
+				name = jInstance.Get("name").MustString()
+			}
+			vm, ok := a.formatVM(jInstance, name)
+			if !ok {
+				continue
+			}
+			vms = append(vms, vm)
+		}
+	}
+	return vms, vinterfaces, ips, nil
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"github.com/bitly/go-simplejson"
+
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+func (a *Azure) getVInterfacesAndIPs() ([]model.VInterface, []model.IP, []model.NATRule, error) {
+	log.Debug("get vinterfaces,ips starting", logger.NewORGPrefix(a.orgID))
+	var vinterfaces []model.VInterface
+	var ips []model.IP
+	var vNatRules []model.NATRule
+
+	jNICs, err := a.getRawData(a.subscriptionPath("/providers/Microsoft.Network/networkInterfaces"), API_VERSION_NETWORK)
+	if err != nil {
+		log.Errorf("vinterface request azure api error: (%s)", err.Error(), logger.NewORGPrefix(a.orgID))
+		return []model.VInterface{}, []model.IP{}, []model.NATRule{}, err
+	}
+	for _, jNIC := range jNICs {
+		if !a.inScope(jNIC) {
+			continue
+		}
+		nicVinterfaces, nicIPs, nicNatRules := a.formatVInterfaceAndIPs(jNIC, false)
+		vinterfaces = append(vinterfaces, nicVinterfaces...)
+		ips = append(ips, nicIPs...)
+		vNatRules = append(vNatRules, nicNatRules...)
+	}
+	log.Debug("get vinterfaces,ips complete", logger.NewORGPrefix(a.orgID))
+	return vinterfaces, ips, vNatRules, nil
+}
+
+// formatVInterfaceAndIPs 将网卡转换为接口及 IP，网卡的 IP 配置绑定了公网 IP 时生成 WAN 接口、公网 IP 及 DNAT 规则
+// AKS 节点使用 Azure CNI 时，网卡的辅助 IP 配置为 pod IP，primaryOnly 为 true 时只保留主 IP 配置
+func (a *Azure) formatVInterfaceAndIPs(jNIC *simplejson.Json, primaryOnly bool) ([]model.VInterface, []model.IP, []model.NATRule) {
+	var vinterfaces []model.VInterface
+	var ips []model.IP
+	var vNatRules []model.NATRule
+
+	nicID := normalizeID(jNIC.Get("id").MustString())
+	jProperties := jNIC.Get("properties")
+	vmID := normalizeID(jProperties.Get("virtualMachine").Get("id").MustString())
+	if vmID == "" {
+		log.Debugf("vinterface (%s) not binding device", nicID, logger.NewORGPrefix(a.orgID))
+		return nil, nil, nil
+	}
+	// 已释放（deallocated）的云服务器的网卡没有 MAC 地址
+	mac := formatMac(jProperties.Get("macAddress").MustString())
+	if mac == "" {
+		mac = common.VIF_DEFAULT_MAC
+	}
+	nicPrimary := jProperties.Get("primary").MustBool()
+	deviceLcuuid := common.GetUUIDByOrgID(a.orgID, vmID)
+	vinterfaceLcuuid := common.GetUUIDByOrgID(a.orgID, nicID)
+
+	var vinterface *model.VInterface
+	hasWANVInterface := false
+	jIPConfigs := jProperties.Get("ipConfigurations")
+	for i := range jIPConfigs.MustArray() {
+		jIPConfig := jIPConfigs.GetIndex(i)
+		jIPProperties := jIPConfig.Get("properties")
+		primary := jIPProperties.Get("primary").MustBool()
+		if primaryOnly && !primary {
+			continue
+		}
+		subnetID := normalizeID(jIPProperties.Get("subnet").Get("id").MustString())
+		network, ok := a.subnetIDToNetwork[subnetID]
+		if !ok {
+			log.Debugf("vinterface (%s) subnet (%s) not found", nicID, subnetID, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		if vinterface == nil {
+			vinterface = &model.VInterface{
+				Lcuuid:        vinterfaceLcuuid,
+				Type:          common.VIF_TYPE_LAN,
+				Mac:           mac,
+				DeviceLcuuid:  deviceLcuuid,
+				DeviceType:    common.VIF_DEVICE_TYPE_VM,
+				NetworkLcuuid: network.Lcuuid,
+				VPCLcuuid:     network.VPCLcuuid,
+				RegionLcuuid:  network.RegionLcuuid,
+			}
+			vinterfaces = append(vinterfaces, *vinterface)
+		}
+
+		privateIP := jIPProperties.Get("privateIPAddress").MustString()
+		if privateIP == "" {
+			continue
+		}
+		ips = append(ips, model.IP{
+			Lcuuid:           common.GetUUIDByOrgID(a.orgID, vinterfaceLcuuid+privateIP),
+			VInterfaceLcuuid: vinterfaceLcuuid,
+			IP:               privateIP,
+			SubnetLcuuid:     a.getSubnetLcuuid(network.Lcuuid, privateIP),
+			RegionLcuuid:     network.RegionLcuuid,
+		})
+		if primary {
+			if _, ok := a.vmIDToPrimaryIP[vmID]; !ok || nicPrimary {
+				a.vmIDToPrimaryIP[vmID] = privateIP
+				a.vmIDToVPCLcuuid[vmID] = network.VPCLcuuid
+			}
+		}
+		a.ipConfigIDToTarget[normalizeID(jIPConfig.Get("id").MustString())] = ipConfigTarget{
+			vmLcuuid:  deviceLcuuid,
+			ip:        privateIP,
+			vpcLcuuid: network.VPCLcuuid,
+		}
+
+		publicIP := a.publicIPIDToIP[normalizeID(jIPProperties.Get("publicIPAddress").Get("id").MustString())]
+		if publicIP == "" {
+			continue
+		}
+		wanVinterfaceLcuuid := common.GetUUIDByOrgID(a.orgID, vinterfaceLcuuid)
+		if !hasWANVInterface {
+			hasWANVInterface = true
+			vinterfaces = append(vinterfaces, model.VInterface{
+				Lcuuid:        wanVinterfaceLcuuid,
+				Type:          common.VIF_TYPE_WAN,
+				Mac:           "ff" + mac[2:],
+				DeviceLcuuid:  deviceLcuuid,
+				DeviceType:    common.VIF_DEVICE_TYPE_VM,
+				NetworkLcuuid: common.NETWORK_ISP_LCUUID,
+				VPCLcuuid:     network.VPCLcuuid,
+				RegionLcuuid:  network.RegionLcuuid,
+			})
+		}
+		ips = append(ips, model.IP{
+			Lcuuid:           common.GetUUIDByOrgID(a.orgID, vinterfaceLcuuid+publicIP),
+			VInterfaceLcuuid: wanVinterfaceLcuuid,
+			IP:               publicIP,
+			RegionLcuuid:     network.RegionLcuuid,
+		})
+		a.publicIPToVinterface[publicIP] = *vinterface
+		vNatRules = append(vNatRules, model.NATRule{
+			Lcuuid:           common.GetUUIDByOrgID(a.orgID, publicIP+vinterfaceLcuuid+privateIP),
+			Type:             "DNAT",
+			Protocol:         "ALL",
+			FloatingIP:       publicIP,
+			FixedIP:          privateIP,
+			VInterfaceLcuuid: vinterfaceLcuuid,
+		})
+	}
+	return vinterfaces, ips, vNatRules
+}
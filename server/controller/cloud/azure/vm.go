@@ -0,0 +1,91 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"time"
+
+	"github.com/bitly/go-simplejson"
+
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+var vmStates = map[string]int{
+	"PowerState/running":     common.VM_STATE_RUNNING,
+	"PowerState/stopped":     common.VM_STATE_STOPPED,
+	"PowerState/deallocated": common.VM_STATE_STOPPED,
+}
+
+func (a *Azure) getVMs() ([]model.VM, error) {
+	log.Debug("get vms starting", logger.NewORGPrefix(a.orgID))
+	var vms []model.VM
+
+	// statusOnly 时返回结果中带有实例视图，可以获取电源状态
+	jVMs, err := a.getRawData(a.subscriptionPath("/providers/Microsoft.Compute/virtualMachines?statusOnly=true"), API_VERSION_COMPUTE)
+	if err != nil {
+		log.Errorf("vm request azure api error: (%s)", err.Error(), logger.NewORGPrefix(a.orgID))
+		return []model.VM{}, err
+	}
+	for _, jVM := range jVMs {
+		if !a.inScope(jVM) {
+			continue
+		}
+		vm, ok := a.formatVM(jVM, jVM.Get("name").MustString())
+		if !ok {
+			continue
+		}
+		vms = append(vms, vm)
+	}
+	log.Debug("get vms complete", logger.NewORGPrefix(a.orgID))
+	return vms, nil
+}
+
+// formatVM 云服务器及虚拟机规模集实例的结构相同，云服务器所属 VPC 及 IP 由其网卡确定
+func (a *Azure) formatVM(jVM *simplejson.Json, name string) (model.VM, bool) {
+	vmID := normalizeID(jVM.Get("id").MustString())
+	vpcLcuuid, ok := a.vmIDToVPCLcuuid[vmID]
+	if !ok {
+		log.Infof("vm (%s) vpc not found", vmID, logger.NewORGPrefix(a.orgID))
+		return model.VM{}, false
+	}
+	jProperties := jVM.Get("properties")
+	vmState := common.VM_STATE_EXCEPTION
+	jStatuses := jProperties.Get("instanceView").Get("statuses")
+	for i := range jStatuses.MustArray() {
+		if state, ok := vmStates[jStatuses.GetIndex(i).Get("code").MustString()]; ok {
+			vmState = state
+			break
+		}
+	}
+	createdAt, _ := time.Parse(time.RFC3339, jProperties.Get("timeCreated").MustString())
+	location := jVM.Get("location").MustString()
+	return model.VM{
+		Lcuuid:       common.GetUUIDByOrgID(a.orgID, vmID),
+		Name:         name,
+		Label:        resourceGroupOf(vmID) + "/" + jVM.Get("name").MustString(),
+		VPCLcuuid:    vpcLcuuid,
+		State:        vmState,
+		HType:        common.VM_HTYPE_VM_C,
+		IP:           a.vmIDToPrimaryIP[vmID],
+		CreatedAt:    createdAt,
+		AZLcuuid:     a.getAZLcuuid(jVM),
+		RegionLcuuid: a.getRegionLcuuid(location),
+		CloudTags:    a.getCloudTags(jVM),
+	}, true
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"strings"
+
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+func (a *Azure) getVPCs() ([]model.VPC, error) {
+	log.Debug("get vpcs starting", logger.NewORGPrefix(a.orgID))
+	var vpcs []model.VPC
+
+	jVNets, err := a.getRawData(a.subscriptionPath("/providers/Microsoft.Network/virtualNetworks"), API_VERSION_NETWORK)
+	if err != nil {
+		log.Errorf("vpc request azure api error: (%s)", err.Error(), logger.NewORGPrefix(a.orgID))
+		return []model.VPC{}, err
+	}
+	for _, jVNet := range jVNets {
+		if !a.inScope(jVNet) {
+			continue
+		}
+		vnetID := normalizeID(jVNet.Get("id").MustString())
+		vpcLcuuid := common.GetUUIDByOrgID(a.orgID, vnetID)
+		var cidrs []string
+		for _, prefix := range jVNet.Get("properties").Get("addressSpace").Get("addressPrefixes").MustArray() {
+			if cidr, ok := prefix.(string); ok {
+				cidrs = append(cidrs, cidr)
+			}
+		}
+		vpcs = append(vpcs, model.VPC{
+			Lcuuid:       vpcLcuuid,
+			Name:         jVNet.Get("name").MustString(),
+			CIDR:         strings.Join(cidrs, ","),
+			Label:        resourceGroupOf(vnetID) + "/" + jVNet.Get("name").MustString(),
+			RegionLcuuid: a.getRegionLcuuid(jVNet.Get("location").MustString()),
+		})
+		a.vnetIDToLcuuid[vnetID] = vpcLcuuid
+		a.vnets = append(a.vnets, jVNet)
+	}
+	log.Debug("get vpcs complete", logger.NewORGPrefix(a.orgID))
+	return vpcs, nil
+}
@@ -0,0 +1,47 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+func (g *GCP) getAZLcuuid(zone string) string {
+	lcuuid := common.GetUUIDByOrgID(g.orgID, g.uuidGenerate+"_"+zone)
+	if _, ok := g.azLcuuidMap[lcuuid]; !ok {
+		g.azLcuuidMap[lcuuid] = model.AZ{
+			Lcuuid:       lcuuid,
+			Label:        zone,
+			Name:         zone,
+			RegionLcuuid: g.getRegionLcuuid(zoneToRegion(zone)),
+		}
+	}
+	return lcuuid
+}
+
+// getAZs 只返回存在云服务器的可用区
+func (g *GCP) getAZs() []model.AZ {
+	log.Debug("get azs starting", logger.NewORGPrefix(g.orgID))
+	var azs []model.AZ
+	for _, az := range g.azLcuuidMap {
+		azs = append(azs, az)
+	}
+	log.Debug("get azs complete", logger.NewORGPrefix(g.orgID))
+	return azs
+}
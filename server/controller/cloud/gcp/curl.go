@@ -0,0 +1,142 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/bitly/go-simplejson"
+
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+func newErr(url, msg string) error {
+	return errors.New(fmt.Sprintf("request url: %s, %s", url, msg))
+}
+
+func (g *GCP) doRequest(method, url string, body []byte) (*simplejson.Json, error) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		return nil, newErr(url, fmt.Sprintf("new request failed: %s", err.Error()))
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	// 令牌获取失败时，错误信息中带有认证接口返回的内容
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, newErr(url, fmt.Sprintf("failed: %s", err.Error()))
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, newErr(url, fmt.Sprintf("read failed: %s", err.Error()))
+	}
+	jsonResp, err := simplejson.NewJson(respBody)
+	if resp.StatusCode != http.StatusOK {
+		// gcp 的错误信息格式为 {"error": {"code": 403, "message": "xxx", "status": "PERMISSION_DENIED"}}
+		msg := fmt.Sprintf("status code: %d", resp.StatusCode)
+		if err == nil {
+			if message := jsonResp.Get("error").Get("message").MustString(); message != "" {
+				msg += fmt.Sprintf(", %s: %s", jsonResp.Get("error").Get("status").MustString(), message)
+			}
+		}
+		return nil, newErr(url, msg)
+	}
+	if err != nil {
+		return nil, newErr(url, fmt.Sprintf("JSONiz failed: %s", err.Error()))
+	}
+	return jsonResp, nil
+}
+
+// getRawDetail 获取单个资源的详情
+func (g *GCP) getRawDetail(path string) (*simplejson.Json, error) {
+	jResp, err := g.doRequest("GET", g.computeEndpoint+path, nil)
+	if err != nil {
+		log.Errorf("request gcp api error: (%s)", err.Error(), logger.NewORGPrefix(g.orgID))
+		return nil, err
+	}
+	return jResp, nil
+}
+
+// requestPages 根据 nextPageToken 获取所有分页，每页的返回结果交由 handle 处理
+func (g *GCP) requestPages(method, path string, body []byte, handle func(jResp *simplejson.Json)) error {
+	pageToken := ""
+	for {
+		query := url.Values{}
+		query.Set("maxResults", "500")
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		url := g.computeEndpoint + path + "?" + query.Encode()
+		log.Debugf("url: %s", url, logger.NewORGPrefix(g.orgID))
+		jResp, err := g.doRequest(method, url, body)
+		if err != nil {
+			log.Errorf("request gcp api error: (%s)", err.Error(), logger.NewORGPrefix(g.orgID))
+			return err
+		}
+		handle(jResp)
+		if pageToken = jResp.Get("nextPageToken").MustString(); pageToken == "" {
+			return nil
+		}
+	}
+}
+
+// getRawData 获取资源列表
+func (g *GCP) getRawData(path string) ([]*simplejson.Json, error) {
+	var results []*simplejson.Json
+	err := g.requestPages("GET", path, nil, func(jResp *simplejson.Json) {
+		jItems := jResp.Get("items")
+		for i := range jItems.MustArray() {
+			results = append(results, jItems.GetIndex(i))
+		}
+	})
+	return results, err
+}
+
+// getAggregatedData 获取项目中所有区域及可用区的资源列表，返回结果按区域或可用区分组，
+// 如 {"items": {"regions/us-central1": {"subnetworks": [...]}, "regions/us-east1": {"warning": {...}}}}
+func (g *GCP) getAggregatedData(path, key string) ([]*simplejson.Json, error) {
+	var results []*simplejson.Json
+	err := g.requestPages("GET", path, nil, func(jResp *simplejson.Json) {
+		jItems := jResp.Get("items")
+		var scopes []string
+		for scope := range jItems.MustMap() {
+			scopes = append(scopes, scope)
+		}
+		sort.Strings(scopes)
+		for _, scope := range scopes {
+			jScopeItems := jItems.Get(scope).Get(key)
+			for i := range jScopeItems.MustArray() {
+				results = append(results, jScopeItems.GetIndex(i))
+			}
+		}
+	})
+	return results, err
+}
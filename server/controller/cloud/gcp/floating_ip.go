@@ -0,0 +1,57 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// getAddresses 获取预留的 IP 地址，供 Cloud NAT 关联
+func (g *GCP) getAddresses(project string) error {
+	log.Debug("get addresses starting", logger.NewORGPrefix(g.orgID))
+	jAddresses, err := g.getAggregatedData("/projects/"+project+"/aggregated/addresses", "addresses")
+	if err != nil {
+		log.Errorf("address request gcp api error: (%s)", err.Error(), logger.NewORGPrefix(g.orgID))
+		return err
+	}
+	for _, jAddress := range jAddresses {
+		if ip := jAddress.Get("address").MustString(); ip != "" {
+			g.addressPathToIP[resourcePath(jAddress.Get("selfLink").MustString())] = ip
+		}
+	}
+	log.Debug("get addresses complete", logger.NewORGPrefix(g.orgID))
+	return nil
+}
+
+func (g *GCP) getFloatingIPs() (floatingIPs []model.FloatingIP) {
+	log.Debug("get floating ips starting", logger.NewORGPrefix(g.orgID))
+	for ip, v := range g.natIPToVinterface {
+		floatingIP := model.FloatingIP{
+			Lcuuid:        common.GetUUIDByOrgID(g.orgID, v.Lcuuid+ip),
+			IP:            ip,
+			VMLcuuid:      v.DeviceLcuuid,
+			NetworkLcuuid: common.NETWORK_ISP_LCUUID,
+			VPCLcuuid:     v.VPCLcuuid,
+			RegionLcuuid:  v.RegionLcuuid,
+		}
+		floatingIPs = append(floatingIPs, floatingIP)
+	}
+	log.Debug("get floating ips complete", logger.NewORGPrefix(g.orgID))
+	return
+}
@@ -0,0 +1,283 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bitly/go-simplejson"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/jwt"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	cloudconfig "github.com/deepflowio/deepflow/server/controller/cloud/config"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	metadbmodel "github.com/deepflowio/deepflow/server/controller/db/metadb/model"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+var log = logger.MustGetLogger("cloud.gcp")
+
+const (
+	DEFAULT_TOKEN_URI        = "https://oauth2.googleapis.com/token"
+	DEFAULT_COMPUTE_ENDPOINT = "https://compute.googleapis.com/compute/v1"
+	COMPUTE_READONLY_SCOPE   = "https://www.googleapis.com/auth/compute.readonly"
+)
+
+type GCP struct {
+	orgID           int
+	teamID          int
+	name            string
+	lcuuid          string
+	regionLcuuid    string
+	uuidGenerate    string
+	projects        []string
+	computeEndpoint string
+	client          *http.Client
+	includeRegions  map[string]bool
+
+	// 以下属性为获取资源所用的关联关系
+	regionLcuuidMap        map[string]string
+	azLcuuidMap            map[string]model.AZ
+	networkPathToRegion    map[string]string
+	networkPathToCIDRs     map[string][]string
+	subnetPathToNetwork    map[string]model.Network
+	networkLcuuidToSubnets map[string][]model.Subnet
+	addressPathToIP        map[string]string
+	natIPToVinterface      map[string]model.VInterface
+	instancePathToTarget   map[string]instanceTarget
+}
+
+// instanceTarget 为云服务器的主网卡 IP 及所属 VPC、区域，用于关联负载均衡后端实例组
+type instanceTarget struct {
+	vmLcuuid     string
+	ip           string
+	vpcLcuuid    string
+	regionLcuuid string
+}
+
+func NewGCP(orgID int, domain metadbmodel.Domain, cfg cloudconfig.CloudConfig) (*GCP, error) {
+	config, err := simplejson.NewJson([]byte(domain.Config))
+	if err != nil {
+		log.Error(err, logger.NewORGPrefix(orgID))
+		return nil, err
+	}
+
+	serviceAccountKey, err := config.Get("service_account_key").String()
+	if err != nil {
+		log.Error("service_account_key must be specified", logger.NewORGPrefix(orgID))
+		return nil, err
+	}
+
+	decryptServiceAccountKey, err := common.DecryptSecretKey(serviceAccountKey)
+	if err != nil {
+		log.Errorf("decrypt service_account_key failed (%s)", err.Error(), logger.NewORGPrefix(orgID))
+		return nil, err
+	}
+
+	// 服务账号密钥为 GCP 控制台下载的 JSON 文件内容
+	key, err := simplejson.NewJson([]byte(decryptServiceAccountKey))
+	if err != nil {
+		log.Errorf("service_account_key is not a valid json (%s)", err.Error(), logger.NewORGPrefix(orgID))
+		return nil, err
+	}
+	if keyType := key.Get("type").MustString(); keyType != "service_account" {
+		log.Errorf("service_account_key type (%s) is not service_account", keyType, logger.NewORGPrefix(orgID))
+		return nil, errors.New("service_account_key type must be service_account")
+	}
+	clientEmail := key.Get("client_email").MustString()
+	privateKey := key.Get("private_key").MustString()
+	if clientEmail == "" || privateKey == "" {
+		log.Error("service_account_key client_email and private_key must be specified", logger.NewORGPrefix(orgID))
+		return nil, errors.New("service_account_key client_email and private_key must be specified")
+	}
+	tokenURI := key.Get("token_uri").MustString()
+	if tokenURI == "" {
+		tokenURI = DEFAULT_TOKEN_URI
+	}
+
+	// 未指定项目时，只同步服务账号所属的项目
+	var projects []string
+	for project := range cloudcommon.UniqRegions(config.Get("projects").MustString()) {
+		projects = append(projects, project)
+	}
+	if len(projects) == 0 {
+		projectID := key.Get("project_id").MustString()
+		if projectID == "" {
+			log.Error("projects must be specified", logger.NewORGPrefix(orgID))
+			return nil, errors.New("projects must be specified")
+		}
+		projects = append(projects, projectID)
+	}
+
+	computeEndpoint := strings.TrimSuffix(config.Get("compute_endpoint").MustString(), "/")
+	if computeEndpoint == "" {
+		computeEndpoint = DEFAULT_COMPUTE_ENDPOINT
+	}
+
+	// 获取令牌及调用接口均使用相同的超时时间，令牌过期前由 oauth2 自动复用
+	timeout := time.Second * time.Duration(cfg.HTTPTimeout)
+	jwtConfig := &jwt.Config{
+		Email:        clientEmail,
+		PrivateKey:   []byte(privateKey),
+		PrivateKeyID: key.Get("private_key_id").MustString(),
+		Scopes:       []string{COMPUTE_READONLY_SCOPE},
+		TokenURL:     tokenURI,
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Timeout: timeout})
+	client := jwtConfig.Client(ctx)
+	client.Timeout = timeout
+
+	return &GCP{
+		// TODO: display_name后期需要修改为uuid_generate
+		orgID:           orgID,
+		teamID:          domain.TeamID,
+		name:            domain.Name,
+		lcuuid:          domain.Lcuuid,
+		uuidGenerate:    domain.DisplayName,
+		regionLcuuid:    config.Get("region_uuid").MustString(),
+		projects:        projects,
+		computeEndpoint: computeEndpoint,
+		client:          client,
+		includeRegions:  cloudcommon.UniqRegions(config.Get("include_regions").MustString()),
+	}, nil
+}
+
+func (g *GCP) CheckAuth() error {
+	for _, project := range g.projects {
+		if _, err := g.getRawDetail("/projects/" + project); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *GCP) ClearDebugLog() {}
+
+func (g *GCP) GetCloudData() (model.Resource, error) {
+	var resource model.Resource
+
+	g.regionLcuuidMap = map[string]string{}
+	g.azLcuuidMap = map[string]model.AZ{}
+	g.networkPathToRegion = map[string]string{}
+	g.networkPathToCIDRs = map[string][]string{}
+	g.subnetPathToNetwork = map[string]model.Network{}
+	g.networkLcuuidToSubnets = map[string][]model.Subnet{}
+	g.addressPathToIP = map[string]string{}
+	g.natIPToVinterface = map[string]model.VInterface{}
+	g.instancePathToTarget = map[string]instanceTarget{}
+
+	for _, project := range g.projects {
+		// VPC 网络为全局资源，其所属区域由子网确定，因此先获取子网
+		networks, subnets, err := g.getNetworks(project)
+		if err != nil {
+			return model.Resource{}, err
+		}
+		resource.Networks = append(resource.Networks, networks...)
+		resource.Subnets = append(resource.Subnets, subnets...)
+
+		vpcs, err := g.getVPCs(project)
+		if err != nil {
+			return model.Resource{}, err
+		}
+		resource.VPCs = append(resource.VPCs, vpcs...)
+
+		err = g.getAddresses(project)
+		if err != nil {
+			return model.Resource{}, err
+		}
+
+		vms, vinterfaces, ips, vNatRules, err := g.getVMs(project)
+		if err != nil {
+			return model.Resource{}, err
+		}
+		resource.VMs = append(resource.VMs, vms...)
+		resource.VInterfaces = append(resource.VInterfaces, vinterfaces...)
+		resource.IPs = append(resource.IPs, ips...)
+		resource.NATRules = append(resource.NATRules, vNatRules...)
+
+		lbs, lbListeners, lbTargetServers, err := g.getLoadBalances(project)
+		if err != nil {
+			return model.Resource{}, err
+		}
+		resource.LBs = append(resource.LBs, lbs...)
+		resource.LBListeners = append(resource.LBListeners, lbListeners...)
+		resource.LBTargetServers = append(resource.LBTargetServers, lbTargetServers...)
+
+		natGateways, natVinterfaces, natIPs, err := g.getNatGateways(project)
+		if err != nil {
+			return model.Resource{}, err
+		}
+		resource.NATGateways = append(resource.NATGateways, natGateways...)
+		resource.VInterfaces = append(resource.VInterfaces, natVinterfaces...)
+		resource.IPs = append(resource.IPs, natIPs...)
+	}
+
+	resource.FloatingIPs = append(resource.FloatingIPs, g.getFloatingIPs()...)
+	resource.AZs = append(resource.AZs, g.getAZs()...)
+	resource.Regions = append(resource.Regions, g.getRegions()...)
+	return resource, nil
+}
+
+// inScope 判断区域是否在白名单中，白名单为空时不过滤
+func (g *GCP) inScope(resourcePath, region string) bool {
+	if len(g.includeRegions) == 0 {
+		return true
+	}
+	if _, ok := g.includeRegions[region]; !ok {
+		log.Debugf("resource (%s) region (%s) not in include_regions", resourcePath, region, logger.NewORGPrefix(g.orgID))
+		return false
+	}
+	return true
+}
+
+func (g *GCP) getCloudTags(jResource *simplejson.Json) map[string]string {
+	tags := map[string]string{}
+	for k, v := range jResource.Get("labels").MustMap() {
+		if s, ok := v.(string); ok {
+			tags[k] = s
+		}
+	}
+	return tags
+}
+
+// resourcePath 返回资源链接中 projects/ 开始的部分，如 projects/<project>/regions/<region>/subnetworks/<name>，
+// 不同资源中引用的链接的域名可能不同，统一使用该部分作为资源的标识
+func resourcePath(selfLink string) string {
+	if index := strings.Index(selfLink, "projects/"); index >= 0 {
+		return selfLink[index:]
+	}
+	return selfLink
+}
+
+// lastSegment 返回资源链接的最后一段，即资源名称
+func lastSegment(selfLink string) string {
+	return selfLink[strings.LastIndex(selfLink, "/")+1:]
+}
+
+// zoneToRegion 可用区名称为区域名称加后缀，如 us-central1-a 所属区域为 us-central1
+func zoneToRegion(zone string) string {
+	if index := strings.LastIndex(zone, "-"); index > 0 {
+		return zone[:index]
+	}
+	return zone
+}
@@ -0,0 +1,275 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/agiledragon/gomonkey/v2"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/deepflowio/deepflow/server/controller/cloud/config"
+	cloudtest "github.com/deepflowio/deepflow/server/controller/cloud/test"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	metadbcommon "github.com/deepflowio/deepflow/server/controller/db/metadb/common"
+	metadbmodel "github.com/deepflowio/deepflow/server/controller/db/metadb/model"
+)
+
+const (
+	testClientEmail = "deepflow@proj-1.iam.gserviceaccount.com"
+	testToken       = "test-token"
+)
+
+// verifyAssertion checks the signature and the claims of the JWT assertion signed with the service account key
+func verifyAssertion(assertion string, publicKey *rsa.PublicKey) bool {
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature) != nil {
+		return false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	claims := map[string]interface{}{}
+	if json.Unmarshal(payload, &claims) != nil {
+		return false
+	}
+	return claims["iss"] == testClientEmail && claims["scope"] == COMPUTE_READONLY_SCOPE
+}
+
+// newFixtureServer serves both the token endpoint and the recorded Compute Engine responses in testfiles
+func newFixtureServer(t *testing.T, publicKey *rsa.PublicKey) *httptest.Server {
+	return cloudtest.NewFixtureServer(t, cloudtest.Fixtures{
+		Files: map[string]string{
+			"/compute/v1/projects/proj-1":                               "project.json",
+			"/compute/v1/projects/proj-1/global/networks":               "networks.json",
+			"/compute/v1/projects/proj-1/aggregated/subnetworks":        "subnetworks.json",
+			"/compute/v1/projects/proj-1/aggregated/addresses":          "addresses.json",
+			"/compute/v1/projects/proj-1/aggregated/instances":          "instances.json",
+			"/compute/v1/projects/proj-1/aggregated/backendServices":    "backend_services.json",
+			"/compute/v1/projects/proj-1/aggregated/urlMaps":            "url_maps.json",
+			"/compute/v1/projects/proj-1/aggregated/targetHttpProxies":  "target_http_proxies.json",
+			"/compute/v1/projects/proj-1/aggregated/targetHttpsProxies": "target_https_proxies.json",
+			"/compute/v1/projects/proj-1/aggregated/forwardingRules":    "forwarding_rules.json",
+			"/compute/v1/projects/proj-1/aggregated/routers":            "routers.json",
+		},
+		PageParam:    "pageToken",
+		PageSuffix:   "_",
+		NotFoundBody: `{"error": {"code": 404, "message": "The resource was not found", "status": "NOT_FOUND"}}`,
+		Route: func(w http.ResponseWriter, r *http.Request) (string, bool) {
+			if r.URL.Path == "/token" && r.Method == http.MethodPost {
+				if r.PostFormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || !verifyAssertion(r.PostFormValue("assertion"), publicKey) {
+					w.WriteHeader(http.StatusBadRequest)
+					w.Write([]byte(`{"error": "invalid_grant", "error_description": "Invalid JWT Signature."}`))
+					return "", true
+				}
+				return "token.json", false
+			}
+			if r.Header.Get("Authorization") != "Bearer "+testToken {
+				w.WriteHeader(http.StatusUnauthorized)
+				return "", true
+			}
+			if r.URL.Path == "/compute/v1/projects/proj-1/zones/us-central1-a/instanceGroups/ig-web/listInstances" && r.Method == http.MethodPost {
+				return "ig_web_instances.json", false
+			}
+			return "", false
+		},
+	})
+}
+
+func newTestGCP(endpoint string, privateKey *rsa.PrivateKey, keyType string) (*GCP, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	serviceAccountKey, _ := json.Marshal(map[string]string{
+		"type":           keyType,
+		"project_id":     "proj-1",
+		"private_key_id": "key-1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   testClientEmail,
+		"token_uri":      endpoint + "/token",
+	})
+	domainConfig, _ := json.Marshal(map[string]string{
+		"service_account_key": string(serviceAccountKey),
+		"include_regions":     "us-central1,us-east1",
+		"compute_endpoint":    endpoint + "/compute/v1",
+	})
+	domain := metadbmodel.Domain{
+		Name:        "test_gcp",
+		DisplayName: "test_gcp",
+		Config:      string(domainConfig),
+	}
+	return NewGCP(metadbcommon.DEFAULT_ORG_ID, domain, config.CloudConfig{HTTPTimeout: 5})
+}
+
+func TestGCP(t *testing.T) {
+	Convey("TestGCP", t, func() {
+		patches := gomonkey.ApplyFunc(common.DecryptSecretKey, func(secretKey string) (string, error) {
+			return secretKey, nil
+		})
+		defer patches.Reset()
+
+		privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		So(err, ShouldBeNil)
+		server := newFixtureServer(t, &privateKey.PublicKey)
+
+		Convey("non service account key should be rejected", func() {
+			_, err := newTestGCP(server.URL, privateKey, "authorized_user")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("key not matching the service account should fail the auth check", func() {
+			otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			So(err, ShouldBeNil)
+			gcp, err := newTestGCP(server.URL, otherKey, "service_account")
+			So(err, ShouldBeNil)
+			So(gcp.CheckAuth(), ShouldNotBeNil)
+		})
+
+		gcp, err := newTestGCP(server.URL, privateKey, "service_account")
+		So(err, ShouldBeNil)
+		So(gcp.CheckAuth(), ShouldBeNil)
+
+		data, err := gcp.GetCloudData()
+		So(err, ShouldBeNil)
+
+		vms := map[string]int{}
+		for i, vm := range data.VMs {
+			vms[vm.Name] = i
+		}
+
+		Convey("resource number should be equal", func() {
+			So(len(data.Regions), ShouldEqual, 2)
+			So(len(data.AZs), ShouldEqual, 2)
+			So(len(data.VPCs), ShouldEqual, 1)
+			So(len(data.Networks), ShouldEqual, 2)
+			So(len(data.Subnets), ShouldEqual, 3)
+			So(len(data.VMs), ShouldEqual, 3)
+			So(len(data.VInterfaces), ShouldEqual, 6)
+			So(len(data.IPs), ShouldEqual, 6)
+			So(len(data.FloatingIPs), ShouldEqual, 1)
+			So(len(data.NATRules), ShouldEqual, 1)
+			So(len(data.NATGateways), ShouldEqual, 2)
+			So(len(data.LBs), ShouldEqual, 2)
+			So(len(data.LBListeners), ShouldEqual, 2)
+			So(len(data.LBTargetServers), ShouldEqual, 4)
+		})
+
+		Convey("global vpc should belong to the region of its first subnetwork", func() {
+			So(data.VPCs[0].Name, ShouldEqual, "vpc-1")
+			So(data.VPCs[0].Label, ShouldEqual, "proj-1/vpc-1")
+			So(data.VPCs[0].CIDR, ShouldEqual, "10.128.0.0/20,10.142.0.0/20")
+			So(data.VPCs[0].RegionLcuuid, ShouldEqual, data.Networks[0].RegionLcuuid)
+		})
+
+		Convey("vms should be related to the vpcs and azs of their nics", func() {
+			web := data.VMs[vms["vm-web-1"]]
+			So(web.VPCLcuuid, ShouldEqual, data.VPCs[0].Lcuuid)
+			So(web.IP, ShouldEqual, "10.128.0.2")
+			So(web.State, ShouldEqual, common.VM_STATE_RUNNING)
+			So(web.CloudTags, ShouldResemble, map[string]string{"env": "prod"})
+			So(data.VMs[vms["vm-web-2"]].State, ShouldEqual, common.VM_STATE_STOPPED)
+			So(data.VMs[vms["vm-web-2"]].AZLcuuid, ShouldEqual, web.AZLcuuid)
+			db := data.VMs[vms["vm-db"]]
+			So(db.AZLcuuid, ShouldNotEqual, web.AZLcuuid)
+			So(db.RegionLcuuid, ShouldNotEqual, web.RegionLcuuid)
+		})
+
+		Convey("nics should keep single alias ips and derive mac from the primary ip", func() {
+			ips := map[string]string{}
+			for _, ip := range data.IPs {
+				ips[ip.IP] = ip.VInterfaceLcuuid
+			}
+			So(ips, ShouldContainKey, "10.128.0.10")
+			So(ips, ShouldNotContainKey, "10.4.1.0")
+			So(ips["10.128.0.10"], ShouldEqual, ips["10.128.0.2"])
+			macs := map[string]bool{}
+			for _, vinterface := range data.VInterfaces {
+				macs[vinterface.Mac] = true
+			}
+			So(macs, ShouldContainKey, "42:01:0a:80:00:02")
+			So(macs, ShouldContainKey, "ff:01:0a:80:00:02")
+		})
+
+		Convey("external ips should generate floating ips and dnat rules", func() {
+			So(data.FloatingIPs[0].IP, ShouldEqual, "35.1.1.1")
+			So(data.FloatingIPs[0].VMLcuuid, ShouldEqual, data.VMs[vms["vm-web-1"]].Lcuuid)
+			So(data.NATRules[0].FixedIP, ShouldEqual, "10.128.0.2")
+		})
+
+		Convey("cloud nat should be related to the router network and reserved addresses", func() {
+			natIPs := map[string]string{}
+			for _, natGateway := range data.NATGateways {
+				natIPs[natGateway.Label] = natGateway.FloatingIPs
+				So(natGateway.VPCLcuuid, ShouldEqual, data.VPCs[0].Lcuuid)
+			}
+			So(natIPs, ShouldResemble, map[string]string{"router-1/nat-1": "34.10.0.1", "router-1/nat-auto": ""})
+		})
+
+		Convey("backend services should be related to forwarding rules and instance groups", func() {
+			for _, lb := range data.LBs {
+				So(lb.VPCLcuuid, ShouldEqual, data.VPCs[0].Lcuuid)
+				if lb.Name == "bs-web" {
+					So(lb.Model, ShouldEqual, common.LB_MODEL_EXTERNAL)
+					So(lb.VIP, ShouldEqual, "34.120.0.1")
+					So(lb.RegionLcuuid, ShouldEqual, data.VMs[vms["vm-web-1"]].RegionLcuuid)
+				} else {
+					So(lb.Name, ShouldEqual, "bs-internal")
+					So(lb.Model, ShouldEqual, common.LB_MODEL_INTERNAL)
+					So(lb.VIP, ShouldEqual, "10.128.0.100")
+				}
+			}
+			listeners := map[string]string{}
+			for _, listener := range data.LBListeners {
+				listeners[listener.Name] = fmt.Sprintf("%s:%d", listener.Protocol, listener.Port)
+			}
+			So(listeners, ShouldResemble, map[string]string{"fr-web": "HTTP:80", "fr-internal": "TCP:8080"})
+			servers := map[string]bool{}
+			for _, ts := range data.LBTargetServers {
+				So(ts.Type, ShouldEqual, common.LB_SERVER_TYPE_VM)
+				servers[fmt.Sprintf("%s:%d", ts.IP, ts.Port)] = true
+			}
+			So(servers, ShouldResemble, map[string]bool{
+				"10.128.0.2:80":   true,
+				"10.128.0.3:80":   true,
+				"10.128.0.2:8080": true,
+				"10.128.0.3:8080": true,
+			})
+		})
+	})
+}
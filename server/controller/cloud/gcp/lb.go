@@ -0,0 +1,247 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/bitly/go-simplejson"
+
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+var lbInternalSchemes = map[string]bool{
+	"INTERNAL":              true,
+	"INTERNAL_MANAGED":      true,
+	"INTERNAL_SELF_MANAGED": true,
+}
+
+// getLoadBalances GCP 没有负载均衡器资源，每个后端服务（backend service）对应一个负载均衡器，
+// 转发规则（forwarding rule）直接引用后端服务，或通过目标代理（target proxy）及网址映射（url map）引用后端服务，
+// 每个转发规则对应一个监听器，后端服务中实例组的实例为后端主机，网络端点组（NEG）暂不支持
+func (g *GCP) getLoadBalances(project string) ([]model.LB, []model.LBListener, []model.LBTargetServer, error) {
+	log.Debug("get load balances starting", logger.NewORGPrefix(g.orgID))
+	var lbs []model.LB
+	var lbListeners []model.LBListener
+	var lbTargetServers []model.LBTargetServer
+
+	backendServices := map[string]*simplejson.Json{}
+	jBackendServices, err := g.getAggregatedData("/projects/"+project+"/aggregated/backendServices", "backendServices")
+	if err != nil {
+		log.Errorf("load balance request gcp api error: (%s)", err.Error(), logger.NewORGPrefix(g.orgID))
+		return []model.LB{}, []model.LBListener{}, []model.LBTargetServer{}, err
+	}
+	for _, jBackendService := range jBackendServices {
+		backendServices[resourcePath(jBackendService.Get("selfLink").MustString())] = jBackendService
+	}
+
+	targetToBackendService, err := g.getTargetProxyBackendServices(project)
+	if err != nil {
+		return []model.LB{}, []model.LBListener{}, []model.LBTargetServer{}, err
+	}
+
+	jForwardingRules, err := g.getAggregatedData("/projects/"+project+"/aggregated/forwardingRules", "forwardingRules")
+	if err != nil {
+		log.Errorf("load balance request gcp api error: (%s)", err.Error(), logger.NewORGPrefix(g.orgID))
+		return []model.LB{}, []model.LBListener{}, []model.LBTargetServer{}, err
+	}
+	var backendServicePaths []string
+	backendServiceToRules := map[string][]*simplejson.Json{}
+	for _, jForwardingRule := range jForwardingRules {
+		rulePath := resourcePath(jForwardingRule.Get("selfLink").MustString())
+		// 全局转发规则没有区域，不按区域过滤
+		if region := lastSegment(jForwardingRule.Get("region").MustString()); region != "" && !g.inScope(rulePath, region) {
+			continue
+		}
+		backendServicePath := resourcePath(jForwardingRule.Get("backendService").MustString())
+		if backendServicePath == "" {
+			backendServicePath = targetToBackendService[resourcePath(jForwardingRule.Get("target").MustString())]
+		}
+		if _, ok := backendServices[backendServicePath]; !ok {
+			log.Debugf("load balance forwarding rule (%s) backend service not found", rulePath, logger.NewORGPrefix(g.orgID))
+			continue
+		}
+		if _, ok := backendServiceToRules[backendServicePath]; !ok {
+			backendServicePaths = append(backendServicePaths, backendServicePath)
+		}
+		backendServiceToRules[backendServicePath] = append(backendServiceToRules[backendServicePath], jForwardingRule)
+	}
+
+	groupToTargets := map[string][]instanceTarget{}
+	for _, backendServicePath := range backendServicePaths {
+		jBackendService := backendServices[backendServicePath]
+		var targets []instanceTarget
+		jBackends := jBackendService.Get("backends")
+		for i := range jBackends.MustArray() {
+			groupPath := resourcePath(jBackends.GetIndex(i).Get("group").MustString())
+			if !strings.Contains(groupPath, "/instanceGroups/") {
+				log.Debugf("load balance backend (%s) is not an instance group", groupPath, logger.NewORGPrefix(g.orgID))
+				continue
+			}
+			groupTargets, ok := groupToTargets[groupPath]
+			if !ok {
+				groupTargets, err = g.getInstanceGroupTargets(groupPath)
+				if err != nil {
+					return []model.LB{}, []model.LBListener{}, []model.LBTargetServer{}, err
+				}
+				groupToTargets[groupPath] = groupTargets
+			}
+			targets = append(targets, groupTargets...)
+		}
+
+		// 内部负载均衡的后端服务带有所属网络，外部负载均衡的 VPC 及全局后端服务的区域由后端主机确定
+		var vpcLcuuid, regionLcuuid string
+		networkPath := resourcePath(jBackendService.Get("network").MustString())
+		if _, ok := g.networkPathToRegion[networkPath]; ok {
+			vpcLcuuid = common.GetUUIDByOrgID(g.orgID, networkPath)
+		}
+		if region := lastSegment(jBackendService.Get("region").MustString()); region != "" {
+			regionLcuuid = g.getRegionLcuuid(region)
+		}
+		for _, target := range targets {
+			if vpcLcuuid == "" {
+				vpcLcuuid = target.vpcLcuuid
+			}
+			if regionLcuuid == "" {
+				regionLcuuid = target.regionLcuuid
+			}
+		}
+		if vpcLcuuid == "" || regionLcuuid == "" {
+			log.Infof("load balance (%s) vpc or region not found", backendServicePath, logger.NewORGPrefix(g.orgID))
+			continue
+		}
+
+		lbModel := common.LB_MODEL_EXTERNAL
+		if lbInternalSchemes[jBackendService.Get("loadBalancingScheme").MustString()] {
+			lbModel = common.LB_MODEL_INTERNAL
+		}
+		lbLcuuid := common.GetUUIDByOrgID(g.orgID, backendServicePath)
+		protocol := jBackendService.Get("protocol").MustString()
+		var vips []string
+		for _, jForwardingRule := range backendServiceToRules[backendServicePath] {
+			vip := jForwardingRule.Get("IPAddress").MustString()
+			vips = append(vips, vip)
+			port := forwardingRulePort(jForwardingRule)
+			listenerLcuuid := common.GetUUIDByOrgID(g.orgID, resourcePath(jForwardingRule.Get("selfLink").MustString()))
+			lbListeners = append(lbListeners, model.LBListener{
+				Lcuuid:   listenerLcuuid,
+				LBLcuuid: lbLcuuid,
+				IPs:      vip,
+				Name:     jForwardingRule.Get("name").MustString(),
+				Label:    jForwardingRule.Get("id").MustString(),
+				Port:     port,
+				Protocol: protocol,
+			})
+
+			targetPort := jBackendService.Get("port").MustInt()
+			if targetPort == 0 {
+				targetPort = port
+			}
+			targetIPs := map[string]bool{}
+			for _, target := range targets {
+				if targetIPs[target.ip] {
+					continue
+				}
+				targetIPs[target.ip] = true
+				lbTargetServers = append(lbTargetServers, model.LBTargetServer{
+					Lcuuid:           common.GetUUIDByOrgID(g.orgID, listenerLcuuid+target.ip),
+					LBLcuuid:         lbLcuuid,
+					LBListenerLcuuid: listenerLcuuid,
+					Type:             common.LB_SERVER_TYPE_VM,
+					IP:               target.ip,
+					VMLcuuid:         target.vmLcuuid,
+					Port:             targetPort,
+					VPCLcuuid:        target.vpcLcuuid,
+					Protocol:         protocol,
+				})
+			}
+		}
+		lbs = append(lbs, model.LB{
+			Lcuuid:       lbLcuuid,
+			Name:         jBackendService.Get("name").MustString(),
+			Label:        jBackendService.Get("id").MustString(),
+			Model:        lbModel,
+			VIP:          strings.Join(vips, ","),
+			VPCLcuuid:    vpcLcuuid,
+			RegionLcuuid: regionLcuuid,
+		})
+	}
+	log.Debug("get load balances complete", logger.NewORGPrefix(g.orgID))
+	return lbs, lbListeners, lbTargetServers, nil
+}
+
+// getTargetProxyBackendServices 返回 HTTP(S) 目标代理通过网址映射引用的默认后端服务
+func (g *GCP) getTargetProxyBackendServices(project string) (map[string]string, error) {
+	urlMapToBackendService := map[string]string{}
+	jURLMaps, err := g.getAggregatedData("/projects/"+project+"/aggregated/urlMaps", "urlMaps")
+	if err != nil {
+		log.Errorf("url map request gcp api error: (%s)", err.Error(), logger.NewORGPrefix(g.orgID))
+		return nil, err
+	}
+	for _, jURLMap := range jURLMaps {
+		urlMapToBackendService[resourcePath(jURLMap.Get("selfLink").MustString())] = resourcePath(jURLMap.Get("defaultService").MustString())
+	}
+
+	targetToBackendService := map[string]string{}
+	for _, key := range []string{"targetHttpProxies", "targetHttpsProxies"} {
+		jProxies, err := g.getAggregatedData("/projects/"+project+"/aggregated/"+key, key)
+		if err != nil {
+			log.Errorf("target proxy request gcp api error: (%s)", err.Error(), logger.NewORGPrefix(g.orgID))
+			return nil, err
+		}
+		for _, jProxy := range jProxies {
+			targetToBackendService[resourcePath(jProxy.Get("selfLink").MustString())] = urlMapToBackendService[resourcePath(jProxy.Get("urlMap").MustString())]
+		}
+	}
+	return targetToBackendService, nil
+}
+
+// getInstanceGroupTargets 返回实例组中已同步的云服务器
+func (g *GCP) getInstanceGroupTargets(groupPath string) ([]instanceTarget, error) {
+	var targets []instanceTarget
+	err := g.requestPages("POST", "/"+groupPath+"/listInstances", []byte(`{"instanceState": "ALL"}`), func(jResp *simplejson.Json) {
+		jItems := jResp.Get("items")
+		for i := range jItems.MustArray() {
+			instancePath := resourcePath(jItems.GetIndex(i).Get("instance").MustString())
+			target, ok := g.instancePathToTarget[instancePath]
+			if !ok {
+				log.Debugf("lb target server instance (%s) not found", instancePath, logger.NewORGPrefix(g.orgID))
+				continue
+			}
+			targets = append(targets, target)
+		}
+	})
+	if err != nil {
+		log.Errorf("instance group request gcp api error: (%s)", err.Error(), logger.NewORGPrefix(g.orgID))
+		return nil, err
+	}
+	return targets, nil
+}
+
+// forwardingRulePort 返回转发规则的第一个端口，端口可以为端口列表（ports）或端口范围（portRange，如 80-80）
+func forwardingRulePort(jForwardingRule *simplejson.Json) int {
+	if ports := jForwardingRule.Get("ports").MustStringArray(); len(ports) > 0 {
+		port, _ := strconv.Atoi(ports[0])
+		return port
+	}
+	portRange := jForwardingRule.Get("portRange").MustString()
+	port, _ := strconv.Atoi(strings.SplitN(portRange, "-", 2)[0])
+	return port
+}
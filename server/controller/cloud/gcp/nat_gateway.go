@@ -0,0 +1,98 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"strings"
+
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// getNatGateways Cloud NAT 为 Cloud Router 上的配置，每个 NAT 配置对应一个 NAT 网关
+// 自动分配（AUTO_ONLY）的外部 IP 不在 Cloud Router 的配置中，此时 NAT 网关没有 IP
+func (g *GCP) getNatGateways(project string) ([]model.NATGateway, []model.VInterface, []model.IP, error) {
+	log.Debug("get nat gateways starting", logger.NewORGPrefix(g.orgID))
+	var natGateways []model.NATGateway
+	var natVinterfaces []model.VInterface
+	var natIPs []model.IP
+
+	jRouters, err := g.getAggregatedData("/projects/"+project+"/aggregated/routers", "routers")
+	if err != nil {
+		log.Errorf("nat gateway request gcp api error: (%s)", err.Error(), logger.NewORGPrefix(g.orgID))
+		return []model.NATGateway{}, []model.VInterface{}, []model.IP{}, err
+	}
+	for _, jRouter := range jRouters {
+		routerPath := resourcePath(jRouter.Get("selfLink").MustString())
+		region := lastSegment(jRouter.Get("region").MustString())
+		if !g.inScope(routerPath, region) {
+			continue
+		}
+		networkPath := resourcePath(jRouter.Get("network").MustString())
+		if _, ok := g.networkPathToRegion[networkPath]; !ok {
+			log.Infof("nat gateway router (%s) vpc (%s) not found", routerPath, networkPath, logger.NewORGPrefix(g.orgID))
+			continue
+		}
+		vpcLcuuid := common.GetUUIDByOrgID(g.orgID, networkPath)
+		regionLcuuid := g.getRegionLcuuid(region)
+
+		jNats := jRouter.Get("nats")
+		for i := range jNats.MustArray() {
+			jNat := jNats.GetIndex(i)
+			natName := jNat.Get("name").MustString()
+			floatingIPs := []string{}
+			for _, natIP := range jNat.Get("natIps").MustStringArray() {
+				if ip := g.addressPathToIP[resourcePath(natIP)]; ip != "" {
+					floatingIPs = append(floatingIPs, ip)
+				}
+			}
+			natGatewayLcuuid := common.GetUUIDByOrgID(g.orgID, routerPath+"/"+natName)
+			natGateways = append(natGateways, model.NATGateway{
+				Lcuuid:       natGatewayLcuuid,
+				Name:         natName,
+				Label:        jRouter.Get("name").MustString() + "/" + natName,
+				FloatingIPs:  strings.Join(floatingIPs, ","),
+				VPCLcuuid:    vpcLcuuid,
+				RegionLcuuid: regionLcuuid,
+			})
+
+			vinterfaceLcuuid := common.GetUUIDByOrgID(g.orgID, natGatewayLcuuid)
+			natVinterfaces = append(natVinterfaces, model.VInterface{
+				Lcuuid:        vinterfaceLcuuid,
+				Type:          common.VIF_TYPE_WAN,
+				Mac:           common.VIF_DEFAULT_MAC,
+				DeviceLcuuid:  natGatewayLcuuid,
+				DeviceType:    common.VIF_DEVICE_TYPE_NAT_GATEWAY,
+				NetworkLcuuid: common.NETWORK_ISP_LCUUID,
+				VPCLcuuid:     vpcLcuuid,
+				RegionLcuuid:  regionLcuuid,
+			})
+
+			for _, ip := range floatingIPs {
+				natIPs = append(natIPs, model.IP{
+					IP:               ip,
+					VInterfaceLcuuid: vinterfaceLcuuid,
+					RegionLcuuid:     regionLcuuid,
+					Lcuuid:           common.GetUUIDByOrgID(g.orgID, vinterfaceLcuuid+ip),
+				})
+			}
+		}
+	}
+	log.Debug("get nat gateways complete", logger.NewORGPrefix(g.orgID))
+	return natGateways, natVinterfaces, natIPs, nil
+}
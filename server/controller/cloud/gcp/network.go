@@ -0,0 +1,111 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"net"
+
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// getNetworks 每个子网（subnetwork）对应一个网络，子网的主地址段、次要地址段及 IPv6 地址段各对应一个子网
+func (g *GCP) getNetworks(project string) ([]model.Network, []model.Subnet, error) {
+	log.Debug("get networks starting", logger.NewORGPrefix(g.orgID))
+	var networks []model.Network
+	var subnets []model.Subnet
+
+	jSubnetworks, err := g.getAggregatedData("/projects/"+project+"/aggregated/subnetworks", "subnetworks")
+	if err != nil {
+		log.Errorf("network request gcp api error: (%s)", err.Error(), logger.NewORGPrefix(g.orgID))
+		return []model.Network{}, []model.Subnet{}, err
+	}
+	for _, jSubnetwork := range jSubnetworks {
+		subnetworkPath := resourcePath(jSubnetwork.Get("selfLink").MustString())
+		region := lastSegment(jSubnetwork.Get("region").MustString())
+		if !g.inScope(subnetworkPath, region) {
+			continue
+		}
+		networkPath := resourcePath(jSubnetwork.Get("network").MustString())
+		vpcLcuuid := common.GetUUIDByOrgID(g.orgID, networkPath)
+		networkName := jSubnetwork.Get("name").MustString()
+		networkLcuuid := common.GetUUIDByOrgID(g.orgID, subnetworkPath)
+		network := model.Network{
+			Lcuuid:         networkLcuuid,
+			Name:           networkName,
+			Label:          jSubnetwork.Get("id").MustString(),
+			SegmentationID: 1,
+			VPCLcuuid:      vpcLcuuid,
+			Shared:         false,
+			External:       false,
+			NetType:        common.NETWORK_TYPE_LAN,
+			RegionLcuuid:   g.getRegionLcuuid(region),
+		}
+		networks = append(networks, network)
+		g.subnetPathToNetwork[subnetworkPath] = network
+		if _, ok := g.networkPathToRegion[networkPath]; !ok {
+			g.networkPathToRegion[networkPath] = region
+		}
+
+		primaryCIDR := jSubnetwork.Get("ipCidrRange").MustString()
+		if primaryCIDR != "" {
+			g.networkPathToCIDRs[networkPath] = append(g.networkPathToCIDRs[networkPath], primaryCIDR)
+			subnets = append(subnets, g.formatSubnet(network, networkName, primaryCIDR, jSubnetwork.Get("gatewayAddress").MustString()))
+		}
+		// 次要地址段用于别名 IP，如 GKE 的 pod 及 service 地址段
+		jSecondaryRanges := jSubnetwork.Get("secondaryIpRanges")
+		for i := range jSecondaryRanges.MustArray() {
+			jSecondaryRange := jSecondaryRanges.GetIndex(i)
+			if cidr := jSecondaryRange.Get("ipCidrRange").MustString(); cidr != "" {
+				subnets = append(subnets, g.formatSubnet(network, jSecondaryRange.Get("rangeName").MustString(), cidr, ""))
+			}
+		}
+		for _, key := range []string{"ipv6CidrRange", "externalIpv6Prefix"} {
+			if cidr := jSubnetwork.Get(key).MustString(); cidr != "" {
+				subnets = append(subnets, g.formatSubnet(network, networkName, cidr, ""))
+			}
+		}
+	}
+	log.Debug("get networks complete", logger.NewORGPrefix(g.orgID))
+	return networks, subnets, nil
+}
+
+func (g *GCP) formatSubnet(network model.Network, name, cidr, gatewayIP string) model.Subnet {
+	subnet := model.Subnet{
+		Lcuuid:        common.GetUUIDByOrgID(g.orgID, network.Lcuuid+cidr),
+		Name:          name,
+		CIDR:          cidr,
+		GatewayIP:     gatewayIP,
+		VPCLcuuid:     network.VPCLcuuid,
+		NetworkLcuuid: network.Lcuuid,
+	}
+	g.networkLcuuidToSubnets[network.Lcuuid] = append(g.networkLcuuidToSubnets[network.Lcuuid], subnet)
+	return subnet
+}
+
+// getSubnetLcuuid 返回网络中包含该 IP 的子网
+func (g *GCP) getSubnetLcuuid(networkLcuuid, ip string) string {
+	netIP := net.ParseIP(ip)
+	for _, subnet := range g.networkLcuuidToSubnets[networkLcuuid] {
+		_, ipNet, err := net.ParseCIDR(subnet.CIDR)
+		if err == nil && ipNet.Contains(netIP) {
+			return subnet.Lcuuid
+		}
+	}
+	return ""
+}
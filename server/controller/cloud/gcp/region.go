@@ -0,0 +1,48 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// getRegionLcuuid 未指定 region_uuid 时，每个区域（如 us-central1）对应一个区域，不同项目中的同名区域为同一区域
+func (g *GCP) getRegionLcuuid(region string) string {
+	if g.regionLcuuid != "" {
+		return g.regionLcuuid
+	}
+	lcuuid := common.GetUUIDByOrgID(g.orgID, g.uuidGenerate+"_"+region)
+	g.regionLcuuidMap[region] = lcuuid
+	return lcuuid
+}
+
+// getRegions 只返回存在资源的区域
+func (g *GCP) getRegions() []model.Region {
+	log.Debug("get regions starting", logger.NewORGPrefix(g.orgID))
+	var regions []model.Region
+	for region, lcuuid := range g.regionLcuuidMap {
+		regions = append(regions, model.Region{
+			Lcuuid: lcuuid,
+			Label:  region,
+			Name:   region,
+		})
+	}
+	log.Debug("get regions complete", logger.NewORGPrefix(g.orgID))
+	return regions
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/bitly/go-simplejson"
+
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// formatVInterfaceAndIPs 将云服务器的网卡转换为接口及 IP，返回网卡的主 IP 及所属 VPC
+// 网卡的别名 IP 地址段只保留单个 IP（/32），其他地址段一般分配给容器；网卡的访问配置带有外部 IP 时生成 WAN 接口、公网 IP 及 DNAT 规则
+func (g *GCP) formatVInterfaceAndIPs(instancePath, vmLcuuid string, jNIC *simplejson.Json) ([]model.VInterface, []model.IP, []model.NATRule, string, string) {
+	var vinterfaces []model.VInterface
+	var ips []model.IP
+	var vNatRules []model.NATRule
+
+	nicPath := instancePath + "/" + jNIC.Get("name").MustString()
+	subnetworkPath := resourcePath(jNIC.Get("subnetwork").MustString())
+	network, ok := g.subnetPathToNetwork[subnetworkPath]
+	if !ok {
+		log.Debugf("vinterface (%s) subnetwork (%s) not found", nicPath, subnetworkPath, logger.NewORGPrefix(g.orgID))
+		return nil, nil, nil, "", ""
+	}
+	primaryIP := jNIC.Get("networkIP").MustString()
+	mac := formatMac(primaryIP)
+	vinterfaceLcuuid := common.GetUUIDByOrgID(g.orgID, nicPath)
+	vinterface := model.VInterface{
+		Lcuuid:        vinterfaceLcuuid,
+		Type:          common.VIF_TYPE_LAN,
+		Mac:           mac,
+		DeviceLcuuid:  vmLcuuid,
+		DeviceType:    common.VIF_DEVICE_TYPE_VM,
+		NetworkLcuuid: network.Lcuuid,
+		VPCLcuuid:     network.VPCLcuuid,
+		RegionLcuuid:  network.RegionLcuuid,
+	}
+	vinterfaces = append(vinterfaces, vinterface)
+
+	privateIPs := []string{}
+	if primaryIP != "" {
+		privateIPs = append(privateIPs, primaryIP)
+	}
+	jAliasRanges := jNIC.Get("aliasIpRanges")
+	for i := range jAliasRanges.MustArray() {
+		aliasRange := jAliasRanges.GetIndex(i).Get("ipCidrRange").MustString()
+		if strings.Contains(aliasRange, "/") && !strings.HasSuffix(aliasRange, "/32") {
+			log.Debugf("vinterface (%s) alias ip range (%s) is not a single ip", nicPath, aliasRange, logger.NewORGPrefix(g.orgID))
+			continue
+		}
+		privateIPs = append(privateIPs, strings.TrimSuffix(aliasRange, "/32"))
+	}
+	if ipv6 := jNIC.Get("ipv6Address").MustString(); ipv6 != "" {
+		privateIPs = append(privateIPs, ipv6)
+	}
+	for _, ip := range privateIPs {
+		ips = append(ips, model.IP{
+			Lcuuid:           common.GetUUIDByOrgID(g.orgID, vinterfaceLcuuid+ip),
+			VInterfaceLcuuid: vinterfaceLcuuid,
+			IP:               ip,
+			SubnetLcuuid:     g.getSubnetLcuuid(network.Lcuuid, ip),
+			RegionLcuuid:     network.RegionLcuuid,
+		})
+	}
+
+	wanVinterfaceLcuuid := common.GetUUIDByOrgID(g.orgID, vinterfaceLcuuid)
+	hasWANVInterface := false
+	jAccessConfigs := jNIC.Get("accessConfigs")
+	for i := range jAccessConfigs.MustArray() {
+		natIP := jAccessConfigs.GetIndex(i).Get("natIP").MustString()
+		if natIP == "" {
+			continue
+		}
+		if !hasWANVInterface {
+			hasWANVInterface = true
+			vinterfaces = append(vinterfaces, model.VInterface{
+				Lcuuid:        wanVinterfaceLcuuid,
+				Type:          common.VIF_TYPE_WAN,
+				Mac:           "ff" + mac[2:],
+				DeviceLcuuid:  vmLcuuid,
+				DeviceType:    common.VIF_DEVICE_TYPE_VM,
+				NetworkLcuuid: common.NETWORK_ISP_LCUUID,
+				VPCLcuuid:     network.VPCLcuuid,
+				RegionLcuuid:  network.RegionLcuuid,
+			})
+		}
+		ips = append(ips, model.IP{
+			Lcuuid:           common.GetUUIDByOrgID(g.orgID, vinterfaceLcuuid+natIP),
+			VInterfaceLcuuid: wanVinterfaceLcuuid,
+			IP:               natIP,
+			RegionLcuuid:     network.RegionLcuuid,
+		})
+		g.natIPToVinterface[natIP] = vinterface
+		vNatRules = append(vNatRules, model.NATRule{
+			Lcuuid:           common.GetUUIDByOrgID(g.orgID, natIP+vinterfaceLcuuid+primaryIP),
+			Type:             "DNAT",
+			Protocol:         "ALL",
+			FloatingIP:       natIP,
+			FixedIP:          primaryIP,
+			VInterfaceLcuuid: vinterfaceLcuuid,
+		})
+	}
+	return vinterfaces, ips, vNatRules, primaryIP, network.VPCLcuuid
+}
+
+// formatMac GCE 不返回网卡的 MAC 地址，其 MAC 地址固定为 42:01 加主 IP 的十六进制，如 10.128.0.2 对应 42:01:0a:80:00:02
+func formatMac(ip string) string {
+	ipv4 := net.ParseIP(ip).To4()
+	if ipv4 == nil {
+		return common.VIF_DEFAULT_MAC
+	}
+	return fmt.Sprintf("42:01:%02x:%02x:%02x:%02x", ipv4[0], ipv4[1], ipv4[2], ipv4[3])
+}
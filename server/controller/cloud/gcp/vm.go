@@ -0,0 +1,100 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"time"
+
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+var vmStates = map[string]int{
+	"RUNNING":    common.VM_STATE_RUNNING,
+	"STOPPED":    common.VM_STATE_STOPPED,
+	"TERMINATED": common.VM_STATE_STOPPED,
+	"SUSPENDED":  common.VM_STATE_STOPPED,
+}
+
+func (g *GCP) getVMs(project string) ([]model.VM, []model.VInterface, []model.IP, []model.NATRule, error) {
+	log.Debug("get vms starting", logger.NewORGPrefix(g.orgID))
+	var vms []model.VM
+	var vinterfaces []model.VInterface
+	var ips []model.IP
+	var vNatRules []model.NATRule
+
+	jInstances, err := g.getAggregatedData("/projects/"+project+"/aggregated/instances", "instances")
+	if err != nil {
+		log.Errorf("vm request gcp api error: (%s)", err.Error(), logger.NewORGPrefix(g.orgID))
+		return []model.VM{}, []model.VInterface{}, []model.IP{}, []model.NATRule{}, err
+	}
+	for _, jInstance := range jInstances {
+		instancePath := resourcePath(jInstance.Get("selfLink").MustString())
+		zone := lastSegment(jInstance.Get("zone").MustString())
+		region := zoneToRegion(zone)
+		if !g.inScope(instancePath, region) {
+			continue
+		}
+		vmLcuuid := common.GetUUIDByOrgID(g.orgID, instancePath)
+
+		// 云服务器所属 VPC 及 IP 由第一个网卡确定
+		var vpcLcuuid, vmIP string
+		jNICs := jInstance.Get("networkInterfaces")
+		for i := range jNICs.MustArray() {
+			nicVinterfaces, nicIPs, nicNatRules, primaryIP, nicVPCLcuuid := g.formatVInterfaceAndIPs(instancePath, vmLcuuid, jNICs.GetIndex(i))
+			vinterfaces = append(vinterfaces, nicVinterfaces...)
+			ips = append(ips, nicIPs...)
+			vNatRules = append(vNatRules, nicNatRules...)
+			if vpcLcuuid == "" && nicVPCLcuuid != "" {
+				vpcLcuuid = nicVPCLcuuid
+				vmIP = primaryIP
+			}
+		}
+		if vpcLcuuid == "" {
+			log.Infof("vm (%s) vpc not found", instancePath, logger.NewORGPrefix(g.orgID))
+			continue
+		}
+		g.instancePathToTarget[instancePath] = instanceTarget{
+			vmLcuuid:     vmLcuuid,
+			ip:           vmIP,
+			vpcLcuuid:    vpcLcuuid,
+			regionLcuuid: g.getRegionLcuuid(region),
+		}
+
+		vmState, ok := vmStates[jInstance.Get("status").MustString()]
+		if !ok {
+			vmState = common.VM_STATE_EXCEPTION
+		}
+		createdAt, _ := time.Parse(time.RFC3339, jInstance.Get("creationTimestamp").MustString())
+		vms = append(vms, model.VM{
+			Lcuuid:       vmLcuuid,
+			Name:         jInstance.Get("name").MustString(),
+			Label:        jInstance.Get("id").MustString(),
+			VPCLcuuid:    vpcLcuuid,
+			State:        vmState,
+			HType:        common.VM_HTYPE_VM_C,
+			IP:           vmIP,
+			CreatedAt:    createdAt,
+			AZLcuuid:     g.getAZLcuuid(zone),
+			RegionLcuuid: g.getRegionLcuuid(region),
+			CloudTags:    g.getCloudTags(jInstance),
+		})
+	}
+	log.Debug("get vms complete", logger.NewORGPrefix(g.orgID))
+	return vms, vinterfaces, ips, vNatRules, nil
+}
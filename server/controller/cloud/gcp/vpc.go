@@ -0,0 +1,54 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"strings"
+
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// getVPCs VPC 网络为全局资源，所属区域为其第一个子网所在的区域，没有子网（或子网均不在区域白名单中）的 VPC 网络不同步
+func (g *GCP) getVPCs(project string) ([]model.VPC, error) {
+	log.Debug("get vpcs starting", logger.NewORGPrefix(g.orgID))
+	var vpcs []model.VPC
+
+	jNetworks, err := g.getRawData("/projects/" + project + "/global/networks")
+	if err != nil {
+		log.Errorf("vpc request gcp api error: (%s)", err.Error(), logger.NewORGPrefix(g.orgID))
+		return []model.VPC{}, err
+	}
+	for _, jNetwork := range jNetworks {
+		networkPath := resourcePath(jNetwork.Get("selfLink").MustString())
+		region, ok := g.networkPathToRegion[networkPath]
+		if !ok {
+			log.Infof("vpc (%s) has no subnetwork", networkPath, logger.NewORGPrefix(g.orgID))
+			continue
+		}
+		vpcs = append(vpcs, model.VPC{
+			Lcuuid:       common.GetUUIDByOrgID(g.orgID, networkPath),
+			Name:         jNetwork.Get("name").MustString(),
+			CIDR:         strings.Join(g.networkPathToCIDRs[networkPath], ","),
+			Label:        project + "/" + jNetwork.Get("name").MustString(),
+			RegionLcuuid: g.getRegionLcuuid(region),
+		})
+	}
+	log.Debug("get vpcs complete", logger.NewORGPrefix(g.orgID))
+	return vpcs, nil
+}
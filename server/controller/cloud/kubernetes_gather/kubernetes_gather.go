@@ -59,6 +59,7 @@ type KubernetesGather struct {
 	labelRegex                   *regexp.Regexp
 	envRegex                     *regexp.Regexp
 	annotationRegex              *regexp.Regexp
+	podGroupOwnerKinds           map[string]bool
 	podGroupLcuuids              mapset.Set
 	podNetworkLcuuidCIDRs        networkLcuuidCIDRs
 	nodeNetworkLcuuidCIDRs       networkLcuuidCIDRs
@@ -176,6 +177,8 @@ func NewKubernetesGather(db *metadb.DB, domain *metadbmodel.Domain, subDomain *m
 		log.Errorf("annotation regex compile error: (%s)", err.Error(), db.LogPrefixORGID)
 		return nil
 	}
+	// the owner kinds of the custom resources which are aggregated into pod groups, '*' means all the kinds
+	podGroupOwnerKinds := parsePodGroupOwnerKinds(configJson.Get("pod_group_owner_kinds").MustString())
 
 	return &KubernetesGather{
 		// TODO: display_name后期需要修改为uuid_generate
@@ -194,6 +197,7 @@ func NewKubernetesGather(db *metadb.DB, domain *metadbmodel.Domain, subDomain *m
 		labelRegex:            labelR,
 		envRegex:              envR,
 		annotationRegex:       annotationR,
+		podGroupOwnerKinds:    podGroupOwnerKinds,
 
 		// 以下属性为获取资源所用的关联关系
 		azLcuuid:                     "",
@@ -418,6 +422,15 @@ func (k *KubernetesGather) GetKubernetesGatherData() (model.KubernetesGatherReso
 	if err != nil {
 		return model.KubernetesGatherResource{}, err
 	}
+
+	routeIngresses, routeIngressRules, routeIngressRuleBackends, err := k.getRouteIngresses()
+	if err != nil {
+		return model.KubernetesGatherResource{}, err
+	}
+
+	ingresses = append(ingresses, routeIngresses...)
+	ingressRules = append(ingressRules, routeIngressRules...)
+	ingressRuleBackends = append(ingressRuleBackends, routeIngressRuleBackends...)
 	for index, s := range podServices {
 		if ingressLcuuid, ok := k.serviceLcuuidToIngressLcuuid[s.Lcuuid]; ok {
 			podServices[index].PodIngressLcuuid = ingressLcuuid
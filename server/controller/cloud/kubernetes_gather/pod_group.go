@@ -30,13 +30,18 @@ import (
 
 func (k *KubernetesGather) getPodGroups() (podGroups []model.PodGroup, podGroupConfigMapConnections []model.PodGroupConfigMapConnection, err error) {
 	log.Debug("get podgroups starting", logger.NewORGPrefix(k.orgID))
-	podControllers := [5][]string{}
+	podControllers := [8][]string{}
 	podControllers[0] = k.k8sInfo["*v1.Deployment"]
 	podControllers[1] = k.k8sInfo["*v1.StatefulSet"]
 	podControllers[1] = append(podControllers[1], k.k8sInfo["*v1.OpenGaussCluster"]...)
 	podControllers[2] = k.k8sInfo["*v1.DaemonSet"]
 	podControllers[3] = k.k8sInfo["*v1.CloneSet"]
-	podControllers[4] = k.k8sInfo["*v1.Pod"]
+	// cronjobs must be handled before jobs, so that the jobs created by cronjobs can be skipped
+	podControllers[4] = k.k8sInfo["*v1.CronJob"]
+	podControllers[5] = k.k8sInfo["*v1.Job"]
+	podControllers[6] = k.k8sInfo["*v1alpha1.Rollout"]
+	podControllers[7] = k.k8sInfo["*v1.Pod"]
+	podOwnerIndex := k.getPodOwnerIndex()
 	pgNameToTypeID := map[string]int{
 		"deployment":            common.POD_GROUP_DEPLOYMENT,
 		"statefulset":           common.POD_GROUP_STATEFULSET,
@@ -44,6 +49,9 @@ func (k *KubernetesGather) getPodGroups() (podGroups []model.PodGroup, podGroupC
 		"daemonset":             common.POD_GROUP_DAEMON_SET,
 		"replicationcontroller": common.POD_GROUP_RC,
 		"cloneset":              common.POD_GROUP_CLONESET,
+		"job":                   common.POD_GROUP_JOB,
+		"cronjob":               common.POD_GROUP_CRON_JOB,
+		"rollout":               common.POD_GROUP_ROLLOUT,
 	}
 	for t, podController := range podControllers {
 		for _, c := range podController {
@@ -80,9 +88,14 @@ func (k *KubernetesGather) getPodGroups() (podGroups []model.PodGroup, podGroupC
 				continue
 			}
 			spec := cData.Get("spec")
+			// the spec which contains the pod template
+			templateSpec := spec
+			podNum := spec.Get("replicas").MustInt()
 			uLcuuid := common.IDGenerateUUID(k.orgID, uID)
 			var serviceType int
 			var label string
+			// the kind of the custom resource which the custom pod group is generated from
+			var ownerKind string
 			switch t {
 			case 0:
 				serviceType = common.POD_GROUP_DEPLOYMENT
@@ -97,7 +110,48 @@ func (k *KubernetesGather) getPodGroups() (podGroups []model.PodGroup, podGroupC
 				serviceType = common.POD_GROUP_CLONESET
 				label = "cloneset:" + namespace + ":" + name
 			case 4:
-				if metaData.Get("ownerReferences").GetIndex(0).Get("kind").MustString() == "InPlaceSet" {
+				serviceType = common.POD_GROUP_CRON_JOB
+				label = "cronjob:" + namespace + ":" + name
+				templateSpec = spec.GetPath("jobTemplate", "spec")
+				podNum = templateSpec.Get("parallelism").MustInt(1)
+			case 5:
+				// the jobs created by cronjobs are aggregated into the cronjobs
+				if owner, ok := getControllerOwner(metaData); ok && owner.kind == POD_OWNER_KIND_CRON_JOB {
+					log.Debugf("job (%s) belongs to cronjob (%s)", name, owner.name, logger.NewORGPrefix(k.orgID))
+					continue
+				}
+				serviceType = common.POD_GROUP_JOB
+				label = "job:" + namespace + ":" + name
+				podNum = spec.Get("parallelism").MustInt(1)
+			case 6:
+				serviceType = common.POD_GROUP_ROLLOUT
+				label = "rollout:" + namespace + ":" + name
+			case 7:
+				if owner, direct, ok := k.getPodGroupOwner(metaData, podOwnerIndex); ok {
+					// the pods of replicasets are linked to the pod group by the replicaset
+					if direct.kind != POD_OWNER_KIND_REPLICASET {
+						k.podLcuuidToPGInfo[uID] = [2]string{owner.uid, owner.kind}
+					}
+					uLcuuid = common.IDGenerateUUID(k.orgID, owner.uid)
+					if k.podGroupLcuuids.Contains(uLcuuid) {
+						log.Debugf("pod (%s) owner workload already existed", name, logger.NewORGPrefix(k.orgID))
+						continue
+					}
+					// the workload is not reported by the agent, or it is a custom resource specified by the configuration,
+					// generate the pod group according to the owner reference
+					// 工作负载没有被采集器上报，或者是配置指定的自定义资源，根据 ownerReferences 生成工作负载
+					switch owner.kind {
+					case POD_OWNER_KIND_JOB:
+						serviceType = common.POD_GROUP_JOB
+					case POD_OWNER_KIND_CRON_JOB:
+						serviceType = common.POD_GROUP_CRON_JOB
+					default:
+						serviceType = common.POD_GROUP_CUSTOM
+						ownerKind = owner.kind
+					}
+					label = strings.ToLower(owner.kind) + ":" + namespace + ":" + owner.name
+					name = owner.name
+				} else if metaData.Get("ownerReferences").GetIndex(0).Get("kind").MustString() == "InPlaceSet" {
 					uLcuuid = common.IDGenerateUUID(k.orgID, metaData.Get("ownerReferences").GetIndex(0).Get("uid").MustString())
 					name = metaData.Get("ownerReferences").GetIndex(0).Get("name").MustString()
 					if k.podGroupLcuuids.Contains(uLcuuid) {
@@ -148,7 +202,7 @@ func (k *KubernetesGather) getPodGroups() (podGroups []model.PodGroup, podGroupC
 				groupIDsSet.Add(uLcuuid)
 				k.nsLabelToGroupLcuuids[namespace+label] = groupIDsSet
 			}
-			mLabels := spec.GetPath("template", "metadata", "labels").MustMap()
+			mLabels := templateSpec.GetPath("template", "metadata", "labels").MustMap()
 			for key, v := range mLabels {
 				vString, ok := v.(string)
 				if !ok {
@@ -181,7 +235,7 @@ func (k *KubernetesGather) getPodGroups() (podGroups []model.PodGroup, podGroupC
 				}
 			}
 
-			containers := spec.GetPath("template", "spec", "containers")
+			containers := templateSpec.GetPath("template", "spec", "containers")
 			for i := range containers.MustArray() {
 				container := containers.GetIndex(i)
 				cPorts, ok := container.CheckGet("ports")
@@ -208,7 +262,8 @@ func (k *KubernetesGather) getPodGroups() (podGroups []model.PodGroup, podGroupC
 				SpecHash:           cloudcommon.GenerateMD5Sum(specStr),
 				Label:              k.GetLabel(labels),
 				Type:               serviceType,
-				PodNum:             spec.Get("replicas").MustInt(),
+				OwnerKind:          ownerKind,
+				PodNum:             podNum,
 				PodNamespaceLcuuid: namespaceLcuuid,
 				AZLcuuid:           k.azLcuuid,
 				RegionLcuuid:       k.RegionUUID,
@@ -217,7 +272,7 @@ func (k *KubernetesGather) getPodGroups() (podGroups []model.PodGroup, podGroupC
 			podGroups = append(podGroups, podGroup)
 			k.podGroupLcuuids.Add(uLcuuid)
 			k.pgLcuuidTopodTargetPorts[uLcuuid] = podTargetPorts
-			podGroupConfigMapConnections = append(podGroupConfigMapConnections, k.pgSpecGenerateConnections(namespace, name, uLcuuid, spec)...)
+			podGroupConfigMapConnections = append(podGroupConfigMapConnections, k.pgSpecGenerateConnections(namespace, name, uLcuuid, templateSpec)...)
 		}
 	}
 	log.Debug("get podgroups complete", logger.NewORGPrefix(k.orgID))
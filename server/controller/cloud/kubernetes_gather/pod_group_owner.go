@@ -0,0 +1,174 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes_gather
+
+import (
+	"strings"
+
+	"github.com/bitly/go-simplejson"
+
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+const (
+	POD_OWNER_KIND_REPLICASET = "ReplicaSet"
+	POD_OWNER_KIND_JOB        = "Job"
+	POD_OWNER_KIND_CRON_JOB   = "CronJob"
+
+	// the maximum number of owner references followed from a pod
+	POD_OWNER_WALK_MAX_DEPTH = 5
+	// matches any owner kind which is not handled as a builtin pod group
+	POD_OWNER_KIND_ALL = "*"
+)
+
+// builtinPodOwnerKinds are the owner kinds resolved into pod groups by their own rules,
+// they are never treated as the custom workload of the generic owner reference walk
+var builtinPodOwnerKinds = map[string]bool{
+	"CloneSet":              true,
+	"CronJob":               true,
+	"DaemonSet":             true,
+	"Deployment":            true,
+	"InPlaceSet":            true,
+	"Job":                   true,
+	"Node":                  true,
+	"OpenGaussCluster":      true,
+	"ReplicaSet":            true,
+	"ReplicationController": true,
+	"Rollout":               true,
+	"StatefulSet":           true,
+	"StatefulSetPlus":       true,
+}
+
+type podOwner struct {
+	uid  string
+	kind string
+	name string
+}
+
+// getControllerOwner returns the owner reference marked as controller,
+// the first owner reference is returned if none of them is marked
+func getControllerOwner(metaData *simplejson.Json) (podOwner, bool) {
+	ownerRefs := metaData.Get("ownerReferences")
+	index := -1
+	for i := range ownerRefs.MustArray() {
+		if ownerRefs.GetIndex(i).Get("controller").MustBool() {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		if len(ownerRefs.MustArray()) == 0 {
+			return podOwner{}, false
+		}
+		index = 0
+	}
+	ownerRef := ownerRefs.GetIndex(index)
+	owner := podOwner{
+		uid:  ownerRef.Get("uid").MustString(),
+		kind: ownerRef.Get("kind").MustString(),
+		name: ownerRef.Get("name").MustString(),
+	}
+	if owner.uid == "" || owner.kind == "" || owner.name == "" {
+		return podOwner{}, false
+	}
+	return owner, true
+}
+
+// parsePodGroupOwnerKinds parses the comma separated owner kinds of the generic owner reference walk
+func parsePodGroupOwnerKinds(kinds string) map[string]bool {
+	kindSet := map[string]bool{}
+	for _, kind := range strings.Split(kinds, ",") {
+		kind = strings.TrimSpace(kind)
+		if kind == "" {
+			continue
+		}
+		kindSet[kind] = true
+	}
+	return kindSet
+}
+
+// isCustomPodGroupKind returns whether pods owned by the kind should be aggregated into a custom workload
+func (k *KubernetesGather) isCustomPodGroupKind(kind string) bool {
+	if builtinPodOwnerKinds[kind] {
+		return false
+	}
+	return k.podGroupOwnerKinds[POD_OWNER_KIND_ALL] || k.podGroupOwnerKinds[kind]
+}
+
+// getPodOwnerIndex returns the controller owner of the intermediate objects between pods and workloads,
+// which are the replicasets and jobs, indexed by the uid of the object
+func (k *KubernetesGather) getPodOwnerIndex() map[string]podOwner {
+	ownerIndex := map[string]podOwner{}
+	for _, infoKey := range []string{"*v1.ReplicaSet", "*v1.Job"} {
+		for _, info := range k.k8sInfo[infoKey] {
+			iData, err := simplejson.NewJson([]byte(info))
+			if err != nil {
+				log.Warningf("pod owner (%s) initialization simplejson error: (%s)", infoKey, err.Error(), logger.NewORGPrefix(k.orgID))
+				continue
+			}
+			metaData := iData.Get("metadata")
+			uID := metaData.Get("uid").MustString()
+			if uID == "" {
+				continue
+			}
+			owner, ok := getControllerOwner(metaData)
+			if !ok {
+				continue
+			}
+			ownerIndex[uID] = owner
+		}
+	}
+	return ownerIndex
+}
+
+// walkPodOwners follows the owner references from the pod through the intermediate objects in the index,
+// returns the direct owner of the pod and the top owner where the walk stops
+func walkPodOwners(metaData *simplejson.Json, ownerIndex map[string]podOwner) (direct, top podOwner, ok bool) {
+	direct, ok = getControllerOwner(metaData)
+	if !ok {
+		return
+	}
+	top = direct
+	for i := 0; i < POD_OWNER_WALK_MAX_DEPTH; i++ {
+		owner, ok := ownerIndex[top.uid]
+		if !ok {
+			break
+		}
+		top = owner
+	}
+	return
+}
+
+// getPodGroupOwner returns the owner which the pod is aggregated into by the owner reference walk, the pods of
+// jobs belong to the cronjob of the job or the job itself, and the pods of the custom kinds specified by the
+// configuration belong to the custom resource, the others are handled by the original rules.
+func (k *KubernetesGather) getPodGroupOwner(metaData *simplejson.Json, ownerIndex map[string]podOwner) (owner, direct podOwner, ok bool) {
+	direct, top, ok := walkPodOwners(metaData, ownerIndex)
+	if !ok {
+		return
+	}
+	if k.isCustomPodGroupKind(top.kind) {
+		return top, direct, true
+	}
+	if direct.kind != POD_OWNER_KIND_JOB {
+		return podOwner{}, direct, false
+	}
+	if top.kind == POD_OWNER_KIND_CRON_JOB {
+		return top, direct, true
+	}
+	return direct, direct, true
+}
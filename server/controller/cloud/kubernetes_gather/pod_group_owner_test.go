@@ -0,0 +1,87 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes_gather
+
+import (
+	"testing"
+
+	"github.com/bitly/go-simplejson"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func newOwnerMetaData(uid, ownerUID, ownerKind, ownerName string) *simplejson.Json {
+	metaData := simplejson.New()
+	metaData.Set("uid", uid)
+	if ownerUID != "" {
+		metaData.Set("ownerReferences", []interface{}{
+			map[string]interface{}{"uid": ownerUID, "kind": ownerKind, "name": ownerName, "controller": true},
+		})
+	}
+	return metaData
+}
+
+func TestGetPodGroupOwner(t *testing.T) {
+	Convey("TestGetPodGroupOwner", t, func() {
+		k := &KubernetesGather{podGroupOwnerKinds: parsePodGroupOwnerKinds("Workflow, ")}
+		ownerIndex := map[string]podOwner{
+			"job-1": {uid: "cronjob-1", kind: "CronJob", name: "backup"},
+			"rs-1":  {uid: "deploy-1", kind: "Deployment", name: "web"},
+			"rs-2":  {uid: "workflow-1", kind: "Workflow", name: "etl"},
+		}
+
+		Convey("pods of cronjob jobs belong to the cronjob", func() {
+			owner, direct, ok := k.getPodGroupOwner(newOwnerMetaData("pod-1", "job-1", "Job", "backup-28000000"), ownerIndex)
+			So(ok, ShouldBeTrue)
+			So(direct.kind, ShouldEqual, "Job")
+			So(owner, ShouldResemble, podOwner{uid: "cronjob-1", kind: "CronJob", name: "backup"})
+		})
+
+		Convey("pods of standalone jobs belong to the job", func() {
+			owner, _, ok := k.getPodGroupOwner(newOwnerMetaData("pod-2", "job-2", "Job", "migrate"), ownerIndex)
+			So(ok, ShouldBeTrue)
+			So(owner, ShouldResemble, podOwner{uid: "job-2", kind: "Job", name: "migrate"})
+		})
+
+		Convey("pods of deployments keep the original rules", func() {
+			_, _, ok := k.getPodGroupOwner(newOwnerMetaData("pod-3", "rs-1", "ReplicaSet", "web-7d9c"), ownerIndex)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("pods of configured custom kinds belong to the custom resource", func() {
+			owner, direct, ok := k.getPodGroupOwner(newOwnerMetaData("pod-4", "rs-2", "ReplicaSet", "etl-5f6b"), ownerIndex)
+			So(ok, ShouldBeTrue)
+			So(direct.kind, ShouldEqual, "ReplicaSet")
+			So(owner.uid, ShouldEqual, "workflow-1")
+
+			_, _, ok = k.getPodGroupOwner(newOwnerMetaData("pod-5", "app-1", "Application", "shop"), ownerIndex)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("all non builtin kinds are matched by '*'", func() {
+			k.podGroupOwnerKinds = parsePodGroupOwnerKinds("*")
+			owner, _, ok := k.getPodGroupOwner(newOwnerMetaData("pod-5", "app-1", "Application", "shop"), ownerIndex)
+			So(ok, ShouldBeTrue)
+			So(owner.kind, ShouldEqual, "Application")
+			So(k.isCustomPodGroupKind("Node"), ShouldBeFalse)
+		})
+
+		Convey("pods without owner are not matched", func() {
+			_, _, ok := k.getPodGroupOwner(newOwnerMetaData("pod-6", "", "", ""), ownerIndex)
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,324 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes_gather
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/bitly/go-simplejson"
+
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+const (
+	ROUTE_DEFAULT_PATH = "/"
+	// the reserved gateway name of istio which means the sidecars of the mesh
+	VIRTUAL_SERVICE_MESH_GATEWAY = "mesh"
+)
+
+// routeBackend 路由对象中的一组路径及其后端服务
+type routeBackend struct {
+	namespace   string
+	serviceName string
+	port        int
+	paths       []string
+}
+
+// gatewayListener Gateway API 中 Gateway 的监听器
+type gatewayListener struct {
+	name     string
+	hostname string
+	protocol string
+}
+
+// getRouteIngresses maps the routing objects of Gateway API and Istio into pod ingresses,
+// every host of the route is a pod ingress rule, and every backend service is a pod ingress rule backend
+func (k *KubernetesGather) getRouteIngresses() (ingresses []model.PodIngress, ingressRules []model.PodIngressRule, ingressRuleBackends []model.PodIngressRuleBackend, err error) {
+	log.Debug("get route ingresses starting", logger.NewORGPrefix(k.orgID))
+	nsNameToListeners, err := k.getGatewayListeners()
+	if err != nil {
+		return
+	}
+
+	for _, r := range k.k8sInfo["*v1.HTTPRoute"] {
+		rData, rErr := simplejson.NewJson([]byte(r))
+		if rErr != nil {
+			err = rErr
+			log.Errorf("httproute initialization simplejson error: (%s)", rErr.Error(), logger.NewORGPrefix(k.orgID))
+			return
+		}
+		uID, name, namespace, ok := k.getRouteMetadata(rData, "httproute")
+		if !ok {
+			continue
+		}
+		spec := rData.Get("spec")
+		hosts := spec.Get("hostnames").MustStringArray()
+		protocol := "HTTP"
+		parentRefs := spec.Get("parentRefs")
+		for i := range parentRefs.MustArray() {
+			parentRef := parentRefs.GetIndex(i)
+			if kind := parentRef.Get("kind").MustString(); kind != "" && kind != "Gateway" {
+				continue
+			}
+			parentNamespace := parentRef.Get("namespace").MustString(namespace)
+			sectionName := parentRef.Get("sectionName").MustString()
+			for _, listener := range nsNameToListeners[parentNamespace+"/"+parentRef.Get("name").MustString()] {
+				if sectionName != "" && sectionName != listener.name {
+					continue
+				}
+				if listener.protocol == "HTTPS" {
+					protocol = "HTTPS"
+				}
+				// the hostnames of the route are inherited from the listeners if the route does not specify any
+				if len(spec.Get("hostnames").MustArray()) == 0 && listener.hostname != "" {
+					hosts = append(hosts, listener.hostname)
+				}
+			}
+		}
+
+		var backends []routeBackend
+		rules := spec.Get("rules")
+		for i := range rules.MustArray() {
+			rule := rules.GetIndex(i)
+			var paths []string
+			matches := rule.Get("matches")
+			for j := range matches.MustArray() {
+				paths = append(paths, matches.GetIndex(j).GetPath("path", "value").MustString(ROUTE_DEFAULT_PATH))
+			}
+			if len(paths) == 0 {
+				paths = []string{ROUTE_DEFAULT_PATH}
+			}
+			backendRefs := rule.Get("backendRefs")
+			for j := range backendRefs.MustArray() {
+				backendRef := backendRefs.GetIndex(j)
+				if kind := backendRef.Get("kind").MustString(); kind != "" && kind != "Service" {
+					log.Debugf("httproute (%s) backend kind (%s) not support", name, kind, logger.NewORGPrefix(k.orgID))
+					continue
+				}
+				backends = append(backends, routeBackend{
+					namespace:   backendRef.Get("namespace").MustString(namespace),
+					serviceName: backendRef.Get("name").MustString(),
+					port:        backendRef.Get("port").MustInt(),
+					paths:       paths,
+				})
+			}
+		}
+		ingress, rs, bs := k.generateRouteIngress(uID, name, namespace, protocol, hosts, backends)
+		ingresses = append(ingresses, ingress)
+		ingressRules = append(ingressRules, rs...)
+		ingressRuleBackends = append(ingressRuleBackends, bs...)
+	}
+
+	for _, v := range k.k8sInfo["*v1beta1.VirtualService"] {
+		vData, vErr := simplejson.NewJson([]byte(v))
+		if vErr != nil {
+			err = vErr
+			log.Errorf("virtualservice initialization simplejson error: (%s)", vErr.Error(), logger.NewORGPrefix(k.orgID))
+			return
+		}
+		uID, name, namespace, ok := k.getRouteMetadata(vData, "virtualservice")
+		if !ok {
+			continue
+		}
+		spec := vData.Get("spec")
+		// only the virtualservices bound to the gateways are ingresses, the others route the traffic between the sidecars
+		if !isGatewayVirtualService(spec.Get("gateways").MustStringArray()) {
+			log.Debugf("virtualservice (%s) is not bound to any gateway", name, logger.NewORGPrefix(k.orgID))
+			continue
+		}
+		var backends []routeBackend
+		httpRoutes := spec.Get("http")
+		for i := range httpRoutes.MustArray() {
+			httpRoute := httpRoutes.GetIndex(i)
+			var paths []string
+			matches := httpRoute.Get("match")
+			for j := range matches.MustArray() {
+				uri := matches.GetIndex(j).Get("uri")
+				path := uri.Get("prefix").MustString()
+				if path == "" {
+					path = uri.Get("exact").MustString()
+				}
+				if path == "" {
+					path = uri.Get("regex").MustString(ROUTE_DEFAULT_PATH)
+				}
+				paths = append(paths, path)
+			}
+			if len(paths) == 0 {
+				paths = []string{ROUTE_DEFAULT_PATH}
+			}
+			destinations := httpRoute.Get("route")
+			for j := range destinations.MustArray() {
+				destination := destinations.GetIndex(j).Get("destination")
+				// the destination host is the short name or the FQDN of the service, such as 'reviews.prod.svc.cluster.local'
+				hostParts := strings.Split(destination.Get("host").MustString(), ".")
+				backendNamespace := namespace
+				if len(hostParts) > 1 {
+					backendNamespace = hostParts[1]
+				}
+				backends = append(backends, routeBackend{
+					namespace:   backendNamespace,
+					serviceName: hostParts[0],
+					port:        destination.GetPath("port", "number").MustInt(),
+					paths:       paths,
+				})
+			}
+		}
+		ingress, rs, bs := k.generateRouteIngress(uID, name, namespace, "HTTP", spec.Get("hosts").MustStringArray(), backends)
+		ingresses = append(ingresses, ingress)
+		ingressRules = append(ingressRules, rs...)
+		ingressRuleBackends = append(ingressRuleBackends, bs...)
+	}
+	log.Debug("get route ingresses complete", logger.NewORGPrefix(k.orgID))
+	return
+}
+
+// isGatewayVirtualService returns whether the virtualservice is applied to any gateway, the virtualservice without
+// gateways is applied to the sidecars only, which is the same as the reserved gateway 'mesh'
+func isGatewayVirtualService(gateways []string) bool {
+	for _, gateway := range gateways {
+		if gateway != VIRTUAL_SERVICE_MESH_GATEWAY {
+			return true
+		}
+	}
+	return false
+}
+
+// getGatewayListeners returns the listeners of the gateways, indexed by 'namespace/name' of the gateway
+func (k *KubernetesGather) getGatewayListeners() (map[string][]gatewayListener, error) {
+	nsNameToListeners := map[string][]gatewayListener{}
+	for _, g := range k.k8sInfo["*v1.Gateway"] {
+		gData, err := simplejson.NewJson([]byte(g))
+		if err != nil {
+			log.Errorf("gateway initialization simplejson error: (%s)", err.Error(), logger.NewORGPrefix(k.orgID))
+			return nil, err
+		}
+		metaData := gData.Get("metadata")
+		key := metaData.Get("namespace").MustString() + "/" + metaData.Get("name").MustString()
+		listeners := gData.GetPath("spec", "listeners")
+		for i := range listeners.MustArray() {
+			listener := listeners.GetIndex(i)
+			nsNameToListeners[key] = append(nsNameToListeners[key], gatewayListener{
+				name:     listener.Get("name").MustString(),
+				hostname: listener.Get("hostname").MustString(),
+				protocol: listener.Get("protocol").MustString(),
+			})
+		}
+	}
+	return nsNameToListeners, nil
+}
+
+func (k *KubernetesGather) getRouteMetadata(rData *simplejson.Json, resourceType string) (uID, name, namespace string, ok bool) {
+	metaData, ok := rData.CheckGet("metadata")
+	if !ok {
+		log.Infof("%s metadata not found", resourceType, logger.NewORGPrefix(k.orgID))
+		return "", "", "", false
+	}
+	uID = metaData.Get("uid").MustString()
+	if uID == "" {
+		log.Infof("%s uid not found", resourceType, logger.NewORGPrefix(k.orgID))
+		return "", "", "", false
+	}
+	name = metaData.Get("name").MustString()
+	if name == "" {
+		log.Infof("%s (%s) name not found", resourceType, uID, logger.NewORGPrefix(k.orgID))
+		return "", "", "", false
+	}
+	namespace = metaData.Get("namespace").MustString()
+	if _, ok := k.namespaceToLcuuid[namespace]; !ok {
+		log.Infof("%s (%s) namespace not found", resourceType, name, logger.NewORGPrefix(k.orgID))
+		return "", "", "", false
+	}
+	return uID, name, namespace, true
+}
+
+func (k *KubernetesGather) generateRouteIngress(uID, name, namespace, protocol string, hosts []string, backends []routeBackend) (model.PodIngress, []model.PodIngressRule, []model.PodIngressRuleBackend) {
+	var ingressRules []model.PodIngressRule
+	var ingressRuleBackends []model.PodIngressRuleBackend
+	uLcuuid := common.IDGenerateUUID(k.orgID, uID)
+	ingress := model.PodIngress{
+		Lcuuid:             uLcuuid,
+		Name:               name,
+		PodNamespaceLcuuid: k.namespaceToLcuuid[namespace],
+		AZLcuuid:           k.azLcuuid,
+		RegionLcuuid:       k.RegionUUID,
+		PodClusterLcuuid:   k.podClusterLcuuid,
+	}
+	existSet := map[string]bool{}
+	// the route without hosts matches all the hosts
+	if len(hosts) == 0 {
+		hosts = []string{""}
+	}
+	for index, host := range hosts {
+		ruleLcuuid := common.GetUUIDByOrgID(k.orgID, uLcuuid+host+"_"+strconv.Itoa(index))
+		ingressRules = append(ingressRules, model.PodIngressRule{
+			Lcuuid:           ruleLcuuid,
+			Host:             host,
+			Protocol:         protocol,
+			PodIngressLcuuid: uLcuuid,
+		})
+		for _, backend := range backends {
+			service, ok := k.nsServiceNameToService[backend.namespace+backend.serviceName]
+			if !ok {
+				log.Infof("route (%s) backend service (%s) not found", name, backend.serviceName, logger.NewORGPrefix(k.orgID))
+				continue
+			}
+			serviceLcuuid, ports := "", map[string]int{}
+			for key, v := range service {
+				serviceLcuuid = key
+				ports = v
+				break
+			}
+			if ingressLcuuid, ok := k.serviceLcuuidToIngressLcuuid[serviceLcuuid]; ok && ingressLcuuid != uLcuuid {
+				log.Infof("ingress (%s) is already associated with the service (%s), and route (%s) cannot be associated", ingressLcuuid, serviceLcuuid, uID, logger.NewORGPrefix(k.orgID))
+			} else {
+				k.serviceLcuuidToIngressLcuuid[serviceLcuuid] = uLcuuid
+			}
+			port := backend.port
+			// the port can be omitted when the service has only one port
+			if port == 0 && len(ports) == 1 {
+				for _, p := range ports {
+					port = p
+				}
+			}
+			if port == 0 {
+				log.Infof("route (%s) backend service (%s) no servicePort", uID, backend.serviceName, logger.NewORGPrefix(k.orgID))
+				continue
+			}
+			key := backend.serviceName + "_" + strconv.Itoa(port)
+			for _, path := range backend.paths {
+				// the weighted destinations of the same service share the backend
+				backendLcuuid := common.GetUUIDByOrgID(k.orgID, ruleLcuuid+key+path)
+				if existSet[backendLcuuid] {
+					continue
+				}
+				existSet[backendLcuuid] = true
+				ingressRuleBackends = append(ingressRuleBackends, model.PodIngressRuleBackend{
+					Lcuuid:               backendLcuuid,
+					Path:                 path,
+					Port:                 port,
+					PodServiceLcuuid:     serviceLcuuid,
+					PodIngressRuleLcuuid: ruleLcuuid,
+					PodIngressLcuuid:     uLcuuid,
+				})
+			}
+		}
+	}
+	return ingress, ingressRules, ingressRuleBackends
+}
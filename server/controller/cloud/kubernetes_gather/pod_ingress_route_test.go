@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes_gather
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGetRouteIngresses(t *testing.T) {
+	Convey("TestGetRouteIngresses", t, func() {
+		k := &KubernetesGather{
+			k8sInfo: map[string][]string{
+				"*v1.Gateway": {
+					`{"metadata":{"uid":"gw-1","name":"public","namespace":"infra"},"spec":{"listeners":[{"name":"https","hostname":"shop.example.com","protocol":"HTTPS"},{"name":"http","hostname":"www.example.com","protocol":"HTTP"}]}}`,
+				},
+				"*v1.HTTPRoute": {
+					`{"metadata":{"uid":"route-1","name":"shop","namespace":"shop"},"spec":{"parentRefs":[{"name":"public","namespace":"infra","sectionName":"https"}],"rules":[{"matches":[{"path":{"type":"PathPrefix","value":"/api"}},{"path":{"type":"PathPrefix","value":"/cart"}}],"backendRefs":[{"name":"api","port":8080}]},{"backendRefs":[{"name":"web"}]}]}}`,
+				},
+				"*v1beta1.VirtualService": {
+					`{"metadata":{"uid":"vs-1","name":"reviews","namespace":"shop"},"spec":{"hosts":["reviews.example.com"],"gateways":["mesh","infra/public-gateway"],"http":[{"match":[{"uri":{"prefix":"/v1"}}],"route":[{"destination":{"host":"api.shop.svc.cluster.local","port":{"number":8080},"subset":"v1"},"weight":90},{"destination":{"host":"api","port":{"number":8080},"subset":"v2"},"weight":10}]},{"route":[{"destination":{"host":"unknown"}}]}]}}`,
+					`{"metadata":{"uid":"vs-2","name":"ratings","namespace":"shop"},"spec":{"hosts":["ratings"],"gateways":["mesh"],"http":[{"route":[{"destination":{"host":"api"}}]}]}}`,
+					`{"metadata":{"uid":"vs-3","name":"details","namespace":"shop"},"spec":{"hosts":["details"],"http":[{"route":[{"destination":{"host":"api"}}]}]}}`,
+				},
+			},
+			namespaceToLcuuid: map[string]string{"infra": "ns-infra", "shop": "ns-shop"},
+			nsServiceNameToService: map[string]map[string]map[string]int{
+				"shopapi": {"svc-api": {"http": 8080}},
+				"shopweb": {"svc-web": {"http": 80}},
+			},
+			serviceLcuuidToIngressLcuuid: map[string]string{},
+		}
+
+		ingresses, rules, backends, err := k.getRouteIngresses()
+		So(err, ShouldBeNil)
+		So(len(ingresses), ShouldEqual, 2)
+		So(ingresses[0].Name, ShouldEqual, "shop")
+		So(ingresses[0].PodNamespaceLcuuid, ShouldEqual, "ns-shop")
+
+		Convey("httproute hosts are inherited from the gateway listener", func() {
+			So(rules[0].Host, ShouldEqual, "shop.example.com")
+			So(rules[0].Protocol, ShouldEqual, "HTTPS")
+		})
+
+		Convey("virtualservice hosts are used as rules", func() {
+			So(len(rules), ShouldEqual, 2)
+			So(rules[1].Host, ShouldEqual, "reviews.example.com")
+			So(rules[1].Protocol, ShouldEqual, "HTTP")
+		})
+
+		Convey("virtualservices which are not bound to gateways are skipped", func() {
+			So(ingresses[1].Name, ShouldEqual, "reviews")
+			So(isGatewayVirtualService(nil), ShouldBeFalse)
+			So(isGatewayVirtualService([]string{"mesh"}), ShouldBeFalse)
+			So(isGatewayVirtualService([]string{"mesh", "istio-system/ingressgateway"}), ShouldBeTrue)
+		})
+
+		Convey("backends are generated for every path and service port", func() {
+			var routePaths, vsPaths []string
+			for _, b := range backends {
+				if b.PodIngressLcuuid == ingresses[0].Lcuuid {
+					routePaths = append(routePaths, b.Path)
+				} else {
+					vsPaths = append(vsPaths, b.Path)
+					So(b.PodServiceLcuuid, ShouldEqual, "svc-api")
+				}
+			}
+			So(routePaths, ShouldResemble, []string{"/api", "/cart", "/"})
+			So(vsPaths, ShouldResemble, []string{"/v1"})
+			So(backends[2].Port, ShouldEqual, 80)
+			So(k.serviceLcuuidToIngressLcuuid["svc-web"], ShouldEqual, ingresses[0].Lcuuid)
+		})
+	})
+}
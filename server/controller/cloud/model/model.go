@@ -382,6 +382,7 @@ type PodGroup struct {
 	SpecHash           string `json:"spec_hash"`
 	Label              string `json:"label"`
 	Type               int    `json:"type" binding:"required"`
+	OwnerKind          string `json:"owner_kind"` // the kind of the custom resource which the custom pod group is generated from
 	PodNum             int    `json:"pod_num" binding:"required"`
 	PodNamespaceLcuuid string `json:"pod_namespace_lcuuid" binding:"required"`
 	PodClusterLcuuid   string `json:"pod_cluster_lcuuid" binding:"required"`
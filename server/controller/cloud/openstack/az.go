@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// nova 的 internal 可用区中只有控制节点的服务，不是实际的可用区
+const INTERNAL_AZ_NAME = "internal"
+
+func (o *OpenStack) getAZs() ([]model.AZ, error) {
+	var azs []model.AZ
+	for _, region := range o.toolDataSet.regions {
+		jAZs, err := o.getRawData(o.getEndpoint(region.name, SERVICE_TYPE_COMPUTE)+"/os-availability-zone/detail", "availabilityZoneInfo", nil)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range jAZs {
+			jAZ := jAZs[i]
+			zname := jAZ.Get("zoneName").MustString()
+			if !cloudcommon.CheckJsonAttributes(jAZ, []string{"zoneName"}) || zname == INTERNAL_AZ_NAME {
+				log.Infof("exclude az: %s, missing attr or internal", zname, logger.NewORGPrefix(o.orgID))
+				continue
+			}
+			lcuuid := common.GenerateUUIDByOrgID(o.orgID, region.name+"_"+zname+"_"+o.lcuuidGenerate)
+			azs = append(
+				azs,
+				model.AZ{
+					Lcuuid:       lcuuid,
+					Name:         zname,
+					RegionLcuuid: region.lcuuid,
+				},
+			)
+			o.toolDataSet.azNameToAZLcuuid[azKey(region.name, zname)] = lcuuid
+			for hostname := range jAZ.Get("hosts").MustMap() {
+				o.toolDataSet.hostnameToAZLcuuid[hostname] = lcuuid
+			}
+		}
+	}
+	return azs, nil
+}
+
+// 不同区域的可用区可以同名
+func azKey(regionName, azName string) string {
+	return regionName + "/" + azName
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// 绑定到端口的浮动IP，同时生成浮动IP到端口固定IP的 DNAT 规则
+func (o *OpenStack) getFloatingIPs() ([]model.FloatingIP, []model.NATRule, error) {
+	var fIPs []model.FloatingIP
+	var natRules []model.NATRule
+	requiredAttrs := []string{"id", "floating_ip_address", "floating_network_id", "port_id", "fixed_ip_address"}
+	for _, region := range o.toolDataSet.regions {
+		jFIPs, err := o.getRawData(o.getEndpoint(region.name, SERVICE_TYPE_NETWORK)+"/v2.0/floatingips", "floatingips", nil)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for i := range jFIPs {
+			jFIP := jFIPs[i]
+			ip := jFIP.Get("floating_ip_address").MustString()
+			if !cloudcommon.CheckJsonAttributes(jFIP, requiredAttrs) {
+				log.Infof("exclude floating_ip: %s, missing attr", ip, logger.NewORGPrefix(o.orgID))
+				continue
+			}
+			portID := jFIP.Get("port_id").MustString()
+			if portID == "" {
+				log.Debugf("exclude floating_ip: %s, not associated", ip, logger.NewORGPrefix(o.orgID))
+				continue
+			}
+			portLcuuid := common.IDGenerateUUID(o.orgID, portID)
+			o.toolDataSet.vinterfaceLcuuidToFIP[portLcuuid] = ip
+
+			vif, ok := o.toolDataSet.lcuuidToVInterface[portLcuuid]
+			if !ok {
+				log.Infof("exclude floating_ip: %s, missing vinterface info", ip, logger.NewORGPrefix(o.orgID))
+				continue
+			}
+			network, ok := o.toolDataSet.lcuuidToNetwork[common.IDGenerateUUID(o.orgID, jFIP.Get("floating_network_id").MustString())]
+			if !ok {
+				log.Infof("exclude floating_ip: %s, missing network info", ip, logger.NewORGPrefix(o.orgID))
+				continue
+			}
+			fIP := model.FloatingIP{
+				Lcuuid:        common.IDGenerateUUID(o.orgID, jFIP.Get("id").MustString()),
+				IP:            ip,
+				NetworkLcuuid: network.Lcuuid,
+				VPCLcuuid:     vif.VPCLcuuid,
+				RegionLcuuid:  region.lcuuid,
+			}
+			if vif.DeviceType == common.VIF_DEVICE_TYPE_VM {
+				fIP.VMLcuuid = vif.DeviceLcuuid
+			}
+			fIPs = append(fIPs, fIP)
+
+			fixedIP := jFIP.Get("fixed_ip_address").MustString()
+			natRules = append(
+				natRules,
+				model.NATRule{
+					Lcuuid:           common.GenerateUUIDByOrgID(o.orgID, ip+"_"+fixedIP),
+					Type:             cloudcommon.NAT_RULE_TYPE_DNAT,
+					Protocol:         cloudcommon.PROTOCOL_ALL,
+					FloatingIP:       ip,
+					FixedIP:          fixedIP,
+					VInterfaceLcuuid: portLcuuid,
+				},
+			)
+		}
+	}
+	return fIPs, natRules, nil
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"strings"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+func (o *OpenStack) getHosts() ([]model.Host, error) {
+	var hosts []model.Host
+	requiredAttrs := []string{"hypervisor_hostname", "host_ip", "service"}
+	for _, region := range o.toolDataSet.regions {
+		jHypervisors, err := o.getRawData(o.getEndpoint(region.name, SERVICE_TYPE_COMPUTE)+"/os-hypervisors/detail", "hypervisors", nil)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range jHypervisors {
+			jH := jHypervisors[i]
+			name := jH.Get("hypervisor_hostname").MustString()
+			if !cloudcommon.CheckJsonAttributes(jH, requiredAttrs) {
+				log.Infof("exclude host: %s, missing attr", name, logger.NewORGPrefix(o.orgID))
+				continue
+			}
+			// service.host 是 nova-compute 的主机名，可用区及云主机中使用的均为该名称
+			hostname := jH.Get("service").Get("host").MustString()
+			azLcuuid, ok := o.toolDataSet.hostnameToAZLcuuid[hostname]
+			if !ok {
+				log.Infof("exclude host: %s, missing az info", name, logger.NewORGPrefix(o.orgID))
+				continue
+			}
+			ip := jH.Get("host_ip").MustString()
+			host := model.Host{
+				Lcuuid:       common.GenerateUUIDByOrgID(o.orgID, region.name+"_"+hostname+"_"+o.lcuuidGenerate),
+				Name:         name,
+				IP:           ip,
+				Hostname:     hostname,
+				Type:         common.HOST_TYPE_VM,
+				HType:        hypervisorTypeToHType(jH.Get("hypervisor_type").MustString()),
+				VCPUNum:      jH.Get("vcpus").MustInt(),
+				MemTotal:     jH.Get("memory_mb").MustInt(),
+				AZLcuuid:     azLcuuid,
+				RegionLcuuid: region.lcuuid,
+			}
+			hosts = append(hosts, host)
+			o.toolDataSet.hostnameToIP[hostname] = ip
+			o.toolDataSet.azLcuuidToResourceNum[azLcuuid]++
+			o.toolDataSet.regionLcuuidToResourceNum[region.lcuuid]++
+		}
+	}
+	return hosts, nil
+}
+
+func hypervisorTypeToHType(hypervisorType string) int {
+	t := strings.ToLower(hypervisorType)
+	switch {
+	case strings.Contains(t, "vmware"):
+		return common.HOST_HTYPE_ESXI
+	case strings.Contains(t, "hyperv"):
+		return common.HOST_HTYPE_HYPER_V
+	default:
+		return common.HOST_HTYPE_KVM
+	}
+}
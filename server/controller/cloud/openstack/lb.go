@@ -0,0 +1,222 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"fmt"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// 通过 Octavia 同步负载均衡器，未部署 Octavia 的区域跳过
+func (o *OpenStack) getLBs() (
+	lbs []model.LB, lbListeners []model.LBListener, lbTargetServers []model.LBTargetServer, vifs []model.VInterface, ips []model.IP, err error,
+) {
+	requiredAttrs := []string{"id", "name", "vip_address", "vip_port_id", "vip_network_id", "vip_subnet_id"}
+	for _, region := range o.toolDataSet.regions {
+		endpoint := o.getEndpoint(region.name, SERVICE_TYPE_LOAD_BALANCER)
+		if endpoint == "" {
+			log.Infof("region: %s has no load-balancer endpoint", region.name, logger.NewORGPrefix(o.orgID))
+			continue
+		}
+		jLBs, err := o.getRawData(endpoint+"/v2/lbaas/loadbalancers", "loadbalancers", nil)
+		if err != nil {
+			return nil, nil, nil, nil, nil, err
+		}
+
+		for i := range jLBs {
+			jLB := jLBs[i]
+			name := jLB.Get("name").MustString()
+			if !cloudcommon.CheckJsonAttributes(jLB, requiredAttrs) {
+				log.Infof("exclude lb: %s, missing attr", name, logger.NewORGPrefix(o.orgID))
+				continue
+			}
+			network, ok := o.toolDataSet.lcuuidToNetwork[common.IDGenerateUUID(o.orgID, jLB.Get("vip_network_id").MustString())]
+			if !ok {
+				log.Infof("exclude lb: %s, missing network info", name, logger.NewORGPrefix(o.orgID))
+				continue
+			}
+			id := common.IDGenerateUUID(o.orgID, jLB.Get("id").MustString())
+			vip := jLB.Get("vip_address").MustString()
+			floatingIP := o.toolDataSet.vinterfaceLcuuidToFIP[common.IDGenerateUUID(o.orgID, jLB.Get("vip_port_id").MustString())]
+			lbModel := cloudcommon.LB_MODEL_INTERNAL
+			if floatingIP != "" {
+				lbModel = cloudcommon.LB_MODEL_EXTERNAL
+			}
+			lb := model.LB{
+				Lcuuid:       id,
+				Name:         name,
+				Model:        lbModel,
+				VIP:          vip,
+				VPCLcuuid:    network.VPCLcuuid,
+				RegionLcuuid: region.lcuuid,
+			}
+			lbs = append(lbs, lb)
+			o.toolDataSet.lbLcuuidToVPCLcuuid[id] = lb.VPCLcuuid
+			o.toolDataSet.lbLcuuidToIP[id] = vip
+			o.toolDataSet.lbLcuuidToSubnetLcuuid[id] = common.IDGenerateUUID(o.orgID, jLB.Get("vip_subnet_id").MustString())
+			o.toolDataSet.regionLcuuidToResourceNum[region.lcuuid]++
+
+			vifLcuuid := common.GenerateUUIDByOrgID(o.orgID, id)
+			vifs = append(
+				vifs,
+				model.VInterface{
+					Lcuuid:        vifLcuuid,
+					Type:          common.VIF_TYPE_LAN,
+					Mac:           common.VIF_DEFAULT_MAC,
+					DeviceType:    common.VIF_DEVICE_TYPE_LB,
+					DeviceLcuuid:  id,
+					NetworkLcuuid: network.Lcuuid,
+					VPCLcuuid:     lb.VPCLcuuid,
+					RegionLcuuid:  lb.RegionLcuuid,
+				},
+			)
+			ips = append(
+				ips,
+				model.IP{
+					Lcuuid:           common.GenerateUUIDByOrgID(o.orgID, vifLcuuid+vip),
+					VInterfaceLcuuid: vifLcuuid,
+					IP:               vip,
+					SubnetLcuuid:     o.toolDataSet.lbLcuuidToSubnetLcuuid[id],
+					RegionLcuuid:     lb.RegionLcuuid,
+				},
+			)
+			if floatingIP != "" {
+				wanVIFLcuuid := common.GenerateUUIDByOrgID(o.orgID, id+floatingIP)
+				vifs = append(
+					vifs,
+					model.VInterface{
+						Lcuuid:        wanVIFLcuuid,
+						Type:          common.VIF_TYPE_WAN,
+						Mac:           common.VIF_DEFAULT_MAC,
+						DeviceType:    common.VIF_DEVICE_TYPE_LB,
+						DeviceLcuuid:  id,
+						NetworkLcuuid: common.NETWORK_ISP_LCUUID,
+						VPCLcuuid:     lb.VPCLcuuid,
+						RegionLcuuid:  lb.RegionLcuuid,
+					},
+				)
+				ips = append(
+					ips,
+					model.IP{
+						Lcuuid:           common.GenerateUUIDByOrgID(o.orgID, wanVIFLcuuid+floatingIP),
+						VInterfaceLcuuid: wanVIFLcuuid,
+						IP:               floatingIP,
+						RegionLcuuid:     lb.RegionLcuuid,
+					},
+				)
+			}
+		}
+
+		lls, ltss, err := o.formatListenersAndTargetServers(endpoint)
+		if err != nil {
+			return nil, nil, nil, nil, nil, err
+		}
+		lbListeners = append(lbListeners, lls...)
+		lbTargetServers = append(lbTargetServers, ltss...)
+	}
+	return
+}
+
+func (o *OpenStack) formatListenersAndTargetServers(endpoint string) (lbListeners []model.LBListener, lbTargetServers []model.LBTargetServer, err error) {
+	jLs, err := o.getRawData(endpoint+"/v2/lbaas/listeners", "listeners", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	listenerRequiredAttrs := []string{"id", "name", "loadbalancers", "protocol_port", "protocol"}
+	memberRequiredAttrs := []string{"id", "address", "protocol_port"}
+	for i := range jLs {
+		jL := jLs[i]
+		name := jL.Get("name").MustString()
+		if !cloudcommon.CheckJsonAttributes(jL, listenerRequiredAttrs) {
+			log.Infof("exclude lb_listener: %s, missing attr", name, logger.NewORGPrefix(o.orgID))
+			continue
+		}
+		var lbLcuuid string
+		jLBs := jL.Get("loadbalancers")
+		for j := range jLBs.MustArray() {
+			if id := common.IDGenerateUUID(o.orgID, jLBs.GetIndex(j).Get("id").MustString()); o.toolDataSet.lbLcuuidToVPCLcuuid[id] != "" {
+				lbLcuuid = id
+				break
+			}
+		}
+		if lbLcuuid == "" {
+			log.Infof("exclude lb_listener: %s, missing lb info", name, logger.NewORGPrefix(o.orgID))
+			continue
+		}
+		listenerLcuuid := common.IDGenerateUUID(o.orgID, jL.Get("id").MustString())
+		protocol := jL.Get("protocol").MustString()
+		lbListeners = append(
+			lbListeners,
+			model.LBListener{
+				Lcuuid:   listenerLcuuid,
+				Name:     name,
+				LBLcuuid: lbLcuuid,
+				Port:     jL.Get("protocol_port").MustInt(),
+				Protocol: protocol,
+				IPs:      o.toolDataSet.lbLcuuidToIP[lbLcuuid],
+			},
+		)
+
+		poolID := jL.Get("default_pool_id").MustString()
+		if poolID == "" {
+			continue
+		}
+		jMembers, err := o.getRawData(fmt.Sprintf("%s/v2/lbaas/pools/%s/members", endpoint, poolID), "members", nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		for j := range jMembers {
+			jM := jMembers[j]
+			memberLcuuid := common.IDGenerateUUID(o.orgID, jM.Get("id").MustString())
+			if !cloudcommon.CheckJsonAttributes(jM, memberRequiredAttrs) {
+				log.Infof("exclude lb_target_server: %s, missing attr", memberLcuuid, logger.NewORGPrefix(o.orgID))
+				continue
+			}
+			// 未指定子网的成员与负载均衡器的 vip 在同一子网
+			subnetLcuuid := o.toolDataSet.lbLcuuidToSubnetLcuuid[lbLcuuid]
+			if subnetID := jM.Get("subnet_id").MustString(); subnetID != "" {
+				subnetLcuuid = common.IDGenerateUUID(o.orgID, subnetID)
+			}
+			ip := jM.Get("address").MustString()
+			vmLcuuid, ok := o.toolDataSet.keyToVMLcuuid[SubnetIPKey{subnetLcuuid, ip}]
+			if !ok {
+				log.Infof("exclude lb_target_server: %s, missing vm info", memberLcuuid, logger.NewORGPrefix(o.orgID))
+				continue
+			}
+			lbTargetServers = append(
+				lbTargetServers,
+				model.LBTargetServer{
+					Lcuuid:           memberLcuuid,
+					LBLcuuid:         lbLcuuid,
+					LBListenerLcuuid: listenerLcuuid,
+					Type:             common.LB_SERVER_TYPE_VM,
+					VMLcuuid:         vmLcuuid,
+					VPCLcuuid:        o.toolDataSet.lbLcuuidToVPCLcuuid[lbLcuuid],
+					IP:               ip,
+					Port:             jM.Get("protocol_port").MustInt(),
+					Protocol:         protocol,
+				},
+			)
+		}
+	}
+	return
+}
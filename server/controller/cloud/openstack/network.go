@@ -0,0 +1,105 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// 网络所属的 VPC 为连接该网络的路由器，未连接路由器的网络（如外部网络）属于区域的默认 VPC
+func (o *OpenStack) getNetworks() ([]model.Network, []model.Subnet, error) {
+	var networks []model.Network
+	var subnets []model.Subnet
+	for _, region := range o.toolDataSet.regions {
+		endpoint := o.getEndpoint(region.name, SERVICE_TYPE_NETWORK)
+		jNetworks, err := o.getRawData(endpoint+"/v2.0/networks", "networks", nil)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for i := range jNetworks {
+			jN := jNetworks[i]
+			name := jN.Get("name").MustString()
+			if !cloudcommon.CheckJsonAttributes(jN, []string{"id", "name"}) {
+				log.Infof("exclude network: %s, missing attr", name, logger.NewORGPrefix(o.orgID))
+				continue
+			}
+			id := jN.Get("id").MustString()
+			vpcLcuuid, ok := o.toolDataSet.networkIDToVPCLcuuid[id]
+			if !ok {
+				vpcLcuuid = o.defaultVPCLcuuid(region.name)
+				o.toolDataSet.regionNameToDefaultVPC[region.name] = true
+			}
+			external := jN.Get("router:external").MustBool()
+			netType := common.NETWORK_TYPE_LAN
+			if external {
+				netType = common.NETWORK_TYPE_WAN
+			}
+			lcuuid := common.IDGenerateUUID(o.orgID, id)
+			network := model.Network{
+				Lcuuid:         lcuuid,
+				Name:           name,
+				SegmentationID: jN.Get("provider:segmentation_id").MustInt(),
+				Shared:         jN.Get("shared").MustBool(),
+				External:       external,
+				NetType:        netType,
+				VPCLcuuid:      vpcLcuuid,
+				RegionLcuuid:   region.lcuuid,
+			}
+			networks = append(networks, network)
+			o.toolDataSet.lcuuidToNetwork[lcuuid] = network
+			o.toolDataSet.regionLcuuidToResourceNum[region.lcuuid]++
+		}
+
+		jSubnets, err := o.getRawData(endpoint+"/v2.0/subnets", "subnets", nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i := range jSubnets {
+			jS := jSubnets[i]
+			id := jS.Get("id").MustString()
+			if !cloudcommon.CheckJsonAttributes(jS, []string{"id", "cidr", "network_id"}) {
+				log.Infof("exclude subnet: %s, missing attr", id, logger.NewORGPrefix(o.orgID))
+				continue
+			}
+			network, ok := o.toolDataSet.lcuuidToNetwork[common.IDGenerateUUID(o.orgID, jS.Get("network_id").MustString())]
+			if !ok {
+				log.Infof("exclude subnet: %s, missing network info", id, logger.NewORGPrefix(o.orgID))
+				continue
+			}
+			name := jS.Get("name").MustString()
+			if name == "" {
+				name = id
+			}
+			subnets = append(
+				subnets,
+				model.Subnet{
+					Lcuuid:        common.IDGenerateUUID(o.orgID, id),
+					Name:          name,
+					CIDR:          jS.Get("cidr").MustString(),
+					GatewayIP:     jS.Get("gateway_ip").MustString(),
+					NetworkLcuuid: network.Lcuuid,
+					VPCLcuuid:     network.VPCLcuuid,
+				},
+			)
+		}
+	}
+	return networks, subnets, nil
+}
@@ -0,0 +1,218 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"time"
+
+	"github.com/bitly/go-simplejson"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/config"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	metadbmodel "github.com/deepflowio/deepflow/server/controller/db/metadb/model"
+	"github.com/deepflowio/deepflow/server/controller/statsd"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+var log = logger.MustGetLogger("cloud.openstack")
+
+type OpenStack struct {
+	orgID          int
+	teamID         int
+	lcuuid         string
+	lcuuidGenerate string
+	name           string
+	httpTimeout    int
+	config         *Config
+	token          *Token             // 缓存的token，过期后重新获取
+	toolDataSet    *ToolDataSet       // 处理资源数据时，构建的需要提供给其他资源使用的工具数据
+	cloudStatsd    statsd.CloudStatsd // 性能监控
+	debugger       *cloudcommon.Debugger
+}
+
+func NewOpenStack(orgID int, domain metadbmodel.Domain, globalCloudCfg config.CloudConfig) (*OpenStack, error) {
+	conf := &Config{}
+	err := conf.LoadFromString(orgID, domain.Config)
+	if err != nil {
+		return nil, err
+	}
+	return &OpenStack{
+		orgID:  orgID,
+		teamID: domain.TeamID,
+		lcuuid: domain.Lcuuid,
+		// TODO: display_name后期需要修改为uuid_generate
+		lcuuidGenerate: domain.DisplayName,
+		name:           domain.Name,
+		httpTimeout:    globalCloudCfg.HTTPTimeout,
+		config:         conf,
+		debugger:       cloudcommon.NewDebugger(domain.Name),
+	}, nil
+}
+
+func (o *OpenStack) ClearDebugLog() {
+	o.debugger.Clear()
+}
+
+func (o *OpenStack) CheckAuth() error {
+	_, err := o.createToken()
+	return err
+}
+
+func (o *OpenStack) GetCloudData() (model.Resource, error) {
+	o.cloudStatsd = statsd.NewCloudStatsd()
+	o.toolDataSet = NewToolDataSet()
+	var resource model.Resource
+	token, err := o.getToken()
+	if err != nil {
+		return resource, err
+	}
+
+	regions, err := o.getRegions(token)
+	if err != nil {
+		return resource, err
+	}
+
+	azs, err := o.getAZs()
+	if err != nil {
+		return resource, err
+	}
+
+	hosts, err := o.getHosts()
+	if err != nil {
+		return resource, err
+	}
+	resource.Hosts = append(resource.Hosts, hosts...)
+
+	// ports are used by networks, vinterfaces and floating ips, so get them only once
+	err = o.getPorts()
+	if err != nil {
+		return resource, err
+	}
+
+	networks, subnets, err := o.getNetworks()
+	if err != nil {
+		return resource, err
+	}
+	resource.Networks = append(resource.Networks, networks...)
+	resource.Subnets = append(resource.Subnets, subnets...)
+
+	vpcs, vrouters, routingTables, err := o.getVPCs()
+	if err != nil {
+		return resource, err
+	}
+	resource.VPCs = append(resource.VPCs, vpcs...)
+	resource.VRouters = append(resource.VRouters, vrouters...)
+	resource.RoutingTables = append(resource.RoutingTables, routingTables...)
+
+	dhcps, vifs, ips := o.getVInterfaces()
+	resource.DHCPPorts = append(resource.DHCPPorts, dhcps...)
+	resource.VInterfaces = append(resource.VInterfaces, vifs...)
+	resource.IPs = append(resource.IPs, ips...)
+
+	fIPs, natRules, err := o.getFloatingIPs()
+	if err != nil {
+		return resource, err
+	}
+	resource.FloatingIPs = append(resource.FloatingIPs, fIPs...)
+	resource.NATRules = append(resource.NATRules, natRules...)
+
+	vms, err := o.getVMs()
+	if err != nil {
+		return resource, err
+	}
+	resource.VMs = append(resource.VMs, vms...)
+
+	lbs, listeners, targetServers, vifs, ips, err := o.getLBs()
+	if err != nil {
+		return resource, err
+	}
+	resource.LBs = append(resource.LBs, lbs...)
+	resource.LBListeners = append(resource.LBListeners, listeners...)
+	resource.LBTargetServers = append(resource.LBTargetServers, targetServers...)
+	resource.VInterfaces = append(resource.VInterfaces, vifs...)
+	resource.IPs = append(resource.IPs, ips...)
+
+	log.Debugf("region resource num info: %v", o.toolDataSet.regionLcuuidToResourceNum, logger.NewORGPrefix(o.orgID))
+	log.Debugf("az resource num info: %v", o.toolDataSet.azLcuuidToResourceNum, logger.NewORGPrefix(o.orgID))
+	resource.Regions = cloudcommon.EliminateEmptyRegions(regions, o.toolDataSet.regionLcuuidToResourceNum)
+	resource.AZs = cloudcommon.EliminateEmptyAZs(azs, o.toolDataSet.azLcuuidToResourceNum)
+
+	o.cloudStatsd.ResCount = statsd.GetResCount(resource)
+	statsd.MetaStatsd.RegisterStatsdTable(o)
+
+	o.debugger.Refresh()
+	return resource, nil
+}
+
+func (o *OpenStack) GetStatter() statsd.StatsdStatter {
+	globalTags := map[string]string{
+		"domain_name": o.name,
+		"domain":      o.lcuuid,
+		"platform":    common.OPENSTACK_EN,
+	}
+
+	return statsd.StatsdStatter{
+		OrgID:      o.orgID,
+		TeamID:     o.teamID,
+		GlobalTags: globalTags,
+		Element:    statsd.GetCloudStatsd(o.cloudStatsd),
+	}
+}
+
+// getRawData 获取 OpenStack API 返回的资源列表
+// Nova、Neutron 及 Octavia 的分页方式相同：响应中的 <resultKey>_links 包含 rel 为 next 的下一页地址
+func (o *OpenStack) getRawData(url, resultKey string, header map[string]string) (jsonList []*simplejson.Json, err error) {
+	statsdAPIStartTime := time.Now()
+	token, err := o.getToken()
+	if err != nil {
+		return
+	}
+
+	visited := make(map[string]bool)
+	for nextURL := url; nextURL != "" && !visited[nextURL]; {
+		visited[nextURL] = true
+		resp, err := RequestGet(nextURL, token.token, time.Duration(o.httpTimeout), header)
+		if err != nil {
+			return []*simplejson.Json{}, err
+		}
+		jData := resp.Get(resultKey)
+		for i := range jData.MustArray() {
+			jsonList = append(jsonList, jData.GetIndex(i))
+		}
+
+		nextURL = ""
+		jLinks := resp.Get(resultKey + "_links")
+		for i := range jLinks.MustArray() {
+			jLink := jLinks.GetIndex(i)
+			if jLink.Get("rel").MustString() == "next" {
+				nextURL = jLink.Get("href").MustString()
+				break
+			}
+		}
+	}
+	o.cloudStatsd.RefreshAPIMoniter(resultKey, len(jsonList), statsdAPIStartTime)
+
+	o.debugger.WriteJson(resultKey, url, jsonList)
+	return
+}
+
+// getEndpoint 返回区域内服务的 endpoint，服务未部署时返回空
+func (o *OpenStack) getEndpoint(regionName, serviceType string) string {
+	return o.token.regionEndpoints[regionName][serviceType]
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/agiledragon/gomonkey/v2"
+	. "github.com/smartystreets/goconvey/convey"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/config"
+	cloudtest "github.com/deepflowio/deepflow/server/controller/cloud/test"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	metadbcommon "github.com/deepflowio/deepflow/server/controller/db/metadb/common"
+	metadbmodel "github.com/deepflowio/deepflow/server/controller/db/metadb/model"
+	"github.com/deepflowio/deepflow/server/controller/statsd"
+)
+
+const testToken = "test-token"
+
+// newFixtureServer serves the recorded OpenStack API responses in testfiles, '${ENDPOINT}' in the responses is
+// replaced with the server address
+func newFixtureServer(t *testing.T) *httptest.Server {
+	return cloudtest.NewFixtureServer(t, cloudtest.Fixtures{
+		Files: map[string]string{
+			"/compute/v2.1/os-availability-zone/detail":    "availability_zones.json",
+			"/compute/v2.1/os-hypervisors/detail":          "hypervisors.json",
+			"/compute/v2.1/servers/detail":                 "servers.json",
+			"/network/v2.0/networks":                       "networks.json",
+			"/network/v2.0/subnets":                        "subnets.json",
+			"/network/v2.0/routers":                        "routers.json",
+			"/network/v2.0/ports":                          "ports.json",
+			"/network/v2.0/floatingips":                    "floatingips.json",
+			"/load-balancer/v2/lbaas/loadbalancers":        "loadbalancers.json",
+			"/load-balancer/v2/lbaas/listeners":            "listeners.json",
+			"/load-balancer/v2/lbaas/pools/pool-1/members": "members_pool-1.json",
+		},
+		PageParam:  "marker",
+		PageSuffix: "_marker_",
+		Route: func(w http.ResponseWriter, r *http.Request) (string, bool) {
+			if r.URL.Path == "/identity/v3/auth/tokens" && r.Method == http.MethodPost {
+				w.Header().Set("X-Subject-Token", testToken)
+				w.WriteHeader(http.StatusCreated)
+				return "token.json", false
+			}
+			if r.Header.Get("X-Auth-Token") != testToken {
+				w.WriteHeader(http.StatusUnauthorized)
+				return "", true
+			}
+			return "", false
+		},
+	})
+}
+
+func TestOpenStack(t *testing.T) {
+	Convey("TestOpenStack", t, func() {
+		patches := gomonkey.ApplyFunc(common.DecryptSecretKey, func(secretKey string) (string, error) {
+			return secretKey, nil
+		})
+		defer patches.Reset()
+		statsd.MetaStatsd = &statsd.StatsdMonitor{}
+		config.CONF = &config.CloudConfig{}
+
+		server := newFixtureServer(t)
+		domain := metadbmodel.Domain{
+			Name:        "test_openstack",
+			DisplayName: "test_openstack",
+			Config:      fmt.Sprintf(`{"url": "%s/identity/v3", "username": "admin", "password": "secret", "project_name": "admin"}`, server.URL),
+		}
+		openstack, err := NewOpenStack(metadbcommon.DEFAULT_ORG_ID, domain, config.CloudConfig{HTTPTimeout: 5})
+		So(err, ShouldBeNil)
+		So(openstack.CheckAuth(), ShouldBeNil)
+
+		data, err := openstack.GetCloudData()
+		So(err, ShouldBeNil)
+
+		Convey("resource number should be equal", func() {
+			So(len(data.Regions), ShouldEqual, 1)
+			So(data.Regions[0].Name, ShouldEqual, "RegionOne")
+			So(len(data.AZs), ShouldEqual, 1)
+			So(len(data.Hosts), ShouldEqual, 2)
+			So(len(data.Networks), ShouldEqual, 3)
+			So(len(data.Subnets), ShouldEqual, 3)
+			So(len(data.VPCs), ShouldEqual, 2)
+			So(len(data.VRouters), ShouldEqual, 1)
+			So(len(data.RoutingTables), ShouldEqual, 1)
+			So(len(data.DHCPPorts), ShouldEqual, 1)
+			So(len(data.VMs), ShouldEqual, 3)
+			So(len(data.VInterfaces), ShouldEqual, 8)
+			So(len(data.IPs), ShouldEqual, 8)
+			So(len(data.FloatingIPs), ShouldEqual, 1)
+			So(len(data.NATRules), ShouldEqual, 1)
+			So(len(data.LBs), ShouldEqual, 1)
+			So(len(data.LBListeners), ShouldEqual, 1)
+			So(len(data.LBTargetServers), ShouldEqual, 2)
+		})
+
+		Convey("networks not connected to routers should belong to the default vpc", func() {
+			vpcs := map[string]string{}
+			for _, network := range data.Networks {
+				vpcs[network.Lcuuid] = network.VPCLcuuid
+			}
+			So(vpcs["net-private"], ShouldEqual, "router-1")
+			So(vpcs["net-public"], ShouldEqual, openstack.defaultVPCLcuuid("RegionOne"))
+			So(vpcs["net-isolated"], ShouldEqual, openstack.defaultVPCLcuuid("RegionOne"))
+		})
+
+		Convey("vms should be related to vpcs and hosts", func() {
+			vms := map[string]int{}
+			for i, vm := range data.VMs {
+				vms[vm.Lcuuid] = i
+			}
+			vm1 := data.VMs[vms["vm-1"]]
+			So(vm1.VPCLcuuid, ShouldEqual, "router-1")
+			So(vm1.LaunchServer, ShouldEqual, "10.0.0.11")
+			So(vm1.State, ShouldEqual, common.VM_STATE_RUNNING)
+			So(vm1.CloudTags, ShouldResemble, map[string]string{"env": "prod"})
+			So(data.VMs[vms["vm-2"]].State, ShouldEqual, common.VM_STATE_STOPPED)
+			So(data.VMs[vms["vm-3"]].VPCLcuuid, ShouldEqual, openstack.defaultVPCLcuuid("RegionOne"))
+		})
+
+		Convey("floating ips should generate dnat rules", func() {
+			So(data.FloatingIPs[0].IP, ShouldEqual, "172.24.4.20")
+			So(data.FloatingIPs[0].VMLcuuid, ShouldEqual, "vm-1")
+			So(data.NATRules[0].Type, ShouldEqual, cloudcommon.NAT_RULE_TYPE_DNAT)
+			So(data.NATRules[0].FixedIP, ShouldEqual, "10.10.0.11")
+			So(data.NATRules[0].VInterfaceLcuuid, ShouldEqual, "port-vm-1")
+		})
+
+		Convey("lb with floating ip should be external", func() {
+			So(data.LBs[0].Model, ShouldEqual, cloudcommon.LB_MODEL_EXTERNAL)
+			So(data.LBs[0].VPCLcuuid, ShouldEqual, "router-1")
+			servers := map[string]string{}
+			for _, ts := range data.LBTargetServers {
+				servers[ts.IP] = ts.VMLcuuid
+			}
+			So(servers, ShouldResemble, map[string]string{"10.10.0.11": "vm-1", "10.10.0.12": "vm-2"})
+		})
+	})
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// OpenStack 的区域取自 token 服务目录中的 region_id
+func (o *OpenStack) getRegions(token *Token) ([]model.Region, error) {
+	var names []string
+	for name := range token.regionEndpoints {
+		if len(o.config.IncludeRegions) > 0 {
+			if _, ok := o.config.IncludeRegions[name]; !ok {
+				log.Infof("exclude region: %s, not included", name, logger.NewORGPrefix(o.orgID))
+				continue
+			}
+		}
+		if token.regionEndpoints[name][SERVICE_TYPE_COMPUTE] == "" || token.regionEndpoints[name][SERVICE_TYPE_NETWORK] == "" {
+			log.Infof("exclude region: %s, missing compute or network endpoint", name, logger.NewORGPrefix(o.orgID))
+			continue
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no available region in service catalog of %s", o.config.URL)
+	}
+	sort.Strings(names)
+
+	var regions []model.Region
+	for _, name := range names {
+		region := model.Region{
+			Lcuuid: common.GenerateUUIDByOrgID(o.orgID, name+"_"+o.lcuuidGenerate),
+			Name:   name,
+		}
+		regions = append(regions, region)
+		o.toolDataSet.regions = append(o.toolDataSet.regions, Region{name: name, lcuuid: o.regionLcuuid(region.Lcuuid)})
+	}
+	return regions, nil
+}
+
+func (o *OpenStack) regionLcuuid(lcuuid string) string {
+	if o.config.RegionLcuuid != "" {
+		return o.config.RegionLcuuid
+	}
+	return lcuuid
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bitly/go-simplejson"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+const (
+	SERVICE_TYPE_COMPUTE       = "compute"
+	SERVICE_TYPE_NETWORK       = "network"
+	SERVICE_TYPE_LOAD_BALANCER = "load-balancer"
+)
+
+type Token struct {
+	token     string
+	expiresAt string
+	// 服务目录中各区域的 endpoint，region -> service type -> url
+	regionEndpoints map[string]map[string]string
+}
+
+// 检查token是否过期
+// 离失效时间不足5m时，认为已过期
+func (t *Token) isExpired() bool {
+	expire, err := time.Parse(time.RFC3339, t.expiresAt)
+	if err != nil {
+		log.Errorf("parse expire time error: %s, %v", t.expiresAt, err)
+		return true
+	}
+	return time.Until(expire).Minutes() < 5
+}
+
+func (o *OpenStack) getToken() (*Token, error) {
+	if o.token == nil || o.token.isExpired() {
+		token, err := o.createToken()
+		if err != nil {
+			return nil, err
+		}
+		o.token = token
+	}
+	return o.token, nil
+}
+
+// 使用 Keystone v3 password 方式获取 project 范围的 token，并从 token 的服务目录中获取各服务的 endpoint
+func (o *OpenStack) createToken() (*Token, error) {
+	authBody := map[string]interface{}{
+		"auth": map[string]interface{}{
+			"identity": map[string]interface{}{
+				"methods": []string{"password"},
+				"password": map[string]interface{}{
+					"user": map[string]interface{}{
+						"domain": map[string]interface{}{
+							"name": o.config.UserDomainName,
+						},
+						"name":     o.config.Username,
+						"password": o.config.Password,
+					},
+				},
+			},
+			"scope": map[string]interface{}{
+				"project": map[string]interface{}{
+					"domain": map[string]interface{}{
+						"name": o.config.ProjectDomainName,
+					},
+					"name": o.config.ProjectName,
+				},
+			},
+		},
+	}
+	resp, err := RequestPost(o.config.URL+KEYSTONE_AUTH_URL_PATH, time.Duration(o.httpTimeout), authBody)
+	if err != nil {
+		return nil, err
+	}
+	token := &Token{
+		token:           resp.Get("X-Subject-Token").MustString(),
+		expiresAt:       resp.Get("token").Get("expires_at").MustString(),
+		regionEndpoints: o.formatRegionEndpoints(resp.Get("token").Get("catalog")),
+	}
+	if token.token == "" {
+		return nil, fmt.Errorf("get token from %s failed, missing X-Subject-Token", o.config.URL)
+	}
+	return token, nil
+}
+
+func (o *OpenStack) formatRegionEndpoints(jCatalog *simplejson.Json) map[string]map[string]string {
+	regionEndpoints := make(map[string]map[string]string)
+	for i := range jCatalog.MustArray() {
+		jService := jCatalog.GetIndex(i)
+		if !cloudcommon.CheckJsonAttributes(jService, []string{"type", "endpoints"}) {
+			continue
+		}
+		serviceType := jService.Get("type").MustString()
+		jEndpoints := jService.Get("endpoints")
+		for j := range jEndpoints.MustArray() {
+			jEndpoint := jEndpoints.GetIndex(j)
+			if !cloudcommon.CheckJsonAttributes(jEndpoint, []string{"interface", "region_id", "url"}) {
+				continue
+			}
+			if jEndpoint.Get("interface").MustString() != o.config.EndpointType {
+				continue
+			}
+			region := jEndpoint.Get("region_id").MustString()
+			if _, ok := regionEndpoints[region]; !ok {
+				regionEndpoints[region] = make(map[string]string)
+			}
+			regionEndpoints[region][serviceType] = strings.TrimSuffix(jEndpoint.Get("url").MustString(), "/")
+		}
+	}
+	log.Debugf("region endpoints: %v", regionEndpoints, logger.NewORGPrefix(o.orgID))
+	return regionEndpoints
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"github.com/bitly/go-simplejson"
+
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+)
+
+type ToolDataSet struct {
+	regions                []Region
+	azNameToAZLcuuid       map[string]string
+	hostnameToAZLcuuid     map[string]string
+	hostnameToIP           map[string]string
+	regionNameToPorts      map[string][]*simplejson.Json
+	networkIDToVPCLcuuid   map[string]string
+	lcuuidToNetwork        map[string]model.Network
+	lcuuidToVInterface     map[string]model.VInterface
+	vmLcuuidToVPCLcuuid    map[string]string
+	keyToVMLcuuid          map[SubnetIPKey]string
+	vinterfaceLcuuidToFIP  map[string]string
+	lbLcuuidToVPCLcuuid    map[string]string
+	lbLcuuidToIP           map[string]string
+	lbLcuuidToSubnetLcuuid map[string]string
+	regionNameToDefaultVPC map[string]bool
+
+	regionLcuuidToResourceNum map[string]int
+	azLcuuidToResourceNum     map[string]int
+}
+
+func NewToolDataSet() *ToolDataSet {
+	return &ToolDataSet{
+		azNameToAZLcuuid:          make(map[string]string),
+		hostnameToAZLcuuid:        make(map[string]string),
+		hostnameToIP:              make(map[string]string),
+		regionNameToPorts:         make(map[string][]*simplejson.Json),
+		networkIDToVPCLcuuid:      make(map[string]string),
+		lcuuidToNetwork:           make(map[string]model.Network),
+		lcuuidToVInterface:        make(map[string]model.VInterface),
+		vmLcuuidToVPCLcuuid:       make(map[string]string),
+		keyToVMLcuuid:             make(map[SubnetIPKey]string),
+		vinterfaceLcuuidToFIP:     make(map[string]string),
+		lbLcuuidToVPCLcuuid:       make(map[string]string),
+		lbLcuuidToIP:              make(map[string]string),
+		lbLcuuidToSubnetLcuuid:    make(map[string]string),
+		regionNameToDefaultVPC:    make(map[string]bool),
+		regionLcuuidToResourceNum: make(map[string]int),
+		azLcuuidToResourceNum:     make(map[string]int),
+	}
+}
+
+// OpenStack 中用于获取各服务 endpoint 的区域
+type Region struct {
+	name   string
+	lcuuid string
+}
+
+type SubnetIPKey struct {
+	SubnetLcuuid string
+	IP           string
+}
@@ -0,0 +1,161 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"strings"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+const (
+	DEVICE_OWNER_VM_PRE                  = "compute:"
+	DEVICE_OWNER_ROUTER_GW               = "network:router_gateway"
+	DEVICE_OWNER_ROUTER_IFACE            = "network:router_interface"
+	DEVICE_OWNER_ROUTER_IFACE_DISTRIBUTE = "network:router_interface_distributed"
+	DEVICE_OWNER_ROUTER_IFACE_HA         = "network:ha_router_replicated_interface"
+	DEVICE_OWNER_DHCP                    = "network:dhcp"
+)
+
+var routerInterfaceDeviceOwners = []string{DEVICE_OWNER_ROUTER_IFACE, DEVICE_OWNER_ROUTER_IFACE_DISTRIBUTE, DEVICE_OWNER_ROUTER_IFACE_HA}
+
+func (o *OpenStack) getPorts() error {
+	for _, region := range o.toolDataSet.regions {
+		jPorts, err := o.getRawData(o.getEndpoint(region.name, SERVICE_TYPE_NETWORK)+"/v2.0/ports", "ports", nil)
+		if err != nil {
+			return err
+		}
+		o.toolDataSet.regionNameToPorts[region.name] = jPorts
+
+		for i := range jPorts {
+			jPort := jPorts[i]
+			if !common.Contains(routerInterfaceDeviceOwners, jPort.Get("device_owner").MustString()) {
+				continue
+			}
+			networkID := jPort.Get("network_id").MustString()
+			if _, ok := o.toolDataSet.networkIDToVPCLcuuid[networkID]; !ok {
+				o.toolDataSet.networkIDToVPCLcuuid[networkID] = common.IDGenerateUUID(o.orgID, jPort.Get("device_id").MustString())
+			}
+		}
+	}
+	return nil
+}
+
+func (o *OpenStack) getVInterfaces() ([]model.DHCPPort, []model.VInterface, []model.IP) {
+	var dhcpPorts []model.DHCPPort
+	var vifs []model.VInterface
+	var ips []model.IP
+	requiredAttrs := []string{"id", "mac_address", "network_id", "device_id", "device_owner"}
+	for _, region := range o.toolDataSet.regions {
+		for _, jPort := range o.toolDataSet.regionNameToPorts[region.name] {
+			mac := jPort.Get("mac_address").MustString()
+			if !cloudcommon.CheckJsonAttributes(jPort, requiredAttrs) {
+				log.Infof("exclude vinterface: %s, missing attr", mac, logger.NewORGPrefix(o.orgID))
+				continue
+			}
+			network, ok := o.toolDataSet.lcuuidToNetwork[common.IDGenerateUUID(o.orgID, jPort.Get("network_id").MustString())]
+			if !ok {
+				log.Infof("exclude vinterface: %s, missing network info", mac, logger.NewORGPrefix(o.orgID))
+				continue
+			}
+			id := common.IDGenerateUUID(o.orgID, jPort.Get("id").MustString())
+			deviceID := common.IDGenerateUUID(o.orgID, jPort.Get("device_id").MustString())
+			deviceOwner := jPort.Get("device_owner").MustString()
+			vpcLcuuid := network.VPCLcuuid
+
+			var deviceType int
+			var deviceLcuuid string
+			switch {
+			case common.Contains(routerInterfaceDeviceOwners, deviceOwner), deviceOwner == DEVICE_OWNER_ROUTER_GW:
+				deviceType = common.VIF_DEVICE_TYPE_VROUTER
+				vpcLcuuid = deviceID
+				deviceLcuuid = vrouterLcuuid(o.orgID, vpcLcuuid)
+			case deviceOwner == DEVICE_OWNER_DHCP:
+				deviceType = common.VIF_DEVICE_TYPE_DHCP_PORT
+				deviceLcuuid = id
+				name := network.Name + "_DHCP"
+				if len(name) > 256 {
+					name = name[:256]
+				}
+				dhcpPorts = append(
+					dhcpPorts,
+					model.DHCPPort{
+						Lcuuid:       id,
+						Name:         name,
+						VPCLcuuid:    vpcLcuuid,
+						RegionLcuuid: region.lcuuid,
+					},
+				)
+			case strings.HasPrefix(deviceOwner, DEVICE_OWNER_VM_PRE):
+				deviceType = common.VIF_DEVICE_TYPE_VM
+				deviceLcuuid = deviceID
+				if _, ok := o.toolDataSet.vmLcuuidToVPCLcuuid[deviceID]; !ok || !network.External {
+					o.toolDataSet.vmLcuuidToVPCLcuuid[deviceID] = vpcLcuuid
+				}
+			default:
+				// floating ip, lb vip 等端口通过对应的资源同步
+				log.Debugf("exclude vinterface: %s, %s", mac, deviceOwner, logger.NewORGPrefix(o.orgID))
+				continue
+			}
+
+			vifType := common.VIF_TYPE_LAN
+			if network.External {
+				vifType = common.VIF_TYPE_WAN
+			}
+			vif := model.VInterface{
+				Lcuuid:        id,
+				Name:          jPort.Get("name").MustString(),
+				Type:          vifType,
+				Mac:           mac,
+				DeviceType:    deviceType,
+				DeviceLcuuid:  deviceLcuuid,
+				NetworkLcuuid: network.Lcuuid,
+				VPCLcuuid:     vpcLcuuid,
+				RegionLcuuid:  region.lcuuid,
+			}
+			vifs = append(vifs, vif)
+			o.toolDataSet.lcuuidToVInterface[id] = vif
+
+			jIPs := jPort.Get("fixed_ips")
+			for j := range jIPs.MustArray() {
+				jIP := jIPs.GetIndex(j)
+				if !cloudcommon.CheckJsonAttributes(jIP, []string{"ip_address", "subnet_id"}) {
+					continue
+				}
+				ip := jIP.Get("ip_address").MustString()
+				subnetLcuuid := common.IDGenerateUUID(o.orgID, jIP.Get("subnet_id").MustString())
+				ips = append(
+					ips,
+					model.IP{
+						Lcuuid:           common.GenerateUUIDByOrgID(o.orgID, id+ip),
+						VInterfaceLcuuid: id,
+						IP:               ip,
+						SubnetLcuuid:     subnetLcuuid,
+						RegionLcuuid:     region.lcuuid,
+					},
+				)
+				if deviceType == common.VIF_DEVICE_TYPE_VM {
+					o.toolDataSet.keyToVMLcuuid[SubnetIPKey{subnetLcuuid, ip}] = deviceID
+				}
+			}
+		}
+	}
+	return dhcpPorts, vifs, ips
+}
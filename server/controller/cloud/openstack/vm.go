@@ -0,0 +1,100 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bitly/go-simplejson"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+var STATE_CONVERTION = map[string]int{
+	"ACTIVE":  common.VM_STATE_RUNNING,
+	"SHUTOFF": common.VM_STATE_STOPPED,
+	"ERROR":   common.VM_STATE_EXCEPTION,
+}
+
+func (o *OpenStack) getVMs() ([]model.VM, error) {
+	var vms []model.VM
+	requiredAttrs := []string{"id", "name", "status", "OS-EXT-AZ:availability_zone"}
+	for _, region := range o.toolDataSet.regions {
+		jVMs, err := o.getRawData(o.getEndpoint(region.name, SERVICE_TYPE_COMPUTE)+"/servers/detail?all_tenants=true", "servers", nil)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range jVMs {
+			jVM := jVMs[i]
+			name := jVM.Get("name").MustString()
+			if !cloudcommon.CheckJsonAttributes(jVM, requiredAttrs) {
+				log.Infof("exclude vm: %s, missing attr", name, logger.NewORGPrefix(o.orgID))
+				continue
+			}
+			id := common.IDGenerateUUID(o.orgID, jVM.Get("id").MustString())
+			// 云主机的 VPC 取自其端口所在网络的 VPC
+			vpcLcuuid, ok := o.toolDataSet.vmLcuuidToVPCLcuuid[id]
+			if !ok {
+				log.Infof("exclude vm: %s, missing vpc info", name, logger.NewORGPrefix(o.orgID))
+				continue
+			}
+			azLcuuid := o.toolDataSet.azNameToAZLcuuid[azKey(region.name, jVM.Get("OS-EXT-AZ:availability_zone").MustString())]
+			vm := model.VM{
+				Lcuuid:       id,
+				Name:         name,
+				Label:        name,
+				HType:        common.VM_HTYPE_VM_C,
+				State:        STATE_CONVERTION[jVM.Get("status").MustString()],
+				LaunchServer: o.toolDataSet.hostnameToIP[jVM.Get("OS-EXT-SRV-ATTR:host").MustString()],
+				VPCLcuuid:    vpcLcuuid,
+				AZLcuuid:     azLcuuid,
+				RegionLcuuid: region.lcuuid,
+				CloudTags:    o.formatVMCloudTags(jVM.Get("metadata")),
+			}
+			if created := jVM.Get("created").MustString(); created != "" {
+				createdAt, err := time.Parse(time.RFC3339, created)
+				if err != nil {
+					log.Errorf("parse created failed: %s", created, logger.NewORGPrefix(o.orgID))
+				} else {
+					vm.CreatedAt = createdAt
+				}
+			}
+			vms = append(vms, vm)
+			o.toolDataSet.azLcuuidToResourceNum[azLcuuid]++
+			o.toolDataSet.regionLcuuidToResourceNum[region.lcuuid]++
+		}
+	}
+	return vms, nil
+}
+
+// nova 中云主机的 metadata 作为云标签
+func (o *OpenStack) formatVMCloudTags(metadata *simplejson.Json) map[string]string {
+	tags := make(map[string]string)
+	for k, v := range metadata.MustMap() {
+		if s, ok := v.(string); ok {
+			tags[k] = s
+		} else {
+			tags[k] = fmt.Sprint(v)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"github.com/bitly/go-simplejson"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// OpenStack 中没有 VPC，每个路由器及连接到路由器的网络作为一个 VPC
+func (o *OpenStack) getVPCs() ([]model.VPC, []model.VRouter, []model.RoutingTable, error) {
+	var vpcs []model.VPC
+	var vrouters []model.VRouter
+	var routingTables []model.RoutingTable
+	for _, region := range o.toolDataSet.regions {
+		jRouters, err := o.getRawData(o.getEndpoint(region.name, SERVICE_TYPE_NETWORK)+"/v2.0/routers", "routers", nil)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		for i := range jRouters {
+			jR := jRouters[i]
+			name := jR.Get("name").MustString()
+			if !cloudcommon.CheckJsonAttributes(jR, []string{"id", "name"}) {
+				log.Infof("exclude vpc: %s, missing attr", name, logger.NewORGPrefix(o.orgID))
+				continue
+			}
+			vpcLcuuid := common.IDGenerateUUID(o.orgID, jR.Get("id").MustString())
+			vpcs = append(
+				vpcs,
+				model.VPC{
+					Lcuuid:       vpcLcuuid,
+					Name:         name,
+					RegionLcuuid: region.lcuuid,
+				},
+			)
+			o.toolDataSet.regionLcuuidToResourceNum[region.lcuuid]++
+
+			vrouterLcuuid := vrouterLcuuid(o.orgID, vpcLcuuid)
+			vrouters = append(
+				vrouters,
+				model.VRouter{
+					Lcuuid:       vrouterLcuuid,
+					Name:         name,
+					VPCLcuuid:    vpcLcuuid,
+					RegionLcuuid: region.lcuuid,
+				},
+			)
+			routingTables = append(routingTables, o.formatRoutingTables(jR, vpcLcuuid, vrouterLcuuid)...)
+		}
+
+		if o.toolDataSet.regionNameToDefaultVPC[region.name] {
+			vpcs = append(
+				vpcs,
+				model.VPC{
+					Lcuuid:       o.defaultVPCLcuuid(region.name),
+					Name:         o.name + "_default_vpc_" + region.name,
+					RegionLcuuid: region.lcuuid,
+				},
+			)
+		}
+	}
+	return vpcs, vrouters, routingTables, nil
+}
+
+func (o *OpenStack) formatRoutingTables(jRouter *simplejson.Json, vpcLcuuid, vrouterLcuuid string) (routingTables []model.RoutingTable) {
+	jRoutes := jRouter.Get("routes")
+	for i := range jRoutes.MustArray() {
+		jRoute := jRoutes.GetIndex(i)
+		if !cloudcommon.CheckJsonAttributes(jRoute, []string{"destination", "nexthop"}) {
+			continue
+		}
+		destination := jRoute.Get("destination").MustString()
+		nexthop := jRoute.Get("nexthop").MustString()
+		routingTables = append(
+			routingTables,
+			model.RoutingTable{
+				Lcuuid:        common.GenerateUUIDByOrgID(o.orgID, vpcLcuuid+destination+nexthop),
+				VRouterLcuuid: vrouterLcuuid,
+				Destination:   destination,
+				Nexthop:       nexthop,
+				NexthopType:   common.ROUTING_TABLE_TYPE_IP,
+			},
+		)
+	}
+	return
+}
+
+func (o *OpenStack) defaultVPCLcuuid(regionName string) string {
+	return common.GenerateUUIDByOrgID(o.orgID, regionName+"_default_vpc_"+o.lcuuidGenerate)
+}
+
+func vrouterLcuuid(orgID int, vpcLcuuid string) string {
+	return common.GenerateUUIDByOrgID(orgID, vpcLcuuid)
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	FIXTURE_DIR      = "testfiles"
+	FIXTURE_ENDPOINT = "${ENDPOINT}"
+)
+
+// Fixtures are the recorded cloud API responses in the testfiles directory of the test package
+type Fixtures struct {
+	// the fixture file of each request path
+	Files map[string]string
+	// the query parameter of the page token, the page is in the fixture '<file without .json><PageSuffix><token>.json'
+	PageParam  string
+	PageSuffix string
+	// the body of the 404 response, which is returned if the request has no fixture
+	NotFoundBody string
+	// Route is called before the fixture of the request path is looked up, such as for the token and the unauthorized
+	// requests. It returns the fixture file to respond with, or handled as true if it has responded the request. It
+	// may set the headers and the status of the fixture response.
+	Route func(w http.ResponseWriter, r *http.Request) (file string, handled bool)
+}
+
+// NewFixtureServer starts a server which responds the requests with the fixtures, '${ENDPOINT}' in the fixtures is
+// replaced with the server address. The server is closed when the test finishes.
+func NewFixtureServer(t *testing.T, fixtures Fixtures) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var file string
+		if fixtures.Route != nil {
+			var handled bool
+			if file, handled = fixtures.Route(w, r); handled {
+				return
+			}
+		}
+		if file == "" {
+			file = fixtures.Files[r.URL.Path]
+			if token := r.URL.Query().Get(fixtures.PageParam); fixtures.PageParam != "" && token != "" && file != "" {
+				file = strings.TrimSuffix(file, ".json") + fixtures.PageSuffix + token + ".json"
+			}
+		}
+		var content []byte
+		var err error
+		if file != "" {
+			content, err = os.ReadFile(filepath.Join(FIXTURE_DIR, file))
+		}
+		if file == "" || err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(fixtures.NotFoundBody))
+			return
+		}
+		w.Write([]byte(strings.ReplaceAll(string(content), FIXTURE_ENDPOINT, server.URL)))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vsphere
+
+import (
+	"net/url"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// 集群作为可用区，不属于任何集群的宿主机不同步
+func (v *VSphere) getAZs() ([]model.AZ, error) {
+	var azs []model.AZ
+	for _, dc := range v.toolDataSet.datacenters {
+		jClusters, err := v.getRawData("/api/vcenter/cluster?datacenters="+url.QueryEscape(dc.id), "cluster")
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range jClusters {
+			jC := jClusters[i]
+			name := jC.Get("name").MustString()
+			if !cloudcommon.CheckJsonAttributes(jC, []string{"cluster", "name"}) {
+				log.Infof("exclude cluster: %s, missing attr", name, logger.NewORGPrefix(v.orgID))
+				continue
+			}
+			id := jC.Get("cluster").MustString()
+			az := model.AZ{
+				Lcuuid:       common.GenerateUUIDByOrgID(v.orgID, id+"_"+v.lcuuidGenerate),
+				Name:         name,
+				RegionLcuuid: dc.regionLcuuid,
+			}
+			azs = append(azs, az)
+			v.toolDataSet.clusters = append(v.toolDataSet.clusters, Cluster{id: id, name: name, azLcuuid: az.Lcuuid, datacenter: dc})
+		}
+	}
+	return azs, nil
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vsphere
+
+import (
+	"strings"
+
+	"github.com/bitly/go-simplejson"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+type Config struct {
+	RegionLcuuid   string
+	URL            string // vCenter 地址，如 https://vcenter.example.com
+	Username       string
+	Password       string
+	IncludeRegions map[string]bool // 数据中心白名单
+}
+
+func (c *Config) LoadFromString(orgID int, sConf string) (err error) {
+	jConf, err := simplejson.NewJson([]byte(sConf))
+	if err != nil {
+		log.Errorf("convert config string: %s to json failed: %v", sConf, err, logger.NewORGPrefix(orgID))
+		return
+	}
+	c.URL, err = jConf.Get("url").String()
+	if err != nil {
+		log.Error("url must be specified", logger.NewORGPrefix(orgID))
+		return
+	}
+	if !strings.HasPrefix(c.URL, "http://") && !strings.HasPrefix(c.URL, "https://") {
+		c.URL = "https://" + c.URL
+	}
+	c.URL = strings.TrimSuffix(c.URL, "/")
+	c.Username, err = jConf.Get("username").String()
+	if err != nil {
+		log.Error("username must be specified", logger.NewORGPrefix(orgID))
+		return
+	}
+	pswd, err := jConf.Get("password").String()
+	if err != nil {
+		log.Error("password must be specified", logger.NewORGPrefix(orgID))
+		return
+	}
+	dpswd, err := common.DecryptSecretKey(pswd)
+	if err != nil {
+		log.Error("decrypt password failed", logger.NewORGPrefix(orgID))
+		return
+	}
+	c.Password = dpswd
+	c.RegionLcuuid = jConf.Get("region_uuid").MustString()
+	c.IncludeRegions = cloudcommon.UniqRegions(jConf.Get("include_regions").MustString())
+	return
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vsphere
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/bitly/go-simplejson"
+)
+
+// vSphere Automation API 的会话 header
+const SESSION_HEADER = "vmware-api-session-id"
+
+func getUnverifiedHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+}
+
+func newErr(url, msg string) error {
+	return errors.New(fmt.Sprintf("request url: %s, %s", url, msg))
+}
+
+func doRequest(req *http.Request, timeout time.Duration, expectedStatus ...int) (respBody []byte, err error) {
+	url := req.URL.String()
+	client := getUnverifiedHTTPClient(time.Second * timeout)
+	resp, err := client.Do(req)
+	if err != nil {
+		err = newErr(url, fmt.Sprintf("failed: %s", err.Error()))
+		log.Errorf(err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	respBody, err = io.ReadAll(resp.Body)
+	if err != nil {
+		err = newErr(url, fmt.Sprintf("read failed: %s", err.Error()))
+		log.Errorf(err.Error())
+		return
+	}
+	for _, status := range expectedStatus {
+		if resp.StatusCode == status {
+			return
+		}
+	}
+	err = newErr(url, fmt.Sprintf("failed: status %d, %s", resp.StatusCode, string(respBody)))
+	return
+}
+
+// RequestGet 请求 vSphere Automation API，返回的结果为 json 数组或对象
+func RequestGet(url, sessionID string, timeout time.Duration) (jsonResp *simplejson.Json, err error) {
+	log.Debugf("url: %s", url)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		err = newErr(url, fmt.Sprintf("new request failed: %s", err.Error()))
+		log.Errorf(err.Error())
+		return
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set(SESSION_HEADER, sessionID)
+
+	respBody, err := doRequest(req, timeout, http.StatusOK)
+	if err != nil {
+		return
+	}
+	jsonResp, err = simplejson.NewJson(respBody)
+	if err != nil {
+		err = newErr(url, fmt.Sprintf("JSONiz failed: %s", err.Error()))
+		log.Errorf(err.Error())
+	}
+	return
+}
+
+// CreateSession 使用用户名及密码创建会话，返回会话 ID
+func CreateSession(url, username, password string, timeout time.Duration) (string, error) {
+	req, err := http.NewRequest(http.MethodPost, url, nil)
+	if err != nil {
+		return "", newErr(url, fmt.Sprintf("new request failed: %s", err.Error()))
+	}
+	req.SetBasicAuth(username, password)
+	req.Header.Set("Accept", "application/json")
+
+	respBody, err := doRequest(req, timeout, http.StatusOK, http.StatusCreated)
+	if err != nil {
+		return "", err
+	}
+	jsonResp, err := simplejson.NewJson(respBody)
+	if err != nil {
+		return "", newErr(url, fmt.Sprintf("JSONiz failed: %s", err.Error()))
+	}
+	sessionID := jsonResp.MustString()
+	if sessionID == "" {
+		return "", newErr(url, "empty session id")
+	}
+	return sessionID, nil
+}
+
+func DeleteSession(url, sessionID string, timeout time.Duration) error {
+	req, err := http.NewRequest(http.MethodDelete, url, nil)
+	if err != nil {
+		return newErr(url, fmt.Sprintf("new request failed: %s", err.Error()))
+	}
+	req.Header.Set(SESSION_HEADER, sessionID)
+	_, err = doRequest(req, timeout, http.StatusOK, http.StatusNoContent)
+	return err
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vsphere
+
+import (
+	"net"
+	"net/url"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+const HOST_STATE_CONNECTED = "CONNECTED"
+
+func (v *VSphere) getHosts() ([]model.Host, error) {
+	var hosts []model.Host
+	for _, cluster := range v.toolDataSet.clusters {
+		jHosts, err := v.getRawData("/api/vcenter/host?clusters="+url.QueryEscape(cluster.id), "host")
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range jHosts {
+			jH := jHosts[i]
+			name := jH.Get("name").MustString()
+			if !cloudcommon.CheckJsonAttributes(jH, []string{"host", "name"}) {
+				log.Infof("exclude host: %s, missing attr", name, logger.NewORGPrefix(v.orgID))
+				continue
+			}
+			if state := jH.Get("connection_state").MustString(); state != HOST_STATE_CONNECTED {
+				log.Infof("exclude host: %s, connection state is %s", name, state, logger.NewORGPrefix(v.orgID))
+				continue
+			}
+			// ESXi 宿主机在 vCenter 中通常以 IP 或域名命名
+			ip := name
+			if net.ParseIP(name) == nil {
+				ip, err = cloudcommon.GetHostIPByName(name)
+				if err != nil {
+					log.Infof("exclude host: %s, get ip failed: %s", name, err.Error(), logger.NewORGPrefix(v.orgID))
+					continue
+				}
+			}
+			id := jH.Get("host").MustString()
+			hosts = append(
+				hosts,
+				model.Host{
+					Lcuuid:       common.GenerateUUIDByOrgID(v.orgID, id+"_"+v.lcuuidGenerate),
+					Name:         name,
+					IP:           ip,
+					Hostname:     name,
+					Type:         common.HOST_TYPE_VM,
+					HType:        common.HOST_HTYPE_ESXI,
+					AZLcuuid:     cluster.azLcuuid,
+					RegionLcuuid: cluster.datacenter.regionLcuuid,
+				},
+			)
+			v.toolDataSet.hosts = append(v.toolDataSet.hosts, Host{id: id, ip: ip, cluster: cluster})
+			v.toolDataSet.azLcuuidToResourceNum[cluster.azLcuuid]++
+			v.toolDataSet.regionLcuuidToResourceNum[cluster.datacenter.regionLcuuid]++
+		}
+	}
+	return hosts, nil
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vsphere
+
+import (
+	"net/url"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// 标准交换机及分布式交换机的端口组作为网络
+var NETWORK_TYPE_TO_LABEL = map[string]string{
+	"STANDARD_PORTGROUP":    "standard_portgroup",
+	"DISTRIBUTED_PORTGROUP": "distributed_portgroup",
+}
+
+func (v *VSphere) getNetworks() ([]model.Network, error) {
+	var networks []model.Network
+	for _, dc := range v.toolDataSet.datacenters {
+		jNetworks, err := v.getRawData("/api/vcenter/network?datacenters="+url.QueryEscape(dc.id), "network")
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range jNetworks {
+			jN := jNetworks[i]
+			name := jN.Get("name").MustString()
+			if !cloudcommon.CheckJsonAttributes(jN, []string{"network", "name", "type"}) {
+				log.Infof("exclude network: %s, missing attr", name, logger.NewORGPrefix(v.orgID))
+				continue
+			}
+			label, ok := NETWORK_TYPE_TO_LABEL[jN.Get("type").MustString()]
+			if !ok {
+				log.Infof("exclude network: %s, not support type: %s", name, jN.Get("type").MustString(), logger.NewORGPrefix(v.orgID))
+				continue
+			}
+			id := jN.Get("network").MustString()
+			lcuuid := common.GenerateUUIDByOrgID(v.orgID, id+"_"+v.lcuuidGenerate)
+			networks = append(
+				networks,
+				model.Network{
+					Lcuuid:       lcuuid,
+					Name:         name,
+					Label:        label,
+					NetType:      common.NETWORK_TYPE_LAN,
+					VPCLcuuid:    dc.vpcLcuuid,
+					RegionLcuuid: dc.regionLcuuid,
+				},
+			)
+			v.toolDataSet.networkIDToLcuuid[id] = lcuuid
+			v.toolDataSet.regionLcuuidToResourceNum[dc.regionLcuuid]++
+		}
+	}
+	return networks, nil
+}
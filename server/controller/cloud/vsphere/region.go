@@ -0,0 +1,68 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vsphere
+
+import (
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// 数据中心作为区域，vSphere 中没有 VPC，每个数据中心生成一个 VPC
+func (v *VSphere) getRegionsAndVPCs() ([]model.Region, []model.VPC, error) {
+	var regions []model.Region
+	var vpcs []model.VPC
+	jDCs, err := v.getRawData("/api/vcenter/datacenter", "datacenter")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := range jDCs {
+		jDC := jDCs[i]
+		name := jDC.Get("name").MustString()
+		if !cloudcommon.CheckJsonAttributes(jDC, []string{"datacenter", "name"}) {
+			log.Infof("exclude datacenter: %s, missing attr", name, logger.NewORGPrefix(v.orgID))
+			continue
+		}
+		if len(v.config.IncludeRegions) > 0 {
+			if _, ok := v.config.IncludeRegions[name]; !ok {
+				log.Infof("exclude datacenter: %s, not included", name, logger.NewORGPrefix(v.orgID))
+				continue
+			}
+		}
+		id := jDC.Get("datacenter").MustString()
+		region := model.Region{
+			Lcuuid: common.GenerateUUIDByOrgID(v.orgID, id+"_"+v.lcuuidGenerate),
+			Name:   name,
+		}
+		regions = append(regions, region)
+
+		regionLcuuid := region.Lcuuid
+		if v.config.RegionLcuuid != "" {
+			regionLcuuid = v.config.RegionLcuuid
+		}
+		vpc := model.VPC{
+			Lcuuid:       common.GenerateUUIDByOrgID(v.orgID, id+"_vpc_"+v.lcuuidGenerate),
+			Name:         v.name + "_" + name,
+			RegionLcuuid: regionLcuuid,
+		}
+		vpcs = append(vpcs, vpc)
+		v.toolDataSet.datacenters = append(v.toolDataSet.datacenters, Datacenter{id: id, name: name, regionLcuuid: regionLcuuid, vpcLcuuid: vpc.Lcuuid})
+	}
+	return regions, vpcs, nil
+}
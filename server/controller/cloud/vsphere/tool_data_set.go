@@ -0,0 +1,59 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vsphere
+
+type ToolDataSet struct {
+	datacenters         []Datacenter
+	clusters            []Cluster
+	hosts               []Host
+	networkIDToLcuuid   map[string]string
+	subnetLcuuidToExist map[string]bool
+
+	regionLcuuidToResourceNum map[string]int
+	azLcuuidToResourceNum     map[string]int
+}
+
+func NewToolDataSet() *ToolDataSet {
+	return &ToolDataSet{
+		networkIDToLcuuid:         make(map[string]string),
+		subnetLcuuidToExist:       make(map[string]bool),
+		regionLcuuidToResourceNum: make(map[string]int),
+		azLcuuidToResourceNum:     make(map[string]int),
+	}
+}
+
+// 数据中心作为区域，每个数据中心有一个 VPC
+type Datacenter struct {
+	id           string
+	name         string
+	regionLcuuid string
+	vpcLcuuid    string
+}
+
+// 集群作为可用区
+type Cluster struct {
+	id         string
+	name       string
+	azLcuuid   string
+	datacenter Datacenter
+}
+
+type Host struct {
+	id      string
+	ip      string
+	cluster Cluster
+}
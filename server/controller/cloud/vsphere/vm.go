@@ -0,0 +1,179 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vsphere
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/bitly/go-simplejson"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+var STATE_CONVERTION = map[string]int{
+	"POWERED_ON":  common.VM_STATE_RUNNING,
+	"POWERED_OFF": common.VM_STATE_STOPPED,
+	"SUSPENDED":   common.VM_STATE_STOPPED,
+}
+
+func (v *VSphere) getVMs() ([]model.VM, []model.VInterface, []model.IP, []model.Subnet, error) {
+	var vms []model.VM
+	var vifs []model.VInterface
+	var ips []model.IP
+	var subnets []model.Subnet
+	for _, host := range v.toolDataSet.hosts {
+		jVMs, err := v.getRawData("/api/vcenter/vm?hosts="+url.QueryEscape(host.id), "vm")
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+
+		for i := range jVMs {
+			jVM := jVMs[i]
+			name := jVM.Get("name").MustString()
+			if !cloudcommon.CheckJsonAttributes(jVM, []string{"vm", "name", "power_state"}) {
+				log.Infof("exclude vm: %s, missing attr", name, logger.NewORGPrefix(v.orgID))
+				continue
+			}
+			id := jVM.Get("vm").MustString()
+			jDetail, err := v.getRawDetail("/api/vcenter/vm/"+url.PathEscape(id), "vm_detail")
+			if err != nil {
+				return nil, nil, nil, nil, err
+			}
+
+			lcuuid := common.GenerateUUIDByOrgID(v.orgID, id+"_"+v.lcuuidGenerate)
+			vm := model.VM{
+				Lcuuid:       lcuuid,
+				Name:         name,
+				Label:        id,
+				HType:        common.VM_HTYPE_VM_C,
+				State:        STATE_CONVERTION[jVM.Get("power_state").MustString()],
+				LaunchServer: host.ip,
+				VPCLcuuid:    host.cluster.datacenter.vpcLcuuid,
+				AZLcuuid:     host.cluster.azLcuuid,
+				RegionLcuuid: host.cluster.datacenter.regionLcuuid,
+			}
+
+			macToIPs := v.getGuestIPs(id)
+			jNICs := jDetail.Get("nics")
+			for key := range jNICs.MustMap() {
+				jNIC := jNICs.Get(key)
+				mac := strings.ToLower(jNIC.Get("mac_address").MustString())
+				networkLcuuid, ok := v.toolDataSet.networkIDToLcuuid[jNIC.Get("backing").Get("network").MustString()]
+				if mac == "" || !ok {
+					log.Infof("exclude vinterface: %s of vm: %s, missing mac or network info", key, name, logger.NewORGPrefix(v.orgID))
+					continue
+				}
+				vif := model.VInterface{
+					Lcuuid:        common.GenerateUUIDByOrgID(v.orgID, lcuuid+mac),
+					Name:          jNIC.Get("label").MustString(),
+					Type:          common.VIF_TYPE_LAN,
+					Mac:           mac,
+					DeviceType:    common.VIF_DEVICE_TYPE_VM,
+					DeviceLcuuid:  lcuuid,
+					NetworkLcuuid: networkLcuuid,
+					VPCLcuuid:     vm.VPCLcuuid,
+					RegionLcuuid:  vm.RegionLcuuid,
+				}
+				vifs = append(vifs, vif)
+
+				for _, guestIP := range macToIPs[mac] {
+					subnet, err := v.formatSubnet(guestIP, vif)
+					if err != nil {
+						log.Infof("exclude ip: %s of vm: %s, %s", guestIP.ip, name, err.Error(), logger.NewORGPrefix(v.orgID))
+						continue
+					}
+					if !v.toolDataSet.subnetLcuuidToExist[subnet.Lcuuid] {
+						v.toolDataSet.subnetLcuuidToExist[subnet.Lcuuid] = true
+						subnets = append(subnets, subnet)
+					}
+					ips = append(
+						ips,
+						model.IP{
+							Lcuuid:           common.GenerateUUIDByOrgID(v.orgID, vif.Lcuuid+guestIP.ip),
+							VInterfaceLcuuid: vif.Lcuuid,
+							IP:               guestIP.ip,
+							SubnetLcuuid:     subnet.Lcuuid,
+							RegionLcuuid:     vm.RegionLcuuid,
+						},
+					)
+					if vm.IP == "" && !strings.Contains(guestIP.ip, ":") {
+						vm.IP = guestIP.ip
+					}
+				}
+			}
+			vms = append(vms, vm)
+			v.toolDataSet.azLcuuidToResourceNum[vm.AZLcuuid]++
+			v.toolDataSet.regionLcuuidToResourceNum[vm.RegionLcuuid]++
+		}
+	}
+	return vms, vifs, ips, subnets, nil
+}
+
+type guestIP struct {
+	ip           string
+	prefixLength int
+}
+
+// getGuestIPs 返回 VMware Tools 上报的各网卡的 IP，VMware Tools 未运行时返回空
+func (v *VSphere) getGuestIPs(vmID string) map[string][]guestIP {
+	macToIPs := make(map[string][]guestIP)
+	jInterfaces, err := v.getRawData(fmt.Sprintf("/api/vcenter/vm/%s/guest/networking/interfaces", url.PathEscape(vmID)), "guest_interfaces")
+	if err != nil {
+		log.Infof("get guest interfaces of vm: %s failed, vmware tools may be not running: %s", vmID, err.Error(), logger.NewORGPrefix(v.orgID))
+		return macToIPs
+	}
+	for _, jI := range jInterfaces {
+		mac := strings.ToLower(jI.Get("mac_address").MustString())
+		if mac == "" {
+			continue
+		}
+		macToIPs[mac] = append(macToIPs[mac], formatGuestIPs(jI.Get("ip").Get("ip_addresses"))...)
+	}
+	return macToIPs
+}
+
+func formatGuestIPs(jIPs *simplejson.Json) (ips []guestIP) {
+	for i := range jIPs.MustArray() {
+		jIP := jIPs.GetIndex(i)
+		ip := net.ParseIP(jIP.Get("ip_address").MustString())
+		if ip == nil || ip.IsLinkLocalUnicast() || ip.IsLoopback() {
+			continue
+		}
+		ips = append(ips, guestIP{ip: ip.String(), prefixLength: jIP.Get("prefix_length").MustInt()})
+	}
+	return
+}
+
+func (v *VSphere) formatSubnet(ip guestIP, vif model.VInterface) (model.Subnet, error) {
+	cidr, err := cloudcommon.IPAndMaskToCIDR(ip.ip, ip.prefixLength)
+	if err != nil {
+		return model.Subnet{}, err
+	}
+	return model.Subnet{
+		Lcuuid:        common.GenerateUUIDByOrgID(v.orgID, vif.NetworkLcuuid+cidr),
+		Name:          cidr,
+		CIDR:          cidr,
+		NetworkLcuuid: vif.NetworkLcuuid,
+		VPCLcuuid:     vif.VPCLcuuid,
+	}, nil
+}
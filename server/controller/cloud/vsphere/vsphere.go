@@ -0,0 +1,190 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vsphere
+
+import (
+	"time"
+
+	"github.com/bitly/go-simplejson"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/config"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	metadbmodel "github.com/deepflowio/deepflow/server/controller/db/metadb/model"
+	"github.com/deepflowio/deepflow/server/controller/statsd"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+var log = logger.MustGetLogger("cloud.vsphere")
+
+const SESSION_URL_PATH = "/api/session"
+
+// VSphere 通过 vCenter 的 vSphere Automation REST API（7.0 及以上版本）同步资源
+type VSphere struct {
+	orgID          int
+	teamID         int
+	lcuuid         string
+	lcuuidGenerate string
+	name           string
+	httpTimeout    int
+	config         *Config
+	sessionID      string
+	toolDataSet    *ToolDataSet       // 处理资源数据时，构建的需要提供给其他资源使用的工具数据
+	cloudStatsd    statsd.CloudStatsd // 性能监控
+	debugger       *cloudcommon.Debugger
+}
+
+func NewVSphere(orgID int, domain metadbmodel.Domain, globalCloudCfg config.CloudConfig) (*VSphere, error) {
+	conf := &Config{}
+	err := conf.LoadFromString(orgID, domain.Config)
+	if err != nil {
+		return nil, err
+	}
+	return &VSphere{
+		orgID:  orgID,
+		teamID: domain.TeamID,
+		lcuuid: domain.Lcuuid,
+		// TODO: display_name后期需要修改为uuid_generate
+		lcuuidGenerate: domain.DisplayName,
+		name:           domain.Name,
+		httpTimeout:    globalCloudCfg.HTTPTimeout,
+		config:         conf,
+		debugger:       cloudcommon.NewDebugger(domain.Name),
+	}, nil
+}
+
+func (v *VSphere) ClearDebugLog() {
+	v.debugger.Clear()
+}
+
+func (v *VSphere) CheckAuth() error {
+	sessionID, err := CreateSession(v.config.URL+SESSION_URL_PATH, v.config.Username, v.config.Password, time.Duration(v.httpTimeout))
+	if err != nil {
+		return err
+	}
+	v.deleteSession(sessionID)
+	return nil
+}
+
+func (v *VSphere) GetCloudData() (model.Resource, error) {
+	v.cloudStatsd = statsd.NewCloudStatsd()
+	v.toolDataSet = NewToolDataSet()
+	var resource model.Resource
+	sessionID, err := CreateSession(v.config.URL+SESSION_URL_PATH, v.config.Username, v.config.Password, time.Duration(v.httpTimeout))
+	if err != nil {
+		return resource, err
+	}
+	v.sessionID = sessionID
+	defer v.deleteSession(sessionID)
+
+	regions, vpcs, err := v.getRegionsAndVPCs()
+	if err != nil {
+		return resource, err
+	}
+	resource.VPCs = append(resource.VPCs, vpcs...)
+
+	azs, err := v.getAZs()
+	if err != nil {
+		return resource, err
+	}
+
+	hosts, err := v.getHosts()
+	if err != nil {
+		return resource, err
+	}
+	resource.Hosts = append(resource.Hosts, hosts...)
+
+	networks, err := v.getNetworks()
+	if err != nil {
+		return resource, err
+	}
+	resource.Networks = append(resource.Networks, networks...)
+
+	vms, vifs, ips, subnets, err := v.getVMs()
+	if err != nil {
+		return resource, err
+	}
+	resource.VMs = append(resource.VMs, vms...)
+	resource.VInterfaces = append(resource.VInterfaces, vifs...)
+	resource.IPs = append(resource.IPs, ips...)
+	resource.Subnets = append(resource.Subnets, subnets...)
+
+	log.Debugf("region resource num info: %v", v.toolDataSet.regionLcuuidToResourceNum, logger.NewORGPrefix(v.orgID))
+	log.Debugf("az resource num info: %v", v.toolDataSet.azLcuuidToResourceNum, logger.NewORGPrefix(v.orgID))
+	resource.Regions = cloudcommon.EliminateEmptyRegions(regions, v.toolDataSet.regionLcuuidToResourceNum)
+	resource.AZs = cloudcommon.EliminateEmptyAZs(azs, v.toolDataSet.azLcuuidToResourceNum)
+
+	v.cloudStatsd.ResCount = statsd.GetResCount(resource)
+	statsd.MetaStatsd.RegisterStatsdTable(v)
+
+	v.debugger.Refresh()
+	return resource, nil
+}
+
+func (v *VSphere) GetStatter() statsd.StatsdStatter {
+	globalTags := map[string]string{
+		"domain_name": v.name,
+		"domain":      v.lcuuid,
+		"platform":    common.VSPHERE_EN,
+	}
+
+	return statsd.StatsdStatter{
+		OrgID:      v.orgID,
+		TeamID:     v.teamID,
+		GlobalTags: globalTags,
+		Element:    statsd.GetCloudStatsd(v.cloudStatsd),
+	}
+}
+
+func (v *VSphere) deleteSession(sessionID string) {
+	if err := DeleteSession(v.config.URL+SESSION_URL_PATH, sessionID, time.Duration(v.httpTimeout)); err != nil {
+		log.Warningf("delete session failed: %s", err.Error(), logger.NewORGPrefix(v.orgID))
+	}
+}
+
+// getRawData 获取 API 返回的资源列表，vSphere Automation API 的列表接口不分页
+func (v *VSphere) getRawData(path, resultKey string) ([]*simplejson.Json, error) {
+	statsdAPIStartTime := time.Now()
+	url := v.config.URL + path
+	resp, err := RequestGet(url, v.sessionID, time.Duration(v.httpTimeout))
+	if err != nil {
+		return nil, err
+	}
+	var jsonList []*simplejson.Json
+	for i := range resp.MustArray() {
+		jsonList = append(jsonList, resp.GetIndex(i))
+	}
+	v.cloudStatsd.RefreshAPIMoniter(resultKey, len(jsonList), statsdAPIStartTime)
+
+	v.debugger.WriteJson(resultKey, url, jsonList)
+	return jsonList, nil
+}
+
+// getRawDetail 获取单个资源的详情
+func (v *VSphere) getRawDetail(path, resultKey string) (*simplejson.Json, error) {
+	statsdAPIStartTime := time.Now()
+	url := v.config.URL + path
+	resp, err := RequestGet(url, v.sessionID, time.Duration(v.httpTimeout))
+	if err != nil {
+		return nil, err
+	}
+	v.cloudStatsd.RefreshAPIMoniter(resultKey, 1, statsdAPIStartTime)
+
+	v.debugger.WriteJson(resultKey, url, []*simplejson.Json{resp})
+	return resp, nil
+}
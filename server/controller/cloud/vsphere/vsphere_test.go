@@ -0,0 +1,180 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vsphere
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/agiledragon/gomonkey/v2"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/deepflowio/deepflow/server/controller/cloud/config"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	metadbcommon "github.com/deepflowio/deepflow/server/controller/db/metadb/common"
+	metadbmodel "github.com/deepflowio/deepflow/server/controller/db/metadb/model"
+	"github.com/deepflowio/deepflow/server/controller/statsd"
+)
+
+const (
+	testUsername  = "administrator@vsphere.local"
+	testPassword  = "secret"
+	testSessionID = "test-session"
+)
+
+// newSimulator serves the recorded vSphere Automation API responses in testfiles like vcsim, the guest
+// networking of vm-102 is unavailable to simulate a vm without VMware Tools running
+func newSimulator(t *testing.T) *httptest.Server {
+	fixtures := map[string]string{
+		"/api/vcenter/datacenter":                            "datacenters.json",
+		"/api/vcenter/cluster?datacenters=datacenter-1":      "clusters.json",
+		"/api/vcenter/host?clusters=domain-c1":               "hosts.json",
+		"/api/vcenter/network?datacenters=datacenter-1":      "networks.json",
+		"/api/vcenter/vm?hosts=host-11":                      "vms_host-11.json",
+		"/api/vcenter/vm?hosts=host-12":                      "vms_host-12.json",
+		"/api/vcenter/vm/vm-101":                             "vm-101.json",
+		"/api/vcenter/vm/vm-101/guest/networking/interfaces": "vm-101_guest_interfaces.json",
+		"/api/vcenter/vm/vm-102":                             "vm-102.json",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == SESSION_URL_PATH {
+			switch r.Method {
+			case http.MethodPost:
+				if username, password, ok := r.BasicAuth(); !ok || username != testUsername || password != testPassword {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`"` + testSessionID + `"`))
+			case http.MethodDelete:
+				w.WriteHeader(http.StatusNoContent)
+			}
+			return
+		}
+		if r.Header.Get(SESSION_HEADER) != testSessionID {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		file, ok := fixtures[r.URL.RequestURI()]
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		content, err := os.ReadFile("./testfiles/" + file)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(content)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestVSphere(url, password string) (*VSphere, error) {
+	domain := metadbmodel.Domain{
+		Name:        "test_vsphere",
+		DisplayName: "test_vsphere",
+		Config:      fmt.Sprintf(`{"url": "%s", "username": "%s", "password": "%s", "include_regions": "DC1"}`, url, testUsername, password),
+	}
+	return NewVSphere(metadbcommon.DEFAULT_ORG_ID, domain, config.CloudConfig{HTTPTimeout: 5})
+}
+
+func TestVSphere(t *testing.T) {
+	Convey("TestVSphere", t, func() {
+		patches := gomonkey.ApplyFunc(common.DecryptSecretKey, func(secretKey string) (string, error) {
+			return secretKey, nil
+		})
+		defer patches.Reset()
+		statsd.MetaStatsd = &statsd.StatsdMonitor{}
+		config.CONF = &config.CloudConfig{}
+
+		server := newSimulator(t)
+
+		Convey("wrong password should fail the auth check", func() {
+			vsphere, err := newTestVSphere(server.URL, "wrong")
+			So(err, ShouldBeNil)
+			So(vsphere.CheckAuth(), ShouldNotBeNil)
+		})
+
+		vsphere, err := newTestVSphere(server.URL, testPassword)
+		So(err, ShouldBeNil)
+		So(vsphere.CheckAuth(), ShouldBeNil)
+
+		data, err := vsphere.GetCloudData()
+		So(err, ShouldBeNil)
+
+		Convey("resource number should be equal", func() {
+			So(len(data.Regions), ShouldEqual, 1)
+			So(data.Regions[0].Name, ShouldEqual, "DC1")
+			So(len(data.VPCs), ShouldEqual, 1)
+			So(len(data.AZs), ShouldEqual, 1)
+			So(len(data.Hosts), ShouldEqual, 2)
+			So(len(data.Networks), ShouldEqual, 2)
+			So(len(data.VMs), ShouldEqual, 2)
+			So(len(data.VInterfaces), ShouldEqual, 3)
+			So(len(data.IPs), ShouldEqual, 2)
+			So(len(data.Subnets), ShouldEqual, 2)
+		})
+
+		Convey("vms should be related to hosts and clusters", func() {
+			vms := map[string]int{}
+			for i, vm := range data.VMs {
+				vms[vm.Name] = i
+			}
+			web := data.VMs[vms["web-1"]]
+			So(web.Label, ShouldEqual, "vm-101")
+			So(web.State, ShouldEqual, common.VM_STATE_RUNNING)
+			So(web.IP, ShouldEqual, "10.10.0.11")
+			So(web.LaunchServer, ShouldEqual, "10.1.1.11")
+			So(web.AZLcuuid, ShouldEqual, data.AZs[0].Lcuuid)
+			So(web.VPCLcuuid, ShouldEqual, data.VPCs[0].Lcuuid)
+			db := data.VMs[vms["db-1"]]
+			So(db.State, ShouldEqual, common.VM_STATE_STOPPED)
+			So(db.IP, ShouldEqual, "")
+			So(db.LaunchServer, ShouldEqual, "10.1.1.12")
+		})
+
+		Convey("ips reported by vmware tools should be related to port groups", func() {
+			networks := map[string]string{}
+			for _, network := range data.Networks {
+				networks[network.Lcuuid] = network.Name
+			}
+			vifs := map[string]string{}
+			for _, vif := range data.VInterfaces {
+				vifs[vif.Lcuuid] = vif.Mac
+				So(vif.Mac, ShouldEqual, strings.ToLower(vif.Mac))
+			}
+			subnets := map[string]string{}
+			for _, subnet := range data.Subnets {
+				subnets[subnet.Lcuuid] = subnet.CIDR + "@" + networks[subnet.NetworkLcuuid]
+			}
+			ips := map[string]string{}
+			for _, ip := range data.IPs {
+				ips[ip.IP] = vifs[ip.VInterfaceLcuuid] + "@" + subnets[ip.SubnetLcuuid]
+			}
+			So(ips, ShouldResemble, map[string]string{
+				"10.10.0.11":   "00:50:56:aa:00:01@10.10.0.0/24@VM Network",
+				"192.168.1.11": "00:50:56:aa:00:02@192.168.1.0/24@DPortGroup-100",
+			})
+		})
+	})
+}
@@ -351,6 +351,7 @@ const (
 	VOLCENGINE        = 30
 	H3C               = 31
 	FUSIONCOMPUTE     = 32
+	GCP               = 33
 
 	OPENSTACK_EN         = "openstack"
 	VSPHERE_EN           = "vsphere"
@@ -383,6 +384,7 @@ const (
 	VOLCENGINE_EN        = "volcengine"
 	H3C_EN               = "h3c"
 	FUSIONCOMPUTE_EN     = "fusioncompute"
+	GCP_EN               = "gcp"
 
 	TENCENT_CH          = "腾讯云"
 	ALIYUN_CH           = "阿里云"
@@ -405,6 +407,7 @@ const (
 	KUBERNETES_CH    = "Kubernetes"
 	CLOUD_TOWER_CH   = "CloudTower"
 	FUSIONCOMPUTE_CH = "FusionCompute"
+	GCP_CH           = "GCP"
 )
 
 var DomainTypeToIconID = map[int]int{
@@ -479,6 +482,10 @@ const (
 	VIF_DEVICE_TYPE_POD_GROUP_DAEMON_SET            = 133
 	VIF_DEVICE_TYPE_POD_GROUP_REPLICASET_CONTROLLER = 134
 	VIF_DEVICE_TYPE_POD_GROUP_CLONESET              = 135
+	VIF_DEVICE_TYPE_POD_GROUP_JOB                   = 136
+	VIF_DEVICE_TYPE_POD_GROUP_CRON_JOB              = 137
+	VIF_DEVICE_TYPE_POD_GROUP_ROLLOUT               = 138
+	VIF_DEVICE_TYPE_POD_GROUP_CUSTOM                = 139
 	VIF_DEVICE_TYPE_IP                              = 255
 )
 
@@ -582,6 +589,10 @@ const (
 	POD_GROUP_DAEMON_SET            = 4
 	POD_GROUP_REPLICASET_CONTROLLER = 5
 	POD_GROUP_CLONESET              = 6
+	POD_GROUP_JOB                   = 7
+	POD_GROUP_CRON_JOB              = 8
+	POD_GROUP_ROLLOUT               = 9
+	POD_GROUP_CUSTOM                = 10
 )
 
 const (
@@ -74,6 +74,7 @@ type ControllerConfig struct {
 	GrpcNodePort                   string `default:"30035" yaml:"grpc-node-port"`
 	Kubeconfig                     string `yaml:"kubeconfig"`
 	ElectionName                   string `default:"deepflow-server" yaml:"election-name"`
+	ElectionBackend                string `default:"kubernetes" yaml:"election-backend"`
 	ReportingDisabled              bool   `default:"false" yaml:"reporting-disabled"`
 	BillingMethod                  string `default:"license" yaml:"billing-method"`
 	PodClusterInternalIPToIngester int    `default:"0" yaml:"pod-cluster-internal-ip-to-ingester"`
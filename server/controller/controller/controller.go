@@ -45,6 +45,7 @@ import (
 	"github.com/deepflowio/deepflow/server/controller/prometheus"
 	"github.com/deepflowio/deepflow/server/controller/recorder"
 	"github.com/deepflowio/deepflow/server/controller/recorder/event"
+	"github.com/deepflowio/deepflow/server/controller/recorder/history"
 	"github.com/deepflowio/deepflow/server/controller/report"
 	"github.com/deepflowio/deepflow/server/controller/statsd"
 	"github.com/deepflowio/deepflow/server/controller/tagrecorder"
@@ -148,6 +149,7 @@ func Start(ctx context.Context, configPath, serverLogFile string, shared *server
 		time.Sleep(time.Second)
 		os.Exit(0)
 	}
+	history.GetSubscriberManager().Start(cfg.ManagerCfg.TaskCfg.RecorderCfg)
 	m := manager.NewManager(cfg.ManagerCfg)
 	m.Start()
 
@@ -35,6 +35,22 @@ CREATE TABLE IF NOT EXISTS resource_event (
 )ENGINE=innodb AUTO_INCREMENT=1 DEFAULT CHARSET=utf8;
 TRUNCATE TABLE resource_event;
 
+CREATE TABLE IF NOT EXISTS resource_history (
+    id                  INTEGER NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    domain              CHAR(64) DEFAULT '',
+    sub_domain          CHAR(64) DEFAULT '',
+    resource_type       VARCHAR(64) DEFAULT '',
+    resource_id         INTEGER DEFAULT 0,
+    resource_lcuuid     CHAR(64) DEFAULT '',
+    resource_name       VARCHAR(256) DEFAULT '',
+    operation           VARCHAR(16) DEFAULT '',
+    diff                LONGTEXT,
+    created_at          DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX resource_index(resource_type, resource_lcuuid, created_at),
+    INDEX created_at_index(created_at)
+)ENGINE=innodb AUTO_INCREMENT=1 DEFAULT CHARSET=utf8;
+TRUNCATE TABLE resource_history;
+
 CREATE TABLE IF NOT EXISTS domain_additional_resource (
     id                  INTEGER NOT NULL AUTO_INCREMENT PRIMARY KEY,
     domain              CHAR(64) DEFAULT '',
@@ -825,6 +841,7 @@ CREATE TABLE IF NOT EXISTS pod_group (
     name                VARCHAR(256) DEFAULT '',
     alias               CHAR(64) DEFAULT '',
     type                INTEGER DEFAULT NULL COMMENT '1: Deployment 2: StatefulSet 3: ReplicationController',
+    owner_kind          VARCHAR(64) DEFAULT '' COMMENT 'kind of the custom resource of the custom pod group',
     pod_num             INTEGER DEFAULT 1,
     label               TEXT COMMENT 'separated by ,',
     metadata            TEXT COMMENT 'yaml',
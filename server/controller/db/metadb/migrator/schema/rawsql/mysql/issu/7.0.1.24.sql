@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS resource_history (
+    id                  INTEGER NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    domain              CHAR(64) DEFAULT '',
+    sub_domain          CHAR(64) DEFAULT '',
+    resource_type       VARCHAR(64) DEFAULT '',
+    resource_id         INTEGER DEFAULT 0,
+    resource_lcuuid     CHAR(64) DEFAULT '',
+    resource_name       VARCHAR(256) DEFAULT '',
+    operation           VARCHAR(16) DEFAULT '',
+    diff                LONGTEXT,
+    created_at          DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX resource_index(resource_type, resource_lcuuid, created_at),
+    INDEX created_at_index(created_at)
+)ENGINE=innodb AUTO_INCREMENT=1 DEFAULT CHARSET=utf8;
+
+UPDATE db_version SET version='7.0.1.24';
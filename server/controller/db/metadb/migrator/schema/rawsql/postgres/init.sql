@@ -8,6 +8,22 @@ CREATE TABLE IF NOT EXISTS resource_event (
 );
 TRUNCATE TABLE resource_event;
 
+CREATE TABLE IF NOT EXISTS resource_history (
+    id                  SERIAL PRIMARY KEY,
+    domain              VARCHAR(64) DEFAULT '',
+    sub_domain          VARCHAR(64) DEFAULT '',
+    resource_type       VARCHAR(64) DEFAULT '',
+    resource_id         INTEGER DEFAULT 0,
+    resource_lcuuid     VARCHAR(64) DEFAULT '',
+    resource_name       VARCHAR(256) DEFAULT '',
+    operation           VARCHAR(16) DEFAULT '',
+    diff                TEXT,
+    created_at          TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX resource_history_resource_index ON resource_history (resource_type, resource_lcuuid, created_at);
+CREATE INDEX resource_history_created_at_index ON resource_history (created_at);
+TRUNCATE TABLE resource_history;
+
 CREATE TABLE IF NOT EXISTS domain_additional_resource (
     id                  SERIAL PRIMARY KEY,
     domain              VARCHAR(64) DEFAULT '',
@@ -795,6 +811,7 @@ CREATE TABLE IF NOT EXISTS pod_group (
     name                VARCHAR(256) DEFAULT '',
     alias               VARCHAR(64) DEFAULT '',
     type                INTEGER DEFAULT NULL,
+    owner_kind          VARCHAR(64) DEFAULT '',
     pod_num             INTEGER DEFAULT 1,
     label               TEXT,
     metadata            TEXT,
@@ -816,6 +833,7 @@ TRUNCATE TABLE pod_group;
 CREATE INDEX pod_group_pod_namespace_id_index ON pod_group (pod_namespace_id);
 CREATE INDEX pod_group_pod_cluster_id_index ON pod_group (pod_cluster_id);
 COMMENT ON COLUMN pod_group.type IS '1: Deployment 2: StatefulSet 3: ReplicationController';
+COMMENT ON COLUMN pod_group.owner_kind IS 'kind of the custom resource of the custom pod group';
 COMMENT ON COLUMN pod_group.label IS 'separated by ,';
 COMMENT ON COLUMN pod_group.metadata IS 'yaml format';
 COMMENT ON COLUMN pod_group.spec IS 'yaml format';
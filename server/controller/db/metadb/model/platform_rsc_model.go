@@ -31,6 +31,23 @@ type ResourceEvent struct {
 	CreatedAt      time.Time `gorm:"autoCreateTime;column:created_at;type:datetime" json:"CREATED_AT"`
 }
 
+type ResourceHistory struct {
+	ID             int       `gorm:"primaryKey;autoIncrement;unique;column:id;type:int;not null" json:"ID"`
+	Domain         string    `gorm:"column:domain;type:char(64);default:''" json:"DOMAIN"`
+	SubDomain      string    `gorm:"column:sub_domain;type:char(64);default:''" json:"SUB_DOMAIN"`
+	ResourceType   string    `gorm:"column:resource_type;type:varchar(64);default:''" json:"RESOURCE_TYPE"`
+	ResourceID     int       `gorm:"column:resource_id;type:int;default:0" json:"RESOURCE_ID"`
+	ResourceLcuuid string    `gorm:"column:resource_lcuuid;type:char(64);default:''" json:"RESOURCE_LCUUID"`
+	ResourceName   string    `gorm:"column:resource_name;type:varchar(256);default:''" json:"RESOURCE_NAME"`
+	Operation      string    `gorm:"column:operation;type:varchar(16);default:''" json:"OPERATION"` // add, update, delete
+	Diff           string    `gorm:"column:diff;type:longtext" json:"DIFF"`                         // json array of the field changes
+	CreatedAt      time.Time `gorm:"autoCreateTime;column:created_at;type:datetime" json:"CREATED_AT"`
+}
+
+func (ResourceHistory) TableName() string {
+	return "resource_history"
+}
+
 type DomainAdditionalResource struct {
 	ID                int             `gorm:"primaryKey;autoIncrement;unique;column:id;type:int;not null" json:"ID"`
 	Domain            string          `gorm:"column:domain;type:char(64);default:''" json:"DOMAIN"`
@@ -908,6 +925,7 @@ type PodGroup struct {
 	Name           string `gorm:"column:name;type:varchar(256);default:''" json:"NAME" mapstructure:"NAME"`
 	Alias          string `gorm:"column:alias;type:char(64);default:''" json:"ALIAS" mapstructure:"ALIAS"`
 	Type           int    `gorm:"column:type;type:int;default:null" json:"TYPE" mapstructure:"TYPE"` // 1: Deployment 2: StatefulSet 3: ReplicationController
+	OwnerKind      string `gorm:"column:owner_kind;type:varchar(64);default:''" json:"OWNER_KIND" mapstructure:"OWNER_KIND"`
 	PodNum         int    `gorm:"column:pod_num;type:int;default:1" json:"POD_NUM" mapstructure:"POD_NUM"`
 	Label          string `gorm:"column:label;type:text;default:''" json:"LABEL" mapstructure:"LABEL"` // separated by ,
 	Metadata       string `gorm:"column:metadata;type:text;default:''" json:"METADATA" mapstructure:"-"`
@@ -0,0 +1,169 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package election
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const ELECTION_LEASE_TABLE = "election_lease"
+
+// electionLease 选举租约，每个选举名称对应一行记录
+type electionLease struct {
+	Name                 string `gorm:"column:name;type:varchar(256);primaryKey" json:"NAME"`
+	HolderIdentity       string `gorm:"column:holder_identity;type:varchar(256);default:''" json:"HOLDER_IDENTITY"`
+	LeaseDurationSeconds int    `gorm:"column:lease_duration_seconds;type:int;default:0" json:"LEASE_DURATION_SECONDS"`
+	AcquireTime          int64  `gorm:"column:acquire_time;type:bigint;default:0" json:"ACQUIRE_TIME"` // unix microseconds
+	RenewTime            int64  `gorm:"column:renew_time;type:bigint;default:0" json:"RENEW_TIME"`     // unix microseconds
+	LeaderTransitions    int    `gorm:"column:leader_transitions;type:int;default:0" json:"LEADER_TRANSITIONS"`
+	FencingToken         int64  `gorm:"column:fencing_token;type:bigint;default:0" json:"FENCING_TOKEN"`
+}
+
+func (electionLease) TableName() string {
+	return ELECTION_LEASE_TABLE
+}
+
+// DBLock implements resourcelock.Interface with a lease row of metadb, so that deepflow-server can elect
+// the leader without kubernetes. Every write of the row increases the fencing token, and the update only
+// succeeds when the token is the same as the one observed by the last read or write, which plays the role
+// of the resourceVersion of the kubernetes Lease.
+type DBLock struct {
+	db           *gorm.DB
+	name         string
+	identity     string
+	fencingToken int64
+}
+
+// NewDBLock creates the lease table if it does not exist, the table is not created by the metadb migrator
+// because the election must be finished before the master controller migrates the metadb.
+func NewDBLock(db *gorm.DB, name, identity string) (*DBLock, error) {
+	if !db.Migrator().HasTable(&electionLease{}) {
+		if err := db.Migrator().CreateTable(&electionLease{}); err != nil {
+			// the other candidates may create the table at the same time
+			if !db.Migrator().HasTable(&electionLease{}) {
+				return nil, err
+			}
+		}
+	}
+	return &DBLock{db: db, name: name, identity: identity}, nil
+}
+
+func (l *DBLock) Get(ctx context.Context) (*resourcelock.LeaderElectionRecord, []byte, error) {
+	var leases []electionLease
+	if err := l.db.WithContext(ctx).Where("name = ?", l.name).Limit(1).Find(&leases).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(leases) == 0 {
+		return nil, nil, apierrors.NewNotFound(schema.GroupResource{Resource: ELECTION_LEASE_TABLE}, l.name)
+	}
+	l.fencingToken = leases[0].FencingToken
+	record := leaseToRecord(leases[0])
+	recordBytes, err := json.Marshal(*record)
+	if err != nil {
+		return nil, nil, err
+	}
+	return record, recordBytes, nil
+}
+
+func (l *DBLock) Create(ctx context.Context, ler resourcelock.LeaderElectionRecord) error {
+	lease := recordToLease(l.name, ler)
+	lease.FencingToken = 1
+	if err := l.db.WithContext(ctx).Create(&lease).Error; err != nil {
+		return err
+	}
+	l.fencingToken = lease.FencingToken
+	return nil
+}
+
+func (l *DBLock) Update(ctx context.Context, ler resourcelock.LeaderElectionRecord) error {
+	if l.fencingToken == 0 {
+		return fmt.Errorf("lease %s not initialized, call get or create first", l.name)
+	}
+	lease := recordToLease(l.name, ler)
+	result := l.db.WithContext(ctx).Model(&electionLease{}).
+		Where("name = ? AND fencing_token = ?", l.name, l.fencingToken).
+		Updates(map[string]interface{}{
+			"holder_identity":        lease.HolderIdentity,
+			"lease_duration_seconds": lease.LeaseDurationSeconds,
+			"acquire_time":           lease.AcquireTime,
+			"renew_time":             lease.RenewTime,
+			"leader_transitions":     lease.LeaderTransitions,
+			"fencing_token":          l.fencingToken + 1,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return apierrors.NewConflict(schema.GroupResource{Resource: ELECTION_LEASE_TABLE}, l.name,
+			fmt.Errorf("fencing token %d is out of date", l.fencingToken))
+	}
+	l.fencingToken++
+	return nil
+}
+
+func (l *DBLock) RecordEvent(s string) {
+	log.Infof("%s %s", l.identity, s)
+}
+
+func (l *DBLock) Identity() string {
+	return l.identity
+}
+
+func (l *DBLock) Describe() string {
+	return fmt.Sprintf("%s/%s", ELECTION_LEASE_TABLE, l.name)
+}
+
+// Observer returns a lock of the same lease with its own fencing token, it is used to read the lease while the
+// elector is using this lock, since the fencing token of a lock is only accessed by one goroutine.
+func (l *DBLock) Observer() *DBLock {
+	return &DBLock{db: l.db, name: l.name, identity: l.identity}
+}
+
+// FencingToken returns the fencing token of the lease observed by the last read or write
+func (l *DBLock) FencingToken() int64 {
+	return l.fencingToken
+}
+
+func leaseToRecord(lease electionLease) *resourcelock.LeaderElectionRecord {
+	return &resourcelock.LeaderElectionRecord{
+		HolderIdentity:       lease.HolderIdentity,
+		LeaseDurationSeconds: lease.LeaseDurationSeconds,
+		AcquireTime:          metav1.NewTime(time.UnixMicro(lease.AcquireTime)),
+		RenewTime:            metav1.NewTime(time.UnixMicro(lease.RenewTime)),
+		LeaderTransitions:    lease.LeaderTransitions,
+	}
+}
+
+func recordToLease(name string, ler resourcelock.LeaderElectionRecord) electionLease {
+	return electionLease{
+		Name:                 name,
+		HolderIdentity:       ler.HolderIdentity,
+		LeaseDurationSeconds: ler.LeaseDurationSeconds,
+		AcquireTime:          ler.AcquireTime.UnixMicro(),
+		RenewTime:            ler.RenewTime.UnixMicro(),
+		LeaderTransitions:    ler.LeaderTransitions,
+	}
+}
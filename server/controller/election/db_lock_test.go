@@ -0,0 +1,223 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package election
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "election_test.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("create sqlite database failed: %s", err.Error())
+	}
+	// sqlite does not support concurrent writes, the candidates share one connection
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		sqlDB.Close()
+	})
+	return db
+}
+
+func TestDBLockFencingToken(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	lockA, err := NewDBLock(db, "deepflow-server", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockB, err := NewDBLock(db, "deepflow-server", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := lockA.Get(ctx); !apierrors.IsNotFound(err) {
+		t.Fatalf("get lease before create, expected not found, got %v", err)
+	}
+	// the times are stored in microseconds
+	now := metav1.NewTime(time.Now().Truncate(time.Microsecond))
+	record := resourcelock.LeaderElectionRecord{HolderIdentity: "a", LeaseDurationSeconds: 15, AcquireTime: now, RenewTime: now}
+	if err := lockA.Create(ctx, record); err != nil {
+		t.Fatal(err)
+	}
+	if err := lockB.Create(ctx, record); err == nil {
+		t.Fatal("create lease twice, expected error")
+	}
+
+	got, _, err := lockB.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.HolderIdentity != "a" || !got.AcquireTime.Equal(&now) {
+		t.Fatalf("unexpected record %+v", got)
+	}
+
+	// a renews the lease, so the token observed by b is out of date
+	record.RenewTime = metav1.NewTime(time.Now())
+	if err := lockA.Update(ctx, record); err != nil {
+		t.Fatal(err)
+	}
+	record.HolderIdentity = "b"
+	if err := lockB.Update(ctx, record); !apierrors.IsConflict(err) {
+		t.Fatalf("update with stale fencing token, expected conflict, got %v", err)
+	}
+	if _, _, err := lockB.Get(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := lockB.Update(ctx, record); err != nil {
+		t.Fatal(err)
+	}
+	if lockB.FencingToken() != 3 {
+		t.Fatalf("expected fencing token 3, got %d", lockB.FencingToken())
+	}
+}
+
+type testCandidate struct {
+	id      string
+	cancel  context.CancelFunc
+	mutex   sync.Mutex
+	leading bool
+}
+
+func (c *testCandidate) setLeading(leading bool) {
+	c.mutex.Lock()
+	c.leading = leading
+	c.mutex.Unlock()
+}
+
+func (c *testCandidate) isLeading() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.leading
+}
+
+func startTestCandidate(t *testing.T, db *gorm.DB, id string, wg *sync.WaitGroup) *testCandidate {
+	lock, err := NewDBLock(db, "deepflow-server", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &testCandidate{id: id}
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	le, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		ReleaseOnCancel: true,
+		LeaseDuration:   time.Second,
+		RenewDeadline:   500 * time.Millisecond,
+		RetryPeriod:     100 * time.Millisecond,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) { c.setLeading(true) },
+			OnStoppedLeading: func() { c.setLeading(false) },
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	observer := lock.Observer()
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		le.Run(ctx)
+	}()
+	// the lease is read concurrently with the elector like checkLeaderValid
+	go func() {
+		defer wg.Done()
+		for ctx.Err() == nil {
+			observer.Get(ctx)
+			time.Sleep(50 * time.Millisecond)
+		}
+	}()
+	return c
+}
+
+func waitForLeader(candidates []*testCandidate, timeout time.Duration) []*testCandidate {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		var leaders []*testCandidate
+		for _, c := range candidates {
+			if c.isLeading() {
+				leaders = append(leaders, c)
+			}
+		}
+		if len(leaders) > 0 {
+			return leaders
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return nil
+}
+
+func TestDBLockElection(t *testing.T) {
+	db := newTestDB(t)
+	var wg sync.WaitGroup
+	var candidates []*testCandidate
+	for _, id := range []string{"node-1/10.0.0.1/server-1/10.0.0.1", "node-2/10.0.0.2/server-2/10.0.0.2", "node-3/10.0.0.3/server-3/10.0.0.3"} {
+		candidates = append(candidates, startTestCandidate(t, db, id, &wg))
+	}
+	defer func() {
+		for _, c := range candidates {
+			c.cancel()
+		}
+		wg.Wait()
+	}()
+
+	leaders := waitForLeader(candidates, 5*time.Second)
+	if len(leaders) != 1 {
+		t.Fatalf("expected one leader, got %d", len(leaders))
+	}
+	// the leader keeps renewing the lease, the others can not acquire it
+	time.Sleep(1500 * time.Millisecond)
+	leaders = waitForLeader(candidates, time.Second)
+	if len(leaders) != 1 {
+		t.Fatalf("expected one leader after renewing, got %d", len(leaders))
+	}
+
+	oldLeader := leaders[0]
+	oldLeader.cancel()
+	var others []*testCandidate
+	for _, c := range candidates {
+		if c != oldLeader {
+			others = append(others, c)
+		}
+	}
+	leaders = waitForLeader(others, 5*time.Second)
+	if len(leaders) != 1 {
+		t.Fatalf("expected one new leader after the leader stopped, got %d", len(leaders))
+	}
+
+	lock, _ := NewDBLock(db, "deepflow-server", "observer")
+	record, _, err := lock.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.HolderIdentity != leaders[0].id || record.LeaderTransitions == 0 {
+		t.Fatalf("unexpected lease record %+v, leader is %s", record, leaders[0].id)
+	}
+}
@@ -36,6 +36,8 @@ import (
 
 	"github.com/deepflowio/deepflow/server/controller/common"
 	"github.com/deepflowio/deepflow/server/controller/config"
+	metadbcommon "github.com/deepflowio/deepflow/server/controller/db/metadb/common"
+	migratorcommon "github.com/deepflowio/deepflow/server/controller/db/metadb/migrator/common"
 	"github.com/deepflowio/deepflow/server/controller/trisolaris/utils"
 	"github.com/deepflowio/deepflow/server/controller/trisolaris/utils/atomicbool"
 )
@@ -55,6 +57,9 @@ func GetAcquireTime() int64 { // 提供给 trisolairs 使用，确保各个 serv
 
 const (
 	ID_ITEM_NUM = 4
+
+	ELECTION_BACKEND_KUBERNETES = "kubernetes"
+	ELECTION_BACKEND_METADB     = "metadb"
 )
 
 type LeaderData struct {
@@ -121,7 +126,7 @@ func GetLeader() string {
 	return leaderData.GetLeader()
 }
 
-func getCurrentLeader(ctx context.Context, lock *resourcelock.LeaseLock) string {
+func getCurrentLeader(ctx context.Context, lock resourcelock.Interface) string {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 	record, _, err := lock.Get(ctx)
@@ -133,7 +138,7 @@ func getCurrentLeader(ctx context.Context, lock *resourcelock.LeaseLock) string
 	return record.HolderIdentity
 }
 
-func checkLeaderValid(ctx context.Context, lock *resourcelock.LeaseLock) { // server 启动后，确保设置稳定的 leaderData
+func checkLeaderValid(ctx context.Context, lock resourcelock.Interface) { // server 启动后，确保设置稳定的 leaderData
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
 	ctx, cancel := context.WithCancel(ctx)
@@ -172,36 +177,83 @@ func checkLeaderValid(ctx context.Context, lock *resourcelock.LeaseLock) { // se
 	}
 }
 
-func Start(ctx context.Context, cfg *config.ControllerConfig) {
-	kubeconfig := cfg.Kubeconfig
-	electionName := cfg.ElectionName
-	electionNamespace := common.GetNameSpace()
-	id := getID()
-	log.Infof("election id is %s", id)
+func newKubernetesLock(cfg *config.ControllerConfig, id string) (resourcelock.Interface, error) {
 	// leader election uses the Kubernetes API by writing to a
 	// lock object, which can be a LeaseLock object (preferred),
 	// a ConfigMap, or an Endpoints (deprecated) object.
 	// Conflicting writes are detected and each client handles those actions
 	// independently.
-	config, err := buildConfig(kubeconfig)
+	config, err := buildConfig(cfg.Kubeconfig)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	client := clientset.NewForConfigOrDie(config)
 
-	lock := &resourcelock.LeaseLock{
+	return &resourcelock.LeaseLock{
 		LeaseMeta: metav1.ObjectMeta{
-			Name:      electionName,
-			Namespace: electionNamespace,
+			Name:      cfg.ElectionName,
+			Namespace: common.GetNameSpace(),
 		},
 		Client: client.CoordinationV1(),
 		LockConfig: resourcelock.ResourceLockConfig{
 			Identity: id,
 		},
+	}, nil
+}
+
+// newMetadbLock uses a lease row of metadb as the lock, it is used when deepflow-server is not deployed in kubernetes,
+// the database is created if it does not exist because the election is earlier than the metadb migration
+func newMetadbLock(cfg *config.ControllerConfig, id string) (resourcelock.Interface, error) {
+	db, err := migratorcommon.GetSessionWithoutName(cfg.MetadbCfg)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = migratorcommon.CreateDatabaseIfNotExists(migratorcommon.NewDBConfig(db, cfg.MetadbCfg)); err != nil {
+		return nil, err
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+
+	db, err = metadbcommon.GetSession(cfg.MetadbCfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewDBLock(db, cfg.ElectionName, id)
+}
+
+// newObserverLock returns a lock of the same lease which is only used to get the leader
+func newObserverLock(lock resourcelock.Interface) resourcelock.Interface {
+	switch l := lock.(type) {
+	case *DBLock:
+		return l.Observer()
+	case *resourcelock.LeaseLock:
+		return &resourcelock.LeaseLock{LeaseMeta: l.LeaseMeta, Client: l.Client, LockConfig: l.LockConfig}
+	}
+	return lock
+}
+
+func Start(ctx context.Context, cfg *config.ControllerConfig) {
+	id := getID()
+	log.Infof("election id is %s, backend is %s", id, cfg.ElectionBackend)
+	var lock resourcelock.Interface
+	var err error
+	switch cfg.ElectionBackend {
+	case ELECTION_BACKEND_METADB:
+		lock, err = newMetadbLock(cfg, id)
+	case ELECTION_BACKEND_KUBERNETES, "":
+		lock, err = newKubernetesLock(cfg, id)
+	default:
+		err = fmt.Errorf("election backend (%s) is not supported", cfg.ElectionBackend)
+	}
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	go checkLeaderValid(ctx, lock)
+	// the leader is observed with another lock, because the state of the lock is not safe for concurrent use
+	observerLock := newObserverLock(lock)
+	go checkLeaderValid(ctx, observerLock)
 
 	// start the leader election code loop
 	le, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
@@ -226,7 +278,7 @@ func Start(ctx context.Context, cfg *config.ControllerConfig) {
 			OnStoppedLeading: func() {
 				// we can do cleanup here
 				log.Infof("leader lost: %s", id)
-				leaderData.SetLeader(getCurrentLeader(ctx, lock))
+				leaderData.SetLeader(getCurrentLeader(ctx, observerLock))
 			},
 			OnNewLeader: func(identity string) {
 				if leaderData.getValide() {
@@ -0,0 +1,88 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+	"github.com/deepflowio/deepflow/server/controller/http/common/response"
+	"github.com/deepflowio/deepflow/server/controller/http/router/common"
+	"github.com/deepflowio/deepflow/server/controller/http/service/resource"
+)
+
+type ResourceHistory struct{}
+
+func NewResourceHistory() *ResourceHistory {
+	return new(ResourceHistory)
+}
+
+func (h *ResourceHistory) RegisterTo(e *gin.Engine) {
+	e.GET("/v1/resource-history/", getResourceHistories)
+}
+
+// getResourceHistories returns the change history of the resources, filtered by type (required), lcuuid, operation
+// and the time range [since, until], the times are unix seconds or formatted as RFC3339
+func getResourceHistories(c *gin.Context) {
+	filter := resource.ResourceHistoryFilter{}
+	filter.ResourceType = c.Query("type")
+	if filter.ResourceType == "" {
+		response.JSON(c, response.SetError(response.ServiceError(httpcommon.PARAMETER_ILLEGAL, "please enter resource type")))
+		return
+	}
+	filter.ResourceLcuuid = c.Query("lcuuid")
+	filter.Operation = c.Query("operation")
+	for key, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value, ok := c.GetQuery(key)
+		if !ok {
+			continue
+		}
+		parsed, err := parseHistoryTime(value)
+		if err != nil {
+			response.JSON(c, response.SetError(response.ServiceError(httpcommon.INVALID_PARAMETERS, fmt.Sprintf("invalid %s (%s): %s", key, value, err.Error()))))
+			return
+		}
+		*t = parsed
+	}
+	if value, ok := c.GetQuery("limit"); ok {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			response.JSON(c, response.SetOptStatus(httpcommon.INVALID_PARAMETERS), response.SetError(err))
+			return
+		}
+		filter.Limit = limit
+	}
+
+	db, err := common.GetContextOrgDB(c)
+	if err != nil {
+		response.JSON(c, response.SetOptStatus(httpcommon.GET_ORG_DB_FAIL), response.SetError(err))
+		return
+	}
+	data, err := resource.GetResourceHistories(db, filter)
+	response.JSON(c, response.SetData(data), response.SetError(err))
+}
+
+func parseHistoryTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
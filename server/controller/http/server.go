@@ -130,6 +130,7 @@ func (s *Server) appendRegistrant() []registrant.Registrant {
 
 		// resource
 		resource.NewDomain(s.controllerConfig),
+		resource.NewResourceHistory(),
 
 		agent.NewAgentCMD(s.controllerConfig),
 		vtap.NewAgentCMD(s.controllerConfig), // TODO remove
@@ -58,6 +58,7 @@ var DOMAIN_PASSWORD_KEYS = map[string]bool{
 	"manage_one_password": false,
 	"token":               false,
 	"app_secret":          false,
+	"service_account_key": false,
 }
 
 type ResourceCount struct {
@@ -0,0 +1,99 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/db/metadb"
+	metadbmodel "github.com/deepflowio/deepflow/server/controller/db/metadb/model"
+	"github.com/deepflowio/deepflow/server/controller/recorder/history"
+)
+
+const RESOURCE_HISTORY_DEFAULT_LIMIT = 1000
+
+type ResourceHistoryFilter struct {
+	ResourceType   string
+	ResourceLcuuid string
+	Operation      string
+	Since          time.Time
+	Until          time.Time
+	Limit          int
+}
+
+type ResourceHistory struct {
+	ID             int                   `json:"ID"`
+	Domain         string                `json:"DOMAIN"`
+	SubDomain      string                `json:"SUB_DOMAIN"`
+	ResourceType   string                `json:"RESOURCE_TYPE"`
+	ResourceID     int                   `json:"RESOURCE_ID"`
+	ResourceLcuuid string                `json:"RESOURCE_LCUUID"`
+	ResourceName   string                `json:"RESOURCE_NAME"`
+	Operation      string                `json:"OPERATION"`
+	Diff           []history.FieldChange `json:"DIFF"`
+	CreatedAt      string                `json:"CREATED_AT"`
+}
+
+// GetResourceHistories returns the change history of the resources, the latest first
+func GetResourceHistories(db *metadb.DB, filter ResourceHistoryFilter) ([]*ResourceHistory, error) {
+	query := db.Where("resource_type = ?", filter.ResourceType)
+	if filter.ResourceLcuuid != "" {
+		query = query.Where("resource_lcuuid = ?", filter.ResourceLcuuid)
+	}
+	if filter.Operation != "" {
+		query = query.Where("operation = ?", filter.Operation)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("created_at <= ?", filter.Until)
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = RESOURCE_HISTORY_DEFAULT_LIMIT
+	}
+	var dbItems []*metadbmodel.ResourceHistory
+	if err := query.Order("created_at DESC, id DESC").Limit(limit).Find(&dbItems).Error; err != nil {
+		return nil, err
+	}
+
+	resp := make([]*ResourceHistory, 0, len(dbItems))
+	for _, item := range dbItems {
+		h := &ResourceHistory{
+			ID:             item.ID,
+			Domain:         item.Domain,
+			SubDomain:      item.SubDomain,
+			ResourceType:   item.ResourceType,
+			ResourceID:     item.ResourceID,
+			ResourceLcuuid: item.ResourceLcuuid,
+			ResourceName:   item.ResourceName,
+			Operation:      item.Operation,
+			Diff:           []history.FieldChange{},
+			CreatedAt:      item.CreatedAt.Format(common.GO_BIRTHDAY),
+		}
+		if item.Diff != "" {
+			if err := json.Unmarshal([]byte(item.Diff), &h.Diff); err != nil {
+				log.Errorf("json unmarshal resource_history (id: %d) diff failed: %s", item.ID, err.Error(), db.LogPrefixORGID)
+			}
+		}
+		resp = append(resp, h)
+	}
+	return resp, nil
+}
@@ -35,6 +35,7 @@ func (b *DataSet) AddPodGroup(dbItem *metadbmodel.PodGroup, seq int) {
 		Label:           dbItem.Label,
 		PodNum:          dbItem.PodNum,
 		Type:            dbItem.Type,
+		OwnerKind:       dbItem.OwnerKind,
 		Metadata:        dbItem.Metadata,
 		MetadataHash:    dbItem.MetadataHash,
 		Spec:            dbItem.Spec,
@@ -57,6 +58,7 @@ type PodGroup struct {
 	Label           string `json:"label"`
 	PodNum          int    `json:"pod_num"`
 	Type            int    `json:"type"`
+	OwnerKind       string `json:"owner_kind"`
 	Metadata        string `json:"metadata"`
 	MetadataHash    string `json:"metadata_hash"`
 	Spec            string `json:"spec"`
@@ -71,6 +73,7 @@ func (p *PodGroup) Update(cloudItem *cloudmodel.PodGroup, toolDataSet *tool.Data
 	p.Label = cloudItem.Label
 	p.PodNum = cloudItem.PodNum
 	p.Type = cloudItem.Type
+	p.OwnerKind = cloudItem.OwnerKind
 
 	yamlMetadata, err := yaml.JSONToYAML([]byte(cloudItem.Metadata))
 	if err != nil {
@@ -327,6 +327,33 @@ func (c *Cleaner) cleanDeletedData(retentionInterval int) {
 	pageDeleteExpiredAndPublish[*message.PodDelete, message.PodDelete, metadbmodel.Pod](c.org.DB, expiredAt, ctrlrcommon.RESOURCE_TYPE_POD_EN, c.toolData, c.cfg.MySQLBatchSize)
 	pageDeleteExpiredAndPublish[*message.ProcessDelete, message.ProcessDelete, metadbmodel.Process](c.org.DB, expiredAt, ctrlrcommon.RESOURCE_TYPE_PROCESS_EN, c.toolData, c.cfg.MySQLBatchSize)
 	log.Info("clean soft deleted resources completed", c.org.LogPrefix)
+
+	c.cleanExpiredResourceHistory()
+}
+
+// cleanExpiredResourceHistory deletes the expired records in batches, to avoid locking the table for a long time
+func (c *Cleaner) cleanExpiredResourceHistory() {
+	expiredAt := time.Now().Add(time.Duration(-int(c.cfg.ResourceHistory.RetentionTime)) * time.Hour)
+	var count int
+	for {
+		var ids []int
+		if err := c.org.DB.Model(&metadbmodel.ResourceHistory{}).Where("created_at < ?", expiredAt).Limit(c.cfg.MySQLBatchSize).Pluck("id", &ids).Error; err != nil {
+			log.Errorf("get expired resource_history (created_at < %s) failed: %s", expiredAt.Format(ctrlrcommon.GO_BIRTHDAY), err.Error(), c.org.LogPrefix)
+			break
+		}
+		if len(ids) == 0 {
+			break
+		}
+		if err := c.org.DB.Where("id IN ?", ids).Delete(&metadbmodel.ResourceHistory{}).Error; err != nil {
+			log.Errorf("clean expired resource_history (created_at < %s) failed: %s", expiredAt.Format(ctrlrcommon.GO_BIRTHDAY), err.Error(), c.org.LogPrefix)
+			break
+		}
+		count += len(ids)
+		if len(ids) < c.cfg.MySQLBatchSize {
+			break
+		}
+	}
+	log.Infof("clean expired resource_history (created_at < %s) completed: %d", expiredAt.Format(ctrlrcommon.GO_BIRTHDAY), count, c.org.LogPrefix)
 }
 
 func (c *Cleaner) cleanDirtyData() {
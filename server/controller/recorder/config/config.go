@@ -31,8 +31,9 @@ type RecorderConfig struct {
 	ResourceMaxID1               int    `default:"499999" yaml:"resource_max_id_1"`
 	MySQLBatchSize               int    `default:"2500" yaml:"mysql_batch_size"`
 
-	LogDebug LogDebugConfig `yaml:"log_debug"`
-	EventCfg eventConfig.Config
+	LogDebug        LogDebugConfig        `yaml:"log_debug"`
+	EventCfg        eventConfig.Config    `yaml:"event"`
+	ResourceHistory ResourceHistoryConfig `yaml:"resource_history"`
 }
 
 func Get() *RecorderConfig {
@@ -48,3 +49,9 @@ type LogDebugConfig struct {
 	DetailEnabled bool     `default:"false" yaml:"detail_enabled"`
 	ResourceTypes []string `default:"" yaml:"resource_type"`
 }
+
+type ResourceHistoryConfig struct {
+	Enabled       bool     `default:"false" yaml:"enabled"`
+	RetentionTime uint16   `default:"168" yaml:"retention_time"` // unit: hour
+	ResourceTypes []string `default:"" yaml:"resource_type"`     // record all resource types if empty
+}
@@ -17,5 +17,37 @@
 package config
 
 type Config struct {
-	ConfigDiffContext uint8 `default:"3" yaml:"config_diff_context"`
+	ConfigDiffContext uint8      `default:"3" yaml:"config_diff_context"`
+	Sink              SinkConfig `yaml:"sink"`
+}
+
+// SinkConfig configures the external receivers of resource events, every event put into the ingester queue
+// is also pushed to the webhooks and the kafka topic as JSON.
+type SinkConfig struct {
+	QueueSize int             `default:"10000" yaml:"queue_size"`
+	Webhooks  []WebhookConfig `yaml:"webhooks"`
+	Kafka     KafkaConfig     `yaml:"kafka"`
+}
+
+// SinkFilter selects the events to push, empty means all.
+type SinkFilter struct {
+	ResourceTypes []string `yaml:"resource_types"` // e.g. vm, pod, pod_service, config_map
+	Domains       []string `yaml:"domains"`        // domain lcuuids
+}
+
+type WebhookConfig struct {
+	URL              string     `yaml:"url"`
+	Secret           string     `yaml:"secret"`             // used to sign the body with HMAC-SHA256, no signature if empty
+	Timeout          int        `yaml:"timeout"`            // unit: second, default: 10
+	MaxRetries       int        `yaml:"max_retries"`        // default: 3
+	RetryInterval    int        `yaml:"retry_interval"`     // unit: second, doubled after every retry, default: 1
+	MaxRetryInterval int        `yaml:"max_retry_interval"` // unit: second, default: 30
+	Filter           SinkFilter `yaml:",inline"`
+}
+
+type KafkaConfig struct {
+	Enabled bool       `default:"false" yaml:"enabled"`
+	Brokers []string   `yaml:"brokers"`
+	Topic   string     `default:"deepflow_resource_event" yaml:"topic"`
+	Filter  SinkFilter `yaml:",inline"`
 }
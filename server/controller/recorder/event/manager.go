@@ -93,6 +93,7 @@ func (e *ManagerComponent) enqueue(md *message.Metadata, resourceLcuuid string,
 	if rt == "" {
 		rt = common.DEVICE_TYPE_INT_TO_STR[int(event.InstanceType)]
 	}
+	GetSink().Put(md, rt, resourceLcuuid, event)
 	log.Infof("put %s event (lcuuid: %s): %+v into shared queue", rt, resourceLcuuid, event, md.LogPrefixes)
 	err := e.Queue.Put(event)
 	if err != nil {
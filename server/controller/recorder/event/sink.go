@@ -0,0 +1,352 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package event
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+
+	"github.com/deepflowio/deepflow/server/controller/recorder/event/config"
+	"github.com/deepflowio/deepflow/server/controller/recorder/pubsub/message"
+	"github.com/deepflowio/deepflow/server/libs/eventapi"
+)
+
+const (
+	SINK_HEADER_SIGNATURE   = "X-DeepFlow-Signature"
+	SINK_HEADER_EVENT_TYPE  = "X-DeepFlow-Event-Type"
+	SINK_SIGNATURE_PREFIX   = "sha256="
+	SINK_DEFAULT_QUEUE_SIZE = 10000
+
+	WEBHOOK_DEFAULT_TIMEOUT            = 10 // unit: second
+	WEBHOOK_DEFAULT_MAX_RETRIES        = 3
+	WEBHOOK_DEFAULT_RETRY_INTERVAL     = 1  // unit: second
+	WEBHOOK_DEFAULT_MAX_RETRY_INTERVAL = 30 // unit: second
+)
+
+var (
+	sinkOnce sync.Once
+	sink     *Sink
+)
+
+// SinkEvent is the JSON body pushed to the webhooks and the kafka topic
+type SinkEvent struct {
+	ResourceType   string                  `json:"resource_type"`
+	ResourceLcuuid string                  `json:"resource_lcuuid"`
+	Domain         string                  `json:"domain"`
+	SubDomain      string                  `json:"sub_domain"`
+	Event          *eventapi.ResourceEvent `json:"event"`
+}
+
+type sinkMessage struct {
+	resourceType   string
+	resourceLcuuid string
+	domain         string
+	eventType      string
+	body           []byte
+}
+
+type sinkFilter struct {
+	resourceTypes map[string]struct{}
+	domains       map[string]struct{}
+}
+
+func newSinkFilter(cfg config.SinkFilter) sinkFilter {
+	f := sinkFilter{
+		resourceTypes: make(map[string]struct{}),
+		domains:       make(map[string]struct{}),
+	}
+	for _, rt := range cfg.ResourceTypes {
+		f.resourceTypes[rt] = struct{}{}
+	}
+	for _, domain := range cfg.Domains {
+		f.domains[domain] = struct{}{}
+	}
+	return f
+}
+
+func (f sinkFilter) match(msg *sinkMessage) bool {
+	if len(f.resourceTypes) != 0 {
+		if _, ok := f.resourceTypes[msg.resourceType]; !ok {
+			return false
+		}
+	}
+	if len(f.domains) != 0 {
+		if _, ok := f.domains[msg.domain]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+type sinkTarget interface {
+	name() string
+	match(msg *sinkMessage) bool
+	put(msg *sinkMessage) bool
+	run()
+}
+
+// Sink pushes the resource events to the external receivers configured in the recorder event config, each
+// receiver has its own queue, so a slow receiver does not block the others or the ingester queue.
+type Sink struct {
+	targets []sinkTarget
+}
+
+func GetSink() *Sink {
+	sinkOnce.Do(func() {
+		sink = &Sink{}
+	})
+	return sink
+}
+
+func (s *Sink) Start(cfg config.SinkConfig) {
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = SINK_DEFAULT_QUEUE_SIZE
+	}
+	var targets []sinkTarget
+	for _, webhookCfg := range cfg.Webhooks {
+		if webhookCfg.URL == "" {
+			continue
+		}
+		targets = append(targets, newWebhookSink(webhookCfg, queueSize))
+	}
+	if cfg.Kafka.Enabled {
+		kafka, err := newKafkaSink(cfg.Kafka, queueSize)
+		if err != nil {
+			log.Errorf("create resource event kafka sink failed: %s", err.Error())
+		} else {
+			targets = append(targets, kafka)
+		}
+	}
+	for _, target := range targets {
+		log.Infof("resource event sink (%s) started", target.name())
+		go target.run()
+	}
+	s.targets = targets
+}
+
+// Put serializes the event and puts it into the queues of the matched receivers. It must be called before the
+// event is put into the ingester queue, because the event may be released after being consumed.
+func (s *Sink) Put(md *message.Metadata, resourceType, resourceLcuuid string, event *eventapi.ResourceEvent) {
+	if len(s.targets) == 0 {
+		return
+	}
+	msg := &sinkMessage{
+		resourceType:   resourceType,
+		resourceLcuuid: resourceLcuuid,
+		domain:         md.GetDomainLcuuid(),
+		eventType:      event.Type,
+	}
+	var body []byte
+	for _, target := range s.targets {
+		if !target.match(msg) {
+			continue
+		}
+		if body == nil {
+			var err error
+			body, err = json.Marshal(SinkEvent{
+				ResourceType:   resourceType,
+				ResourceLcuuid: resourceLcuuid,
+				Domain:         md.GetDomainLcuuid(),
+				SubDomain:      md.GetSubDomainLcuuid(),
+				Event:          event,
+			})
+			if err != nil {
+				log.Errorf("json marshal %s event (lcuuid: %s) failed: %s", resourceType, resourceLcuuid, err.Error(), md.LogPrefixes)
+				return
+			}
+			msg.body = body
+		}
+		if !target.put(msg) {
+			log.Warningf("resource event sink (%s) queue is full, drop %s event (lcuuid: %s)", target.name(), resourceType, resourceLcuuid, md.LogPrefixes)
+		}
+	}
+}
+
+type webhookSink struct {
+	sinkFilter
+	url              string
+	secret           string
+	maxRetries       int
+	retryInterval    time.Duration
+	maxRetryInterval time.Duration
+	client           *http.Client
+	queue            chan *sinkMessage
+}
+
+func newWebhookSink(cfg config.WebhookConfig, queueSize int) *webhookSink {
+	w := &webhookSink{
+		sinkFilter:       newSinkFilter(cfg.Filter),
+		url:              cfg.URL,
+		secret:           cfg.Secret,
+		maxRetries:       cfg.MaxRetries,
+		retryInterval:    time.Duration(cfg.RetryInterval) * time.Second,
+		maxRetryInterval: time.Duration(cfg.MaxRetryInterval) * time.Second,
+		queue:            make(chan *sinkMessage, queueSize),
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = WEBHOOK_DEFAULT_TIMEOUT
+	}
+	w.client = &http.Client{Timeout: time.Duration(timeout) * time.Second}
+	if w.maxRetries <= 0 {
+		w.maxRetries = WEBHOOK_DEFAULT_MAX_RETRIES
+	}
+	if w.retryInterval <= 0 {
+		w.retryInterval = WEBHOOK_DEFAULT_RETRY_INTERVAL * time.Second
+	}
+	if w.maxRetryInterval <= 0 {
+		w.maxRetryInterval = WEBHOOK_DEFAULT_MAX_RETRY_INTERVAL * time.Second
+	}
+	return w
+}
+
+func (w *webhookSink) name() string {
+	return "webhook " + w.url
+}
+
+func (w *webhookSink) put(msg *sinkMessage) bool {
+	select {
+	case w.queue <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+func (w *webhookSink) run() {
+	for msg := range w.queue {
+		w.send(msg)
+	}
+}
+
+// send posts the message, and retries with exponential backoff until it succeeds or the retries are exhausted
+func (w *webhookSink) send(msg *sinkMessage) {
+	interval := w.retryInterval
+	for i := 0; ; i++ {
+		err := w.post(msg)
+		if err == nil {
+			return
+		}
+		if i >= w.maxRetries {
+			log.Errorf("push %s event (lcuuid: %s) to webhook %s failed after %d retries: %s", msg.resourceType, msg.resourceLcuuid, w.url, i, err.Error())
+			return
+		}
+		log.Warningf("push %s event (lcuuid: %s) to webhook %s failed: %s, retry after %s", msg.resourceType, msg.resourceLcuuid, w.url, err.Error(), interval)
+		time.Sleep(interval)
+		interval *= 2
+		if interval > w.maxRetryInterval {
+			interval = w.maxRetryInterval
+		}
+	}
+}
+
+func (w *webhookSink) post(msg *sinkMessage) error {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(msg.body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SINK_HEADER_EVENT_TYPE, msg.eventType)
+	if w.secret != "" {
+		req.Header.Set(SINK_HEADER_SIGNATURE, SINK_SIGNATURE_PREFIX+signSinkBody(w.secret, msg.body))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// signSinkBody returns the hex encoded HMAC-SHA256 of the body, receivers verify the request by calculating it
+// with the shared secret and comparing it with the X-DeepFlow-Signature header
+func signSinkBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type kafkaSink struct {
+	sinkFilter
+	topic    string
+	producer sarama.SyncProducer
+	queue    chan *sinkMessage
+}
+
+func newKafkaSink(cfg config.KafkaConfig, queueSize int) (*kafkaSink, error) {
+	if len(cfg.Brokers) == 0 || cfg.Topic == "" {
+		return nil, fmt.Errorf("kafka brokers and topic are required")
+	}
+	saramaCfg := sarama.NewConfig()
+	saramaCfg.Producer.RequiredAcks = sarama.WaitForAll
+	saramaCfg.Producer.Retry.Max = WEBHOOK_DEFAULT_MAX_RETRIES
+	saramaCfg.Producer.Retry.BackoffFunc = func(retries, maxRetries int) time.Duration {
+		return time.Duration(WEBHOOK_DEFAULT_RETRY_INTERVAL<<retries) * time.Second
+	}
+	saramaCfg.Producer.Return.Successes = true
+	producer, err := sarama.NewSyncProducer(cfg.Brokers, saramaCfg)
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaSink{
+		sinkFilter: newSinkFilter(cfg.Filter),
+		topic:      cfg.Topic,
+		producer:   producer,
+		queue:      make(chan *sinkMessage, queueSize),
+	}, nil
+}
+
+func (k *kafkaSink) name() string {
+	return "kafka " + k.topic
+}
+
+func (k *kafkaSink) put(msg *sinkMessage) bool {
+	select {
+	case k.queue <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+func (k *kafkaSink) run() {
+	for msg := range k.queue {
+		_, _, err := k.producer.SendMessage(&sarama.ProducerMessage{
+			Topic: k.topic,
+			Key:   sarama.StringEncoder(msg.resourceLcuuid),
+			Value: sarama.ByteEncoder(msg.body),
+		})
+		if err != nil {
+			log.Errorf("push %s event (lcuuid: %s) to kafka topic %s failed: %s", msg.resourceType, msg.resourceLcuuid, k.topic, err.Error())
+		}
+	}
+}
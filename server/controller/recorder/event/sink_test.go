@@ -0,0 +1,105 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package event
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/deepflowio/deepflow/server/controller/recorder/event/config"
+)
+
+func TestSinkFilter_match(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter config.SinkFilter
+		msg    *sinkMessage
+		want   bool
+	}{
+		{
+			name: "empty filter",
+			msg:  &sinkMessage{resourceType: "vm", domain: "d1"},
+			want: true,
+		},
+		{
+			name:   "resource type matched",
+			filter: config.SinkFilter{ResourceTypes: []string{"pod", "vm"}},
+			msg:    &sinkMessage{resourceType: "vm", domain: "d1"},
+			want:   true,
+		},
+		{
+			name:   "resource type not matched",
+			filter: config.SinkFilter{ResourceTypes: []string{"pod"}},
+			msg:    &sinkMessage{resourceType: "vm", domain: "d1"},
+			want:   false,
+		},
+		{
+			name:   "domain not matched",
+			filter: config.SinkFilter{ResourceTypes: []string{"vm"}, Domains: []string{"d2"}},
+			msg:    &sinkMessage{resourceType: "vm", domain: "d1"},
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newSinkFilter(tt.filter).match(tt.msg); got != tt.want {
+				t.Errorf("match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookSink_send(t *testing.T) {
+	body, _ := json.Marshal(SinkEvent{ResourceType: "vm", ResourceLcuuid: "vm-1"})
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		received, _ := io.ReadAll(r.Body)
+		if string(received) != string(body) {
+			t.Errorf("body = %s, want %s", received, body)
+		}
+		if got, want := r.Header.Get(SINK_HEADER_SIGNATURE), SINK_SIGNATURE_PREFIX+signSinkBody("secret", body); got != want {
+			t.Errorf("signature = %s, want %s", got, want)
+		}
+		if got := r.Header.Get(SINK_HEADER_EVENT_TYPE); got != "create" {
+			t.Errorf("event type = %s, want create", got)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	w := newWebhookSink(config.WebhookConfig{URL: server.URL, Secret: "secret", MaxRetries: 3}, 1)
+	w.retryInterval = 0
+	w.send(&sinkMessage{resourceType: "vm", resourceLcuuid: "vm-1", eventType: "create", body: body})
+	if got := atomic.LoadInt32(&requests); got != 3 {
+		t.Errorf("requests = %d, want 3", got)
+	}
+
+	atomic.StoreInt32(&requests, -10)
+	w.maxRetries = 2
+	w.send(&sinkMessage{resourceType: "vm", resourceLcuuid: "vm-1", eventType: "create", body: body})
+	if got := atomic.LoadInt32(&requests); got != -7 {
+		t.Errorf("requests = %d, want -7", got)
+	}
+}
@@ -45,6 +45,7 @@ func GetSubscriberManager() *SubscriberManager {
 func (c *SubscriberManager) Start(cfg config.Config, q *queue.OverwriteQueue) (err error) {
 	log.Info("resource event subscriber manager started")
 	c.cfg = cfg
+	GetSink().Start(cfg.Sink)
 	c.subscribers = c.getSubscribers(q)
 	for _, subscriber := range c.subscribers {
 		subscriber.Subscribe()
@@ -0,0 +1,303 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package history
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"github.com/deepflowio/deepflow/server/controller/db/metadb"
+	metadbmodel "github.com/deepflowio/deepflow/server/controller/db/metadb/model"
+	"github.com/deepflowio/deepflow/server/controller/recorder/config"
+	"github.com/deepflowio/deepflow/server/controller/recorder/pubsub"
+	"github.com/deepflowio/deepflow/server/controller/recorder/pubsub/message"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+	"github.com/deepflowio/deepflow/server/libs/queue"
+)
+
+var log = logger.MustGetLogger("recorder.history")
+
+const (
+	OPERATION_ADD    = "add"
+	OPERATION_UPDATE = "update"
+	OPERATION_DELETE = "delete"
+)
+
+// the context lines of the unified diff of the multi-line text fields, such as the metadata and spec of pod groups
+const TEXT_DIFF_CONTEXT = 3
+
+// the records of the messages are queued and written by a goroutine in batches, the oldest ones are overwritten if
+// the queue is full
+const (
+	QUEUE_SIZE           = 1 << 12
+	QUEUE_BATCH_COUNT    = 1 << 8
+	QUEUE_FLUSH_INTERVAL = time.Second
+)
+
+// FieldChange is the value of a field before and after the update. The multi-line text fields are recorded as
+// the unified diff of the values instead of the values.
+type FieldChange struct {
+	Field string      `json:"FIELD"`
+	Old   interface{} `json:"OLD"`
+	New   interface{} `json:"NEW"`
+	Diff  string      `json:"DIFF,omitempty"`
+}
+
+type fieldDetail interface {
+	IsDifferent() bool
+	GetOldValue() interface{}
+	GetNewValue() interface{}
+}
+
+var (
+	subscriberManagerOnce sync.Once
+	subscriberManager     *SubscriberManager
+)
+
+// SubscriberManager subscribes the add, update and delete messages of all the resources published by the
+// recorder updaters, and stores them into resource_history. Only the updates carry the field changes, the adds
+// and deletes record the resource only.
+type SubscriberManager struct {
+	cfg         config.ResourceHistoryConfig
+	batchSize   int
+	queue       *queue.OverwriteQueue
+	subscribers []*Subscriber
+}
+
+// records are the resource_history records of a message
+type records struct {
+	db    *metadb.DB
+	items []*metadbmodel.ResourceHistory
+}
+
+func GetSubscriberManager() *SubscriberManager {
+	subscriberManagerOnce.Do(func() {
+		subscriberManager = &SubscriberManager{}
+	})
+	return subscriberManager
+}
+
+func (m *SubscriberManager) Start(cfg config.RecorderConfig) {
+	m.cfg = cfg.ResourceHistory
+	if !m.cfg.Enabled {
+		log.Info("resource history is disabled")
+		return
+	}
+	m.batchSize = cfg.MySQLBatchSize
+	m.queue = queue.NewOverwriteQueue("recorder-resource_history", QUEUE_SIZE, queue.OptionFlushIndicator(QUEUE_FLUSH_INTERVAL))
+	go m.run()
+	for pubSubType, ps := range pubsub.GetManager().TypeToPubSub {
+		if _, ok := ps.(pubsub.AnyChangePubSub); ok {
+			continue
+		}
+		if len(m.cfg.ResourceTypes) != 0 && !slices.Contains(m.cfg.ResourceTypes, pubSubType) {
+			continue
+		}
+		subscriber := newSubscriber(pubSubType, m.queue)
+		subscriber.Subscribe()
+		m.subscribers = append(m.subscribers, subscriber)
+	}
+	log.Infof("resource history subscriber manager started, %d resource types subscribed", len(m.subscribers))
+}
+
+// run writes the queued records of each org in batches
+func (m *SubscriberManager) run() {
+	buffer := make([]interface{}, QUEUE_BATCH_COUNT)
+	for {
+		n := m.queue.Gets(buffer)
+		dbToItems := make(map[*metadb.DB][]*metadbmodel.ResourceHistory)
+		for i := 0; i < n; i++ {
+			// nil is the flush indicator
+			if r, ok := buffer[i].(*records); ok {
+				dbToItems[r.db] = append(dbToItems[r.db], r.items...)
+			}
+			buffer[i] = nil
+		}
+		for db, items := range dbToItems {
+			if err := db.CreateInBatches(items, m.batchSize).Error; err != nil {
+				log.Errorf("add resource_history (count: %d) failed: %s", len(items), err.Error(), db.LogPrefixORGID)
+				continue
+			}
+			log.Debugf("add resource_history (count: %d) success", len(items), db.LogPrefixORGID)
+		}
+	}
+}
+
+type Subscriber struct {
+	resourceType string
+	queue        *queue.OverwriteQueue
+}
+
+func newSubscriber(resourceType string, q *queue.OverwriteQueue) *Subscriber {
+	return &Subscriber{resourceType: resourceType, queue: q}
+}
+
+func (s *Subscriber) Subscribe() {
+	pubsub.Subscribe(s.resourceType, pubsub.TopicResourceBatchAddedMySQL, s)
+	pubsub.Subscribe(s.resourceType, pubsub.TopicResourceUpdatedMessage, s)
+	pubsub.Subscribe(s.resourceType, pubsub.TopicResourceBatchDeletedMySQL, s)
+}
+
+// OnResourceBatchAdded msg is the slice of added metadb items
+func (s *Subscriber) OnResourceBatchAdded(md *message.Metadata, msg interface{}) {
+	s.save(md, s.generateBatch(md, OPERATION_ADD, msg))
+}
+
+// OnResourceUpdated msg is the update message which carries the field details and the new metadb item
+func (s *Subscriber) OnResourceUpdated(md *message.Metadata, msg interface{}) {
+	updateMsg, ok := msg.(interface {
+		GetFields() interface{}
+		GetNewMySQLItem() interface{}
+	})
+	if !ok {
+		log.Errorf("%s update message (detail: %#v) is not supported", s.resourceType, msg, md.LogPrefixes)
+		return
+	}
+	changes := GetUpdatedFieldChanges(updateMsg.GetFields())
+	if len(changes) == 0 {
+		return
+	}
+	item := s.generate(md, OPERATION_UPDATE, reflect.ValueOf(updateMsg.GetNewMySQLItem()), changes)
+	if item == nil {
+		return
+	}
+	if fields, ok := updateMsg.GetFields().(interface{ GetLcuuid() string }); ok && item.ResourceLcuuid == "" {
+		item.ResourceLcuuid = fields.GetLcuuid()
+	}
+	s.save(md, []*metadbmodel.ResourceHistory{item})
+}
+
+// OnResourceBatchDeleted msg is the slice of deleted metadb items
+func (s *Subscriber) OnResourceBatchDeleted(md *message.Metadata, msg interface{}) {
+	s.save(md, s.generateBatch(md, OPERATION_DELETE, msg))
+}
+
+func (s *Subscriber) generateBatch(md *message.Metadata, operation string, dbItems interface{}) []*metadbmodel.ResourceHistory {
+	v := reflect.ValueOf(dbItems)
+	if v.Kind() != reflect.Slice {
+		log.Errorf("%s %s message (detail: %#v) is not a slice", s.resourceType, operation, dbItems, md.LogPrefixes)
+		return nil
+	}
+	items := make([]*metadbmodel.ResourceHistory, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		if item := s.generate(md, operation, v.Index(i), nil); item != nil {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (s *Subscriber) generate(md *message.Metadata, operation string, dbItem reflect.Value, changes []FieldChange) *metadbmodel.ResourceHistory {
+	item := &metadbmodel.ResourceHistory{
+		Domain:       md.GetDomainLcuuid(),
+		SubDomain:    md.GetSubDomainLcuuid(),
+		ResourceType: s.resourceType,
+		Operation:    operation,
+	}
+	if len(changes) != 0 {
+		diff, err := json.Marshal(changes)
+		if err != nil {
+			log.Errorf("json marshal %s %s changes failed: %s", s.resourceType, operation, err.Error(), md.LogPrefixes)
+			return nil
+		}
+		item.Diff = string(diff)
+	}
+	for dbItem.Kind() == reflect.Ptr || dbItem.Kind() == reflect.Interface {
+		if dbItem.IsNil() {
+			return item
+		}
+		dbItem = dbItem.Elem()
+	}
+	if dbItem.Kind() != reflect.Struct {
+		return item
+	}
+	if f := dbItem.FieldByName("ID"); f.IsValid() && f.Kind() == reflect.Int {
+		item.ResourceID = int(f.Int())
+	}
+	if f := dbItem.FieldByName("Lcuuid"); f.IsValid() && f.Kind() == reflect.String {
+		item.ResourceLcuuid = f.String()
+	}
+	if f := dbItem.FieldByName("Name"); f.IsValid() && f.Kind() == reflect.String {
+		item.ResourceName = f.String()
+	}
+	return item
+}
+
+// save queues the records, which are written by SubscriberManager.run, the updaters are not blocked by the writes
+func (s *Subscriber) save(md *message.Metadata, items []*metadbmodel.ResourceHistory) {
+	if len(items) == 0 {
+		return
+	}
+	if err := s.queue.Put(&records{db: md.GetDB(), items: items}); err != nil {
+		log.Errorf("queue %s resource_history (count: %d) failed: %s", s.resourceType, len(items), err.Error(), md.LogPrefixes)
+	}
+}
+
+// GetUpdatedFieldChanges returns the changes of the different fields in the fields update message, such as
+// *message.VMFieldsUpdate
+func GetUpdatedFieldChanges(fields interface{}) []FieldChange {
+	v := reflect.ValueOf(fields)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct || !v.CanAddr() {
+		return nil
+	}
+	var changes []FieldChange
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if !t.Field(i).IsExported() {
+			continue
+		}
+		detail, ok := v.Field(i).Addr().Interface().(fieldDetail)
+		if !ok || !detail.IsDifferent() {
+			continue
+		}
+		changes = append(changes, newFieldChange(t.Field(i).Name, detail.GetOldValue(), detail.GetNewValue()))
+	}
+	return changes
+}
+
+// newFieldChange returns the change of the field, the unified diff is recorded if the value is multi-line text
+func newFieldChange(field string, old, new interface{}) FieldChange {
+	oldText, oldOK := old.(string)
+	newText, newOK := new.(string)
+	if !oldOK || !newOK || (!strings.Contains(oldText, "\n") && !strings.Contains(newText, "\n")) {
+		return FieldChange{Field: field, Old: old, New: new}
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(oldText),
+		B:        difflib.SplitLines(newText),
+		FromFile: "old",
+		ToFile:   "new",
+		Context:  TEXT_DIFF_CONTEXT,
+	})
+	if err != nil {
+		log.Errorf("compare field %s failed: %s", field, err.Error())
+		return FieldChange{Field: field, Old: old, New: new}
+	}
+	return FieldChange{Field: field, Diff: diff}
+}
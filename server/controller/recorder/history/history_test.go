@@ -0,0 +1,98 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package history
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	metadbmodel "github.com/deepflowio/deepflow/server/controller/db/metadb/model"
+	"github.com/deepflowio/deepflow/server/controller/recorder/pubsub/message"
+)
+
+func TestGetUpdatedFieldChanges(t *testing.T) {
+	fields := &message.PodFieldsUpdate{}
+	fields.SetLcuuid("pod-1")
+	fields.State.Set(1, 2)
+	fields.PodNodeID.SetNew(3)
+
+	want := []FieldChange{
+		{Field: "PodNodeID", Old: 0, New: 3},
+		{Field: "State", Old: 1, New: 2},
+	}
+	got := GetUpdatedFieldChanges(fields)
+	gotByField := make(map[string]FieldChange)
+	for _, c := range got {
+		gotByField[c.Field] = c
+	}
+	if len(got) != len(want) {
+		t.Fatalf("GetUpdatedFieldChanges() = %#v, want %#v", got, want)
+	}
+	for _, w := range want {
+		if !reflect.DeepEqual(gotByField[w.Field], w) {
+			t.Errorf("GetUpdatedFieldChanges() %s = %#v, want %#v", w.Field, gotByField[w.Field], w)
+		}
+	}
+}
+
+func TestGetUpdatedTextFieldChanges(t *testing.T) {
+	fields := &message.PodGroupFieldsUpdate{}
+	fields.Metadata.Set("name: web\nlabels:\n  app: web\n", "name: web\nlabels:\n  app: shop\n")
+	fields.Label.Set("app:web", "app:shop")
+
+	gotByField := make(map[string]FieldChange)
+	for _, c := range GetUpdatedFieldChanges(fields) {
+		gotByField[c.Field] = c
+	}
+	metadata := gotByField["Metadata"]
+	if metadata.Old != nil || metadata.New != nil {
+		t.Errorf("GetUpdatedFieldChanges() Metadata = %#v, want diff only", metadata)
+	}
+	if !strings.Contains(metadata.Diff, "-  app: web\n+  app: shop\n") {
+		t.Errorf("GetUpdatedFieldChanges() Metadata diff = %q", metadata.Diff)
+	}
+	if label := gotByField["Label"]; label.Old != "app:web" || label.New != "app:shop" || label.Diff != "" {
+		t.Errorf("GetUpdatedFieldChanges() Label = %#v, want old and new values", label)
+	}
+}
+
+func TestGenerateBatch(t *testing.T) {
+	s := newSubscriber("pod_namespace", nil)
+	md := message.NewMetadata(1, message.MetadataDomainLcuuid("domain-1"))
+	dbItem := metadbmodel.PodNamespace{Name: "ns"}
+	dbItem.ID = 1
+	dbItem.Lcuuid = "ns-1"
+
+	for _, operation := range []string{OPERATION_ADD, OPERATION_DELETE} {
+		items := s.generateBatch(md, operation, []*metadbmodel.PodNamespace{&dbItem})
+		if len(items) != 1 {
+			t.Fatalf("generateBatch() = %#v, want 1 item", items)
+		}
+		want := &metadbmodel.ResourceHistory{
+			Domain:         "domain-1",
+			ResourceType:   "pod_namespace",
+			ResourceID:     1,
+			ResourceLcuuid: "ns-1",
+			ResourceName:   "ns",
+			Operation:      operation,
+		}
+		if !reflect.DeepEqual(items[0], want) {
+			t.Errorf("generateBatch() %s = %#v, want %#v", operation, items[0], want)
+		}
+	}
+}
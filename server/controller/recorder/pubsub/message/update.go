@@ -97,6 +97,15 @@ func (d *fieldDetail[T]) SetOld(old T) {
 	d.old = old
 }
 
+// GetOldValue and GetNewValue return the values without type parameter, used to record the field changes of any resource
+func (d *fieldDetail[T]) GetOldValue() interface{} {
+	return d.old
+}
+
+func (d *fieldDetail[T]) GetNewValue() interface{} {
+	return d.new
+}
+
 // TODO rename to metadb
 type MySQLData[MT constraint.MySQLModel] struct {
 	new *MT
@@ -670,6 +679,7 @@ type PodGroupFieldsUpdate struct {
 	Name           fieldDetail[string]
 	Label          fieldDetail[string]
 	Type           fieldDetail[int]
+	OwnerKind      fieldDetail[string]
 	PodNum         fieldDetail[int]
 	Metadata       fieldDetail[string]
 	Spec           fieldDetail[string]
@@ -110,6 +110,7 @@ func (p *PodGroup) generateDBItemToAdd(cloudItem *cloudmodel.PodGroup) (*metadbm
 	dbItem := &metadbmodel.PodGroup{
 		Name:           cloudItem.Name,
 		Type:           cloudItem.Type,
+		OwnerKind:      cloudItem.OwnerKind,
 		Label:          cloudItem.Label,
 		Metadata:       string(yamlMetadata),
 		MetadataHash:   cloudItem.MetadataHash,
@@ -139,6 +140,10 @@ func (p *PodGroup) generateUpdateInfo(diffBase *diffbase.PodGroup, cloudItem *cl
 		mapInfo["type"] = cloudItem.Type
 		structInfo.Type.Set(diffBase.Type, cloudItem.Type)
 	}
+	if diffBase.OwnerKind != cloudItem.OwnerKind {
+		mapInfo["owner_kind"] = cloudItem.OwnerKind
+		structInfo.OwnerKind.Set(diffBase.OwnerKind, cloudItem.OwnerKind)
+	}
 	if diffBase.PodNum != cloudItem.PodNum {
 		mapInfo["pod_num"] = cloudItem.PodNum
 		structInfo.PodNum.Set(diffBase.PodNum, cloudItem.PodNum)
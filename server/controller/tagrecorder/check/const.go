@@ -105,6 +105,10 @@ const (
 	RESOURCE_TYPE_CH_POD_GROUP_DAEMON_SET            = "pod_group_daemon_set"
 	RESOURCE_TYPE_CH_POD_GROUP_REPLICASET_CONTROLLER = "pod_group_replicaset_controller"
 	RESOURCE_TYPE_CH_POD_GROUP_CLONESET              = "pod_group_cloneset"
+	RESOURCE_TYPE_CH_POD_GROUP_JOB                   = "pod_group_job"
+	RESOURCE_TYPE_CH_POD_GROUP_CRON_JOB              = "pod_group_cron_job"
+	RESOURCE_TYPE_CH_POD_GROUP_ROLLOUT               = "pod_group_rollout"
+	RESOURCE_TYPE_CH_POD_GROUP_CUSTOM                = "pod_group_custom"
 
 	RESOURCE_TYPE_CH_PROMETHEUS_METRIC_APP_LABEL_LAYOUT = "ch_promytheus_metric_app_label_layout"
 	RESOURCE_TYPE_CH_TARGET_LABEL                       = "ch_target_label"
@@ -835,6 +839,10 @@ var RESOURCE_TYPE_TO_NODE_TYPE = map[int]string{
 	common.VIF_DEVICE_TYPE_POD_GROUP_DAEMON_SET:            RESOURCE_TYPE_POD_GROUP,
 	common.VIF_DEVICE_TYPE_POD_GROUP_REPLICASET_CONTROLLER: RESOURCE_TYPE_POD_GROUP,
 	common.VIF_DEVICE_TYPE_POD_GROUP_CLONESET:              RESOURCE_TYPE_POD_GROUP,
+	common.VIF_DEVICE_TYPE_POD_GROUP_JOB:                   RESOURCE_TYPE_POD_GROUP,
+	common.VIF_DEVICE_TYPE_POD_GROUP_CRON_JOB:              RESOURCE_TYPE_POD_GROUP,
+	common.VIF_DEVICE_TYPE_POD_GROUP_ROLLOUT:               RESOURCE_TYPE_POD_GROUP,
+	common.VIF_DEVICE_TYPE_POD_GROUP_CUSTOM:                RESOURCE_TYPE_POD_GROUP,
 	common.VIF_DEVICE_TYPE_IP:                              RESOURCE_TYPE_IP,
 	common.VIF_DEVICE_TYPE_CUSTOM_SERVICE:                  RESOURCE_TYPE_CUSTOM_SERVICE,
 }
@@ -846,4 +854,8 @@ var RESOURCE_POD_GROUP_TYPE_MAP = map[int]int{
 	common.POD_GROUP_DAEMON_SET:            common.VIF_DEVICE_TYPE_POD_GROUP_DAEMON_SET,
 	common.POD_GROUP_REPLICASET_CONTROLLER: common.VIF_DEVICE_TYPE_POD_GROUP_REPLICASET_CONTROLLER,
 	common.POD_GROUP_CLONESET:              common.VIF_DEVICE_TYPE_POD_GROUP_CLONESET,
+	common.POD_GROUP_JOB:                   common.VIF_DEVICE_TYPE_POD_GROUP_JOB,
+	common.POD_GROUP_CRON_JOB:              common.VIF_DEVICE_TYPE_POD_GROUP_CRON_JOB,
+	common.POD_GROUP_ROLLOUT:               common.VIF_DEVICE_TYPE_POD_GROUP_ROLLOUT,
+	common.POD_GROUP_CUSTOM:                common.VIF_DEVICE_TYPE_POD_GROUP_CUSTOM,
 }
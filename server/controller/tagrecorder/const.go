@@ -695,6 +695,10 @@ var RESOURCE_TYPE_TO_NODE_TYPE = map[int]string{
 	common.VIF_DEVICE_TYPE_POD_GROUP_DAEMON_SET:            RESOURCE_TYPE_POD_GROUP,
 	common.VIF_DEVICE_TYPE_POD_GROUP_REPLICASET_CONTROLLER: RESOURCE_TYPE_POD_GROUP,
 	common.VIF_DEVICE_TYPE_POD_GROUP_CLONESET:              RESOURCE_TYPE_POD_GROUP,
+	common.VIF_DEVICE_TYPE_POD_GROUP_JOB:                   RESOURCE_TYPE_POD_GROUP,
+	common.VIF_DEVICE_TYPE_POD_GROUP_CRON_JOB:              RESOURCE_TYPE_POD_GROUP,
+	common.VIF_DEVICE_TYPE_POD_GROUP_ROLLOUT:               RESOURCE_TYPE_POD_GROUP,
+	common.VIF_DEVICE_TYPE_POD_GROUP_CUSTOM:                RESOURCE_TYPE_POD_GROUP,
 	common.VIF_DEVICE_TYPE_IP:                              RESOURCE_TYPE_IP,
 	common.VIF_DEVICE_TYPE_CUSTOM_SERVICE:                  RESOURCE_TYPE_CUSTOM_SERVICE,
 }
@@ -706,6 +710,10 @@ var RESOURCE_POD_GROUP_TYPE_MAP = map[int]int{
 	common.POD_GROUP_DAEMON_SET:            common.VIF_DEVICE_TYPE_POD_GROUP_DAEMON_SET,
 	common.POD_GROUP_REPLICASET_CONTROLLER: common.VIF_DEVICE_TYPE_POD_GROUP_REPLICASET_CONTROLLER,
 	common.POD_GROUP_CLONESET:              common.VIF_DEVICE_TYPE_POD_GROUP_CLONESET,
+	common.POD_GROUP_JOB:                   common.VIF_DEVICE_TYPE_POD_GROUP_JOB,
+	common.POD_GROUP_CRON_JOB:              common.VIF_DEVICE_TYPE_POD_GROUP_CRON_JOB,
+	common.POD_GROUP_ROLLOUT:               common.VIF_DEVICE_TYPE_POD_GROUP_ROLLOUT,
+	common.POD_GROUP_CUSTOM:                common.VIF_DEVICE_TYPE_POD_GROUP_CUSTOM,
 }
 
 const TrisolarisNodeTypeMaster = "master"
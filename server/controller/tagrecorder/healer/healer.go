@@ -105,6 +105,10 @@ func NewHealers(md *recorderCommon.MetadataBase) *Healers {
 				common.VIF_DEVICE_TYPE_POD_GROUP_REPLICASET_CONTROLLER,
 				common.VIF_DEVICE_TYPE_POD_GROUP_DEPLOYMENT,
 				common.VIF_DEVICE_TYPE_POD_GROUP_STATEFULSET,
+				common.VIF_DEVICE_TYPE_POD_GROUP_JOB,
+				common.VIF_DEVICE_TYPE_POD_GROUP_CRON_JOB,
+				common.VIF_DEVICE_TYPE_POD_GROUP_ROLLOUT,
+				common.VIF_DEVICE_TYPE_POD_GROUP_CUSTOM,
 			),
 		),
 		newHealer[metadbModel.Process, metadbModel.ChDevice, *message.ProcessAdd, message.ProcessAdd](
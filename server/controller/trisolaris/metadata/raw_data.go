@@ -38,6 +38,10 @@ var PodGroupTypeMap = map[int]uint32{
 	POD_GROUP_DAEMON_SET:            uint32(trident.AutoServiceType_AUTO_SERVICE_TYPE_POD_GROUP_DAEMON_SET),
 	POD_GROUP_REPLICASET_CONTROLLER: uint32(trident.AutoServiceType_AUTO_SERVICE_TYPE_POD_GROUP_REPLICASET_CONTROLLER),
 	POD_GROUP_CLONESET:              uint32(trident.AutoServiceType_AUTO_SERVICE_TYPE_POD_GROUP_CLONESET),
+	POD_GROUP_JOB:                   uint32(trident.AutoServiceType_AUTO_SERVICE_TYPE_POD_GROUP_JOB),
+	POD_GROUP_CRON_JOB:              uint32(trident.AutoServiceType_AUTO_SERVICE_TYPE_POD_GROUP_CRON_JOB),
+	POD_GROUP_ROLLOUT:               uint32(trident.AutoServiceType_AUTO_SERVICE_TYPE_POD_GROUP_ROLLOUT),
+	POD_GROUP_CUSTOM:                uint32(trident.AutoServiceType_AUTO_SERVICE_TYPE_POD_GROUP_CUSTOM),
 }
 
 type TypeIDData struct {
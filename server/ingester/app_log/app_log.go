@@ -24,6 +24,7 @@ import (
 	"github.com/deepflowio/deepflow/server/ingester/app_log/dbwriter"
 	"github.com/deepflowio/deepflow/server/ingester/app_log/decoder"
 	dropletqueue "github.com/deepflowio/deepflow/server/ingester/droplet/queue"
+	"github.com/deepflowio/deepflow/server/ingester/exporters"
 	"github.com/deepflowio/deepflow/server/ingester/ingesterctl"
 	"github.com/deepflowio/deepflow/server/ingester/pkg/ckwriter"
 	"github.com/deepflowio/deepflow/server/libs/datatype"
@@ -51,6 +52,7 @@ func NewApplicationLogger(
 	config *config.Config,
 	recv *receiver.Receiver,
 	platformDataManager *grpc.PlatformDataManager,
+	exporters *exporters.Exporters,
 ) (*ApplicationLogger, error) {
 	manager := dropletqueue.NewManager(ingesterctl.INGESTERCTL_APPLICATION_LOG_QUEUE)
 
@@ -58,15 +60,16 @@ func NewApplicationLogger(
 	if err != nil {
 		return nil, err
 	}
-	sysLogger, err := NewLogger(datatype.MESSAGE_TYPE_SYSLOG, config, manager, recv, platformDataManager, ckwriter)
+	// all the loggers export to 'application_log.log', each decoder uses its own exporter cache
+	sysLogger, err := NewLogger(datatype.MESSAGE_TYPE_SYSLOG, config, manager, recv, platformDataManager, ckwriter, exporters, 0)
 	if err != nil {
 		return nil, err
 	}
-	agentLogger, err := NewLogger(datatype.MESSAGE_TYPE_AGENT_LOG, config, manager, recv, platformDataManager, ckwriter)
+	agentLogger, err := NewLogger(datatype.MESSAGE_TYPE_AGENT_LOG, config, manager, recv, platformDataManager, ckwriter, exporters, config.DecoderQueueCount)
 	if err != nil {
 		return nil, err
 	}
-	appLogger, err := NewLogger(datatype.MESSAGE_TYPE_APPLICATION_LOG, config, manager, recv, platformDataManager, ckwriter)
+	appLogger, err := NewLogger(datatype.MESSAGE_TYPE_APPLICATION_LOG, config, manager, recv, platformDataManager, ckwriter, exporters, 2*config.DecoderQueueCount)
 	if err != nil {
 		return nil, err
 	}
@@ -102,6 +105,8 @@ func NewLogger(
 	recv *receiver.Receiver,
 	platformDataManager *grpc.PlatformDataManager,
 	ckwriter *ckwriter.CKWriter,
+	exporters *exporters.Exporters,
+	exporterIndexBase int,
 ) (*Logger, error) {
 
 	queueCount := config.DecoderQueueCount
@@ -131,6 +136,8 @@ func NewLogger(
 			queue.QueueReader(decodeQueues.FixedMultiQueue[i]),
 			logWriter,
 			platformDatas[i],
+			exporters,
+			exporterIndexBase+i,
 			config,
 		)
 	}
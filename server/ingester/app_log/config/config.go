@@ -20,6 +20,7 @@ import (
 	"os"
 
 	"github.com/deepflowio/deepflow/server/ingester/config"
+	"github.com/deepflowio/deepflow/server/libs/queue"
 
 	logging "github.com/op/go-logging"
 	yaml "gopkg.in/yaml.v2"
@@ -49,6 +50,10 @@ func (c *Config) Validate() error {
 	if c.DecoderQueueCount == 0 {
 		c.DecoderQueueCount = DefaultDecoderQueueCount
 	}
+	// the decoders of syslog, agent log and application log share the exporter caches of 'application_log.log'
+	if c.DecoderQueueCount > queue.MAX_QUEUE_COUNT/3 {
+		c.DecoderQueueCount = queue.MAX_QUEUE_COUNT / 3
+	}
 	if c.DecoderQueueSize == 0 {
 		c.DecoderQueueSize = DefaultDecoderQueueSize
 	}
@@ -17,17 +17,26 @@
 package dbwriter
 
 import (
+	"encoding/hex"
 	"fmt"
 	"net"
+	"reflect"
 	"strings"
 	"sync/atomic"
+	"unsafe"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
 
 	basecommon "github.com/deepflowio/deepflow/server/ingester/common"
+	exportercommon "github.com/deepflowio/deepflow/server/ingester/exporters/common"
 	"github.com/deepflowio/deepflow/server/ingester/exporters/config"
+	utag "github.com/deepflowio/deepflow/server/ingester/exporters/universal_tag"
 	"github.com/deepflowio/deepflow/server/ingester/flow_tag"
 	"github.com/deepflowio/deepflow/server/libs/ckdb"
 	"github.com/deepflowio/deepflow/server/libs/nativetag"
 	"github.com/deepflowio/deepflow/server/libs/pool"
+	"github.com/deepflowio/deepflow/server/libs/utils"
 )
 
 const (
@@ -66,15 +75,15 @@ type ApplicationLogStore struct {
 	Time      uint32 `json:"time" category:"$tag" sub:"flow_info"` // s
 	Timestamp int64  `json:"timestamp" category:"$tag" sub:"flow_info"`
 	_id       uint64 `json:"_id" category:"$tag" sub:"flow_info"`
-	Type      string
+	Type      string `json:"_type" category:"$tag" sub:"flow_info"`
 
-	TraceID    string
-	SpanID     string
-	TraceFlags uint32
+	TraceID    string `json:"trace_id" category:"$tag" sub:"tracing_info"`
+	SpanID     string `json:"span_id" category:"$tag" sub:"tracing_info"`
+	TraceFlags uint32 `json:"trace_flags" category:"$tag" sub:"tracing_info"`
 
-	SeverityNumber uint8 // numerical value of the severity(also known as log level id)
+	SeverityNumber uint8 `json:"severity_number" category:"$tag" sub:"application_layer"` // numerical value of the severity(also known as log level id)
 
-	Body string
+	Body string `json:"body" category:"$tag" sub:"application_layer"`
 
 	AppService string `json:"app_service" category:"$tag" sub:"service_info"` // service name
 
@@ -99,9 +108,9 @@ type ApplicationLogStore struct {
 
 	// Not stored, only determines which database to store in.
 	// When Orgid is 0 or 1, it is stored in database 'event', otherwise stored in '<OrgId>_event'.
-	OrgId  uint16
-	TeamID uint16
-	UserID uint32
+	OrgId  uint16 `json:"org_id" category:"$tag"`
+	TeamID uint16 `json:"team_id" category:"$tag"`
+	UserID uint32 `json:"user_id" category:"$tag"`
 
 	AutoInstanceID   uint32 `json:"auto_instance_id" category:"$tag" sub:"universal_tag"`
 	AutoInstanceType uint8  `json:"auto_instance_type" category:"$tag" sub:"universal_tag" enumfile:"auto_instance_type"`
@@ -132,7 +141,73 @@ func (l *ApplicationLogStore) Release() {
 }
 
 func (l *ApplicationLogStore) DataSource() uint32 {
-	return uint32(config.MAX_DATASOURCE_ID)
+	return uint32(config.APPLICATION_LOG)
+}
+
+func (l *ApplicationLogStore) EncodeTo(protocol config.ExportProtocol, utags *utag.UniversalTagsManager, cfg *config.ExporterCfg) (interface{}, error) {
+	switch protocol {
+	case config.PROTOCOL_KAFKA:
+		tags := l.QueryUniversalTags(utags)
+		k8sLabels := utags.QueryCustomK8sLabels(l.OrgId, l.PodID)
+		return exportercommon.EncodeToJson(l, int(l.DataSource()), cfg, tags, tags, k8sLabels, k8sLabels), nil
+	case config.PROTOCOL_FILE:
+		tags := l.QueryUniversalTags(utags)
+		k8sLabels := utags.QueryCustomK8sLabels(l.OrgId, l.PodID)
+		return exportercommon.EncodeToRow(l, int(l.DataSource()), cfg, tags, tags, k8sLabels, k8sLabels), nil
+	case config.PROTOCOL_OTLP:
+		tags := l.QueryUniversalTags(utags)
+		k8sLabels := utags.QueryCustomK8sLabels(l.OrgId, l.PodID)
+		logs, record := exportercommon.EncodeToOtlpLogs(l, int(l.DataSource()), cfg, tags, k8sLabels)
+		if l.AppService != "" {
+			logs.At(0).Resource().Attributes().PutStr("service.name", l.AppService)
+		}
+		record.Body().SetStr(l.Body)
+		record.SetSeverityNumber(severityToOtlp(l.SeverityNumber))
+		record.SetFlags(plog.LogRecordFlags(l.TraceFlags))
+		if traceID, err := hex.DecodeString(l.TraceID); err == nil && len(traceID) == 16 {
+			record.SetTraceID(pcommon.TraceID(traceID))
+		}
+		if spanID, err := hex.DecodeString(l.SpanID); err == nil && len(spanID) == 8 {
+			record.SetSpanID(pcommon.SpanID(spanID))
+		}
+		return logs, nil
+	default:
+		return nil, fmt.Errorf("application log unsupport export to %s", protocol)
+	}
+}
+
+// the severity number of the log is the same as log/syslog, e.g.: 2 is LOG_CRIT, 7 is LOG_DEBUG used as trace
+var otlpSeverities = [...]plog.SeverityNumber{
+	2: plog.SeverityNumberFatal,
+	3: plog.SeverityNumberError,
+	4: plog.SeverityNumberWarn,
+	5: plog.SeverityNumberInfo,
+	6: plog.SeverityNumberDebug,
+	7: plog.SeverityNumberTrace,
+}
+
+func severityToOtlp(severity uint8) plog.SeverityNumber {
+	if int(severity) < len(otlpSeverities) {
+		return otlpSeverities[severity]
+	}
+	return plog.SeverityNumberUnspecified
+}
+
+func (l *ApplicationLogStore) QueryUniversalTags(utags *utag.UniversalTagsManager) *utag.UniversalTags {
+	return utags.QueryUniversalTags(l.OrgId,
+		l.RegionID, l.AZID, l.HostID, l.PodNSID, l.PodClusterID, l.SubnetID, l.AgentID,
+		l.L3DeviceType, l.AutoServiceType, l.AutoInstanceType,
+		l.L3DeviceID, l.AutoServiceID, l.AutoInstanceID, l.PodNodeID, l.PodGroupID, l.PodID, uint32(l.L3EpcID), l.GProcessID, l.ServiceID,
+		l.IsIPv4, l.IP4, l.IP6,
+	)
+}
+
+func (l *ApplicationLogStore) GetFieldValueByOffsetAndKind(offset uintptr, kind reflect.Kind, dataType utils.DataType) interface{} {
+	return utils.GetValueByOffsetAndKind(uintptr(unsafe.Pointer(l)), offset, kind, dataType)
+}
+
+func (l *ApplicationLogStore) TimestampUs() int64 {
+	return l.Timestamp
 }
 
 var LogCounter uint32
@@ -30,6 +30,9 @@ import (
 	"github.com/deepflowio/deepflow/server/ingester/app_log/config"
 	"github.com/deepflowio/deepflow/server/ingester/app_log/dbwriter"
 	ingestercommon "github.com/deepflowio/deepflow/server/ingester/common"
+	"github.com/deepflowio/deepflow/server/ingester/exporters"
+	exporterscommon "github.com/deepflowio/deepflow/server/ingester/exporters/common"
+	exportersconfig "github.com/deepflowio/deepflow/server/ingester/exporters/config"
 	"github.com/deepflowio/deepflow/server/libs/ckdb"
 	"github.com/deepflowio/deepflow/server/libs/codec"
 	"github.com/deepflowio/deepflow/server/libs/datatype"
@@ -114,7 +117,9 @@ type Decoder struct {
 	msgType           datatype.MessageType
 	platformData      *grpc.PlatformInfoTable
 	inQueue           queue.QueueReader
-	logWriter         *dbwriter.AppLogWriter
+	logWriter         appLogWriter
+	exporters         appLogExporter
+	exporterIndex     int
 	debugEnabled      bool
 	config            *config.Config
 	appLogEntrysCache []AppLogEntry
@@ -124,25 +129,41 @@ type Decoder struct {
 	utils.Closable
 }
 
+type appLogWriter interface {
+	Write(l *dbwriter.ApplicationLogStore)
+}
+
+type appLogExporter interface {
+	Put(dataSourceId uint32, decoderIndex int, item exporterscommon.ExportItem)
+}
+
 func NewDecoder(
 	index int,
 	msgType datatype.MessageType,
 	inQueue queue.QueueReader,
 	logWriter *dbwriter.AppLogWriter,
 	platformData *grpc.PlatformInfoTable,
+	exporters *exporters.Exporters,
+	exporterIndex int,
 	config *config.Config,
 ) *Decoder {
-	return &Decoder{
+	d := &Decoder{
 		index:             index,
 		msgType:           msgType,
 		platformData:      platformData,
 		inQueue:           inQueue,
 		debugEnabled:      log.IsEnabledFor(logging.DEBUG),
 		logWriter:         logWriter,
+		exporterIndex:     exporterIndex,
 		appLogEntrysCache: make([]AppLogEntry, 0),
 		config:            config,
 		counter:           &Counter{},
 	}
+	// avoid storing a typed nil pointer in the interface
+	if exporters != nil {
+		d.exporters = exporters
+	}
+	return d
 }
 
 func (d *Decoder) GetCounter() interface{} {
@@ -151,6 +172,19 @@ func (d *Decoder) GetCounter() interface{} {
 	return counter
 }
 
+func (d *Decoder) export(item exporterscommon.ExportItem) {
+	if d.exporters == nil {
+		return
+	}
+	d.exporters.Put(uint32(exportersconfig.APPLICATION_LOG), d.exporterIndex, item)
+}
+
+// write exports the log once and then hands it to the log writer
+func (d *Decoder) write(s *dbwriter.ApplicationLogStore) {
+	d.export(s)
+	d.logWriter.Write(s)
+}
+
 func (d *Decoder) Run() {
 	log.Infof("application log (%s-%d) decoder run", d.msgType.String(), d.index)
 	ingestercommon.RegisterCountableForIngester("decoder", d, stats.OptionStatTags{
@@ -162,6 +196,7 @@ func (d *Decoder) Run() {
 		n := d.inQueue.Gets(buffer)
 		for i := 0; i < n; i++ {
 			if buffer[i] == nil {
+				d.export(nil)
 				continue
 			}
 			d.counter.InCount++
@@ -301,7 +336,7 @@ func (d *Decoder) WriteAgentLog(agentId uint16, bs []byte) error {
 	s.AttributeNames = append(s.AttributeNames, "module")
 	s.AttributeValues = append(s.AttributeValues, string(columns[4]))
 
-	d.logWriter.Write(s)
+	d.write(s)
 	return nil
 }
 
@@ -449,7 +484,7 @@ func (d *Decoder) WriteAppLog(agentId uint16, l *AppLogEntry) error {
 	customServiceID := d.platformData.QueryCustomService(s.OrgId, s.L3EpcID, !s.IsIPv4, s.IP4, s.IP6, 0)
 	s.AutoServiceID, s.AutoServiceType = ingestercommon.GetAutoService(customServiceID, s.ServiceID, s.PodGroupID, 0, s.PodNodeID, s.L3DeviceID, uint32(s.SubnetID), uint8(s.L3DeviceType), podGroupType, s.L3EpcID)
 
-	d.logWriter.Write(s)
+	d.write(s)
 	return nil
 }
 
@@ -0,0 +1,80 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package decoder
+
+import (
+	"testing"
+
+	"github.com/deepflowio/deepflow/server/ingester/app_log/dbwriter"
+	exporterscommon "github.com/deepflowio/deepflow/server/ingester/exporters/common"
+	exportersconfig "github.com/deepflowio/deepflow/server/ingester/exporters/config"
+	"github.com/deepflowio/deepflow/server/libs/grpc"
+)
+
+type countingExporter struct {
+	count int
+}
+
+func (e *countingExporter) Put(dataSourceId uint32, decoderIndex int, item exporterscommon.ExportItem) {
+	if item == nil || dataSourceId != uint32(exportersconfig.APPLICATION_LOG) {
+		return
+	}
+	e.count++
+}
+
+type countingWriter struct {
+	count int
+}
+
+func (w *countingWriter) Write(l *dbwriter.ApplicationLogStore) {
+	w.count++
+}
+
+func newTestDecoder() (*Decoder, *countingExporter, *countingWriter) {
+	exporter, writer := &countingExporter{}, &countingWriter{}
+	platformData := grpc.NewPlatformInfoTable(nil, 0, 0, 0, "app_log_decoder_test", "", nil, true, nil)
+	d := NewDecoder(0, 0, nil, nil, platformData, nil, 0, nil)
+	d.exporters, d.logWriter = exporter, writer
+	return d, exporter, writer
+}
+
+func TestWriteAgentLogExportOnce(t *testing.T) {
+	d, exporter, writer := newTestDecoder()
+	bs := []byte("2024-04-30T10:26:47.038297752+08:00 mars-1-V3 mars-1[5874]: [ERROR] src/sender/uniform_sender.rs:431 sender tcp connection failed")
+	if err := d.WriteAgentLog(0, bs); err != nil {
+		t.Fatalf("WriteAgentLog failed: %s", err)
+	}
+	if exporter.count != 1 || writer.count != 1 {
+		t.Errorf("agent log exported %d times and written %d times, expected 1 and 1", exporter.count, writer.count)
+	}
+}
+
+func TestWriteAppLogExportOnce(t *testing.T) {
+	d, exporter, writer := newTestDecoder()
+	l := &AppLogEntry{
+		Message:    "GET /api/v1/users 200",
+		Level:      "info",
+		Timestamp:  "2024-04-30T10:26:47+08:00",
+		AppService: "user-service",
+	}
+	if err := d.WriteAppLog(0, l); err != nil {
+		t.Fatalf("WriteAppLog failed: %s", err)
+	}
+	if exporter.count != 1 || writer.count != 1 {
+		t.Errorf("application log exported %d times and written %d times, expected 1 and 1", exporter.count, writer.count)
+	}
+}
@@ -69,6 +69,10 @@ const (
 	//   any endpoints beyond this limit will be ignored
 	MaxClickHouseEndpointsPerServer = 128
 	DefaultDatasourceListenPort     = 20106
+	DefaultCKWriterSpillDir         = "/var/lib/deepflow/ckwriter-spill"
+	DefaultCKWriterSpillMaxSize     = 1024 // MB
+	DefaultCKWriterSpillMaxAge      = 3600 // s
+	DefaultCKWriterReplayInterval   = 10   // s
 )
 
 type DatabaseTable struct {
@@ -139,6 +143,48 @@ type CKWriterConfig struct {
 	FlushTimeout int `yaml:"flush-timeout"`
 }
 
+// CKWriterSpill stores the batches which failed to be written to ClickHouse on the local disk,
+// and writes them again after ClickHouse recovers.
+type CKWriterSpill struct {
+	Enabled        bool     `yaml:"enabled"`
+	Dir            string   `yaml:"dir"`
+	MaxSize        int      `yaml:"max-size"`        // MB, for each table
+	MaxAge         int      `yaml:"max-age"`         // s
+	ReplayInterval int      `yaml:"replay-interval"` // s
+	Tables         []string `yaml:"tables,flow"`     // '<database>.<table>' or '<database>', empty means all tables
+}
+
+func (s *CKWriterSpill) Validate() {
+	if s.Dir == "" {
+		s.Dir = DefaultCKWriterSpillDir
+	}
+	if s.MaxSize <= 0 {
+		s.MaxSize = DefaultCKWriterSpillMaxSize
+	}
+	if s.MaxAge <= 0 {
+		s.MaxAge = DefaultCKWriterSpillMaxAge
+	}
+	if s.ReplayInterval <= 0 {
+		s.ReplayInterval = DefaultCKWriterReplayInterval
+	}
+}
+
+// TableEnabled returns whether the table of the database spills the failed batches
+func (s *CKWriterSpill) TableEnabled(database, table string) bool {
+	if !s.Enabled {
+		return false
+	}
+	if len(s.Tables) == 0 {
+		return true
+	}
+	for _, t := range s.Tables {
+		if t == database || t == database+"."+table {
+			return true
+		}
+	}
+	return false
+}
+
 type CKDB struct {
 	External            bool     `yaml:"external"`
 	Type                string   `yaml:"type"`
@@ -177,6 +223,7 @@ type Config struct {
 	TCPReadBuffer            int             `yaml:"tcp-read-buffer"`
 	TCPReaderBuffer          int             `yaml:"tcp-reader-buffer"`
 	CKDiskMonitor            CKDiskMonitor   `yaml:"ck-disk-monitor"`
+	CKWriterSpill            CKWriterSpill   `yaml:"ckwriter-spill"`
 	ColdStorage              CKDBColdStorage `yaml:"ckdb-cold-storage"`
 	ckdbColdStorages         map[string]*ckdb.ColdStorage
 	NodeIP                   string `yaml:"node-ip"`
@@ -339,6 +386,7 @@ func (c *Config) Validate() error {
 		return nil
 	}
 	c.CKDiskMonitor.Validate()
+	c.CKWriterSpill.Validate()
 
 	if c.CKDB.Type == "" {
 		c.CKDB.Type = ckdb.CKDBTypeClickhouse
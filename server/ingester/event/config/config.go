@@ -20,6 +20,7 @@ import (
 	"os"
 
 	"github.com/deepflowio/deepflow/server/ingester/config"
+	"github.com/deepflowio/deepflow/server/libs/queue"
 
 	logging "github.com/op/go-logging"
 	yaml "gopkg.in/yaml.v2"
@@ -82,6 +83,10 @@ func (c *Config) Validate() error {
 	if c.K8sDecoderQueueCount == 0 {
 		c.K8sDecoderQueueCount = DefaultDecoderQueueCount
 	}
+	// the last exporter cache is used by the resource event decoder
+	if c.K8sDecoderQueueCount >= queue.MAX_QUEUE_COUNT {
+		c.K8sDecoderQueueCount = queue.MAX_QUEUE_COUNT - 1
+	}
 	if c.K8sDecoderQueueSize == 0 {
 		c.K8sDecoderQueueSize = DefaultDecoderQueueSize
 	}
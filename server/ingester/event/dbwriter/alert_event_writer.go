@@ -17,16 +17,23 @@
 package dbwriter
 
 import (
+	"fmt"
+	"reflect"
 	"strconv"
 	"sync/atomic"
+	"unsafe"
 
 	basecommon "github.com/deepflowio/deepflow/server/ingester/common"
 	"github.com/deepflowio/deepflow/server/ingester/event/common"
 	"github.com/deepflowio/deepflow/server/ingester/event/config"
+	exportercommon "github.com/deepflowio/deepflow/server/ingester/exporters/common"
+	exporterconfig "github.com/deepflowio/deepflow/server/ingester/exporters/config"
+	utag "github.com/deepflowio/deepflow/server/ingester/exporters/universal_tag"
 	"github.com/deepflowio/deepflow/server/ingester/flow_tag"
 	"github.com/deepflowio/deepflow/server/ingester/pkg/ckwriter"
 	"github.com/deepflowio/deepflow/server/libs/ckdb"
 	"github.com/deepflowio/deepflow/server/libs/pool"
+	"github.com/deepflowio/deepflow/server/libs/utils"
 )
 
 var alertEventPool = pool.NewLockFreePool(func() *AlertEventStore {
@@ -34,11 +41,13 @@ var alertEventPool = pool.NewLockFreePool(func() *AlertEventStore {
 })
 
 func AcquireAlertEventStore() *AlertEventStore {
-	return alertEventPool.Get()
+	e := alertEventPool.Get()
+	e.Reset()
+	return e
 }
 
 func ReleaseAlertEventStore(e *AlertEventStore) {
-	if e == nil {
+	if e == nil || e.SubReferenceCount() {
 		return
 	}
 	*e = AlertEventStore{}
@@ -46,26 +55,28 @@ func ReleaseAlertEventStore(e *AlertEventStore) {
 }
 
 type AlertEventStore struct {
-	Time uint32
-	_id  uint64
-
-	PolicyId     uint32
-	PolicyType   uint8
-	AlertPolicy  string
-	MetricValue  float64
-	EventLevel   uint8
-	TargetTags   string
-	TagStrKeys   []string
-	TagStrValues []string
+	pool.ReferenceCount
+
+	Time uint32 `json:"time" category:"$tag" sub:"flow_info"` // s
+	_id  uint64 `json:"_id" category:"$tag" sub:"flow_info"`
+
+	PolicyId     uint32   `json:"policy_id" category:"$tag" sub:"event_info"`
+	PolicyType   uint8    `json:"policy_type" category:"$tag" sub:"event_info"`
+	AlertPolicy  string   `json:"alert_policy" category:"$tag" sub:"event_info"`
+	MetricValue  float64  `json:"metric_value" category:"$metrics"`
+	EventLevel   uint8    `json:"event_level" category:"$tag" sub:"event_info"`
+	TargetTags   string   `json:"target_tags" category:"$tag" sub:"event_info"`
+	TagStrKeys   []string `json:"tag_string_names" category:"$tag" sub:"native_tag" data_type:"[]string"`
+	TagStrValues []string `json:"tag_string_values" category:"$tag" sub:"native_tag" data_type:"[]string"`
 	TagIntKeys   []string
 	TagIntValues []int64
 
-	XTargetUid   string
-	XQueryRegion string
+	XTargetUid   string `json:"_target_uid" category:"$tag" sub:"event_info"`
+	XQueryRegion string `json:"_query_region" category:"$tag" sub:"event_info"`
 
-	UserId uint32
-	OrgId  uint16
-	TeamID uint16
+	UserId uint32 `json:"user_id" category:"$tag"`
+	OrgId  uint16 `json:"org_id" category:"$tag"`
+	TeamID uint16 `json:"team_id" category:"$tag"`
 }
 
 func (e *AlertEventStore) SetId(time, analyzerID uint32) {
@@ -103,6 +114,33 @@ func (e *AlertEventStore) Release() {
 	ReleaseAlertEventStore(e)
 }
 
+func (e *AlertEventStore) DataSource() uint32 {
+	return uint32(exporterconfig.ALERT_EVENT)
+}
+
+func (e *AlertEventStore) EncodeTo(protocol exporterconfig.ExportProtocol, utags *utag.UniversalTagsManager, cfg *exporterconfig.ExporterCfg) (interface{}, error) {
+	switch protocol {
+	case exporterconfig.PROTOCOL_KAFKA:
+		return exportercommon.EncodeToJson(e, int(e.DataSource()), cfg, nil, nil, nil, nil), nil
+	case exporterconfig.PROTOCOL_FILE:
+		return exportercommon.EncodeToRow(e, int(e.DataSource()), cfg, nil, nil, nil, nil), nil
+	case exporterconfig.PROTOCOL_OTLP:
+		logs, record := exportercommon.EncodeToOtlpLogs(e, int(e.DataSource()), cfg, nil, nil)
+		record.Body().SetStr(e.AlertPolicy)
+		return logs, nil
+	default:
+		return nil, fmt.Errorf("alert event unsupport export to %s", protocol)
+	}
+}
+
+func (e *AlertEventStore) GetFieldValueByOffsetAndKind(offset uintptr, kind reflect.Kind, dataType utils.DataType) interface{} {
+	return utils.GetValueByOffsetAndKind(uintptr(unsafe.Pointer(e)), offset, kind, dataType)
+}
+
+func (e *AlertEventStore) TimestampUs() int64 {
+	return int64(e.Time) * 1000000
+}
+
 func (e *AlertEventStore) NativeTagVersion() uint32 {
 	return 0
 }
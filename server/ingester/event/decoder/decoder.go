@@ -260,7 +260,16 @@ func (d *Decoder) export(item exporterscommon.ExportItem) {
 	if d.exporters == nil {
 		return
 	}
-	d.exporters.Put(d.eventType.DataSource(), d.index, item)
+	d.exporters.Put(d.eventType.DataSource(), d.exporterIndex(), item)
+}
+
+// resource events and k8s events are both exported as 'event.event', the resource event decoder uses the last
+// exporter cache which is not used by the k8s event decoders
+func (d *Decoder) exporterIndex() int {
+	if d.eventType == common.RESOURCE_EVENT {
+		return queue.MAX_QUEUE_COUNT - 1
+	}
+	return d.index
 }
 
 func (d *Decoder) handlePerfEvent(vtapId uint16, decoder *codec.SimpleDecoder) {
@@ -416,6 +425,7 @@ func (d *Decoder) handleResourceEvent(event *eventapi.ResourceEvent) {
 		)
 
 	d.counter.OutCount++
+	d.export(s)
 	d.eventWriter.Write(s)
 }
 
@@ -465,5 +475,6 @@ func (d *Decoder) writeAlertEvent(event *alert_event.AlertEvent) {
 	s.TeamID = uint16(event.GetTeamId())
 	s.UserId = event.GetUserId()
 
+	d.export(s)
 	d.eventWriter.WriteAlertEvent(s)
 }
@@ -112,35 +112,59 @@ func writeK8sLabels(sb *strings.Builder, keyName, valueName string, k8sLabels ut
 	sb.WriteString(valuesBuilder.String())
 }
 
-func EncodeToJson(item EncodeItem, dataSourceId int, exporterCfg *config.ExporterCfg, uTags0, uTags1 *utag.UniversalTags, k8sLabels0, k8sLabels1 utag.Labels) string {
-	var sb = &strings.Builder{}
-	sb.WriteString("{\"datasource\":\"")
-	sb.WriteString(config.DataSourceID(dataSourceId).String())
-	sb.WriteString(`"`)
+type fieldKind uint8
 
-	if dataSourceId >= int(config.MAX_DATASOURCE_ID) {
-		log.Errorf("export datasource wrong: datasourceid %d ", dataSourceId)
-		return ""
+const (
+	fieldString fieldKind = iota
+	fieldFloat64
+	fieldStringSlice
+	fieldFloat64Slice
+)
+
+// exportField is the value of an exported field after the universal tag and enum translation
+type exportField struct {
+	structTags   *config.StructTags
+	key          string
+	kind         fieldKind
+	value        interface{} // the value before translation
+	valueStr     string
+	valueFloat64 float64
+	stringSlice  []string
+	float64Slice []float64
+}
+
+func exportFieldKey(structTags *config.StructTags, isMapItem bool, exporterCfg *config.ExporterCfg) string {
+	keyStr := structTags.Name
+	if isMapItem && structTags.MapName != "" {
+		keyStr = structTags.MapName
 	}
+	if structTags.ToStringFuncName == "" && structTags.UniversalTagMapID > 0 && !exporterCfg.UniversalTagTranslateToNameDisabled {
+		// skip '_id'
+		if pos := strings.Index(keyStr, "_id"); pos != -1 {
+			keyStr = (keyStr[:pos]) + keyStr[pos+3:] // 3 is  length of '_id'
+		}
+	}
+	return keyStr
+}
 
+// rangeExportFields calls fn with each exported field of the item, the empty tags and metrics are skipped as configured
+func rangeExportFields(item EncodeItem, dataSourceId int, exporterCfg *config.ExporterCfg, uTags0, uTags1 *utag.UniversalTags, fn func(f *exportField)) {
 	isMapItem := config.DataSourceID(dataSourceId).IsMap()
 	var isString, isFloat64, isStringSlice, isFloat64Slice bool
 	var keyStr, valueStr string
 	var valueFloat64 float64
 	var stringSlice []string
 	var float64Slice []float64
-	for _, structTags := range exporterCfg.ExportFieldStructTags[dataSourceId] {
+	f := &exportField{}
+	for i := range exporterCfg.ExportFieldStructTags[dataSourceId] {
+		structTags := &exporterCfg.ExportFieldStructTags[dataSourceId][i]
 		isString, isFloat64, isStringSlice, isFloat64Slice = false, false, false, false
 		value := item.GetFieldValueByOffsetAndKind(structTags.Offset, structTags.DataKind, structTags.DataType)
 		if utils.IsNil(value) {
 			log.Debugf("%s value is nil", structTags.FieldName)
 			continue
 		}
-		if isMapItem && structTags.MapName != "" {
-			keyStr = structTags.MapName
-		} else {
-			keyStr = structTags.Name
-		}
+		keyStr = exportFieldKey(structTags, isMapItem, exporterCfg)
 		if v, ok := value.(string); ok {
 			isString = true
 			valueStr = v
@@ -164,10 +188,6 @@ func EncodeToJson(item EncodeItem, dataSourceId int, exporterCfg *config.Exporte
 			valueStr = ret[0].String()
 			isString = true
 		} else if structTags.UniversalTagMapID > 0 && !exporterCfg.UniversalTagTranslateToNameDisabled {
-			// skip '_id'
-			if pos := strings.Index(keyStr, "_id"); pos != -1 {
-				keyStr = (keyStr[:pos]) + keyStr[pos+3:] // 3 is  length of '_id'
-			}
 			if strings.HasSuffix(structTags.Name, "_1") {
 				valueStr = uTags1.GetTagValue(structTags.UniversalTagMapID)
 			} else {
@@ -199,16 +219,46 @@ func EncodeToJson(item EncodeItem, dataSourceId int, exporterCfg *config.Exporte
 			continue
 		}
 
+		*f = exportField{structTags: structTags, key: keyStr, value: value}
+		if isString {
+			f.kind, f.valueStr = fieldString, valueStr
+		} else if isStringSlice {
+			f.kind, f.stringSlice = fieldStringSlice, stringSlice
+		} else if isFloat64Slice {
+			f.kind, f.float64Slice = fieldFloat64Slice, float64Slice
+		} else if isFloat64 {
+			f.kind, f.valueFloat64, f.valueStr = fieldFloat64, valueFloat64, valueStr
+		} else {
+			log.Warningf("unreachable")
+			continue
+		}
+		fn(f)
+	}
+}
+
+func EncodeToJson(item EncodeItem, dataSourceId int, exporterCfg *config.ExporterCfg, uTags0, uTags1 *utag.UniversalTags, k8sLabels0, k8sLabels1 utag.Labels) string {
+	var sb = &strings.Builder{}
+	sb.WriteString("{\"datasource\":\"")
+	sb.WriteString(config.DataSourceID(dataSourceId).String())
+	sb.WriteString(`"`)
+
+	if dataSourceId >= int(config.MAX_DATASOURCE_ID) {
+		log.Errorf("export datasource wrong: datasourceid %d ", dataSourceId)
+		return ""
+	}
+
+	rangeExportFields(item, dataSourceId, exporterCfg, uTags0, uTags1, func(f *exportField) {
 		sb.WriteString(`,"`)
-		sb.WriteString(keyStr)
+		sb.WriteString(f.key)
 		sb.WriteString(`":`)
-		if isString {
+		switch f.kind {
+		case fieldString:
 			sb.WriteString(`"`)
-			utils.EscapeJsonStringToStringBuilder(sb, valueStr)
+			utils.EscapeJsonStringToStringBuilder(sb, f.valueStr)
 			sb.WriteString(`"`)
-		} else if isStringSlice {
+		case fieldStringSlice:
 			sb.WriteString("[")
-			for i, v := range stringSlice {
+			for i, v := range f.stringSlice {
 				if i != 0 {
 					sb.WriteString(`,`)
 				}
@@ -217,23 +267,21 @@ func EncodeToJson(item EncodeItem, dataSourceId int, exporterCfg *config.Exporte
 				sb.WriteString(`"`)
 			}
 			sb.WriteString("]")
-		} else if isFloat64Slice {
+		case fieldFloat64Slice:
 			sb.WriteString("[")
-			for i, v := range float64Slice {
+			for i, v := range f.float64Slice {
 				if i != 0 {
 					sb.WriteString(`,`)
 				}
 				sb.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
 			}
 			sb.WriteString("]")
-		} else if isFloat64 {
-			sb.WriteString(valueStr)
-		} else {
-			log.Warningf("unreachable")
+		case fieldFloat64:
+			sb.WriteString(f.valueStr)
 		}
-	}
+	})
 
-	if isMapItem {
+	if config.DataSourceID(dataSourceId).IsMap() {
 		writeK8sLabels(sb, "k8s_label_names_0", "k8s_label_values_0", k8sLabels0)
 		writeK8sLabels(sb, "k8s_label_names_1", "k8s_label_values_1", k8sLabels1)
 	} else {
@@ -247,3 +295,154 @@ func EncodeToJson(item EncodeItem, dataSourceId int, exporterCfg *config.Exporte
 	sb.WriteString("}")
 	return sb.String()
 }
+
+type ColumnKind uint8
+
+const (
+	ColumnString ColumnKind = iota
+	ColumnInt64
+	ColumnUint64
+	ColumnFloat64
+	ColumnJson // the slices, stored as json text
+)
+
+type ExportColumn struct {
+	Name string
+	Kind ColumnKind
+}
+
+// ExportColumns returns the columns of the json encoded by EncodeToJson and the row encoded by EncodeToRow, the
+// duplicated keys are only returned once
+func ExportColumns(dataSourceId int, exporterCfg *config.ExporterCfg) []ExportColumn {
+	if dataSourceId >= int(config.MAX_DATASOURCE_ID) {
+		return nil
+	}
+	isMapItem := config.DataSourceID(dataSourceId).IsMap()
+	columns := []ExportColumn{{Name: "datasource", Kind: ColumnString}}
+	names := map[string]bool{"datasource": true}
+	add := func(name string, kind ColumnKind) {
+		if !names[name] {
+			names[name] = true
+			columns = append(columns, ExportColumn{Name: name, Kind: kind})
+		}
+	}
+
+	for i := range exporterCfg.ExportFieldStructTags[dataSourceId] {
+		structTags := &exporterCfg.ExportFieldStructTags[dataSourceId][i]
+		kind := ColumnString
+		// the translated fields are strings
+		translated := structTags.ToStringFuncName != "" ||
+			(structTags.UniversalTagMapID > 0 && !exporterCfg.UniversalTagTranslateToNameDisabled) ||
+			(structTags.EnumFile != "" && !exporterCfg.EnumTranslateToNameDisabled)
+		if !translated {
+			switch structTags.DataKind {
+			case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				kind = ColumnInt64
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				kind = ColumnUint64
+			case reflect.Float32, reflect.Float64:
+				kind = ColumnFloat64
+			case reflect.Slice, reflect.Array, reflect.Map:
+				kind = ColumnJson
+			}
+		}
+		add(exportFieldKey(structTags, isMapItem, exporterCfg), kind)
+	}
+
+	if isMapItem {
+		add("k8s_label_names_0", ColumnJson)
+		add("k8s_label_values_0", ColumnJson)
+		add("k8s_label_names_1", ColumnJson)
+		add("k8s_label_values_1", ColumnJson)
+	} else {
+		add("k8s_label_names", ColumnJson)
+		add("k8s_label_values", ColumnJson)
+	}
+	add("time_str", ColumnString)
+	return columns
+}
+
+// ExportValue is the value of a column returned by ExportColumns, the type of Value is string, int64, uint64,
+// float64, []string or []float64
+type ExportValue struct {
+	Name  string
+	Value interface{}
+}
+
+// ExportRow is the exported fields of an item in the order of ExportColumns, the fields which are not exported
+// are absent
+type ExportRow []ExportValue
+
+// Value returns the value of the column, or nil if the column is not exported
+func (r ExportRow) Value(name string) interface{} {
+	for i := range r {
+		if r[i].Name == name {
+			return r[i].Value
+		}
+	}
+	return nil
+}
+
+// EncodeToRow returns the same fields as EncodeToJson, the numbers keep their integer types instead of being
+// formatted as json
+func EncodeToRow(item EncodeItem, dataSourceId int, exporterCfg *config.ExporterCfg, uTags0, uTags1 *utag.UniversalTags, k8sLabels0, k8sLabels1 utag.Labels) ExportRow {
+	if dataSourceId >= int(config.MAX_DATASOURCE_ID) {
+		log.Errorf("export datasource wrong: datasourceid %d ", dataSourceId)
+		return nil
+	}
+	row := ExportRow{{Name: "datasource", Value: config.DataSourceID(dataSourceId).String()}}
+
+	rangeExportFields(item, dataSourceId, exporterCfg, uTags0, uTags1, func(f *exportField) {
+		v := ExportValue{Name: f.key}
+		switch f.kind {
+		case fieldString:
+			v.Value = f.valueStr
+		case fieldStringSlice:
+			v.Value = f.stringSlice
+		case fieldFloat64Slice:
+			v.Value = f.float64Slice
+		case fieldFloat64:
+			v.Value = numberValue(f.value, f.valueFloat64)
+		}
+		row = append(row, v)
+	})
+
+	if config.DataSourceID(dataSourceId).IsMap() {
+		row = appendK8sLabels(row, "k8s_label_names_0", "k8s_label_values_0", k8sLabels0)
+		row = appendK8sLabels(row, "k8s_label_names_1", "k8s_label_values_1", k8sLabels1)
+	} else {
+		row = appendK8sLabels(row, "k8s_label_names", "k8s_label_values", k8sLabels0)
+	}
+	return append(row, ExportValue{Name: "time_str", Value: time.UnixMicro(item.TimestampUs()).String()})
+}
+
+// numberValue returns the integer as int64 or uint64 to keep the precision, other numbers as float64
+func numberValue(value interface{}, valueFloat64 float64) interface{} {
+	v := reflect.Indirect(reflect.ValueOf(value))
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return int64(1)
+		}
+		return int64(0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint()
+	default:
+		return valueFloat64
+	}
+}
+
+func appendK8sLabels(row ExportRow, keyName, valueName string, k8sLabels utag.Labels) ExportRow {
+	if len(k8sLabels) == 0 {
+		return row
+	}
+	keys := make([]string, 0, len(k8sLabels))
+	values := make([]string, 0, len(k8sLabels))
+	for key, value := range k8sLabels {
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+	return append(row, ExportValue{Name: keyName, Value: keys}, ExportValue{Name: valueName, Value: values})
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+
+	"github.com/deepflowio/deepflow/server/ingester/exporters/config"
+	utag "github.com/deepflowio/deepflow/server/ingester/exporters/universal_tag"
+)
+
+const (
+	OTLP_SCOPE_NAME          = "deepflow"
+	OTLP_ATTR_DATASOURCE     = "df.datasource"
+	OTLP_ATTR_K8S_LABEL_PREF = "df.custom_tag.k8s.labels."
+)
+
+// EncodeToOtlpLogs encodes the exported fields of the item into an OTLP log record, the universal tags and k8s labels
+// are put into the resource attributes and the others into the log attributes.
+// The caller fills the body, severity and trace context of the returned log record.
+func EncodeToOtlpLogs(item EncodeItem, dataSourceId int, exporterCfg *config.ExporterCfg, uTags *utag.UniversalTags, k8sLabels utag.Labels) (plog.ResourceLogsSlice, plog.LogRecord) {
+	logsSlice := plog.NewResourceLogsSlice()
+	resLogs := logsSlice.AppendEmpty()
+	resAttrs := resLogs.Resource().Attributes()
+	scopeLogs := resLogs.ScopeLogs().AppendEmpty()
+	scopeLogs.Scope().SetName(OTLP_SCOPE_NAME)
+	record := scopeLogs.LogRecords().AppendEmpty()
+	record.SetTimestamp(pcommon.NewTimestampFromTime(time.UnixMicro(item.TimestampUs())))
+	record.SetObservedTimestamp(pcommon.NewTimestampFromTime(time.Now()))
+
+	attrs := record.Attributes()
+	attrs.PutStr(OTLP_ATTR_DATASOURCE, config.DataSourceID(dataSourceId).String())
+	if dataSourceId >= int(config.MAX_DATASOURCE_ID) {
+		log.Errorf("export datasource wrong: datasourceid %d ", dataSourceId)
+		return logsSlice, record
+	}
+
+	rangeExportFields(item, dataSourceId, exporterCfg, uTags, uTags, func(f *exportField) {
+		dst := attrs
+		if f.structTags.SubCategoryBit == config.UNIVERSAL_TAG {
+			dst = resAttrs
+		}
+		switch f.kind {
+		case fieldString:
+			dst.PutStr(f.key, f.valueStr)
+		case fieldFloat64:
+			// the string keeps the precision of the integers
+			if v, err := strconv.ParseInt(f.valueStr, 10, 64); err == nil {
+				dst.PutInt(f.key, v)
+			} else {
+				dst.PutDouble(f.key, f.valueFloat64)
+			}
+		case fieldStringSlice:
+			s := dst.PutEmptySlice(f.key)
+			s.EnsureCapacity(len(f.stringSlice))
+			for _, v := range f.stringSlice {
+				s.AppendEmpty().SetStr(v)
+			}
+		case fieldFloat64Slice:
+			s := dst.PutEmptySlice(f.key)
+			s.EnsureCapacity(len(f.float64Slice))
+			for _, v := range f.float64Slice {
+				s.AppendEmpty().SetDouble(v)
+			}
+		}
+	})
+
+	for name, value := range k8sLabels {
+		if value != "" {
+			resAttrs.PutStr(OTLP_ATTR_K8S_LABEL_PREF+name, value)
+		}
+	}
+	return logsSlice, record
+}
@@ -38,11 +38,12 @@ import (
 var log = logging.MustGetLogger("exporters_config")
 
 const (
-	DefaultExportQueueCount     = 4
-	DefaultExportQueueSize      = 100000
-	DefaultExportOtlpBatchSize  = 32
-	DefaultExportOtherBatchSize = 1024
-	SecurityProtocol            = "SASL_SSL"
+	DefaultExportQueueCount       = 4
+	DefaultExportQueueSize        = 100000
+	DefaultExportOtlpBatchSize    = 32
+	DefaultExportOtherBatchSize   = 1024
+	SecurityProtocolSaslSSL       = "SASL_SSL"
+	SecurityProtocolSaslPlaintext = "SASL_PLAINTEXT"
 
 	CATEGORY_K8S_LABEL = "$k8s.label"
 	CATEGORY_TAG       = "$tag"
@@ -69,6 +70,10 @@ const (
 	PERF_EVENT = DataSourceID(flow_metrics.METRICS_TABLE_ID_MAX) + 1 + iota
 	L4_FLOW_LOG
 	L7_FLOW_LOG
+	EVENT
+	ALERT_EVENT
+	APPLICATION_LOG
+	IN_PROCESS_PROFILE
 
 	MAX_DATASOURCE_ID
 )
@@ -85,6 +90,10 @@ var dataSourceStrings = []string{
 	PERF_EVENT:         "event.perf_event",
 	L4_FLOW_LOG:        "flow_log.l4_flow_log",
 	L7_FLOW_LOG:        "flow_log.l7_flow_log",
+	EVENT:              "event.event",
+	ALERT_EVENT:        "event.alert_event",
+	APPLICATION_LOG:    "application_log.log",
+	IN_PROCESS_PROFILE: "profile.in_process",
 	MAX_DATASOURCE_ID:  "invalid_datasource",
 }
 
@@ -100,6 +109,10 @@ var dataSourceTopicStrings = []string{
 	PERF_EVENT:         TOPIC_PREFIX + dataSourceStrings[PERF_EVENT],
 	L4_FLOW_LOG:        TOPIC_PREFIX + dataSourceStrings[L4_FLOW_LOG],
 	L7_FLOW_LOG:        TOPIC_PREFIX + dataSourceStrings[L7_FLOW_LOG],
+	EVENT:              TOPIC_PREFIX + dataSourceStrings[EVENT],
+	ALERT_EVENT:        TOPIC_PREFIX + dataSourceStrings[ALERT_EVENT],
+	APPLICATION_LOG:    TOPIC_PREFIX + dataSourceStrings[APPLICATION_LOG],
+	IN_PROCESS_PROFILE: TOPIC_PREFIX + dataSourceStrings[IN_PROCESS_PROFILE],
 	MAX_DATASOURCE_ID:  TOPIC_PREFIX + dataSourceStrings[MAX_DATASOURCE_ID],
 }
 
@@ -145,7 +158,8 @@ func (d DataSourceID) TopicString() string {
 
 func (d DataSourceID) IsMap() bool {
 	switch d {
-	case NETWORK_1M, APPLICATION_1M, NETWORK_1S, APPLICATION_1S, PERF_EVENT:
+	case NETWORK_1M, APPLICATION_1M, NETWORK_1S, APPLICATION_1S, PERF_EVENT,
+		EVENT, ALERT_EVENT, APPLICATION_LOG, IN_PROCESS_PROFILE:
 		return false
 	default:
 		return true
@@ -476,27 +490,185 @@ type ExporterCfg struct {
 	ExtraHeaders map[string]string `yaml:"extra-headers"`
 
 	// kafka private configuration
-	Sasl  Sasl   `yaml:"sasl"`
-	Topic string `yaml:"topic"`
+	Sasl         Sasl         `yaml:"sasl"`
+	Topic        string       `yaml:"topic"`         // template, such as 'deepflow.{datasource}.{pod_ns}'
+	PartitionKey string       `yaml:"partition-key"` // template, such as '{app_service}'
+	Encoding     string       `yaml:"encoding"`      // 'json', 'protobuf' or 'avro'
+	AvroSchemas  []AvroSchema `yaml:"avro-schemas"`
+
+	// file private configuration
+	File FileConfig `yaml:"file"`
 }
 
 type Sasl struct {
-	Enabled          bool   `yaml:"enabled"`
-	SecurityProtocol string `yaml:"security-protocol"` // only support 'SASL_SSL'
-	Mechanism        string `yaml:"sasl-mechanism"`    // only support 'PLAIN'
-	Username         string `yaml:"username"`
-	Password         string `yaml:"password"`
+	Enabled               bool   `yaml:"enabled"`
+	SecurityProtocol      string `yaml:"security-protocol"` // 'SASL_SSL' or 'SASL_PLAINTEXT'
+	Mechanism             string `yaml:"sasl-mechanism"`    // 'PLAIN', 'SCRAM-SHA-256' or 'SCRAM-SHA-512'
+	Username              string `yaml:"username"`
+	Password              string `yaml:"password"`
+	TLSInsecureSkipVerify bool   `yaml:"tls-insecure-skip-verify"`
 }
 
 func (s *Sasl) Validate() error {
 	if !s.Enabled {
 		return nil
 	}
-	if s.SecurityProtocol != SecurityProtocol {
-		log.Warningf("'sasl-protocol' only support value %s", SecurityProtocol)
+	switch s.SecurityProtocol {
+	case SecurityProtocolSaslSSL, SecurityProtocolSaslPlaintext:
+	default:
+		log.Warningf("'security-protocol' only support value %s, %s, use %s", SecurityProtocolSaslSSL, SecurityProtocolSaslPlaintext, SecurityProtocolSaslSSL)
+		s.SecurityProtocol = SecurityProtocolSaslSSL
+	}
+	switch s.Mechanism {
+	case sarama.SASLTypePlaintext, sarama.SASLTypeSCRAMSHA256, sarama.SASLTypeSCRAMSHA512:
+	default:
+		log.Warningf("'sasl-mechanism' only support value %s, %s, %s, use %s", sarama.SASLTypePlaintext, sarama.SASLTypeSCRAMSHA256, sarama.SASLTypeSCRAMSHA512, sarama.SASLTypePlaintext)
+		s.Mechanism = sarama.SASLTypePlaintext
+	}
+	return nil
+}
+
+const (
+	KAFKA_ENCODING_JSON     = "json"
+	KAFKA_ENCODING_PROTOBUF = "protobuf" // the OTLP ExportTraceServiceRequest or ExportLogsServiceRequest
+	KAFKA_ENCODING_AVRO     = "avro"
+)
+
+type AvroSchema struct {
+	DataSource string `yaml:"data-source"`
+	SchemaFile string `yaml:"schema-file"`
+	// if it is not 0, the message is encoded in the confluent wire format: magic byte 0, 4 bytes schema id and the avro binary
+	SchemaRegistryID uint32 `yaml:"schema-registry-id"`
+}
+
+func (cfg *ExporterCfg) validateKafka() error {
+	cfg.Sasl.Validate()
+	switch cfg.Encoding {
+	case KAFKA_ENCODING_JSON:
+	case KAFKA_ENCODING_PROTOBUF:
+		if err := cfg.validateOtlpDataSources(); err != nil {
+			return err
+		}
+	case KAFKA_ENCODING_AVRO:
+		for _, s := range cfg.AvroSchemas {
+			if _, err := ToDataSourceID(s.DataSource); err != nil {
+				return fmt.Errorf("kafka avro schema %s: %s", s.SchemaFile, err)
+			}
+			if s.SchemaFile == "" {
+				return fmt.Errorf("kafka avro schema file of %s is empty", s.DataSource)
+			}
+		}
+	case "":
+		cfg.Encoding = KAFKA_ENCODING_JSON
+	default:
+		log.Warningf("unsupport kafka encoding %s, use %s", cfg.Encoding, KAFKA_ENCODING_JSON)
+		cfg.Encoding = KAFKA_ENCODING_JSON
 	}
-	if s.Mechanism != sarama.SASLTypePlaintext {
-		log.Warningf("'sasl-mechanism' only support value %s", sarama.SASLTypePlaintext)
+	return nil
+}
+
+// the OTLP profiles signal is not supported by the pdata in use, so the profiles can not be exported in the OTLP format
+func (cfg *ExporterCfg) validateOtlpDataSources() error {
+	if cfg.DataSourceBits&(1<<uint32(IN_PROCESS_PROFILE)) != 0 {
+		return fmt.Errorf("data source %s can not be exported in the OTLP format", IN_PROCESS_PROFILE)
+	}
+	return nil
+}
+
+const (
+	FILE_FORMAT_NDJSON  = "ndjson"
+	FILE_FORMAT_PARQUET = "parquet"
+
+	FILE_COMPRESSION_NONE   = "none"
+	FILE_COMPRESSION_GZIP   = "gzip"
+	FILE_COMPRESSION_ZSTD   = "zstd"
+	FILE_COMPRESSION_SNAPPY = "snappy" // parquet only
+
+	DefaultFileDirectory        = "/var/lib/deepflow/exporters"
+	DefaultFileRotateInterval   = 600 // s
+	DefaultFileRotateSizeMB     = 256
+	DefaultFileRetentionHours   = 72
+	DefaultFileRetentionSizeMB  = 10240
+	DefaultFileS3Region         = "us-east-1"
+	DefaultFileS3UploadTimeout  = 60 // s
+	DefaultFileS3UploadRetryMax = 3
+)
+
+type FileS3 struct {
+	Enabled           bool   `yaml:"enabled"`
+	Endpoint          string `yaml:"endpoint"` // such as 'http://127.0.0.1:9000', the bucket is put in the path
+	Region            string `yaml:"region"`
+	Bucket            string `yaml:"bucket"`
+	Prefix            string `yaml:"prefix"`
+	AccessKey         string `yaml:"access-key"`
+	SecretKey         string `yaml:"secret-key"`
+	Timeout           int    `yaml:"timeout"` // s
+	RetryMax          int    `yaml:"retry-max"`
+	DeleteAfterUpload bool   `yaml:"delete-after-upload"`
+}
+
+type FileConfig struct {
+	Directory       string `yaml:"directory"`
+	Format          string `yaml:"format"`            // 'ndjson' or 'parquet'
+	Compression     string `yaml:"compression"`       // 'none', 'gzip', 'zstd', 'snappy'(parquet only)
+	RotateInterval  int    `yaml:"rotate-interval"`   // s
+	RotateSizeMB    int    `yaml:"rotate-size-mb"`    // the size before compression
+	RetentionHours  int    `yaml:"retention-hours"`   // negative means never delete by time
+	RetentionSizeMB int    `yaml:"retention-size-mb"` // negative means never delete by size
+	S3              FileS3 `yaml:"s3"`
+}
+
+func (f *FileConfig) Validate() error {
+	if f.Directory == "" {
+		f.Directory = DefaultFileDirectory
+	}
+	switch f.Format {
+	case FILE_FORMAT_NDJSON, FILE_FORMAT_PARQUET:
+	case "":
+		f.Format = FILE_FORMAT_NDJSON
+	default:
+		log.Warningf("unsupport file format %s, use %s", f.Format, FILE_FORMAT_NDJSON)
+		f.Format = FILE_FORMAT_NDJSON
+	}
+	switch f.Compression {
+	case FILE_COMPRESSION_NONE, FILE_COMPRESSION_GZIP, FILE_COMPRESSION_ZSTD:
+	case FILE_COMPRESSION_SNAPPY:
+		if f.Format != FILE_FORMAT_PARQUET {
+			log.Warningf("file compression %s only support format %s, use %s", f.Compression, FILE_FORMAT_PARQUET, FILE_COMPRESSION_GZIP)
+			f.Compression = FILE_COMPRESSION_GZIP
+		}
+	case "":
+		f.Compression = FILE_COMPRESSION_GZIP
+	default:
+		log.Warningf("unsupport file compression %s, use %s", f.Compression, FILE_COMPRESSION_GZIP)
+		f.Compression = FILE_COMPRESSION_GZIP
+	}
+	if f.RotateInterval <= 0 {
+		f.RotateInterval = DefaultFileRotateInterval
+	}
+	if f.RotateSizeMB <= 0 {
+		f.RotateSizeMB = DefaultFileRotateSizeMB
+	}
+	if f.RetentionHours == 0 {
+		f.RetentionHours = DefaultFileRetentionHours
+	}
+	if f.RetentionSizeMB == 0 {
+		f.RetentionSizeMB = DefaultFileRetentionSizeMB
+	}
+
+	if f.S3.Enabled {
+		if f.S3.Endpoint == "" || f.S3.Bucket == "" {
+			return fmt.Errorf("file exporter s3 'endpoint' and 'bucket' must be set")
+		}
+		if f.S3.Region == "" {
+			f.S3.Region = DefaultFileS3Region
+		}
+		if f.S3.Timeout <= 0 {
+			f.S3.Timeout = DefaultFileS3UploadTimeout
+		}
+		if f.S3.RetryMax <= 0 {
+			f.S3.RetryMax = DefaultFileS3UploadRetryMax
+		}
 	}
 	return nil
 }
@@ -507,6 +679,7 @@ const (
 	PROTOCOL_OTLP ExportProtocol = iota
 	PROTOCOL_PROMETHEUS
 	PROTOCOL_KAFKA
+	PROTOCOL_FILE
 
 	MAX_PROTOCOL_ID
 )
@@ -515,6 +688,7 @@ var protocolToStrings = []string{
 	PROTOCOL_OTLP:       "opentelemetry",
 	PROTOCOL_PROMETHEUS: "prometheus",
 	PROTOCOL_KAFKA:      "kafka",
+	PROTOCOL_FILE:       "file",
 	MAX_PROTOCOL_ID:     "unknown",
 }
 
@@ -569,7 +743,20 @@ func (cfg *ExporterCfg) Validate() error {
 	}
 
 	cfg.TagFilterCondition.Validate()
-	cfg.Sasl.Validate()
+	switch cfg.ExportProtocol {
+	case PROTOCOL_KAFKA:
+		if err := cfg.validateKafka(); err != nil {
+			return err
+		}
+	case PROTOCOL_FILE:
+		if err := cfg.File.Validate(); err != nil {
+			return err
+		}
+	case PROTOCOL_OTLP:
+		if err := cfg.validateOtlpDataSources(); err != nil {
+			return err
+		}
+	}
 
 	for i := range cfg.TagFiltersGroups {
 		cfg.TagFiltersGroups[i].Validate()
@@ -625,7 +812,7 @@ const (
 	SERVICE_INFO
 	TRACING_INFO
 	CAPTURE_INFO
-	EVENT_INFO // perf_event only
+	EVENT_INFO // event only
 	DATA_LINK_LAYER
 
 	// metrics
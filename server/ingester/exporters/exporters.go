@@ -25,6 +25,7 @@ import (
 	"github.com/deepflowio/deepflow/server/ingester/exporters/common"
 	"github.com/deepflowio/deepflow/server/ingester/exporters/config"
 	"github.com/deepflowio/deepflow/server/ingester/exporters/enum_translation"
+	"github.com/deepflowio/deepflow/server/ingester/exporters/file_exporter"
 	"github.com/deepflowio/deepflow/server/ingester/exporters/kafka_exporter"
 	"github.com/deepflowio/deepflow/server/ingester/exporters/otlp_exporter"
 	"github.com/deepflowio/deepflow/server/ingester/exporters/prometheus_exporter"
@@ -97,6 +98,8 @@ func NewExporters(cfg *config.Config) *Exporters {
 			exporter = prometheus_exporter.NewPrometheusExporter(i, &cfg.Exporters[i], universalTagManager)
 		case config.PROTOCOL_KAFKA:
 			exporter = kafka_exporter.NewKafkaExporter(i, &cfg.Exporters[i], universalTagManager)
+		case config.PROTOCOL_FILE:
+			exporter = file_exporter.NewFileExporter(i, &cfg.Exporters[i], universalTagManager)
 		default:
 			exporter = nil
 			log.Warningf("unsupport export protocol %s", exporterCfg.Protocol)
@@ -0,0 +1,295 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package file_exporter
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	logging "github.com/op/go-logging"
+
+	ingester_common "github.com/deepflowio/deepflow/server/ingester/common"
+	"github.com/deepflowio/deepflow/server/ingester/exporters/common"
+	exporters_cfg "github.com/deepflowio/deepflow/server/ingester/exporters/config"
+	utag "github.com/deepflowio/deepflow/server/ingester/exporters/universal_tag"
+	"github.com/deepflowio/deepflow/server/ingester/ingesterctl"
+	"github.com/deepflowio/deepflow/server/libs/debug"
+	"github.com/deepflowio/deepflow/server/libs/queue"
+	"github.com/deepflowio/deepflow/server/libs/stats"
+	"github.com/deepflowio/deepflow/server/libs/utils"
+)
+
+var log = logging.MustGetLogger("file_exporter")
+
+const (
+	QUEUE_BATCH_COUNT     = 1024
+	UPLOAD_QUEUE_SIZE     = 1024
+	RETENTION_CHECK_CYCLE = time.Minute
+)
+
+type FileExporter struct {
+	index                int
+	dataQueues           queue.FixedMultiQueue
+	queueCount           int
+	universalTagsManager *utag.UniversalTagsManager
+	config               *exporters_cfg.ExporterCfg
+	protocol             exporters_cfg.ExportProtocol
+	uploader             *s3Uploader
+	uploadQueue          chan string
+	pendingUploads       sync.Map // the finished files which are not uploaded yet, skipped by the retention
+	counter              *Counter
+	lastCounter          Counter
+	running              bool
+	wg                   sync.WaitGroup
+
+	utils.Closable
+}
+
+type Counter struct {
+	RecvCounter         int64 `statsd:"recv-count"`
+	WriteCounter        int64 `statsd:"write-count"`
+	DropCounter         int64 `statsd:"drop-count"`
+	FileCounter         int64 `statsd:"file-count"`
+	FileErrCounter      int64 `statsd:"file-err-count"`
+	UploadCounter       int64 `statsd:"upload-count"`
+	UploadErrCounter    int64 `statsd:"upload-err-count"`
+	UploadDropCounter   int64 `statsd:"upload-drop-count"`
+	DeleteFileCounter   int64 `statsd:"delete-file-count"`
+	ExportUsedTimeNs    int64 `statsd:"export-used-time-ns"`
+	UploadUsedTimeNs    int64 `statsd:"upload-used-time-ns"`
+	RetentionErrCounter int64 `statsd:"retention-err-count"`
+}
+
+// GetCounter swaps the counters atomically, because they are updated by the queue, upload and retention goroutines
+func (e *FileExporter) GetCounter() interface{} {
+	c := e.counter
+	counter := Counter{
+		RecvCounter:         atomic.SwapInt64(&c.RecvCounter, 0),
+		WriteCounter:        atomic.SwapInt64(&c.WriteCounter, 0),
+		DropCounter:         atomic.SwapInt64(&c.DropCounter, 0),
+		FileCounter:         atomic.SwapInt64(&c.FileCounter, 0),
+		FileErrCounter:      atomic.SwapInt64(&c.FileErrCounter, 0),
+		UploadCounter:       atomic.SwapInt64(&c.UploadCounter, 0),
+		UploadErrCounter:    atomic.SwapInt64(&c.UploadErrCounter, 0),
+		UploadDropCounter:   atomic.SwapInt64(&c.UploadDropCounter, 0),
+		DeleteFileCounter:   atomic.SwapInt64(&c.DeleteFileCounter, 0),
+		ExportUsedTimeNs:    atomic.SwapInt64(&c.ExportUsedTimeNs, 0),
+		UploadUsedTimeNs:    atomic.SwapInt64(&c.UploadUsedTimeNs, 0),
+		RetentionErrCounter: atomic.SwapInt64(&c.RetentionErrCounter, 0),
+	}
+	e.lastCounter = counter
+	return &counter
+}
+
+func NewFileExporter(index int, config *exporters_cfg.ExporterCfg, universalTagsManager *utag.UniversalTagsManager) *FileExporter {
+	dataQueues := queue.NewOverwriteQueues(
+		fmt.Sprintf("file_exporter_%d", index), queue.HashKey(config.QueueCount), config.QueueSize,
+		queue.OptionFlushIndicator(time.Second),
+		queue.OptionRelease(func(p interface{}) { p.(common.ExportItem).Release() }),
+		ingester_common.QUEUE_STATS_MODULE_INGESTER)
+
+	exporter := &FileExporter{
+		index:                index,
+		dataQueues:           dataQueues,
+		queueCount:           config.QueueCount,
+		universalTagsManager: universalTagsManager,
+		config:               config,
+		protocol:             exporters_cfg.PROTOCOL_KAFKA,
+		counter:              &Counter{},
+	}
+	if config.File.Format == exporters_cfg.FILE_FORMAT_PARQUET {
+		// the parquet rows are encoded from the fields directly, the ndjson rows are the same as the kafka exporter
+		exporter.protocol = exporters_cfg.PROTOCOL_FILE
+	}
+	if config.File.S3.Enabled {
+		exporter.uploader = newS3Uploader(&config.File.S3, config.File.Directory)
+		exporter.uploadQueue = make(chan string, UPLOAD_QUEUE_SIZE)
+	}
+	debug.ServerRegisterSimple(ingesterctl.CMD_FILE_EXPORTER, exporter)
+	ingester_common.RegisterCountableForIngester("exporter", exporter, stats.OptionStatTags{
+		"type": "file", "index": strconv.Itoa(index)})
+	log.Infof("file exporter %d created, directory: %s, format: %s", index, config.File.Directory, config.File.Format)
+	return exporter
+}
+
+func (e *FileExporter) Put(items ...interface{}) {
+	recv := atomic.AddInt64(&e.counter.RecvCounter, 1)
+	e.dataQueues.Put(queue.HashKey(int(recv)%e.queueCount), items...)
+}
+
+func (e *FileExporter) Start() {
+	if e.running {
+		log.Warningf("file exporter %d already running", e.index)
+		return
+	}
+	e.running = true
+	for i := 0; i < e.queueCount; i++ {
+		e.wg.Add(1)
+		go e.queueProcess(int(i))
+	}
+	if e.uploader != nil {
+		go e.uploadProcess(e.uploadQueue)
+	}
+	go e.retentionProcess()
+	log.Infof("file exporter %d started %d queue", e.index, e.queueCount)
+}
+
+// Close stops the queue processes and waits for the files being written to be finished, then stops the upload
+// process after the finished files in the upload queue are uploaded
+func (e *FileExporter) Close() {
+	e.Closable.Close()
+	e.running = false
+	e.wg.Wait()
+	// the upload queue is only written by the queue processes which have exited
+	if e.uploadQueue != nil {
+		close(e.uploadQueue)
+		e.uploadQueue = nil
+	}
+	log.Infof("file exporter %d stopping", e.index)
+}
+
+func (e *FileExporter) queueProcess(queueID int) {
+	defer e.wg.Done()
+	items := make([]interface{}, QUEUE_BATCH_COUNT)
+	files := make(map[uint32]*rotatingFile)
+
+	for e.running {
+		n := e.dataQueues.Gets(queue.HashKey(queueID), items)
+		now := time.Now()
+		for _, item := range items[:n] {
+			if item == nil {
+				for _, f := range files {
+					if f.NeedRotate(now) {
+						e.finish(f)
+					}
+				}
+				continue
+			}
+			exportItem, ok := item.(common.ExportItem)
+			if !ok {
+				atomic.AddInt64(&e.counter.DropCounter, 1)
+				continue
+			}
+			e.write(queueID, files, exportItem, now)
+			exportItem.Release()
+		}
+		atomic.AddInt64(&e.counter.ExportUsedTimeNs, int64(time.Since(now)))
+	}
+
+	for _, f := range files {
+		e.finish(f)
+	}
+}
+
+func (e *FileExporter) write(queueID int, files map[uint32]*rotatingFile, item common.ExportItem, now time.Time) {
+	// the rows are filtered by the export fields and translated with the universal tags, the same as the
+	// kafka exporter
+	row, err := item.EncodeTo(e.protocol, e.universalTagsManager, e.config)
+	if err != nil {
+		if atomic.AddInt64(&e.counter.DropCounter, 1) == 1 {
+			log.Warningf("file exporter %d encode failed, err: %s", e.index, err)
+		}
+		return
+	}
+
+	dataSourceId := item.DataSource()
+	f, ok := files[dataSourceId]
+	if !ok {
+		// the struct tags of the export fields are initialized before the first item is put to the exporter
+		f = newRotatingFile(&e.config.File, exporters_cfg.DataSourceID(dataSourceId).String(),
+			fmt.Sprintf("%d-%d", e.index, queueID),
+			common.ExportColumns(int(dataSourceId), e.config))
+		files[dataSourceId] = f
+	}
+	if err := f.WriteRow(row, now); err != nil {
+		if atomic.AddInt64(&e.counter.FileErrCounter, 1) == 1 {
+			log.Warningf("file exporter %d write %s failed, err: %s", e.index, f.dir, err)
+		}
+		atomic.AddInt64(&e.counter.DropCounter, 1)
+		return
+	}
+	atomic.AddInt64(&e.counter.WriteCounter, 1)
+	if f.NeedRotate(now) {
+		e.finish(f)
+	}
+}
+
+func (e *FileExporter) finish(f *rotatingFile) {
+	path, err := f.Finish()
+	if err != nil {
+		log.Warningf("file exporter %d finish file failed, err: %s", e.index, err)
+		atomic.AddInt64(&e.counter.FileErrCounter, 1)
+		return
+	}
+	if path == "" {
+		return
+	}
+	atomic.AddInt64(&e.counter.FileCounter, 1)
+	if e.uploadQueue == nil {
+		return
+	}
+	e.pendingUploads.Store(path, struct{}{})
+	select {
+	case e.uploadQueue <- path:
+	default:
+		e.pendingUploads.Delete(path)
+		atomic.AddInt64(&e.counter.UploadDropCounter, 1)
+	}
+}
+
+func (e *FileExporter) uploadProcess(uploadQueue <-chan string) {
+	for path := range uploadQueue {
+		start := time.Now()
+		err := e.uploader.Upload(path)
+		e.pendingUploads.Delete(path)
+		if err != nil {
+			log.Warningf("file exporter %d upload %s failed, err: %s", e.index, path, err)
+			atomic.AddInt64(&e.counter.UploadErrCounter, 1)
+			continue
+		}
+		atomic.AddInt64(&e.counter.UploadCounter, 1)
+		atomic.AddInt64(&e.counter.UploadUsedTimeNs, int64(time.Since(start)))
+	}
+}
+
+func (e *FileExporter) isPendingUpload(path string) bool {
+	_, ok := e.pendingUploads.Load(path)
+	return ok
+}
+
+func (e *FileExporter) retentionProcess() {
+	ticker := time.NewTicker(RETENTION_CHECK_CYCLE)
+	defer ticker.Stop()
+	for range ticker.C {
+		if e.Closed() {
+			return
+		}
+		deleted, err := cleanExpiredFiles(&e.config.File, time.Now(), e.isPendingUpload)
+		if err != nil {
+			log.Warningf("file exporter %d clean expired files failed, err: %s", e.index, err)
+			atomic.AddInt64(&e.counter.RetentionErrCounter, 1)
+		}
+		atomic.AddInt64(&e.counter.DeleteFileCounter, int64(deleted))
+	}
+}
+
+func (e *FileExporter) HandleSimpleCommand(op uint16, arg string) string {
+	return fmt.Sprintf("file exporter %d last 10s counter: %+v", e.index, e.lastCounter)
+}
@@ -0,0 +1,213 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package file_exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress"
+
+	"github.com/deepflowio/deepflow/server/ingester/exporters/common"
+	exporters_cfg "github.com/deepflowio/deepflow/server/ingester/exporters/config"
+)
+
+const (
+	PARQUET_SCHEMA_NAME     = "deepflow"
+	PARQUET_ROW_GROUP_ROWS  = 64 * 1024
+	PARQUET_ROW_GROUP_BYTES = 64 << 20
+)
+
+func parquetCodec(compression string) compress.Codec {
+	switch compression {
+	case exporters_cfg.FILE_COMPRESSION_SNAPPY:
+		return &parquet.Snappy
+	case exporters_cfg.FILE_COMPRESSION_GZIP:
+		return &parquet.Gzip
+	case exporters_cfg.FILE_COMPRESSION_ZSTD:
+		return &parquet.Zstd
+	default:
+		return &parquet.Uncompressed
+	}
+}
+
+// parquetSchema returns the schema of the columns, all columns are optional because the empty fields may not be
+// exported. The slices are stored as json text.
+func parquetSchema(columns []common.ExportColumn) *parquet.Schema {
+	group := make(parquet.Group, len(columns))
+	for _, c := range columns {
+		var node parquet.Node
+		switch c.Kind {
+		case common.ColumnInt64:
+			node = parquet.Int(64)
+		case common.ColumnUint64:
+			node = parquet.Uint(64)
+		case common.ColumnFloat64:
+			node = parquet.Leaf(parquet.DoubleType)
+		case common.ColumnJson:
+			node = parquet.JSON()
+		default:
+			node = parquet.String()
+		}
+		group[c.Name] = parquet.Optional(node)
+	}
+	return parquet.NewSchema(PARQUET_SCHEMA_NAME, group)
+}
+
+type countWriter struct {
+	w     io.Writer
+	count int64
+}
+
+func (c *countWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.count += int64(n)
+	return n, err
+}
+
+type parquetColumn struct {
+	common.ExportColumn
+	index int // the index of the leaf column in the schema
+}
+
+type parquetWriter struct {
+	output    *countWriter
+	writer    *parquet.Writer
+	columns   map[string]*parquetColumn
+	rows      []parquet.Row // a single row reused by WriteRow
+	groupRows int
+	groupSize int64
+}
+
+func newParquetWriter(w io.Writer, columns []common.ExportColumn, compression string) (*parquetWriter, error) {
+	schema := parquetSchema(columns)
+	p := &parquetWriter{
+		output:  &countWriter{w: w},
+		columns: make(map[string]*parquetColumn, len(columns)),
+		rows:    []parquet.Row{make(parquet.Row, len(columns))},
+	}
+	for _, c := range columns {
+		leaf, ok := schema.Lookup(c.Name)
+		if !ok {
+			return nil, fmt.Errorf("column %s is not found in parquet schema", c.Name)
+		}
+		p.columns[c.Name] = &parquetColumn{ExportColumn: c, index: leaf.ColumnIndex}
+	}
+	config, err := parquet.NewWriterConfig(
+		schema,
+		parquet.Compression(parquetCodec(compression)),
+		parquet.MaxRowsPerRowGroup(PARQUET_ROW_GROUP_ROWS),
+	)
+	if err != nil {
+		return nil, err
+	}
+	p.writer = parquet.NewWriter(p.output, config)
+	return p, nil
+}
+
+// value converts the exported value to the type of the column, the value which does not match the type of the
+// column is stored as null
+func (c *parquetColumn) value(v interface{}) parquet.Value {
+	switch c.Kind {
+	case common.ColumnInt64, common.ColumnUint64:
+		switch x := v.(type) {
+		case int64:
+			return parquet.Int64Value(x)
+		case uint64:
+			return parquet.Int64Value(int64(x))
+		case float64:
+			return parquet.Int64Value(int64(x))
+		}
+	case common.ColumnFloat64:
+		switch x := v.(type) {
+		case float64:
+			return parquet.DoubleValue(x)
+		case int64:
+			return parquet.DoubleValue(float64(x))
+		case uint64:
+			return parquet.DoubleValue(float64(x))
+		}
+	case common.ColumnJson:
+		if b, err := json.Marshal(v); err == nil {
+			return parquet.ByteArrayValue(b)
+		}
+	default:
+		switch x := v.(type) {
+		case string:
+			return parquet.ByteArrayValue([]byte(x))
+		case int64:
+			return parquet.ByteArrayValue(strconv.AppendInt(nil, x, 10))
+		case uint64:
+			return parquet.ByteArrayValue(strconv.AppendUint(nil, x, 10))
+		case float64:
+			return parquet.ByteArrayValue(strconv.AppendFloat(nil, x, 'f', -1, 64))
+		}
+	}
+	return parquet.NullValue()
+}
+
+// WriteRow appends a common.ExportRow, the fields which are not columns are ignored
+func (p *parquetWriter) WriteRow(row interface{}) error {
+	exportRow, ok := row.(common.ExportRow)
+	if !ok {
+		return fmt.Errorf("parquet writer does not support row type %T", row)
+	}
+	values := p.rows[0]
+	for i := range values {
+		values[i] = parquet.NullValue().Level(0, 0, i)
+	}
+	for _, field := range exportRow {
+		c, ok := p.columns[field.Name]
+		if !ok {
+			continue
+		}
+		v := c.value(field.Value)
+		if v.IsNull() {
+			continue
+		}
+		values[c.index] = v.Level(0, 1, c.index)
+		if v.Kind() == parquet.ByteArray {
+			p.groupSize += int64(len(v.ByteArray()))
+		} else {
+			p.groupSize += 8
+		}
+	}
+	if _, err := p.writer.WriteRows(p.rows); err != nil {
+		return err
+	}
+	// the row group is flushed by the writer when it reaches PARQUET_ROW_GROUP_ROWS
+	if p.groupRows++; p.groupRows >= PARQUET_ROW_GROUP_ROWS {
+		p.groupRows, p.groupSize = 0, 0
+	} else if p.groupSize >= PARQUET_ROW_GROUP_BYTES {
+		p.groupRows, p.groupSize = 0, 0
+		return p.writer.Flush()
+	}
+	return nil
+}
+
+// Size returns the bytes written and the bytes of the buffered values before compression
+func (p *parquetWriter) Size() int64 {
+	return p.output.count + p.groupSize
+}
+
+// Close writes the buffered rows and the footer, the underlying writer is not closed
+func (p *parquetWriter) Close() error {
+	return p.writer.Close()
+}
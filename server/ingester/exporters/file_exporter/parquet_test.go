@@ -0,0 +1,195 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package file_exporter
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/deepflowio/deepflow/server/ingester/exporters/common"
+	exporters_cfg "github.com/deepflowio/deepflow/server/ingester/exporters/config"
+)
+
+// readParquet reads the rows of the parquet file back, the null values are absent in the rows
+func readParquet(t *testing.T, data []byte) []map[string]parquet.Value {
+	reader := parquet.NewReader(bytes.NewReader(data))
+	defer reader.Close()
+	columns := reader.Schema().Columns()
+
+	rows := []map[string]parquet.Value{}
+	buffer := make([]parquet.Row, 2)
+	for {
+		n, err := reader.ReadRows(buffer)
+		for _, row := range buffer[:n] {
+			values := map[string]parquet.Value{}
+			for _, v := range row {
+				if !v.IsNull() {
+					values[columns[v.Column()][0]] = v
+				}
+			}
+			rows = append(rows, values)
+		}
+		if err == io.EOF {
+			return rows
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParquetWriter(t *testing.T) {
+	columns := []common.ExportColumn{
+		{Name: "datasource", Kind: common.ColumnString},
+		{Name: "byte", Kind: common.ColumnUint64},
+		{Name: "rtt", Kind: common.ColumnFloat64},
+		{Name: "offset", Kind: common.ColumnInt64},
+		{Name: "k8s_label_names", Kind: common.ColumnJson},
+	}
+	rows := []common.ExportRow{
+		{
+			{Name: "datasource", Value: "flow_log.l4_flow_log"},
+			{Name: "byte", Value: uint64(10)},
+			{Name: "rtt", Value: 1.5},
+			{Name: "offset", Value: int64(-3)},
+			{Name: "k8s_label_names", Value: []string{"app"}},
+		},
+		{
+			{Name: "datasource", Value: "flow_log.l4_flow_log"},
+			{Name: "byte", Value: uint64(math.MaxUint64)},
+			{Name: "unknown", Value: int64(1)},
+		},
+		{
+			{Name: "datasource", Value: `flow_log.l4_flow_log"`},
+			{Name: "rtt", Value: "NaN"},
+			{Name: "offset", Value: uint64(7)},
+		},
+	}
+
+	for _, compression := range []string{
+		exporters_cfg.FILE_COMPRESSION_NONE,
+		exporters_cfg.FILE_COMPRESSION_SNAPPY,
+		exporters_cfg.FILE_COMPRESSION_GZIP,
+		exporters_cfg.FILE_COMPRESSION_ZSTD,
+	} {
+		buf := &bytes.Buffer{}
+		p, err := newParquetWriter(buf, columns, compression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range rows {
+			if err := p.WriteRow(row); err != nil {
+				t.Fatalf("write row %v failed: %s", row, err)
+			}
+		}
+		if err := p.WriteRow(`{"datasource":"flow_log.l4_flow_log"}`); err == nil {
+			t.Errorf("write json row is not failed")
+		}
+		if p.Size() == 0 {
+			t.Errorf("size of the buffered rows is 0")
+		}
+		if err := p.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		file, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatalf("open %s parquet file failed: %s", compression, err)
+		}
+		for _, c := range columns {
+			leaf, ok := file.Schema().Lookup(c.Name)
+			if !ok {
+				t.Fatalf("column %s is not found", c.Name)
+			}
+			if !leaf.Node.Optional() {
+				t.Errorf("column %s is not optional", c.Name)
+			}
+		}
+		if leaf, _ := file.Schema().Lookup("byte"); leaf.Node.Type().LogicalType().Integer.IsSigned {
+			t.Errorf("column byte is signed")
+		}
+
+		got := readParquet(t, buf.Bytes())
+		if len(got) != len(rows) {
+			t.Fatalf("%d rows are read from %s parquet file, expected %d", len(got), compression, len(rows))
+		}
+		if v := got[0]["datasource"].String(); v != "flow_log.l4_flow_log" {
+			t.Errorf("datasource is %s", v)
+		}
+		if v := got[0]["byte"].Uint64(); v != 10 {
+			t.Errorf("byte is %d", v)
+		}
+		if v := got[0]["rtt"].Double(); v != 1.5 {
+			t.Errorf("rtt is %v", v)
+		}
+		if v := got[0]["offset"].Int64(); v != -3 {
+			t.Errorf("offset is %d", v)
+		}
+		if v := got[0]["k8s_label_names"].String(); v != `["app"]` {
+			t.Errorf("k8s_label_names is %s", v)
+		}
+		if v := got[1]["byte"].Uint64(); v != math.MaxUint64 {
+			t.Errorf("byte is %d", v)
+		}
+		if len(got[1]) != 2 {
+			t.Errorf("unexpected values %v", got[1])
+		}
+		// the value which does not match the type of the column is null
+		if _, ok := got[2]["rtt"]; ok || got[2]["offset"].Int64() != 7 || got[2]["datasource"].String() != `flow_log.l4_flow_log"` {
+			t.Errorf("unexpected values %v", got[2])
+		}
+	}
+}
+
+func TestParquetRowGroups(t *testing.T) {
+	columns := []common.ExportColumn{{Name: "id", Kind: common.ColumnInt64}}
+	buf := &bytes.Buffer{}
+	p, err := newParquetWriter(buf, columns, exporters_cfg.FILE_COMPRESSION_ZSTD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := PARQUET_ROW_GROUP_ROWS + 10
+	for i := 0; i < count; i++ {
+		if err := p.WriteRow(common.ExportRow{{Name: "id", Value: int64(i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(file.RowGroups()); n != 2 {
+		t.Errorf("%d row groups are written, expected 2", n)
+	}
+	got := readParquet(t, buf.Bytes())
+	if len(got) != count {
+		t.Fatalf("%d rows are read, expected %d", len(got), count)
+	}
+	for i, row := range got {
+		if row["id"].Int64() != int64(i) {
+			t.Fatalf("row %d is %v", i, row)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package file_exporter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+
+	exporters_cfg "github.com/deepflowio/deepflow/server/ingester/exporters/config"
+)
+
+const (
+	S3_SERVICE             = "s3"
+	S3_UPLOAD_RETRY_WAIT   = 5 * time.Second
+	S3_ERROR_BODY_MAX_SIZE = 512
+)
+
+// s3Uploader puts the finished files to the S3 compatible storage (AWS S3, MinIO, ...) by the path style url
+// '<endpoint>/<bucket>/<prefix><relative path of the file>', the requests are signed by AWS signature v4
+type s3Uploader struct {
+	cfg         *exporters_cfg.FileS3
+	baseDir     string
+	client      *http.Client
+	signer      *v4.Signer
+	credentials aws.Credentials
+}
+
+func newS3Uploader(cfg *exporters_cfg.FileS3, baseDir string) *s3Uploader {
+	return &s3Uploader{
+		cfg:     cfg,
+		baseDir: baseDir,
+		client:  &http.Client{Timeout: time.Duration(cfg.Timeout) * time.Second},
+		// S3 does not need to escape the path again
+		signer: v4.NewSigner(func(o *v4.SignerOptions) { o.DisableURIPathEscaping = true }),
+		credentials: aws.Credentials{
+			AccessKeyID:     cfg.AccessKey,
+			SecretAccessKey: cfg.SecretKey,
+		},
+	}
+}
+
+func (u *s3Uploader) objectURL(filePath string) (string, error) {
+	rel, err := filepath.Rel(u.baseDir, filePath)
+	if err != nil {
+		return "", err
+	}
+	segments := strings.Split(path.Join(u.cfg.Bucket, u.cfg.Prefix+filepath.ToSlash(rel)), "/")
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+	return strings.TrimRight(u.cfg.Endpoint, "/") + "/" + strings.Join(segments, "/"), nil
+}
+
+func (u *s3Uploader) upload(filePath string) error {
+	objectURL, err := u.objectURL(filePath)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	payloadHash := hex.EncodeToString(hash.Sum(nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.cfg.Timeout)*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, objectURL, file)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if err := u.signer.SignHTTP(ctx, u.credentials, req, payloadHash, S3_SERVICE, u.cfg.Region, time.Now()); err != nil {
+		return err
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, S3_ERROR_BODY_MAX_SIZE))
+		return fmt.Errorf("put %s failed, status: %s, response: %s", objectURL, resp.Status, body)
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// Upload puts the file with retries, and deletes the local file after uploading if configured
+func (u *s3Uploader) Upload(filePath string) error {
+	var err error
+	for i := 0; i < u.cfg.RetryMax; i++ {
+		if i > 0 {
+			time.Sleep(S3_UPLOAD_RETRY_WAIT)
+		}
+		if err = u.upload(filePath); err == nil || os.IsNotExist(err) {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if u.cfg.DeleteAfterUpload {
+		return os.Remove(filePath)
+	}
+	return nil
+}
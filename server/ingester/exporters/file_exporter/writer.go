@@ -0,0 +1,275 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package file_exporter
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/deepflowio/deepflow/server/ingester/exporters/common"
+	exporters_cfg "github.com/deepflowio/deepflow/server/ingester/exporters/config"
+)
+
+const (
+	TMP_FILE_SUFFIX   = ".tmp"
+	FILE_TIME_FORMAT  = "20060102150405"
+	WRITE_BUFFER_SIZE = 256 << 10
+)
+
+type rowWriter interface {
+	WriteRow(row interface{}) error
+	Size() int64 // the bytes written before compression
+	Close() error
+}
+
+type ndjsonWriter struct {
+	buffer     *bufio.Writer
+	compressor io.WriteCloser
+	size       int64
+}
+
+func newNdjsonWriter(w io.Writer, compression string) (*ndjsonWriter, error) {
+	n := &ndjsonWriter{}
+	switch compression {
+	case exporters_cfg.FILE_COMPRESSION_GZIP:
+		n.compressor = gzip.NewWriter(w)
+	case exporters_cfg.FILE_COMPRESSION_ZSTD:
+		encoder, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		n.compressor = encoder
+	}
+	if n.compressor != nil {
+		w = n.compressor
+	}
+	n.buffer = bufio.NewWriterSize(w, WRITE_BUFFER_SIZE)
+	return n, nil
+}
+
+// WriteRow appends a row encoded as json object
+func (n *ndjsonWriter) WriteRow(r interface{}) error {
+	row, ok := r.(string)
+	if !ok {
+		return fmt.Errorf("ndjson writer does not support row type %T", r)
+	}
+	if _, err := n.buffer.WriteString(row); err != nil {
+		return err
+	}
+	n.size += int64(len(row)) + 1
+	return n.buffer.WriteByte('\n')
+}
+
+func (n *ndjsonWriter) Size() int64 {
+	return n.size
+}
+
+// Close flushes the rows, the underlying writer is not closed
+func (n *ndjsonWriter) Close() error {
+	if err := n.buffer.Flush(); err != nil {
+		return err
+	}
+	if n.compressor != nil {
+		return n.compressor.Close()
+	}
+	return nil
+}
+
+func fileExtension(cfg *exporters_cfg.FileConfig) string {
+	if cfg.Format == exporters_cfg.FILE_FORMAT_PARQUET {
+		return ".parquet"
+	}
+	switch cfg.Compression {
+	case exporters_cfg.FILE_COMPRESSION_GZIP:
+		return ".ndjson.gz"
+	case exporters_cfg.FILE_COMPRESSION_ZSTD:
+		return ".ndjson.zst"
+	default:
+		return ".ndjson"
+	}
+}
+
+// rotatingFile writes the rows of a data source to the file '<directory>/<datasource>/<datasource>.<time>.<name>.<seq><ext>',
+// the file is named with suffix '.tmp' before it is finished
+type rotatingFile struct {
+	cfg        *exporters_cfg.FileConfig
+	dir        string
+	dataSource string
+	name       string
+	columns    []common.ExportColumn
+	seq        int
+
+	file       *os.File
+	writer     rowWriter
+	path       string
+	createTime time.Time
+}
+
+func newRotatingFile(cfg *exporters_cfg.FileConfig, dataSource, name string, columns []common.ExportColumn) *rotatingFile {
+	return &rotatingFile{
+		cfg:        cfg,
+		dir:        filepath.Join(cfg.Directory, dataSource),
+		dataSource: dataSource,
+		name:       name,
+		columns:    columns,
+	}
+}
+
+func (r *rotatingFile) open(now time.Time) error {
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return err
+	}
+	r.seq++
+	path := filepath.Join(r.dir, fmt.Sprintf("%s.%s.%s.%d%s", r.dataSource, now.Format(FILE_TIME_FORMAT), r.name, r.seq, fileExtension(r.cfg)))
+	file, err := os.OpenFile(path+TMP_FILE_SUFFIX, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	var writer rowWriter
+	if r.cfg.Format == exporters_cfg.FILE_FORMAT_PARQUET {
+		writer, err = newParquetWriter(file, r.columns, r.cfg.Compression)
+	} else {
+		writer, err = newNdjsonWriter(file, r.cfg.Compression)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(path + TMP_FILE_SUFFIX)
+		return err
+	}
+	r.file, r.writer, r.path, r.createTime = file, writer, path, now
+	return nil
+}
+
+func (r *rotatingFile) WriteRow(row interface{}, now time.Time) error {
+	if r.writer == nil {
+		if err := r.open(now); err != nil {
+			return err
+		}
+	}
+	return r.writer.WriteRow(row)
+}
+
+// NeedRotate returns whether the file reaches the rotate interval or size
+func (r *rotatingFile) NeedRotate(now time.Time) bool {
+	if r.writer == nil {
+		return false
+	}
+	return now.Sub(r.createTime) >= time.Duration(r.cfg.RotateInterval)*time.Second ||
+		r.writer.Size() >= int64(r.cfg.RotateSizeMB)<<20
+}
+
+// Finish closes the file and removes the '.tmp' suffix, returns the path of the finished file
+func (r *rotatingFile) Finish() (string, error) {
+	if r.writer == nil {
+		return "", nil
+	}
+	file, writer, path := r.file, r.writer, r.path
+	r.file, r.writer, r.path = nil, nil, ""
+
+	err := writer.Close()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path + TMP_FILE_SUFFIX)
+		return "", err
+	}
+	if err := os.Rename(path+TMP_FILE_SUFFIX, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// exportedFileRegexp matches the name of the finished file '<datasource>.<time>.<name>.<seq><ext>', the name is
+// '<exporter index>-<queue id>'
+var exportedFileRegexp = regexp.MustCompile(`^(.+)\.\d{14}\.\d+-\d+\.\d+(\.parquet|\.ndjson|\.ndjson\.gz|\.ndjson\.zst)$`)
+
+// isExportedFile returns whether the path is '<directory>/<datasource>/<datasource>.<time>.<name>.<seq><ext>', so
+// that the other files in the directory are never deleted
+func isExportedFile(directory, path string) bool {
+	dir, base := filepath.Split(path)
+	dataSourceDir := filepath.Clean(dir)
+	if filepath.Dir(dataSourceDir) != filepath.Clean(directory) {
+		return false
+	}
+	matches := exportedFileRegexp.FindStringSubmatch(base)
+	return matches != nil && matches[1] == filepath.Base(dataSourceDir)
+}
+
+type fileInfo struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// cleanExpiredFiles deletes the finished files which are older than the retention time, then deletes the oldest
+// files until the total size is not larger than the retention size. The files pending upload are skipped.
+// Returns the count of deleted files.
+func cleanExpiredFiles(cfg *exporters_cfg.FileConfig, now time.Time, pendingUpload func(path string) bool) (int, error) {
+	var files []fileInfo
+	var totalSize int64
+	err := filepath.Walk(cfg.Directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || !isExportedFile(cfg.Directory, path) {
+			return nil
+		}
+		files = append(files, fileInfo{path: path, size: info.Size(), modTime: info.ModTime()})
+		totalSize += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	deleted := 0
+	expireTime := now.Add(-time.Duration(cfg.RetentionHours) * time.Hour)
+	retentionSize := int64(cfg.RetentionSizeMB) << 20
+	for _, f := range files {
+		expired := cfg.RetentionHours > 0 && f.modTime.Before(expireTime)
+		oversize := cfg.RetentionSizeMB > 0 && totalSize > retentionSize
+		if !expired && !oversize {
+			break
+		}
+		if pendingUpload != nil && pendingUpload(f.path) {
+			continue
+		}
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return deleted, err
+		}
+		totalSize -= f.size
+		deleted++
+	}
+	return deleted, nil
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package file_exporter
+
+import (
+	"bufio"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	exporters_cfg "github.com/deepflowio/deepflow/server/ingester/exporters/config"
+)
+
+func TestRotatingFile(t *testing.T) {
+	cfg := &exporters_cfg.FileConfig{Directory: t.TempDir(), Format: exporters_cfg.FILE_FORMAT_NDJSON, Compression: exporters_cfg.FILE_COMPRESSION_GZIP}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	f := newRotatingFile(cfg, "flow_log.l7_flow_log", "0-1", nil)
+	for i := 0; i < 3; i++ {
+		if err := f.WriteRow(`{"datasource":"flow_log.l7_flow_log"}`, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if f.NeedRotate(now.Add(time.Second)) || !f.NeedRotate(now.Add(time.Duration(cfg.RotateInterval)*time.Second)) {
+		t.Errorf("rotation by interval is wrong")
+	}
+	path, err := f.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(path) != "flow_log.l7_flow_log."+now.Format(FILE_TIME_FORMAT)+".0-1.1.ndjson.gz" {
+		t.Errorf("unexpected file %s", path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := 0
+	for scanner := bufio.NewScanner(reader); scanner.Scan(); lines++ {
+	}
+	if lines != 3 {
+		t.Errorf("%d lines are written, expected 3", lines)
+	}
+	if _, err := os.Stat(path + TMP_FILE_SUFFIX); !os.IsNotExist(err) {
+		t.Errorf("tmp file is not renamed")
+	}
+}
+
+func TestCleanExpiredFiles(t *testing.T) {
+	cfg := &exporters_cfg.FileConfig{Directory: t.TempDir(), RetentionHours: 1, RetentionSizeMB: 1}
+	now := time.Now()
+	write := func(name string, size int, age time.Duration) string {
+		path := filepath.Join(cfg.Directory, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, now.Add(-age), now.Add(-age))
+		return path
+	}
+	expired := write("event.event/event.event.20240101000000.0-1.1.ndjson", 10, 2*time.Hour)
+	pending := write("event.event/event.event.20240101000000.0-1.2.ndjson.gz", 10, 2*time.Hour)
+	oldest := write("event.event/event.event.20240101000000.0-1.3.parquet", 600<<10, 30*time.Minute)
+	newest := write("event.event/event.event.20240101000000.0-1.4.ndjson.zst", 600<<10, time.Minute)
+	writing := write("event.event/event.event.20240101000000.0-1.5.ndjson"+TMP_FILE_SUFFIX, 10, 3*time.Hour)
+	// the files not written by the exporter are never deleted
+	others := []string{
+		write("event.event/notes.txt", 10, 3*time.Hour),
+		write("event.event.20240101000000.0-1.1.ndjson", 10, 3*time.Hour),
+		write("event.event/flow_log.l7_flow_log.20240101000000.0-1.1.ndjson", 10, 3*time.Hour),
+		write("backup/event.event/event.event.20240101000000.0-1.1.ndjson", 10, 3*time.Hour),
+	}
+
+	deleted, err := cleanExpiredFiles(cfg, now, func(path string) bool { return path == pending })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Errorf("%d files are deleted, expected 2", deleted)
+	}
+	exists := map[string]bool{expired: false, pending: true, oldest: false, newest: true, writing: true}
+	for _, path := range others {
+		exists[path] = true
+	}
+	for path, exist := range exists {
+		if _, err := os.Stat(path); (err == nil) != exist {
+			t.Errorf("file %s exists: %v, expected %v", path, err == nil, exist)
+		}
+	}
+}
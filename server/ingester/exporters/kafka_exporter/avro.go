@@ -0,0 +1,391 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka_exporter
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The avro binary encoding, see https://avro.apache.org/docs/1.11.1/specification/#binary-encoding
+// Supports the primitive types, record, enum, array, map, union and the references to the named types.
+
+const (
+	AVRO_NULL    = "null"
+	AVRO_BOOLEAN = "boolean"
+	AVRO_INT     = "int"
+	AVRO_LONG    = "long"
+	AVRO_FLOAT   = "float"
+	AVRO_DOUBLE  = "double"
+	AVRO_BYTES   = "bytes"
+	AVRO_STRING  = "string"
+	AVRO_RECORD  = "record"
+	AVRO_ENUM    = "enum"
+	AVRO_ARRAY   = "array"
+	AVRO_MAP     = "map"
+	AVRO_UNION   = "union"
+
+	CONFLUENT_MAGIC_BYTE = 0
+)
+
+type avroField struct {
+	name   string
+	schema *avroSchema
+}
+
+type avroSchema struct {
+	typ      string
+	name     string         // the full name of record and enum
+	fields   []avroField    // record
+	symbols  map[string]int // enum
+	items    *avroSchema    // array items or map values
+	branches []*avroSchema  // union
+}
+
+func loadAvroSchema(file string) (*avroSchema, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return parseAvroSchema(content)
+}
+
+func parseAvroSchema(content []byte) (*avroSchema, error) {
+	var v interface{}
+	if err := json.Unmarshal(content, &v); err != nil {
+		return nil, err
+	}
+	p := &avroParser{names: make(map[string]*avroSchema)}
+	return p.parse(v, "")
+}
+
+// avroParser parses the schema, the named types are registered by the full name before their fields are parsed,
+// so that they can be referenced by the later and the nested fields
+type avroParser struct {
+	names map[string]*avroSchema
+}
+
+func avroFullName(name, namespace string) string {
+	if namespace == "" || strings.Contains(name, ".") {
+		return name
+	}
+	return namespace + "." + name
+}
+
+func avroNamespace(fullName string) string {
+	if pos := strings.LastIndexByte(fullName, '.'); pos >= 0 {
+		return fullName[:pos]
+	}
+	return ""
+}
+
+func (p *avroParser) parse(v interface{}, namespace string) (*avroSchema, error) {
+	switch t := v.(type) {
+	case string:
+		switch t {
+		case AVRO_NULL, AVRO_BOOLEAN, AVRO_INT, AVRO_LONG, AVRO_FLOAT, AVRO_DOUBLE, AVRO_BYTES, AVRO_STRING:
+			return &avroSchema{typ: t}, nil
+		}
+		if s, ok := p.names[avroFullName(t, namespace)]; ok {
+			return s, nil
+		}
+		if s, ok := p.names[t]; ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("unsupport avro type %s", t)
+	case []interface{}:
+		s := &avroSchema{typ: AVRO_UNION}
+		for _, b := range t {
+			branch, err := p.parse(b, namespace)
+			if err != nil {
+				return nil, err
+			}
+			s.branches = append(s.branches, branch)
+		}
+		return s, nil
+	case map[string]interface{}:
+		typ, _ := t["type"].(string)
+		s := &avroSchema{typ: typ}
+		switch typ {
+		case AVRO_RECORD, AVRO_ENUM:
+			name, _ := t["name"].(string)
+			if name == "" {
+				return nil, fmt.Errorf("avro %s has no name", typ)
+			}
+			if ns, ok := t["namespace"].(string); ok {
+				namespace = ns
+			}
+			s.name = avroFullName(name, namespace)
+			if _, ok := p.names[s.name]; ok {
+				return nil, fmt.Errorf("avro type %s is redefined", s.name)
+			}
+			p.names[s.name] = s
+			namespace = avroNamespace(s.name)
+		}
+		switch typ {
+		case AVRO_RECORD:
+			fields, _ := t["fields"].([]interface{})
+			for _, f := range fields {
+				fm, ok := f.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("invalid avro field %v", f)
+				}
+				name, _ := fm["name"].(string)
+				fs, err := p.parse(fm["type"], namespace)
+				if err != nil {
+					return nil, fmt.Errorf("avro field %s: %s", name, err)
+				}
+				s.fields = append(s.fields, avroField{name: name, schema: fs})
+			}
+		case AVRO_ENUM:
+			symbols, _ := t["symbols"].([]interface{})
+			s.symbols = make(map[string]int, len(symbols))
+			for i, symbol := range symbols {
+				if str, ok := symbol.(string); ok {
+					s.symbols[str] = i
+				}
+			}
+		case AVRO_ARRAY, AVRO_MAP:
+			key := "items"
+			if typ == AVRO_MAP {
+				key = "values"
+			}
+			items, err := p.parse(t[key], namespace)
+			if err != nil {
+				return nil, err
+			}
+			s.items = items
+		default:
+			// such as {"type": "long", "logicalType": "timestamp-micros"}
+			return p.parse(typ, namespace)
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("invalid avro schema %v", v)
+}
+
+func appendAvroLong(dst []byte, v int64) []byte {
+	return binary.AppendVarint(dst, v)
+}
+
+func appendAvroString(dst []byte, v string) []byte {
+	dst = appendAvroLong(dst, int64(len(v)))
+	return append(dst, v...)
+}
+
+// Encode appends the avro binary of the value, the record is map[string]interface{}, the other values are the
+// values of common.ExportRow: string, int64, uint64, float64, []string or []float64.
+// The missing value is encoded as null if the schema is nullable, otherwise the zero value.
+func (s *avroSchema) Encode(dst []byte, v interface{}) ([]byte, error) {
+	switch s.typ {
+	case AVRO_NULL:
+		return dst, nil
+	case AVRO_BOOLEAN:
+		b := false
+		switch t := v.(type) {
+		case bool:
+			b = t
+		case string:
+			b, _ = strconv.ParseBool(t)
+		default:
+			b = avroFloat64(v) != 0
+		}
+		if b {
+			return append(dst, 1), nil
+		}
+		return append(dst, 0), nil
+	case AVRO_INT, AVRO_LONG:
+		return appendAvroLong(dst, avroInt64(v)), nil
+	case AVRO_FLOAT:
+		return binary.LittleEndian.AppendUint32(dst, math.Float32bits(float32(avroFloat64(v)))), nil
+	case AVRO_DOUBLE:
+		return binary.LittleEndian.AppendUint64(dst, math.Float64bits(avroFloat64(v))), nil
+	case AVRO_STRING, AVRO_BYTES:
+		return appendAvroString(dst, avroString(v)), nil
+	case AVRO_ENUM:
+		symbol := avroString(v)
+		i, ok := s.symbols[symbol]
+		if !ok {
+			return nil, fmt.Errorf("unknown symbol '%s' of avro enum %s", symbol, s.name)
+		}
+		return appendAvroLong(dst, int64(i)), nil
+	case AVRO_RECORD:
+		m, _ := v.(map[string]interface{})
+		var err error
+		for i := range s.fields {
+			if dst, err = s.fields[i].schema.Encode(dst, m[s.fields[i].name]); err != nil {
+				return nil, fmt.Errorf("avro field %s: %s", s.fields[i].name, err)
+			}
+		}
+		return dst, nil
+	case AVRO_ARRAY:
+		items := avroItems(v)
+		if len(items) > 0 {
+			dst = appendAvroLong(dst, int64(len(items)))
+			var err error
+			for _, item := range items {
+				if dst, err = s.items.Encode(dst, item); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return append(dst, 0), nil
+	case AVRO_MAP:
+		m, _ := v.(map[string]interface{})
+		if len(m) > 0 {
+			dst = appendAvroLong(dst, int64(len(m)))
+			var err error
+			for key, value := range m {
+				dst = appendAvroString(dst, key)
+				if dst, err = s.items.Encode(dst, value); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return append(dst, 0), nil
+	case AVRO_UNION:
+		i := s.unionBranch(v)
+		if i < 0 {
+			return nil, fmt.Errorf("no union branch matches value %v", v)
+		}
+		dst = appendAvroLong(dst, int64(i))
+		return s.branches[i].Encode(dst, v)
+	}
+	return nil, fmt.Errorf("unsupport avro type %s", s.typ)
+}
+
+// unionBranch returns the first branch which matches the type of the value, or the first non-null branch whose
+// zero value is encoded
+func (s *avroSchema) unionBranch(v interface{}) int {
+	var types []string
+	switch v.(type) {
+	case nil:
+		types = []string{AVRO_NULL}
+	case bool:
+		types = []string{AVRO_BOOLEAN}
+	case int64, uint64:
+		types = []string{AVRO_LONG, AVRO_INT, AVRO_DOUBLE, AVRO_FLOAT}
+	case float64:
+		types = []string{AVRO_DOUBLE, AVRO_FLOAT, AVRO_LONG, AVRO_INT}
+	case string:
+		types = []string{AVRO_STRING, AVRO_BYTES, AVRO_ENUM}
+	case []string, []float64, []interface{}:
+		types = []string{AVRO_ARRAY}
+	case map[string]interface{}:
+		types = []string{AVRO_MAP, AVRO_RECORD}
+	}
+	for _, typ := range types {
+		for i, b := range s.branches {
+			if b.typ == typ {
+				return i
+			}
+		}
+	}
+	for i, b := range s.branches {
+		if b.typ != AVRO_NULL {
+			return i
+		}
+	}
+	return -1
+}
+
+func avroItems(v interface{}) []interface{} {
+	switch t := v.(type) {
+	case []interface{}:
+		return t
+	case []string:
+		items := make([]interface{}, len(t))
+		for i := range t {
+			items[i] = t[i]
+		}
+		return items
+	case []float64:
+		items := make([]interface{}, len(t))
+		for i := range t {
+			items[i] = t[i]
+		}
+		return items
+	}
+	return nil
+}
+
+func avroInt64(v interface{}) int64 {
+	switch t := v.(type) {
+	case int64:
+		return t
+	case uint64:
+		return int64(t)
+	case float64:
+		return int64(t)
+	case bool:
+		if t {
+			return 1
+		}
+		return 0
+	case string:
+		if i, err := strconv.ParseInt(t, 10, 64); err == nil {
+			return i
+		}
+		if u, err := strconv.ParseUint(t, 10, 64); err == nil {
+			return int64(u)
+		}
+		f, _ := strconv.ParseFloat(t, 64)
+		return int64(f)
+	}
+	return 0
+}
+
+func avroFloat64(v interface{}) float64 {
+	switch t := v.(type) {
+	case int64:
+		return float64(t)
+	case uint64:
+		return float64(t)
+	case float64:
+		return t
+	case bool:
+		if t {
+			return 1
+		}
+	case string:
+		f, _ := strconv.ParseFloat(t, 64)
+		return f
+	}
+	return 0
+}
+
+func avroString(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case int64:
+		return strconv.FormatInt(t, 10)
+	case uint64:
+		return strconv.FormatUint(t, 10)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
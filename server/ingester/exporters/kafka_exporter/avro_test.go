@@ -0,0 +1,96 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka_exporter
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAvroEncode(t *testing.T) {
+	schema, err := parseAvroSchema([]byte(`{
+		"type": "record", "name": "l7_flow_log",
+		"fields": [
+			{"name": "datasource", "type": "string"},
+			{"name": "response_duration", "type": "long"},
+			{"name": "rrt", "type": ["null", "double"]},
+			{"name": "app_service", "type": ["null", "string"]},
+			{"name": "k8s_label_names", "type": {"type": "array", "items": "string"}},
+			{"name": "ok", "type": "boolean"}
+		]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := map[string]interface{}{
+		"datasource":        "ab",
+		"response_duration": int64(-2),
+		"rrt":               float64(0.5),
+		"k8s_label_names":   []string{"x"},
+		"ok":                int64(1),
+	}
+	encoded, err := schema.Encode(nil, row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{
+		4, 'a', 'b', // string
+		3,                               // long -2 in zigzag
+		2, 0, 0, 0, 0, 0, 0, 0xe0, 0x3f, // union branch 1, double 0.5
+		0,            // union branch 0, null
+		2, 2, 'x', 0, // array of 1 item
+		1, // boolean
+	}
+	if !bytes.Equal(encoded, expected) {
+		t.Errorf("encoded is %v, expected %v", encoded, expected)
+	}
+
+	if _, err := parseAvroSchema([]byte(`{"type": "record", "name": "r", "fields": [{"name": "a", "type": "fixed"}]}`)); err == nil {
+		t.Errorf("unsupported type is parsed")
+	}
+}
+
+func TestAvroNamedType(t *testing.T) {
+	schema, err := parseAvroSchema([]byte(`{
+		"type": "record", "name": "l7_flow_log", "namespace": "deepflow",
+		"fields": [
+			{"name": "l7_protocol", "type": {"type": "enum", "name": "protocol", "symbols": ["HTTP", "DNS"]}},
+			{"name": "request_protocol", "type": ["null", "protocol"]},
+			{"name": "response_protocol", "type": "deepflow.protocol"}
+		]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := schema.Encode(nil, map[string]interface{}{"l7_protocol": "DNS", "response_protocol": "HTTP"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{
+		2, // enum index 1
+		0, // union branch 0, null
+		0, // enum index 0
+	}
+	if !bytes.Equal(encoded, expected) {
+		t.Errorf("encoded is %v, expected %v", encoded, expected)
+	}
+
+	if _, err := schema.Encode(nil, map[string]interface{}{"l7_protocol": "MySQL"}); err == nil {
+		t.Errorf("unknown enum symbol is encoded")
+	}
+	if _, err := parseAvroSchema([]byte(`{"type": "record", "name": "a", "fields": [{"name": "b", "type": "c"}]}`)); err == nil {
+		t.Errorf("undefined named type is parsed")
+	}
+}
@@ -17,12 +17,16 @@
 package kafka_exporter
 
 import (
+	"crypto/tls"
+	"encoding/binary"
 	"fmt"
 	"strconv"
 	"time"
 
 	"github.com/IBM/sarama"
 	logging "github.com/op/go-logging"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
 
 	ingester_common "github.com/deepflowio/deepflow/server/ingester/common"
 	"github.com/deepflowio/deepflow/server/ingester/exporters/common"
@@ -49,6 +53,9 @@ type KafkaExporter struct {
 	producers            []sarama.SyncProducer
 	universalTagsManager *utag.UniversalTagsManager
 	config               *exporters_cfg.ExporterCfg
+	topic                *template
+	partitionKey         *template
+	avroCodecs           [exporters_cfg.MAX_DATASOURCE_ID]*avroCodec
 	counter              *Counter
 	lastCounter          Counter
 	running              bool
@@ -64,6 +71,12 @@ type Counter struct {
 	DropCounter          int64 `statsd:"drop-count"`
 	DropBatchCounter     int64 `statsd:"drop-batch-count"`
 	DropNoTraceIDCounter int64 `statsd:"drop-no-traceid-count"`
+	EncodeErrCounter     int64 `statsd:"encode-err-count"`
+}
+
+type avroCodec struct {
+	schema   *avroSchema
+	schemaID uint32
 }
 
 func (e *KafkaExporter) GetCounter() interface{} {
@@ -87,8 +100,21 @@ func NewKafkaExporter(index int, config *exporters_cfg.ExporterCfg, universalTag
 		universalTagsManager: universalTagsManager,
 		producers:            make([]sarama.SyncProducer, config.QueueCount),
 		config:               config,
+		topic:                newTemplate(config.Topic),
+		partitionKey:         newTemplate(config.PartitionKey),
 		counter:              &Counter{},
 	}
+	if config.Encoding == exporters_cfg.KAFKA_ENCODING_AVRO {
+		for _, s := range config.AvroSchemas {
+			schema, err := loadAvroSchema(s.SchemaFile)
+			if err != nil {
+				log.Errorf("kafka exporter %d load avro schema %s failed: %s", index, s.SchemaFile, err)
+				continue
+			}
+			dataSourceId, _ := exporters_cfg.ToDataSourceID(s.DataSource)
+			exporter.avroCodecs[dataSourceId] = &avroCodec{schema: schema, schemaID: s.SchemaRegistryID}
+		}
+	}
 	debug.ServerRegisterSimple(ingesterctl.CMD_KAFKA_EXPORTER, exporter)
 	ingester_common.RegisterCountableForIngester("exporter", exporter, stats.OptionStatTags{
 		"type": "kafka", "index": strconv.Itoa(index)})
@@ -133,10 +159,21 @@ func (e *KafkaExporter) newProducer(id int) error {
 	config.Producer.Return.Successes = true
 	config.Producer.Compression = sarama.CompressionSnappy
 
-	config.Net.SASL.Enable = e.config.Sasl.Enabled
-	config.Net.SASL.Mechanism = sarama.SASLTypePlaintext
-	config.Net.SASL.User = e.config.Sasl.Username
-	config.Net.SASL.Password = e.config.Sasl.Password
+	sasl := &e.config.Sasl
+	config.Net.SASL.Enable = sasl.Enabled
+	config.Net.SASL.Mechanism = sarama.SASLMechanism(sasl.Mechanism)
+	config.Net.SASL.User = sasl.Username
+	config.Net.SASL.Password = sasl.Password
+	switch sasl.Mechanism {
+	case sarama.SASLTypeSCRAMSHA256:
+		config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return newScramSHA256Client() }
+	case sarama.SASLTypeSCRAMSHA512:
+		config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return newScramSHA512Client() }
+	}
+	if sasl.Enabled && sasl.SecurityProtocol == exporters_cfg.SecurityProtocolSaslSSL {
+		config.Net.TLS.Enable = true
+		config.Net.TLS.Config = &tls.Config{InsecureSkipVerify: sasl.TLSInsecureSkipVerify}
+	}
 
 	producer, err := sarama.NewSyncProducer(e.config.Endpoints, config)
 	if err != nil {
@@ -165,31 +202,20 @@ func (e *KafkaExporter) queueProcess(queueID int) {
 				continue
 			}
 
-			json, err := exportItem.EncodeTo(exporters_cfg.PROTOCOL_KAFKA, e.universalTagsManager, e.config)
+			msg, err := e.encode(exportItem)
 			if err != nil {
-				if e.counter.DropCounter == 0 {
+				if e.counter.EncodeErrCounter == 0 {
 					log.Warningf("kafka encode failed, err: %s", err)
 				}
+				e.counter.EncodeErrCounter++
 				e.counter.DropCounter++
 				exportItem.Release()
 				continue
 			}
 
-			jsonStr := json.(string)
-			topic := e.config.Topic
-			if topic == "" {
-				topic = exporters_cfg.DataSourceID(exportItem.DataSource()).TopicString()
-			}
-			batch = append(batch,
-				&sarama.ProducerMessage{
-					Topic:     topic,
-					Key:       nil,
-					Value:     sarama.ByteEncoder(utils.Slice(jsonStr)),
-					Timestamp: time.UnixMicro(exportItem.TimestampUs()),
-				},
-			)
+			batch = append(batch, msg)
 			if len(batch) >= e.config.BatchSize {
-				log.Debugf("kafka: %s \n %+v", jsonStr, item)
+				log.Debugf("kafka: %s \n %+v", msg.Topic, item)
 				e.exportBatch(queueID, batch)
 				batch = batch[:0]
 			}
@@ -198,6 +224,90 @@ func (e *KafkaExporter) queueProcess(queueID int) {
 	}
 }
 
+// encode encodes the item as the configured encoding, and routes it to the topic and the partition key rendered from
+// the exported fields
+func (e *KafkaExporter) encode(item common.ExportItem) (*sarama.ProducerMessage, error) {
+	dataSourceId := item.DataSource()
+	dataSource := exporters_cfg.DataSourceID(dataSourceId).String()
+	msg := &sarama.ProducerMessage{
+		Timestamp: time.UnixMicro(item.TimestampUs()),
+	}
+
+	var jsonStr string
+	if e.config.Encoding == exporters_cfg.KAFKA_ENCODING_JSON {
+		encoded, err := item.EncodeTo(exporters_cfg.PROTOCOL_KAFKA, e.universalTagsManager, e.config)
+		if err != nil {
+			return nil, err
+		}
+		jsonStr = encoded.(string)
+	}
+	// the row has the same fields as the json, the numbers keep their types
+	var row common.ExportRow
+	if e.topic.HasFields() || e.partitionKey.HasFields() || e.config.Encoding == exporters_cfg.KAFKA_ENCODING_AVRO {
+		encoded, err := item.EncodeTo(exporters_cfg.PROTOCOL_FILE, e.universalTagsManager, e.config)
+		if err != nil {
+			return nil, err
+		}
+		row = encoded.(common.ExportRow)
+	}
+
+	switch e.config.Encoding {
+	case exporters_cfg.KAFKA_ENCODING_PROTOBUF:
+		encoded, err := item.EncodeTo(exporters_cfg.PROTOCOL_OTLP, e.universalTagsManager, e.config)
+		if err != nil {
+			return nil, err
+		}
+		var value []byte
+		switch rsSlice := encoded.(type) {
+		case ptrace.ResourceSpansSlice:
+			traces := ptrace.NewTraces()
+			rsSlice.MoveAndAppendTo(traces.ResourceSpans())
+			value, err = (&ptrace.ProtoMarshaler{}).MarshalTraces(traces)
+		case plog.ResourceLogsSlice:
+			logs := plog.NewLogs()
+			rsSlice.MoveAndAppendTo(logs.ResourceLogs())
+			value, err = (&plog.ProtoMarshaler{}).MarshalLogs(logs)
+		default:
+			err = fmt.Errorf("unsupport otlp encoded data %T of %s", encoded, dataSource)
+		}
+		if err != nil {
+			return nil, err
+		}
+		msg.Value = sarama.ByteEncoder(value)
+	case exporters_cfg.KAFKA_ENCODING_AVRO:
+		codec := e.avroCodecs[dataSourceId]
+		if codec == nil {
+			return nil, fmt.Errorf("avro schema of %s is not configured", dataSource)
+		}
+		var value []byte
+		if codec.schemaID != 0 {
+			value = append(value, CONFLUENT_MAGIC_BYTE)
+			value = binary.BigEndian.AppendUint32(value, codec.schemaID)
+		}
+		record := make(map[string]interface{}, len(row))
+		for i := range row {
+			record[row[i].Name] = row[i].Value
+		}
+		value, err := codec.schema.Encode(value, record)
+		if err != nil {
+			return nil, err
+		}
+		msg.Value = sarama.ByteEncoder(value)
+	default:
+		msg.Value = sarama.ByteEncoder(utils.Slice(jsonStr))
+	}
+
+	if e.topic.IsEmpty() {
+		msg.Topic = exporters_cfg.DataSourceID(dataSourceId).TopicString()
+	} else {
+		msg.Topic = e.topic.Render(dataSource, row, true)
+	}
+	if !e.partitionKey.IsEmpty() {
+		msg.Key = sarama.StringEncoder(e.partitionKey.Render(dataSource, row, false))
+	}
+	return msg, nil
+}
+
 func (e *KafkaExporter) exportBatch(queueID int, batch []*sarama.ProducerMessage) {
 	defer func() {
 		if r := recover(); r != nil {
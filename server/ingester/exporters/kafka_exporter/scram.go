@@ -0,0 +1,150 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka_exporter
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+const SCRAM_NONCE_SIZE = 24
+
+// scramClient implements sarama.SCRAMClient by RFC 5802, the user name is not normalized by SASLprep
+type scramClient struct {
+	newHash func() hash.Hash
+	nonce   string // for test, generated randomly if it is empty
+
+	userName, password string
+	clientFirstBare    string
+	serverSignature    []byte
+	step               int
+	done               bool
+}
+
+func newScramSHA256Client() *scramClient {
+	return &scramClient{newHash: sha256.New}
+}
+
+func newScramSHA512Client() *scramClient {
+	return &scramClient{newHash: sha512.New}
+}
+
+func (c *scramClient) Begin(userName, password, authzID string) error {
+	c.userName, c.password = userName, password
+	c.step, c.done = 0, false
+	if c.nonce == "" {
+		b := make([]byte, SCRAM_NONCE_SIZE)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		c.nonce = base64.RawStdEncoding.EncodeToString(b)
+	}
+	return nil
+}
+
+func (c *scramClient) Step(challenge string) (string, error) {
+	c.step++
+	switch c.step {
+	case 1:
+		c.clientFirstBare = "n=" + scramEscape(c.userName) + ",r=" + c.nonce
+		return "n,," + c.clientFirstBare, nil
+	case 2:
+		return c.clientFinal(challenge)
+	case 3:
+		c.done = true
+		return "", c.verifyServerFinal(challenge)
+	}
+	return "", fmt.Errorf("unexpected scram step %d", c.step)
+}
+
+func (c *scramClient) Done() bool {
+	return c.done
+}
+
+func (c *scramClient) hmac(key []byte, data string) []byte {
+	h := hmac.New(c.newHash, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func (c *scramClient) clientFinal(serverFirst string) (string, error) {
+	attrs := scramAttributes(serverFirst)
+	nonce, salt64, iterStr := attrs["r"], attrs["s"], attrs["i"]
+	if !strings.HasPrefix(nonce, c.nonce) || len(nonce) == len(c.nonce) {
+		return "", fmt.Errorf("invalid scram server nonce %s", nonce)
+	}
+	salt, err := base64.StdEncoding.DecodeString(salt64)
+	if err != nil {
+		return "", fmt.Errorf("invalid scram salt %s: %s", salt64, err)
+	}
+	iter, err := strconv.Atoi(iterStr)
+	if err != nil || iter <= 0 {
+		return "", fmt.Errorf("invalid scram iteration count %s", iterStr)
+	}
+
+	saltedPassword, err := pbkdf2.Key(c.newHash, c.password, salt, iter, c.newHash().Size())
+	if err != nil {
+		return "", err
+	}
+	clientKey := c.hmac(saltedPassword, "Client Key")
+	h := c.newHash()
+	h.Write(clientKey)
+	storedKey := h.Sum(nil)
+
+	clientFinalWithoutProof := "c=biws,r=" + nonce // biws is base64 of 'n,,'
+	authMessage := c.clientFirstBare + "," + serverFirst + "," + clientFinalWithoutProof
+	clientSignature := c.hmac(storedKey, authMessage)
+	for i := range clientKey {
+		clientKey[i] ^= clientSignature[i]
+	}
+	c.serverSignature = c.hmac(c.hmac(saltedPassword, "Server Key"), authMessage)
+	return clientFinalWithoutProof + ",p=" + base64.StdEncoding.EncodeToString(clientKey), nil
+}
+
+func (c *scramClient) verifyServerFinal(serverFinal string) error {
+	attrs := scramAttributes(serverFinal)
+	if e, ok := attrs["e"]; ok {
+		return fmt.Errorf("scram authentication failed: %s", e)
+	}
+	signature, err := base64.StdEncoding.DecodeString(attrs["v"])
+	if err != nil || !hmac.Equal(signature, c.serverSignature) {
+		return fmt.Errorf("invalid scram server signature %s", attrs["v"])
+	}
+	return nil
+}
+
+func scramAttributes(s string) map[string]string {
+	attrs := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		if len(kv) > 2 && kv[1] == '=' {
+			attrs[kv[:1]] = kv[2:]
+		}
+	}
+	return attrs
+}
+
+func scramEscape(s string) string {
+	return strings.NewReplacer("=", "=3D", ",", "=2C").Replace(s)
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka_exporter
+
+import "testing"
+
+// the example of RFC 7677
+func TestScramSHA256Client(t *testing.T) {
+	c := newScramSHA256Client()
+	c.nonce = "rOprNGfwEbeRWgbNEkqO"
+	if err := c.Begin("user", "pencil", ""); err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		challenge, response string
+	}{
+		{"", "n,,n=user,r=rOprNGfwEbeRWgbNEkqO"},
+		{"r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",
+			"c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="},
+		{"v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=", ""},
+	}
+	for i, s := range steps {
+		if c.Done() {
+			t.Fatalf("scram is done before step %d", i)
+		}
+		response, err := c.Step(s.challenge)
+		if err != nil {
+			t.Fatalf("step %d failed: %s", i, err)
+		}
+		if response != s.response {
+			t.Errorf("response of step %d is %s, expected %s", i, response, s.response)
+		}
+	}
+	if !c.Done() {
+		t.Errorf("scram is not done")
+	}
+
+	c = newScramSHA256Client()
+	c.nonce = "rOprNGfwEbeRWgbNEkqO"
+	c.Begin("user", "pencil", "")
+	c.Step("")
+	c.Step(steps[1].challenge)
+	if _, err := c.Step("v=AAAA"); err == nil {
+		t.Errorf("invalid server signature is accepted")
+	}
+}
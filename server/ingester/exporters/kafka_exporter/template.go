@@ -0,0 +1,139 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka_exporter
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/deepflowio/deepflow/server/ingester/exporters/common"
+)
+
+const (
+	TEMPLATE_DATASOURCE    = "datasource"
+	TEMPLATE_EMPTY_VALUE   = "unknown"
+	TEMPLATE_SERVER_SUFFIX = "_1"
+)
+
+type templateSegment struct {
+	literal string
+	field   string // if it is not empty, the segment is the value of the field
+}
+
+// template renders the string such as 'deepflow.{datasource}.{pod_ns}', the '{field}' is replaced by the value of
+// the exported field. For the data sources with the client and server side fields, '{pod_ns}' is the server side
+// field 'pod_ns_1' if 'pod_ns' does not exist.
+type template struct {
+	segments  []templateSegment
+	hasFields bool // has the fields except 'datasource'
+}
+
+func newTemplate(s string) *template {
+	t := &template{}
+	for len(s) > 0 {
+		start := strings.IndexByte(s, '{')
+		end := -1
+		if start >= 0 {
+			end = strings.IndexByte(s[start:], '}')
+		}
+		if start < 0 || end < 0 {
+			t.segments = append(t.segments, templateSegment{literal: s})
+			break
+		}
+		end += start
+		if start > 0 {
+			t.segments = append(t.segments, templateSegment{literal: s[:start]})
+		}
+		field := strings.TrimSpace(s[start+1 : end])
+		t.segments = append(t.segments, templateSegment{field: field})
+		if field != TEMPLATE_DATASOURCE {
+			t.hasFields = true
+		}
+		s = s[end+1:]
+	}
+	return t
+}
+
+func (t *template) IsEmpty() bool {
+	return len(t.segments) == 0
+}
+
+// HasFields returns whether the template needs the fields of the row
+func (t *template) HasFields() bool {
+	return t.hasFields
+}
+
+// Render returns the string which the fields are replaced by the values in the row, the chars which are not
+// allowed by kafka topic are replaced by '_' if sanitize is true.
+func (t *template) Render(dataSource string, row common.ExportRow, sanitize bool) string {
+	if len(t.segments) == 1 && t.segments[0].field == "" {
+		return t.segments[0].literal
+	}
+	sb := &strings.Builder{}
+	for _, seg := range t.segments {
+		if seg.field == "" {
+			sb.WriteString(seg.literal)
+			continue
+		}
+		var value string
+		if seg.field == TEMPLATE_DATASOURCE {
+			value = dataSource
+		} else {
+			value = rowValue(row, seg.field)
+			if value == "" {
+				value = rowValue(row, seg.field+TEMPLATE_SERVER_SUFFIX)
+			}
+		}
+		if value == "" {
+			value = TEMPLATE_EMPTY_VALUE
+		}
+		if sanitize {
+			value = sanitizeTopic(value)
+		}
+		sb.WriteString(value)
+	}
+	return sb.String()
+}
+
+func rowValue(row common.ExportRow, field string) string {
+	switch v := row.Value(field).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// sanitizeTopic replaces the chars which are not in [a-zA-Z0-9._-]
+func sanitizeTopic(s string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '.' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, s)
+}
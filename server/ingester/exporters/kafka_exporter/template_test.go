@@ -0,0 +1,51 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka_exporter
+
+import (
+	"testing"
+
+	"github.com/deepflowio/deepflow/server/ingester/exporters/common"
+)
+
+func TestTemplate(t *testing.T) {
+	row := common.ExportRow{
+		{Name: "pod_ns_1", Value: "prod/ns"},
+		{Name: "app_service", Value: "order"},
+		{Name: "pod_id", Value: uint64(12)},
+	}
+	cases := []struct {
+		template, expected  string
+		sanitize, hasFields bool
+	}{
+		{"", "", true, false},
+		{"abcd", "abcd", true, false},
+		{"deepflow.{datasource}", "deepflow.flow_log.l7_flow_log", true, false},
+		{"deepflow.{datasource}.{pod_ns}", "deepflow.flow_log.l7_flow_log.prod_ns", true, true},
+		{"{app_service}-{pod_id}-{pod_group}", "order-12-unknown", false, true},
+		{"{app_service", "{app_service", false, false},
+	}
+	for _, c := range cases {
+		tpl := newTemplate(c.template)
+		if tpl.HasFields() != c.hasFields {
+			t.Errorf("template %s has fields %v, expected %v", c.template, tpl.HasFields(), c.hasFields)
+		}
+		if s := tpl.Render("flow_log.l7_flow_log", row, c.sanitize); s != c.expected {
+			t.Errorf("template %s is rendered as %s, expected %s", c.template, s, c.expected)
+		}
+	}
+}
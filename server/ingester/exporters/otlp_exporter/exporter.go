@@ -24,6 +24,8 @@ import (
 
 	logging "github.com/op/go-logging"
 	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
 	"golang.org/x/net/context"
@@ -53,6 +55,7 @@ type OtlpExporter struct {
 	dataQueues           queue.FixedMultiQueue
 	queueCount           int
 	grpcExporters        []ptraceotlp.GRPCClient
+	grpcLogExporters     []plogotlp.GRPCClient
 	grpcConns            []*grpc.ClientConn
 	grpcFailedCounters   []int
 	universalTagsManager *utag.UniversalTagsManager
@@ -95,6 +98,7 @@ func NewOtlpExporter(index int, config *exporters_cfg.ExporterCfg, universalTags
 		grpcConns:            make([]*grpc.ClientConn, config.QueueCount),
 		grpcFailedCounters:   make([]int, config.QueueCount),
 		grpcExporters:        make([]ptraceotlp.GRPCClient, config.QueueCount),
+		grpcLogExporters:     make([]plogotlp.GRPCClient, config.QueueCount),
 		config:               config,
 		counter:              &Counter{},
 	}
@@ -128,8 +132,9 @@ func (e *OtlpExporter) Close() {
 }
 
 func (e *OtlpExporter) queueProcess(queueID int) {
-	var batchCount int
+	var batchCount, traceCount, logCount int
 	traces := ptrace.NewTraces()
+	logs := plog.NewLogs()
 	items := make([]interface{}, QUEUE_BATCH_COUNT)
 
 	ctx := context.Background()
@@ -142,12 +147,20 @@ func (e *OtlpExporter) queueProcess(queueID int) {
 			return
 		}
 
-		if err := e.grpcExport(ctx, queueID, ptraceotlp.NewExportRequestFromTraces(traces)); err == nil {
-			e.counter.SendCounter += int64(batchCount)
+		if traceCount > 0 {
+			if err := e.grpcExport(ctx, queueID, ptraceotlp.NewExportRequestFromTraces(traces)); err == nil {
+				e.counter.SendCounter += int64(traceCount)
+			}
+			log.Debugf(tracesToString(traces))
+			traces = ptrace.NewTraces()
+		}
+		if logCount > 0 {
+			if err := e.grpcExport(ctx, queueID, plogotlp.NewExportRequestFromLogs(logs)); err == nil {
+				e.counter.SendCounter += int64(logCount)
+			}
+			logs = plog.NewLogs()
 		}
-		batchCount = 0
-		log.Debugf(tracesToString(traces))
-		traces = ptrace.NewTraces()
+		batchCount, traceCount, logCount = 0, 0, 0
 	}
 
 	for e.running {
@@ -173,8 +186,18 @@ func (e *OtlpExporter) queueProcess(queueID int) {
 				exportItem.Release()
 				continue
 			}
-			rsSlice := dst.(ptrace.ResourceSpansSlice)
-			rsSlice.MoveAndAppendTo(traces.ResourceSpans())
+			switch rsSlice := dst.(type) {
+			case ptrace.ResourceSpansSlice:
+				rsSlice.MoveAndAppendTo(traces.ResourceSpans())
+				traceCount++
+			case plog.ResourceLogsSlice:
+				rsSlice.MoveAndAppendTo(logs.ResourceLogs())
+				logCount++
+			default:
+				e.counter.DropCounter++
+				exportItem.Release()
+				continue
+			}
 
 			batchCount++
 			if batchCount >= e.config.BatchSize {
@@ -185,7 +208,12 @@ func (e *OtlpExporter) queueProcess(queueID int) {
 	}
 }
 
-func (e *OtlpExporter) grpcExport(ctx context.Context, queueID int, req ptraceotlp.ExportRequest) error {
+// exportRequest is ptraceotlp.ExportRequest or plogotlp.ExportRequest
+type exportRequest interface {
+	MarshalJSON() ([]byte, error)
+}
+
+func (e *OtlpExporter) grpcExport(ctx context.Context, queueID int, req exportRequest) error {
 	defer func() {
 		if r := recover(); r != nil {
 			log.Warningf("grpc otlp export error: %s", r)
@@ -206,10 +234,18 @@ func (e *OtlpExporter) grpcExport(ctx context.Context, queueID int, req ptraceot
 			return err
 		}
 	}
-	_, err := e.grpcExporters[queueID].Export(ctx, req)
+	var err error
+	switch r := req.(type) {
+	case ptraceotlp.ExportRequest:
+		_, err = e.grpcExporters[queueID].Export(ctx, r)
+	case plogotlp.ExportRequest:
+		_, err = e.grpcLogExporters[queueID].Export(ctx, r)
+	default:
+		err = fmt.Errorf("unsupport otlp export request %T", req)
+	}
 	if err != nil {
 		if e.counter.DropCounter == 0 {
-			log.Warningf("otlp exporter %d send grpc traces failed. faildCounter=%d, err: %s", e.index, e.grpcFailedCounters[queueID], err)
+			log.Warningf("otlp exporter %d send grpc %T failed. faildCounter=%d, err: %s", e.index, req, e.grpcFailedCounters[queueID], err)
 		}
 		e.counter.DropCounter++
 		e.grpcExporters[queueID] = nil
@@ -249,6 +285,7 @@ func (e *OtlpExporter) newGrpcExporter(queueID int) error {
 
 	e.grpcConns[queueID] = conn
 	e.grpcExporters[queueID] = ptraceotlp.NewGRPCClient(conn)
+	e.grpcLogExporters[queueID] = plogotlp.NewGRPCClient(conn)
 	return nil
 }
 
@@ -35,6 +35,12 @@ const (
 	DefaultDecoderQueueSize  = 4096
 	DefaultBrokerQueueSize   = 1 << 14
 	DefaultFlowLogTTL        = 72 // hour
+	DefaultPriorityThrottle  = 10000
+	DefaultSlowThreshold     = 1000 // ms
+	DefaultAssembleWindow    = 5    // s
+	DefaultAssembleMaxAge    = 30   // s
+	DefaultAssembleMaxTraces = 100000
+	DefaultAssembleMaxSpans  = 1024
 )
 
 type FlowLogTTL struct {
@@ -43,6 +49,23 @@ type FlowLogTTL struct {
 	L4Packet  int `yaml:"l4-packet"`
 }
 
+// PrioritySampling keeps the error, timeout and slow L7 flow logs when throttling,
+// and keeps or drops the other L7 flow logs of the same trace together.
+type PrioritySampling struct {
+	Enabled       bool `yaml:"enabled"`
+	Throttle      int  `yaml:"throttle"`       // the maximum of the error, timeout and slow L7 flow logs per second
+	SlowThreshold int  `yaml:"slow-threshold"` // ms, the L7 flow logs whose response duration exceeds it are slow
+}
+
+// TraceTreeAssembler assembles the spans of the same trace_id arriving in a time window into the trace tree.
+type TraceTreeAssembler struct {
+	Enabled          *bool `yaml:"enabled"`
+	Window           int   `yaml:"window"`              // s, a trace is assembled when none of its spans arrives in the window
+	MaxAge           int   `yaml:"max-age"`             // s, a trace is assembled when its first span arrived max-age ago
+	MaxTraces        int   `yaml:"max-traces"`          // the maximum of the traces being assembled, the spans of new traces are dropped when exceeded
+	MaxSpansPerTrace int   `yaml:"max-spans-per-trace"` // the trace is assembled at once when its spans exceed it
+}
+
 type Config struct {
 	Base              *config.Config
 	CKWriterConfig    config.CKWriterConfig `yaml:"flowlog-ck-writer"`
@@ -50,10 +73,12 @@ type Config struct {
 	ThrottleBucket    int                   `yaml:"throttle-bucket"`
 	L4Throttle        int                   `yaml:"l4-throttle"`
 	L7Throttle        int                   `yaml:"l7-throttle"`
+	PrioritySampling  PrioritySampling      `yaml:"l7-priority-sampling"`
 	FlowLogTTL        FlowLogTTL            `yaml:"flow-log-ttl-hour"`
 	DecoderQueueCount int                   `yaml:"flow-log-decoder-queue-count"`
 	DecoderQueueSize  int                   `yaml:"flow-log-decoder-queue-size"`
 	TraceTreeEnabled  *bool                 `yaml:"flow-log-trace-tree-enabled"`
+	TraceTreeAssemble TraceTreeAssembler    `yaml:"flow-log-trace-tree-assembler"`
 }
 
 type FlowLogConfig struct {
@@ -78,11 +103,39 @@ func (c *Config) Validate() error {
 		c.FlowLogTTL.L4Packet = DefaultFlowLogTTL
 	}
 
+	if c.PrioritySampling.Throttle <= 0 {
+		c.PrioritySampling.Throttle = DefaultPriorityThrottle
+	}
+
+	if c.PrioritySampling.SlowThreshold <= 0 {
+		c.PrioritySampling.SlowThreshold = DefaultSlowThreshold
+	}
+
 	if c.TraceTreeEnabled == nil {
 		value := configdefaults.FLOG_LOG_TRACE_TREE_ENABLED_DEFAULT
 		c.TraceTreeEnabled = &value
 	}
 
+	if c.TraceTreeAssemble.Enabled == nil {
+		value := true
+		c.TraceTreeAssemble.Enabled = &value
+	}
+	if c.TraceTreeAssemble.Window <= 0 {
+		c.TraceTreeAssemble.Window = DefaultAssembleWindow
+	}
+	if c.TraceTreeAssemble.MaxAge < c.TraceTreeAssemble.Window {
+		c.TraceTreeAssemble.MaxAge = DefaultAssembleMaxAge
+		if c.TraceTreeAssemble.MaxAge < c.TraceTreeAssemble.Window {
+			c.TraceTreeAssemble.MaxAge = c.TraceTreeAssemble.Window
+		}
+	}
+	if c.TraceTreeAssemble.MaxTraces <= 0 {
+		c.TraceTreeAssemble.MaxTraces = DefaultAssembleMaxTraces
+	}
+	if c.TraceTreeAssemble.MaxSpansPerTrace <= 0 {
+		c.TraceTreeAssemble.MaxSpansPerTrace = DefaultAssembleMaxSpans
+	}
+
 	return nil
 }
 
@@ -17,6 +17,8 @@
 package dbwriter
 
 import (
+	"net"
+
 	basecommon "github.com/deepflowio/deepflow/server/ingester/common"
 	baseconfig "github.com/deepflowio/deepflow/server/ingester/config"
 	"github.com/deepflowio/deepflow/server/ingester/flow_log/common"
@@ -26,6 +28,7 @@ import (
 	"github.com/deepflowio/deepflow/server/libs/ckdb"
 	"github.com/deepflowio/deepflow/server/libs/codec"
 	"github.com/deepflowio/deepflow/server/libs/datatype"
+	flow_metrics "github.com/deepflowio/deepflow/server/libs/flow-metrics"
 	"github.com/deepflowio/deepflow/server/libs/tracetree"
 )
 
@@ -96,6 +99,46 @@ func (t *SpanWithTraceID) Encode() {
 	t.EncodedSpan = encoder.Bytes()
 }
 
+// SpanTrace returns the fields of the span used to assemble the trace tree, the same as the decoded encoded_span
+func (t *SpanWithTraceID) SpanTrace() *tracetree.SpanTrace {
+	s := tracetree.AcquireSpanTrace()
+	s.Time = t.Time
+	s.EndTimeUsPart = uint32(t.EndTime % 1000000)
+	s.AutoServiceType0 = t.AutoServiceType0
+	s.AutoServiceType1 = t.AutoServiceType1
+	s.AutoServiceID0 = t.AutoServiceID0
+	s.AutoServiceID1 = t.AutoServiceID1
+	s.IsIPv4 = t.IsIPv4
+	if t.IsIPv4 {
+		s.IP40 = t.IP40
+		s.IP41 = t.IP41
+	} else {
+		// copy them, the l7 flow log is reused after released
+		s.IP60 = append(net.IP(nil), t.IP60...)
+		s.IP61 = append(net.IP(nil), t.IP61...)
+	}
+	s.ProcessId0 = t.ProcessID0
+	s.ProcessId1 = t.ProcessID1
+	s.AgentId = t.VtapID
+	s.ObservationPoint = flow_metrics.TAPSideEnum(t.TapSideEnum).String()
+	s.ReqTcpSeq = t.ReqTcpSeq
+	s.RespTcpSeq = t.RespTcpSeq
+	s.XRequestId0 = t.XRequestId0
+	s.XRequestId1 = t.XRequestId1
+	s.SpanId = t.SpanId
+	s.ParentSpanId = t.ParentSpanId
+	s.AppService = t.AppService
+	if t.L7Protocol == uint8(datatype.L7_PROTOCOL_KAFKA) {
+		s.Topic = t.RequestDomain
+		s.RequestType = t.RequestType
+	}
+	s.SyscallTraceIDRequest = t.SyscallTraceIDRequest
+	s.SyscallTraceIDResponse = t.SyscallTraceIDResponse
+	s.ResponseDuration = t.ResponseDuration
+	s.ResponseStatus = t.ResponseStatus
+	return s
+}
+
 func GenSpanWithTraceIDCKTable(cluster, storagePolicy, ckdbType string, ttl int, coldStorage *ckdb.ColdStorage) *ckdb.Table {
 	table := SPAN_WITH_TRACE_ID_TABLE
 	timeKey := "time"
@@ -132,9 +175,10 @@ type SpanWriter struct {
 	writerConfig      baseconfig.CKWriterConfig
 
 	traceWriter *ckwriter.CKWriter
+	assembler   *TraceTreeAssembler
 }
 
-func NewSpanWriter(config *config.Config) (*SpanWriter, error) {
+func NewSpanWriter(config *config.Config, traceTreeWriter *TraceTreeWriter) (*SpanWriter, error) {
 	if !*config.TraceTreeEnabled {
 		return nil, nil
 	}
@@ -157,19 +201,32 @@ func NewSpanWriter(config *config.Config) (*SpanWriter, error) {
 		return nil, err
 	}
 	w.traceWriter = ckwriter
+	if traceTreeWriter != nil && *config.TraceTreeAssemble.Enabled {
+		w.assembler = NewTraceTreeAssembler(&config.TraceTreeAssemble, traceTreeWriter)
+	}
 
 	return w, nil
 }
 
 func (s *SpanWriter) Put(items []interface{}) {
+	if s.assembler != nil {
+		// the items are released after written, assemble them before
+		s.assembler.Put(items)
+	}
 	s.traceWriter.Put(items...)
 }
 
 func (s *SpanWriter) Start() {
 	log.Info("flow log span writer starting")
 	s.traceWriter.Run()
+	if s.assembler != nil {
+		s.assembler.Start()
+	}
 }
 
 func (s *SpanWriter) Close() {
+	if s.assembler != nil {
+		s.assembler.Close()
+	}
 	s.traceWriter.Close()
 }
@@ -0,0 +1,224 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dbwriter
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	basecommon "github.com/deepflowio/deepflow/server/ingester/common"
+	"github.com/deepflowio/deepflow/server/ingester/flow_log/config"
+	"github.com/deepflowio/deepflow/server/libs/tracetree"
+	"github.com/deepflowio/deepflow/server/libs/utils"
+)
+
+const ASSEMBLER_SHARD_COUNT = 16
+
+type TraceTreeAssemblerCounter struct {
+	SpanCount      int64 `statsd:"span-count"`
+	SpanDropCount  int64 `statsd:"span-drop-count"`
+	TraceCount     int64 `statsd:"trace-count"`
+	TraceDropCount int64 `statsd:"trace-drop-count"`
+	PendingTraces  int64 `statsd:"pending-traces"`
+}
+
+type traceKey struct {
+	orgId   uint16
+	traceId string
+}
+
+type pendingTrace struct {
+	firstSeen int64 // s
+	lastSeen  int64 // s
+	spans     []*tracetree.SpanTrace
+}
+
+type assemblerShard struct {
+	sync.Mutex
+	traces map[traceKey]*pendingTrace
+}
+
+// TraceTreeAssembler collects the spans of each trace_id in a time window, and writes the trace trees built from them
+type TraceTreeAssembler struct {
+	window           int64
+	maxAge           int64
+	maxTracesInShard int
+	maxSpansPerTrace int
+
+	shards [ASSEMBLER_SHARD_COUNT]assemblerShard
+	writer *TraceTreeWriter
+
+	counter *TraceTreeAssemblerCounter
+	utils.Closable
+}
+
+func NewTraceTreeAssembler(cfg *config.TraceTreeAssembler, writer *TraceTreeWriter) *TraceTreeAssembler {
+	a := &TraceTreeAssembler{
+		window:           int64(cfg.Window),
+		maxAge:           int64(cfg.MaxAge),
+		maxTracesInShard: (cfg.MaxTraces + ASSEMBLER_SHARD_COUNT - 1) / ASSEMBLER_SHARD_COUNT,
+		maxSpansPerTrace: cfg.MaxSpansPerTrace,
+		writer:           writer,
+		counter:          &TraceTreeAssemblerCounter{},
+	}
+	for i := range a.shards {
+		a.shards[i].traces = make(map[traceKey]*pendingTrace)
+	}
+	basecommon.RegisterCountableForIngester("flow_log_trace_tree_assembler", a)
+	return a
+}
+
+// GetCounter reads and resets each field atomically, since the counter is updated by put without the shard locks
+func (a *TraceTreeAssembler) GetCounter() interface{} {
+	counter := &TraceTreeAssemblerCounter{
+		SpanCount:      atomic.SwapInt64(&a.counter.SpanCount, 0),
+		SpanDropCount:  atomic.SwapInt64(&a.counter.SpanDropCount, 0),
+		TraceCount:     atomic.SwapInt64(&a.counter.TraceCount, 0),
+		TraceDropCount: atomic.SwapInt64(&a.counter.TraceDropCount, 0),
+	}
+	pending := 0
+	for i := range a.shards {
+		a.shards[i].Lock()
+		pending += len(a.shards[i].traces)
+		a.shards[i].Unlock()
+	}
+	counter.PendingTraces = int64(pending)
+	return counter
+}
+
+// Put adds the spans, the items must be *SpanWithTraceID
+func (a *TraceTreeAssembler) Put(items []interface{}) {
+	a.put(items, time.Now().Unix())
+}
+
+func (a *TraceTreeAssembler) put(items []interface{}, now int64) {
+	var full []interface{}
+	for _, item := range items {
+		span, ok := item.(*SpanWithTraceID)
+		if !ok || span.TraceId == "" {
+			continue
+		}
+		atomic.AddInt64(&a.counter.SpanCount, 1)
+		key := traceKey{orgId: span.OrgId, traceId: span.TraceId}
+		shard := &a.shards[tracetree.HashSearchIndex(span.TraceId)%ASSEMBLER_SHARD_COUNT]
+
+		shard.Lock()
+		trace, ok := shard.traces[key]
+		if !ok {
+			if len(shard.traces) >= a.maxTracesInShard {
+				shard.Unlock()
+				atomic.AddInt64(&a.counter.SpanDropCount, 1)
+				continue
+			}
+			trace = &pendingTrace{firstSeen: now}
+			shard.traces[key] = trace
+		}
+		trace.lastSeen = now
+		trace.spans = append(trace.spans, span.SpanTrace())
+		if len(trace.spans) >= a.maxSpansPerTrace {
+			delete(shard.traces, key)
+		} else {
+			trace = nil
+		}
+		shard.Unlock()
+
+		if trace != nil {
+			if tree := a.build(key, trace); tree != nil {
+				full = append(full, tree)
+			}
+		}
+	}
+	if len(full) > 0 {
+		a.writer.Put(full)
+	}
+}
+
+func (a *TraceTreeAssembler) build(key traceKey, trace *pendingTrace) *tracetree.TraceTree {
+	tree := tracetree.BuildTraceTree(key.orgId, key.traceId, trace.spans)
+	for _, s := range trace.spans {
+		tracetree.ReleaseSpanTrace(s)
+	}
+	if tree == nil {
+		atomic.AddInt64(&a.counter.TraceDropCount, 1)
+		return nil
+	}
+	tree.Encode()
+	atomic.AddInt64(&a.counter.TraceCount, 1)
+	return tree
+}
+
+// assemble builds the trace trees which are out of the window or reach the max age, or all of them if force is true
+func (a *TraceTreeAssembler) assemble(now int64, force bool) []interface{} {
+	var trees []interface{}
+	var expired []traceKey
+	var traces []*pendingTrace
+	for i := range a.shards {
+		shard := &a.shards[i]
+		expired, traces = expired[:0], traces[:0]
+		shard.Lock()
+		for key, trace := range shard.traces {
+			if force || now-trace.lastSeen >= a.window || now-trace.firstSeen >= a.maxAge {
+				expired = append(expired, key)
+				traces = append(traces, trace)
+				delete(shard.traces, key)
+			}
+		}
+		shard.Unlock()
+
+		for j, key := range expired {
+			if tree := a.build(key, traces[j]); tree != nil {
+				trees = append(trees, tree)
+			}
+		}
+	}
+	return trees
+}
+
+func (a *TraceTreeAssembler) flush(now int64, force bool) {
+	trees := a.assemble(now, force)
+	for len(trees) > 0 {
+		n := len(trees)
+		if n > BUFFER_SIZE {
+			n = BUFFER_SIZE
+		}
+		a.writer.Put(trees[:n])
+		trees = trees[n:]
+	}
+}
+
+func (a *TraceTreeAssembler) Start() {
+	go a.run()
+}
+
+func (a *TraceTreeAssembler) run() {
+	log.Info("flow log trace tree assembler starting")
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		if a.Closed() {
+			return
+		}
+		a.flush(time.Now().Unix(), false)
+	}
+}
+
+func (a *TraceTreeAssembler) Close() error {
+	a.Closable.Close()
+	a.flush(time.Now().Unix(), true)
+	return nil
+}
@@ -43,6 +43,7 @@ import (
 	"github.com/deepflowio/deepflow/server/libs/queue"
 	libqueue "github.com/deepflowio/deepflow/server/libs/queue"
 	"github.com/deepflowio/deepflow/server/libs/receiver"
+	"github.com/deepflowio/deepflow/server/libs/stats"
 )
 
 var log = logging.MustGetLogger("flow_log")
@@ -70,9 +71,15 @@ type Logger struct {
 
 func NewFlowLog(config *config.Config, traceTreeQueue *queue.OverwriteQueue, recv *receiver.Receiver, platformDataManager *grpc.PlatformDataManager, exporters *exporters.Exporters) (*FlowLog, error) {
 	manager := dropletqueue.NewManager(ingesterctl.INGESTERCTL_FLOW_LOG_QUEUE)
+	// all the L7 flow logs of a trace are sampled by the same threshold, even if they are received by different loggers
+	l7Throttle := config.Throttle
+	if config.L7Throttle != 0 {
+		l7Throttle = config.L7Throttle
+	}
+	traceSampler := throttler.NewTraceSampler(l7Throttle * config.ThrottleBucket)
 
 	if config.Base.StorageDisabled {
-		l7FlowLogger, err := NewL7FlowLogger(config, platformDataManager, manager, recv, nil, exporters, nil)
+		l7FlowLogger, err := NewL7FlowLogger(config, platformDataManager, manager, recv, nil, exporters, nil, traceSampler)
 		if err != nil {
 			return nil, err
 		}
@@ -92,39 +99,39 @@ func NewFlowLog(config *config.Config, traceTreeQueue *queue.OverwriteQueue, rec
 		return nil, err
 	}
 
-	spanWriter, err := dbwriter.NewSpanWriter(config)
+	traceTreeWriter, err := dbwriter.NewTraceTreeWriter(config, traceTreeQueue)
 	if err != nil {
 		return nil, err
 	}
 
-	traceTreeWriter, err := dbwriter.NewTraceTreeWriter(config, traceTreeQueue)
+	spanWriter, err := dbwriter.NewSpanWriter(config, traceTreeWriter)
 	if err != nil {
 		return nil, err
 	}
 
 	l4FlowLogger := NewL4FlowLogger(config, platformDataManager, manager, recv, flowLogWriter, exporters)
 
-	l7FlowLogger, err := NewL7FlowLogger(config, platformDataManager, manager, recv, flowLogWriter, exporters, spanWriter)
+	l7FlowLogger, err := NewL7FlowLogger(config, platformDataManager, manager, recv, flowLogWriter, exporters, spanWriter, traceSampler)
 	if err != nil {
 		return nil, err
 	}
-	otelLogger, err := NewLogger(datatype.MESSAGE_TYPE_OPENTELEMETRY, config, platformDataManager, manager, recv, flowLogWriter, common.L7_FLOW_ID, nil, spanWriter)
+	otelLogger, err := NewLogger(datatype.MESSAGE_TYPE_OPENTELEMETRY, config, platformDataManager, manager, recv, flowLogWriter, common.L7_FLOW_ID, nil, spanWriter, traceSampler)
 	if err != nil {
 		return nil, err
 	}
-	otelCompressedLogger, err := NewLogger(datatype.MESSAGE_TYPE_OPENTELEMETRY_COMPRESSED, config, platformDataManager, manager, recv, flowLogWriter, common.L7_FLOW_ID, nil, spanWriter)
+	otelCompressedLogger, err := NewLogger(datatype.MESSAGE_TYPE_OPENTELEMETRY_COMPRESSED, config, platformDataManager, manager, recv, flowLogWriter, common.L7_FLOW_ID, nil, spanWriter, traceSampler)
 	if err != nil {
 		return nil, err
 	}
-	l4PacketLogger, err := NewLogger(datatype.MESSAGE_TYPE_PACKETSEQUENCE, config, nil, manager, recv, flowLogWriter, common.L4_PACKET_ID, nil, nil)
+	l4PacketLogger, err := NewLogger(datatype.MESSAGE_TYPE_PACKETSEQUENCE, config, nil, manager, recv, flowLogWriter, common.L4_PACKET_ID, nil, nil, traceSampler)
 	if err != nil {
 		return nil, err
 	}
-	skywalkingLogger, err := NewLogger(datatype.MESSAGE_TYPE_SKYWALKING, config, platformDataManager, manager, recv, flowLogWriter, common.L7_FLOW_ID, nil, spanWriter)
+	skywalkingLogger, err := NewLogger(datatype.MESSAGE_TYPE_SKYWALKING, config, platformDataManager, manager, recv, flowLogWriter, common.L7_FLOW_ID, nil, spanWriter, traceSampler)
 	if err != nil {
 		return nil, err
 	}
-	ddogLogger, err := NewLogger(datatype.MESSAGE_TYPE_DATADOG, config, platformDataManager, manager, recv, flowLogWriter, common.L7_FLOW_ID, nil, spanWriter)
+	ddogLogger, err := NewLogger(datatype.MESSAGE_TYPE_DATADOG, config, platformDataManager, manager, recv, flowLogWriter, common.L7_FLOW_ID, nil, spanWriter, traceSampler)
 	if err != nil {
 		return nil, err
 	}
@@ -143,7 +150,7 @@ func NewFlowLog(config *config.Config, traceTreeQueue *queue.OverwriteQueue, rec
 	}, nil
 }
 
-func NewLogger(msgType datatype.MessageType, config *config.Config, platformDataManager *grpc.PlatformDataManager, manager *dropletqueue.Manager, recv *receiver.Receiver, flowLogWriter *dbwriter.FlowLogWriter, flowLogId common.FlowLogID, exporters *exporters.Exporters, spanWriter *dbwriter.SpanWriter) (*Logger, error) {
+func NewLogger(msgType datatype.MessageType, config *config.Config, platformDataManager *grpc.PlatformDataManager, manager *dropletqueue.Manager, recv *receiver.Receiver, flowLogWriter *dbwriter.FlowLogWriter, flowLogId common.FlowLogID, exporters *exporters.Exporters, spanWriter *dbwriter.SpanWriter, traceSampler *throttler.TraceSampler) (*Logger, error) {
 	queueCount := config.DecoderQueueCount
 	decodeQueues := manager.NewQueues(
 		"1-receive-to-decode-"+datatype.MessageTypeString[msgType],
@@ -173,6 +180,15 @@ func NewLogger(msgType datatype.MessageType, config *config.Config, platformData
 			flowLogWriter,
 			int(flowLogId),
 		)
+		// the packet sequence logs are not L7 flow logs
+		if flowLogId == common.L7_FLOW_ID {
+			throttlers[i].EnablePrioritySampling(
+				config.PrioritySampling.Throttle/queueCount,
+				&config.PrioritySampling,
+				traceSampler,
+				stats.OptionStatTags{"thread": strconv.Itoa(i), "msg_type": msgType.String()},
+			)
+		}
 		if platformDataManager != nil {
 			platformDatas[i], _ = platformDataManager.NewPlatformInfoTable("flow-log-" + datatype.MessageTypeString[msgType] + "-" + strconv.Itoa(i))
 			if i == 0 {
@@ -253,7 +269,7 @@ func NewL4FlowLogger(config *config.Config, platformDataManager *grpc.PlatformDa
 	}
 }
 
-func NewL7FlowLogger(config *config.Config, platformDataManager *grpc.PlatformDataManager, manager *dropletqueue.Manager, recv *receiver.Receiver, flowLogWriter *dbwriter.FlowLogWriter, exporters *exporters.Exporters, spanWriter *dbwriter.SpanWriter) (*Logger, error) {
+func NewL7FlowLogger(config *config.Config, platformDataManager *grpc.PlatformDataManager, manager *dropletqueue.Manager, recv *receiver.Receiver, flowLogWriter *dbwriter.FlowLogWriter, exporters *exporters.Exporters, spanWriter *dbwriter.SpanWriter, traceSampler *throttler.TraceSampler) (*Logger, error) {
 	queueSuffix := "-l7"
 	queueCount := config.DecoderQueueCount
 	msgType := datatype.MESSAGE_TYPE_PROTOCOLLOG
@@ -297,6 +313,12 @@ func NewL7FlowLogger(config *config.Config, platformDataManager *grpc.PlatformDa
 			flowLogWriter,
 			int(common.L7_FLOW_ID),
 		)
+		throttlers[i].EnablePrioritySampling(
+			config.PrioritySampling.Throttle/queueCount,
+			&config.PrioritySampling,
+			traceSampler,
+			stats.OptionStatTags{"thread": strconv.Itoa(i), "msg_type": msgType.String()},
+		)
 		platformDatas[i], _ = platformDataManager.NewPlatformInfoTable("l7-flow-log-" + strconv.Itoa(i))
 		if i == 0 {
 			debug.ServerRegisterSimple(ingesterctl.CMD_PLATFORMDATA_FLOW_LOG, platformDatas[i])
@@ -17,12 +17,543 @@
 package dd_import
 
 import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	json "github.com/goccy/go-json"
+	logging "github.com/op/go-logging"
+	v11 "go.opentelemetry.io/proto/otlp/common/v1"
+	resv1 "go.opentelemetry.io/proto/otlp/resource/v1"
+	v1 "go.opentelemetry.io/proto/otlp/trace/v1"
+
 	flowlogCfg "github.com/deepflowio/deepflow/server/ingester/flow_log/config"
 	"github.com/deepflowio/deepflow/server/ingester/flow_log/log_data"
 	"github.com/deepflowio/deepflow/server/libs/datatype/pb"
 	"github.com/deepflowio/deepflow/server/libs/grpc"
 )
 
+var log = logging.MustGetLogger("flow_log.dd_import")
+
+const (
+	URI_V03_TRACES = "/v0.3/traces"
+	URI_V04_TRACES = "/v0.4/traces"
+	URI_V05_TRACES = "/v0.5/traces"
+
+	HEADER_CONTENT_TYPE = "Content-Type"
+	HEADER_CONTAINER_ID = "Datadog-Container-Id"
+	HEADER_LANG         = "Datadog-Meta-Lang"
+
+	V05_SPAN_FIELD_COUNT = 12
+)
+
+// https://github.com/DataDog/datadog-agent/blob/main/pkg/proto/datadog/trace/span.proto
+type ddSpan struct {
+	Service  string             `json:"service"`
+	Name     string             `json:"name"`
+	Resource string             `json:"resource"`
+	TraceID  uint64             `json:"trace_id"`
+	SpanID   uint64             `json:"span_id"`
+	ParentID uint64             `json:"parent_id"`
+	Start    int64              `json:"start"`    // unit: ns
+	Duration int64              `json:"duration"` // unit: ns
+	Error    int32              `json:"error"`
+	Meta     map[string]string  `json:"meta"`
+	Metrics  map[string]float64 `json:"metrics"`
+	Type     string             `json:"type"`
+}
+
+// DDogDataToL7FlowLogs converts Datadog traces into L7FlowLogs.
+// The spans are translated into OTLP spans and filled by the OTel import, then the trace_id/span_id/parent_span_id
+// are rewritten as the decimal 64-bit ids, which are the same as the 'x-datadog-*-id' headers parsed by the agent.
 func DDogDataToL7FlowLogs(vtapID, orgId, teamId uint16, pbThirdPartyData *pb.ThirdPartyTrace, platformData *grpc.PlatformInfoTable, cfg *flowlogCfg.Config) []*log_data.L7FlowLog {
-	return []*log_data.L7FlowLog{}
+	headers := make(map[string]string, len(pbThirdPartyData.ExtendKeys))
+	for i, key := range pbThirdPartyData.ExtendKeys {
+		if i < len(pbThirdPartyData.ExtendValues) {
+			headers[key] = pbThirdPartyData.ExtendValues[i]
+		}
+	}
+
+	traces, err := decodeTraces(pbThirdPartyData.Data, pbThirdPartyData.Uri, headers[HEADER_CONTENT_TYPE])
+	if err != nil {
+		log.Debugf("datadog traces decode failed, uri=%s len=%d err: %s", pbThirdPartyData.Uri, len(pbThirdPartyData.Data), err)
+		return []*log_data.L7FlowLog{}
+	}
+
+	hostIP := queryHostIP(orgId, vtapID, headers[HEADER_CONTAINER_ID], pbThirdPartyData.PeerIp, platformData)
+	ret := []*log_data.L7FlowLog{}
+	for _, trace := range traces {
+		if len(trace) == 0 {
+			continue
+		}
+		ls := log_data.OTelTracesDataToL7FlowLogs(vtapID, orgId, teamId, traceToTracesData(trace, hostIP, headers[HEADER_LANG]), platformData, cfg)
+		// OTelTracesDataToL7FlowLogs keeps the order of the spans
+		for i, l := range ls {
+			fillDDogIds(l, trace[i], cfg)
+		}
+		ret = append(ret, ls...)
+	}
+	return ret
+}
+
+// queryHostIP returns the IP of the pod which the container belongs to, or the address of the Datadog tracer
+func queryHostIP(orgId, vtapID uint16, containerID string, peerIP []byte, platformData *grpc.PlatformInfoTable) string {
+	if containerID != "" && platformData != nil {
+		if podInfo := platformData.QueryPodContainerInfo(orgId, vtapID, containerID); podInfo != nil {
+			// ip is empty means pod maybe `hostNetwork` and PodIP equals NodeIP
+			if podInfo.Ip != "" {
+				return podInfo.Ip
+			}
+			if podInfo.PodNodeIp != "" {
+				return podInfo.PodNodeIp
+			}
+		}
+	}
+	if len(peerIP) == net.IPv4len || len(peerIP) == net.IPv6len {
+		return net.IP(peerIP).String()
+	}
+	return ""
+}
+
+func decodeTraces(data []byte, uri, contentType string) ([][]*ddSpan, error) {
+	switch {
+	case strings.HasSuffix(uri, URI_V05_TRACES):
+		return decodeTracesV05(newMsgpReader(data))
+	case strings.HasSuffix(uri, URI_V03_TRACES), strings.HasSuffix(uri, URI_V04_TRACES):
+		if strings.Contains(contentType, "application/json") || strings.Contains(contentType, "text/json") {
+			traces := [][]*ddSpan{}
+			err := json.Unmarshal(data, &traces)
+			return traces, err
+		}
+		return decodeTracesV04(newMsgpReader(data))
+	}
+	return nil, fmt.Errorf("unsupported datadog uri %s", uri)
+}
+
+// v0.4: an array of traces, a trace is an array of spans, a span is a map
+func decodeTracesV04(r *msgpReader) ([][]*ddSpan, error) {
+	traceCount, err := r.readArrayHeader()
+	if err != nil {
+		return nil, err
+	}
+	traces := make([][]*ddSpan, 0, traceCount)
+	for i := 0; i < traceCount; i++ {
+		spanCount, err := r.readArrayHeader()
+		if err != nil {
+			return nil, err
+		}
+		trace := make([]*ddSpan, 0, spanCount)
+		for j := 0; j < spanCount; j++ {
+			span, err := decodeSpanV04(r)
+			if err != nil {
+				return nil, err
+			}
+			if span != nil {
+				trace = append(trace, span)
+			}
+		}
+		traces = append(traces, trace)
+	}
+	return traces, nil
+}
+
+func decodeSpanV04(r *msgpReader) (*ddSpan, error) {
+	if r.readNil() {
+		return nil, nil
+	}
+	fieldCount, err := r.readMapHeader()
+	if err != nil {
+		return nil, err
+	}
+	span := &ddSpan{}
+	for i := 0; i < fieldCount; i++ {
+		key, err := r.readStringBytes()
+		if err != nil {
+			return nil, err
+		}
+		switch string(key) {
+		case "service":
+			span.Service, err = r.readString()
+		case "name":
+			span.Name, err = r.readString()
+		case "resource":
+			span.Resource, err = r.readString()
+		case "trace_id":
+			span.TraceID, err = r.readUint64()
+		case "span_id":
+			span.SpanID, err = r.readUint64()
+		case "parent_id":
+			span.ParentID, err = r.readUint64()
+		case "start":
+			span.Start, err = r.readInt64()
+		case "duration":
+			span.Duration, err = r.readInt64()
+		case "error":
+			var v int64
+			v, err = r.readInt64()
+			span.Error = int32(v)
+		case "type":
+			span.Type, err = r.readString()
+		case "meta":
+			span.Meta, err = decodeStringMap(r)
+		case "metrics":
+			span.Metrics, err = decodeFloatMap(r)
+		default:
+			// eg. meta_struct, span_links
+			err = r.skip()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decode span field %s failed: %s", key, err)
+		}
+	}
+	return span, nil
+}
+
+func decodeStringMap(r *msgpReader) (map[string]string, error) {
+	n, err := r.readMapHeader()
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		k, err := r.readString()
+		if err != nil {
+			return nil, err
+		}
+		v, err := r.readString()
+		if err != nil {
+			return nil, err
+		}
+		m[k] = v
+	}
+	return m, nil
+}
+
+func decodeFloatMap(r *msgpReader) (map[string]float64, error) {
+	n, err := r.readMapHeader()
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]float64, n)
+	for i := 0; i < n; i++ {
+		k, err := r.readString()
+		if err != nil {
+			return nil, err
+		}
+		v, err := r.readFloat64()
+		if err != nil {
+			return nil, err
+		}
+		m[k] = v
+	}
+	return m, nil
+}
+
+// v0.5: [dictionary, traces], all the strings are the indexes of the dictionary, a span is an array of 12 elements:
+// [service, name, resource, trace_id, span_id, parent_id, start, duration, error, meta, metrics, type]
+// https://github.com/DataDog/datadog-agent/blob/main/pkg/trace/api/version.go
+func decodeTracesV05(r *msgpReader) ([][]*ddSpan, error) {
+	n, err := r.readArrayHeader()
+	if err != nil {
+		return nil, err
+	}
+	if n != 2 {
+		return nil, fmt.Errorf("v0.5 payload should be an array of 2 elements, but got %d", n)
+	}
+	dictCount, err := r.readArrayHeader()
+	if err != nil {
+		return nil, err
+	}
+	dict := make([]string, dictCount)
+	for i := range dict {
+		if dict[i], err = r.readString(); err != nil {
+			return nil, err
+		}
+	}
+
+	traceCount, err := r.readArrayHeader()
+	if err != nil {
+		return nil, err
+	}
+	traces := make([][]*ddSpan, 0, traceCount)
+	for i := 0; i < traceCount; i++ {
+		spanCount, err := r.readArrayHeader()
+		if err != nil {
+			return nil, err
+		}
+		trace := make([]*ddSpan, 0, spanCount)
+		for j := 0; j < spanCount; j++ {
+			span, err := decodeSpanV05(r, dict)
+			if err != nil {
+				return nil, err
+			}
+			trace = append(trace, span)
+		}
+		traces = append(traces, trace)
+	}
+	return traces, nil
+}
+
+func decodeSpanV05(r *msgpReader, dict []string) (*ddSpan, error) {
+	n, err := r.readArrayHeader()
+	if err != nil {
+		return nil, err
+	}
+	if n != V05_SPAN_FIELD_COUNT {
+		return nil, fmt.Errorf("v0.5 span should be an array of %d elements, but got %d", V05_SPAN_FIELD_COUNT, n)
+	}
+	readDictString := func() (string, error) {
+		index, err := r.readUint64()
+		if err != nil {
+			return "", err
+		}
+		if index >= uint64(len(dict)) {
+			return "", fmt.Errorf("dictionary index %d out of range %d", index, len(dict))
+		}
+		return dict[index], nil
+	}
+
+	span := &ddSpan{}
+	if span.Service, err = readDictString(); err != nil {
+		return nil, err
+	}
+	if span.Name, err = readDictString(); err != nil {
+		return nil, err
+	}
+	if span.Resource, err = readDictString(); err != nil {
+		return nil, err
+	}
+	if span.TraceID, err = r.readUint64(); err != nil {
+		return nil, err
+	}
+	if span.SpanID, err = r.readUint64(); err != nil {
+		return nil, err
+	}
+	if span.ParentID, err = r.readUint64(); err != nil {
+		return nil, err
+	}
+	if span.Start, err = r.readInt64(); err != nil {
+		return nil, err
+	}
+	if span.Duration, err = r.readInt64(); err != nil {
+		return nil, err
+	}
+	errCode, err := r.readInt64()
+	if err != nil {
+		return nil, err
+	}
+	span.Error = int32(errCode)
+
+	metaCount, err := r.readMapHeader()
+	if err != nil {
+		return nil, err
+	}
+	span.Meta = make(map[string]string, metaCount)
+	for i := 0; i < metaCount; i++ {
+		k, err := readDictString()
+		if err != nil {
+			return nil, err
+		}
+		v, err := readDictString()
+		if err != nil {
+			return nil, err
+		}
+		span.Meta[k] = v
+	}
+
+	metricsCount, err := r.readMapHeader()
+	if err != nil {
+		return nil, err
+	}
+	span.Metrics = make(map[string]float64, metricsCount)
+	for i := 0; i < metricsCount; i++ {
+		k, err := readDictString()
+		if err != nil {
+			return nil, err
+		}
+		v, err := r.readFloat64()
+		if err != nil {
+			return nil, err
+		}
+		span.Metrics[k] = v
+	}
+
+	if span.Type, err = readDictString(); err != nil {
+		return nil, err
+	}
+	return span, nil
+}
+
+func traceToTracesData(trace []*ddSpan, hostIP, lang string) *v1.TracesData {
+	tracesData := &v1.TracesData{}
+	// the spans of a trace may come from different services, keep each span in its own resource to keep the order
+	for _, span := range trace {
+		resAttributes := []*v11.KeyValue{
+			stringKeyValue("service.name", span.Service),
+		}
+		if hostIP != "" {
+			resAttributes = append(resAttributes, stringKeyValue("app.host.ip", hostIP))
+		}
+		if lang != "" {
+			resAttributes = append(resAttributes, stringKeyValue("telemetry.sdk.language", lang))
+		}
+		tracesData.ResourceSpans = append(tracesData.ResourceSpans, &v1.ResourceSpans{
+			Resource:   &resv1.Resource{Attributes: resAttributes},
+			ScopeSpans: []*v1.ScopeSpans{{Spans: []*v1.Span{ddSpanToSpan(span)}}},
+		})
+	}
+	return tracesData
+}
+
+func ddSpanToSpan(span *ddSpan) *v1.Span {
+	// trace_id/span_id/parent_span_id are filled by fillDDogIds
+	s := &v1.Span{
+		Name:              span.Resource,
+		Kind:              ddSpanKind(span),
+		StartTimeUnixNano: uint64(span.Start),
+		EndTimeUnixNano:   uint64(span.Start + span.Duration),
+	}
+	if s.Name == "" {
+		s.Name = span.Name
+	}
+
+	keys := make([]string, 0, len(span.Meta))
+	for k := range span.Meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attributes := make([]*v11.KeyValue, 0, len(span.Meta)+4)
+	attributes = append(attributes, stringKeyValue("dd.span.name", span.Name))
+	if span.Type != "" {
+		attributes = append(attributes, stringKeyValue("dd.span.type", span.Type))
+	}
+	hasProtocol := false
+	for _, k := range keys {
+		key, value := ddMetaKey(k), span.Meta[k]
+		switch key {
+		case "http.scheme", "db.system", "rpc.system", "messaging.system":
+			hasProtocol = true
+		case "net.peer.name":
+			if ip := net.ParseIP(value); ip != nil {
+				attributes = append(attributes, stringKeyValue("net.peer.ip", ip.String()))
+			}
+		}
+		attributes = append(attributes, stringKeyValue(key, value))
+	}
+	if !hasProtocol {
+		if key, protocol := spanTypeProtocol(span); key != "" {
+			attributes = append(attributes, stringKeyValue(key, protocol))
+		}
+	}
+	s.Attributes = attributes
+
+	if span.Error != 0 {
+		message := span.Meta["error.message"]
+		if message == "" {
+			message = span.Meta["error.msg"]
+		}
+		if message == "" {
+			message = span.Meta["error.type"]
+		}
+		s.Status = &v1.Status{Code: v1.Status_STATUS_CODE_ERROR, Message: message}
+	} else {
+		s.Status = &v1.Status{Code: v1.Status_STATUS_CODE_OK}
+	}
+	return s
+}
+
+func ddSpanKind(span *ddSpan) v1.Span_SpanKind {
+	switch span.Meta["span.kind"] {
+	case "server":
+		return v1.Span_SPAN_KIND_SERVER
+	case "client":
+		return v1.Span_SPAN_KIND_CLIENT
+	case "producer":
+		return v1.Span_SPAN_KIND_PRODUCER
+	case "consumer":
+		return v1.Span_SPAN_KIND_CONSUMER
+	case "internal":
+		return v1.Span_SPAN_KIND_INTERNAL
+	}
+	switch span.Type {
+	case "web":
+		return v1.Span_SPAN_KIND_SERVER
+	case "http", "grpc", "sql", "db", "cache", "redis", "memcached", "mongodb", "cassandra", "elasticsearch":
+		return v1.Span_SPAN_KIND_CLIENT
+	case "queue":
+		return v1.Span_SPAN_KIND_PRODUCER
+	}
+	return v1.Span_SPAN_KIND_INTERNAL
+}
+
+// ddMetaKey maps the meta keys of Datadog tracers to OTel semantic conventions used by the OTel import
+func ddMetaKey(key string) string {
+	switch key {
+	case "out.host", "peer.hostname":
+		return "net.peer.name"
+	case "out.port":
+		return "net.peer.port"
+	case "sql.query":
+		return "db.statement"
+	case "db.type":
+		return "db.system"
+	case "rpc.grpc.full_method", "grpc.method.name":
+		return "rpc.method"
+	}
+	return key
+}
+
+// spanTypeProtocol returns the OTel attribute and its value which indicates the L7 protocol of the span
+func spanTypeProtocol(span *ddSpan) (string, string) {
+	switch span.Type {
+	case "web", "http":
+		return "http.scheme", "HTTP"
+	case "grpc":
+		return "rpc.system", "gRPC"
+	case "redis":
+		return "db.system", "Redis"
+	case "memcached":
+		return "db.system", "Memcached"
+	case "mongodb":
+		return "db.system", "MongoDB"
+	case "queue":
+		if component := span.Meta["component"]; component != "" {
+			return "messaging.system", component
+		}
+	}
+	return "", ""
+}
+
+func fillDDogIds(l *log_data.L7FlowLog, span *ddSpan, cfg *flowlogCfg.Config) {
+	l.TraceId = strconv.FormatUint(span.TraceID, 10)
+	l.TraceIdIndex = log_data.ParseTraceIdIndex(l.TraceId, &cfg.Base.TraceIdWithIndex)
+	l.SpanId = strconv.FormatUint(span.SpanID, 10)
+	if span.ParentID != 0 {
+		l.ParentSpanId = strconv.FormatUint(span.ParentID, 10)
+	} else {
+		l.ParentSpanId = ""
+	}
+
+	if len(span.Metrics) == 0 {
+		return
+	}
+	names := make([]string, 0, len(span.Metrics))
+	for k := range span.Metrics {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		l.MetricsNames = append(l.MetricsNames, name)
+		l.MetricsValues = append(l.MetricsValues, span.Metrics[name])
+	}
+}
+
+func stringKeyValue(key, value string) *v11.KeyValue {
+	return &v11.KeyValue{
+		Key:   key,
+		Value: &v11.AnyValue{Value: &v11.AnyValue_StringValue{StringValue: value}},
+	}
 }
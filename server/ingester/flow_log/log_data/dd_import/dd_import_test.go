@@ -0,0 +1,177 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dd_import
+
+import (
+	"os"
+	"testing"
+
+	v11 "go.opentelemetry.io/proto/otlp/common/v1"
+	v1 "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+const (
+	testTraceID     = 12388187227513151212
+	testRootSpanID  = 1506426311446541233
+	testChildSpanID = 7214839201034512345
+)
+
+func attributeMap(attributes []*v11.KeyValue) map[string]string {
+	m := make(map[string]string, len(attributes))
+	for _, attr := range attributes {
+		m[attr.GetKey()] = attr.GetValue().GetStringValue()
+	}
+	return m
+}
+
+func loadTraces(t *testing.T, file, uri string) [][]*ddSpan {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("read %s failed: %s", file, err)
+	}
+	traces, err := decodeTraces(data, uri, "application/msgpack")
+	if err != nil {
+		t.Fatalf("decode %s failed: %s", file, err)
+	}
+	return traces
+}
+
+func checkTraces(t *testing.T, traces [][]*ddSpan) {
+	if len(traces) != 1 || len(traces[0]) != 2 {
+		t.Fatalf("expected 1 trace with 2 spans, got %v", traces)
+	}
+	root, child := traces[0][0], traces[0][1]
+	if root.TraceID != testTraceID || root.SpanID != testRootSpanID || root.ParentID != 0 {
+		t.Errorf("unexpected root span ids %d %d %d", root.TraceID, root.SpanID, root.ParentID)
+	}
+	if root.Service != "web-store" || root.Name != "http.request" || root.Resource != "GET /api/orders" || root.Type != "web" {
+		t.Errorf("unexpected root span %+v", root)
+	}
+	if root.Start != 1700000000000000000 || root.Duration != 25000000 || root.Error != 1 {
+		t.Errorf("unexpected root span time or error %+v", root)
+	}
+	if root.Meta["http.status_code"] != "500" || root.Metrics["_sampling_priority_v1"] != 1 {
+		t.Errorf("unexpected root span meta %v or metrics %v", root.Meta, root.Metrics)
+	}
+	if child.ParentID != testRootSpanID || child.SpanID != testChildSpanID || child.Metrics["db.row_count"] != 3 {
+		t.Errorf("unexpected child span %+v", child)
+	}
+}
+
+func TestDecodeTracesV04(t *testing.T) {
+	checkTraces(t, loadTraces(t, "testdata/v04_traces.msgpack", URI_V04_TRACES))
+}
+
+func TestDecodeTracesV05(t *testing.T) {
+	checkTraces(t, loadTraces(t, "testdata/v05_traces.msgpack", URI_V05_TRACES))
+}
+
+func TestDecodeTracesJSON(t *testing.T) {
+	data := []byte(`[[{"service":"svc","name":"n","resource":"r","trace_id":1,"span_id":2,"parent_id":0,"start":10,"duration":5,"type":"web"}]]`)
+	traces, err := decodeTraces(data, URI_V03_TRACES, "application/json")
+	if err != nil || len(traces) != 1 || len(traces[0]) != 1 || traces[0][0].SpanID != 2 {
+		t.Fatalf("decode json traces failed: %v %v", err, traces)
+	}
+}
+
+func TestDecodeTracesTruncated(t *testing.T) {
+	data, err := os.ReadFile("testdata/v04_traces.msgpack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decodeTraces(data[:len(data)/2], URI_V04_TRACES, ""); err == nil {
+		t.Errorf("decode truncated payload should fail")
+	}
+	if _, err := decodeTraces(data, "/v0.7/traces", ""); err == nil {
+		t.Errorf("decode unsupported uri should fail")
+	}
+}
+
+func TestDecodeTracesOversizedLength(t *testing.T) {
+	for _, c := range []struct {
+		uri  string
+		data []byte
+	}{
+		// array32 of 0xffffffff traces
+		{URI_V04_TRACES, []byte{mpArray32, 0xff, 0xff, 0xff, 0xff, 0x90}},
+		// a trace of array32 spans
+		{URI_V04_TRACES, []byte{0x91, mpArray32, 0x7f, 0xff, 0xff, 0xff}},
+		// a span of map32 fields
+		{URI_V04_TRACES, []byte{0x91, 0x91, mpMap32, 0x00, 0x00, 0x00, 0x02, 0xa0, 0xa0}},
+		// v0.5 dictionary of array32 strings
+		{URI_V05_TRACES, []byte{0x92, mpArray32, 0xff, 0xff, 0xff, 0xff, 0x90}},
+	} {
+		if _, err := decodeTraces(c.data, c.uri, ""); err == nil {
+			t.Errorf("decode %x should fail", c.data)
+		}
+	}
+}
+
+func TestDDSpanToSpan(t *testing.T) {
+	traces := loadTraces(t, "testdata/v04_traces.msgpack", URI_V04_TRACES)
+	tracesData := traceToTracesData(traces[0], "10.0.0.1", "go")
+	if len(tracesData.ResourceSpans) != 2 {
+		t.Fatalf("expected 2 resource spans, got %d", len(tracesData.ResourceSpans))
+	}
+	resAttributes := attributeMap(tracesData.ResourceSpans[0].Resource.Attributes)
+	if resAttributes["service.name"] != "web-store" || resAttributes["app.host.ip"] != "10.0.0.1" {
+		t.Errorf("unexpected resource attributes %v", resAttributes)
+	}
+
+	root := tracesData.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	if root.Name != "GET /api/orders" || root.Kind != v1.Span_SPAN_KIND_SERVER {
+		t.Errorf("unexpected root span %v", root)
+	}
+	if root.Status.Code != v1.Status_STATUS_CODE_ERROR || root.Status.Message != "internal error" {
+		t.Errorf("unexpected root span status %v", root.Status)
+	}
+	if root.EndTimeUnixNano-root.StartTimeUnixNano != 25000000 {
+		t.Errorf("unexpected root span duration %d", root.EndTimeUnixNano-root.StartTimeUnixNano)
+	}
+	attributes := attributeMap(root.Attributes)
+	if attributes["http.scheme"] != "HTTP" || attributes["http.status_code"] != "500" || attributes["dd.span.name"] != "http.request" {
+		t.Errorf("unexpected root span attributes %v", attributes)
+	}
+
+	child := tracesData.ResourceSpans[1].ScopeSpans[0].Spans[0]
+	if child.Kind != v1.Span_SPAN_KIND_CLIENT || child.Status.Code != v1.Status_STATUS_CODE_OK {
+		t.Errorf("unexpected child span %v", child)
+	}
+	attributes = attributeMap(child.Attributes)
+	expected := map[string]string{
+		"net.peer.name": "10.0.0.5",
+		"net.peer.ip":   "10.0.0.5",
+		"net.peer.port": "3306",
+		"db.system":     "mysql",
+		"db.statement":  "SELECT * FROM orders",
+	}
+	for k, v := range expected {
+		if attributes[k] != v {
+			t.Errorf("child span attribute %s == %q, expected %q", k, attributes[k], v)
+		}
+	}
+}
+
+func TestMsgpReaderSkip(t *testing.T) {
+	// {"a": [1, -1, 1.5, nil, true, "x", {}], "b": bin8(2)}
+	data := []byte{0x82, 0xa1, 'a', 0x97, 0x01, 0xff, 0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0, 0xc0, 0xc3, 0xa1, 'x', 0x80,
+		0xa1, 'b', 0xc4, 0x02, 0x01, 0x02}
+	r := newMsgpReader(data)
+	if err := r.skip(); err != nil || !r.isEnd() {
+		t.Errorf("skip failed: %v, offset %d of %d", err, r.offset, len(data))
+	}
+}
@@ -0,0 +1,365 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dd_import
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// https://github.com/msgpack/msgpack/blob/master/spec.md#formats
+const (
+	mpNil      = 0xc0
+	mpFalse    = 0xc2
+	mpTrue     = 0xc3
+	mpBin8     = 0xc4
+	mpBin16    = 0xc5
+	mpBin32    = 0xc6
+	mpExt8     = 0xc7
+	mpExt16    = 0xc8
+	mpExt32    = 0xc9
+	mpFloat32  = 0xca
+	mpFloat64  = 0xcb
+	mpUint8    = 0xcc
+	mpUint16   = 0xcd
+	mpUint32   = 0xce
+	mpUint64   = 0xcf
+	mpInt8     = 0xd0
+	mpInt16    = 0xd1
+	mpInt32    = 0xd2
+	mpInt64    = 0xd3
+	mpFixExt1  = 0xd4
+	mpFixExt2  = 0xd5
+	mpFixExt4  = 0xd6
+	mpFixExt8  = 0xd7
+	mpFixExt16 = 0xd8
+	mpStr8     = 0xd9
+	mpStr16    = 0xda
+	mpStr32    = 0xdb
+	mpArray16  = 0xdc
+	mpArray32  = 0xdd
+	mpMap16    = 0xde
+	mpMap32    = 0xdf
+)
+
+var errShortBuffer = errors.New("msgpack: short buffer")
+
+// msgpReader is a minimal msgpack decoder which only supports the types used by the Datadog trace payloads
+type msgpReader struct {
+	buf    []byte
+	offset int
+}
+
+func newMsgpReader(buf []byte) *msgpReader {
+	return &msgpReader{buf: buf}
+}
+
+func (r *msgpReader) isEnd() bool {
+	return r.offset >= len(r.buf)
+}
+
+func (r *msgpReader) remaining() int {
+	return len(r.buf) - r.offset
+}
+
+func (r *msgpReader) next(n int) ([]byte, error) {
+	if n < 0 || r.offset+n > len(r.buf) {
+		return nil, errShortBuffer
+	}
+	b := r.buf[r.offset : r.offset+n]
+	r.offset += n
+	return b, nil
+}
+
+func (r *msgpReader) readByte() (byte, error) {
+	if r.offset >= len(r.buf) {
+		return 0, errShortBuffer
+	}
+	b := r.buf[r.offset]
+	r.offset++
+	return b, nil
+}
+
+func (r *msgpReader) peek() (byte, error) {
+	if r.offset >= len(r.buf) {
+		return 0, errShortBuffer
+	}
+	return r.buf[r.offset], nil
+}
+
+func (r *msgpReader) readUintN(n int) (uint64, error) {
+	b, err := r.next(n)
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+// readNil returns true and consumes the byte if the next value is nil
+func (r *msgpReader) readNil() bool {
+	if b, err := r.peek(); err == nil && b == mpNil {
+		r.offset++
+		return true
+	}
+	return false
+}
+
+func (r *msgpReader) readArrayHeader() (int, error) {
+	b, err := r.readByte()
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	switch {
+	case b >= 0x90 && b <= 0x9f:
+		n = uint64(b & 0x0f)
+	case b == mpArray16:
+		n, err = r.readUintN(2)
+	case b == mpArray32:
+		n, err = r.readUintN(4)
+	case b == mpNil:
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("msgpack: invalid array header 0x%x at offset %d", b, r.offset-1)
+	}
+	if err != nil {
+		return 0, err
+	}
+	// every element takes at least one byte, the length is checked before the caller allocates by it
+	if n > uint64(r.remaining()) {
+		return 0, fmt.Errorf("msgpack: array length %d exceeds the remaining %d bytes at offset %d", n, r.remaining(), r.offset)
+	}
+	return int(n), nil
+}
+
+func (r *msgpReader) readMapHeader() (int, error) {
+	b, err := r.readByte()
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	switch {
+	case b >= 0x80 && b <= 0x8f:
+		n = uint64(b & 0x0f)
+	case b == mpMap16:
+		n, err = r.readUintN(2)
+	case b == mpMap32:
+		n, err = r.readUintN(4)
+	case b == mpNil:
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("msgpack: invalid map header 0x%x at offset %d", b, r.offset-1)
+	}
+	if err != nil {
+		return 0, err
+	}
+	// every entry takes at least two bytes for the key and the value
+	if n*2 > uint64(r.remaining()) {
+		return 0, fmt.Errorf("msgpack: map length %d exceeds the remaining %d bytes at offset %d", n, r.remaining(), r.offset)
+	}
+	return int(n), nil
+}
+
+// readStringBytes reads str or bin, the returned slice refers to the underlying buffer
+func (r *msgpReader) readStringBytes() ([]byte, error) {
+	b, err := r.readByte()
+	if err != nil {
+		return nil, err
+	}
+	var n uint64
+	switch {
+	case b >= 0xa0 && b <= 0xbf:
+		n = uint64(b & 0x1f)
+	case b == mpStr8, b == mpBin8:
+		n, err = r.readUintN(1)
+	case b == mpStr16, b == mpBin16:
+		n, err = r.readUintN(2)
+	case b == mpStr32, b == mpBin32:
+		n, err = r.readUintN(4)
+	case b == mpNil:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("msgpack: invalid string header 0x%x at offset %d", b, r.offset-1)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.next(int(n))
+}
+
+func (r *msgpReader) readString() (string, error) {
+	b, err := r.readStringBytes()
+	return string(b), err
+}
+
+// readUint64 reads any integer or float, negative integers are returned as two's complement
+func (r *msgpReader) readUint64() (uint64, error) {
+	b, err := r.readByte()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case b <= 0x7f:
+		return uint64(b), nil
+	case b >= 0xe0:
+		return uint64(int64(int8(b))), nil
+	}
+	switch b {
+	case mpUint8:
+		return r.readUintN(1)
+	case mpUint16:
+		return r.readUintN(2)
+	case mpUint32:
+		return r.readUintN(4)
+	case mpUint64:
+		return r.readUintN(8)
+	case mpInt8:
+		v, err := r.readUintN(1)
+		return uint64(int64(int8(v))), err
+	case mpInt16:
+		v, err := r.readUintN(2)
+		return uint64(int64(int16(v))), err
+	case mpInt32:
+		v, err := r.readUintN(4)
+		return uint64(int64(int32(v))), err
+	case mpInt64:
+		return r.readUintN(8)
+	case mpFloat32, mpFloat64:
+		r.offset--
+		f, err := r.readFloat64()
+		return uint64(f), err
+	case mpNil:
+		return 0, nil
+	}
+	return 0, fmt.Errorf("msgpack: invalid integer header 0x%x at offset %d", b, r.offset-1)
+}
+
+func (r *msgpReader) readInt64() (int64, error) {
+	v, err := r.readUint64()
+	return int64(v), err
+}
+
+// readFloat64 reads any float or integer
+func (r *msgpReader) readFloat64() (float64, error) {
+	b, err := r.peek()
+	if err != nil {
+		return 0, err
+	}
+	switch b {
+	case mpFloat32:
+		r.offset++
+		v, err := r.readUintN(4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case mpFloat64:
+		r.offset++
+		v, err := r.readUintN(8)
+		return math.Float64frombits(v), err
+	case mpInt8, mpInt16, mpInt32, mpInt64:
+		v, err := r.readInt64()
+		return float64(v), err
+	}
+	if b >= 0xe0 {
+		v, err := r.readInt64()
+		return float64(v), err
+	}
+	v, err := r.readUint64()
+	return float64(v), err
+}
+
+// skip skips the next value, including the nested arrays and maps
+func (r *msgpReader) skip() error {
+	b, err := r.readByte()
+	if err != nil {
+		return err
+	}
+	switch {
+	case b <= 0x7f, b >= 0xe0, b == mpNil, b == mpFalse, b == mpTrue:
+		return nil
+	case b >= 0x80 && b <= 0x8f:
+		return r.skipN(int(b&0x0f) * 2)
+	case b >= 0x90 && b <= 0x9f:
+		return r.skipN(int(b & 0x0f))
+	case b >= 0xa0 && b <= 0xbf:
+		_, err = r.next(int(b & 0x1f))
+		return err
+	}
+
+	var n uint64
+	switch b {
+	case mpUint8, mpInt8:
+		_, err = r.next(1)
+	case mpUint16, mpInt16:
+		_, err = r.next(2)
+	case mpUint32, mpInt32, mpFloat32:
+		_, err = r.next(4)
+	case mpUint64, mpInt64, mpFloat64:
+		_, err = r.next(8)
+	case mpFixExt1:
+		_, err = r.next(2)
+	case mpFixExt2:
+		_, err = r.next(3)
+	case mpFixExt4:
+		_, err = r.next(5)
+	case mpFixExt8:
+		_, err = r.next(9)
+	case mpFixExt16:
+		_, err = r.next(17)
+	case mpStr8, mpBin8, mpStr16, mpBin16, mpStr32, mpBin32:
+		r.offset--
+		_, err = r.readStringBytes()
+	case mpExt8, mpExt16, mpExt32:
+		// the length is 1, 2 or 4 bytes, followed by 1 byte type and the data
+		if n, err = r.readUintN(1 << (b - mpExt8)); err == nil {
+			_, err = r.next(int(n) + 1)
+		}
+	case mpArray16, mpArray32:
+		r.offset--
+		var l int
+		if l, err = r.readArrayHeader(); err == nil {
+			err = r.skipN(l)
+		}
+	case mpMap16, mpMap32:
+		r.offset--
+		var l int
+		if l, err = r.readMapHeader(); err == nil {
+			err = r.skipN(l * 2)
+		}
+	default:
+		err = fmt.Errorf("msgpack: invalid header 0x%x at offset %d", b, r.offset-1)
+	}
+	return err
+}
+
+func (r *msgpReader) skipN(n int) error {
+	for i := 0; i < n; i++ {
+		if err := r.skip(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sw_import
+
+import (
+	"strconv"
+
+	agent "skywalking.apache.org/repo/goapi/collect/language/agent/v3"
+)
+
+const (
+	ATTR_HTTP_SCHEME      = "http.scheme"
+	ATTR_DB_SYSTEM        = "db.system"
+	ATTR_RPC_SYSTEM       = "rpc.system"
+	ATTR_MESSAGING_SYSTEM = "messaging.system"
+)
+
+type component struct {
+	name string
+	// OTel attribute which carries the protocol, empty if the component does not imply a protocol
+	attribute string
+	// must be a name which can be parsed by log_data.ParseL7Protocol
+	protocol string
+}
+
+// the commonly used components of SkyWalking java agent,
+// ref: https://github.com/apache/skywalking/blob/master/oap-server/server-starter/src/main/resources/component-libraries.yml
+var components = map[int32]component{
+	1:   {"Tomcat", ATTR_HTTP_SCHEME, "HTTP"},
+	2:   {"HttpClient", ATTR_HTTP_SCHEME, "HTTP"},
+	3:   {"Dubbo", ATTR_RPC_SYSTEM, "Dubbo"},
+	4:   {"H2", "", ""},
+	5:   {"Mysql", ATTR_DB_SYSTEM, "MySQL"},
+	6:   {"ORACLE", ATTR_DB_SYSTEM, "Oracle"},
+	7:   {"Redis", ATTR_DB_SYSTEM, "Redis"},
+	8:   {"Motan", "", ""},
+	9:   {"MongoDB", ATTR_DB_SYSTEM, "MongoDB"},
+	10:  {"Resin", ATTR_HTTP_SCHEME, "HTTP"},
+	11:  {"Feign", ATTR_HTTP_SCHEME, "HTTP"},
+	12:  {"OKHttp", ATTR_HTTP_SCHEME, "HTTP"},
+	13:  {"SpringRestTemplate", ATTR_HTTP_SCHEME, "HTTP"},
+	14:  {"SpringMVC", ATTR_HTTP_SCHEME, "HTTP"},
+	15:  {"Struts2", ATTR_HTTP_SCHEME, "HTTP"},
+	16:  {"NutzMVC", ATTR_HTTP_SCHEME, "HTTP"},
+	17:  {"NutzHttp", ATTR_HTTP_SCHEME, "HTTP"},
+	18:  {"JettyClient", ATTR_HTTP_SCHEME, "HTTP"},
+	19:  {"JettyServer", ATTR_HTTP_SCHEME, "HTTP"},
+	20:  {"Memcached", ATTR_DB_SYSTEM, "Memcached"},
+	21:  {"ShardingJDBC", "", ""},
+	22:  {"PostgreSQL", ATTR_DB_SYSTEM, "PostgreSQL"},
+	23:  {"GRPC", ATTR_RPC_SYSTEM, "gRPC"},
+	24:  {"ElasticJob", "", ""},
+	25:  {"RocketMQ", ATTR_MESSAGING_SYSTEM, "RocketMQ"},
+	26:  {"httpasyncclient", ATTR_HTTP_SCHEME, "HTTP"},
+	27:  {"Kafka", ATTR_MESSAGING_SYSTEM, "Kafka"},
+	28:  {"ServiceComb", "", ""},
+	29:  {"Hystrix", "", ""},
+	30:  {"Jedis", ATTR_DB_SYSTEM, "Redis"},
+	31:  {"SQLite", "", ""},
+	32:  {"h2-jdbc-driver", "", ""},
+	33:  {"mysql-connector-java", ATTR_DB_SYSTEM, "MySQL"},
+	34:  {"ojdbc", ATTR_DB_SYSTEM, "Oracle"},
+	35:  {"Spymemcached", ATTR_DB_SYSTEM, "Memcached"},
+	36:  {"Xmemcached", ATTR_DB_SYSTEM, "Memcached"},
+	37:  {"postgresql-jdbc-driver", ATTR_DB_SYSTEM, "PostgreSQL"},
+	38:  {"rocketMQ-producer", ATTR_MESSAGING_SYSTEM, "RocketMQ"},
+	39:  {"rocketMQ-consumer", ATTR_MESSAGING_SYSTEM, "RocketMQ"},
+	40:  {"kafka-producer", ATTR_MESSAGING_SYSTEM, "Kafka"},
+	41:  {"kafka-consumer", ATTR_MESSAGING_SYSTEM, "Kafka"},
+	42:  {"mongodb-driver", ATTR_DB_SYSTEM, "MongoDB"},
+	43:  {"SOFARPC", ATTR_RPC_SYSTEM, "SofaRPC"},
+	44:  {"ActiveMQ", ATTR_MESSAGING_SYSTEM, "OpenWire"},
+	45:  {"activemq-producer", ATTR_MESSAGING_SYSTEM, "OpenWire"},
+	46:  {"activemq-consumer", ATTR_MESSAGING_SYSTEM, "OpenWire"},
+	51:  {"RabbitMQ", ATTR_MESSAGING_SYSTEM, "AMQP"},
+	52:  {"rabbitmq-producer", ATTR_MESSAGING_SYSTEM, "AMQP"},
+	53:  {"rabbitmq-consumer", ATTR_MESSAGING_SYSTEM, "AMQP"},
+	56:  {"Redisson", ATTR_DB_SYSTEM, "Redis"},
+	57:  {"Lettuce", ATTR_DB_SYSTEM, "Redis"},
+	61:  {"spring-cloud-gateway", ATTR_HTTP_SCHEME, "HTTP"},
+	66:  {"JdkHttp", ATTR_HTTP_SCHEME, "HTTP"},
+	67:  {"spring-webflux", ATTR_HTTP_SCHEME, "HTTP"},
+	72:  {"Pulsar", ATTR_MESSAGING_SYSTEM, "Pulsar"},
+	73:  {"pulsar-producer", ATTR_MESSAGING_SYSTEM, "Pulsar"},
+	74:  {"pulsar-consumer", ATTR_MESSAGING_SYSTEM, "Pulsar"},
+	84:  {"Undertow", ATTR_HTTP_SCHEME, "HTTP"},
+	86:  {"Mariadb", ATTR_DB_SYSTEM, "MySQL"},
+	87:  {"mariadb-jdbc", ATTR_DB_SYSTEM, "MySQL"},
+	91:  {"brpc-java", ATTR_RPC_SYSTEM, "bRPC"},
+	99:  {"spring-webflux-webclient", ATTR_HTTP_SCHEME, "HTTP"},
+	104: {"mssql-jdbc-driver", "", ""},
+}
+
+func componentName(id int32) string {
+	if c, ok := components[id]; ok {
+		return c.name
+	}
+	return strconv.Itoa(int(id))
+}
+
+// componentProtocol returns the OTel attribute and its value which indicates the L7 protocol of the span.
+// If the component is unknown, the protocol of the HTTP layer is still known.
+func componentProtocol(id int32, layer agent.SpanLayer) (string, string) {
+	if c, ok := components[id]; ok && c.protocol != "" {
+		return c.attribute, c.protocol
+	}
+	if layer == agent.SpanLayer_Http {
+		return ATTR_HTTP_SCHEME, "HTTP"
+	}
+	return "", ""
+}
@@ -17,11 +17,276 @@
 package sw_import
 
 import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	json "github.com/goccy/go-json"
+	logging "github.com/op/go-logging"
+	v11 "go.opentelemetry.io/proto/otlp/common/v1"
+	resv1 "go.opentelemetry.io/proto/otlp/resource/v1"
+	v1 "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	agent "skywalking.apache.org/repo/goapi/collect/language/agent/v3"
+
 	flowlogCfg "github.com/deepflowio/deepflow/server/ingester/flow_log/config"
 	"github.com/deepflowio/deepflow/server/ingester/flow_log/log_data"
 	"github.com/deepflowio/deepflow/server/libs/grpc"
 )
 
+var log = logging.MustGetLogger("flow_log.sw_import")
+
+const (
+	// HTTP endpoints of the SkyWalking OAP receiver, the body is JSON
+	URI_HTTP_SEGMENT  = "/v3/segment"
+	URI_HTTP_SEGMENTS = "/v3/segments"
+	// gRPC 'collectInSync' carries a SegmentCollection, the streaming 'collect' carries one SegmentObject per message
+	URI_GRPC_COLLECT_IN_SYNC = "collectInSync"
+)
+
+var jsonUnmarshalOptions = protojson.UnmarshalOptions{DiscardUnknown: true}
+
+// SkyWalkingDataToL7FlowLogs converts SkyWalking segments into L7FlowLogs.
+// The segments are translated into OTLP spans the same way the OpenTelemetry Collector SkyWalking receiver does
+// (sw8.* attributes, CrossProcess/CrossThread links), and then filled by the OTel import, so that the span ids
+// and parent span ids of SkyWalking data are consistent no matter which way it is reported.
 func SkyWalkingDataToL7FlowLogs(vtapID, orgId, teamId uint16, segmentData, peerIP []byte, uri string, platformData *grpc.PlatformInfoTable, cfg *flowlogCfg.Config) []*log_data.L7FlowLog {
-	return []*log_data.L7FlowLog{}
+	segments, err := decodeSegments(segmentData, uri)
+	if err != nil {
+		log.Debugf("skywalking segment decode failed, uri=%s len=%d err: %s", uri, len(segmentData), err)
+		return []*log_data.L7FlowLog{}
+	}
+
+	ret := []*log_data.L7FlowLog{}
+	for _, segment := range segments {
+		if segment == nil || len(segment.Spans) == 0 {
+			continue
+		}
+		tracesData := segmentToTracesData(segment, peerIP)
+		ret = append(ret, log_data.OTelTracesDataToL7FlowLogs(vtapID, orgId, teamId, tracesData, platformData, cfg)...)
+	}
+	return ret
+}
+
+func decodeSegments(data []byte, uri string) ([]*agent.SegmentObject, error) {
+	switch {
+	case strings.HasSuffix(uri, URI_HTTP_SEGMENTS):
+		rawSegments := []json.RawMessage{}
+		if err := json.Unmarshal(data, &rawSegments); err != nil {
+			return nil, err
+		}
+		segments := make([]*agent.SegmentObject, 0, len(rawSegments))
+		for _, raw := range rawSegments {
+			segment := &agent.SegmentObject{}
+			if err := jsonUnmarshalOptions.Unmarshal(raw, segment); err != nil {
+				return nil, err
+			}
+			segments = append(segments, segment)
+		}
+		return segments, nil
+	case strings.HasSuffix(uri, URI_HTTP_SEGMENT):
+		segment := &agent.SegmentObject{}
+		if err := jsonUnmarshalOptions.Unmarshal(data, segment); err != nil {
+			return nil, err
+		}
+		return []*agent.SegmentObject{segment}, nil
+	case strings.HasSuffix(uri, URI_GRPC_COLLECT_IN_SYNC):
+		collection := &agent.SegmentCollection{}
+		if err := proto.Unmarshal(data, collection); err != nil {
+			return nil, err
+		}
+		return collection.Segments, nil
+	default:
+		segment := &agent.SegmentObject{}
+		if err := proto.Unmarshal(data, segment); err != nil {
+			return nil, err
+		}
+		return []*agent.SegmentObject{segment}, nil
+	}
+}
+
+func segmentToTracesData(segment *agent.SegmentObject, peerIP []byte) *v1.TracesData {
+	resAttributes := []*v11.KeyValue{
+		stringKeyValue("service.name", segment.Service),
+		stringKeyValue("service.instance.id", segment.ServiceInstance),
+		stringKeyValue("sw8.trace_id", segment.TraceId),
+	}
+	// the address of the SkyWalking agent which reports the segment
+	if len(peerIP) == net.IPv4len || len(peerIP) == net.IPv6len {
+		resAttributes = append(resAttributes, stringKeyValue("app.host.ip", net.IP(peerIP).String()))
+	}
+
+	spans := make([]*v1.Span, 0, len(segment.Spans))
+	for _, span := range segment.Spans {
+		if span == nil {
+			continue
+		}
+		spans = append(spans, swSpanToSpan(segment.TraceSegmentId, span))
+	}
+
+	return &v1.TracesData{
+		ResourceSpans: []*v1.ResourceSpans{
+			{
+				Resource:   &resv1.Resource{Attributes: resAttributes},
+				ScopeSpans: []*v1.ScopeSpans{{Spans: spans}},
+			},
+		},
+	}
+}
+
+func swSpanToSpan(segmentId string, span *agent.SpanObject) *v1.Span {
+	// the trace_id is filled by resource attribute 'sw8.trace_id', because the SkyWalking trace id is not hex encoded
+	s := &v1.Span{
+		Name:              span.OperationName,
+		Kind:              swSpanKind(span.SpanType, span.SpanLayer),
+		StartTimeUnixNano: uint64(span.StartTime * int64(time.Millisecond)),
+		EndTimeUnixNano:   uint64(span.EndTime * int64(time.Millisecond)),
+	}
+
+	attributes := make([]*v11.KeyValue, 0, len(span.Tags)+8)
+	attributes = append(attributes,
+		stringKeyValue("sw8.segment_id", segmentId),
+		stringKeyValue("sw8.span_id", strconv.Itoa(int(span.SpanId))),
+		stringKeyValue("sw8.span_layer", span.SpanLayer.String()),
+		stringKeyValue("sw8.component", componentName(span.ComponentId)),
+	)
+	// -1 means the span is the first span of the segment, then the parent is found in the refs
+	if span.ParentSpanId != -1 {
+		attributes = append(attributes, stringKeyValue("sw8.parent_span_id", strconv.Itoa(int(span.ParentSpanId))))
+	}
+	attributes = append(attributes, peerAttributes(span.Peer)...)
+
+	hasProtocol := false
+	for _, tag := range span.Tags {
+		if tag == nil || tag.Key == "" {
+			continue
+		}
+		key := swTagKey(tag.Key)
+		switch key {
+		case "http.scheme", "db.system", "rpc.system", "messaging.system":
+			hasProtocol = true
+		}
+		attributes = append(attributes, stringKeyValue(key, tag.Value))
+	}
+	if !hasProtocol {
+		if key, protocol := componentProtocol(span.ComponentId, span.SpanLayer); key != "" {
+			attributes = append(attributes, stringKeyValue(key, protocol))
+		}
+	}
+	s.Attributes = attributes
+
+	for _, ref := range span.Refs {
+		if ref == nil {
+			continue
+		}
+		s.Links = append(s.Links, &v1.Span_Link{
+			Attributes: []*v11.KeyValue{
+				stringKeyValue("refType", ref.RefType.String()),
+				stringKeyValue("sw8.parent_trace_id", ref.TraceId),
+				stringKeyValue("sw8.parent_segment_id", ref.ParentTraceSegmentId),
+				stringKeyValue("sw8.parent_span_id", strconv.Itoa(int(ref.ParentSpanId))),
+				stringKeyValue("sw8.parent_service", ref.ParentService),
+				stringKeyValue("sw8.parent_service_instance", ref.ParentServiceInstance),
+				stringKeyValue("sw8.parent_endpoint", ref.ParentEndpoint),
+				stringKeyValue("network.address_used_at_peer", ref.NetworkAddressUsedAtPeer),
+			},
+		})
+	}
+
+	errorMessage := ""
+	for _, l := range span.Logs {
+		if l == nil {
+			continue
+		}
+		event := &v1.Span_Event{
+			Name:         "log",
+			TimeUnixNano: uint64(l.Time * int64(time.Millisecond)),
+		}
+		for _, kv := range l.Data {
+			if kv == nil {
+				continue
+			}
+			switch kv.Key {
+			case "event":
+				event.Name = kv.Value
+			case "message", "error.kind":
+				if errorMessage == "" {
+					errorMessage = kv.Value
+				}
+			}
+			event.Attributes = append(event.Attributes, stringKeyValue(kv.Key, kv.Value))
+		}
+		s.Events = append(s.Events, event)
+	}
+
+	if span.IsError {
+		s.Status = &v1.Status{Code: v1.Status_STATUS_CODE_ERROR, Message: errorMessage}
+	} else {
+		s.Status = &v1.Status{Code: v1.Status_STATUS_CODE_OK}
+	}
+	return s
+}
+
+func swSpanKind(spanType agent.SpanType, spanLayer agent.SpanLayer) v1.Span_SpanKind {
+	switch spanType {
+	case agent.SpanType_Entry:
+		if spanLayer == agent.SpanLayer_MQ {
+			return v1.Span_SPAN_KIND_CONSUMER
+		}
+		return v1.Span_SPAN_KIND_SERVER
+	case agent.SpanType_Exit:
+		if spanLayer == agent.SpanLayer_MQ {
+			return v1.Span_SPAN_KIND_PRODUCER
+		}
+		return v1.Span_SPAN_KIND_CLIENT
+	case agent.SpanType_Local:
+		return v1.Span_SPAN_KIND_INTERNAL
+	}
+	return v1.Span_SPAN_KIND_UNSPECIFIED
+}
+
+// peerAttributes splits the SkyWalking peer ('host:port' or 'host') into OTel net.peer.* attributes
+func peerAttributes(peer string) []*v11.KeyValue {
+	if peer == "" {
+		return nil
+	}
+	attributes := []*v11.KeyValue{stringKeyValue("net.peer.name", peer)}
+	host, port, err := net.SplitHostPort(peer)
+	if err != nil {
+		host = peer
+	} else if port != "" {
+		attributes = append(attributes, stringKeyValue("net.peer.port", port))
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		attributes = append(attributes, stringKeyValue("net.peer.ip", ip.String()))
+	}
+	return attributes
+}
+
+// swTagKey maps the tag keys of SkyWalking agents to OTel semantic conventions used by the OTel import.
+// 'db.type' is not mapped to 'db.system', because its value (eg. 'sql') is too vague to parse the L7 protocol,
+// the protocol is got from the component instead.
+func swTagKey(key string) string {
+	switch key {
+	case "url":
+		return "http.url"
+	case "status_code", "http.status_code":
+		return "http.status_code"
+	case "db.instance":
+		return "db.name"
+	case "mq.topic", "mq.queue":
+		return "messaging.destination"
+	case "mq.broker":
+		return "messaging.url"
+	}
+	return key
+}
+
+func stringKeyValue(key, value string) *v11.KeyValue {
+	return &v11.KeyValue{
+		Key:   key,
+		Value: &v11.AnyValue{Value: &v11.AnyValue_StringValue{StringValue: value}},
+	}
 }
@@ -0,0 +1,234 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sw_import
+
+import (
+	"testing"
+
+	v11 "go.opentelemetry.io/proto/otlp/common/v1"
+	v1 "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+	swcommon "skywalking.apache.org/repo/goapi/collect/common/v3"
+	agent "skywalking.apache.org/repo/goapi/collect/language/agent/v3"
+)
+
+const testSegmentJSON = `{
+	"traceId": "56a5e1c519ae4c76a2b8b11d92cead7f.12.16563474296430001",
+	"traceSegmentId": "56a5e1c519ae4c76a2b8b11d92cead7f.12.16563474296430000",
+	"service": "order",
+	"serviceInstance": "order-7d9c@10.1.2.3",
+	"spans": [
+		{
+			"spanId": 0,
+			"parentSpanId": -1,
+			"startTime": 1656347429643,
+			"endTime": 1656347429655,
+			"operationName": "/order/create",
+			"spanType": "Entry",
+			"spanLayer": "Http",
+			"componentId": 14,
+			"isError": true,
+			"refs": [
+				{
+					"refType": "CrossProcess",
+					"traceId": "56a5e1c519ae4c76a2b8b11d92cead7f.12.16563474296430001",
+					"parentTraceSegmentId": "a1b2c3.1.1",
+					"parentSpanId": 2,
+					"parentService": "gateway"
+				}
+			],
+			"tags": [
+				{"key": "url", "value": "http://order:8080/order/create"},
+				{"key": "http.method", "value": "POST"},
+				{"key": "status_code", "value": "500"}
+			],
+			"logs": [
+				{
+					"time": 1656347429650,
+					"data": [
+						{"key": "event", "value": "error"},
+						{"key": "error.kind", "value": "java.lang.NullPointerException"}
+					]
+				}
+			]
+		},
+		{
+			"spanId": 1,
+			"parentSpanId": 0,
+			"startTime": 1656347429645,
+			"endTime": 1656347429650,
+			"operationName": "Mysql/JDBC/PreparedStatement/execute",
+			"peer": "10.1.2.4:3306",
+			"spanType": "Exit",
+			"spanLayer": "Database",
+			"componentId": 33,
+			"tags": [
+				{"key": "db.statement", "value": "SELECT * FROM orders"}
+			]
+		}
+	]
+}`
+
+func attributeMap(attributes []*v11.KeyValue) map[string]string {
+	m := make(map[string]string, len(attributes))
+	for _, attr := range attributes {
+		m[attr.GetKey()] = attr.GetValue().GetStringValue()
+	}
+	return m
+}
+
+func TestDecodeSegments(t *testing.T) {
+	segments, err := decodeSegments([]byte(testSegmentJSON), URI_HTTP_SEGMENT)
+	if err != nil || len(segments) != 1 {
+		t.Fatalf("decode json segment failed: %v, %d segments", err, len(segments))
+	}
+	if len(segments[0].Spans) != 2 || segments[0].Spans[0].SpanType != agent.SpanType_Entry {
+		t.Fatalf("decode json segment got unexpected spans: %v", segments[0].Spans)
+	}
+
+	segments, err = decodeSegments([]byte("["+testSegmentJSON+","+testSegmentJSON+"]"), URI_HTTP_SEGMENTS)
+	if err != nil || len(segments) != 2 {
+		t.Fatalf("decode json segments failed: %v, %d segments", err, len(segments))
+	}
+
+	collection := &agent.SegmentCollection{Segments: segments}
+	data, _ := proto.Marshal(collection)
+	segments, err = decodeSegments(data, "/skywalking.v3.TraceSegmentReportService/collectInSync")
+	if err != nil || len(segments) != 2 {
+		t.Fatalf("decode segment collection failed: %v, %d segments", err, len(segments))
+	}
+
+	data, _ = proto.Marshal(segments[0])
+	segments, err = decodeSegments(data, "/skywalking.v3.TraceSegmentReportService/collect")
+	if err != nil || len(segments) != 1 || segments[0].Service != "order" {
+		t.Fatalf("decode segment object failed: %v, %v", err, segments)
+	}
+}
+
+func TestSegmentToTracesData(t *testing.T) {
+	segments, _ := decodeSegments([]byte(testSegmentJSON), URI_HTTP_SEGMENT)
+	tracesData := segmentToTracesData(segments[0], []byte{10, 1, 2, 3})
+
+	resourceSpans := tracesData.GetResourceSpans()
+	if len(resourceSpans) != 1 {
+		t.Fatalf("expected 1 resource spans, got %d", len(resourceSpans))
+	}
+	resAttributes := attributeMap(resourceSpans[0].GetResource().GetAttributes())
+	if resAttributes["service.name"] != "order" || resAttributes["app.host.ip"] != "10.1.2.3" ||
+		resAttributes["sw8.trace_id"] != "56a5e1c519ae4c76a2b8b11d92cead7f.12.16563474296430001" {
+		t.Errorf("unexpected resource attributes %v", resAttributes)
+	}
+
+	spans := resourceSpans[0].GetScopeSpans()[0].GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+
+	entry := spans[0]
+	if entry.Kind != v1.Span_SPAN_KIND_SERVER || entry.Status.Code != v1.Status_STATUS_CODE_ERROR ||
+		entry.Status.Message != "java.lang.NullPointerException" {
+		t.Errorf("unexpected entry span %v", entry)
+	}
+	if entry.EndTimeUnixNano-entry.StartTimeUnixNano != 12000000 {
+		t.Errorf("unexpected entry span duration %d", entry.EndTimeUnixNano-entry.StartTimeUnixNano)
+	}
+	attributes := attributeMap(entry.Attributes)
+	expected := map[string]string{
+		"sw8.segment_id":   "56a5e1c519ae4c76a2b8b11d92cead7f.12.16563474296430000",
+		"sw8.span_id":      "0",
+		"sw8.component":    "SpringMVC",
+		"http.url":         "http://order:8080/order/create",
+		"http.status_code": "500",
+		"http.scheme":      "HTTP",
+	}
+	for k, v := range expected {
+		if attributes[k] != v {
+			t.Errorf("entry span attribute %s == %q, expected %q", k, attributes[k], v)
+		}
+	}
+	if _, ok := attributes["sw8.parent_span_id"]; ok {
+		t.Errorf("entry span should not have sw8.parent_span_id")
+	}
+	if len(entry.Links) != 1 {
+		t.Fatalf("expected 1 link, got %d", len(entry.Links))
+	}
+	links := attributeMap(entry.Links[0].Attributes)
+	if links["refType"] != "CrossProcess" || links["sw8.parent_segment_id"] != "a1b2c3.1.1" || links["sw8.parent_span_id"] != "2" {
+		t.Errorf("unexpected link attributes %v", links)
+	}
+	if len(entry.Events) != 1 || entry.Events[0].Name != "error" {
+		t.Errorf("unexpected events %v", entry.Events)
+	}
+
+	exit := spans[1]
+	if exit.Kind != v1.Span_SPAN_KIND_CLIENT || exit.Status.Code != v1.Status_STATUS_CODE_OK {
+		t.Errorf("unexpected exit span %v", exit)
+	}
+	attributes = attributeMap(exit.Attributes)
+	expected = map[string]string{
+		"sw8.parent_span_id": "0",
+		"net.peer.ip":        "10.1.2.4",
+		"net.peer.port":      "3306",
+		"db.system":          "MySQL",
+		"db.statement":       "SELECT * FROM orders",
+	}
+	for k, v := range expected {
+		if attributes[k] != v {
+			t.Errorf("exit span attribute %s == %q, expected %q", k, attributes[k], v)
+		}
+	}
+}
+
+func TestSwSpanKind(t *testing.T) {
+	cases := []struct {
+		spanType agent.SpanType
+		layer    agent.SpanLayer
+		expected v1.Span_SpanKind
+	}{
+		{agent.SpanType_Entry, agent.SpanLayer_Http, v1.Span_SPAN_KIND_SERVER},
+		{agent.SpanType_Entry, agent.SpanLayer_MQ, v1.Span_SPAN_KIND_CONSUMER},
+		{agent.SpanType_Exit, agent.SpanLayer_Database, v1.Span_SPAN_KIND_CLIENT},
+		{agent.SpanType_Exit, agent.SpanLayer_MQ, v1.Span_SPAN_KIND_PRODUCER},
+		{agent.SpanType_Local, agent.SpanLayer_Unknown, v1.Span_SPAN_KIND_INTERNAL},
+	}
+	for _, c := range cases {
+		if got := swSpanKind(c.spanType, c.layer); got != c.expected {
+			t.Errorf("swSpanKind(%s, %s) == %s, expected %s", c.spanType, c.layer, got, c.expected)
+		}
+	}
+}
+
+func TestSwTagsWithProtocol(t *testing.T) {
+	span := &agent.SpanObject{
+		SpanId:       1,
+		ParentSpanId: 0,
+		SpanType:     agent.SpanType_Exit,
+		SpanLayer:    agent.SpanLayer_Cache,
+		ComponentId:  30,
+		Tags:         []*swcommon.KeyStringValuePair{{Key: "db.system", Value: "Redis"}},
+	}
+	attributes := swSpanToSpan("segment", span).Attributes
+	count := 0
+	for _, attr := range attributes {
+		if attr.Key == ATTR_DB_SYSTEM {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("db.system from tags should not be overwritten by component, got %d", count)
+	}
+}
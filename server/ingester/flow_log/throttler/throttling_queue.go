@@ -17,10 +17,21 @@
 package throttler
 
 import (
+	"container/heap"
+	"math"
 	"math/rand"
+	"sync/atomic"
 	"time"
 
+	"github.com/OneOfOne/xxhash"
+
+	"github.com/deepflowio/deepflow/server/ingester/common"
+	"github.com/deepflowio/deepflow/server/ingester/flow_log/config"
 	"github.com/deepflowio/deepflow/server/ingester/flow_log/dbwriter"
+	"github.com/deepflowio/deepflow/server/ingester/flow_log/log_data"
+	"github.com/deepflowio/deepflow/server/libs/datatype"
+	"github.com/deepflowio/deepflow/server/libs/stats"
+	"github.com/deepflowio/deepflow/server/libs/utils"
 )
 
 const (
@@ -31,6 +42,155 @@ type throttleItem interface {
 	Release()
 }
 
+type priorityClass uint8
+
+const (
+	CLASS_NORMAL priorityClass = iota
+	CLASS_ERROR
+	CLASS_TIMEOUT
+	CLASS_SLOW
+)
+
+type Counter struct {
+	NormalCount      int64 `statsd:"normal-count"`
+	NormalDropCount  int64 `statsd:"normal-drop-count"`
+	ErrorCount       int64 `statsd:"error-count"`
+	ErrorDropCount   int64 `statsd:"error-drop-count"`
+	TimeoutCount     int64 `statsd:"timeout-count"`
+	TimeoutDropCount int64 `statsd:"timeout-drop-count"`
+	SlowCount        int64 `statsd:"slow-count"`
+	SlowDropCount    int64 `statsd:"slow-drop-count"`
+	TraceSampleRate  int64 `statsd:"trace-sample-rate"` // per ten thousand, of the L7 flow logs which are not error, timeout or slow
+	utils.Closable
+}
+
+func (c *Counter) GetCounter() interface{} {
+	var counter Counter
+	counter, *c = *c, Counter{TraceSampleRate: c.TraceSampleRate}
+	return &counter
+}
+
+func (c *Counter) add(class priorityClass, dropped bool) {
+	drop := int64(0)
+	if dropped {
+		drop = 1
+	}
+	switch class {
+	case CLASS_NORMAL:
+		c.NormalCount++
+		c.NormalDropCount += drop
+	case CLASS_ERROR:
+		c.ErrorCount++
+		c.ErrorDropCount += drop
+	case CLASS_TIMEOUT:
+		c.TimeoutCount++
+		c.TimeoutDropCount += drop
+	case CLASS_SLOW:
+		c.SlowCount++
+		c.SlowDropCount += drop
+	}
+}
+
+// drop counts the evicted item, which has been counted as kept when it was added
+func (c *Counter) drop(class priorityClass) {
+	switch class {
+	case CLASS_NORMAL:
+		c.NormalDropCount++
+	case CLASS_ERROR:
+		c.ErrorDropCount++
+	case CLASS_TIMEOUT:
+		c.TimeoutDropCount++
+	case CLASS_SLOW:
+		c.SlowDropCount++
+	}
+}
+
+// TraceSampler is shared by all the throttling queues of the L7 flow logs. The normal L7 flow logs whose
+// trace_id hash is not greater than the threshold are kept, and every queue uses the same threshold in a period,
+// so that all the L7 flow logs of a trace are kept or dropped together. The threshold is adjusted by the total
+// count of the last period, so that the kept count is about the throttle.
+type TraceSampler struct {
+	throttle int64 // the total throttle of the L7 flow logs per period
+
+	period     int64 // the current period
+	count      int64 // the count of the normal L7 flow logs in the current period
+	threshold  uint64
+	sampleRate int64 // per ten thousand
+}
+
+// NewTraceSampler creates the sampler with the total throttle of all the queues which share it per period
+func NewTraceSampler(throttle int) *TraceSampler {
+	return &TraceSampler{throttle: int64(throttle), threshold: math.MaxUint64, sampleRate: 10000}
+}
+
+// sample counts the L7 flow log in the period, and returns whether it is kept by its trace_id hash
+func (s *TraceSampler) sample(period int64, hash uint64) bool {
+	s.rollover(period)
+	atomic.AddInt64(&s.count, 1)
+	return hash <= atomic.LoadUint64(&s.threshold)
+}
+
+// rollover adjusts the threshold by the count of the last period when the first queue enters a new period
+func (s *TraceSampler) rollover(period int64) {
+	last := atomic.LoadInt64(&s.period)
+	if period <= last || !atomic.CompareAndSwapInt64(&s.period, last, period) {
+		return
+	}
+	count := atomic.SwapInt64(&s.count, 0)
+	if period != last+1 {
+		// nothing is received in the last period
+		count = 0
+	}
+	throttle := s.throttle
+	if count <= throttle {
+		atomic.StoreUint64(&s.threshold, math.MaxUint64)
+		atomic.StoreInt64(&s.sampleRate, 10000)
+	} else {
+		atomic.StoreUint64(&s.threshold, uint64(float64(math.MaxUint64)*float64(throttle)/float64(count)))
+		atomic.StoreInt64(&s.sampleRate, throttle*10000/count)
+	}
+}
+
+func (s *TraceSampler) SampleRate() int64 {
+	return atomic.LoadInt64(&s.sampleRate)
+}
+
+type traceItem struct {
+	hash uint64
+	l    *log_data.L7FlowLog
+}
+
+// traceHeap is a max heap by the trace_id hash, so that the L7 flow logs with the largest hash are evicted
+// first when the queue is full, and the L7 flow logs of a trace are still likely to be kept or dropped together
+type traceHeap []traceItem
+
+func (h traceHeap) Len() int            { return len(h) }
+func (h traceHeap) Less(i, j int) bool  { return h[i].hash > h[j].hash }
+func (h traceHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *traceHeap) Push(x interface{}) { *h = append(*h, x.(traceItem)) }
+func (h *traceHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// prioritySampling keeps the error, timeout and slow L7 flow logs in a separate reservoir, and samples the
+// others by the trace sampler shared by the queues
+type prioritySampling struct {
+	throttle      int
+	slowThreshold uint64 // us
+	items         []interface{}
+	periodCount   int
+	emitCount     int
+
+	sampler     *TraceSampler
+	traceItems  traceHeap     // the normal L7 flow logs kept by the sampler in the period, at most the throttle of the queue
+	flushBuffer []interface{} // the buffer to flush traceItems
+
+	counter *Counter
+}
+
 type ThrottlingQueue struct {
 	flowLogWriter *dbwriter.FlowLogWriter
 	index         int
@@ -43,6 +203,8 @@ type ThrottlingQueue struct {
 
 	sampleItems    []interface{}
 	nonSampleItems []interface{}
+
+	priority *prioritySampling // nil if priority sampling is disabled
 }
 
 func NewThrottlingQueue(throttle, throttleBucket int, flowLogWriter *dbwriter.FlowLogWriter, index int) *ThrottlingQueue {
@@ -60,25 +222,142 @@ func NewThrottlingQueue(throttle, throttleBucket int, flowLogWriter *dbwriter.Fl
 	return thq
 }
 
+// EnablePrioritySampling should be called before sending, priorityThrottle is the maximum of the error, timeout
+// and slow L7 flow logs per second of this queue, sampler should be shared by all the queues of the L7 flow logs.
+// It should only be enabled for the queues of the L7 flow logs.
+func (thq *ThrottlingQueue) EnablePrioritySampling(priorityThrottle int, cfg *config.PrioritySampling, sampler *TraceSampler, statTags stats.OptionStatTags) {
+	if thq.SampleDisabled() || !cfg.Enabled {
+		return
+	}
+	p := &prioritySampling{
+		throttle:      priorityThrottle * int(thq.throttleBucket),
+		slowThreshold: uint64(cfg.SlowThreshold) * uint64(time.Millisecond/time.Microsecond),
+		sampler:       sampler,
+		counter:       &Counter{TraceSampleRate: sampler.SampleRate()},
+	}
+	if p.throttle > 0 {
+		p.items = make([]interface{}, p.throttle)
+	}
+	thq.priority = p
+	common.RegisterCountableForIngester("flow_log_throttler", p.counter, statTags)
+}
+
 func (thq *ThrottlingQueue) SampleDisabled() bool {
 	return thq.Throttle <= 0
 }
 
+func (p *prioritySampling) classify(l *log_data.L7FlowLog) priorityClass {
+	switch datatype.LogMessageStatus(l.ResponseStatus) {
+	case datatype.STATUS_SERVER_ERROR, datatype.STATUS_CLIENT_ERROR:
+		return CLASS_ERROR
+	case datatype.STATUS_TIMEOUT:
+		return CLASS_TIMEOUT
+	}
+	if l.ResponseDuration > p.slowThreshold {
+		return CLASS_SLOW
+	}
+	return CLASS_NORMAL
+}
+
+func (thq *ThrottlingQueue) sendWithPriority(l *log_data.L7FlowLog) bool {
+	p := thq.priority
+	class := p.classify(l)
+	if class != CLASS_NORMAL {
+		// Reservoir Sampling in the priority items
+		p.periodCount++
+		if p.emitCount < p.throttle {
+			p.items[p.emitCount] = l
+			p.emitCount++
+			p.counter.add(class, false)
+			return true
+		}
+		r := rand.Intn(p.periodCount)
+		if r < p.throttle {
+			evicted := p.items[r].(*log_data.L7FlowLog)
+			p.counter.drop(p.classify(evicted))
+			p.counter.add(class, false)
+			evicted.Release()
+			p.items[r] = l
+		} else {
+			p.counter.add(class, true)
+			l.Release()
+		}
+		return false
+	}
+
+	var hash uint64
+	if l.TraceId != "" {
+		hash = xxhash.ChecksumString64(l.TraceId)
+	} else {
+		hash = rand.Uint64()
+	}
+	if !p.sampler.sample(thq.lastFlush/thq.throttleBucket, hash) {
+		p.counter.add(CLASS_NORMAL, true)
+		l.Release()
+		return false
+	}
+	if len(p.traceItems) < thq.Throttle {
+		p.counter.add(CLASS_NORMAL, false)
+		heap.Push(&p.traceItems, traceItem{hash: hash, l: l})
+		return true
+	}
+	// the sampler lags behind the traffic burst, the items with the largest hash are dropped to limit the memory
+	if hash >= p.traceItems[0].hash {
+		p.counter.add(CLASS_NORMAL, true)
+		l.Release()
+		return false
+	}
+	p.counter.drop(CLASS_NORMAL)
+	p.counter.add(CLASS_NORMAL, false)
+	p.traceItems[0].l.Release()
+	p.traceItems[0] = traceItem{hash: hash, l: l}
+	heap.Fix(&p.traceItems, 0)
+	return false
+}
+
+func (p *prioritySampling) resetPeriod(period int64) {
+	p.sampler.rollover(period)
+	p.counter.TraceSampleRate = p.sampler.SampleRate()
+	for i := range p.traceItems {
+		p.traceItems[i].l = nil
+	}
+	p.traceItems = p.traceItems[:0]
+	p.periodCount = 0
+	p.emitCount = 0
+}
+
 func (thq *ThrottlingQueue) flush() {
-	if thq.periodEmitCount > 0 {
-		if thq.flowLogWriter != nil {
-			for i := 0; i < thq.periodEmitCount; i += QUEUE_BATCH {
-				end := i + QUEUE_BATCH
-				if end > thq.periodEmitCount {
-					end = thq.periodEmitCount
-				}
-				thq.flowLogWriter.Put(thq.index, thq.sampleItems[i:end]...)
+	thq.flushItems(thq.sampleItems[:thq.periodEmitCount])
+	if thq.priority != nil {
+		thq.flushItems(thq.priority.items[:thq.priority.emitCount])
+		p := thq.priority
+		for _, item := range p.traceItems {
+			p.flushBuffer = append(p.flushBuffer, item.l)
+		}
+		thq.flushItems(p.flushBuffer)
+		for i := range p.flushBuffer {
+			p.flushBuffer[i] = nil
+		}
+		p.flushBuffer = p.flushBuffer[:0]
+	}
+}
+
+func (thq *ThrottlingQueue) flushItems(items []interface{}) {
+	if len(items) == 0 {
+		return
+	}
+	if thq.flowLogWriter != nil {
+		for i := 0; i < len(items); i += QUEUE_BATCH {
+			end := i + QUEUE_BATCH
+			if end > len(items) {
+				end = len(items)
 			}
-		} else {
-			for i := range thq.sampleItems[:thq.periodEmitCount] {
-				if tItem, ok := thq.sampleItems[i].(throttleItem); ok {
-					tItem.Release()
-				}
+			thq.flowLogWriter.Put(thq.index, items[i:end]...)
+		}
+	} else {
+		for i := range items {
+			if tItem, ok := items[i].(throttleItem); ok {
+				tItem.Release()
 			}
 		}
 	}
@@ -96,11 +375,20 @@ func (thq *ThrottlingQueue) SendWithThrottling(flow interface{}) bool {
 		thq.lastFlush = now
 		thq.periodCount = 0
 		thq.periodEmitCount = 0
+		if thq.priority != nil {
+			thq.priority.resetPeriod(now / thq.throttleBucket)
+		}
 	}
 	if flow == nil {
 		return false
 	}
 
+	if thq.priority != nil {
+		if l, ok := flow.(*log_data.L7FlowLog); ok {
+			return thq.sendWithPriority(l)
+		}
+	}
+
 	// Reservoir Sampling
 	thq.periodCount++
 	if thq.periodEmitCount < thq.Throttle {
@@ -0,0 +1,167 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package throttler
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/OneOfOne/xxhash"
+
+	"github.com/deepflowio/deepflow/server/ingester/flow_log/config"
+	"github.com/deepflowio/deepflow/server/ingester/flow_log/log_data"
+	"github.com/deepflowio/deepflow/server/libs/datatype"
+)
+
+func newL7FlowLog(traceId string, status datatype.LogMessageStatus, duration uint64) *log_data.L7FlowLog {
+	l := log_data.AcquireL7FlowLog()
+	l.TraceId = traceId
+	l.ResponseStatus = uint8(status)
+	l.ResponseDuration = duration
+	return l
+}
+
+func newPriorityQueue(throttle, priorityThrottle int, sampler *TraceSampler) *ThrottlingQueue {
+	thq := NewThrottlingQueue(throttle, 1, nil, 0)
+	thq.EnablePrioritySampling(priorityThrottle, &config.PrioritySampling{Enabled: true, SlowThreshold: 1000}, sampler, nil)
+	return thq
+}
+
+// nextPeriod does what SendWithThrottling does at the beginning of a period
+func nextPeriod(thq *ThrottlingQueue) {
+	thq.flush()
+	thq.lastFlush++
+	thq.periodCount = 0
+	thq.periodEmitCount = 0
+	thq.priority.resetPeriod(thq.lastFlush)
+}
+
+func TestPriorityClassify(t *testing.T) {
+	thq := newPriorityQueue(10, 10, NewTraceSampler(10))
+	cases := []struct {
+		status   datatype.LogMessageStatus
+		duration uint64
+		expected priorityClass
+	}{
+		{datatype.STATUS_OK, 100, CLASS_NORMAL},
+		{datatype.STATUS_SERVER_ERROR, 100, CLASS_ERROR},
+		{datatype.STATUS_CLIENT_ERROR, 2000000, CLASS_ERROR},
+		{datatype.STATUS_TIMEOUT, 0, CLASS_TIMEOUT},
+		{datatype.STATUS_OK, 1000001, CLASS_SLOW},
+	}
+	for _, c := range cases {
+		l := newL7FlowLog("", c.status, c.duration)
+		if got := thq.priority.classify(l); got != c.expected {
+			t.Errorf("classify(%d, %d) == %d, expected %d", c.status, c.duration, got, c.expected)
+		}
+		l.Release()
+	}
+}
+
+func TestPrioritySampling(t *testing.T) {
+	thq := newPriorityQueue(1000, 5, NewTraceSampler(100))
+	p := thq.priority
+	// no count of the last period, all the normal items are kept
+	for i := 0; i < 1000; i++ {
+		thq.sendWithPriority(newL7FlowLog(fmt.Sprintf("trace-%d", i), datatype.STATUS_OK, 100))
+	}
+	for i := 0; i < 20; i++ {
+		thq.sendWithPriority(newL7FlowLog("", datatype.STATUS_SERVER_ERROR, 100))
+	}
+	if len(p.traceItems) != 1000 || p.emitCount != 5 {
+		t.Errorf("kept %d normal and %d priority items, expected 1000 and 5", len(p.traceItems), p.emitCount)
+	}
+	if p.counter.ErrorCount != 20 || p.counter.ErrorDropCount != 15 || p.counter.NormalDropCount != 0 {
+		t.Errorf("unexpected counter %+v", p.counter)
+	}
+
+	// the next period samples the normal items by the trace_id hash, the same trace is kept or dropped together
+	nextPeriod(thq)
+	kept := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		traceId := fmt.Sprintf("trace-%d", i%200)
+		sent := thq.sendWithPriority(newL7FlowLog(traceId, datatype.STATUS_OK, 100))
+		if last, ok := kept[traceId]; ok && last != sent {
+			t.Errorf("trace %s is not sampled consistently", traceId)
+		}
+		kept[traceId] = sent
+	}
+	if p.counter.TraceSampleRate != 1000 {
+		t.Errorf("unexpected trace sample rate %d", p.counter.TraceSampleRate)
+	}
+	if len(p.traceItems) == 0 || len(p.traceItems) == 1000 || len(p.traceItems)%5 != 0 {
+		t.Errorf("kept %d normal items, expected whole traces of 5 items", len(p.traceItems))
+	}
+}
+
+func TestSharedTraceSampler(t *testing.T) {
+	sampler := NewTraceSampler(20)
+	queues := []*ThrottlingQueue{newPriorityQueue(100, 5, sampler), newPriorityQueue(100, 5, sampler)}
+	for _, thq := range queues {
+		for i := 0; i < 200; i++ {
+			thq.sendWithPriority(newL7FlowLog(fmt.Sprintf("trace-%d", i), datatype.STATUS_OK, 100))
+		}
+	}
+
+	// the spans of a trace received by different queues are kept or dropped together
+	for _, thq := range queues {
+		nextPeriod(thq)
+	}
+	if sampler.SampleRate() != 500 {
+		t.Errorf("unexpected trace sample rate %d", sampler.SampleRate())
+	}
+	for i := 0; i < 400; i++ {
+		traceId := fmt.Sprintf("trace-%d", i)
+		sent0 := queues[0].sendWithPriority(newL7FlowLog(traceId, datatype.STATUS_OK, 100))
+		sent1 := queues[1].sendWithPriority(newL7FlowLog(traceId, datatype.STATUS_OK, 100))
+		if sent0 != sent1 {
+			t.Errorf("trace %s is not sampled consistently by the queues", traceId)
+		}
+	}
+}
+
+func TestTraceItemsCapped(t *testing.T) {
+	thq := newPriorityQueue(10, 5, NewTraceSampler(1000))
+	p := thq.priority
+	hashes := make([]uint64, 0, 100)
+	for i := 0; i < 100; i++ {
+		traceId := fmt.Sprintf("trace-%d", i)
+		hashes = append(hashes, xxhash.ChecksumString64(traceId))
+		thq.sendWithPriority(newL7FlowLog(traceId, datatype.STATUS_OK, 100))
+	}
+	if len(p.traceItems) != 10 {
+		t.Fatalf("kept %d normal items, expected 10", len(p.traceItems))
+	}
+	if p.counter.NormalCount != 100 || p.counter.NormalDropCount != 90 {
+		t.Errorf("unexpected counter %+v", p.counter)
+	}
+
+	// the items with the smallest trace_id hash are kept
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+	kept := make([]uint64, 0, len(p.traceItems))
+	for _, item := range p.traceItems {
+		kept = append(kept, item.hash)
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i] < kept[j] })
+	for i := range kept {
+		if kept[i] != hashes[i] {
+			t.Errorf("kept hashes %v, expected %v", kept, hashes[:10])
+			break
+		}
+	}
+}
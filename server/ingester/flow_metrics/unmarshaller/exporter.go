@@ -136,6 +136,10 @@ func EncodeTo(e app.Document, protocol config.ExportProtocol, utags *utag.Univer
 		tags0, tags1 := QueryUniversalTags0(e, utags), QueryUniversalTags1(e, utags)
 		k8sLabels0, k8sLabels1 := utags.QueryCustomK8sLabels(e.OrgID(), e.Tags().PodID), utags.QueryCustomK8sLabels(e.OrgID(), e.Tags().PodID1)
 		return exportercommon.EncodeToJson(e, int(e.DataSource()), cfg, tags0, tags1, k8sLabels0, k8sLabels1), nil
+	case config.PROTOCOL_FILE:
+		tags0, tags1 := QueryUniversalTags0(e, utags), QueryUniversalTags1(e, utags)
+		k8sLabels0, k8sLabels1 := utags.QueryCustomK8sLabels(e.OrgID(), e.Tags().PodID), utags.QueryCustomK8sLabels(e.OrgID(), e.Tags().PodID1)
+		return exportercommon.EncodeToRow(e, int(e.DataSource()), cfg, tags0, tags1, k8sLabels0, k8sLabels1), nil
 	case config.PROTOCOL_PROMETHEUS:
 		return EncodeToPrometheus(e, utags, cfg)
 	default:
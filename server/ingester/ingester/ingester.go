@@ -53,6 +53,7 @@ import (
 	flowmetrics "github.com/deepflowio/deepflow/server/ingester/flow_metrics/flow_metrics"
 	pcapcfg "github.com/deepflowio/deepflow/server/ingester/pcap/config"
 	"github.com/deepflowio/deepflow/server/ingester/pcap/pcap"
+	"github.com/deepflowio/deepflow/server/ingester/pkg/ckwriter"
 	profilecfg "github.com/deepflowio/deepflow/server/ingester/profile/config"
 	"github.com/deepflowio/deepflow/server/ingester/profile/profile"
 	prometheuscfg "github.com/deepflowio/deepflow/server/ingester/prometheus/config"
@@ -99,6 +100,8 @@ func Start(configPath string, shared *servercommon.ControllerIngesterShared) []i
 	closers := []io.Closer{}
 
 	if cfg.IngesterEnabled {
+		ckwriter.SetSpillConfig(&cfg.CKWriterSpill)
+
 		flowLogConfig := flowlogcfg.Load(cfg, configPath)
 		bytes, _ = yaml.Marshal(flowLogConfig)
 		log.Infof("flow log config:\n%s", string(bytes))
@@ -205,7 +208,7 @@ func Start(configPath string, shared *servercommon.ControllerIngesterShared) []i
 			closers = append(closers, pcaper)
 
 			// write profile data
-			profile, err := profile.NewProfile(profileConfig, receiver, platformDataManager)
+			profile, err := profile.NewProfile(profileConfig, receiver, platformDataManager, exporters)
 			checkError(err)
 			profile.Start()
 			closers = append(closers, profile)
@@ -218,7 +221,7 @@ func Start(configPath string, shared *servercommon.ControllerIngesterShared) []i
 			ingesterOrgHandler.SetPromHandler(prometheus)
 
 			// write application log data
-			applicationLog, err := app_log.NewApplicationLogger(applicationLogConfig, receiver, platformDataManager)
+			applicationLog, err := app_log.NewApplicationLogger(applicationLogConfig, receiver, platformDataManager, exporters)
 			checkError(err)
 			applicationLog.Start()
 			closers = append(closers, applicationLog)
@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"sync"
@@ -51,6 +52,13 @@ const (
 
 var ckwriterManager = &CKWriterManager{}
 
+var spillConfig = &config.CKWriterSpill{}
+
+// SetSpillConfig should be called before creating CKWriters
+func SetSpillConfig(cfg *config.CKWriterSpill) {
+	spillConfig = cfg
+}
+
 type CKWriterManager struct {
 	ckwriters []*CKWriter
 	sync.Mutex
@@ -121,6 +129,9 @@ type QueueContext struct {
 	conns           []*ch.Client
 	connCount       int
 	counter         Counter
+	spill           *spillQueue // nil if spill is disabled
+	lastReplayTime  time.Time
+	replayRetries   int // the times the oldest spilled record is rejected by ClickHouse
 }
 
 func (qc *QueueContext) EndpointsChange(addrs []string) {
@@ -212,6 +223,8 @@ func (qc *QueueContext) initConn(connIndex int) error {
 		}
 	} else {
 		qc.conns[connIndex] = client
+		// ClickHouse is available again, replay the spilled items at the next flush tick
+		qc.lastReplayTime = time.Time{}
 	}
 	return err
 }
@@ -390,14 +403,25 @@ func NewCKWriter(addrs []string, user, password, counterName, timeZone string, t
 		}
 	}
 
+	name := fmt.Sprintf("%s-%s-%s", table.Database, table.LocalName, counterName)
 	queueContexts := make([]*QueueContext, queueCount)
 	for i := range queueContexts {
 		queueContexts[i] = &QueueContext{}
 		if err := queueContexts[i].Init(addrs, user, password, table); err != nil {
 			return nil, err
 		}
+		if spillConfig.TableEnabled(table.Database, table.LocalName) {
+			dir := filepath.Join(spillConfig.Dir, name, strconv.Itoa(i))
+			maxSize := int64(spillConfig.MaxSize) << 20 / int64(queueCount)
+			spill, err := newSpillQueue(dir, maxSize, time.Duration(spillConfig.MaxAge)*time.Second, &queueContexts[i].counter)
+			if err != nil {
+				// continue without spill, the failed batches are dropped as before
+				log.Warningf("ckwriter %s queue %d create spill queue in %s failed: %s", name, i, dir, err)
+			} else {
+				queueContexts[i].spill = spill
+			}
+		}
 	}
-	name := fmt.Sprintf("%s-%s-%s", table.Database, table.LocalName, counterName)
 	dataQueues := queue.NewOverwriteQueues(
 		name, queue.HashKey(queueCount), queueSize,
 		queue.OptionFlushIndicator(time.Second),
@@ -452,6 +476,10 @@ type Counter struct {
 	RetryCount        int64 `statsd:"retry-count"`
 	RetryFailedCount  int64 `statsd:"retry-failed-count"`
 	OrgInvalidCount   int64 `statsd:"org-invalid-count"`
+	SpilledCount      int64 `statsd:"spilled-count"`
+	SpillFailedCount  int64 `statsd:"spill-failed-count"`
+	SpillEvictedCount int64 `statsd:"spill-evicted-count"`
+	ReplayedCount     int64 `statsd:"replayed-count"`
 	utils.Closable
 }
 
@@ -543,6 +571,7 @@ func (w *CKWriter) queueProcess(queueID int) {
 						w.Write(queueID, cache)
 					}
 				}
+				w.replaySpill(queueID, false)
 			} else {
 				log.Warningf("get writer queue data type wrong %T", item)
 			}
@@ -563,20 +592,22 @@ func (c *Cache) Add(item CKItem) error {
 	return nil
 }
 
-func (c *Cache) Write() error {
+// Write writes the cached items, if failed, the items are spilled to disk when spill is enabled
+func (c *Cache) Write() (spilled bool, err error) {
 	if c.size == 0 {
-		return nil
+		return false, nil
 	}
 
 	connIndex := c.writeCounter % c.queueContext.connCount
 	conn := c.queueContext.conns[connIndex]
 	if conn == nil || conn.IsClosed() {
 		if err := c.queueContext.initConn(connIndex); err != nil {
+			spilled = c.Spill()
 			c.writeCounter++
 			c.lastWriteTime = time.Now()
 			c.size = 0
 			c.columnBlock.Reset()
-			return err
+			return spilled, err
 		}
 		conn = c.queueContext.conns[connIndex]
 	}
@@ -584,18 +615,38 @@ func (c *Cache) Write() error {
 	input := c.columnBlock.ToInput(c.protoInput)
 	c.protoInput = input
 
-	err := conn.Do(context.Background(), ch.Query{
+	err = conn.Do(context.Background(), ch.Query{
 		Body:  c.prepare,
 		Input: input,
 	})
+	if err != nil {
+		spilled = c.Spill()
+	}
 	c.writeCounter++
 	c.lastWriteTime = time.Now()
 	c.size = 0
 	c.columnBlock.Reset()
 	if err != nil {
-		return fmt.Errorf("batch item write block failed: %s", err)
+		return spilled, fmt.Errorf("batch item write block failed: %s", err)
 	}
-	return nil
+	return false, nil
+}
+
+// Spill stores the cached items to the spill queue, returns false if spill is disabled or failed
+func (c *Cache) Spill() bool {
+	qc := c.queueContext
+	if qc.spill == nil || c.size == 0 {
+		return false
+	}
+	c.protoInput = c.columnBlock.ToInput(c.protoInput[:0])
+	if err := qc.spill.Put(c.orgID, c.prepare, c.size, c.protoInput); err != nil {
+		if qc.counter.SpillFailedCount == 0 {
+			log.Warningf("spill (%s) failed: %s", c.prepare, err)
+		}
+		qc.counter.SpillFailedCount += int64(c.size)
+		return false
+	}
+	return true
 }
 
 func (w *CKWriter) ResetConnection(queueID, connID int) error {
@@ -635,7 +686,9 @@ func (w *CKWriter) Write(queueID int, cache *Cache) {
 		err := w.InitTable(queueID, cache.orgID)
 		if err != nil {
 			if logEnabled {
-				log.Warningf("create table (%s.%s) failed, drop (%d) items: %s", w.table.OrgDatabase(cache.orgID), w.table.LocalName, itemsLen, err)
+				log.Warningf("create table (%s.%s) failed, %s (%d) items: %s", w.table.OrgDatabase(cache.orgID), w.table.LocalName, dropOrSpill(cache.Spill()), itemsLen, err)
+			} else {
+				cache.Spill()
 			}
 			qc.counter.WriteFailedCount += int64(itemsLen)
 			cache.Release()
@@ -643,16 +696,106 @@ func (w *CKWriter) Write(queueID int, cache *Cache) {
 		}
 		cache.tableCreated = true
 	}
-	if err := cache.Write(); err != nil {
+	if spilled, err := cache.Write(); err != nil {
 		if logEnabled {
-			log.Warningf("write table (%s.%s) failed, drop (%d) items: %s", w.table.OrgDatabase(cache.orgID), w.table.LocalName, itemsLen, err)
+			log.Warningf("write table (%s.%s) failed, %s (%d) items: %s", w.table.OrgDatabase(cache.orgID), w.table.LocalName, dropOrSpill(spilled), itemsLen, err)
 		}
 		qc.counter.WriteFailedCount += int64(itemsLen)
 	} else {
 		qc.counter.WriteSuccessCount += int64(itemsLen)
+		w.replaySpill(queueID, true)
+	}
+}
+
+func dropOrSpill(spilled bool) string {
+	if spilled {
+		return "spill"
+	}
+	return "drop"
+}
+
+// replaySpill writes the spilled items again, it is called after a successful write (ClickHouse is available)
+// or by the flush ticker every replay interval
+func (w *CKWriter) replaySpill(queueID int, writeSucceeded bool) {
+	qc := w.queueContexts[queueID]
+	if qc.spill == nil {
+		return
+	}
+	now := time.Now()
+	qc.spill.Evict(now.Unix())
+	if qc.spill.Empty() {
+		return
+	}
+	if !writeSucceeded && now.Sub(qc.lastReplayTime) < time.Duration(spillConfig.ReplayInterval)*time.Second {
+		return
+	}
+	qc.lastReplayTime = now
+
+	for i := 0; i < SPILL_REPLAY_MAX_BATCH; i++ {
+		record, err := qc.spill.Peek()
+		if err != nil {
+			log.Warningf("ckwriter %s queue %d read spill queue failed: %s", w.name, queueID, err)
+			continue
+		}
+		if record == nil {
+			return
+		}
+		if int(record.orgID) >= len(qc.orgCaches) || !qc.orgCaches[record.orgID].OrgIdExists() {
+			log.Warningf("table (%s.%s) orgId is not exist, drop (%d) spilled items", w.table.OrgDatabase(record.orgID), w.table.LocalName, record.rows)
+			qc.counter.SpillEvictedCount += int64(record.rows)
+			qc.replayRetries = 0
+			qc.spill.Commit(record)
+			continue
+		}
+		if err := w.replayRecord(queueID, record); err != nil {
+			if !ch.IsException(err) {
+				// ClickHouse is unavailable, the record is kept until it expires
+				log.Warningf("ckwriter %s queue %d replay (%d) spilled items failed, will retry later: %s", w.name, queueID, record.rows, err)
+				return
+			}
+			// ClickHouse rejects the record, e.g. the table is changed, retrying it forever would block the queue
+			qc.replayRetries++
+			if qc.replayRetries < SPILL_REPLAY_MAX_RETRY {
+				log.Warningf("ckwriter %s queue %d replay (%d) spilled items rejected (%d/%d), will retry later: %s", w.name, queueID, record.rows, qc.replayRetries, SPILL_REPLAY_MAX_RETRY, err)
+				return
+			}
+			log.Warningf("ckwriter %s queue %d replay (%d) spilled items rejected %d times, drop them: %s", w.name, queueID, record.rows, qc.replayRetries, err)
+			qc.counter.SpillEvictedCount += int64(record.rows)
+			qc.replayRetries = 0
+			qc.spill.Commit(record)
+			continue
+		}
+		qc.counter.ReplayedCount += int64(record.rows)
+		qc.replayRetries = 0
+		qc.spill.Commit(record)
 	}
 }
 
+func (w *CKWriter) replayRecord(queueID int, record *spillRecord) error {
+	qc := w.queueContexts[queueID]
+	cache := qc.orgCaches[record.orgID]
+	if !cache.tableCreated {
+		if err := w.InitTable(queueID, record.orgID); err != nil {
+			return err
+		}
+		cache.tableCreated = true
+	}
+
+	connIndex := cache.writeCounter % qc.connCount
+	cache.writeCounter++
+	conn := qc.conns[connIndex]
+	if conn == nil || conn.IsClosed() {
+		if err := qc.initConn(connIndex); err != nil {
+			return err
+		}
+		conn = qc.conns[connIndex]
+	}
+	return conn.Do(context.Background(), ch.Query{
+		Body:  record.query,
+		Input: record.input,
+	})
+}
+
 func IsNil(i interface{}) bool {
 	if i == nil {
 		return true
@@ -674,6 +817,9 @@ func (w *CKWriter) Close() {
 				qc.conns[i] = nil
 			}
 		}
+		if qc.spill != nil {
+			qc.spill.Close()
+		}
 		qc.counter.Close()
 	}
 
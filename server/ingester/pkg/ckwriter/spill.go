@@ -0,0 +1,500 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ckwriter
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/ch-go/proto"
+)
+
+const (
+	SPILL_FILE_SUFFIX      = ".spill"
+	SPILL_SEGMENT_SIZE     = 16 << 20
+	SPILL_RECORD_HEADER    = 8 // 4 bytes payload length + 4 bytes crc32 of payload
+	SPILL_RECORD_MAX_SIZE  = 1 << 30
+	SPILL_REPLAY_MAX_BATCH = 64 // max records replayed at a time, avoid blocking the writer queue
+	SPILL_REPLAY_MAX_RETRY = 3  // the record rejected by ClickHouse this many times is dropped
+)
+
+var errSpillCorrupted = errors.New("spill record corrupted")
+
+// rawColumn is a column which is already encoded in ClickHouse native format (including the state prefix)
+type rawColumn struct {
+	typ  proto.ColumnType
+	rows int
+	data []byte
+}
+
+func (c *rawColumn) Type() proto.ColumnType       { return c.typ }
+func (c *rawColumn) Rows() int                    { return c.rows }
+func (c *rawColumn) EncodeColumn(b *proto.Buffer) { b.PutRaw(c.data) }
+func (c *rawColumn) WriteColumn(w *proto.Writer)  { w.ChainWrite(c.data) }
+
+type spillRecord struct {
+	time  int64 // unix seconds when spilled
+	orgID uint16
+	rows  int
+	query string
+	input proto.Input
+	size  int64 // encoded size including the header
+}
+
+// freshColumn copies LowCardinality columns, because they may have been prepared by the failed write,
+// and preparing a LowCardinality column twice without reset generates wrong keys.
+// Enum columns are stored as String, since their type is only inferred after the first successful write,
+// and ClickHouse converts them back when inserting.
+func freshColumn(col proto.ColInput) proto.ColInput {
+	switch c := col.(type) {
+	case *proto.ColEnum:
+		fresh := &proto.ColStr{}
+		fresh.AppendArr(c.Values)
+		return fresh
+	case *proto.ColLowCardinality[string]:
+		fresh := new(proto.ColStr).LowCardinality()
+		fresh.AppendArr(c.Values)
+		return fresh
+	case *proto.ColArr[string]:
+		if lc, ok := c.Data.(*proto.ColLowCardinality[string]); ok {
+			fresh := new(proto.ColStr).LowCardinality()
+			fresh.AppendArr(lc.Values)
+			return &proto.ColArr[string]{
+				Offsets: append(proto.ColUInt64{}, c.Offsets...),
+				Data:    fresh,
+			}
+		}
+	}
+	return col
+}
+
+// encodeSpillRecord encodes the record as:
+//
+//	| payload length (uint32) | crc32 of payload (uint32) | payload |
+//
+// payload:
+//
+//	| time (varint) | orgID (uvarint) | rows (uvarint) | query (string) | column count (uvarint) | columns |
+//
+// column:
+//
+//	| name (string) | type (string) | data (string) |
+//
+// and a string is encoded as its length (uvarint) followed by the bytes.
+func encodeSpillRecord(buf []byte, now int64, orgID uint16, rows int, query string, input proto.Input) ([]byte, error) {
+	buf = append(buf[:0], make([]byte, SPILL_RECORD_HEADER)...)
+	buf = binary.AppendVarint(buf, now)
+	buf = binary.AppendUvarint(buf, uint64(orgID))
+	buf = binary.AppendUvarint(buf, uint64(rows))
+	buf = appendString(buf, query)
+	buf = binary.AppendUvarint(buf, uint64(len(input)))
+
+	colBuf := &proto.Buffer{}
+	for _, col := range input {
+		data := freshColumn(col.Data)
+		if data.Rows() != rows {
+			return nil, fmt.Errorf("column %s has %d rows, expected %d", col.Name, data.Rows(), rows)
+		}
+		if v, ok := data.(proto.Preparable); ok {
+			if err := v.Prepare(); err != nil {
+				return nil, fmt.Errorf("prepare column %s failed: %s", col.Name, err)
+			}
+		}
+		colBuf.Reset()
+		if rows > 0 {
+			if v, ok := data.(proto.StateEncoder); ok {
+				v.EncodeState(colBuf)
+			}
+			data.EncodeColumn(colBuf)
+		}
+		buf = appendString(buf, col.Name)
+		buf = appendString(buf, string(data.Type()))
+		buf = binary.AppendUvarint(buf, uint64(len(colBuf.Buf)))
+		buf = append(buf, colBuf.Buf...)
+	}
+
+	payloadLen := len(buf) - SPILL_RECORD_HEADER
+	if payloadLen > SPILL_RECORD_MAX_SIZE {
+		return nil, fmt.Errorf("spill record size %d exceeds %d", payloadLen, SPILL_RECORD_MAX_SIZE)
+	}
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(payloadLen))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(buf[SPILL_RECORD_HEADER:]))
+	return buf, nil
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+type spillDecoder struct {
+	buf    []byte
+	offset int
+	err    error
+}
+
+func (d *spillDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.buf[d.offset:])
+	if n <= 0 {
+		d.err = errSpillCorrupted
+		return 0
+	}
+	d.offset += n
+	return v
+}
+
+func (d *spillDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf[d.offset:])
+	if n <= 0 {
+		d.err = errSpillCorrupted
+		return 0
+	}
+	d.offset += n
+	return v
+}
+
+func (d *spillDecoder) bytes() []byte {
+	l := d.uvarint()
+	if d.err != nil {
+		return nil
+	}
+	if l > uint64(len(d.buf)-d.offset) {
+		d.err = errSpillCorrupted
+		return nil
+	}
+	b := d.buf[d.offset : d.offset+int(l)]
+	d.offset += int(l)
+	return b
+}
+
+func decodeSpillPayload(payload []byte) (*spillRecord, error) {
+	d := &spillDecoder{buf: payload}
+	r := &spillRecord{
+		time:  d.varint(),
+		orgID: uint16(d.uvarint()),
+		rows:  int(d.uvarint()),
+		query: string(d.bytes()),
+	}
+	columnCount := int(d.uvarint())
+	if d.err != nil {
+		return nil, d.err
+	}
+	r.input = make(proto.Input, 0, columnCount)
+	for i := 0; i < columnCount; i++ {
+		name := string(d.bytes())
+		typ := proto.ColumnType(d.bytes())
+		data := d.bytes()
+		if d.err != nil {
+			return nil, d.err
+		}
+		r.input = append(r.input, proto.InputColumn{
+			Name: name,
+			Data: &rawColumn{typ: typ, rows: r.rows, data: data},
+		})
+	}
+	r.size = int64(len(payload) + SPILL_RECORD_HEADER)
+	return r, nil
+}
+
+// readSpillRecord reads one record, returns io.EOF if there is no complete record
+func readSpillRecord(reader io.Reader) (*spillRecord, error) {
+	var header [SPILL_RECORD_HEADER]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	payloadLen := binary.LittleEndian.Uint32(header[0:4])
+	if payloadLen > SPILL_RECORD_MAX_SIZE {
+		return nil, errSpillCorrupted
+	}
+	payload := make([]byte, payloadLen)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:8]) {
+		return nil, errSpillCorrupted
+	}
+	return decodeSpillPayload(payload)
+}
+
+type spillSegment struct {
+	id         uint64
+	path       string
+	size       int64 // bytes of complete records
+	items      int64 // rows not replayed yet
+	lastTime   int64 // unix seconds of the newest record
+	readOffset int64
+	reader     *os.File
+}
+
+func (s *spillSegment) closeReader() {
+	if s.reader != nil {
+		s.reader.Close()
+		s.reader = nil
+	}
+}
+
+// spillQueue is an on-disk FIFO of the batches which failed to be written, the files are split into segments,
+// the oldest segments are evicted when the total size exceeds maxSize or the records are older than maxAge.
+// It is only accessed by the goroutine of its writer queue, so it is not goroutine-safe.
+type spillQueue struct {
+	dir       string
+	maxSize   int64
+	maxAge    int64 // s
+	segments  []*spillSegment
+	totalSize int64
+	writer    *os.File // write to the last segment
+	nextID    uint64
+	buf       []byte
+	counter   *Counter
+}
+
+func newSpillQueue(dir string, maxSize int64, maxAge time.Duration, counter *Counter) (*spillQueue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	q := &spillQueue{
+		dir:     dir,
+		maxSize: maxSize,
+		maxAge:  int64(maxAge / time.Second),
+		counter: counter,
+	}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// load rebuilds the segments from the files left by the last run
+func (q *spillQueue) load() error {
+	files, err := os.ReadDir(q.dir)
+	if err != nil {
+		return err
+	}
+	ids := []uint64{}
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, SPILL_FILE_SUFFIX) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, SPILL_FILE_SUFFIX), 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		segment := &spillSegment{id: id, path: q.segmentPath(id)}
+		if err := q.scanSegment(segment); err != nil {
+			log.Warningf("spill segment %s is corrupted, only %d bytes are kept: %s", segment.path, segment.size, err)
+		}
+		if segment.size == 0 {
+			os.Remove(segment.path)
+			continue
+		}
+		// truncate the incomplete record at the end
+		if err := os.Truncate(segment.path, segment.size); err != nil {
+			log.Warningf("truncate spill segment %s failed: %s", segment.path, err)
+		}
+		q.segments = append(q.segments, segment)
+		q.totalSize += segment.size
+		q.nextID = id + 1
+	}
+	if len(q.segments) > 0 {
+		log.Infof("load %d spill segments (%d bytes) from %s", len(q.segments), q.totalSize, q.dir)
+	}
+	return nil
+}
+
+func (q *spillQueue) scanSegment(segment *spillSegment) error {
+	f, err := os.Open(segment.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	reader := bufio.NewReader(f)
+	for {
+		record, err := readSpillRecord(reader)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		segment.size += record.size
+		segment.items += int64(record.rows)
+		segment.lastTime = record.time
+	}
+}
+
+func (q *spillQueue) segmentPath(id uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", id, SPILL_FILE_SUFFIX))
+}
+
+func (q *spillQueue) Empty() bool {
+	return len(q.segments) == 0
+}
+
+// Put appends the batch to the queue
+func (q *spillQueue) Put(orgID uint16, query string, rows int, input proto.Input) error {
+	now := time.Now().Unix()
+	buf, err := encodeSpillRecord(q.buf, now, orgID, rows, query, input)
+	if err != nil {
+		return err
+	}
+	q.buf = buf
+
+	var segment *spillSegment
+	if q.writer != nil {
+		segment = q.segments[len(q.segments)-1]
+		if segment.size+int64(len(buf)) > SPILL_SEGMENT_SIZE {
+			q.writer.Close()
+			q.writer = nil
+		}
+	}
+	if q.writer == nil {
+		segment = &spillSegment{id: q.nextID, path: q.segmentPath(q.nextID)}
+		if q.writer, err = os.OpenFile(segment.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644); err != nil {
+			q.writer = nil
+			return err
+		}
+		q.nextID++
+		q.segments = append(q.segments, segment)
+	}
+
+	if _, err := q.writer.Write(buf); err != nil {
+		// drop the incomplete record, the segment will not be written any more
+		q.writer.Close()
+		q.writer = nil
+		os.Truncate(segment.path, segment.size)
+		return err
+	}
+	segment.size += int64(len(buf))
+	segment.items += int64(rows)
+	segment.lastTime = now
+	q.totalSize += int64(len(buf))
+	q.counter.SpilledCount += int64(rows)
+
+	q.Evict(now)
+	return nil
+}
+
+// Evict removes the oldest segments exceeding the size limit and the segments exceeding the age limit
+func (q *spillQueue) Evict(now int64) {
+	for len(q.segments) > 0 {
+		oldest := q.segments[0]
+		if q.totalSize <= q.maxSize && oldest.lastTime+q.maxAge >= now {
+			break
+		}
+		log.Warningf("evict spill segment %s, drop (%d) items", oldest.path, oldest.items)
+		q.counter.SpillEvictedCount += oldest.items
+		q.removeOldest()
+	}
+}
+
+func (q *spillQueue) removeOldest() {
+	oldest := q.segments[0]
+	oldest.closeReader()
+	if len(q.segments) == 1 && q.writer != nil {
+		q.writer.Close()
+		q.writer = nil
+	}
+	os.Remove(oldest.path)
+	q.totalSize -= oldest.size
+	q.segments[0] = nil
+	q.segments = q.segments[1:]
+}
+
+// Peek returns the oldest record without removing it, nil if the queue is empty
+func (q *spillQueue) Peek() (*spillRecord, error) {
+	for len(q.segments) > 0 {
+		oldest := q.segments[0]
+		if oldest.readOffset >= oldest.size {
+			if q.writer != nil && len(q.segments) == 1 {
+				// the writing segment is fully replayed
+				q.removeOldest()
+				return nil, nil
+			}
+			q.removeOldest()
+			continue
+		}
+		if oldest.reader == nil {
+			f, err := os.Open(oldest.path)
+			if err != nil {
+				q.counter.SpillEvictedCount += oldest.items
+				q.removeOldest()
+				return nil, err
+			}
+			oldest.reader = f
+		}
+		record, err := readSpillRecord(io.NewSectionReader(oldest.reader, oldest.readOffset, oldest.size-oldest.readOffset))
+		if err != nil {
+			// the rest of the segment can not be read
+			log.Warningf("read spill segment %s at %d failed, drop (%d) items: %s", oldest.path, oldest.readOffset, oldest.items, err)
+			q.counter.SpillEvictedCount += oldest.items
+			q.removeOldest()
+			continue
+		}
+		return record, nil
+	}
+	return nil, nil
+}
+
+// Commit removes the record returned by Peek after it is written
+func (q *spillQueue) Commit(record *spillRecord) {
+	if len(q.segments) == 0 {
+		return
+	}
+	oldest := q.segments[0]
+	oldest.readOffset += record.size
+	oldest.items -= int64(record.rows)
+	if oldest.readOffset >= oldest.size && (q.writer == nil || len(q.segments) > 1) {
+		q.removeOldest()
+	}
+}
+
+func (q *spillQueue) Close() {
+	if q.writer != nil {
+		q.writer.Close()
+		q.writer = nil
+	}
+	for _, segment := range q.segments {
+		segment.closeReader()
+	}
+}
@@ -0,0 +1,144 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ckwriter
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/ch-go/proto"
+)
+
+func testInput(rows int) proto.Input {
+	ids := &proto.ColUInt64{}
+	names := new(proto.ColStr).LowCardinality()
+	tags := new(proto.ColStr).LowCardinality().Array()
+	for i := 0; i < rows; i++ {
+		ids.Append(uint64(i))
+		names.Append([]string{"a", "b"}[i%2])
+		tags.Append([]string{"x", "y"})
+	}
+	return proto.Input{
+		{Name: "id", Data: ids},
+		{Name: "name", Data: names},
+		{Name: "tags", Data: tags},
+	}
+}
+
+func encodeBlock(t *testing.T, input proto.Input) []byte {
+	buf := &proto.Buffer{}
+	block := &proto.Block{Columns: len(input), Rows: input[0].Data.Rows()}
+	if err := block.EncodeRawBlock(buf, 54451, input); err != nil {
+		t.Fatalf("encode block failed: %s", err)
+	}
+	return buf.Buf
+}
+
+func TestSpillQueueRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	counter := &Counter{}
+	q, err := newSpillQueue(dir, 1<<20, time.Hour, counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := testInput(10)
+	// prepare the columns like a failed write did
+	expected := encodeBlock(t, testInput(10))
+	encodeBlock(t, input)
+	if err := q.Put(1, "INSERT INTO flow_log.`l7_flow_log` VALUES", 10, input); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Put(2, "INSERT INTO flow_log.`l4_flow_log` VALUES", 10, testInput(10)); err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+	if counter.SpilledCount != 20 {
+		t.Errorf("spilled count == %d, expected 20", counter.SpilledCount)
+	}
+
+	// reload from disk
+	q, err = newSpillQueue(dir, 1<<20, time.Hour, counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	record, err := q.Peek()
+	if err != nil || record == nil {
+		t.Fatalf("peek failed: %v %v", err, record)
+	}
+	if record.orgID != 1 || record.rows != 10 || record.query != "INSERT INTO flow_log.`l7_flow_log` VALUES" {
+		t.Errorf("unexpected record %+v", record)
+	}
+	if got := encodeBlock(t, record.input); !bytes.Equal(got, expected) {
+		t.Errorf("replayed block differs from the original block")
+	}
+	q.Commit(record)
+
+	record, _ = q.Peek()
+	if record == nil || record.orgID != 2 {
+		t.Fatalf("unexpected second record %+v", record)
+	}
+	q.Commit(record)
+	if record, _ = q.Peek(); record != nil || !q.Empty() {
+		t.Errorf("queue should be empty, got %+v", record)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("replayed segments should be removed, got %d files", len(files))
+	}
+}
+
+func TestSpillQueueEvict(t *testing.T) {
+	counter := &Counter{}
+	q, err := newSpillQueue(t.TempDir(), 1, time.Hour, counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if err := q.Put(1, "INSERT INTO t VALUES", 10, testInput(10)); err != nil {
+		t.Fatal(err)
+	}
+	if !q.Empty() || counter.SpillEvictedCount != 10 {
+		t.Errorf("exceeding max size should be evicted, evicted count %d", counter.SpillEvictedCount)
+	}
+
+	q.maxSize = 1 << 20
+	if err := q.Put(1, "INSERT INTO t VALUES", 5, testInput(5)); err != nil {
+		t.Fatal(err)
+	}
+	q.Evict(time.Now().Unix() + 2*3600)
+	if !q.Empty() || counter.SpillEvictedCount != 15 {
+		t.Errorf("exceeding max age should be evicted, evicted count %d", counter.SpillEvictedCount)
+	}
+}
+
+func TestSpillRecordCorrupted(t *testing.T) {
+	buf, err := encodeSpillRecord(nil, 0, 1, 3, "INSERT INTO t VALUES", testInput(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf[len(buf)-1] ^= 0xff
+	if _, err := readSpillRecord(bytes.NewReader(buf)); err != errSpillCorrupted {
+		t.Errorf("readSpillRecord of corrupted record returns %v, expected %v", err, errSpillCorrupted)
+	}
+	if _, err := readSpillRecord(bytes.NewReader(buf[:len(buf)/2])); err != io.EOF {
+		t.Errorf("readSpillRecord of truncated record returns %v, expected EOF", err)
+	}
+}
@@ -26,6 +26,9 @@ import (
 	"time"
 
 	"github.com/deepflowio/deepflow/server/ingester/common"
+	"github.com/deepflowio/deepflow/server/ingester/exporters"
+	exporterscommon "github.com/deepflowio/deepflow/server/ingester/exporters/common"
+	exportersconfig "github.com/deepflowio/deepflow/server/ingester/exporters/config"
 	"github.com/deepflowio/deepflow/server/ingester/flow_tag"
 	profile_common "github.com/deepflowio/deepflow/server/ingester/profile/common"
 	"github.com/deepflowio/deepflow/server/ingester/profile/dbwriter"
@@ -104,6 +107,7 @@ type Decoder struct {
 	inQueue             queue.QueueReader
 	profileWriter       *dbwriter.ProfileWriter
 	appServiceTagWriter *flow_tag.AppServiceTagWriter
+	exporters           *exporters.Exporters
 	compressionAlgo     string
 
 	offCpuSplittingGranularity int
@@ -120,7 +124,8 @@ func NewDecoder(index int, msgType datatype.MessageType, compressionAlgo string,
 	platformData *grpc.PlatformInfoTable,
 	inQueue queue.QueueReader,
 	profileWriter *dbwriter.ProfileWriter,
-	appServiceTagWriter *flow_tag.AppServiceTagWriter) *Decoder {
+	appServiceTagWriter *flow_tag.AppServiceTagWriter,
+	exporters *exporters.Exporters) *Decoder {
 	return &Decoder{
 		index:                      index,
 		msgType:                    msgType,
@@ -128,6 +133,7 @@ func NewDecoder(index int, msgType datatype.MessageType, compressionAlgo string,
 		inQueue:                    inQueue,
 		profileWriter:              profileWriter,
 		appServiceTagWriter:        appServiceTagWriter,
+		exporters:                  exporters,
 		compressionAlgo:            compressionAlgo,
 		offCpuSplittingGranularity: offCpuSplittingGranularity,
 		counter:                    &Counter{},
@@ -154,6 +160,7 @@ func (d *Decoder) Run() {
 		start := time.Now()
 		for i := 0; i < n; i++ {
 			if buffer[i] == nil {
+				d.export(nil)
 				continue
 			}
 			atomic.AddInt64(&d.counter.RawCount, 1)
@@ -173,6 +180,20 @@ func (d *Decoder) Run() {
 	}
 }
 
+func (d *Decoder) export(item exporterscommon.ExportItem) {
+	if d.exporters == nil {
+		return
+	}
+	d.exporters.Put(uint32(exportersconfig.IN_PROCESS_PROFILE), d.index, item)
+}
+
+func (d *Decoder) profileWrite(items []interface{}) {
+	for _, item := range items {
+		d.export(item.(*dbwriter.InProcessProfile))
+	}
+	d.profileWriter.Write(items)
+}
+
 func (d *Decoder) appServiceTagWrite(p *dbwriter.InProcessProfile) {
 	if d.appServiceTagWriter == nil {
 		return
@@ -199,7 +220,7 @@ func (d *Decoder) handleProfileData(vtapID uint16, decoder *codec.SimpleDecoder)
 			orgId:                       d.orgId,
 			teamId:                      d.teamId,
 			inTimestamp:                 time.Now(),
-			profileWriterCallback:       d.profileWriter.Write,
+			profileWriterCallback:       d.profileWrite,
 			appServiceTagWriterCallback: d.appServiceTagWrite,
 			platformData:                d.platformData,
 			IP:                          make([]byte, len(profile.Ip)),
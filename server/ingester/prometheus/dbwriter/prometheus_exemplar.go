@@ -0,0 +1,127 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dbwriter
+
+import (
+	"github.com/deepflowio/deepflow/server/ingester/common"
+	"github.com/deepflowio/deepflow/server/libs/ckdb"
+	"github.com/deepflowio/deepflow/server/libs/pool"
+)
+
+const (
+	PROMETHEUS_EXEMPLAR_TABLE = "exemplars"
+)
+
+// PrometheusExemplar is an exemplar of the prometheus time series, the trace_id and span_id labels of the exemplar
+// are stored in separate columns for jumping to the l7_flow_log, and the other labels are stored in attributes.
+type PrometheusExemplar struct {
+	Time      uint32 // s
+	Timestamp int64  // us
+	VtapId    uint16
+	MetricID  uint32
+	TargetID  uint32
+
+	// Not stored, only determines which database to store in.
+	OrgId  uint16
+	TeamID uint16
+	// the same as the app_label_value_id_<index> columns of the samples table, AppLabelValueIDs[0] is not stored
+	AppLabelValueIDs []uint32
+
+	Value           float64
+	TraceID         string
+	SpanID          string
+	AttributeNames  []string
+	AttributeValues []string
+}
+
+func (m *PrometheusExemplar) DatabaseName() string {
+	return PROMETHEUS_DB
+}
+
+func (m *PrometheusExemplar) TableName() string {
+	return PROMETHEUS_EXEMPLAR_TABLE
+}
+
+func (m *PrometheusExemplar) OrgID() uint16 {
+	return m.OrgId
+}
+
+func (m *PrometheusExemplar) NativeTagVersion() uint32 {
+	return 0
+}
+
+func (m *PrometheusExemplar) Release() {
+	ReleasePrometheusExemplar(m)
+}
+
+func PrometheusExemplarColumns() []*ckdb.Column {
+	return []*ckdb.Column{
+		ckdb.NewColumn("time", ckdb.DateTime),
+		ckdb.NewColumn("timestamp", ckdb.DateTime64us).SetComment("precision: us"),
+		ckdb.NewColumn("metric_id", ckdb.UInt32).SetComment("encoded ID of the metric name"),
+		ckdb.NewColumn("target_id", ckdb.UInt32).SetComment("the encoded ID of the target"),
+		ckdb.NewColumn("team_id", ckdb.UInt16).SetComment("the team ID"),
+		ckdb.NewColumn("agent_id", ckdb.UInt16).SetComment("Agent ID"),
+		ckdb.NewColumn("app_label_value_ids", ckdb.ArrayUInt32).SetComment("the Nth element is the value of the column app_label_value_id_N in the samples table"),
+		ckdb.NewColumn("value", ckdb.Float64),
+		ckdb.NewColumn("trace_id", ckdb.String).SetCodec(ckdb.CodecZSTD).SetIndex(ckdb.IndexBloomfilter).SetComment("Trace ID"),
+		ckdb.NewColumn("span_id", ckdb.String).SetCodec(ckdb.CodecZSTD).SetComment("Span ID"),
+		ckdb.NewColumn("attribute_names", ckdb.ArrayLowCardinalityString).SetComment("the label names of the exemplar except trace_id and span_id"),
+		ckdb.NewColumn("attribute_values", ckdb.ArrayString).SetComment("the label values of the exemplar except trace_id and span_id"),
+	}
+}
+
+func GenPrometheusExemplarCKTable(cluster, storagePolicy, ckdbType string, ttl int, coldStorage *ckdb.ColdStorage) *ckdb.Table {
+	timeKey := "time"
+	engine := ckdb.MergeTree
+	orderKeys := []string{"metric_id", timeKey}
+
+	return &ckdb.Table{
+		Version:         common.CK_VERSION,
+		Database:        PROMETHEUS_DB,
+		DBType:          ckdbType,
+		LocalName:       PROMETHEUS_EXEMPLAR_TABLE + ckdb.LOCAL_SUBFFIX,
+		GlobalName:      PROMETHEUS_EXEMPLAR_TABLE,
+		Columns:         PrometheusExemplarColumns(),
+		TimeKey:         timeKey,
+		TTL:             ttl,
+		PartitionFunc:   DefaultPartition,
+		Engine:          engine,
+		Cluster:         cluster,
+		StoragePolicy:   storagePolicy,
+		ColdStorage:     *coldStorage,
+		OrderKeys:       orderKeys,
+		PrimaryKeyCount: len(orderKeys),
+	}
+}
+
+var prometheusExemplarPool = pool.NewLockFreePool(func() *PrometheusExemplar {
+	return &PrometheusExemplar{}
+})
+
+func AcquirePrometheusExemplar() *PrometheusExemplar {
+	return prometheusExemplarPool.Get()
+}
+
+func ReleasePrometheusExemplar(p *PrometheusExemplar) {
+	appLabelValueIDs := p.AppLabelValueIDs[:0]
+	attributeNames, attributeValues := p.AttributeNames[:0], p.AttributeValues[:0]
+	*p = PrometheusExemplar{}
+	p.AppLabelValueIDs = appLabelValueIDs
+	p.AttributeNames, p.AttributeValues = attributeNames, attributeValues
+	prometheusExemplarPool.Put(p)
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dbwriter
+
+import (
+	"github.com/ClickHouse/ch-go/proto"
+
+	"github.com/deepflowio/deepflow/server/libs/ckdb"
+)
+
+type PrometheusExemplarBlock struct {
+	ColTime             proto.ColDateTime
+	ColTimestamp        proto.ColDateTime64
+	ColMetricId         proto.ColUInt32
+	ColTargetId         proto.ColUInt32
+	ColTeamId           proto.ColUInt16
+	ColAgentId          proto.ColUInt16
+	ColAppLabelValueIds *proto.ColArr[uint32]
+	ColValue            proto.ColFloat64
+	ColTraceId          proto.ColStr
+	ColSpanId           proto.ColStr
+	ColAttributeNames   *proto.ColArr[string]
+	ColAttributeValues  *proto.ColArr[string]
+}
+
+func (b *PrometheusExemplarBlock) Reset() {
+	b.ColTime.Reset()
+	b.ColTimestamp.Reset()
+	b.ColMetricId.Reset()
+	b.ColTargetId.Reset()
+	b.ColTeamId.Reset()
+	b.ColAgentId.Reset()
+	b.ColAppLabelValueIds.Reset()
+	b.ColValue.Reset()
+	b.ColTraceId.Reset()
+	b.ColSpanId.Reset()
+	b.ColAttributeNames.Reset()
+	b.ColAttributeValues.Reset()
+}
+
+func (b *PrometheusExemplarBlock) ToInput(input proto.Input) proto.Input {
+	return append(input,
+		proto.InputColumn{Name: ckdb.COLUMN_TIME, Data: &b.ColTime},
+		proto.InputColumn{Name: ckdb.COLUMN_TIMESTAMP, Data: &b.ColTimestamp},
+		proto.InputColumn{Name: ckdb.COLUMN_METRIC_ID, Data: &b.ColMetricId},
+		proto.InputColumn{Name: ckdb.COLUMN_TARGET_ID, Data: &b.ColTargetId},
+		proto.InputColumn{Name: ckdb.COLUMN_TEAM_ID, Data: &b.ColTeamId},
+		proto.InputColumn{Name: ckdb.COLUMN_AGENT_ID, Data: &b.ColAgentId},
+		proto.InputColumn{Name: ckdb.COLUMN_APP_LABEL_VALUE_IDS, Data: b.ColAppLabelValueIds},
+		proto.InputColumn{Name: ckdb.COLUMN_VALUE, Data: &b.ColValue},
+		proto.InputColumn{Name: ckdb.COLUMN_TRACE_ID, Data: &b.ColTraceId},
+		proto.InputColumn{Name: ckdb.COLUMN_SPAN_ID, Data: &b.ColSpanId},
+		proto.InputColumn{Name: ckdb.COLUMN_ATTRIBUTE_NAMES, Data: b.ColAttributeNames},
+		proto.InputColumn{Name: ckdb.COLUMN_ATTRIBUTE_VALUES, Data: b.ColAttributeValues},
+	)
+}
+
+func (n *PrometheusExemplar) NewColumnBlock() ckdb.CKColumnBlock {
+	return &PrometheusExemplarBlock{
+		ColAppLabelValueIds: new(proto.ColUInt32).Array(),
+		ColAttributeNames:   new(proto.ColStr).LowCardinality().Array(),
+		ColAttributeValues:  new(proto.ColStr).Array(),
+	}
+}
+
+func (n *PrometheusExemplar) AppendToColumnBlock(b ckdb.CKColumnBlock) {
+	block := b.(*PrometheusExemplarBlock)
+	ckdb.AppendColDateTime(&block.ColTime, n.Time)
+	ckdb.AppendColDateTime64Micro(&block.ColTimestamp, n.Timestamp)
+	block.ColMetricId.Append(n.MetricID)
+	block.ColTargetId.Append(n.TargetID)
+	block.ColTeamId.Append(n.TeamID)
+	block.ColAgentId.Append(n.VtapId)
+	if len(n.AppLabelValueIDs) > 0 {
+		block.ColAppLabelValueIds.Append(n.AppLabelValueIDs[1:])
+	} else {
+		block.ColAppLabelValueIds.Append(n.AppLabelValueIDs)
+	}
+	block.ColValue.Append(n.Value)
+	block.ColTraceId.Append(n.TraceID)
+	block.ColSpanId.Append(n.SpanID)
+	block.ColAttributeNames.Append(n.AttributeNames)
+	block.ColAttributeValues.Append(n.AttributeValues)
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dbwriter
+
+import (
+	"github.com/deepflowio/deepflow/server/ingester/common"
+	"github.com/deepflowio/deepflow/server/libs/ckdb"
+	"github.com/deepflowio/deepflow/server/libs/pool"
+)
+
+const (
+	PROMETHEUS_HISTOGRAM_TABLE = "histograms"
+)
+
+// PrometheusHistogram is a sample of the prometheus native histogram, the sparse buckets are stored as they are
+// received, the querier expands them into the '<name>_bucket', '<name>_count' and '<name>_sum' series.
+type PrometheusHistogram struct {
+	Time     uint32 // s
+	VtapId   uint16
+	MetricID uint32
+	TargetID uint32
+
+	// Not stored, only determines which database to store in.
+	OrgId  uint16
+	TeamID uint16
+	// the same as the app_label_value_id_<index> columns of the samples table, AppLabelValueIDs[0] is not stored
+	AppLabelValueIDs []uint32
+
+	Count         float64
+	Sum           float64
+	Schema        int32
+	ZeroThreshold float64
+	ZeroCount     float64
+
+	// the offsets and lengths of the bucket spans, and the absolute count of every bucket in the spans
+	PositiveSpanOffsets []int32
+	PositiveSpanLengths []uint32
+	PositiveCounts      []float64
+	NegativeSpanOffsets []int32
+	NegativeSpanLengths []uint32
+	NegativeCounts      []float64
+}
+
+func (m *PrometheusHistogram) DatabaseName() string {
+	return PROMETHEUS_DB
+}
+
+func (m *PrometheusHistogram) TableName() string {
+	return PROMETHEUS_HISTOGRAM_TABLE
+}
+
+func (m *PrometheusHistogram) OrgID() uint16 {
+	return m.OrgId
+}
+
+func (m *PrometheusHistogram) NativeTagVersion() uint32 {
+	return 0
+}
+
+func (m *PrometheusHistogram) Release() {
+	ReleasePrometheusHistogram(m)
+}
+
+func PrometheusHistogramColumns() []*ckdb.Column {
+	return []*ckdb.Column{
+		ckdb.NewColumn("time", ckdb.DateTime),
+		ckdb.NewColumn("metric_id", ckdb.UInt32).SetComment("encoded ID of the metric name"),
+		ckdb.NewColumn("target_id", ckdb.UInt32).SetComment("the encoded ID of the target"),
+		ckdb.NewColumn("team_id", ckdb.UInt16).SetComment("the team ID"),
+		ckdb.NewColumn("agent_id", ckdb.UInt16).SetComment("Agent ID"),
+		ckdb.NewColumn("app_label_value_ids", ckdb.ArrayUInt32).SetComment("the Nth element is the value of the column app_label_value_id_N in the samples table"),
+		ckdb.NewColumn("count", ckdb.Float64).SetComment("the count of observations"),
+		ckdb.NewColumn("sum", ckdb.Float64).SetComment("the sum of observations"),
+		ckdb.NewColumn("schema", ckdb.Int32).SetComment("the upper bound of the bucket index i is 2^(i*2^-schema)"),
+		ckdb.NewColumn("zero_threshold", ckdb.Float64).SetComment("the width of the zero bucket"),
+		ckdb.NewColumn("zero_count", ckdb.Float64).SetComment("the count of observations in the zero bucket"),
+		ckdb.NewColumn("positive_span_offsets", ckdb.ArrayInt32).SetComment("the gap to the previous span, or the starting bucket index of the first span"),
+		ckdb.NewColumn("positive_span_lengths", ckdb.ArrayUInt32).SetComment("the count of consecutive buckets of the spans"),
+		ckdb.NewColumn("positive_counts", ckdb.ArrayFloat64).SetComment("the absolute count of every bucket in the spans"),
+		ckdb.NewColumn("negative_span_offsets", ckdb.ArrayInt32).SetComment("the gap to the previous span, or the starting bucket index of the first span"),
+		ckdb.NewColumn("negative_span_lengths", ckdb.ArrayUInt32).SetComment("the count of consecutive buckets of the spans"),
+		ckdb.NewColumn("negative_counts", ckdb.ArrayFloat64).SetComment("the absolute count of every bucket in the spans"),
+	}
+}
+
+func GenPrometheusHistogramCKTable(cluster, storagePolicy, ckdbType string, ttl int, coldStorage *ckdb.ColdStorage) *ckdb.Table {
+	timeKey := "time"
+	engine := ckdb.MergeTree
+	orderKeys := []string{"metric_id", timeKey}
+
+	return &ckdb.Table{
+		Version:         common.CK_VERSION,
+		Database:        PROMETHEUS_DB,
+		DBType:          ckdbType,
+		LocalName:       PROMETHEUS_HISTOGRAM_TABLE + ckdb.LOCAL_SUBFFIX,
+		GlobalName:      PROMETHEUS_HISTOGRAM_TABLE,
+		Columns:         PrometheusHistogramColumns(),
+		TimeKey:         timeKey,
+		TTL:             ttl,
+		PartitionFunc:   DefaultPartition,
+		Engine:          engine,
+		Cluster:         cluster,
+		StoragePolicy:   storagePolicy,
+		ColdStorage:     *coldStorage,
+		OrderKeys:       orderKeys,
+		PrimaryKeyCount: len(orderKeys),
+	}
+}
+
+var prometheusHistogramPool = pool.NewLockFreePool(func() *PrometheusHistogram {
+	return &PrometheusHistogram{}
+})
+
+func AcquirePrometheusHistogram() *PrometheusHistogram {
+	return prometheusHistogramPool.Get()
+}
+
+func ReleasePrometheusHistogram(p *PrometheusHistogram) {
+	appLabelValueIDs := p.AppLabelValueIDs[:0]
+	positiveSpanOffsets, positiveSpanLengths, positiveCounts := p.PositiveSpanOffsets[:0], p.PositiveSpanLengths[:0], p.PositiveCounts[:0]
+	negativeSpanOffsets, negativeSpanLengths, negativeCounts := p.NegativeSpanOffsets[:0], p.NegativeSpanLengths[:0], p.NegativeCounts[:0]
+	*p = PrometheusHistogram{}
+	p.AppLabelValueIDs = appLabelValueIDs
+	p.PositiveSpanOffsets, p.PositiveSpanLengths, p.PositiveCounts = positiveSpanOffsets, positiveSpanLengths, positiveCounts
+	p.NegativeSpanOffsets, p.NegativeSpanLengths, p.NegativeCounts = negativeSpanOffsets, negativeSpanLengths, negativeCounts
+	prometheusHistogramPool.Put(p)
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dbwriter
+
+import (
+	"github.com/ClickHouse/ch-go/proto"
+
+	"github.com/deepflowio/deepflow/server/libs/ckdb"
+)
+
+type PrometheusHistogramBlock struct {
+	ColTime                proto.ColDateTime
+	ColMetricId            proto.ColUInt32
+	ColTargetId            proto.ColUInt32
+	ColTeamId              proto.ColUInt16
+	ColAgentId             proto.ColUInt16
+	ColAppLabelValueIds    *proto.ColArr[uint32]
+	ColCount               proto.ColFloat64
+	ColSum                 proto.ColFloat64
+	ColSchema              proto.ColInt32
+	ColZeroThreshold       proto.ColFloat64
+	ColZeroCount           proto.ColFloat64
+	ColPositiveSpanOffsets *proto.ColArr[int32]
+	ColPositiveSpanLengths *proto.ColArr[uint32]
+	ColPositiveCounts      *proto.ColArr[float64]
+	ColNegativeSpanOffsets *proto.ColArr[int32]
+	ColNegativeSpanLengths *proto.ColArr[uint32]
+	ColNegativeCounts      *proto.ColArr[float64]
+}
+
+func (b *PrometheusHistogramBlock) Reset() {
+	b.ColTime.Reset()
+	b.ColMetricId.Reset()
+	b.ColTargetId.Reset()
+	b.ColTeamId.Reset()
+	b.ColAgentId.Reset()
+	b.ColAppLabelValueIds.Reset()
+	b.ColCount.Reset()
+	b.ColSum.Reset()
+	b.ColSchema.Reset()
+	b.ColZeroThreshold.Reset()
+	b.ColZeroCount.Reset()
+	b.ColPositiveSpanOffsets.Reset()
+	b.ColPositiveSpanLengths.Reset()
+	b.ColPositiveCounts.Reset()
+	b.ColNegativeSpanOffsets.Reset()
+	b.ColNegativeSpanLengths.Reset()
+	b.ColNegativeCounts.Reset()
+}
+
+func (b *PrometheusHistogramBlock) ToInput(input proto.Input) proto.Input {
+	return append(input,
+		proto.InputColumn{Name: ckdb.COLUMN_TIME, Data: &b.ColTime},
+		proto.InputColumn{Name: ckdb.COLUMN_METRIC_ID, Data: &b.ColMetricId},
+		proto.InputColumn{Name: ckdb.COLUMN_TARGET_ID, Data: &b.ColTargetId},
+		proto.InputColumn{Name: ckdb.COLUMN_TEAM_ID, Data: &b.ColTeamId},
+		proto.InputColumn{Name: ckdb.COLUMN_AGENT_ID, Data: &b.ColAgentId},
+		proto.InputColumn{Name: ckdb.COLUMN_APP_LABEL_VALUE_IDS, Data: b.ColAppLabelValueIds},
+		proto.InputColumn{Name: ckdb.COLUMN_COUNT, Data: &b.ColCount},
+		proto.InputColumn{Name: ckdb.COLUMN_SUM, Data: &b.ColSum},
+		proto.InputColumn{Name: ckdb.COLUMN_SCHEMA, Data: &b.ColSchema},
+		proto.InputColumn{Name: ckdb.COLUMN_ZERO_THRESHOLD, Data: &b.ColZeroThreshold},
+		proto.InputColumn{Name: ckdb.COLUMN_ZERO_COUNT, Data: &b.ColZeroCount},
+		proto.InputColumn{Name: ckdb.COLUMN_POSITIVE_SPAN_OFFSETS, Data: b.ColPositiveSpanOffsets},
+		proto.InputColumn{Name: ckdb.COLUMN_POSITIVE_SPAN_LENGTHS, Data: b.ColPositiveSpanLengths},
+		proto.InputColumn{Name: ckdb.COLUMN_POSITIVE_COUNTS, Data: b.ColPositiveCounts},
+		proto.InputColumn{Name: ckdb.COLUMN_NEGATIVE_SPAN_OFFSETS, Data: b.ColNegativeSpanOffsets},
+		proto.InputColumn{Name: ckdb.COLUMN_NEGATIVE_SPAN_LENGTHS, Data: b.ColNegativeSpanLengths},
+		proto.InputColumn{Name: ckdb.COLUMN_NEGATIVE_COUNTS, Data: b.ColNegativeCounts},
+	)
+}
+
+func (n *PrometheusHistogram) NewColumnBlock() ckdb.CKColumnBlock {
+	return &PrometheusHistogramBlock{
+		ColAppLabelValueIds:    new(proto.ColUInt32).Array(),
+		ColPositiveSpanOffsets: new(proto.ColInt32).Array(),
+		ColPositiveSpanLengths: new(proto.ColUInt32).Array(),
+		ColPositiveCounts:      new(proto.ColFloat64).Array(),
+		ColNegativeSpanOffsets: new(proto.ColInt32).Array(),
+		ColNegativeSpanLengths: new(proto.ColUInt32).Array(),
+		ColNegativeCounts:      new(proto.ColFloat64).Array(),
+	}
+}
+
+func (n *PrometheusHistogram) AppendToColumnBlock(b ckdb.CKColumnBlock) {
+	block := b.(*PrometheusHistogramBlock)
+	ckdb.AppendColDateTime(&block.ColTime, n.Time)
+	block.ColMetricId.Append(n.MetricID)
+	block.ColTargetId.Append(n.TargetID)
+	block.ColTeamId.Append(n.TeamID)
+	block.ColAgentId.Append(n.VtapId)
+	if len(n.AppLabelValueIDs) > 0 {
+		block.ColAppLabelValueIds.Append(n.AppLabelValueIDs[1:])
+	} else {
+		block.ColAppLabelValueIds.Append(n.AppLabelValueIDs)
+	}
+	block.ColCount.Append(n.Count)
+	block.ColSum.Append(n.Sum)
+	block.ColSchema.Append(n.Schema)
+	block.ColZeroThreshold.Append(n.ZeroThreshold)
+	block.ColZeroCount.Append(n.ZeroCount)
+	block.ColPositiveSpanOffsets.Append(n.PositiveSpanOffsets)
+	block.ColPositiveSpanLengths.Append(n.PositiveSpanLengths)
+	block.ColPositiveCounts.Append(n.PositiveCounts)
+	block.ColNegativeSpanOffsets.Append(n.NegativeSpanOffsets)
+	block.ColNegativeSpanLengths.Append(n.NegativeSpanLengths)
+	block.ColNegativeCounts.Append(n.NegativeCounts)
+}
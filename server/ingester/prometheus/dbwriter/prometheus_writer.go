@@ -53,8 +53,10 @@ type ClusterNode struct {
 }
 
 type Counter struct {
-	MetricsCount int64 `statsd:"metrics-count"`
-	WriteErr     int64 `statsd:"write-err"`
+	MetricsCount    int64 `statsd:"metrics-count"`
+	ExemplarsCount  int64 `statsd:"exemplars-count"`
+	HistogramsCount int64 `statsd:"histograms-count"`
+	WriteErr        int64 `statsd:"write-err"`
 }
 
 type PrometheusCKWriter struct {
@@ -64,7 +66,9 @@ type PrometheusCKWriter struct {
 
 // all 'PrometheusWriters' share 'prometheusCKWriters' to write to ClickHouse, preventing each PrometheusWriter from creating CKWriter and causing excessive resource consumption
 type PrometheusCKWriters struct {
-	writers [ckdb.MAX_APP_LABEL_COLUMN_INDEX + 1]PrometheusCKWriter
+	writers         [ckdb.MAX_APP_LABEL_COLUMN_INDEX + 1]PrometheusCKWriter
+	exemplarWriter  *ckwriter.CKWriter // the writer for prometheus.exemplars table
+	histogramWriter *ckwriter.CKWriter // the writer for prometheus.histograms table
 	sync.Mutex
 }
 
@@ -231,6 +235,56 @@ func (w *PrometheusWriter) getOrCreateCkwriter(s PrometheusSampleInterface) (*ck
 	return ckwriter, nil
 }
 
+func (w *PrometheusWriter) getOrCreateExemplarCkwriter() (*ckwriter.CKWriter, error) {
+	if writer := prometheusCKWriters.exemplarWriter; writer != nil {
+		return writer, nil
+	}
+	lockPrometheusCKWriters()
+	defer unlockPrometheusCKWriters()
+	// check again
+	if writer := prometheusCKWriters.exemplarWriter; writer != nil {
+		return writer, nil
+	}
+
+	table := GenPrometheusExemplarCKTable(w.ckdbCluster, w.ckdbStoragePolicy, w.ckdbType, w.ttl, ckdb.GetColdStorage(w.ckdbColdStorages, PROMETHEUS_DB, PROMETHEUS_EXEMPLAR_TABLE))
+	ckwriter, err := ckwriter.NewCKWriter(
+		w.currentCkdbAddrs, w.ckdbUsername, w.ckdbPassword,
+		fmt.Sprintf("%s-%s-%d", w.name, PROMETHEUS_EXEMPLAR_TABLE, w.decoderIndex), w.ckdbTimeZone,
+		table, w.writerConfig.QueueCount, w.writerConfig.QueueSize, w.writerConfig.BatchSize, w.writerConfig.FlushTimeout, w.ckdbWatcher)
+	if err != nil {
+		return nil, err
+	}
+	ckwriter.Run()
+	prometheusCKWriters.exemplarWriter = ckwriter
+	log.Info("finish create new ckwriter for prometheus exemplars")
+	return ckwriter, nil
+}
+
+func (w *PrometheusWriter) getOrCreateHistogramCkwriter() (*ckwriter.CKWriter, error) {
+	if writer := prometheusCKWriters.histogramWriter; writer != nil {
+		return writer, nil
+	}
+	lockPrometheusCKWriters()
+	defer unlockPrometheusCKWriters()
+	// check again
+	if writer := prometheusCKWriters.histogramWriter; writer != nil {
+		return writer, nil
+	}
+
+	table := GenPrometheusHistogramCKTable(w.ckdbCluster, w.ckdbStoragePolicy, w.ckdbType, w.ttl, ckdb.GetColdStorage(w.ckdbColdStorages, PROMETHEUS_DB, PROMETHEUS_HISTOGRAM_TABLE))
+	ckwriter, err := ckwriter.NewCKWriter(
+		w.currentCkdbAddrs, w.ckdbUsername, w.ckdbPassword,
+		fmt.Sprintf("%s-%s-%d", w.name, PROMETHEUS_HISTOGRAM_TABLE, w.decoderIndex), w.ckdbTimeZone,
+		table, w.writerConfig.QueueCount, w.writerConfig.QueueSize, w.writerConfig.BatchSize, w.writerConfig.FlushTimeout, w.ckdbWatcher)
+	if err != nil {
+		return nil, err
+	}
+	ckwriter.Run()
+	prometheusCKWriters.histogramWriter = ckwriter
+	log.Info("finish create new ckwriter for prometheus histograms")
+	return ckwriter, nil
+}
+
 func (w *PrometheusWriter) addAppLabelColumnsOnCluster(startIndex, endIndex int, orgDatabase string) error {
 	// in standalone mode, ckdbWatcher will be nil
 	if w.ckdbWatcher == nil {
@@ -357,6 +411,53 @@ func (w *PrometheusWriter) WriteBatch(batch []interface{}, metricName string, ti
 	ckwriter.Put(batch...)
 }
 
+func (w *PrometheusWriter) WriteExemplars(batch []interface{}) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ckwriter, err := w.getOrCreateExemplarCkwriter()
+	if err != nil {
+		if w.counter.WriteErr == 0 {
+			log.Warningf("get exemplar writer failed: %s", err)
+		}
+		atomic.AddInt64(&w.counter.WriteErr, 1)
+		for _, item := range batch {
+			item.(*PrometheusExemplar).Release()
+		}
+		return
+	}
+	atomic.AddInt64(&w.counter.ExemplarsCount, int64(len(batch)))
+	ckwriter.Put(batch...)
+}
+
+// WriteHistograms writes the native histograms of a time series, the flow tags are generated with the metric name
+// of the histograms, since the time series may have no float samples.
+func (w *PrometheusWriter) WriteHistograms(batch []interface{}, metricName string, timeSeries *prompb.TimeSeries, extraLabels []prompb.Label, tsLabelNameIDs, tsLabelValueIDs []uint32) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ckwriter, err := w.getOrCreateHistogramCkwriter()
+	if err != nil {
+		if w.counter.WriteErr == 0 {
+			log.Warningf("get histogram writer failed: %s", err)
+		}
+		atomic.AddInt64(&w.counter.WriteErr, 1)
+		for _, item := range batch {
+			item.(*PrometheusHistogram).Release()
+		}
+		return
+	}
+	h := batch[0].(*PrometheusHistogram)
+	flowTagSample := &PrometheusSampleMini{VtapId: h.VtapId, MetricID: h.MetricID, OrgId: h.OrgId, TeamID: h.TeamID}
+	flowTagSample.GenerateNewFlowTags(w.flowTagWriter.Cache, metricName, timeSeries, extraLabels, tsLabelNameIDs, tsLabelValueIDs)
+	w.flowTagWriter.WriteFieldsAndFieldValuesInCache()
+
+	atomic.AddInt64(&w.counter.HistogramsCount, int64(len(batch)))
+	ckwriter.Put(batch...)
+}
+
 func NewPrometheusWriter(
 	decoderIndex int,
 	initAppLabelCount int,
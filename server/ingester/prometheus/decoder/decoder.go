@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"math"
 	"net"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -60,6 +61,7 @@ type Counter struct {
 	TimeSeriesErr  int64 `statsd:"time-series-err"`
 	TimeSeriesSlow int64 `statsd:"time-series-slow"`
 	TimeSeriesOut  int64 `statsd:"time-series-out"` // count the number of TimeSeries (not Samples)
+	HistogramIn    int64 `statsd:"histogram-in"`    // count the number of native histograms
 }
 
 type BuilderCounter struct {
@@ -75,6 +77,8 @@ type BuilderCounter struct {
 	TargetMiss        int64 `statsd:"target-miss"`
 	MetricTargetMiss  int64 `statsd:"metric-target-miss"`
 	Sample            int64 `statsd:"sample-out"`
+	Exemplar          int64 `statsd:"exemplar-out"`
+	Histogram         int64 `statsd:"histogram-out"`
 }
 
 type UniversalTagKey struct {
@@ -94,6 +98,8 @@ type PrometheusSamplesBuilder struct {
 	// temporary buffers
 	metricName              string
 	samplesBuffer           []interface{} // store all Samples in a TimeSeries.
+	exemplarsBuffer         []interface{} // store all Exemplars in a TimeSeries.
+	histogramsBuffer        []interface{} // store all native Histograms in a TimeSeries.
 	timeSeriesBuffer        *prompb.TimeSeries
 	tsLabelNameIDsBuffer    []uint32 // store timeSeries labelNameIDs without metricName
 	tsLabelValueIDsBuffer   []uint32 // store timeSeries labelValueIDs without metricID
@@ -268,7 +274,9 @@ func (d *Decoder) handlePrometheusData(vtapID uint16, decoder *codec.SimpleDecod
 
 		for i := range req.Timeseries {
 			d.counter.TimeSeriesIn++
-			d.sendPrometheus(vtapID, &req.Timeseries[i], *extraLabels)
+			ts := &req.Timeseries[i]
+			d.counter.HistogramIn += int64(len(ts.Histograms))
+			d.sendPrometheus(vtapID, ts, *extraLabels)
 		}
 		req.ResetWithBufferReserved() // release memory as soon as possible
 	}
@@ -304,6 +312,8 @@ func (d *Decoder) sendPrometheus(vtapID uint16, ts *prompb.TimeSeries, extraLabe
 		return
 	}
 	d.prometheusWriter.WriteBatch(builder.samplesBuffer, builder.metricName, builder.timeSeriesBuffer, extraLabels, builder.tsLabelNameIDsBuffer, builder.tsLabelValueIDsBuffer)
+	d.prometheusWriter.WriteExemplars(builder.exemplarsBuffer)
+	d.prometheusWriter.WriteHistograms(builder.histogramsBuffer, builder.metricName, builder.timeSeriesBuffer, extraLabels, builder.tsLabelNameIDsBuffer, builder.tsLabelValueIDsBuffer)
 	d.counter.OutCount += int64(len(builder.samplesBuffer))
 	d.counter.TimeSeriesOut++
 }
@@ -324,13 +334,15 @@ func (b *PrometheusSamplesBuilder) GetEpcPodClusterId(orgId, vtapID uint16) (uin
 // if failed, return false,err
 // if isSlow, return true,slowReason
 func (b *PrometheusSamplesBuilder) TimeSeriesToStore(vtapID, epcId, podClusterId, orgId, teamID uint16, ts *prompb.TimeSeries, extraLabels []prompb.Label) (bool, error) {
-	if len(ts.Samples) == 0 {
+	if len(ts.Samples) == 0 && len(ts.Exemplars) == 0 && len(ts.Histograms) == 0 {
 		b.counter.TimeSeriesInvaild++
-		return false, fmt.Errorf("prometheum samples of time serries(%s) is empty.", ts)
+		return false, fmt.Errorf("prometheum samples, exemplars and histograms of time serries(%s) are empty.", ts)
 	}
 	b.counter.TimeSeriesIn++
 
 	b.samplesBuffer = b.samplesBuffer[:0]
+	b.exemplarsBuffer = b.exemplarsBuffer[:0]
+	b.histogramsBuffer = b.histogramsBuffer[:0]
 	b.timeSeriesBuffer = ts
 	b.tsLabelNameIDsBuffer = b.tsLabelNameIDsBuffer[:0]
 	b.tsLabelValueIDsBuffer = b.tsLabelValueIDsBuffer[:0]
@@ -466,9 +478,64 @@ func (b *PrometheusSamplesBuilder) TimeSeriesToStore(vtapID, epcId, podClusterId
 
 		b.counter.Sample++
 	}
+
+	for i := range ts.Exemplars {
+		e := &ts.Exemplars[i]
+		if math.IsNaN(e.Value) || math.IsInf(e.Value, 0) {
+			continue
+		}
+		m := dbwriter.AcquirePrometheusExemplar()
+		m.Time = uint32(model.Time(e.Timestamp).Unix())
+		m.Timestamp = e.Timestamp * 1000
+		m.MetricID = metricID
+		m.AppLabelValueIDs = append(m.AppLabelValueIDs, b.appLabelValueIDsBuffer...)
+		m.Value = e.Value
+		m.VtapId = vtapID
+		m.OrgId, m.TeamID = orgId, teamID
+		fillExemplarLabels(m, e.Labels)
+		b.exemplarsBuffer = append(b.exemplarsBuffer, m)
+		b.counter.Exemplar++
+	}
+
+	for i := range ts.Histograms {
+		h := &ts.Histograms[i]
+		// skip the stale markers whose sum is NaN, and the non-finite sums like the float samples
+		if math.IsNaN(h.Sum) || math.IsInf(h.Sum, 0) {
+			continue
+		}
+		m := dbwriter.AcquirePrometheusHistogram()
+		m.Time = uint32(model.Time(h.Timestamp).Unix())
+		m.MetricID = metricID
+		m.AppLabelValueIDs = append(m.AppLabelValueIDs, b.appLabelValueIDsBuffer...)
+		m.VtapId = vtapID
+		m.OrgId, m.TeamID = orgId, teamID
+		fillPrometheusHistogram(m, h)
+		b.histogramsBuffer = append(b.histogramsBuffer, m)
+		b.counter.Histogram++
+	}
 	return false, nil
 }
 
+// the label names of trace id and span id used by the OpenTelemetry and Prometheus client libraries
+var (
+	exemplarTraceIDLabels = []string{"trace_id", "traceID", "traceId", "TraceID"}
+	exemplarSpanIDLabels  = []string{"span_id", "spanID", "spanId", "SpanID"}
+)
+
+func fillExemplarLabels(m *dbwriter.PrometheusExemplar, labels []prompb.Label) {
+	for _, l := range labels {
+		// the labels are from temporary memory, so needs to be cloned
+		if m.TraceID == "" && slices.Contains(exemplarTraceIDLabels, l.Name) {
+			m.TraceID = strings.Clone(l.Value)
+		} else if m.SpanID == "" && slices.Contains(exemplarSpanIDLabels, l.Name) {
+			m.SpanID = strings.Clone(l.Value)
+		} else {
+			m.AttributeNames = append(m.AttributeNames, strings.Clone(l.Name))
+			m.AttributeValues = append(m.AttributeValues, strings.Clone(l.Value))
+		}
+	}
+}
+
 func (b *PrometheusSamplesBuilder) fillUniversalTag(m *dbwriter.PrometheusSample, vtapID uint16, podName, instance string, podNameID, instanceID uint32, fillWithVtapId bool) {
 	platformDataVersion := b.platformData.Version(m.OrgId)
 	if platformDataVersion != b.platformDataVersion[m.OrgId] {
@@ -0,0 +1,87 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package decoder
+
+import (
+	"github.com/deepflowio/deepflow/server/ingester/prometheus/dbwriter"
+	"github.com/deepflowio/deepflow/server/libs/datatype/prompb"
+)
+
+func histogramCount(h *prompb.Histogram) float64 {
+	if _, ok := h.GetCount().(*prompb.Histogram_CountFloat); ok {
+		return h.GetCountFloat()
+	}
+	return float64(h.GetCountInt())
+}
+
+func histogramZeroCount(h *prompb.Histogram) float64 {
+	if _, ok := h.GetZeroCount().(*prompb.Histogram_ZeroCountFloat); ok {
+		return h.GetZeroCountFloat()
+	}
+	return float64(h.GetZeroCountInt())
+}
+
+// appendSpanBuckets appends the offsets and lengths of the spans and the absolute count of every bucket in the
+// spans, the counts are the absolute values of the float histogram, or the deltas of the integer histogram. The
+// spans are truncated if there are not enough counts, so that the lengths always match the counts.
+func appendSpanBuckets(offsets []int32, lengths []uint32, counts []float64,
+	spans []prompb.BucketSpan, floatCounts []float64, deltas []int64, isFloat bool) ([]int32, []uint32, []float64) {
+	var current int64
+	n := 0
+	for _, span := range spans {
+		length := uint32(0)
+		for ; length < span.Length; length++ {
+			if isFloat {
+				if n >= len(floatCounts) {
+					break
+				}
+				counts = append(counts, floatCounts[n])
+			} else {
+				if n >= len(deltas) {
+					break
+				}
+				current += deltas[n]
+				counts = append(counts, float64(current))
+			}
+			n++
+		}
+		if length == 0 && span.Length > 0 {
+			break
+		}
+		offsets = append(offsets, span.Offset)
+		lengths = append(lengths, length)
+		if length < span.Length {
+			break
+		}
+	}
+	return offsets, lengths, counts
+}
+
+// fillPrometheusHistogram stores the native histogram as it is, except that the deltas of the integer histogram
+// are decoded to the absolute bucket counts, so that the integer and float histograms have the same layout.
+func fillPrometheusHistogram(m *dbwriter.PrometheusHistogram, h *prompb.Histogram) {
+	_, isFloat := h.GetCount().(*prompb.Histogram_CountFloat)
+	m.Count = histogramCount(h)
+	m.Sum = h.Sum
+	m.Schema = h.Schema
+	m.ZeroThreshold = h.ZeroThreshold
+	m.ZeroCount = histogramZeroCount(h)
+	m.PositiveSpanOffsets, m.PositiveSpanLengths, m.PositiveCounts = appendSpanBuckets(
+		m.PositiveSpanOffsets, m.PositiveSpanLengths, m.PositiveCounts, h.PositiveSpans, h.PositiveCounts, h.PositiveDeltas, isFloat)
+	m.NegativeSpanOffsets, m.NegativeSpanLengths, m.NegativeCounts = appendSpanBuckets(
+		m.NegativeSpanOffsets, m.NegativeSpanLengths, m.NegativeCounts, h.NegativeSpans, h.NegativeCounts, h.NegativeDeltas, isFloat)
+}
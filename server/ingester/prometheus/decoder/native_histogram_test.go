@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package decoder
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/deepflowio/deepflow/server/ingester/prometheus/dbwriter"
+	"github.com/deepflowio/deepflow/server/libs/datatype/prompb"
+)
+
+func TestFillPrometheusHistogram(t *testing.T) {
+	h := &prompb.Histogram{
+		Count:          &prompb.Histogram_CountInt{CountInt: 12},
+		Sum:            30,
+		Schema:         0,
+		ZeroThreshold:  0.001,
+		ZeroCount:      &prompb.Histogram_ZeroCountInt{ZeroCountInt: 1},
+		NegativeSpans:  []prompb.BucketSpan{{Offset: 1, Length: 1}},
+		NegativeDeltas: []int64{1},
+		PositiveSpans:  []prompb.BucketSpan{{Offset: 0, Length: 3}, {Offset: 1, Length: 1}},
+		PositiveDeltas: []int64{2, 1, -2, 3},
+	}
+	m := &dbwriter.PrometheusHistogram{}
+	fillPrometheusHistogram(m, h)
+	// the deltas of the integer histogram are decoded to the absolute counts
+	expected := &dbwriter.PrometheusHistogram{
+		Count:               12,
+		Sum:                 30,
+		ZeroThreshold:       0.001,
+		ZeroCount:           1,
+		PositiveSpanOffsets: []int32{0, 1},
+		PositiveSpanLengths: []uint32{3, 1},
+		PositiveCounts:      []float64{2, 3, 1, 4},
+		NegativeSpanOffsets: []int32{1},
+		NegativeSpanLengths: []uint32{1},
+		NegativeCounts:      []float64{1},
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("integer histogram is stored as %+v, expected %+v", m, expected)
+	}
+
+	h = &prompb.Histogram{
+		Count:          &prompb.Histogram_CountFloat{CountFloat: 3.5},
+		Sum:            5,
+		Schema:         1,
+		ZeroCount:      &prompb.Histogram_ZeroCountFloat{},
+		PositiveSpans:  []prompb.BucketSpan{{Offset: 2, Length: 2}},
+		PositiveCounts: []float64{1.5, 2},
+	}
+	m = &dbwriter.PrometheusHistogram{}
+	fillPrometheusHistogram(m, h)
+	expected = &dbwriter.PrometheusHistogram{
+		Count:               3.5,
+		Sum:                 5,
+		Schema:              1,
+		PositiveSpanOffsets: []int32{2},
+		PositiveSpanLengths: []uint32{2},
+		PositiveCounts:      []float64{1.5, 2},
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("float histogram is stored as %+v, expected %+v", m, expected)
+	}
+}
+
+func TestAppendSpanBucketsTruncated(t *testing.T) {
+	// the second span has only one of its buckets, the third span has none
+	spans := []prompb.BucketSpan{{Offset: -1, Length: 2}, {Offset: 3, Length: 2}, {Offset: 1, Length: 1}}
+	offsets, lengths, counts := appendSpanBuckets(nil, nil, nil, spans, nil, []int64{1, 1, 1}, false)
+	if !reflect.DeepEqual(offsets, []int32{-1, 3}) || !reflect.DeepEqual(lengths, []uint32{2, 1}) ||
+		!reflect.DeepEqual(counts, []float64{1, 2, 3}) {
+		t.Errorf("truncated spans are %v %v %v", offsets, lengths, counts)
+	}
+}
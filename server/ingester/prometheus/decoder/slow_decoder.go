@@ -77,6 +77,26 @@ func AcquireSlowItem(vtapId, epcId, podClusterId, orgId, teamId uint16, ts *prom
 	}
 
 	s.ts.Samples = append(s.ts.Samples, ts.Samples...)
+	for _, e := range ts.Exemplars {
+		exemplar := prompb.Exemplar{Value: e.Value, Timestamp: e.Timestamp}
+		for _, l := range e.Labels {
+			exemplar.Labels = append(exemplar.Labels, prompb.Label{
+				Name:  strings.Clone(l.Name),
+				Value: strings.Clone(l.Value),
+			})
+		}
+		s.ts.Exemplars = append(s.ts.Exemplars, exemplar)
+	}
+	for _, h := range ts.Histograms {
+		// the spans and buckets refer to the decode buffer, so needs to be cloned
+		h.NegativeSpans = append([]prompb.BucketSpan(nil), h.NegativeSpans...)
+		h.NegativeDeltas = append([]int64(nil), h.NegativeDeltas...)
+		h.NegativeCounts = append([]float64(nil), h.NegativeCounts...)
+		h.PositiveSpans = append([]prompb.BucketSpan(nil), h.PositiveSpans...)
+		h.PositiveDeltas = append([]int64(nil), h.PositiveDeltas...)
+		h.PositiveCounts = append([]float64(nil), h.PositiveCounts...)
+		s.ts.Histograms = append(s.ts.Histograms, h)
+	}
 	return s
 }
 
@@ -87,6 +107,12 @@ func ReleaseSlowItem(s *SlowItem) {
 	if s.ts.Samples != nil {
 		s.ts.Samples = s.ts.Samples[:0]
 	}
+	if s.ts.Exemplars != nil {
+		s.ts.Exemplars = s.ts.Exemplars[:0]
+	}
+	if s.ts.Histograms != nil {
+		s.ts.Histograms = s.ts.Histograms[:0]
+	}
 	slowItemPool.Put(s)
 }
 
@@ -293,6 +319,12 @@ func (d *SlowDecoder) sendPrometheusSamples(vtapID, epcId, podClusterId, orgId,
 		d.samplesBuilder.timeSeriesBuffer,
 		nil,
 		d.samplesBuilder.tsLabelNameIDsBuffer, d.samplesBuilder.tsLabelValueIDsBuffer)
+	d.prometheusWriter.WriteExemplars(d.samplesBuilder.exemplarsBuffer)
+	d.prometheusWriter.WriteHistograms(d.samplesBuilder.histogramsBuffer,
+		d.samplesBuilder.metricName,
+		d.samplesBuilder.timeSeriesBuffer,
+		nil,
+		d.samplesBuilder.tsLabelNameIDsBuffer, d.samplesBuilder.tsLabelValueIDsBuffer)
 	d.counter.SampleOut += int64(len(d.samplesBuilder.samplesBuffer))
 	d.counter.TimeSeriesOut++
 }
@@ -94,6 +94,7 @@ const (
 	ArrayUInt8
 	ArrayUInt16
 	ArrayUInt32
+	ArrayInt32
 	ArrayInt64
 	ArrayFloat64
 	DateTime
@@ -132,6 +133,7 @@ var cloumnTypeString = []string{
 	ArrayUInt8:                "Array(UInt8)",
 	ArrayUInt16:               "Array(UInt16)",
 	ArrayUInt32:               "Array(UInt32)",
+	ArrayInt32:                "Array(Int32)",
 	ArrayInt64:                "Array(Int64)",
 	ArrayFloat64:              "Array(Float64)",
 	DateTime:                  "DateTime('" + DF_TIMEZONE + "')",
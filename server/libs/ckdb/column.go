@@ -36,6 +36,7 @@ const (
 	COLUMN_ALERT_POLICY               = "alert_policy"
 	COLUMN_APP_INSTANCE               = "app_instance"
 	COLUMN_APP_LABEL_VALUE_ID         = "app_label_value_id"
+	COLUMN_APP_LABEL_VALUE_IDS        = "app_label_value_ids"
 	COLUMN_APP_SERVICE                = "app_service"
 	COLUMN_ART_COUNT                  = "art_count"
 	COLUMN_ART_MAX                    = "art_max"
@@ -164,6 +165,9 @@ const (
 	COLUMN_NAT_REAL_PORT_0            = "nat_real_port_0"
 	COLUMN_NAT_REAL_PORT_1            = "nat_real_port_1"
 	COLUMN_NAT_SOURCE                 = "nat_source"
+	COLUMN_NEGATIVE_COUNTS            = "negative_counts"
+	COLUMN_NEGATIVE_SPAN_LENGTHS      = "negative_span_lengths"
+	COLUMN_NEGATIVE_SPAN_OFFSETS      = "negative_span_offsets"
 	COLUMN_NEW_FLOW                   = "new_flow"
 	COLUMN_OBSERVATION_POINT          = "observation_point"
 	COLUMN_PACKET                     = "packet"
@@ -189,6 +193,9 @@ const (
 	COLUMN_POD_NS_ID_1                = "pod_ns_id_1"
 	COLUMN_POLICY_ID                  = "policy_id"
 	COLUMN_POLICY_TYPE                = "policy_type"
+	COLUMN_POSITIVE_COUNTS            = "positive_counts"
+	COLUMN_POSITIVE_SPAN_LENGTHS      = "positive_span_lengths"
+	COLUMN_POSITIVE_SPAN_OFFSETS      = "positive_span_offsets"
 	COLUMN_PROCESS_ID                 = "process_id"
 	COLUMN_PROCESS_ID_0               = "process_id_0"
 	COLUMN_PROCESS_ID_1               = "process_id_1"
@@ -246,6 +253,7 @@ const (
 	COLUMN_RTT_SERVER_MAX             = "rtt_server_max"
 	COLUMN_RTT_SERVER_SUM             = "rtt_server_sum"
 	COLUMN_RTT_SUM                    = "rtt_sum"
+	COLUMN_SCHEMA                     = "schema"
 	COLUMN_SEARCH_INDEX               = "search_index"
 	COLUMN_SERVER_ERROR               = "server_error"
 	COLUMN_SERVER_ESTABLISH_FAIL      = "server_establish_fail"
@@ -273,6 +281,7 @@ const (
 	COLUMN_SUBNET_ID                  = "subnet_id"
 	COLUMN_SUBNET_ID_0                = "subnet_id_0"
 	COLUMN_SUBNET_ID_1                = "subnet_id_1"
+	COLUMN_SUM                        = "sum"
 	COLUMN_SYNACK_COUNT               = "synack_count"
 	COLUMN_SYN_ACK_SEQ                = "syn_ack_seq"
 	COLUMN_SYN_COUNT                  = "syn_count"
@@ -343,6 +352,8 @@ const (
 	COLUMN_VPC_ID                     = "vpc_id"
 	COLUMN_X_REQUEST_ID_0             = "x_request_id_0"
 	COLUMN_X_REQUEST_ID_1             = "x_request_id_1"
+	COLUMN_ZERO_COUNT                 = "zero_count"
+	COLUMN_ZERO_THRESHOLD             = "zero_threshold"
 	COLUMN_ZERO_WIN                   = "zero_win"
 	COLUMN_ZERO_WIN_RX                = "zero_win_rx"
 	COLUMN_ZERO_WIN_TX                = "zero_win_tx"
@@ -361,6 +372,7 @@ var ColumnNames = []string{
 	COLUMN_ALERT_POLICY,
 	COLUMN_APP_INSTANCE,
 	COLUMN_APP_LABEL_VALUE_ID,
+	COLUMN_APP_LABEL_VALUE_IDS,
 	COLUMN_APP_SERVICE,
 	COLUMN_ART_COUNT,
 	COLUMN_ART_MAX,
@@ -489,6 +501,9 @@ var ColumnNames = []string{
 	COLUMN_NAT_REAL_PORT_0,
 	COLUMN_NAT_REAL_PORT_1,
 	COLUMN_NAT_SOURCE,
+	COLUMN_NEGATIVE_COUNTS,
+	COLUMN_NEGATIVE_SPAN_LENGTHS,
+	COLUMN_NEGATIVE_SPAN_OFFSETS,
 	COLUMN_NEW_FLOW,
 	COLUMN_OBSERVATION_POINT,
 	COLUMN_PACKET,
@@ -514,6 +529,9 @@ var ColumnNames = []string{
 	COLUMN_POD_NS_ID_1,
 	COLUMN_POLICY_ID,
 	COLUMN_POLICY_TYPE,
+	COLUMN_POSITIVE_COUNTS,
+	COLUMN_POSITIVE_SPAN_LENGTHS,
+	COLUMN_POSITIVE_SPAN_OFFSETS,
 	COLUMN_PROCESS_ID,
 	COLUMN_PROCESS_ID_0,
 	COLUMN_PROCESS_ID_1,
@@ -571,6 +589,7 @@ var ColumnNames = []string{
 	COLUMN_RTT_SERVER_MAX,
 	COLUMN_RTT_SERVER_SUM,
 	COLUMN_RTT_SUM,
+	COLUMN_SCHEMA,
 	COLUMN_SEARCH_INDEX,
 	COLUMN_SERVER_ERROR,
 	COLUMN_SERVER_ESTABLISH_FAIL,
@@ -598,6 +617,7 @@ var ColumnNames = []string{
 	COLUMN_SUBNET_ID,
 	COLUMN_SUBNET_ID_0,
 	COLUMN_SUBNET_ID_1,
+	COLUMN_SUM,
 	COLUMN_SYNACK_COUNT,
 	COLUMN_SYN_ACK_SEQ,
 	COLUMN_SYN_COUNT,
@@ -668,6 +688,8 @@ var ColumnNames = []string{
 	COLUMN_VPC_ID,
 	COLUMN_X_REQUEST_ID_0,
 	COLUMN_X_REQUEST_ID_1,
+	COLUMN_ZERO_COUNT,
+	COLUMN_ZERO_THRESHOLD,
 	COLUMN_ZERO_WIN,
 	COLUMN_ZERO_WIN_RX,
 	COLUMN_ZERO_WIN_TX,
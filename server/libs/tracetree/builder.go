@@ -0,0 +1,329 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracetree
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/deepflowio/deepflow/server/libs/datatype"
+)
+
+// the max depth when checking whether the parent links of the spans make a loop
+const MAX_SPAN_DEPTH = 64
+
+func isClientSide(s *SpanTrace) bool {
+	return strings.HasPrefix(s.ObservationPoint, "c")
+}
+
+func isServerSide(s *SpanTrace) bool {
+	return strings.HasPrefix(s.ObservationPoint, "s")
+}
+
+func isAppSpan(s *SpanTrace) bool {
+	return !isClientSide(s) && !isServerSide(s)
+}
+
+// LinkSpanTraces returns the index of the parent span of each span, -1 if it is a root span. The parent is searched by:
+//  1. parent_span_id equals span_id of the other span
+//  2. client side span and server side span in the same thread share syscall_trace_id
+//  3. client side span sends the x_request_id which the server side span of the same gateway responds
+//  4. server side span and client side span of the same request share req_tcp_seq
+func LinkSpanTraces(spans []*SpanTrace) []int {
+	parents := make([]int, len(spans))
+	spanIds := make(map[string]int, len(spans))
+	for i, s := range spans {
+		parents[i] = -1
+		if s.SpanId == "" {
+			continue
+		}
+		// prefer the app span when the span_id is shared by the network spans
+		if j, ok := spanIds[s.SpanId]; !ok || !isAppSpan(spans[j]) {
+			spanIds[s.SpanId] = i
+		}
+	}
+
+	for i, s := range spans {
+		if s.ParentSpanId != "" {
+			if j, ok := spanIds[s.ParentSpanId]; ok && j != i {
+				parents[i] = j
+				continue
+			}
+		}
+		if isClientSide(s) {
+			if s.SyscallTraceIDRequest != 0 {
+				for j, p := range spans {
+					if j != i && isServerSide(p) && (p.SyscallTraceIDRequest == s.SyscallTraceIDRequest || p.SyscallTraceIDResponse == s.SyscallTraceIDRequest) {
+						parents[i] = j
+						break
+					}
+				}
+			}
+			if parents[i] < 0 && s.XRequestId0 != "" {
+				for j, p := range spans {
+					if j != i && isServerSide(p) && p.XRequestId1 == s.XRequestId0 {
+						parents[i] = j
+						break
+					}
+				}
+			}
+		} else if isServerSide(s) && s.ReqTcpSeq != 0 {
+			for j, p := range spans {
+				if j != i && isClientSide(p) && p.ReqTcpSeq == s.ReqTcpSeq {
+					parents[i] = j
+					break
+				}
+			}
+		}
+	}
+
+	// break the loops
+	for i := range parents {
+		depth, p := 0, parents[i]
+		for p >= 0 && depth < MAX_SPAN_DEPTH {
+			p = parents[p]
+			depth++
+		}
+		if depth >= MAX_SPAN_DEPTH {
+			parents[i] = -1
+		}
+	}
+	return parents
+}
+
+func serverNodeInfo(s *SpanTrace) NodeInfo {
+	return NodeInfo{
+		AutoServiceType: s.AutoServiceType1,
+		AutoServiceID:   s.AutoServiceID1,
+		AppService:      s.AppService,
+		IsIPv4:          s.IsIPv4,
+		IP4:             s.IP41,
+		IP6:             s.IP61,
+	}
+}
+
+// the app_service of the client is only known for the app spans
+func clientNodeInfo(s *SpanTrace) NodeInfo {
+	info := NodeInfo{
+		AutoServiceType: s.AutoServiceType0,
+		AutoServiceID:   s.AutoServiceID0,
+		IsIPv4:          s.IsIPv4,
+		IP4:             s.IP40,
+		IP6:             s.IP60,
+	}
+	if isAppSpan(s) {
+		info.AppService = s.AppService
+	}
+	return info
+}
+
+// UID is the uid of the node, the same as the node uid of the trace map
+func (n *NodeInfo) UID() string {
+	return fmt.Sprintf("%d-%d-%s", n.AutoServiceType, n.AutoServiceID, n.AppService)
+}
+
+func (n *TreeNode) addSpan(s *SpanTrace) {
+	n.ResponseDurationSum += s.ResponseDuration
+	n.ResponseTotal++
+	if s.ResponseStatus == uint8(datatype.STATUS_SERVER_ERROR) {
+		n.ResponseStatusServerErrorCount++
+	}
+	if n.Topic == "" && s.Topic != "" {
+		n.Topic = s.Topic
+	}
+}
+
+func (n *TreeNode) addParentSpanInfo(s *SpanTrace) {
+	info := SpanInfo{
+		AutoServiceType0: s.AutoServiceType0,
+		AutoServiceType1: s.AutoServiceType1,
+		AutoServiceID0:   s.AutoServiceID0,
+		AutoServiceID1:   s.AutoServiceID1,
+		AppService1:      s.AppService,
+		IsIPv4:           s.IsIPv4,
+		IP40:             s.IP40,
+		IP60:             s.IP60,
+		IP41:             s.IP41,
+		IP61:             s.IP61,
+	}
+	if isAppSpan(s) {
+		info.AppService0 = s.AppService
+	}
+	for i := range n.UniqParentSpanInfos {
+		p := &n.UniqParentSpanInfos[i]
+		if p.AutoServiceType0 == info.AutoServiceType0 && p.AutoServiceID0 == info.AutoServiceID0 &&
+			p.AutoServiceType1 == info.AutoServiceType1 && p.AutoServiceID1 == info.AutoServiceID1 &&
+			p.AppService0 == info.AppService0 && p.AppService1 == info.AppService1 {
+			return
+		}
+	}
+	n.UniqParentSpanInfos = append(n.UniqParentSpanInfos, info)
+}
+
+// observationPriority is used to choose the spans of a call when it is observed at several observation points,
+// the app spans have the response of the app itself, then the server side spans, then the client side spans
+func observationPriority(s *SpanTrace) int {
+	if isClientSide(s) {
+		return 0
+	} else if isServerSide(s) {
+		return 1
+	}
+	return 2
+}
+
+// preferObservation returns whether the observation point of span a is preferred to that of span b
+func preferObservation(a, b *SpanTrace) bool {
+	if pa, pb := observationPriority(a), observationPriority(b); pa != pb {
+		return pa > pb
+	}
+	return a.ObservationPoint < b.ObservationPoint
+}
+
+// Call is the requests from the client node to the server node under the same parent span
+type Call struct {
+	Parent    int // index of the parent span, -1 if the spans are root spans
+	Client    NodeInfo
+	Server    NodeInfo
+	HasClient bool  // false if the spans are root spans served by the server node itself
+	Spans     []int // indices of the spans, one span for each request
+}
+
+type callKey struct {
+	parent         int
+	client, server string
+}
+
+// Calls links the spans of a trace and groups them into the calls between nodes. A request is usually observed at
+// several observation points, e.g. by the app span and the c-p/s-p network spans. Each observation point sees every
+// request once, so only the spans of the observation point which sees the most are kept, and each request is
+// counted once.
+func Calls(spans []*SpanTrace) []Call {
+	parents := LinkSpanTraces(spans)
+	calls := make([]Call, 0, len(spans))
+	callIndices := make(map[callKey]int)
+	observations := make(map[int]map[string][]int)
+	for i, s := range spans {
+		c := Call{Parent: parents[i], Server: serverNodeInfo(s)}
+		if c.Parent >= 0 {
+			c.Client, c.HasClient = serverNodeInfo(spans[c.Parent]), true
+		} else if !isServerSide(s) {
+			c.Client, c.HasClient = clientNodeInfo(s), true
+		}
+		serverUID := c.Server.UID()
+		if !c.HasClient || c.Client.UID() == serverUID {
+			// the span is served by the same node of its parent, only count the root spans
+			if c.Parent < 0 {
+				c.HasClient = false
+				c.Spans = []int{i}
+				calls = append(calls, c)
+			}
+			continue
+		}
+		key := callKey{parent: c.Parent, client: c.Client.UID(), server: serverUID}
+		index, ok := callIndices[key]
+		if !ok {
+			index = len(calls)
+			callIndices[key] = index
+			observations[index] = make(map[string][]int)
+			calls = append(calls, c)
+		}
+		observations[index][s.ObservationPoint] = append(observations[index][s.ObservationPoint], i)
+	}
+	for index, points := range observations {
+		var chosen []int
+		for _, indices := range points {
+			if len(indices) > len(chosen) || len(indices) == len(chosen) && preferObservation(spans[indices[0]], spans[chosen[0]]) {
+				chosen = indices
+			}
+		}
+		calls[index].Spans = chosen
+	}
+	return calls
+}
+
+// BuildTraceTree links the spans of a trace, and aggregates them into the tree nodes which are the services
+// identified by auto_service and app_service. Returns nil if there is no span.
+func BuildTraceTree(orgId uint16, traceId string, spans []*SpanTrace) *TraceTree {
+	if len(spans) == 0 {
+		return nil
+	}
+	return BuildTraceTreeOfCalls(orgId, traceId, spans, Calls(spans))
+}
+
+// BuildTraceTreeOfCalls aggregates the calls of the spans returned by Calls into the tree nodes
+func BuildTraceTreeOfCalls(orgId uint16, traceId string, spans []*SpanTrace, calls []Call) *TraceTree {
+	if len(spans) == 0 {
+		return nil
+	}
+	t := AcquireTraceTree()
+	t.OrgId = orgId
+	t.TraceId = traceId
+	t.SearchIndex = HashSearchIndex(traceId)
+	t.Time = spans[0].Time
+	for _, s := range spans {
+		if s.Time < t.Time {
+			t.Time = s.Time
+		}
+	}
+	t.TreeNodes = t.TreeNodes[:0]
+	nodeIndices := make(map[string]int32, len(spans))
+	nodeIndex := func(info NodeInfo) int32 {
+		uid := info.UID()
+		if i, ok := nodeIndices[uid]; ok {
+			return i
+		}
+		i := int32(len(t.TreeNodes))
+		t.TreeNodes = append(t.TreeNodes, TreeNode{
+			ParentNodeIndex: -1,
+			NodeInfo:        info,
+			UID:             uid,
+		})
+		nodeIndices[uid] = i
+		return i
+	}
+
+	for _, c := range calls {
+		server := nodeIndex(c.Server)
+		if !c.HasClient {
+			for _, i := range c.Spans {
+				t.TreeNodes[server].addSpan(spans[i])
+			}
+			continue
+		}
+		client := nodeIndex(c.Client)
+		node := &t.TreeNodes[server]
+		if node.ParentNodeIndex < 0 && !isAncestor(t.TreeNodes, server, client) {
+			node.ParentNodeIndex = client
+		}
+		for _, i := range c.Spans {
+			node.addParentSpanInfo(spans[i])
+			node.addSpan(spans[i])
+		}
+	}
+	return t
+}
+
+// isAncestor returns whether node is an ancestor of (or the same as) the other node
+func isAncestor(nodes []TreeNode, node, other int32) bool {
+	for depth := 0; other >= 0 && depth < len(nodes); depth++ {
+		if other == node {
+			return true
+		}
+		other = nodes[other].ParentNodeIndex
+	}
+	return false
+}
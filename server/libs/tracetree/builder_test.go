@@ -0,0 +1,134 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracetree
+
+import (
+	"testing"
+
+	"github.com/deepflowio/deepflow/server/libs/codec"
+	"github.com/deepflowio/deepflow/server/libs/datatype"
+)
+
+// gateway (s-p) --x_request_id--> gateway (c-p) --req_tcp_seq--> backend (s-p) --syscall--> backend (c-p) -> db
+func newTestSpanTraces() []*SpanTrace {
+	return []*SpanTrace{
+		{Time: 12, ObservationPoint: "s-p", AutoServiceType0: 1, AutoServiceID0: 1, AutoServiceType1: 1, AutoServiceID1: 2, IsIPv4: true, XRequestId1: "x1", ResponseDuration: 100},
+		{Time: 12, ObservationPoint: "c-p", AutoServiceType0: 1, AutoServiceID0: 2, AutoServiceType1: 1, AutoServiceID1: 3, IsIPv4: true, XRequestId0: "x1", ReqTcpSeq: 10, ResponseDuration: 90},
+		{Time: 12, ObservationPoint: "s-p", AutoServiceType0: 1, AutoServiceID0: 2, AutoServiceType1: 1, AutoServiceID1: 3, IsIPv4: true, ReqTcpSeq: 10, SyscallTraceIDRequest: 7, ResponseDuration: 80},
+		{Time: 11, ObservationPoint: "c-p", AutoServiceType0: 1, AutoServiceID0: 3, AutoServiceType1: 1, AutoServiceID1: 4, IsIPv4: true, SyscallTraceIDRequest: 7, ResponseDuration: 50, ResponseStatus: uint8(datatype.STATUS_SERVER_ERROR), Topic: "orders"},
+	}
+}
+
+func TestLinkSpanTraces(t *testing.T) {
+	parents := LinkSpanTraces(newTestSpanTraces())
+	expected := []int{-1, 0, 1, 2}
+	for i := range expected {
+		if parents[i] != expected[i] {
+			t.Errorf("parent of span %d is %d, expected %d", i, parents[i], expected[i])
+		}
+	}
+
+	spans := []*SpanTrace{
+		{SpanId: "a", ParentSpanId: "b"},
+		{SpanId: "b", ParentSpanId: "a"},
+		{SpanId: "c", ParentSpanId: "a", ObservationPoint: "c"},
+		{SpanId: "c", ParentSpanId: "a"},
+		{ParentSpanId: "c"},
+	}
+	parents = LinkSpanTraces(spans)
+	if parents[0] >= 0 && parents[1] >= 0 {
+		t.Errorf("loop of spans is not broken")
+	}
+	if parents[4] != 3 {
+		t.Errorf("parent of span 4 is %d, expected the app span 3", parents[4])
+	}
+}
+
+func TestCalls(t *testing.T) {
+	// frontend (app span) calls backend twice, each request is seen by a c-p span and a backend app span
+	spans := []*SpanTrace{
+		{SpanId: "a", AutoServiceType0: 1, AutoServiceID0: 1, AutoServiceType1: 1, AutoServiceID1: 1, AppService: "frontend"},
+		{ParentSpanId: "a", ObservationPoint: "c-p", AutoServiceType0: 1, AutoServiceID0: 1, AutoServiceType1: 1, AutoServiceID1: 2, AppService: "backend", ReqTcpSeq: 10, ResponseDuration: 80},
+		{ObservationPoint: "s-p", AutoServiceType0: 1, AutoServiceID0: 1, AutoServiceType1: 1, AutoServiceID1: 2, AppService: "backend", ReqTcpSeq: 10, ResponseDuration: 70},
+		{SpanId: "b", ParentSpanId: "a", AutoServiceType0: 1, AutoServiceID0: 2, AutoServiceType1: 1, AutoServiceID1: 2, AppService: "backend", ResponseDuration: 60},
+		{ParentSpanId: "a", ObservationPoint: "c-p", AutoServiceType0: 1, AutoServiceID0: 1, AutoServiceType1: 1, AutoServiceID1: 2, AppService: "backend", ReqTcpSeq: 20, ResponseDuration: 40},
+		{SpanId: "c", ParentSpanId: "a", AutoServiceType0: 1, AutoServiceID0: 2, AutoServiceType1: 1, AutoServiceID1: 2, AppService: "backend", ResponseDuration: 30},
+	}
+	calls := Calls(spans)
+	if len(calls) != 2 {
+		t.Fatalf("unexpected calls %+v", calls)
+	}
+	if calls[0].HasClient || calls[0].Server.UID() != "1-1-frontend" || len(calls[0].Spans) != 1 {
+		t.Errorf("unexpected root call %+v", calls[0])
+	}
+	// the app spans are chosen, the network spans of the same requests are not counted again
+	c := calls[1]
+	if !c.HasClient || c.Parent != 0 || c.Client.UID() != "1-1-frontend" || c.Server.UID() != "1-2-backend" || len(c.Spans) != 2 || c.Spans[0] != 3 || c.Spans[1] != 5 {
+		t.Errorf("unexpected call %+v", c)
+	}
+
+	tree := BuildTraceTreeOfCalls(1, "t1", spans, calls)
+	if len(tree.TreeNodes) != 2 || tree.TreeNodes[1].ResponseTotal != 2 || tree.TreeNodes[1].ResponseDurationSum != 90 {
+		t.Errorf("unexpected tree nodes %+v", tree.TreeNodes)
+	}
+}
+
+func TestBuildTraceTree(t *testing.T) {
+	if BuildTraceTree(1, "t1", nil) != nil {
+		t.Fatalf("trace tree of no span is built")
+	}
+	tree := BuildTraceTree(2, "t1", newTestSpanTraces())
+	if tree.OrgId != 2 || tree.TraceId != "t1" || tree.Time != 11 || tree.SearchIndex != HashSearchIndex("t1") {
+		t.Errorf("unexpected trace tree %+v", tree)
+	}
+	// gateway 1-2, backend 1-3, db 1-4, the client of the root server side span is unknown
+	if len(tree.TreeNodes) != 3 {
+		t.Fatalf("unexpected tree nodes %+v", tree.TreeNodes)
+	}
+	parents := map[string]string{"1-3-": "1-2-", "1-4-": "1-3-"}
+	for _, n := range tree.TreeNodes {
+		parent := ""
+		if n.ParentNodeIndex >= 0 {
+			parent = tree.TreeNodes[n.ParentNodeIndex].UID
+		}
+		if parent != parents[n.UID] {
+			t.Errorf("parent of node %s is %s, expected %s", n.UID, parent, parents[n.UID])
+		}
+		switch n.UID {
+		case "1-3-":
+			// the server side span of the same request is not counted again
+			if n.ResponseTotal != 1 || n.ResponseDurationSum != 90 || len(n.UniqParentSpanInfos) != 1 {
+				t.Errorf("unexpected node %+v", n)
+			}
+		case "1-4-":
+			if n.ResponseStatusServerErrorCount != 1 || n.Topic != "orders" {
+				t.Errorf("unexpected node %+v", n)
+			}
+		}
+	}
+
+	tree.Encode()
+	decoder := &codec.SimpleDecoder{}
+	decoder.Init(tree.encodedTreeNodes)
+	decoded := &TraceTree{}
+	if err := decoded.Decode(decoder); err != nil {
+		t.Fatalf("decode failed: %s", err)
+	}
+	if len(decoded.TreeNodes) != len(tree.TreeNodes) || decoded.TreeNodes[2].ParentNodeIndex != tree.TreeNodes[2].ParentNodeIndex {
+		t.Errorf("decoded tree nodes %+v are different", decoded.TreeNodes)
+	}
+}
@@ -0,0 +1,54 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+// TraceMapNode is a service in the call graph, identified by auto_service and app_service
+type TraceMapNode struct {
+	// encoding: auto_service_type-auto_service_id-app_service
+	Uid             string `json:"uid"`
+	AutoServiceType uint8  `json:"auto_service_type"`
+	AutoServiceId   uint32 `json:"auto_service_id"`
+	AppService      string `json:"app_service"`
+	PodId           uint32 `json:"pod_id"`
+	ProcessId       uint32 `json:"process_id"`
+	ProcessKname    string `json:"process_kname"`
+	IP              string `json:"ip"`
+	QuerierRegion   string `json:"querier_region"`
+
+	ResponseTotal                  uint64 `json:"response_total"`
+	ResponseStatusServerErrorCount uint64 `json:"response_status_server_error_count"`
+}
+
+// TraceMapEdge is the calls from the client node to the server node
+type TraceMapEdge struct {
+	ClientUid string `json:"client_uid"`
+	ServerUid string `json:"server_uid"`
+
+	ResponseTotal                  uint64  `json:"response_total"`
+	ResponseStatusServerErrorCount uint64  `json:"response_status_server_error_count"`
+	ErrorRate                      float64 `json:"error_rate"`            // %
+	ResponseDurationAvg            float64 `json:"response_duration_avg"` // us
+	ResponseDurationP50            uint64  `json:"response_duration_p50"` // us
+	ResponseDurationP95            uint64  `json:"response_duration_p95"` // us
+}
+
+type TraceMapResult struct {
+	Nodes      []*TraceMapNode `json:"nodes"`
+	Edges      []*TraceMapEdge `json:"edges"`
+	TraceCount int             `json:"trace_count"`
+	SpanCount  int             `json:"span_count"`
+}
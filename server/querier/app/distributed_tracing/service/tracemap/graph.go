@@ -0,0 +1,226 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package tracemap
+
+import (
+	"net"
+	"sort"
+
+	"github.com/deepflowio/deepflow/server/libs/datatype"
+	"github.com/deepflowio/deepflow/server/libs/tracetree"
+	"github.com/deepflowio/deepflow/server/libs/utils"
+	"github.com/deepflowio/deepflow/server/querier/app/distributed_tracing/model"
+)
+
+// the max response durations of an edge kept for the percentiles
+const MAX_DURATION_SAMPLES = 10000
+
+// Span is the fields of l7_flow_log used to build the trace map, the spans are linked and aggregated by
+// libs/tracetree, the same as the trace trees assembled by the ingester
+type Span struct {
+	tracetree.SpanTrace
+
+	TraceId       string
+	PodId0        uint32
+	PodId1        uint32
+	ProcessKname0 string
+	ProcessKname1 string
+}
+
+func (s *Span) isServerError() bool {
+	return s.ResponseStatus == uint8(datatype.STATUS_SERVER_ERROR)
+}
+
+// serverNode returns the node which serves the request of the span
+func (s *Span) serverNode(info tracetree.NodeInfo) *model.TraceMapNode {
+	return newNode(info, s.PodId1, s.ProcessId1, s.ProcessKname1)
+}
+
+// clientNode returns the node which sends the request of the span
+func (s *Span) clientNode(info tracetree.NodeInfo) *model.TraceMapNode {
+	return newNode(info, s.PodId0, s.ProcessId0, s.ProcessKname0)
+}
+
+func newNode(info tracetree.NodeInfo, podId, processId uint32, processKname string) *model.TraceMapNode {
+	ip := ""
+	if info.IsIPv4 {
+		ip = utils.IpFromUint32(info.IP4).String()
+	} else if info.IP6 != nil {
+		ip = info.IP6.String()
+	}
+	return &model.TraceMapNode{
+		Uid:             info.UID(),
+		AutoServiceType: info.AutoServiceType,
+		AutoServiceId:   info.AutoServiceID,
+		AppService:      info.AppService,
+		PodId:           podId,
+		ProcessId:       processId,
+		ProcessKname:    processKname,
+		IP:              ip,
+	}
+}
+
+type edge struct {
+	model.TraceMapEdge
+	durationSum uint64
+	durations   []uint64
+}
+
+func (e *edge) add(s *Span) {
+	e.ResponseTotal++
+	if s.isServerError() {
+		e.ResponseStatusServerErrorCount++
+	}
+	e.durationSum += s.ResponseDuration
+	if len(e.durations) < MAX_DURATION_SAMPLES {
+		e.durations = append(e.durations, s.ResponseDuration)
+	} else {
+		// keep a uniform sample of the durations
+		if r := utils.DJBHash(e.ResponseTotal, s.TraceId) % e.ResponseTotal; r < MAX_DURATION_SAMPLES {
+			e.durations[r] = s.ResponseDuration
+		}
+	}
+}
+
+// Graph aggregates the spans of traces into the service call graph
+type Graph struct {
+	region     string
+	nodes      map[string]*model.TraceMapNode
+	edges      map[[2]string]*edge
+	traceCount int
+	spanCount  int
+}
+
+func NewGraph(region string) *Graph {
+	return &Graph{
+		region: region,
+		nodes:  make(map[string]*model.TraceMapNode),
+		edges:  make(map[[2]string]*edge),
+	}
+}
+
+func (g *Graph) node(n *model.TraceMapNode) *model.TraceMapNode {
+	if exist, ok := g.nodes[n.Uid]; ok {
+		return exist
+	}
+	n.QuerierRegion = g.region
+	g.nodes[n.Uid] = n
+	return n
+}
+
+// AddTrace adds the spans of a trace, and returns the trace tree of it
+func (g *Graph) AddTrace(traceId string, orgId uint16, spans []*Span) *tracetree.TraceTree {
+	if len(spans) == 0 {
+		return nil
+	}
+	g.traceCount++
+	g.spanCount += len(spans)
+
+	spanTraces := make([]*tracetree.SpanTrace, len(spans))
+	for i, s := range spans {
+		spanTraces[i] = &s.SpanTrace
+	}
+	calls := tracetree.Calls(spanTraces)
+	tree := tracetree.BuildTraceTreeOfCalls(orgId, traceId, spanTraces, calls)
+	for i := range tree.TreeNodes {
+		tree.TreeNodes[i].QuerierRegion = g.region
+	}
+
+	for _, c := range calls {
+		first := spans[c.Spans[0]]
+		server := g.node(first.serverNode(c.Server))
+		for _, i := range c.Spans {
+			server.ResponseTotal++
+			if spans[i].isServerError() {
+				server.ResponseStatusServerErrorCount++
+			}
+		}
+		if !c.HasClient {
+			continue
+		}
+
+		var client *model.TraceMapNode
+		if c.Parent >= 0 {
+			client = g.node(spans[c.Parent].serverNode(c.Client))
+		} else {
+			client = g.node(first.clientNode(c.Client))
+		}
+		key := [2]string{client.Uid, server.Uid}
+		e, ok := g.edges[key]
+		if !ok {
+			e = &edge{TraceMapEdge: model.TraceMapEdge{ClientUid: client.Uid, ServerUid: server.Uid}}
+			g.edges[key] = e
+		}
+		for _, i := range c.Spans {
+			e.add(spans[i])
+		}
+	}
+	return tree
+}
+
+func ipv4ToUint32(ip string) uint32 {
+	if ip4 := net.ParseIP(ip).To4(); ip4 != nil {
+		return utils.IpToUint32(ip4)
+	}
+	return 0
+}
+
+func ipv6(ip string) net.IP {
+	return net.ParseIP(ip).To16()
+}
+
+// percentile returns the nearest-rank percentile of the sorted durations
+func percentile(sorted []uint64, p int) uint64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (len(sorted)*p + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// Result returns the nodes and edges sorted by uid
+func (g *Graph) Result() *model.TraceMapResult {
+	result := &model.TraceMapResult{
+		Nodes:      make([]*model.TraceMapNode, 0, len(g.nodes)),
+		Edges:      make([]*model.TraceMapEdge, 0, len(g.edges)),
+		TraceCount: g.traceCount,
+		SpanCount:  g.spanCount,
+	}
+	for _, n := range g.nodes {
+		result.Nodes = append(result.Nodes, n)
+	}
+	sort.Slice(result.Nodes, func(i, j int) bool { return result.Nodes[i].Uid < result.Nodes[j].Uid })
+
+	for _, e := range g.edges {
+		sort.Slice(e.durations, func(i, j int) bool { return e.durations[i] < e.durations[j] })
+		e.ErrorRate = float64(e.ResponseStatusServerErrorCount) * 100 / float64(e.ResponseTotal)
+		e.ResponseDurationAvg = float64(e.durationSum) / float64(e.ResponseTotal)
+		e.ResponseDurationP50 = percentile(e.durations, 50)
+		e.ResponseDurationP95 = percentile(e.durations, 95)
+		edge := e.TraceMapEdge
+		result.Edges = append(result.Edges, &edge)
+	}
+	sort.Slice(result.Edges, func(i, j int) bool {
+		if result.Edges[i].ClientUid != result.Edges[j].ClientUid {
+			return result.Edges[i].ClientUid < result.Edges[j].ClientUid
+		}
+		return result.Edges[i].ServerUid < result.Edges[j].ServerUid
+	})
+	return result
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package tracemap
+
+import (
+	"testing"
+
+	"github.com/deepflowio/deepflow/server/libs/datatype"
+	"github.com/deepflowio/deepflow/server/libs/tracetree"
+)
+
+func newTestSpan(s tracetree.SpanTrace) *Span {
+	return &Span{SpanTrace: s, TraceId: "t1"}
+}
+
+// frontend (app span) -> c-p (client process) -> s-p (server process) -> backend (app span),
+// c-p, s-p and backend are the same call and it is counted once
+func newTestSpans() []*Span {
+	return []*Span{
+		newTestSpan(tracetree.SpanTrace{Time: 10, SpanId: "a", AppService: "frontend", AutoServiceType0: 1, AutoServiceID0: 1, AutoServiceType1: 1, AutoServiceID1: 1, IsIPv4: true, IP40: 0x0a000001, IP41: 0x0a000001, ResponseDuration: 100}),
+		newTestSpan(tracetree.SpanTrace{Time: 11, ParentSpanId: "a", ObservationPoint: "c-p", AutoServiceType0: 1, AutoServiceID0: 1, AutoServiceType1: 2, AutoServiceID1: 2, AppService: "backend", IsIPv4: true, IP40: 0x0a000001, IP41: 0x0a000002, ReqTcpSeq: 100, ResponseDuration: 80, ResponseStatus: uint8(datatype.STATUS_SERVER_ERROR)}),
+		newTestSpan(tracetree.SpanTrace{Time: 11, ObservationPoint: "s-p", AutoServiceType0: 1, AutoServiceID0: 1, AutoServiceType1: 2, AutoServiceID1: 2, AppService: "backend", IsIPv4: true, IP40: 0x0a000001, IP41: 0x0a000002, ReqTcpSeq: 100, ResponseDuration: 70}),
+		newTestSpan(tracetree.SpanTrace{Time: 12, SpanId: "b", ParentSpanId: "a", AppService: "backend", AutoServiceType0: 2, AutoServiceID0: 2, AutoServiceType1: 2, AutoServiceID1: 2, IsIPv4: true, IP40: 0x0a000002, IP41: 0x0a000002, ResponseDuration: 60, ResponseStatus: uint8(datatype.STATUS_SERVER_ERROR)}),
+	}
+}
+
+func TestGraphAddTrace(t *testing.T) {
+	g := NewGraph("region-1")
+	tree := g.AddTrace("t1", 1, newTestSpans())
+	if tree == nil || tree.Time != 10 || len(tree.TreeNodes) != 2 {
+		t.Fatalf("unexpected trace tree %+v", tree)
+	}
+	if tree.TreeNodes[1].ParentNodeIndex != 0 || tree.TreeNodes[1].ResponseTotal != 1 || tree.TreeNodes[1].ResponseStatusServerErrorCount != 1 || tree.TreeNodes[1].QuerierRegion != "region-1" {
+		t.Errorf("unexpected tree node %+v", tree.TreeNodes[1])
+	}
+
+	result := g.Result()
+	if result.TraceCount != 1 || result.SpanCount != 4 || len(result.Nodes) != 2 || len(result.Edges) != 1 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	e := result.Edges[0]
+	if e.ClientUid != "1-1-frontend" || e.ServerUid != "2-2-backend" {
+		t.Errorf("unexpected edge %s -> %s", e.ClientUid, e.ServerUid)
+	}
+	// the backend app span is chosen for the call
+	if e.ResponseTotal != 1 || e.ResponseStatusServerErrorCount != 1 || e.ResponseDurationAvg != 60 || e.ResponseDurationP50 != 60 || e.ResponseDurationP95 != 60 {
+		t.Errorf("unexpected edge metrics %+v", e)
+	}
+	for _, n := range result.Nodes {
+		if n.QuerierRegion != "region-1" {
+			t.Errorf("unexpected region of node %s", n.Uid)
+		}
+		if n.Uid == "2-2-backend" && (n.IP != "10.0.0.2" || n.ResponseTotal != 1) {
+			t.Errorf("unexpected node %+v", n)
+		}
+	}
+}
+
+func TestGraphAddTraceRepeatedCalls(t *testing.T) {
+	// frontend calls backend twice, each call is seen by a c-p span and a backend app span
+	spans := newTestSpans()
+	spans = append(spans,
+		newTestSpan(tracetree.SpanTrace{Time: 13, ParentSpanId: "a", ObservationPoint: "c-p", AutoServiceType0: 1, AutoServiceID0: 1, AutoServiceType1: 2, AutoServiceID1: 2, AppService: "backend", IsIPv4: true, IP40: 0x0a000001, IP41: 0x0a000002, ReqTcpSeq: 200, ResponseDuration: 40}),
+		newTestSpan(tracetree.SpanTrace{Time: 14, SpanId: "c", ParentSpanId: "a", AppService: "backend", AutoServiceType0: 2, AutoServiceID0: 2, AutoServiceType1: 2, AutoServiceID1: 2, IsIPv4: true, IP40: 0x0a000002, IP41: 0x0a000002, ResponseDuration: 30}),
+	)
+	g := NewGraph("")
+	tree := g.AddTrace("t1", 1, spans)
+	if tree == nil || len(tree.TreeNodes) != 2 || tree.TreeNodes[1].ResponseTotal != 2 {
+		t.Fatalf("unexpected trace tree %+v", tree)
+	}
+	result := g.Result()
+	if len(result.Edges) != 1 || result.Edges[0].ResponseTotal != 2 || result.Edges[0].ResponseDurationAvg != 45 {
+		t.Errorf("unexpected edges %+v", result.Edges)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []uint64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	if p := percentile(sorted, 50); p != 5 {
+		t.Errorf("p50 is %d, expected 5", p)
+	}
+	if p := percentile(sorted, 95); p != 10 {
+		t.Errorf("p95 is %d, expected 10", p)
+	}
+	if p := percentile(nil, 95); p != 0 {
+		t.Errorf("p95 of empty is %d, expected 0", p)
+	}
+}
+
+func TestRowToSpan(t *testing.T) {
+	row := []interface{}{uint32(10), "t1", "a", "", "c-p", uint8(1), uint32(1), uint8(2), uint32(2), "backend", uint8(1),
+		"10.0.0.1", "10.0.0.2", uint32(3), uint32(4), uint32(5), uint32(6), "p0", "p1", uint32(100), uint64(7), uint64(8), uint64(80), uint8(3),
+		"x0", "x1", "orders"}
+	s := rowToSpan(row)
+	if s == nil || s.TraceId != "t1" || !s.IsIPv4 || s.IP41 != 0x0a000002 || s.ReqTcpSeq != 100 || s.SyscallTraceIDResponse != 8 || s.ResponseStatus != 3 || s.ProcessKname1 != "p1" || s.XRequestId1 != "x1" || s.Topic != "orders" {
+		t.Errorf("unexpected span %+v", s)
+	}
+	if rowToSpan(row[:10]) != nil {
+		t.Errorf("short row is converted")
+	}
+}
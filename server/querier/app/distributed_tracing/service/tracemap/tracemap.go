@@ -20,9 +20,17 @@ import (
 
 	"github.com/deepflowio/deepflow/server/querier/app/distributed_tracing/model"
 	"github.com/deepflowio/deepflow/server/querier/config"
+	"github.com/deepflowio/deepflow/server/querier/router"
 )
 
 func TraceMap(args model.TraceMap, cfg *config.QuerierConfig, c *gin.Context, done chan bool, generator *TraceMapGenerator) {
-	done <- true
-	return
+	defer func() { done <- true }()
+	if generator == nil {
+		generator = NewTraceMapGenerator(nil, cfg)
+	}
+	result, debug, err := generator.Generate(&args)
+	if err != nil {
+		log.Errorf("trace map failed: %s", err)
+	}
+	router.JsonResponse(c, result, debug, err)
 }
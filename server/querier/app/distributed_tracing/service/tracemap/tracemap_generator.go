@@ -16,19 +16,361 @@
 package tracemap
 
 import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+
+	"github.com/deepflowio/deepflow/server/libs/ckdb"
+	"github.com/deepflowio/deepflow/server/libs/datatype"
 	"github.com/deepflowio/deepflow/server/libs/queue"
+	"github.com/deepflowio/deepflow/server/libs/tracetree"
+	"github.com/deepflowio/deepflow/server/querier/app/distributed_tracing/common"
+	"github.com/deepflowio/deepflow/server/querier/app/distributed_tracing/model"
+	querier_common "github.com/deepflowio/deepflow/server/querier/common"
 	"github.com/deepflowio/deepflow/server/querier/config"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/client"
 )
 
+var log = logging.MustGetLogger("tracemap")
+
+// SPAN_COLUMNS is formatted with the l7_protocol of kafka, whose request_domain is the topic
+const SPAN_COLUMNS = "toUInt32(time) AS time, trace_id, span_id, parent_span_id, observation_point, " +
+	"auto_service_type_0, auto_service_id_0, auto_service_type_1, auto_service_id_1, app_service, is_ipv4, " +
+	"if(is_ipv4, IPv4NumToString(ip4_0), IPv6NumToString(ip6_0)) AS ip_0, " +
+	"if(is_ipv4, IPv4NumToString(ip4_1), IPv6NumToString(ip6_1)) AS ip_1, " +
+	"pod_id_0, pod_id_1, process_id_0, process_id_1, process_kname_0, process_kname_1, " +
+	"req_tcp_seq, syscall_trace_id_request, syscall_trace_id_response, response_duration, response_status, " +
+	"x_request_id_0, x_request_id_1, if(l7_protocol = %d, request_domain, '') AS topic"
+
 type TraceMapGenerator struct {
 	sharedQueue *queue.OverwriteQueue
 	cfg         *config.QuerierConfig
+
+	// the trace trees waiting to be written, deduplicated by trace_id
+	sync.Mutex
+	traceTrees map[string]*tracetree.TraceTree
 }
 
 func NewTraceMapGenerator(sharedQueue *queue.OverwriteQueue, cfg *config.QuerierConfig) *TraceMapGenerator {
-	return &TraceMapGenerator{sharedQueue: sharedQueue, cfg: cfg}
+	return &TraceMapGenerator{sharedQueue: sharedQueue, cfg: cfg, traceTrees: make(map[string]*tracetree.TraceTree)}
 }
 
+// Start writes the trace trees generated by the trace map queries to the shared queue every write interval,
+// and the ingester writes them to flow_log.trace_tree
 func (g *TraceMapGenerator) Start() {
+	if g.sharedQueue == nil {
+		return
+	}
+	interval := time.Duration(g.cfg.Tracemap.WriteInterval) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			g.flush()
+		}
+	}()
+}
+
+func (g *TraceMapGenerator) putTraceTree(tree *tracetree.TraceTree) {
+	if g.sharedQueue == nil {
+		tree.Release()
+		return
+	}
+	g.Lock()
+	if old, ok := g.traceTrees[tree.TraceId]; ok {
+		old.Release()
+	}
+	g.traceTrees[tree.TraceId] = tree
+	g.Unlock()
+}
+
+func (g *TraceMapGenerator) flush() {
+	g.Lock()
+	traceTrees := g.traceTrees
+	g.traceTrees = make(map[string]*tracetree.TraceTree)
+	g.Unlock()
+
+	batchSize := g.cfg.Tracemap.WriteBatchSize
+	if batchSize <= 0 {
+		batchSize = len(traceTrees)
+	}
+	batch := make([]interface{}, 0, batchSize)
+	for _, tree := range traceTrees {
+		tree.Encode()
+		batch = append(batch, tree)
+		if len(batch) >= batchSize {
+			g.sharedQueue.Put(batch...)
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		g.sharedQueue.Put(batch...)
+	}
+	if len(traceTrees) > 0 {
+		log.Debugf("put %d trace trees to shared queue", len(traceTrees))
+	}
+}
+
+// querierRegion returns the region of the local ClickHouse, only the spans of it are queried
+func (g *TraceMapGenerator) querierRegion(regions map[string]string) string {
+	names := make([]string, 0, len(regions))
+	for name, host := range regions {
+		if host == "" || host == g.cfg.Tracemap.Querier.Host {
+			return name
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	if len(names) > 1 {
+		log.Warningf("trace map only queries the local region %s, other regions %v are ignored", names[0], names[1:])
+	}
+	return names[0]
+}
+
+// Generate queries the traces in the time range and aggregates their spans into the call graph. The trace_ids are
+// split into iterations by their hash, so that each iteration queries at most max_trace_per_iteration traces.
+func (g *TraceMapGenerator) Generate(args *model.TraceMap) (*model.TraceMapResult, map[string]interface{}, error) {
+	cfg := &g.cfg.Tracemap
+	orgID := ckdb.DEFAULT_ORG_ID
+	if args.OrgID != "" {
+		id, err := strconv.Atoi(args.OrgID)
+		if err != nil || id < 0 || id > ckdb.MAX_ORG_ID {
+			return nil, nil, querier_common.NewError(querier_common.INVALID_POST_DATA, fmt.Sprintf("invalid org id %s", args.OrgID))
+		}
+		orgID = id
+	}
+	if args.TimeEnd < args.TimeStart {
+		return nil, nil, querier_common.NewError(querier_common.INVALID_POST_DATA, fmt.Sprintf("time_end %d is less than time_start %d", args.TimeEnd, args.TimeStart))
+	}
+	table := fmt.Sprintf("%s%s.`%s`", ckdb.OrgDatabasePrefix(uint16(orgID)), common.DATABASE_FLOW_LOG, common.TABLE_L7_FLOW_LOG)
+	condition, err := transQueryCondition(args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	graph := NewGraph(g.querierRegion(args.Regions))
+	debugInfo := &client.DebugInfo{}
+	iterations := nextPowerOfTwo(cfg.TraceIdQueryIterations)
+	for i := uint64(0); i < iterations; i++ {
+		sql := fmt.Sprintf("SELECT trace_id FROM %s WHERE time >= %d AND time <= %d AND trace_id != ''%s AND cityHash64(trace_id) %% %d = %d GROUP BY trace_id LIMIT %d",
+			table, args.TimeStart, args.TimeEnd, condition, iterations, i, cfg.MaxTracePerIteration)
+		result, err := g.query(args, sql, debugInfo)
+		if err != nil {
+			return nil, debugInfo.Get(), err
+		}
+		traceIds := make([]string, 0, len(result.Values))
+		for _, value := range result.Values {
+			if row, ok := value.([]interface{}); ok && len(row) > 0 {
+				traceIds = append(traceIds, toString(row[0]))
+			}
+		}
+
+		batchSize := int(cfg.BatchTracesCountMax)
+		if batchSize <= 0 {
+			batchSize = len(traceIds)
+		}
+		for start := 0; start < len(traceIds); start += batchSize {
+			end := start + batchSize
+			if end > len(traceIds) {
+				end = len(traceIds)
+			}
+			if err := g.addTraces(args, graph, uint16(orgID), table, traceIds[start:end], debugInfo); err != nil {
+				return nil, debugInfo.Get(), err
+			}
+		}
+		if args.Context != nil && args.Context.Err() != nil {
+			return nil, debugInfo.Get(), args.Context.Err()
+		}
+	}
+
+	var debug map[string]interface{}
+	if args.Debug {
+		debug = debugInfo.Get()
+	}
+	return graph.Result(), debug, nil
+}
+
+// transQueryCondition translates the query condition with the querier engine, so that its tags are translated
+// and its values are escaped the same way as the SQL API
+func transQueryCondition(args *model.TraceMap) (string, error) {
+	if strings.TrimSpace(args.QueryCondition) == "" {
+		return "", nil
+	}
+	ckEngine := &clickhouse.CHEngine{DB: common.DATABASE_FLOW_LOG, Table: common.TABLE_L7_FLOW_LOG, ORGID: args.OrgID}
+	ckEngine.Init()
+	filter, err := ckEngine.TransFilter(args.QueryCondition)
+	if err != nil {
+		return "", querier_common.NewError(querier_common.INVALID_POST_DATA, fmt.Sprintf("invalid query condition %s: %s", args.QueryCondition, err))
+	}
+	if filter == "" {
+		return "", nil
+	}
+	return " AND (" + filter + ")", nil
+}
+
+func (g *TraceMapGenerator) addTraces(args *model.TraceMap, graph *Graph, orgID uint16, table string, traceIds []string, debugInfo *client.DebugInfo) error {
+	quoted := make([]string, len(traceIds))
+	for i, traceId := range traceIds {
+		quoted[i] = "'" + escapeString(traceId) + "'"
+	}
+	delta := int(g.cfg.Tracemap.TraceQueryDelta)
+	sql := fmt.Sprintf("SELECT %s FROM %s WHERE time >= %d AND time <= %d AND trace_id IN (%s) ORDER BY time",
+		fmt.Sprintf(SPAN_COLUMNS, datatype.L7_PROTOCOL_KAFKA), table, args.TimeStart-delta, args.TimeEnd+delta, strings.Join(quoted, ","))
+	result, err := g.query(args, sql, debugInfo)
+	if err != nil {
+		return err
+	}
+
+	traces := make(map[string][]*Span, len(traceIds))
+	for _, value := range result.Values {
+		row, ok := value.([]interface{})
+		if !ok {
+			continue
+		}
+		span := rowToSpan(row)
+		if span == nil {
+			continue
+		}
+		traces[span.TraceId] = append(traces[span.TraceId], span)
+	}
+	for _, traceId := range traceIds {
+		spans := traces[traceId]
+		if len(spans) == 0 {
+			continue
+		}
+		if tree := graph.AddTrace(traceId, orgID, spans); tree != nil {
+			g.putTraceTree(tree)
+		}
+	}
+	return nil
+}
+
+func (g *TraceMapGenerator) query(args *model.TraceMap, sql string, debugInfo *client.DebugInfo) (*querier_common.Result, error) {
+	chClient := client.Client{
+		Host:     g.cfg.Clickhouse.Host,
+		Port:     g.cfg.Clickhouse.Port,
+		UserName: g.cfg.Clickhouse.User,
+		Password: g.cfg.Clickhouse.Password,
+		DB:       common.DATABASE_FLOW_LOG,
+		Context:  args.Context,
+		Debug:    &client.Debug{IP: g.cfg.Clickhouse.Host},
+	}
+	// not a simple sql, the dictionaries in the translated query condition need the database of the org
+	result, err := chClient.DoQuery(&client.QueryParams{Sql: sql, ORGID: args.OrgID})
+	debugInfo.Debug = append(debugInfo.Debug, *chClient.Debug)
+	return result, err
+}
+
+func nextPowerOfTwo(n uint64) uint64 {
+	p := uint64(1)
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
+
+func escapeString(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s)
+}
+
+// rowToSpan converts a row of SPAN_COLUMNS to the span
+func rowToSpan(row []interface{}) *Span {
+	if len(row) < 27 {
+		return nil
+	}
+	s := &Span{
+		TraceId:       toString(row[1]),
+		PodId0:        uint32(toUint64(row[13])),
+		PodId1:        uint32(toUint64(row[14])),
+		ProcessKname0: toString(row[17]),
+		ProcessKname1: toString(row[18]),
+	}
+	s.Time = uint32(toUint64(row[0]))
+	s.SpanId = toString(row[2])
+	s.ParentSpanId = toString(row[3])
+	s.ObservationPoint = toString(row[4])
+	s.AutoServiceType0 = uint8(toUint64(row[5]))
+	s.AutoServiceID0 = uint32(toUint64(row[6]))
+	s.AutoServiceType1 = uint8(toUint64(row[7]))
+	s.AutoServiceID1 = uint32(toUint64(row[8]))
+	s.AppService = toString(row[9])
+	s.IsIPv4 = toUint64(row[10]) != 0
+	if s.IsIPv4 {
+		s.IP40, s.IP41 = ipv4ToUint32(toString(row[11])), ipv4ToUint32(toString(row[12]))
+	} else {
+		s.IP60, s.IP61 = ipv6(toString(row[11])), ipv6(toString(row[12]))
+	}
+	s.ProcessId0 = uint32(toUint64(row[15]))
+	s.ProcessId1 = uint32(toUint64(row[16]))
+	s.ReqTcpSeq = uint32(toUint64(row[19]))
+	s.SyscallTraceIDRequest = toUint64(row[20])
+	s.SyscallTraceIDResponse = toUint64(row[21])
+	s.ResponseDuration = toUint64(row[22])
+	s.ResponseStatus = uint8(toUint64(row[23]))
+	s.XRequestId0 = toString(row[24])
+	s.XRequestId1 = toString(row[25])
+	s.Topic = toString(row[26])
+	return s
+}
+
+func toString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case *string:
+		if s != nil {
+			return *s
+		}
+	case nil:
+	default:
+		return fmt.Sprint(v)
+	}
+	return ""
+}
 
+func toUint64(v interface{}) uint64 {
+	switch n := v.(type) {
+	case uint8:
+		return uint64(n)
+	case uint16:
+		return uint64(n)
+	case uint32:
+		return uint64(n)
+	case uint64:
+		return n
+	case int8:
+		return uint64(n)
+	case int16:
+		return uint64(n)
+	case int32:
+		return uint64(n)
+	case int64:
+		return uint64(n)
+	case int:
+		return uint64(n)
+	case float64:
+		return uint64(n)
+	case bool:
+		if n {
+			return 1
+		}
+	case time.Time:
+		return uint64(n.Unix())
+	case string:
+		u, _ := strconv.ParseUint(n, 10, 64)
+		return u
+	}
+	return 0
 }
@@ -0,0 +1,50 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+const (
+	SUCCESS            = "SUCCESS"
+	FAIL               = "FAIL"
+	INVALID_PARAMETERS = "INVALID_PARAMETERS"
+	INVALID_POST_DATA  = "INVALID_POST_DATA"
+	SERVER_ERROR       = "SERVER_ERROR"
+)
+
+const (
+	DATABASE_FLOW_LOG = "flow_log"
+	TABLE_L4_FLOW_LOG = "l4_flow_log"
+	TABLE_L7_PACKET   = "l7_packet"
+	TAG_FLOW_ID       = "flow_id"
+)
+
+const (
+	HEADER_KEY_X_ORG_ID = "X-Org-Id"
+)
+
+const (
+	FORMAT_PCAP   = "pcap"
+	FORMAT_PCAPNG = "pcapng"
+
+	CONTENT_TYPE_PCAP   = "application/vnd.tcpdump.pcap"
+	CONTENT_TYPE_PCAPNG = "application/x-pcapng"
+)
+
+const (
+	// the max count of flows and packet batches in one download
+	MAX_FLOW_COUNT         = 10000
+	MAX_PACKET_BATCH_COUNT = 100000
+)
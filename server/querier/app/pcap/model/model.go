@@ -0,0 +1,29 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import "context"
+
+type PcapDownload struct {
+	FlowIDs   []uint64 `json:"flow_ids"`
+	TagFilter string   `json:"tag_filter"` // filter of l4_flow_log, e.g.: ip_0='1.1.1.1' AND server_port=80
+	TimeStart int      `json:"time_start" binding:"required"`
+	TimeEnd   int      `json:"time_end" binding:"required"`
+	Format    string   `json:"format"` // pcapng or pcap, default: pcapng
+	Context   context.Context
+	OrgID     string
+}
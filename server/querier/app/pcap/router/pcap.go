@@ -0,0 +1,84 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/op/go-logging"
+
+	"github.com/deepflowio/deepflow/server/querier/app/pcap/common"
+	"github.com/deepflowio/deepflow/server/querier/app/pcap/model"
+	"github.com/deepflowio/deepflow/server/querier/app/pcap/service"
+	"github.com/deepflowio/deepflow/server/querier/router"
+)
+
+var log = logging.MustGetLogger("pcap")
+
+func PcapRouter(e *gin.Engine) {
+	e.POST("/v1/pcap/download", pcapDownload())
+}
+
+func pcapDownload() gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		var args model.PcapDownload
+
+		// 参数校验
+		err := c.ShouldBindBodyWith(&args, binding.JSON)
+		if err != nil {
+			router.BadRequestResponse(c, common.INVALID_POST_DATA, err.Error())
+			return
+		}
+		args.Context = c.Request.Context()
+		args.OrgID = c.Request.Header.Get(common.HEADER_KEY_X_ORG_ID)
+		file, err := service.Pcap(&args)
+		if err != nil {
+			router.JsonResponse(c, nil, nil, err)
+			return
+		}
+
+		w := &responseWriter{c: c, file: file, args: &args}
+		if err := file.Write(w); err != nil {
+			if !w.started {
+				router.JsonResponse(c, nil, nil, err)
+				return
+			}
+			// the response is truncated because it has been started
+			log.Errorf("write %s file of %d packets failed: %s", file.Format, file.PacketCount(), err)
+		}
+	})
+}
+
+// responseWriter sets the headers of the file before writing the response, so that an error can still be
+// responded if the file fails before writing
+type responseWriter struct {
+	c       *gin.Context
+	file    *service.PcapFile
+	args    *model.PcapDownload
+	started bool
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if !w.started {
+		w.started = true
+		w.c.Header("Content-Type", w.file.ContentType())
+		w.c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=deepflow-%d-%d.%s", w.args.TimeStart, w.args.TimeEnd, w.file.Format))
+	}
+	return w.c.Writer.Write(p)
+}
@@ -0,0 +1,223 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	logging "github.com/op/go-logging"
+
+	"github.com/deepflowio/deepflow/server/querier/app/pcap/common"
+	"github.com/deepflowio/deepflow/server/querier/app/pcap/model"
+	querier_common "github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/config"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/client"
+)
+
+var log = logging.MustGetLogger("pcap")
+
+type flowKey struct {
+	AgentID uint16
+	FlowID  uint64
+}
+
+// flowTap is where the flow is captured
+type flowTap struct {
+	TapSide     string
+	TapPortType uint8
+	TapPort     uint32
+}
+
+// PcapFile is the packets of the flows in the l7_packet table, they are read and written in time order
+type PcapFile struct {
+	Format      string
+	args        *model.PcapDownload
+	flowIDs     []uint64
+	flows       map[flowKey]flowTap
+	packetCount int
+}
+
+// Write reads the packet batches and writes the packets as a pcap or pcapng file while reading, nothing is
+// written if the query of packet batches fails before any packet batch is read
+func (f *PcapFile) Write(w io.Writer) error {
+	merger := newPacketMerger(newPacketWriter(f.Format, w))
+	defer func() {
+		f.packetCount = merger.packetCount
+	}()
+	if len(f.flowIDs) > 0 {
+		err := queryPacketBatches(f.args, f.flowIDs, func(row []interface{}) error {
+			if len(row) < 5 {
+				return nil
+			}
+			iface := pcapInterface{}
+			iface.AgentID, _ = row[0].(uint16)
+			iface.AgentName, _ = row[1].(string)
+			flowID, _ := row[2].(uint64)
+			startTime, _ := row[3].(int64)
+			batch, _ := row[4].(string)
+			tap := f.flows[flowKey{AgentID: iface.AgentID, FlowID: flowID}]
+			iface.TapSide, iface.TapPortType, iface.TapPort = tap.TapSide, tap.TapPortType, tap.TapPort
+			return merger.add(iface, startTime, []byte(batch))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return merger.close()
+}
+
+func (f *PcapFile) ContentType() string {
+	if f.Format == common.FORMAT_PCAP {
+		return common.CONTENT_TYPE_PCAP
+	}
+	return common.CONTENT_TYPE_PCAPNG
+}
+
+// PacketCount returns the count of written packets
+func (f *PcapFile) PacketCount() int {
+	return f.packetCount
+}
+
+// Pcap checks the args and finds the flows, the packets are read when the file is written
+func Pcap(args *model.PcapDownload) (*PcapFile, error) {
+	if args.Format == "" {
+		args.Format = common.FORMAT_PCAPNG
+	}
+	if args.Format != common.FORMAT_PCAP && args.Format != common.FORMAT_PCAPNG {
+		return nil, querier_common.NewError(querier_common.INVALID_POST_DATA, fmt.Sprintf("format %s is not supported", args.Format))
+	}
+	if args.TimeEnd < args.TimeStart {
+		return nil, querier_common.NewError(querier_common.INVALID_POST_DATA, "time_end must not be before time_start")
+	}
+	if len(args.FlowIDs) == 0 && args.TagFilter == "" {
+		return nil, querier_common.NewError(querier_common.INVALID_POST_DATA, "flow_ids or tag_filter is required")
+	}
+	if len(args.FlowIDs) > common.MAX_FLOW_COUNT {
+		return nil, querier_common.NewError(querier_common.INVALID_POST_DATA, fmt.Sprintf("flow count %d exceeds %d", len(args.FlowIDs), common.MAX_FLOW_COUNT))
+	}
+
+	flows, err := queryFlows(args)
+	if err != nil {
+		return nil, err
+	}
+	file := &PcapFile{Format: args.Format, args: args, flows: flows}
+	flowIDSet := make(map[uint64]struct{}, len(args.FlowIDs)+len(flows))
+	for _, id := range args.FlowIDs {
+		flowIDSet[id] = struct{}{}
+	}
+	for key := range flows {
+		flowIDSet[key.FlowID] = struct{}{}
+	}
+	if len(flowIDSet) > common.MAX_FLOW_COUNT {
+		return nil, querier_common.NewError(querier_common.INVALID_POST_DATA, fmt.Sprintf("flow count %d exceeds %d", len(flowIDSet), common.MAX_FLOW_COUNT))
+	}
+	for id := range flowIDSet {
+		file.flowIDs = append(file.flowIDs, id)
+	}
+	return file, nil
+}
+
+func newClient(args *model.PcapDownload) *client.Client {
+	return &client.Client{
+		Host:     config.Cfg.Clickhouse.Host,
+		Port:     config.Cfg.Clickhouse.Port,
+		UserName: config.Cfg.Clickhouse.User,
+		Password: config.Cfg.Clickhouse.Password,
+		DB:       common.DATABASE_FLOW_LOG,
+		Context:  args.Context,
+	}
+}
+
+func joinFlowIDs(flowIDs []uint64) string {
+	ids := make([]string, 0, len(flowIDs))
+	for _, id := range flowIDs {
+		ids = append(ids, strconv.FormatUint(id, 10))
+	}
+	return strings.Join(ids, ",")
+}
+
+// queryFlows returns where the flows are captured, the flows are the flow_ids in args, and the flows which have
+// pcap and are matched by the tag filter in l4_flow_log
+func queryFlows(args *model.PcapDownload) (map[flowKey]flowTap, error) {
+	conditions := []string{}
+	if len(args.FlowIDs) > 0 {
+		conditions = append(conditions, fmt.Sprintf("flow_id IN (%s)", joinFlowIDs(args.FlowIDs)))
+	}
+	if args.TagFilter != "" {
+		ckEngine := &clickhouse.CHEngine{DB: common.DATABASE_FLOW_LOG, Table: common.TABLE_L4_FLOW_LOG, ORGID: args.OrgID}
+		ckEngine.Init()
+		filter, err := ckEngine.TransFilter(args.TagFilter)
+		if err != nil {
+			return nil, querier_common.NewError(querier_common.INVALID_POST_DATA, fmt.Sprintf("invalid tag_filter %s: %s", args.TagFilter, err))
+		}
+		if filter != "" {
+			conditions = append(conditions, fmt.Sprintf("(notEmpty(acl_gids) AND (%s))", filter))
+		} else {
+			conditions = append(conditions, "notEmpty(acl_gids)")
+		}
+	}
+	sql := fmt.Sprintf(
+		"SELECT %s, agent_id, observation_point, capture_nic_type, capture_nic FROM %s.`%s` "+
+			"WHERE time>=%d AND time<=%d AND (%s) LIMIT 1 BY %s, agent_id LIMIT %d",
+		common.TAG_FLOW_ID, flowLogDatabase(args.OrgID), common.TABLE_L4_FLOW_LOG, args.TimeStart, args.TimeEnd,
+		strings.Join(conditions, " OR "), common.TAG_FLOW_ID, common.MAX_FLOW_COUNT+1,
+	)
+	result, err := newClient(args).DoQuery(&client.QueryParams{Sql: sql, ORGID: args.OrgID})
+	if err != nil {
+		return nil, err
+	}
+	flows := make(map[flowKey]flowTap, len(result.Values))
+	for _, value := range result.Values {
+		row, ok := value.([]interface{})
+		if !ok || len(row) < 5 {
+			continue
+		}
+		key, tap := flowKey{}, flowTap{}
+		key.FlowID, _ = row[0].(uint64)
+		key.AgentID, _ = row[1].(uint16)
+		tap.TapSide, _ = row[2].(string)
+		tap.TapPortType, _ = row[3].(uint8)
+		tap.TapPort, _ = row[4].(uint32)
+		flows[key] = tap
+	}
+	return flows, nil
+}
+
+func flowLogDatabase(orgID string) string {
+	if orgID != querier_common.DEFAULT_ORG_ID && orgID != "" {
+		if orgIDInt, err := strconv.Atoi(orgID); err == nil {
+			return fmt.Sprintf("%04d_%s", orgIDInt, common.DATABASE_FLOW_LOG)
+		}
+	}
+	return common.DATABASE_FLOW_LOG
+}
+
+// queryPacketBatches reads the packet batches of the flows in the order of start time, f is called with every
+// row, the packet_batch column is read by the ClickHouse client directly, because it is the raw pcap data
+func queryPacketBatches(args *model.PcapDownload, flowIDs []uint64, f func(row []interface{}) error) error {
+	sql := fmt.Sprintf(
+		"SELECT agent_id, dictGet('flow_tag.vtap_map', 'name', toUInt64(agent_id)) AS agent, flow_id, "+
+			"toUnixTimestamp64Nano(start_time) AS start_time_ns, packet_batch "+
+			"FROM %s.`%s` WHERE time>=%d AND time<=%d AND flow_id IN (%s) ORDER BY start_time LIMIT %d",
+		flowLogDatabase(args.OrgID), common.TABLE_L7_PACKET, args.TimeStart, args.TimeEnd, joinFlowIDs(flowIDs), common.MAX_PACKET_BATCH_COUNT,
+	)
+	return newClient(args).DoQueryRows(&client.QueryParams{Sql: sql, ORGID: args.OrgID}, f)
+}
@@ -0,0 +1,315 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/deepflowio/deepflow/server/querier/app/pcap/common"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/packet_batch"
+)
+
+// https://datatracker.ietf.org/doc/id/draft-tuexen-opsawg-pcapng-05.html
+const (
+	PCAPNG_BLOCK_TYPE_SHB        = 0x0a0d0d0a
+	PCAPNG_BLOCK_TYPE_IDB        = 0x00000001
+	PCAPNG_BLOCK_TYPE_EPB        = 0x00000006
+	PCAPNG_BYTE_ORDER_MAGIC      = 0x1a2b3c4d
+	PCAPNG_OPTION_END            = 0
+	PCAPNG_OPTION_IF_NAME        = 2
+	PCAPNG_OPTION_IF_DESCRIPTION = 3
+	PCAPNG_OPTION_IF_TSRESOL     = 9
+	PCAPNG_TSRESOL_NANOSECOND    = 9
+)
+
+// pcapInterface is where the packets are captured, the packets of different observation points or capture
+// nics of an agent are written to different interfaces
+type pcapInterface struct {
+	AgentID     uint16
+	AgentName   string
+	TapSide     string // observation_point
+	TapPortType uint8  // capture_nic_type
+	TapPort     uint32 // capture_nic
+	LinkType    uint32
+}
+
+func (i *pcapInterface) name() string {
+	name := i.AgentName
+	if name == "" {
+		name = fmt.Sprintf("agent-%d", i.AgentID)
+	}
+	if i.TapSide != "" {
+		name += "-" + i.TapSide
+	}
+	return name
+}
+
+func (i *pcapInterface) description() string {
+	return fmt.Sprintf("agent_id: %d, agent: %s, observation_point: %s, capture_nic_type: %d, capture_nic: %d",
+		i.AgentID, i.AgentName, i.TapSide, i.TapPortType, i.TapPort)
+}
+
+type packet struct {
+	Interface *pcapInterface
+	Timestamp int64 // unit: ns
+	OrigLen   uint32
+	Data      []byte
+}
+
+type packetWriter interface {
+	// writePacket writes the packet, the packets should be written in time order
+	writePacket(p *packet) error
+	// close writes the header if no packet is written
+	close() error
+}
+
+func newPacketWriter(format string, w io.Writer) packetWriter {
+	if format == common.FORMAT_PCAP {
+		return &pcapWriter{w: w}
+	}
+	return &pcapngWriter{w: w, interfaces: make(map[pcapInterface]int)}
+}
+
+// pcapWriter writes the packets as a pcap file in nanosecond precision, the link type of the file is decided by
+// the first packet, because the pcap file has only one link type
+type pcapWriter struct {
+	w             io.Writer
+	linkType      uint32
+	headerWritten bool
+	buf           []byte
+}
+
+func (pw *pcapWriter) writeHeader(linkType uint32) error {
+	pw.linkType = linkType
+	pw.headerWritten = true
+	buf := make([]byte, 0, packet_batch.PCAP_HEADER_LEN)
+	buf = binary.LittleEndian.AppendUint32(buf, packet_batch.PCAP_MAGIC_NANOSECOND)
+	buf = binary.LittleEndian.AppendUint16(buf, packet_batch.PCAP_VERSION_MAJOR)
+	buf = binary.LittleEndian.AppendUint16(buf, packet_batch.PCAP_VERSION_MINOR)
+	buf = binary.LittleEndian.AppendUint32(buf, 0) // reserved
+	buf = binary.LittleEndian.AppendUint32(buf, 0) // reserved
+	buf = binary.LittleEndian.AppendUint32(buf, packet_batch.PCAP_SNAP_LEN)
+	buf = binary.LittleEndian.AppendUint32(buf, linkType)
+	_, err := pw.w.Write(buf)
+	return err
+}
+
+func (pw *pcapWriter) writePacket(p *packet) error {
+	if !pw.headerWritten {
+		if err := pw.writeHeader(p.Interface.LinkType); err != nil {
+			return err
+		}
+	} else if p.Interface.LinkType != pw.linkType {
+		return fmt.Errorf("pcap format does not support multiple link types %d and %d, use pcapng instead", pw.linkType, p.Interface.LinkType)
+	}
+	buf := pw.buf[:0]
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(p.Timestamp/1e9))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(p.Timestamp%1e9))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(p.Data)))
+	buf = binary.LittleEndian.AppendUint32(buf, p.OrigLen)
+	buf = append(buf, p.Data...)
+	pw.buf = buf
+	_, err := pw.w.Write(buf)
+	return err
+}
+
+func (pw *pcapWriter) close() error {
+	if !pw.headerWritten {
+		return pw.writeHeader(1) // Ethernet
+	}
+	return nil
+}
+
+func pcapngPadding(n int) int {
+	return (4 - n%4) % 4
+}
+
+func appendPcapngOption(buf []byte, code uint16, value []byte) []byte {
+	buf = binary.LittleEndian.AppendUint16(buf, code)
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(value)))
+	buf = append(buf, value...)
+	return append(buf, make([]byte, pcapngPadding(len(value)))...)
+}
+
+// appendPcapngBlock appends the block with the body, the length of body must be a multiple of 4
+func appendPcapngBlock(buf []byte, blockType uint32, body []byte) []byte {
+	length := uint32(len(body) + 12)
+	buf = binary.LittleEndian.AppendUint32(buf, blockType)
+	buf = binary.LittleEndian.AppendUint32(buf, length)
+	buf = append(buf, body...)
+	return binary.LittleEndian.AppendUint32(buf, length)
+}
+
+// pcapngWriter writes the packets as a pcapng file, the Interface Description Block of an interface is written
+// before its first packet, and the packets are written as the Enhanced Packet Blocks in nanosecond precision
+type pcapngWriter struct {
+	w             io.Writer
+	interfaces    map[pcapInterface]int // interface to its index
+	headerWritten bool
+	buf, body     []byte
+}
+
+func (pw *pcapngWriter) writeHeader() error {
+	pw.headerWritten = true
+	body := make([]byte, 0, 16)
+	body = binary.LittleEndian.AppendUint32(body, PCAPNG_BYTE_ORDER_MAGIC)
+	body = binary.LittleEndian.AppendUint16(body, 1) // major version
+	body = binary.LittleEndian.AppendUint16(body, 0) // minor version
+	body = binary.LittleEndian.AppendUint64(body, 0xffffffffffffffff)
+	_, err := pw.w.Write(appendPcapngBlock(nil, PCAPNG_BLOCK_TYPE_SHB, body))
+	return err
+}
+
+func (pw *pcapngWriter) writeInterface(iface *pcapInterface) (int, error) {
+	body := pw.body[:0]
+	body = binary.LittleEndian.AppendUint16(body, uint16(iface.LinkType))
+	body = binary.LittleEndian.AppendUint16(body, 0) // reserved
+	body = binary.LittleEndian.AppendUint32(body, packet_batch.PCAP_SNAP_LEN)
+	body = appendPcapngOption(body, PCAPNG_OPTION_IF_NAME, []byte(iface.name()))
+	body = appendPcapngOption(body, PCAPNG_OPTION_IF_DESCRIPTION, []byte(iface.description()))
+	body = appendPcapngOption(body, PCAPNG_OPTION_IF_TSRESOL, []byte{PCAPNG_TSRESOL_NANOSECOND})
+	body = appendPcapngOption(body, PCAPNG_OPTION_END, nil)
+	pw.body = body
+	pw.buf = appendPcapngBlock(pw.buf[:0], PCAPNG_BLOCK_TYPE_IDB, body)
+	if _, err := pw.w.Write(pw.buf); err != nil {
+		return 0, err
+	}
+	index := len(pw.interfaces)
+	pw.interfaces[*iface] = index
+	return index, nil
+}
+
+func (pw *pcapngWriter) writePacket(p *packet) error {
+	if !pw.headerWritten {
+		if err := pw.writeHeader(); err != nil {
+			return err
+		}
+	}
+	index, ok := pw.interfaces[*p.Interface]
+	if !ok {
+		var err error
+		if index, err = pw.writeInterface(p.Interface); err != nil {
+			return err
+		}
+	}
+	body := pw.body[:0]
+	body = binary.LittleEndian.AppendUint32(body, uint32(index))
+	body = binary.LittleEndian.AppendUint32(body, uint32(uint64(p.Timestamp)>>32))
+	body = binary.LittleEndian.AppendUint32(body, uint32(p.Timestamp))
+	body = binary.LittleEndian.AppendUint32(body, uint32(len(p.Data)))
+	body = binary.LittleEndian.AppendUint32(body, p.OrigLen)
+	body = append(body, p.Data...)
+	body = append(body, make([]byte, pcapngPadding(len(p.Data)))...)
+	pw.body = body
+	pw.buf = appendPcapngBlock(pw.buf[:0], PCAPNG_BLOCK_TYPE_EPB, body)
+	_, err := pw.w.Write(pw.buf)
+	return err
+}
+
+func (pw *pcapngWriter) close() error {
+	if !pw.headerWritten {
+		return pw.writeHeader()
+	}
+	return nil
+}
+
+// batchHeap is the min-heap of the packet batches by the timestamp of their next packet
+type batchHeap [][]packet
+
+func (h batchHeap) Len() int            { return len(h) }
+func (h batchHeap) Less(i, j int) bool  { return h[i][0].Timestamp < h[j][0].Timestamp }
+func (h batchHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *batchHeap) Push(x interface{}) { *h = append(*h, x.([]packet)) }
+func (h *batchHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// packetMerger merges the packets of the batches in time order, the batches should be added in the order of their
+// start time. When a batch is added, the packets earlier than its start time can not be preceded by the packets of
+// the later batches, so they are written, only the packets of the overlapped batches are kept in memory.
+type packetMerger struct {
+	writer      packetWriter
+	batches     batchHeap
+	packetCount int
+}
+
+func newPacketMerger(writer packetWriter) *packetMerger {
+	return &packetMerger{writer: writer}
+}
+
+// add parses the packet batch, startTime should not be greater than the timestamps of its packets
+func (m *packetMerger) add(iface pcapInterface, startTime int64, batch []byte) error {
+	var packets []packet
+	interfaces := make(map[uint32]*pcapInterface, 1)
+	err := packet_batch.ParsePcapBatch(batch, func(linkType uint32, timestamp int64, origLen uint32, data []byte) {
+		i, ok := interfaces[linkType]
+		if !ok {
+			i = &pcapInterface{}
+			*i = iface
+			i.LinkType = linkType
+			interfaces[linkType] = i
+		}
+		packets = append(packets, packet{Interface: i, Timestamp: timestamp, OrigLen: origLen, Data: data})
+	})
+	if err != nil {
+		// skip the broken packet batch, the packets parsed before the error are kept
+		log.Warningf("parse packet batch of agent %d failed: %s", iface.AgentID, err)
+	}
+	sort.SliceStable(packets, func(i, j int) bool {
+		return packets[i].Timestamp < packets[j].Timestamp
+	})
+	if err := m.writeBefore(startTime); err != nil {
+		return err
+	}
+	if len(packets) > 0 {
+		heap.Push(&m.batches, packets)
+	}
+	return nil
+}
+
+// writeBefore writes the packets whose timestamp is less than the timestamp
+func (m *packetMerger) writeBefore(timestamp int64) error {
+	for len(m.batches) > 0 && m.batches[0][0].Timestamp < timestamp {
+		if err := m.writer.writePacket(&m.batches[0][0]); err != nil {
+			return err
+		}
+		m.packetCount++
+		if m.batches[0] = m.batches[0][1:]; len(m.batches[0]) == 0 {
+			heap.Pop(&m.batches)
+		} else {
+			heap.Fix(&m.batches, 0)
+		}
+	}
+	return nil
+}
+
+// close writes all the remaining packets
+func (m *packetMerger) close() error {
+	if err := m.writeBefore(math.MaxInt64); err != nil {
+		return err
+	}
+	return m.writer.close()
+}
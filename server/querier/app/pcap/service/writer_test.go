@@ -0,0 +1,238 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/packet_batch"
+)
+
+// newPacketBatch returns the packet batch in the format which is stored by the ingester
+func newPacketBatch(magic uint32, linkType uint32, timestamps []time.Time, data [][]byte) []byte {
+	batch := binary.LittleEndian.AppendUint32(nil, magic)
+	batch = binary.LittleEndian.AppendUint16(batch, packet_batch.PCAP_VERSION_MAJOR)
+	batch = binary.LittleEndian.AppendUint16(batch, packet_batch.PCAP_VERSION_MINOR)
+	batch = binary.LittleEndian.AppendUint32(batch, 0)
+	batch = binary.LittleEndian.AppendUint32(batch, 0)
+	batch = binary.LittleEndian.AppendUint32(batch, packet_batch.PCAP_SNAP_LEN)
+	batch = binary.LittleEndian.AppendUint32(batch, linkType)
+	for i, ts := range timestamps {
+		batch = binary.LittleEndian.AppendUint32(batch, uint32(ts.Unix()))
+		if magic == packet_batch.PCAP_MAGIC_NANOSECOND {
+			batch = binary.LittleEndian.AppendUint32(batch, uint32(ts.Nanosecond()))
+		} else {
+			batch = binary.LittleEndian.AppendUint32(batch, uint32(ts.Nanosecond()/1e3))
+		}
+		batch = binary.LittleEndian.AppendUint32(batch, uint32(len(data[i])))
+		batch = binary.LittleEndian.AppendUint32(batch, uint32(len(data[i])+10))
+		batch = append(batch, data[i]...)
+	}
+	return batch
+}
+
+type testBatch struct {
+	iface     pcapInterface
+	startTime time.Time
+	batch     []byte
+}
+
+func writeTestBatches(format string, batches ...testBatch) (*bytes.Buffer, error) {
+	buf := &bytes.Buffer{}
+	m := newPacketMerger(newPacketWriter(format, buf))
+	for _, b := range batches {
+		if err := m.add(b.iface, b.startTime.UnixNano(), b.batch); err != nil {
+			return buf, err
+		}
+	}
+	return buf, m.close()
+}
+
+func TestParsePacketBatch(t *testing.T) {
+	ts := time.Unix(1700000000, 123456789)
+	batch := newPacketBatch(packet_batch.PCAP_MAGIC_MICROSECOND, 1, []time.Time{ts, ts.Add(time.Second)}, [][]byte{{1, 2, 3}, {4, 5}})
+	var timestamps []int64
+	var packets [][]byte
+	err := packet_batch.ParsePcapBatch(batch, func(linkType uint32, timestamp int64, origLen uint32, data []byte) {
+		if linkType != 1 || origLen != uint32(len(data)+10) {
+			t.Errorf("unexpected link type %d or length %d", linkType, origLen)
+		}
+		timestamps = append(timestamps, timestamp)
+		packets = append(packets, data)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packets) != 2 || !bytes.Equal(packets[1], []byte{4, 5}) {
+		t.Fatalf("unexpected packets %v", packets)
+	}
+	// microsecond precision
+	if timestamps[0] != ts.UnixNano()/1e3*1e3 {
+		t.Errorf("expected timestamp %d, got %d", ts.UnixNano()/1e3*1e3, timestamps[0])
+	}
+
+	if err := packet_batch.ParsePcapBatch(batch[:len(batch)-1], func(uint32, int64, uint32, []byte) {}); err == nil {
+		t.Error("expected error of truncated packet batch")
+	}
+	if err := packet_batch.ParsePcapBatch(make([]byte, packet_batch.PCAP_HEADER_LEN), func(uint32, int64, uint32, []byte) {}); err == nil {
+		t.Error("expected error of invalid magic number")
+	}
+}
+
+func TestWritePcapng(t *testing.T) {
+	ts := time.Unix(1700000000, 123456789)
+	buf, err := writeTestBatches("pcapng",
+		testBatch{
+			pcapInterface{AgentID: 1, AgentName: "agent", TapSide: "c"}, ts,
+			newPacketBatch(packet_batch.PCAP_MAGIC_NANOSECOND, uint32(layers.LinkTypeEthernet), []time.Time{ts}, [][]byte{{1, 2, 3, 4, 5}}),
+		},
+		testBatch{
+			pcapInterface{AgentID: 1, AgentName: "agent", TapSide: "s"}, ts,
+			newPacketBatch(packet_batch.PCAP_MAGIC_NANOSECOND, uint32(layers.LinkTypeEthernet), []time.Time{ts.Add(time.Microsecond)}, [][]byte{{8}}),
+		},
+		testBatch{
+			pcapInterface{AgentID: 2, AgentName: "agent"}, ts.Add(time.Millisecond),
+			newPacketBatch(packet_batch.PCAP_MAGIC_MICROSECOND, uint32(layers.LinkTypeRaw), []time.Time{ts.Add(time.Millisecond)}, [][]byte{{6, 7}}),
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := pcapgo.NewNgReader(buf, pcapgo.NgReaderOptions{WantMixedLinkType: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		data      []byte
+		timestamp time.Time
+		linkType  layers.LinkType
+		name      string
+	}{
+		{[]byte{1, 2, 3, 4, 5}, ts, layers.LinkTypeEthernet, "agent-c"},
+		{[]byte{8}, ts.Add(time.Microsecond), layers.LinkTypeEthernet, "agent-s"},
+		{[]byte{6, 7}, time.Unix(1700000000, 124456000), layers.LinkTypeRaw, "agent"},
+	}
+	for i, e := range expected {
+		data, ci, err := r.ReadPacketData()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, e.data) || !ci.Timestamp.Equal(e.timestamp) || ci.Length != len(e.data)+10 {
+			t.Errorf("packet %d: unexpected data %v, timestamp %s, length %d", i, data, ci.Timestamp, ci.Length)
+		}
+		iface, err := r.Interface(ci.InterfaceIndex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if iface.LinkType != e.linkType || iface.Name != e.name {
+			t.Errorf("packet %d: unexpected interface %+v", i, iface)
+		}
+	}
+	if r.NInterfaces() != 3 {
+		t.Errorf("expected 3 interfaces, got %d", r.NInterfaces())
+	}
+}
+
+func TestWritePcap(t *testing.T) {
+	ts := time.Unix(1700000000, 123456789)
+	buf, err := writeTestBatches("pcap", testBatch{
+		pcapInterface{AgentID: 1}, ts,
+		newPacketBatch(packet_batch.PCAP_MAGIC_NANOSECOND, uint32(layers.LinkTypeEthernet), []time.Time{ts, ts.Add(time.Second)}, [][]byte{{1, 2, 3}, {4}}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := pcapgo.NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.LinkType() != layers.LinkTypeEthernet {
+		t.Errorf("unexpected link type %s", r.LinkType())
+	}
+	for i, e := range [][]byte{{1, 2, 3}, {4}} {
+		data, ci, err := r.ReadPacketData()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, e) || !ci.Timestamp.Equal(ts.Add(time.Duration(i)*time.Second)) {
+			t.Errorf("packet %d: unexpected data %v, timestamp %s", i, data, ci.Timestamp)
+		}
+	}
+
+	_, err = writeTestBatches("pcap",
+		testBatch{pcapInterface{AgentID: 1}, ts, newPacketBatch(packet_batch.PCAP_MAGIC_NANOSECOND, uint32(layers.LinkTypeEthernet), []time.Time{ts}, [][]byte{{1}})},
+		testBatch{pcapInterface{AgentID: 2}, ts, newPacketBatch(packet_batch.PCAP_MAGIC_NANOSECOND, uint32(layers.LinkTypeRaw), []time.Time{ts}, [][]byte{{2}})},
+	)
+	if err == nil {
+		t.Error("expected error of multiple link types")
+	}
+
+	// the header is written even if there is no packet
+	buf, err = writeTestBatches("pcap")
+	if err != nil || buf.Len() != packet_batch.PCAP_HEADER_LEN {
+		t.Errorf("unexpected empty pcap file of %d bytes, error: %v", buf.Len(), err)
+	}
+}
+
+func TestPacketMerger(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	at := func(ms ...int) []time.Time {
+		result := make([]time.Time, 0, len(ms))
+		for _, m := range ms {
+			result = append(result, ts.Add(time.Duration(m)*time.Millisecond))
+		}
+		return result
+	}
+	buf := &bytes.Buffer{}
+	m := newPacketMerger(newPacketWriter("pcap", buf))
+	iface := pcapInterface{AgentID: 1}
+	// the batches overlap, and are added in the order of start time
+	m.add(iface, at(0)[0].UnixNano(), newPacketBatch(packet_batch.PCAP_MAGIC_NANOSECOND, 1, at(0, 2, 4), [][]byte{{0}, {2}, {4}}))
+	m.add(iface, at(1)[0].UnixNano(), newPacketBatch(packet_batch.PCAP_MAGIC_NANOSECOND, 1, at(1, 3), [][]byte{{1}, {3}}))
+	if m.packetCount != 1 {
+		t.Errorf("expected 1 packet written before the start time of the second batch, got %d", m.packetCount)
+	}
+	m.add(iface, at(5)[0].UnixNano(), newPacketBatch(packet_batch.PCAP_MAGIC_NANOSECOND, 1, at(5), [][]byte{{5}}))
+	if m.packetCount != 5 || len(m.batches) != 1 {
+		t.Errorf("expected 5 packets written and 1 batch kept, got %d and %d", m.packetCount, len(m.batches))
+	}
+	if err := m.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := pcapgo.NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 6; i++ {
+		data, ci, err := r.ReadPacketData()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) != 1 || data[0] != byte(i) || !ci.Timestamp.Equal(at(i)[0]) {
+			t.Errorf("packet %d: unexpected data %v, timestamp %s", i, data, ci.Timestamp)
+		}
+	}
+}
@@ -28,6 +28,7 @@ type Prometheus struct {
 	ThanosReplicaLabels     []string        `yaml:"thanos-replica-labels"`
 	OperatorOffloading      bool            `default:"false" yaml:"operator-offloading"`
 	Cache                   PrometheusCache `yaml:"cache"`
+	Rules                   PrometheusRules `yaml:"rules"`
 }
 
 type PrometheusCache struct {
@@ -39,3 +40,15 @@ type PrometheusCache struct {
 	CacheCleanInterval int    `default:"3600" yaml:"cache-clean-interval"` // clean interval for cache, unit: s, default: 1h
 	CacheAllowTimeGap  int    `default:"1" yaml:"cache-allow-time-gap"`    // when query end time - cache end time <= allow gap: not update cache, unit: s, default: 1s
 }
+
+// PrometheusRules are only evaluated by the elected leader of the controllers in the region
+type PrometheusRules struct {
+	Enabled            bool     `default:"false" yaml:"enabled"`
+	RuleFiles          []string `yaml:"rule-files"`                       // rule files in Prometheus format, glob patterns are supported
+	EvaluationInterval int      `default:"60" yaml:"evaluation-interval"` // default evaluation interval of rule groups, unit: s
+	ResendDelay        int      `default:"60" yaml:"resend-delay"`        // minimum interval to resend a firing alert, unit: s
+	OrgID              string   `default:"1" yaml:"org-id"`               // organization that the rules are evaluated in
+	RemoteWriteURL     string   `yaml:"remote-write-url"`                 // remote write endpoint for the results of recording rules, required if there are recording rules, e.g.: http://deepflow-agent/api/v1/prometheus
+	AlertmanagerURLs   []string `yaml:"alertmanager-urls"`                // Alertmanager compatible webhooks, e.g.: http://alertmanager:9093/api/v2/alerts
+	ExternalURL        string   `yaml:"external-url"`                     // prefix of the generatorURL in the alerts
+}
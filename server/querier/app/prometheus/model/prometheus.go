@@ -20,6 +20,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/promql/parser"
 )
 
@@ -51,11 +52,119 @@ type PromQueryResponse struct {
 	Stats     []PromQueryStats `json:"stats,omitempty"`
 }
 
+// PromExemplarData is the exemplars of a series in the response of '/api/v1/query_exemplars'
+type PromExemplarData struct {
+	SeriesLabels map[string]string `json:"seriesLabels"`
+	Exemplars    []PromExemplar    `json:"exemplars"`
+}
+
+type PromExemplar struct {
+	Labels    map[string]string `json:"labels"`
+	Value     string            `json:"value"`
+	Timestamp float64           `json:"timestamp"`
+}
+
+// PromRuleDiscovery is the data of '/api/v1/rules'
+type PromRuleDiscovery struct {
+	RuleGroups []*PromRuleGroup `json:"groups"`
+}
+
+type PromRuleGroup struct {
+	Name string `json:"name"`
+	File string `json:"file"`
+	// Rules is the list of PromAlertingRule and PromRecordingRule
+	Rules          []interface{} `json:"rules"`
+	Interval       float64       `json:"interval"`
+	Limit          int           `json:"limit"`
+	EvaluationTime float64       `json:"evaluationTime"`
+	LastEvaluation time.Time     `json:"lastEvaluation"`
+}
+
+type PromAlertingRule struct {
+	State          string        `json:"state"`
+	Name           string        `json:"name"`
+	Query          string        `json:"query"`
+	Duration       float64       `json:"duration"`
+	Labels         labels.Labels `json:"labels"`
+	Annotations    labels.Labels `json:"annotations"`
+	Alerts         []*PromAlert  `json:"alerts"`
+	Health         string        `json:"health"`
+	LastError      string        `json:"lastError,omitempty"`
+	EvaluationTime float64       `json:"evaluationTime"`
+	LastEvaluation time.Time     `json:"lastEvaluation"`
+	Type           string        `json:"type"`
+}
+
+type PromRecordingRule struct {
+	Name           string        `json:"name"`
+	Query          string        `json:"query"`
+	Labels         labels.Labels `json:"labels,omitempty"`
+	Health         string        `json:"health"`
+	LastError      string        `json:"lastError,omitempty"`
+	EvaluationTime float64       `json:"evaluationTime"`
+	LastEvaluation time.Time     `json:"lastEvaluation"`
+	Type           string        `json:"type"`
+}
+
+// PromAlertDiscovery is the data of '/api/v1/alerts'
+type PromAlertDiscovery struct {
+	Alerts []*PromAlert `json:"alerts"`
+}
+
+type PromAlert struct {
+	Labels      labels.Labels `json:"labels"`
+	Annotations labels.Labels `json:"annotations"`
+	State       string        `json:"state"`
+	ActiveAt    *time.Time    `json:"activeAt,omitempty"`
+	Value       string        `json:"value"`
+}
+
+// PromMetricMetadata is the metadata of a metric in the response of '/api/v1/metadata'
+type PromMetricMetadata struct {
+	Type string `json:"type"`
+	Help string `json:"help"`
+	Unit string `json:"unit"`
+}
+
+// PromBuildInfo is the data of '/api/v1/status/buildinfo'
+type PromBuildInfo struct {
+	Version   string `json:"version"`
+	Revision  string `json:"revision"`
+	Branch    string `json:"branch"`
+	BuildUser string `json:"buildUser"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+}
+
+// PromTargetDiscovery is the data of '/api/v1/targets'
+type PromTargetDiscovery struct {
+	ActiveTargets  []*PromTarget        `json:"activeTargets"`
+	DroppedTargets []*PromDroppedTarget `json:"droppedTargets"`
+}
+
+type PromTarget struct {
+	DiscoveredLabels   map[string]string `json:"discoveredLabels"`
+	Labels             map[string]string `json:"labels"`
+	ScrapePool         string            `json:"scrapePool"`
+	ScrapeURL          string            `json:"scrapeUrl"`
+	GlobalURL          string            `json:"globalUrl"`
+	LastError          string            `json:"lastError"`
+	LastScrape         time.Time         `json:"lastScrape"`
+	LastScrapeDuration float64           `json:"lastScrapeDuration"`
+	Health             string            `json:"health"`
+}
+
+type PromDroppedTarget struct {
+	DiscoveredLabels map[string]string `json:"discoveredLabels"`
+}
+
 type PromMetaParams struct {
 	StartTime   string
 	EndTime     string
 	LabelName   string
 	OrgID       string
+	Matchers    []string
+	Limit       int
 	BlockTeamID []string
 	Context     context.Context
 }
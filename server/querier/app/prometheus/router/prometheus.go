@@ -30,6 +30,7 @@ import (
 	"github.com/prometheus/prometheus/promql"
 	"github.com/prometheus/prometheus/promql/parser"
 
+	routercommon "github.com/deepflowio/deepflow/server/controller/http/router/common"
 	"github.com/deepflowio/deepflow/server/querier/app/prometheus/model"
 	"github.com/deepflowio/deepflow/server/querier/app/prometheus/service"
 	"github.com/deepflowio/deepflow/server/querier/common"
@@ -160,10 +161,18 @@ func promTagValuesReader(svc *service.PrometheusService) gin.HandlerFunc {
 			c.JSON(code, obj)
 			return
 		}
+		var limit int
+		err = setRouterArgs(c.Request.FormValue("limit"), &limit, 0, strconv.Atoi)
+		if err != nil {
+			c.JSON(400, &model.PromQueryResponse{Error: err.Error(), Status: _STATUS_FAIL})
+			return
+		}
 		args := model.PromMetaParams{
 			LabelName:   c.Param("labelName"),
 			StartTime:   c.Request.FormValue("start"),
 			EndTime:     c.Request.FormValue("end"),
+			Matchers:    c.Request.Form["match[]"],
+			Limit:       limit,
 			Context:     c.Request.Context(),
 			BlockTeamID: block_team_ids,
 			OrgID:       c.Request.Header.Get(common.HEADER_KEY_X_ORG_ID),
@@ -177,6 +186,148 @@ func promTagValuesReader(svc *service.PrometheusService) gin.HandlerFunc {
 	})
 }
 
+func promExemplarsReader(svc *service.PrometheusService) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		block_team_id := c.Request.FormValue("block-team-id")
+		block_team_ids, err := splitStrings(block_team_id)
+		if err != nil {
+			code, obj := handleError(err)
+			c.JSON(code, obj)
+			return
+		}
+		args := model.PromQueryParams{
+			Promql:      c.Request.FormValue("query"),
+			StartTime:   c.Request.FormValue("start"),
+			EndTime:     c.Request.FormValue("end"),
+			Context:     c.Request.Context(),
+			BlockTeamID: block_team_ids,
+			OrgID:       c.Request.Header.Get(common.HEADER_KEY_X_ORG_ID),
+		}
+		result, err := svc.PromExemplarsQueryService(&args, c.Request.Context())
+		if err != nil {
+			c.JSON(500, &model.PromQueryResponse{Error: err.Error(), Status: _STATUS_FAIL})
+			return
+		}
+		c.JSON(200, result)
+	})
+}
+
+// API Spec: https://prometheus.io/docs/prometheus/latest/querying/api/#getting-label-names
+func promLabelNamesReader(svc *service.PrometheusService) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		args := model.PromQueryParams{
+			StartTime: c.Request.FormValue("start"),
+			EndTime:   c.Request.FormValue("end"),
+			Matchers:  c.Request.Form["match[]"],
+			Context:   c.Request.Context(),
+			OrgID:     c.Request.Header.Get(common.HEADER_KEY_X_ORG_ID),
+		}
+		result, err := svc.PromLabelNamesService(&args, c.Request.Context())
+		if err != nil {
+			c.JSON(500, &model.PromQueryResponse{Error: err.Error(), Status: _STATUS_FAIL})
+			return
+		}
+		c.JSON(200, result)
+	})
+}
+
+// API Spec: https://prometheus.io/docs/prometheus/latest/querying/api/#querying-metric-metadata
+func promMetadataReader(svc *service.PrometheusService) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		var limit int
+		err := setRouterArgs(c.Request.FormValue("limit"), &limit, -1, strconv.Atoi)
+		if err != nil {
+			c.JSON(400, &model.PromQueryResponse{Error: err.Error(), Status: _STATUS_FAIL})
+			return
+		}
+		orgID := c.Request.Header.Get(common.HEADER_KEY_X_ORG_ID)
+		result, err := svc.PromMetadataService(orgID, c.Request.FormValue("metric"), limit)
+		if err != nil {
+			c.JSON(500, &model.PromQueryResponse{Error: err.Error(), Status: _STATUS_FAIL})
+			return
+		}
+		c.JSON(200, result)
+	})
+}
+
+// API Spec: https://prometheus.io/docs/prometheus/latest/querying/api/#formatting-query-expressions
+func promFormatQuery(svc *service.PrometheusService) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		result, err := svc.PromFormatQueryService(c.Request.FormValue("query"))
+		if err != nil {
+			c.JSON(400, &model.PromQueryResponse{Error: err.Error(), Status: _STATUS_FAIL})
+			return
+		}
+		c.JSON(200, result)
+	})
+}
+
+// API Spec: https://prometheus.io/docs/prometheus/latest/querying/api/#build-information
+func promBuildInfo(svc *service.PrometheusService) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		c.JSON(200, svc.PromBuildInfoService())
+	})
+}
+
+// API Spec: https://prometheus.io/docs/prometheus/latest/querying/api/#targets
+func promTargetsReader(svc *service.PrometheusService) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		orgID := c.Request.Header.Get(common.HEADER_KEY_X_ORG_ID)
+		result, err := svc.PromTargetsService(c.Request.Context(), orgID, c.Request.FormValue("state"))
+		if err != nil {
+			c.JSON(500, &model.PromQueryResponse{Error: err.Error(), Status: _STATUS_FAIL})
+			return
+		}
+		c.JSON(200, result)
+	})
+}
+
+// forwardToRulesLeader forwards the request to the leader which evaluates the rules, returns false if the rules
+// are evaluated by this server
+func forwardToRulesLeader(c *gin.Context, svc *service.PrometheusService) bool {
+	leader, err := svc.PromRulesLeader()
+	if err != nil {
+		c.JSON(500, &model.PromQueryResponse{Error: err.Error(), Status: _STATUS_FAIL})
+		return true
+	}
+	if leader == "" {
+		return false
+	}
+	routercommon.ForwardMasterController(c, leader, config.Cfg.ListenPort)
+	return true
+}
+
+// API Spec: https://prometheus.io/docs/prometheus/latest/querying/api/#rules
+func promRulesReader(svc *service.PrometheusService) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		if forwardToRulesLeader(c, svc) {
+			return
+		}
+		result, err := svc.PromRulesService(c.Request.FormValue("type"))
+		if err != nil {
+			c.JSON(400, &model.PromQueryResponse{Error: err.Error(), Status: _STATUS_FAIL})
+			return
+		}
+		c.JSON(200, result)
+	})
+}
+
+// API Spec: https://prometheus.io/docs/prometheus/latest/querying/api/#alerts
+func promAlertsReader(svc *service.PrometheusService) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		if forwardToRulesLeader(c, svc) {
+			return
+		}
+		result, err := svc.PromAlertsService()
+		if err != nil {
+			code, obj := handleError(err)
+			c.JSON(code, obj)
+			return
+		}
+		c.JSON(200, result)
+	})
+}
+
 func promSeriesReader(svc *service.PrometheusService) gin.HandlerFunc {
 	return gin.HandlerFunc(func(c *gin.Context) {
 		args := model.PromQueryParams{
@@ -18,17 +18,25 @@ package router
 
 import (
 	"github.com/gin-gonic/gin"
+	logging "github.com/op/go-logging"
 
 	"github.com/deepflowio/deepflow/server/querier/app/prometheus/router/packet_adapter"
 	"github.com/deepflowio/deepflow/server/querier/app/prometheus/service"
 	"github.com/deepflowio/deepflow/server/querier/config"
 )
 
+var log = logging.MustGetLogger("prometheus.router")
+
 func PrometheusRouter(e *gin.Engine) {
 	// only one instance during server lifetime
 	prometheusService := service.NewPrometheusService()
 	// Both SetRate and Acquire are expanded by 1000 times, making it suitable for small QPS scenarios.
 	prometheusService.QPSLeakyBucket.Init(uint64(config.Cfg.Prometheus.QPSLimit * 1000))
+	if config.Cfg.Prometheus.Rules.Enabled {
+		if err := prometheusService.StartRuleManager(); err != nil {
+			log.Errorf("start prometheus rule manager failed: %v", err)
+		}
+	}
 
 	// api router for prometheus
 	e.POST("/api/v1/prom/read", Limiter(prometheusService.QPSLeakyBucket), promReader(prometheusService))
@@ -44,6 +52,12 @@ func PrometheusRouter(e *gin.Engine) {
 		promGroup.GET("/api/v1/series", promSeriesReader(prometheusService))
 		promGroup.POST("/api/v1/series", promSeriesReader(prometheusService))
 		promGroup.GET("/api/v1/label/:labelName/values", promTagValuesReader(prometheusService))
+		promGroup.GET("/api/v1/labels", promLabelNamesReader(prometheusService))
+		promGroup.POST("/api/v1/labels", promLabelNamesReader(prometheusService))
+		promGroup.GET("/api/v1/metadata", promMetadataReader(prometheusService))
+		promGroup.GET("/api/v1/targets", promTargetsReader(prometheusService))
+		promGroup.GET("/api/v1/query_exemplars", promExemplarsReader(prometheusService))
+		promGroup.POST("/api/v1/query_exemplars", promExemplarsReader(prometheusService))
 
 		// not use "/prom/api/v1/adapter/:name", suitable for map[rouer key]counter in statsd
 		for _, v := range []string{"label", "query_range", "query", "series"} {
@@ -53,6 +67,11 @@ func PrometheusRouter(e *gin.Engine) {
 	}
 
 	// not using rate-limit, cause it's low-frequency of request-calling
+	e.GET("/prom/api/v1/rules", promRulesReader(prometheusService))
+	e.GET("/prom/api/v1/alerts", promAlertsReader(prometheusService))
+	e.GET("/prom/api/v1/format_query", promFormatQuery(prometheusService))
+	e.POST("/prom/api/v1/format_query", promFormatQuery(prometheusService))
+	e.GET("/prom/api/v1/status/buildinfo", promBuildInfo(prometheusService))
 	e.GET("/prom/api/v1/analysis", promQLAnalysis(prometheusService))
 	e.GET("/prom/api/v1/parse", promQLParse(prometheusService))
 	e.GET("/prom/api/v1/addfilter", promQLAddFilters(prometheusService))
@@ -391,13 +391,18 @@ func extractSubFunctionFromPath(p []parser.Node) []functionCall {
 			// Call: rate/delta/increase/x_over_time...
 			f := functionCall{Name: n.Func.Name, Range: evalRange}
 			if n.Args != nil {
-				// p for quantile_over_time
+				// p for quantile_over_time/predict_linear/histogram_quantile
 				for _, subE := range n.Args {
-					val := extractValFromSubNode(subE)
-					if val > 0 {
-						f.Param = val
-						break
+					if subE.Type() != parser.ValueTypeScalar {
+						continue
 					}
+					val, ok := extractValFromSubNode(subE)
+					if !ok {
+						// the scalar is calculated by another expression, we can not offload this function
+						return funcs
+					}
+					f.Param = val
+					break
 				}
 			}
 			evalRange = 0
@@ -429,16 +434,22 @@ func extractSubFunctionFromPath(p []parser.Node) []functionCall {
 	return funcs
 }
 
-func extractValFromSubNode(expr parser.Expr) float64 {
+func extractValFromSubNode(expr parser.Expr) (float64, bool) {
 	switch n := expr.(type) {
 	case *parser.UnaryExpr:
+		val, ok := extractValFromSubNode(n.Expr)
+		if n.Op == parser.SUB {
+			val = -val
+		}
+		return val, ok
+	case *parser.ParenExpr:
 		return extractValFromSubNode(n.Expr)
 	case *parser.StepInvariantExpr:
 		return extractValFromSubNode(n.Expr)
 	case *parser.NumberLiteral:
-		return n.Val
+		return n.Val, true
 	default:
-		return 0
+		return 0, false
 	}
 }
 
@@ -1042,6 +1042,10 @@ func (p *prometheusReader) parseQueryRequestToSQL(ctx context.Context, queryReq
 	if queryReq == nil {
 		return ""
 	}
+	// the native histograms are expanded by promReaderExecute
+	if _, _, ok := nativeHistogramMetric(p.orgID, queryReq.GetMetric()); ok {
+		return ""
+	}
 	// get funcs
 	funcs := queryReq.GetFunc()
 	f0, f1 := "", ""
@@ -1130,6 +1134,14 @@ func (p *prometheusReader) parseQueryRequestToSQL(ctx context.Context, queryReq
 		}
 	}
 
+	if len(p.blockTeamID) > 0 {
+		filters = append(filters, fmt.Sprintf("team_id not in (%s)", strings.Join(p.blockTeamID, ",")))
+	}
+
+	if pushdown := QueryPushdownCall[f0]; pushdown != nil {
+		return p.parsePushdownQueryToSQL(ctx, queryReq, queryType, pushdown, filters)
+	}
+
 	// order
 	orderBy := []string{fmt.Sprintf("%s desc", PROMETHEUS_TIME_COLUMNS)}
 
@@ -1209,14 +1221,99 @@ func (p *prometheusReader) parseQueryRequestToSQL(ctx context.Context, queryReq
 		// only when group by any tag, add `time` group
 		groupBy = append(groupBy, PROMETHEUS_TIME_COLUMNS)
 	}
-	if len(p.blockTeamID) > 0 {
-		filters = append(filters, fmt.Sprintf("team_id not in (%s)", strings.Join(p.blockTeamID, ",")))
-	}
 
 	sql := parseToQuerierSQL(ctx, chCommon.DB_NAME_PROMETHEUS, queryReq.GetMetric(), selection, filters, groupBy, orderBy)
 	return sql
 }
 
+// returns nil if the function can not be pushed down
+func (p *prometheusReader) newPushdownQuery(queryReq model.QueryRequest, queryType model.QueryType, pushdown *pushdownFunc) *pushdownQuery {
+	f0 := queryReq.GetFunc()[0]
+	if queryReq.GetSubStep(f0) > 0 {
+		// evaluated at the steps of subquery
+		return nil
+	}
+	q := &pushdownQuery{param: queryReq.GetFuncParam(f0), end: queryReq.GetEnd()}
+	if pushdown.matrix {
+		q.window = queryReq.GetRange(f0)
+		if q.window <= 0 {
+			// the matrix is a subquery
+			return nil
+		}
+	} else {
+		q.window = p.lookbackDelta.Milliseconds()
+		if q.window <= 0 {
+			q.window = defaultLookbackDelta.Milliseconds()
+		}
+	}
+	if queryType == model.Range {
+		// the results are returned as samples in seconds, at most one evaluation timestamp in a second
+		q.start, q.step = queryReq.GetStart()+q.window, queryReq.GetStep()
+		if q.step < time.Second.Milliseconds() {
+			return nil
+		}
+	} else {
+		q.start = q.end
+	}
+	return q
+}
+
+// the samples are selected by querier sql, and the function is calculated by the outer sql, see `QueryPushdownCall`
+func (p *prometheusReader) parsePushdownQueryToSQL(ctx context.Context, queryReq model.QueryRequest, queryType model.QueryType, pushdown *pushdownFunc, filters []string) string {
+	q := p.newPushdownQuery(queryReq, queryType, pushdown)
+	if q == nil {
+		return ""
+	}
+	funcs := queryReq.GetFunc()
+	f0 := funcs[0]
+
+	// the aggregation only keeps the grouping tags, other functions keep series, add tags for the aggregation in the next function
+	groupings := queryReq.GetGrouping(f0)
+	if pushdown.calculate == nil || f0 == FUNCTION_BOTTOMK || f0 == "histogram_quantile" {
+		if len(funcs) > 1 {
+			groupings = append(groupings[:len(groupings):len(groupings)], queryReq.GetGrouping(funcs[1])...)
+		}
+	}
+	expectedQueryTags := make(map[string]string, len(groupings))
+	for _, tag := range groupings {
+		tagName, tagAlias, isDeepFlowTag := p.parsePromQLTag(prefixDeepFlow, chCommon.DB_NAME_PROMETHEUS, tag)
+		if !isDeepFlowTag {
+			if common.IsValueInSliceString(tag, queryReq.GetGrouping(f0)) && !common.IsValueInSliceString(tag, q.labels) {
+				q.labels = append(q.labels, tag)
+			}
+			continue
+		}
+		column := tagName
+		if tagAlias != "" {
+			column = tagAlias
+		}
+		if _, ok := expectedQueryTags[tagName]; !ok {
+			q.tags = append(q.tags, column)
+			if common.IsValueInSliceString(tag, queryReq.GetGrouping(f0)) {
+				q.groupTags = append(q.groupTags, column)
+			}
+		}
+		expectedQueryTags[tagName] = tagAlias
+	}
+
+	outerSql := q.toSQL(pushdown)
+	if outerSql == "" {
+		return ""
+	}
+
+	selection := []string{fmt.Sprintf("toUnixTimestamp(time) AS %s", PROMETHEUS_TIME_COLUMNS), PROMETHEUS_METRIC_VALUE, fmt.Sprintf("`%s`", PROMETHEUS_NATIVE_TAG_NAME)}
+	for tagName, tagAlias := range expectedQueryTags {
+		if tagAlias == "" {
+			selection = append(selection, tagName)
+		} else {
+			selection = append(selection, fmt.Sprintf("%s as %s", tagName, tagAlias))
+		}
+	}
+	orderBy := []string{fmt.Sprintf("%s desc", PROMETHEUS_TIME_COLUMNS)}
+	samplesSql := parseToQuerierSQL(ctx, chCommon.DB_NAME_PROMETHEUS, queryReq.GetMetric(), selection, filters, nil, orderBy)
+	return fmt.Sprintf("WITH _samples AS (%s) %s", samplesSql, outerSql)
+}
+
 func parseMatcherType(t labels.MatchType) prompb.LabelMatcher_Type {
 	switch t {
 	case labels.MatchEqual:
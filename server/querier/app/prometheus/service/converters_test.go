@@ -526,6 +526,16 @@ func TestParseQueryRequestToSQL(t *testing.T) {
 	rateInterval := (startMs % interval) / 1e3
 	irateInterval := (startMs%min_interval.Milliseconds() + min_interval.Milliseconds()) / 1e3
 
+	// pushed down functions, the samples are aggregated in the window of each evaluation timestamp
+	samplesSql := fmt.Sprintf("WITH _samples AS (SELECT toUnixTimestamp(time) AS timestamp,value,`tag` FROM `node_cpu_seconds_total` WHERE (time >= %d AND time <= %d) AND `tag.instance` = 'localhost' AND `tag.job` = 'prometheus'  ORDER BY timestamp desc LIMIT 1000000)", start, end)
+	seriesSql := func(series string, window, first, step, last int64) string {
+		return fmt.Sprintf("select %d + _k * %d AS _window, _window - %d AS _window_start, tag AS _tag, %s AS _series_value from _samples array join range(toUInt64(greatest(ceil((toInt64(timestamp) * 1000 - %d) / %d), 0)), toUInt64(greatest(least(floor((toInt64(timestamp) * 1000 + %d - %d) / %d), %d) + 1, 0))) AS _k group by _window, _tag",
+			first, step, window, series, first, step, window, first, step, last)
+	}
+	lookback := defaultLookbackDelta.Milliseconds()
+	rangeFirst := startMs + min_5.Milliseconds()
+	rangeLast := (endMs - rangeFirst) / interval
+
 	// instant query
 	instantQueryTestcases := []queryRequestParse{
 		{
@@ -601,8 +611,9 @@ func TestParseQueryRequestToSQL(t *testing.T) {
 					{Name: "job", Type: labels.MatchEqual, Value: "prometheus"},
 				},
 			},
-			output: "",
-			err:    nil,
+			output: fmt.Sprintf("%s select toUInt32(intDiv(_window, 1000)) AS timestamp, _tag AS tag, toFloat64(_series_value) AS value from (%s) order by _window desc, JSONExtractString(_tag, 'cpu'), _series_value asc, _tag limit 10 by _window, JSONExtractString(_tag, 'cpu')",
+				samplesSql, seriesSql("argMax(value, timestamp)", lookback, endMs, 1, 0)),
+			err: nil,
 		},
 
 		{
@@ -716,6 +727,63 @@ func TestParseQueryRequestToSQL(t *testing.T) {
 			output: fmt.Sprintf("SELECT toUnixTimestamp(time) AS timestamp,`tag`,Last(Derivative(value,tag)) as value FROM `node_cpu_seconds_total` WHERE (time >= %d AND time <= %d) AND `tag.instance` = 'localhost' AND `tag.job` = 'prometheus' AND `tag.instance` = '''demo' GROUP BY `tag`,timestamp ORDER BY timestamp desc LIMIT 1000000", start, end),
 			err:    nil,
 		},
+		{
+			input: &QueryHint{
+				start: startMs,
+				end:   endMs,
+				step:  0,
+				query: "eval stdvar(node_cpu_seconds_total) by (cpu)",
+				funcs: []functionCall{
+					{Name: "stdvar", Grouping: []string{"cpu"}},
+				},
+				matchers: []*labels.Matcher{
+					{Name: "__name__", Type: labels.MatchEqual, Value: "node_cpu_seconds_total"},
+					{Name: "instance", Type: labels.MatchEqual, Value: "localhost"},
+					{Name: "job", Type: labels.MatchEqual, Value: "prometheus"},
+				},
+			},
+			output: fmt.Sprintf("%s select toUInt32(intDiv(_window, 1000)) AS timestamp, toJSONString(map('cpu', JSONExtractString(_tag, 'cpu'))) AS tag, toFloat64(varPopStable(_series_value)) AS value from (%s) group by _window, tag order by timestamp desc",
+				samplesSql, seriesSql("argMax(value, timestamp)", lookback, endMs, 1, 0)),
+			err: nil,
+		},
+		{
+			input: &QueryHint{
+				start: startMs,
+				end:   endMs,
+				step:  0,
+				query: "eval changes(node_cpu_seconds_total[5m])",
+				funcs: []functionCall{
+					{Name: "changes", Range: min_5},
+				},
+				matchers: []*labels.Matcher{
+					{Name: "__name__", Type: labels.MatchEqual, Value: "node_cpu_seconds_total"},
+					{Name: "instance", Type: labels.MatchEqual, Value: "localhost"},
+					{Name: "job", Type: labels.MatchEqual, Value: "prometheus"},
+				},
+			},
+			output: fmt.Sprintf("%s select toUInt32(intDiv(_window, 1000)) AS timestamp, _tag AS tag, toFloat64(_series_value) AS value from (%s) order by timestamp desc",
+				samplesSql, seriesSql("arrayCount(x -> x != 0, arrayDifference(arrayMap(x -> x.2, arraySort(groupArray((timestamp, value))))))", min_5.Milliseconds(), endMs, 1, 0)),
+			err: nil,
+		},
+		{
+			input: &QueryHint{
+				start: startMs,
+				end:   endMs,
+				step:  0,
+				query: "eval absent(node_cpu_seconds_total)",
+				funcs: []functionCall{
+					{Name: "absent"},
+				},
+				matchers: []*labels.Matcher{
+					{Name: "__name__", Type: labels.MatchEqual, Value: "node_cpu_seconds_total"},
+					{Name: "instance", Type: labels.MatchEqual, Value: "localhost"},
+					{Name: "job", Type: labels.MatchEqual, Value: "prometheus"},
+				},
+			},
+			output: fmt.Sprintf("%s select toUInt32(intDiv(_window, 1000)) AS timestamp, '{}' AS tag, toFloat64(1) AS value from (%s) group by _window order by timestamp desc",
+				samplesSql, seriesSql("count()", lookback, endMs, 1, 0)),
+			err: nil,
+		},
 	}
 
 	// range query
@@ -793,8 +861,9 @@ func TestParseQueryRequestToSQL(t *testing.T) {
 					{Name: "job", Type: labels.MatchEqual, Value: "prometheus"},
 				},
 			},
-			output: "",
-			err:    nil,
+			output: fmt.Sprintf("%s select toUInt32(intDiv(_window, 1000)) AS timestamp, _tag AS tag, toFloat64(_series_value) AS value from (%s) order by _window desc, JSONExtractString(_tag, 'cpu'), _series_value asc, _tag limit 10 by _window, JSONExtractString(_tag, 'cpu')",
+				samplesSql, seriesSql("argMax(value, timestamp)", lookback, startMs+lookback, interval, (endMs-startMs-lookback)/interval)),
+			err: nil,
 		},
 
 		{
@@ -889,6 +958,62 @@ func TestParseQueryRequestToSQL(t *testing.T) {
 			output: fmt.Sprintf("SELECT time(time, 10, 1,'', %d) AS timestamp,`tag`,Last(Derivative(value,tag)) as value FROM `node_cpu_seconds_total` WHERE (time >= %d AND time <= %d) AND `tag.instance` = 'localhost' AND `tag.job` = 'prometheus' GROUP BY `tag`,timestamp ORDER BY timestamp desc LIMIT 1000000", irateInterval, start, end),
 			err:    nil,
 		},
+		{
+			input: &QueryHint{
+				start: startMs,
+				end:   endMs,
+				step:  interval,
+				query: "eval idelta(node_cpu_seconds_total[5m])",
+				funcs: []functionCall{
+					{Name: "idelta", Range: min_5},
+				},
+				matchers: []*labels.Matcher{
+					{Name: "__name__", Type: labels.MatchEqual, Value: "node_cpu_seconds_total"},
+					{Name: "instance", Type: labels.MatchEqual, Value: "localhost"},
+					{Name: "job", Type: labels.MatchEqual, Value: "prometheus"},
+				},
+			},
+			output: fmt.Sprintf("%s select toUInt32(intDiv(_window, 1000)) AS timestamp, _tag AS tag, toFloat64(_series_value) AS value from (%s having count() > 1) order by timestamp desc",
+				samplesSql, seriesSql("arraySort(groupArray((timestamp, value)))[-1].2 - arraySort(groupArray((timestamp, value)))[-2].2", min_5.Milliseconds(), rangeFirst, interval, rangeLast)),
+			err: nil,
+		},
+		{
+			input: &QueryHint{
+				start: startMs,
+				end:   endMs,
+				step:  interval,
+				query: "eval predict_linear(node_cpu_seconds_total[5m], 600)",
+				funcs: []functionCall{
+					{Name: "predict_linear", Range: min_5, Param: 600},
+				},
+				matchers: []*labels.Matcher{
+					{Name: "__name__", Type: labels.MatchEqual, Value: "node_cpu_seconds_total"},
+					{Name: "instance", Type: labels.MatchEqual, Value: "localhost"},
+					{Name: "job", Type: labels.MatchEqual, Value: "prometheus"},
+				},
+			},
+			output: fmt.Sprintf("%s select toUInt32(intDiv(_window, 1000)) AS timestamp, _tag AS tag, toFloat64(_series_value) AS value from (%s having count() > 1) order by timestamp desc",
+				samplesSql, seriesSql("simpleLinearRegression(timestamp - _window / 1000, value).1 * 600 + simpleLinearRegression(timestamp - _window / 1000, value).2", min_5.Milliseconds(), rangeFirst, interval, rangeLast)),
+			err: nil,
+		},
+		{
+			input: &QueryHint{
+				start: startMs,
+				end:   endMs,
+				step:  interval,
+				query: "eval deriv(node_cpu_seconds_total[1m:10s])",
+				funcs: []functionCall{
+					{Name: "deriv", SubStep: 10 * time.Second},
+				},
+				matchers: []*labels.Matcher{
+					{Name: "__name__", Type: labels.MatchEqual, Value: "node_cpu_seconds_total"},
+					{Name: "instance", Type: labels.MatchEqual, Value: "localhost"},
+					{Name: "job", Type: labels.MatchEqual, Value: "prometheus"},
+				},
+			},
+			output: "",
+			err:    nil,
+		},
 	}
 
 	// instant query
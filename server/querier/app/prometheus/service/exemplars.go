@@ -0,0 +1,248 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/deepflowio/deepflow/server/querier/app/prometheus/model"
+	"github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/config"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/client"
+	chCommon "github.com/deepflowio/deepflow/server/querier/engine/clickhouse/common"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/trans_prometheus"
+)
+
+const (
+	PROMETHEUS_EXEMPLAR_TABLE = "exemplars"
+	EXEMPLAR_TRACE_ID         = "trace_id"
+	EXEMPLAR_SPAN_ID          = "span_id"
+)
+
+// exemplars returns the exemplars of the series selected by the query, the result is the same as
+// the Prometheus API '/api/v1/query_exemplars'
+func (p *prometheusExecutor) exemplars(ctx context.Context, args *model.PromQueryParams) (*model.PromQueryResponse, error) {
+	start, err := parseTime(args.StartTime)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseTime(args.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("end timestamp must not be before start timestamp")
+	}
+	expr, err := parser.ParseExpr(args.Promql)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []model.PromExemplarData{}
+	for _, matchers := range parser.ExtractSelectors(expr) {
+		sql, ok := exemplarQuerySQL(matchers, start.Unix(), end.Unix(), args.OrgID, args.BlockTeamID)
+		if !ok {
+			continue
+		}
+		chClient := client.Client{
+			Host:     config.Cfg.Clickhouse.Host,
+			Port:     config.Cfg.Clickhouse.Port,
+			UserName: config.Cfg.Clickhouse.User,
+			Password: config.Cfg.Clickhouse.Password,
+			DB:       chCommon.DB_NAME_PROMETHEUS,
+			Context:  ctx,
+		}
+		result, err := chClient.DoQuery(&client.QueryParams{Sql: sql, ORGID: args.OrgID})
+		if err != nil {
+			return nil, err
+		}
+		results = appendExemplarResult(results, result)
+	}
+	return &model.PromQueryResponse{Data: results, Status: _SUCCESS}, nil
+}
+
+func prometheusDatabase(orgID string) string {
+	if orgID != common.DEFAULT_ORG_ID && orgID != "" {
+		if orgIDInt, err := strconv.Atoi(orgID); err == nil {
+			return fmt.Sprintf("%04d_%s", orgIDInt, chCommon.DB_NAME_PROMETHEUS)
+		}
+	}
+	return chCommon.DB_NAME_PROMETHEUS
+}
+
+func quoteExemplarValue(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// exemplarLabelFilter returns the filter of the label value, the label value is the 'label_value' column of
+// flow_tag.app_label_live_view or flow_tag.target_label_live_view
+func exemplarLabelFilter(m *labels.Matcher) string {
+	switch m.Type {
+	case labels.MatchEqual:
+		return "label_value = " + quoteExemplarValue(m.Value)
+	case labels.MatchNotEqual:
+		return "label_value != " + quoteExemplarValue(m.Value)
+	case labels.MatchRegexp:
+		return fmt.Sprintf("match(label_value, %s)", quoteExemplarValue("^(?:"+m.Value+")$"))
+	default:
+		return fmt.Sprintf("NOT match(label_value, %s)", quoteExemplarValue("^(?:"+m.Value+")$"))
+	}
+}
+
+// prometheusOrgMap returns the metric and label IDs of the organization
+func prometheusOrgMap(orgID string) trans_prometheus.PrometheusMap {
+	if orgID == "" {
+		return trans_prometheus.ORGPrometheus[common.DEFAULT_ORG_ID]
+	}
+	return trans_prometheus.ORGPrometheus[orgID]
+}
+
+// seriesFilters translates the label matchers of a selector into the filters of the tables which store the app
+// label values in the array app_label_value_ids, such as prometheus.exemplars and prometheus.histograms, returns
+// false if the selector can not match any series. The matchers of the metric name should be checked by the caller.
+func seriesFilters(prometheusMap trans_prometheus.PrometheusMap, metricName string, metricID uint64, matchers []*labels.Matcher, blockTeamID []string) ([]string, bool) {
+	appLabels := prometheusMap.MetricAppLabelLayout[metricName]
+	filters := []string{fmt.Sprintf("metric_id=%d", metricID)}
+	if len(blockTeamID) > 0 {
+		filters = append(filters, fmt.Sprintf("team_id NOT IN (%s)", strings.Join(blockTeamID, ",")))
+	}
+	for _, m := range matchers {
+		if m.Name == labels.MetricName {
+			continue
+		}
+		labelNameID, ok := prometheusMap.LabelNameToID[m.Name]
+		if !ok {
+			// the label does not exist, it is matched only if the matcher matches the empty value
+			if !m.Matches("") {
+				return nil, false
+			}
+			continue
+		}
+		column := ""
+		for _, appLabel := range appLabels {
+			if appLabel.AppLabelName == m.Name {
+				column = fmt.Sprintf("app_label_value_ids[%d]", appLabel.AppLabelColumnIndex)
+				break
+			}
+		}
+		if column != "" {
+			filters = append(filters, fmt.Sprintf("toUInt64(%s) GLOBAL IN (SELECT label_value_id FROM flow_tag.app_label_live_view WHERE label_name_id=%d and %s)",
+				column, labelNameID, exemplarLabelFilter(m)))
+		} else {
+			filters = append(filters, fmt.Sprintf("toUInt64(target_id) GLOBAL IN (SELECT target_id FROM flow_tag.target_label_live_view WHERE metric_id=%d and label_name_id=%d and %s)",
+				metricID, labelNameID, exemplarLabelFilter(m)))
+		}
+	}
+	return filters, true
+}
+
+// seriesLabelsExpr returns the expression of the labels of the series in JSON, including the metric name, the app
+// labels in the array app_label_value_ids and the target labels
+func seriesLabelsExpr(prometheusMap trans_prometheus.PrometheusMap, metricName string) string {
+	seriesLabels := []string{fmt.Sprintf("'%s',%s", labels.MetricName, quoteExemplarValue(metricName))}
+	for _, appLabel := range prometheusMap.MetricAppLabelLayout[metricName] {
+		if labelNameID, ok := prometheusMap.LabelNameToID[appLabel.AppLabelName]; ok {
+			seriesLabels = append(seriesLabels, fmt.Sprintf("%s,dictGet('flow_tag.app_label_map', 'label_value', (toUInt64(%d), toUInt64(app_label_value_ids[%d])))",
+				quoteExemplarValue(appLabel.AppLabelName), labelNameID, appLabel.AppLabelColumnIndex))
+		}
+	}
+	targetLabels := "CAST((splitByString(', ', dictGet('flow_tag.prometheus_target_label_layout_map', 'target_label_names', toUInt64(target_id))), splitByString(', ', dictGet('flow_tag.prometheus_target_label_layout_map', 'target_label_values', toUInt64(target_id)))), 'Map(String, String)')"
+	return fmt.Sprintf("toJSONString(mapUpdate(map(%s),%s))", strings.Join(seriesLabels, ","), targetLabels)
+}
+
+// exemplarQuerySQL translates the matchers of a selector into the SQL of prometheus.exemplars, returns false
+// if the selector can not match any exemplars
+func exemplarQuerySQL(matchers []*labels.Matcher, start, end int64, orgID string, blockTeamID []string) (string, bool) {
+	metricName := ""
+	for _, m := range matchers {
+		if m.Name == labels.MetricName && m.Type == labels.MatchEqual {
+			metricName = m.Value
+		}
+	}
+	prometheusMap := prometheusOrgMap(orgID)
+	metricID, ok := prometheusMap.MetricNameToID[metricName]
+	if metricName == "" || !ok {
+		return "", false
+	}
+	for _, m := range matchers {
+		if m.Name == labels.MetricName && !m.Matches(metricName) {
+			return "", false
+		}
+	}
+	filters, ok := seriesFilters(prometheusMap, metricName, metricID, matchers, blockTeamID)
+	if !ok {
+		return "", false
+	}
+	filters = append(filters, fmt.Sprintf("time>=%d", start), fmt.Sprintf("time<=%d", end))
+
+	return fmt.Sprintf("SELECT %s AS series_labels, "+
+		"toJSONString(CAST((attribute_names, attribute_values), 'Map(String, String)')) AS exemplar_labels, "+
+		"trace_id, span_id, value, toFloat64(toUnixTimestamp64Micro(timestamp))/1000000 AS exemplar_time "+
+		"FROM %s.`%s` WHERE %s ORDER BY exemplar_time LIMIT %s",
+		seriesLabelsExpr(prometheusMap, metricName),
+		prometheusDatabase(orgID), PROMETHEUS_EXEMPLAR_TABLE, strings.Join(filters, " AND "), config.Cfg.Prometheus.Limit), true
+}
+
+func appendExemplarResult(results []model.PromExemplarData, result *common.Result) []model.PromExemplarData {
+	seriesIndex := make(map[string]int)
+	for _, v := range result.Values {
+		row, ok := v.([]interface{})
+		if !ok || len(row) < 6 {
+			continue
+		}
+		seriesKey, _ := row[0].(string)
+		index, ok := seriesIndex[seriesKey]
+		if !ok {
+			seriesLabels := map[string]string{}
+			json.Unmarshal([]byte(seriesKey), &seriesLabels)
+			for k, v := range seriesLabels {
+				if v == "" {
+					delete(seriesLabels, k)
+				}
+			}
+			results = append(results, model.PromExemplarData{SeriesLabels: seriesLabels})
+			index = len(results) - 1
+			seriesIndex[seriesKey] = index
+		}
+
+		exemplarLabels := map[string]string{}
+		if s, ok := row[1].(string); ok {
+			json.Unmarshal([]byte(s), &exemplarLabels)
+		}
+		if traceID, _ := row[2].(string); traceID != "" {
+			exemplarLabels[EXEMPLAR_TRACE_ID] = traceID
+		}
+		if spanID, _ := row[3].(string); spanID != "" {
+			exemplarLabels[EXEMPLAR_SPAN_ID] = spanID
+		}
+		value, _ := row[4].(float64)
+		timestamp, _ := row[5].(float64)
+		results[index].Exemplars = append(results[index].Exemplars, model.PromExemplar{
+			Labels:    exemplarLabels,
+			Value:     strconv.FormatFloat(value, 'f', -1, 64),
+			Timestamp: timestamp,
+		})
+	}
+	return results
+}
@@ -22,6 +22,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/prompb"
+
 	"github.com/deepflowio/deepflow/server/querier/app/prometheus/model"
 )
 
@@ -40,6 +43,11 @@ func offloadEnabled(metric string, funcs []string, by bool) bool {
 		return false
 	}
 
+	// the pushed down function is calculated by ClickHouse, the outer functions are calculated by prometheus engine
+	if QueryPushdownCall[funcs[0]] != nil {
+		return true
+	}
+
 	// when len(funcs)>2, we don't consider offload
 	// the inner function offloaded <VectorSelector> for data reduce is enough, i.e.: sum(rate(x))
 	maxIterateLevel := math.Min(float64(len(funcs)), 2)
@@ -293,3 +301,199 @@ func offloadRate(fun string) QueryFunc {
 		*query = append(*query, fmt.Sprintf("Max(%s)", metric))
 	}
 }
+
+/*
+the functions below are pushed down to ClickHouse, they are calculated at each evaluation timestamp by the SQL:
+
+WITH _samples AS (<querier sql of samples>) select ... from (select ... from _samples array join <windows> ...)
+
+- the samples of series are selected by querier as `_samples` with columns: `timestamp`, `value`, `tag` and deepflow tags
+- the evaluation timestamps are [start, start+step, ..., end], the window of evaluation timestamp T is [T-window, T],
+window is the range of matrix selector, or the lookback delta of vector selector, each sample is joined to all windows it's in
+- the samples of each series in window are aggregated to one value, then the function is calculated on the aggregated series
+- the outer sql is not translated by querier, keep it in lower case, querier only translates the sub sql `(SELECT ... LIMIT ...)`
+
+the result of function at evaluation timestamp T is returned as the sample at T, see `changeFunctionAfterOffloadSelected`
+for how prometheus engine outputs it
+*/
+
+type pushdownQuery struct {
+	param     float64
+	window    int64    // range of matrix selector or lookback delta of vector selector, unit: ms
+	start     int64    // the first evaluation timestamp, unit: ms
+	end       int64    // the last evaluation timestamp, unit: ms
+	step      int64    // unit: ms
+	labels    []string // prometheus labels in grouping
+	groupTags []string // deepflow tags in grouping
+	tags      []string // deepflow tags of series
+}
+
+type pushdownFunc struct {
+	// aggregate the samples in range of matrix selector, otherwise the last sample in lookback delta
+	matrix bool
+	// aggregation of the samples of each series in window, `_window` is the evaluation timestamp
+	series func(param float64) string
+	// filter of the aggregated series, i.e.: at least 2 samples for delta
+	having string
+	// calculates the function on the aggregated series as `_series_value`, returns the outer sql
+	// nil if the function keeps the aggregated series
+	calculate func(q *pushdownQuery, from string) string
+}
+
+const (
+	pushdownLastSample    = "argMax(value, timestamp)"
+	pushdownSortedSamples = "arraySort(groupArray((timestamp, value)))"
+	pushdownAtLeast2      = "count() > 1"
+)
+
+var QueryPushdownCall = map[string]*pushdownFunc{
+	// aggregation operators, calculated on the last sample of each series in lookback delta
+	"stddev":  {series: pushdownSeries(pushdownLastSample), calculate: pushdownAggregation("stddevPopStable")},
+	"stdvar":  {series: pushdownSeries(pushdownLastSample), calculate: pushdownAggregation("varPopStable")},
+	"bottomk": {series: pushdownSeries(pushdownLastSample), calculate: pushdownBottomK},
+
+	// range-vector functions calculated by all samples in range
+	"idelta": {matrix: true, series: pushdownSeries(fmt.Sprintf("%[1]s[-1].2 - %[1]s[-2].2", pushdownSortedSamples)), having: pushdownAtLeast2},
+	// minus(last, first) extrapolated to the whole range, the same as `extrapolatedRate` without counter reset
+	"delta": {matrix: true, series: pushdownSeries(
+		"(argMax(value, timestamp) - argMin(value, timestamp)) * ((max(timestamp) - min(timestamp)" +
+			" + if(min(timestamp) - _window_start / 1000 < (max(timestamp) - min(timestamp)) / (count() - 1) * 1.1, min(timestamp) - _window_start / 1000, (max(timestamp) - min(timestamp)) / (count() - 1) / 2)" +
+			" + if(_window / 1000 - max(timestamp) < (max(timestamp) - min(timestamp)) / (count() - 1) * 1.1, _window / 1000 - max(timestamp), (max(timestamp) - min(timestamp)) / (count() - 1) / 2))" +
+			" / (max(timestamp) - min(timestamp)))"), having: pushdownAtLeast2},
+	"deriv": {matrix: true, series: pushdownSeries("simpleLinearRegression(timestamp - _window / 1000, value).1"), having: pushdownAtLeast2},
+	"predict_linear": {matrix: true, series: func(duration float64) string {
+		return fmt.Sprintf("simpleLinearRegression(timestamp - _window / 1000, value).1 * %v + simpleLinearRegression(timestamp - _window / 1000, value).2", duration)
+	}, having: pushdownAtLeast2},
+	"changes": {matrix: true, series: pushdownSeries(fmt.Sprintf("arrayCount(x -> x != 0, arrayDifference(arrayMap(x -> x.2, %s)))", pushdownSortedSamples))},
+	"resets":  {matrix: true, series: pushdownSeries(fmt.Sprintf("arrayCount(x -> x < 0, arrayDifference(arrayMap(x -> x.2, %s)))", pushdownSortedSamples))},
+
+	// instant-vector functions
+	"histogram_quantile": {series: pushdownSeries(pushdownLastSample), calculate: pushdownHistogramQuantile},
+	"label_replace":      {series: pushdownSeries(pushdownLastSample)}, // the labels are replaced by prometheus engine
+	"absent":             {series: pushdownSeries("count()"), calculate: pushdownAbsent},
+}
+
+func pushdownSeries(sql string) func(float64) string {
+	return func(float64) string { return sql }
+}
+
+// joins each sample to the windows it's in, and aggregates the samples of each series in window
+func (q *pushdownQuery) seriesSQL(f *pushdownFunc) string {
+	step, last := q.step, int64(0)
+	if step > 0 {
+		last = (q.end - q.start) / step
+	} else {
+		step = 1
+	}
+	// the sample at t is in the windows of evaluation timestamp T when T-window <= t <= T
+	first := fmt.Sprintf("greatest(ceil((toInt64(timestamp) * 1000 - %d) / %d), 0)", q.start, step)
+	end := fmt.Sprintf("least(floor((toInt64(timestamp) * 1000 + %d - %d) / %d), %d)", q.window, q.start, step, last)
+	sql := fmt.Sprintf("select %d + _k * %d AS _window, _window - %d AS _window_start, tag AS _tag%s, %s AS _series_value from _samples array join range(toUInt64(%s), toUInt64(greatest(%s + 1, 0))) AS _k group by _window, _tag%s",
+		q.start, step, q.window, pushdownColumns(q.tags), f.series(q.param), first, end, pushdownColumns(q.tags))
+	if f.having != "" {
+		sql += " having " + f.having
+	}
+	return sql
+}
+
+func pushdownColumns(columns []string) string {
+	if len(columns) == 0 {
+		return ""
+	}
+	return ", " + strings.Join(columns, ", ")
+}
+
+const pushdownTimestamp = "toUInt32(intDiv(_window, 1000)) AS timestamp"
+
+// the outer sql of the pushed down function, the result is ordered by timestamp desc, see `respTransToProm`
+func (q *pushdownQuery) toSQL(f *pushdownFunc) string {
+	series := q.seriesSQL(f)
+	if f.calculate != nil {
+		return f.calculate(q, series)
+	}
+	return fmt.Sprintf("select %s, _tag AS tag%s, toFloat64(_series_value) AS value from (%s) order by timestamp desc",
+		pushdownTimestamp, pushdownColumns(q.tags), series)
+}
+
+// the labels in grouping of series `_tag`
+func (q *pushdownQuery) groupLabels() []string {
+	labels := make([]string, 0, len(q.labels))
+	for _, label := range q.labels {
+		labels = append(labels, fmt.Sprintf("JSONExtractString(_tag, '%s')", escapeSingleQuote(label)))
+	}
+	return labels
+}
+
+func pushdownAggregation(aggregation string) func(q *pushdownQuery, from string) string {
+	return func(q *pushdownQuery, from string) string {
+		tag := "'{}'"
+		if labels := q.groupLabels(); len(labels) > 0 {
+			pairs := make([]string, 0, len(labels))
+			for i, label := range labels {
+				pairs = append(pairs, fmt.Sprintf("'%s', %s", escapeSingleQuote(q.labels[i]), label))
+			}
+			tag = fmt.Sprintf("toJSONString(map(%s))", strings.Join(pairs, ", "))
+		}
+		return fmt.Sprintf("select %s, %s AS tag%s, toFloat64(%s(_series_value)) AS value from (%s) group by _window, tag%s order by timestamp desc",
+			pushdownTimestamp, tag, pushdownColumns(q.groupTags), aggregation, from, pushdownColumns(q.groupTags))
+	}
+}
+
+func pushdownBottomK(q *pushdownQuery, from string) string {
+	if q.param < 1 {
+		return ""
+	}
+	groups := append(q.groupLabels(), q.groupTags...)
+	return fmt.Sprintf("select %s, _tag AS tag%s, toFloat64(_series_value) AS value from (%s) order by _window desc%s, _series_value asc, _tag limit %d by _window%s",
+		pushdownTimestamp, pushdownColumns(q.tags), from, pushdownColumns(groups), int64(q.param), pushdownColumns(groups))
+}
+
+// the same as `bucketQuantile` of prometheus, the buckets of series with the same labels except `le` are calculated together
+func pushdownHistogramQuantile(q *pushdownQuery, from string) string {
+	le := "JSONExtractString(_tag, 'le')"
+	buckets := fmt.Sprintf("select _window, toJSONString(mapFilter((k, v) -> k != 'le', JSONExtract(_tag, 'Map(String, String)'))) AS _labels%s, multiIf(lower(%s) IN ('+inf', 'inf'), inf, toFloat64OrNull(%s)) AS _le, sum(_series_value) AS _count from (%s) where isNotNull(_le) group by _window, _labels%s, _le",
+		pushdownColumns(q.tags), le, le, from, pushdownColumns(q.tags))
+	histogram := fmt.Sprintf("select _window, _labels%s, arraySort(groupArray((assumeNotNull(_le), _count))) AS _buckets from (%s) group by _window, _labels%s",
+		pushdownColumns(q.tags), buckets, pushdownColumns(q.tags))
+	quantile := fmt.Sprintf("select _window, _labels%s, length(_buckets) AS _n, arrayMap(x -> x.1, _buckets) AS _les, arrayMap(i -> arrayReduce('max', arraySlice(arrayMap(x -> x.2, _buckets), 1, i)), arrayEnumerate(_buckets)) AS _counts, %v * _counts[_n] AS _rank, arrayFirstIndex(x -> x >= _rank, arraySlice(_counts, 1, _n - 1)) AS _b from (%s)",
+		pushdownColumns(q.tags), q.param, histogram)
+
+	value := "multiIf(_n < 2 OR _les[_n] != inf OR _counts[_n] = 0, nan, _b = 0, _les[_n - 1], _b = 1 AND _les[1] <= 0, _les[1], _b = 1, _les[1] * (_rank / _counts[1]), _les[_b - 1] + (_les[_b] - _les[_b - 1]) * ((_rank - _counts[_b - 1]) / (_counts[_b] - _counts[_b - 1])))"
+	if math.IsNaN(q.param) {
+		value = "nan"
+	} else if q.param < 0 {
+		value = "-inf"
+	} else if q.param > 1 {
+		value = "inf"
+	}
+	return fmt.Sprintf("select %s, _labels AS tag%s, toFloat64(%s) AS value from (%s) order by timestamp desc",
+		pushdownTimestamp, pushdownColumns(q.tags), value, quantile)
+}
+
+func pushdownAbsent(q *pushdownQuery, from string) string {
+	return fmt.Sprintf("select %s, '{}' AS tag, toFloat64(1) AS value from (%s) group by _window order by timestamp desc", pushdownTimestamp, from)
+}
+
+// the result of function at T is returned as the sample at T, when the series has no result at the next evaluation timestamp,
+// add a stale marker there, so prometheus engine won't look back to the result of T
+func (q *pushdownQuery) appendStaleMarkers(result *prompb.QueryResult) {
+	if q.step <= 0 {
+		return
+	}
+	for _, series := range result.Timeseries {
+		samples := make([]prompb.Sample, 0, len(series.Samples))
+		for i, sample := range series.Samples {
+			samples = append(samples, sample)
+			// the sample of evaluation timestamp T is at T in seconds
+			next := q.start + ((sample.Timestamp-q.start+q.step-1)/q.step+1)*q.step
+			if next > q.end {
+				continue
+			}
+			next = next / 1000 * 1000
+			if i+1 == len(series.Samples) || series.Samples[i+1].Timestamp > next {
+				samples = append(samples, prompb.Sample{Timestamp: next, Value: math.Float64frombits(value.StaleNaN)})
+			}
+		}
+		series.Samples = samples
+	}
+}
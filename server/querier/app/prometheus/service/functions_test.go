@@ -0,0 +1,503 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/storage/remote"
+
+	"github.com/deepflowio/deepflow/server/querier/app/prometheus/model"
+	"github.com/deepflowio/deepflow/server/querier/common"
+)
+
+const pushdownTestSeries = `
+load 10s
+	test_requests_total{instance="a", job="x"} 0+10x90 0+15x89
+	test_requests_total{instance="b", job="x"} 5+3x179
+	test_requests_total{instance="c", job="y"} 100-1x40 60+7x50 20-2x88
+	test_latency_bucket{le="0.1"} 0+1x179
+	test_latency_bucket{le="0.5"} 0+3x179
+	test_latency_bucket{le="+Inf"} 0+4x179
+`
+
+// pushdownSeriesAggregations simulates `series` of QueryPushdownCall, the samples of a series in window are sorted by time,
+// the timestamps in sql are in seconds
+var pushdownSeriesAggregations = map[string]func(samples []promql.Point, param float64, window, windowStart int64) float64{
+	"last": func(s []promql.Point, _ float64, _, _ int64) float64 { return s[len(s)-1].V },
+	"count": func(s []promql.Point, _ float64, _, _ int64) float64 {
+		return float64(len(s))
+	},
+	"idelta": func(s []promql.Point, _ float64, _, _ int64) float64 { return s[len(s)-1].V - s[len(s)-2].V },
+	"delta": func(s []promql.Point, _ float64, window, windowStart int64) float64 {
+		first, last := float64(s[0].T/1000), float64(s[len(s)-1].T/1000)
+		sampled := last - first
+		avg := sampled / float64(len(s)-1)
+		extrapolated := sampled
+		if toStart := first - float64(windowStart)/1000; toStart < avg*1.1 {
+			extrapolated += toStart
+		} else {
+			extrapolated += avg / 2
+		}
+		if toEnd := float64(window)/1000 - last; toEnd < avg*1.1 {
+			extrapolated += toEnd
+		} else {
+			extrapolated += avg / 2
+		}
+		return (s[len(s)-1].V - s[0].V) * extrapolated / sampled
+	},
+	"deriv": func(s []promql.Point, _ float64, window, _ int64) float64 {
+		slope, _ := simpleLinearRegression(s, window)
+		return slope
+	},
+	"predict_linear": func(s []promql.Point, duration float64, window, _ int64) float64 {
+		slope, intercept := simpleLinearRegression(s, window)
+		return slope*duration + intercept
+	},
+	"changes": func(s []promql.Point, _ float64, _, _ int64) float64 {
+		changes := 0
+		for i := 1; i < len(s); i++ {
+			if s[i].V-s[i-1].V != 0 {
+				changes++
+			}
+		}
+		return float64(changes)
+	},
+	"resets": func(s []promql.Point, _ float64, _, _ int64) float64 {
+		resets := 0
+		for i := 1; i < len(s); i++ {
+			if s[i].V-s[i-1].V < 0 {
+				resets++
+			}
+		}
+		return float64(resets)
+	},
+}
+
+// the same as `simpleLinearRegression(timestamp - _window / 1000, value)` of ClickHouse
+func simpleLinearRegression(s []promql.Point, window int64) (slope, intercept float64) {
+	var sumX, sumY, sumXY, sumX2 float64
+	for _, p := range s {
+		x := float64(p.T/1000) - float64(window)/1000
+		sumX += x
+		sumY += p.V
+		sumXY += x * p.V
+		sumX2 += x * x
+	}
+	n := float64(len(s))
+	slope = (n*sumXY - sumX*sumY) / (n*sumX2 - sumX*sumX)
+	intercept = (sumY - slope*sumX) / n
+	return slope, intercept
+}
+
+type pushdownRow struct {
+	window int64
+	tag    string
+	value  float64
+}
+
+// pushdownSQL simulates the sql built by `pushdownQuery.toSQL` on the raw samples
+func pushdownSQL(t *testing.T, raw storage.Queryable, q *pushdownQuery, f *pushdownFunc, name, aggregation string, matchers []*labels.Matcher) *common.Result {
+	querier, err := raw.Querier(context.Background(), math.MinInt64, math.MaxInt64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer querier.Close()
+
+	step, last := q.step, int64(0)
+	if step > 0 {
+		last = (q.end - q.start) / step
+	} else {
+		step = 1
+	}
+	aggregate := pushdownSeriesAggregations[aggregation]
+	rows := []pushdownRow{}
+	seriesSet := querier.Select(false, nil, matchers...)
+	for seriesSet.Next() {
+		series := seriesSet.At()
+		tag, err := json.Marshal(series.Labels().WithoutLabels(labels.MetricName).Map())
+		if err != nil {
+			t.Fatal(err)
+		}
+		windows := map[int64][]promql.Point{}
+		it := series.Iterator()
+		for it.Next() {
+			ts, v := it.At()
+			// the timestamps are selected in seconds
+			ts = ts / 1000 * 1000
+			first := int64(math.Max(math.Ceil(float64(ts-q.start)/float64(step)), 0))
+			end := int64(math.Min(math.Floor(float64(ts+q.window-q.start)/float64(step)), float64(last)))
+			for k := first; k <= end; k++ {
+				windows[k] = append(windows[k], promql.Point{T: ts, V: v})
+			}
+		}
+		for k, samples := range windows {
+			if f.having == pushdownAtLeast2 && len(samples) < 2 {
+				continue
+			}
+			window := q.start + k*step
+			rows = append(rows, pushdownRow{window: window, tag: string(tag), value: aggregate(samples, q.param, window, window-q.window)})
+		}
+	}
+	if err := seriesSet.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	switch name {
+	case "stddev", "stdvar":
+		rows = pushdownAggregate(rows, q.labels, func(values []float64) float64 {
+			var mean, variance float64
+			for _, v := range values {
+				mean += v / float64(len(values))
+			}
+			for _, v := range values {
+				variance += (v - mean) * (v - mean) / float64(len(values))
+			}
+			if name == "stddev" {
+				return math.Sqrt(variance)
+			}
+			return variance
+		})
+	case "bottomk":
+		groups := map[string][]pushdownRow{}
+		for _, row := range rows {
+			key := fmt.Sprintf("%d-%s", row.window, pushdownGroupTag(row.tag, q.labels))
+			groups[key] = append(groups[key], row)
+		}
+		rows = rows[:0]
+		for _, group := range groups {
+			sort.Slice(group, func(i, j int) bool {
+				if group[i].value != group[j].value {
+					return group[i].value < group[j].value
+				}
+				return group[i].tag < group[j].tag
+			})
+			if len(group) > int(q.param) {
+				group = group[:int(q.param)]
+			}
+			rows = append(rows, group...)
+		}
+	case "histogram_quantile":
+		rows = pushdownHistogramQuantileRows(rows, q.param)
+	case "absent":
+		rows = pushdownAggregate(rows, nil, func([]float64) float64 { return 1 })
+	}
+
+	sort.Slice(rows, func(i, j int) bool { return rows[i].window > rows[j].window })
+	result := &common.Result{
+		Columns: []interface{}{PROMETHEUS_TIME_COLUMNS, PROMETHEUS_NATIVE_TAG_NAME, PROMETHEUS_METRIC_VALUE},
+		Schemas: common.ColumnSchemas{{Name: PROMETHEUS_TIME_COLUMNS, ValueType: "UInt32"}, {Name: PROMETHEUS_NATIVE_TAG_NAME, ValueType: "String"}, {Name: PROMETHEUS_METRIC_VALUE, ValueType: "Float64"}},
+	}
+	for _, row := range rows {
+		result.Values = append(result.Values, []interface{}{uint32(row.window / 1000), row.tag, row.value})
+	}
+	return result
+}
+
+// the tag of series only keeps labels in grouping, `{}` without grouping
+func pushdownGroupTag(tag string, grouping []string) string {
+	if len(grouping) == 0 {
+		return "{}"
+	}
+	m := map[string]string{}
+	json.Unmarshal([]byte(tag), &m)
+	group := make(map[string]string, len(grouping))
+	for _, label := range grouping {
+		group[label] = m[label]
+	}
+	b, _ := json.Marshal(group)
+	return string(b)
+}
+
+func pushdownAggregate(rows []pushdownRow, grouping []string, aggregate func([]float64) float64) []pushdownRow {
+	groups := map[pushdownRow][]float64{}
+	for _, row := range rows {
+		key := pushdownRow{window: row.window, tag: pushdownGroupTag(row.tag, grouping)}
+		groups[key] = append(groups[key], row.value)
+	}
+	aggregated := make([]pushdownRow, 0, len(groups))
+	for key, values := range groups {
+		key.value = aggregate(values)
+		aggregated = append(aggregated, key)
+	}
+	return aggregated
+}
+
+func pushdownHistogramQuantileRows(rows []pushdownRow, q float64) []pushdownRow {
+	type bucket struct{ le, count float64 }
+	histograms := map[pushdownRow]map[float64]float64{}
+	for _, row := range rows {
+		m := map[string]string{}
+		json.Unmarshal([]byte(row.tag), &m)
+		le, err := strconv.ParseFloat(m["le"], 64)
+		if lower := strings.ToLower(m["le"]); lower == "+inf" || lower == "inf" {
+			le, err = math.Inf(1), nil
+		}
+		if err != nil {
+			continue
+		}
+		delete(m, "le")
+		tag, _ := json.Marshal(m)
+		key := pushdownRow{window: row.window, tag: string(tag)}
+		if histograms[key] == nil {
+			histograms[key] = map[float64]float64{}
+		}
+		histograms[key][le] += row.value
+	}
+	quantiles := make([]pushdownRow, 0, len(histograms))
+	for key, counts := range histograms {
+		buckets := make([]bucket, 0, len(counts))
+		for le, count := range counts {
+			buckets = append(buckets, bucket{le, count})
+		}
+		sort.Slice(buckets, func(i, j int) bool { return buckets[i].le < buckets[j].le })
+		for i := 1; i < len(buckets); i++ {
+			buckets[i].count = math.Max(buckets[i].count, buckets[i-1].count)
+		}
+		n := len(buckets)
+		switch {
+		case math.IsNaN(q):
+			key.value = math.NaN()
+		case q < 0:
+			key.value = math.Inf(-1)
+		case q > 1:
+			key.value = math.Inf(1)
+		case n < 2 || !math.IsInf(buckets[n-1].le, 1) || buckets[n-1].count == 0:
+			key.value = math.NaN()
+		default:
+			rank := q * buckets[n-1].count
+			b := sort.Search(n-1, func(i int) bool { return buckets[i].count >= rank })
+			switch {
+			case b == n-1:
+				key.value = buckets[n-2].le
+			case b == 0 && buckets[0].le <= 0:
+				key.value = buckets[0].le
+			case b == 0:
+				key.value = buckets[0].le * (rank / buckets[0].count)
+			default:
+				key.value = buckets[b-1].le + (buckets[b].le-buckets[b-1].le)*((rank-buckets[b-1].count)/(buckets[b].count-buckets[b-1].count))
+			}
+		}
+		quantiles = append(quantiles, key)
+	}
+	return quantiles
+}
+
+// pushdownQuerier returns the results of pushed down function, and changes the query like `OffloadQuerier`
+type pushdownQuerier struct {
+	storage.Querier
+	querierable *OffloadQuerierable
+	query       promql.Query
+	queryType   model.QueryType
+	function    string
+	result      *common.Result
+	pushdown    *pushdownQuery
+	reader      *prometheusReader
+}
+
+func (p *pushdownQuerier) Select(sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
+	p.querierable.restoreFunctionAfterQueryFinished()
+	ctx := context.WithValue(context.Background(), ctxKeyPrefixType{}, prefixDeepFlow)
+	var metric string
+	for _, matcher := range matchers {
+		if matcher.Name == labels.MetricName {
+			metric = matcher.Value
+		}
+	}
+	resp, err := p.reader.respTransToProm(ctx, metric, p.pushdown.start/1e3, p.pushdown.end/1e3, p.result)
+	if err != nil {
+		return storage.ErrSeriesSet(err)
+	}
+	p.querierable.pushdownSelectors[matchers[0]] = p.function
+	p.pushdown.appendStaleMarkers(resp.Results[0])
+	p.querierable.changeFunctionAfterOffloadSelected(p.query.Statement().(*parser.EvalStmt), p.queryType)
+	return remote.FromQueryResult(sortSeries, resp.Results[0])
+}
+
+func comparePushdownResult(t *testing.T, expected, actual promql.Matrix) {
+	if len(expected) != len(actual) {
+		t.Fatalf("expected %d series, got %d: %v", len(expected), len(actual), actual)
+	}
+	for i := range expected {
+		if !labels.Equal(expected[i].Metric, actual[i].Metric) {
+			t.Fatalf("expected series %s, got %s", expected[i].Metric, actual[i].Metric)
+		}
+		if len(expected[i].Points) != len(actual[i].Points) {
+			t.Fatalf("series %s: expected %d points, got %d", expected[i].Metric, len(expected[i].Points), len(actual[i].Points))
+		}
+		for j, p := range expected[i].Points {
+			a := actual[i].Points[j]
+			if math.IsNaN(p.V) && math.IsNaN(a.V) && p.T == a.T {
+				continue
+			}
+			if p.T != a.T || !(p.V == a.V || math.Abs(p.V-a.V) <= 1e-9*math.Max(1, math.Abs(p.V))) {
+				t.Fatalf("series %s: expected %v, got %v", expected[i].Metric, p, a)
+			}
+		}
+	}
+}
+
+// TestPushdownFunctions checks the functions pushed down to ClickHouse: the sql is simulated on the raw series,
+// and the results output by prometheus engine should be the same as the function calculated by prometheus engine
+func TestPushdownFunctions(t *testing.T) {
+	test, err := promql.NewTest(t, pushdownTestSeries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer test.Close()
+	if err := test.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	start, end, step := time.Unix(600, 0), time.Unix(1500, 0), 30*time.Second
+	testCases := []struct {
+		query       string
+		call        functionCall
+		aggregation string
+	}{
+		{query: "stddev(test_requests_total)", call: functionCall{Name: "stddev"}, aggregation: "last"},
+		{query: "stdvar by (job) (test_requests_total)", call: functionCall{Name: "stdvar", Grouping: []string{"job"}}, aggregation: "last"},
+		{query: "bottomk(2, test_requests_total)", call: functionCall{Name: "bottomk", Param: 2}, aggregation: "last"},
+		{query: "bottomk by (job) (1, test_requests_total)", call: functionCall{Name: "bottomk", Param: 1, Grouping: []string{"job"}}, aggregation: "last"},
+		{query: "idelta(test_requests_total[1m])", call: functionCall{Name: "idelta", Range: time.Minute}, aggregation: "idelta"},
+		{query: "delta(test_requests_total[5m])", call: functionCall{Name: "delta", Range: 5 * time.Minute}, aggregation: "delta"},
+		{query: "deriv(test_requests_total[5m])", call: functionCall{Name: "deriv", Range: 5 * time.Minute}, aggregation: "deriv"},
+		{query: "predict_linear(test_requests_total[5m], 600)", call: functionCall{Name: "predict_linear", Range: 5 * time.Minute, Param: 600}, aggregation: "predict_linear"},
+		{query: "predict_linear(test_requests_total[5m], -600)", call: functionCall{Name: "predict_linear", Range: 5 * time.Minute, Param: -600}, aggregation: "predict_linear"},
+		{query: "changes(test_requests_total[5m])", call: functionCall{Name: "changes", Range: 5 * time.Minute}, aggregation: "changes"},
+		{query: "resets(test_requests_total[5m])", call: functionCall{Name: "resets", Range: 5 * time.Minute}, aggregation: "resets"},
+		{query: "histogram_quantile(0.9, test_latency_bucket)", call: functionCall{Name: "histogram_quantile", Param: 0.9}, aggregation: "last"},
+		{query: "histogram_quantile(0, test_latency_bucket)", call: functionCall{Name: "histogram_quantile", Param: 0}, aggregation: "last"},
+		{query: "histogram_quantile(1.5, test_latency_bucket)", call: functionCall{Name: "histogram_quantile", Param: 1.5}, aggregation: "last"},
+		{query: `label_replace(test_requests_total, "host", "$1", "instance", "(.*)")`, call: functionCall{Name: "label_replace"}, aggregation: "last"},
+		{query: `absent(test_requests_total{instance="d"})`, call: functionCall{Name: "absent"}, aggregation: "count"},
+		{query: `absent(test_requests_total{instance="a"})`, call: functionCall{Name: "absent"}, aggregation: "count"},
+	}
+	for _, tc := range testCases {
+		for _, queryType := range []model.QueryType{model.Instant, model.Range} {
+			t.Run(fmt.Sprintf("%s/%d", tc.query, queryType), func(t *testing.T) {
+				f := QueryPushdownCall[tc.call.Name]
+				if f == nil {
+					t.Fatalf("function %s is not pushed down", tc.call.Name)
+				}
+				if _, ok := pushdownSeriesAggregations[tc.aggregation]; !ok {
+					t.Fatalf("unexpected aggregation %s", tc.aggregation)
+				}
+				lookback := 5 * time.Minute
+				hint := &QueryHint{start: end.Add(-lookback).UnixMilli(), end: end.UnixMilli(), funcs: []functionCall{tc.call}}
+				if queryType == model.Range {
+					hint.start, hint.step = start.Add(-lookback).UnixMilli(), step.Milliseconds()
+				}
+				if tc.call.Range > 0 {
+					hint.start += (lookback - tc.call.Range).Milliseconds()
+				}
+				reader := &prometheusReader{lookbackDelta: lookback}
+				q := reader.newPushdownQuery(hint, queryType, f)
+				if q == nil {
+					t.Fatalf("query %s is not pushed down", tc.query)
+				}
+				q.labels = tc.call.Grouping
+				sql := q.toSQL(f)
+				if !strings.Contains(sql, f.series(q.param)) {
+					t.Fatalf("unexpected sql: %s", sql)
+				}
+				expr, err := parser.ParseExpr(tc.query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var matchers []*labels.Matcher
+				parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+					if vs, ok := node.(*parser.VectorSelector); ok {
+						matchers = vs.LabelMatchers
+					}
+					return nil
+				})
+
+				eval := func(queryable storage.Queryable, querier *pushdownQuerier) promql.Matrix {
+					var qry promql.Query
+					var err error
+					if queryType == model.Range {
+						qry, err = test.QueryEngine().NewRangeQuery(queryable, nil, tc.query, start, end, step)
+					} else {
+						qry, err = test.QueryEngine().NewInstantQuery(queryable, nil, tc.query, end)
+					}
+					if err != nil {
+						t.Fatal(err)
+					}
+					defer qry.Close()
+					if querier != nil {
+						querier.query = qry
+						defer querier.querierable.restoreFunctionAfterQueryFinished()
+					}
+					res := qry.Exec(test.Context())
+					if res.Err != nil {
+						t.Fatal(res.Err)
+					}
+					// the points are put back to the pool when the query is closed, so copy them here
+					m := promql.Matrix{}
+					switch v := res.Value.(type) {
+					case promql.Matrix:
+						for _, s := range v {
+							m = append(m, promql.Series{Metric: s.Metric, Points: append([]promql.Point{}, s.Points...)})
+						}
+					case promql.Vector:
+						for _, s := range v {
+							m = append(m, promql.Series{Metric: s.Metric, Points: []promql.Point{s.Point}})
+						}
+						sort.Sort(m)
+					default:
+						t.Fatalf("unexpected result type %s", res.Value.Type())
+					}
+					return m
+				}
+
+				querier := &pushdownQuerier{
+					Querier: storage.NoopQuerier(),
+					querierable: &OffloadQuerierable{
+						cachedQueryExprs:  make(map[parser.Expr]func(parser.Expr)),
+						pushdownSelectors: make(map[*labels.Matcher]string),
+					},
+					queryType: queryType,
+					function:  tc.call.Name,
+					result:    pushdownSQL(t, test.Queryable(), q, f, tc.call.Name, tc.aggregation, matchers),
+					pushdown:  q,
+					reader:    reader,
+				}
+				queryable := storage.QueryableFunc(func(context.Context, int64, int64) (storage.Querier, error) {
+					return querier, nil
+				})
+				expected, actual := eval(test.Queryable(), nil), eval(queryable, querier)
+				if len(expected) == 0 && !strings.HasPrefix(tc.query, "absent") {
+					t.Fatalf("query %s has no result", tc.query)
+				}
+				comparePushdownResult(t, expected, actual)
+			})
+		}
+	}
+}
@@ -19,6 +19,7 @@ package service
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/deepflowio/deepflow/server/querier/app/prometheus/model"
@@ -36,12 +37,22 @@ const (
 )
 
 func (p *prometheusExecutor) getTagValues(ctx context.Context, args *model.PromMetaParams) (result *model.PromQueryResponse, err error) {
-	if args.LabelName == LABEL_NAME_METRICS {
-		return &model.PromQueryResponse{
-			Data: getMetrics(ctx, args),
-		}, nil
+	var values []string
+	if len(args.Matchers) > 0 {
+		values, err = p.getSeriesLabelValues(ctx, args)
+	} else if args.LabelName == LABEL_NAME_METRICS {
+		values = getMetrics(ctx, args)
+		sort.Strings(values)
+	} else {
+		values, err = getLabelValues(ctx, args)
 	}
-	return result, err
+	if err != nil {
+		return nil, err
+	}
+	if args.Limit > 0 && len(values) > args.Limit {
+		values = values[:args.Limit]
+	}
+	return &model.PromQueryResponse{Data: values, Status: _SUCCESS}, nil
 }
 
 func getMetrics(ctx context.Context, args *model.PromMetaParams) (resp []string) {
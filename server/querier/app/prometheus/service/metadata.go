@@ -0,0 +1,380 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/deepflowio/deepflow/server/querier/app/prometheus/model"
+	"github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/config"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/client"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/trans_prometheus"
+)
+
+const (
+	// version of the Prometheus library embedded in the querier, '/api/v1/status/buildinfo' reports it so that
+	// clients like Grafana enable the features of this version
+	PROMETHEUS_COMPATIBLE_VERSION = "2.36.2"
+
+	METRIC_TYPE_COUNTER   = "counter"
+	METRIC_TYPE_HISTOGRAM = "histogram"
+	METRIC_TYPE_UNKNOWN   = "unknown"
+
+	TARGET_HEALTH_UNKNOWN = "unknown"
+
+	// the default flow-tag-cache-flush-timeout of the ingester, unit: s
+	FLOW_TAG_CACHE_FLUSH_TIMEOUT = 1800
+	// the time range of label values selected by match[] if start is not specified, the same as the default
+	// lookback delta of Prometheus
+	LABEL_VALUES_DEFAULT_RANGE = 5 * time.Minute
+)
+
+var serverBranch, serverRevision, serverCompileTime string
+
+// SetBuildInfo sets the version information of deepflow-server reported by '/api/v1/status/buildinfo'
+func SetBuildInfo(branch, revision, compileTime string) {
+	serverBranch = branch
+	serverRevision = revision
+	serverCompileTime = compileTime
+}
+
+func getPrometheusMap(orgID string) trans_prometheus.PrometheusMap {
+	if orgID == "" {
+		orgID = common.DEFAULT_ORG_ID
+	}
+	return trans_prometheus.ORGPrometheus[orgID]
+}
+
+func queryFlowTag(ctx context.Context, sql string, orgID string) (*common.Result, error) {
+	chClient := client.Client{
+		Host:     config.Cfg.Clickhouse.Host,
+		Port:     config.Cfg.Clickhouse.Port,
+		UserName: config.Cfg.Clickhouse.User,
+		Password: config.Cfg.Clickhouse.Password,
+		DB:       "flow_tag",
+		Context:  ctx,
+	}
+	return chClient.DoQuery(&client.QueryParams{Sql: sql, ORGID: orgID})
+}
+
+// labelNames returns the label names of the series selected by the matchers, the result is the same as the
+// Prometheus API '/api/v1/labels'. The label names of the metric are the app labels in the label layout
+// cache and the target labels of the metric, all label names are returned if the metric name is not
+// specified in any of the matchers.
+func (p *prometheusExecutor) labelNames(ctx context.Context, args *model.PromQueryParams) (*model.PromQueryResponse, error) {
+	prometheusMap := getPrometheusMap(args.OrgID)
+	names := map[string]struct{}{labels.MetricName: {}}
+
+	metricIDs := []string{}
+	allLabels := len(args.Matchers) == 0
+	for _, s := range args.Matchers {
+		matchers, err := parser.ParseMetricSelector(s)
+		if err != nil {
+			return nil, err
+		}
+		metricName := ""
+		for _, m := range matchers {
+			if m.Name == labels.MetricName && m.Type == labels.MatchEqual {
+				metricName = m.Value
+			}
+		}
+		if metricName == "" {
+			allLabels = true
+			break
+		}
+		metricID, ok := prometheusMap.MetricNameToID[metricName]
+		if !ok {
+			continue
+		}
+		metricIDs = append(metricIDs, strconv.FormatUint(metricID, 10))
+		for _, appLabel := range prometheusMap.MetricAppLabelLayout[metricName] {
+			names[appLabel.AppLabelName] = struct{}{}
+		}
+	}
+
+	if allLabels {
+		for name := range prometheusMap.LabelNameToID {
+			names[name] = struct{}{}
+		}
+	} else if len(metricIDs) > 0 {
+		sql := fmt.Sprintf("SELECT label_name_id FROM flow_tag.target_label_live_view WHERE metric_id IN (%s) GROUP BY label_name_id",
+			strings.Join(metricIDs, ","))
+		result, err := queryFlowTag(ctx, sql, args.OrgID)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range result.Values {
+			row, ok := v.([]interface{})
+			if !ok || len(row) == 0 {
+				continue
+			}
+			labelNameID, _ := row[0].(uint64)
+			if name, ok := prometheusMap.LabelIDToName[labelNameID]; ok {
+				names[name] = struct{}{}
+			}
+		}
+	}
+
+	data := make([]string, 0, len(names))
+	for name := range names {
+		data = append(data, name)
+	}
+	sort.Strings(data)
+	return &model.PromQueryResponse{Data: data, Status: _SUCCESS}, nil
+}
+
+// getLabelValues returns the values of the app label or target label in flow_tag, the result is the same as
+// the Prometheus API '/api/v1/label/<label_name>/values' without match[]. The label values of all time are
+// returned if neither start nor end is specified, otherwise the values seen in the time range are read from
+// `flow_tag.prometheus_custom_field_value`.
+func getLabelValues(ctx context.Context, args *model.PromMetaParams) ([]string, error) {
+	labelNameID, ok := getPrometheusMap(args.OrgID).LabelNameToID[args.LabelName]
+	if !ok {
+		return []string{}, nil
+	}
+	var sql string
+	if args.StartTime == "" && args.EndTime == "" {
+		sql = fmt.Sprintf("SELECT label_value FROM flow_tag.app_label_live_view WHERE label_name_id=%d GROUP BY label_value "+
+			"UNION ALL SELECT label_value FROM flow_tag.target_label_live_view WHERE label_name_id=%d GROUP BY label_value",
+			labelNameID, labelNameID)
+	} else {
+		var start, end time.Time
+		var err error
+		if args.StartTime != "" {
+			if start, err = parseTime(args.StartTime); err != nil {
+				return nil, err
+			}
+		}
+		if args.EndTime != "" {
+			if end, err = parseTime(args.EndTime); err != nil {
+				return nil, err
+			}
+		}
+		sql = labelValuesInRangeSQL(args.LabelName, start, end, args.BlockTeamID)
+	}
+	result, err := queryFlowTag(ctx, sql, args.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]struct{}{}
+	for _, v := range result.Values {
+		row, ok := v.([]interface{})
+		if !ok || len(row) == 0 {
+			continue
+		}
+		if value, _ := row[0].(string); value != "" {
+			values[value] = struct{}{}
+		}
+	}
+	data := make([]string, 0, len(values))
+	for value := range values {
+		data = append(data, value)
+	}
+	sort.Strings(data)
+	return data, nil
+}
+
+// labelValuesInRangeSQL returns the sql to query the values of the label seen between start and end, a zero
+// start or end leaves that side of the range open. The ingester writes a label value into flow_tag again only
+// after the flush timeout of the flow tag cache, so the start is moved forward by that timeout to keep the
+// values which are still alive but last written before the start.
+func labelValuesInRangeSQL(labelName string, start, end time.Time, blockTeamID []string) string {
+	conditions := []string{"field_type='tag'", fmt.Sprintf("field_name='%s'", labelName)}
+	if !start.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("time>=%d", start.Unix()-FLOW_TAG_CACHE_FLUSH_TIMEOUT))
+	}
+	if !end.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("time<=%d", end.Unix()))
+	}
+	if len(blockTeamID) > 0 {
+		conditions = append(conditions, fmt.Sprintf("team_id NOT IN (%s)", strings.Join(blockTeamID, ",")))
+	}
+	return fmt.Sprintf("SELECT field_value FROM flow_tag.prometheus_custom_field_value WHERE %s GROUP BY field_value",
+		strings.Join(conditions, " AND "))
+}
+
+// getSeriesLabelValues returns the values of the label in the series selected by match[] between start and
+// end, the result is the same as the Prometheus API '/api/v1/label/<label_name>/values' with match[]. The
+// time range defaults to the last LABEL_VALUES_DEFAULT_RANGE because the series are read from the samples.
+func (p *prometheusExecutor) getSeriesLabelValues(ctx context.Context, args *model.PromMetaParams) ([]string, error) {
+	end := time.Now()
+	if args.EndTime != "" {
+		t, err := parseTime(args.EndTime)
+		if err != nil {
+			return nil, err
+		}
+		end = t
+	}
+	start := end.Add(-LABEL_VALUES_DEFAULT_RANGE)
+	if args.StartTime != "" {
+		t, err := parseTime(args.StartTime)
+		if err != nil {
+			return nil, err
+		}
+		start = t
+	}
+	// the tags of the series are required to read the label values
+	ctx = context.WithValue(ctx, CtxKeyShowTag{}, true)
+	result, err := p.series(ctx, &model.PromQueryParams{
+		StartTime:   formatTime(start),
+		EndTime:     formatTime(end),
+		Matchers:    args.Matchers,
+		OrgID:       args.OrgID,
+		BlockTeamID: args.BlockTeamID,
+		Context:     ctx,
+	})
+	if err != nil {
+		return nil, err
+	}
+	series, _ := result.Data.([]labels.Labels)
+	return seriesLabelValues(series, args.LabelName), nil
+}
+
+// seriesLabelValues returns the sorted distinct values of the label in the series
+func seriesLabelValues(series []labels.Labels, labelName string) []string {
+	values := map[string]struct{}{}
+	for _, lbs := range series {
+		if value := lbs.Get(labelName); value != "" {
+			values[value] = struct{}{}
+		}
+	}
+	data := make([]string, 0, len(values))
+	for value := range values {
+		data = append(data, value)
+	}
+	sort.Strings(data)
+	return data
+}
+
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixMilli())/1e3, 'f', -1, 64)
+}
+
+// metricType infers the type of the metric by the naming conventions, because the metadata of metrics is
+// not stored by the ingester
+func metricType(metricName string) string {
+	switch {
+	case strings.HasSuffix(metricName, "_total"):
+		return METRIC_TYPE_COUNTER
+	case strings.HasSuffix(metricName, "_bucket"):
+		return METRIC_TYPE_HISTOGRAM
+	default:
+		return METRIC_TYPE_UNKNOWN
+	}
+}
+
+// metadata returns the metadata of the metrics in the metric name cache, the result is the same as the
+// Prometheus API '/api/v1/metadata'
+func (p *prometheusExecutor) metadata(orgID string, metric string, limit int) (*model.PromQueryResponse, error) {
+	metricNames := []string{}
+	for name := range getPrometheusMap(orgID).MetricNameToID {
+		if metric == "" || name == metric {
+			metricNames = append(metricNames, name)
+		}
+	}
+	sort.Strings(metricNames)
+	if limit > 0 && len(metricNames) > limit {
+		metricNames = metricNames[:limit]
+	}
+	data := make(map[string][]model.PromMetricMetadata, len(metricNames))
+	for _, name := range metricNames {
+		data[name] = []model.PromMetricMetadata{{Type: metricType(name)}}
+	}
+	return &model.PromQueryResponse{Data: data, Status: _SUCCESS}, nil
+}
+
+// formatQuery returns the query formatted by the PromQL printer, the result is the same as the Prometheus
+// API '/api/v1/format_query'
+func (p *prometheusExecutor) formatQuery(query string) (*model.PromQueryResponse, error) {
+	expr, err := parser.ParseExpr(query)
+	if err != nil {
+		return nil, err
+	}
+	return &model.PromQueryResponse{Data: expr.String(), Status: _SUCCESS}, nil
+}
+
+func (p *prometheusExecutor) buildInfo() *model.PromQueryResponse {
+	return &model.PromQueryResponse{
+		Data: &model.PromBuildInfo{
+			Version:   PROMETHEUS_COMPATIBLE_VERSION,
+			Revision:  serverRevision,
+			Branch:    serverBranch,
+			BuildUser: "deepflow",
+			BuildDate: serverCompileTime,
+			GoVersion: runtime.Version(),
+		},
+		Status: _SUCCESS,
+	}
+}
+
+// targets returns the targets in flow_tag.prometheus_target_label_layout_map, the result is the same as the
+// Prometheus API '/api/v1/targets'. The targets are scraped by deepflow-agent or other Prometheus servers, so
+// the scrape state is unknown and there are no dropped targets.
+func (p *prometheusExecutor) targets(ctx context.Context, orgID string, state string) (*model.PromQueryResponse, error) {
+	data := &model.PromTargetDiscovery{
+		ActiveTargets:  []*model.PromTarget{},
+		DroppedTargets: []*model.PromDroppedTarget{},
+	}
+	if state != "" && state != "any" && state != "active" {
+		return &model.PromQueryResponse{Data: data, Status: _SUCCESS}, nil
+	}
+	sql := "SELECT target_id, target_label_names, target_label_values FROM flow_tag.prometheus_target_label_layout_map ORDER BY target_id"
+	result, err := queryFlowTag(ctx, sql, orgID)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range result.Values {
+		row, ok := v.([]interface{})
+		if !ok || len(row) < 3 {
+			continue
+		}
+		names, _ := row[1].(string)
+		values, _ := row[2].(string)
+		data.ActiveTargets = append(data.ActiveTargets, newPromTarget(names, values))
+	}
+	return &model.PromQueryResponse{Data: data, Status: _SUCCESS}, nil
+}
+
+// newPromTarget returns the target of the label names and values joined by ', ' in the target label layout
+func newPromTarget(names, values string) *model.PromTarget {
+	targetLabels := map[string]string{}
+	if names != "" {
+		nameList := strings.Split(names, ", ")
+		valueList := strings.Split(values, ", ")
+		for i, name := range nameList {
+			if i < len(valueList) {
+				targetLabels[name] = valueList[i]
+			}
+		}
+	}
+	return &model.PromTarget{
+		DiscoveredLabels: map[string]string{},
+		Labels:           targetLabels,
+		ScrapePool:       targetLabels["job"],
+		Health:           TARGET_HEALTH_UNKNOWN,
+	}
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deepflowio/deepflow/server/querier/app/prometheus/model"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/trans_prometheus"
+)
+
+func TestMetadata(t *testing.T) {
+	trans_prometheus.ORGPrometheus["1"] = trans_prometheus.PrometheusMap{
+		MetricNameToID: map[string]uint64{
+			"http_requests_total":                  1,
+			"http_request_duration_seconds_bucket": 2,
+			"node_load1":                           3,
+		},
+		LabelNameToID: map[string]uint64{"job": 1, "instance": 2, "method": 3},
+	}
+	defer delete(trans_prometheus.ORGPrometheus, "1")
+	p := &prometheusExecutor{}
+
+	result, err := p.metadata("", "", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]model.PromMetricMetadata{
+		"http_requests_total":                  {{Type: METRIC_TYPE_COUNTER}},
+		"http_request_duration_seconds_bucket": {{Type: METRIC_TYPE_HISTOGRAM}},
+		"node_load1":                           {{Type: METRIC_TYPE_UNKNOWN}},
+	}, result.Data)
+
+	result, err = p.metadata("1", "", 1)
+	assert.Nil(t, err)
+	assert.Len(t, result.Data, 1)
+
+	result, err = p.metadata("1", "node_load1", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]model.PromMetricMetadata{"node_load1": {{Type: METRIC_TYPE_UNKNOWN}}}, result.Data)
+
+	result, err = p.labelNames(context.Background(), &model.PromQueryParams{OrgID: "1"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"__name__", "instance", "job", "method"}, result.Data)
+
+	// the selector without metric name matches all label names
+	result, err = p.labelNames(context.Background(), &model.PromQueryParams{OrgID: "1", Matchers: []string{`{job="node"}`}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"__name__", "instance", "job", "method"}, result.Data)
+
+	// the metric is not in the cache
+	result, err = p.labelNames(context.Background(), &model.PromQueryParams{OrgID: "1", Matchers: []string{`unknown_metric`}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"__name__"}, result.Data)
+}
+
+func TestLabelValues(t *testing.T) {
+	series := []labels.Labels{
+		labels.FromStrings("__name__", "http_requests_total", "job", "node", "method", "GET"),
+		labels.FromStrings("__name__", "http_requests_total", "job", "api", "method", "GET"),
+		labels.FromStrings("__name__", "node_load1", "job", "node"),
+	}
+	assert.Equal(t, []string{"api", "node"}, seriesLabelValues(series, "job"))
+	assert.Equal(t, []string{"GET"}, seriesLabelValues(series, "method"))
+	assert.Equal(t, []string{"http_requests_total", "node_load1"}, seriesLabelValues(series, "__name__"))
+	assert.Equal(t, []string{}, seriesLabelValues(series, "instance"))
+
+	start, end := time.Unix(1700000000, 0), time.Unix(1700003600, 0)
+	assert.Equal(t,
+		"SELECT field_value FROM flow_tag.prometheus_custom_field_value WHERE field_type='tag' AND field_name='job' "+
+			"AND time>=1699998200 AND time<=1700003600 AND team_id NOT IN (2,3) GROUP BY field_value",
+		labelValuesInRangeSQL("job", start, end, []string{"2", "3"}))
+	assert.Equal(t,
+		"SELECT field_value FROM flow_tag.prometheus_custom_field_value WHERE field_type='tag' AND field_name='job' "+
+			"AND time<=1700003600 GROUP BY field_value",
+		labelValuesInRangeSQL("job", time.Time{}, end, nil))
+
+	assert.Equal(t, "1700000000.5", formatTime(time.UnixMilli(1700000000500)))
+}
+
+func TestFormatQuery(t *testing.T) {
+	p := &prometheusExecutor{}
+	result, err := p.formatQuery(`sum  by(job)(rate(http_requests_total{method = "GET"}[5m] ))`)
+	assert.Nil(t, err)
+	assert.Equal(t, `sum by(job) (rate(http_requests_total{method="GET"}[5m]))`, result.Data)
+
+	_, err = p.formatQuery(`sum(`)
+	assert.NotNil(t, err)
+}
+
+func TestNewPromTarget(t *testing.T) {
+	target := newPromTarget("instance, job", "10.0.0.1:9100, node")
+	assert.Equal(t, map[string]string{"instance": "10.0.0.1:9100", "job": "node"}, target.Labels)
+	assert.Equal(t, "node", target.ScrapePool)
+	assert.Equal(t, TARGET_HEALTH_UNKNOWN, target.Health)
+
+	target = newPromTarget("", "")
+	assert.Equal(t, map[string]string{}, target.Labels)
+}
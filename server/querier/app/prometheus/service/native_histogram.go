@@ -0,0 +1,314 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/storage/remote"
+
+	"github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/config"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/client"
+	chCommon "github.com/deepflowio/deepflow/server/querier/engine/clickhouse/common"
+)
+
+const (
+	PROMETHEUS_HISTOGRAM_TABLE = "histograms"
+
+	HISTOGRAM_BUCKET_SUFFIX = "_bucket"
+	HISTOGRAM_COUNT_SUFFIX  = "_count"
+	HISTOGRAM_SUM_SUFFIX    = "_sum"
+)
+
+// nativeHistogram is a row of prometheus.histograms, the counts of the buckets are absolute
+type nativeHistogram struct {
+	timestamp     int64 // ms
+	count         float64
+	sum           float64
+	schema        int32
+	zeroThreshold float64
+	zeroCount     float64
+
+	positiveSpanOffsets []int32
+	positiveSpanLengths []uint32
+	positiveCounts      []float64
+	negativeSpanOffsets []int32
+	negativeSpanLengths []uint32
+	negativeCounts      []float64
+}
+
+// histogramBucket is a bucket of the native histogram with its upper bound
+type histogramBucket struct {
+	upper float64
+	count float64
+}
+
+// nativeHistogramMetric returns the metric name of the native histograms which are expanded into the series of
+// metricName, that is, metricName is '<name>_bucket', '<name>_count' or '<name>_sum' and '<name>' exists.
+func nativeHistogramMetric(orgID, metricName string) (string, uint64, bool) {
+	for _, suffix := range []string{HISTOGRAM_BUCKET_SUFFIX, HISTOGRAM_COUNT_SUFFIX, HISTOGRAM_SUM_SUFFIX} {
+		if name, ok := strings.CutSuffix(metricName, suffix); ok && name != "" {
+			metricID, ok := prometheusOrgMap(orgID).MetricNameToID[name]
+			return name, metricID, ok
+		}
+	}
+	return "", 0, false
+}
+
+// nativeHistogramSeries returns the classic histogram series expanded from the native histograms, the series
+// are nil if the metric of the query is not a native histogram. The histograms are queried from
+// prometheus.histograms in the time range [start, end] in seconds.
+func (p *prometheusReader) nativeHistogramSeries(ctx context.Context, query *prompb.Query, metricName string, start, end int64) ([]*prompb.TimeSeries, error) {
+	histogramName, metricID, ok := nativeHistogramMetric(p.orgID, metricName)
+	// the extra filters are on the universal tags which are not stored with the histograms
+	if !ok || p.extraFilters != "" {
+		return nil, nil
+	}
+	matchers, err := remote.FromLabelMatchers(query.Matchers)
+	if err != nil {
+		return nil, err
+	}
+	// the 'le' matchers are applied after the expansion
+	seriesMatchers := make([]*labels.Matcher, 0, len(matchers))
+	for _, m := range matchers {
+		if m.Name == labels.MetricName && !m.Matches(metricName) {
+			return nil, nil
+		} else if m.Name == labels.BucketLabel {
+			continue
+		}
+		seriesMatchers = append(seriesMatchers, m)
+	}
+	prometheusMap := prometheusOrgMap(p.orgID)
+	filters, ok := seriesFilters(prometheusMap, histogramName, metricID, seriesMatchers, p.blockTeamID)
+	if !ok {
+		return nil, nil
+	}
+	filters = append(filters, fmt.Sprintf("time>=%d", start), fmt.Sprintf("time<=%d", end))
+	sql := fmt.Sprintf("SELECT %s AS series_labels, toUnixTimestamp(time) AS sample_time, "+
+		"`count`, `sum`, `schema`, zero_threshold, zero_count, "+
+		"positive_span_offsets, positive_span_lengths, positive_counts, negative_span_offsets, negative_span_lengths, negative_counts "+
+		"FROM %s.`%s` WHERE %s ORDER BY sample_time LIMIT %s",
+		seriesLabelsExpr(prometheusMap, histogramName),
+		prometheusDatabase(p.orgID), PROMETHEUS_HISTOGRAM_TABLE, strings.Join(filters, " AND "), config.Cfg.Prometheus.Limit)
+
+	chClient := client.Client{
+		Host:     config.Cfg.Clickhouse.Host,
+		Port:     config.Cfg.Clickhouse.Port,
+		UserName: config.Cfg.Clickhouse.User,
+		Password: config.Cfg.Clickhouse.Password,
+		DB:       chCommon.DB_NAME_PROMETHEUS,
+		Context:  ctx,
+	}
+	result, err := chClient.DoQuery(&client.QueryParams{Sql: sql, ORGID: p.orgID})
+	if err != nil {
+		return nil, err
+	}
+	return expandNativeHistograms(histogramName, metricName, matchers, result), nil
+}
+
+// expandNativeHistograms groups the rows of prometheus.histograms by series, and expands the histograms of each
+// series into the series of metricName, which is one of '<name>_bucket', '<name>_count' and '<name>_sum'.
+func expandNativeHistograms(histogramName, metricName string, matchers []*labels.Matcher, result *common.Result) []*prompb.TimeSeries {
+	if result == nil {
+		return nil
+	}
+	seriesKeys := []string{}
+	seriesHistograms := map[string][]*nativeHistogram{}
+	for _, v := range result.Values {
+		row, ok := v.([]interface{})
+		if !ok || len(row) < 13 {
+			continue
+		}
+		seriesKey, _ := row[0].(string)
+		if _, ok := seriesHistograms[seriesKey]; !ok {
+			seriesKeys = append(seriesKeys, seriesKey)
+		}
+		h := &nativeHistogram{}
+		if t, ok := row[1].(uint32); ok {
+			h.timestamp = int64(t) * 1000
+		}
+		h.count, _ = row[2].(float64)
+		h.sum, _ = row[3].(float64)
+		h.schema, _ = row[4].(int32)
+		h.zeroThreshold, _ = row[5].(float64)
+		h.zeroCount, _ = row[6].(float64)
+		h.positiveSpanOffsets, _ = row[7].([]int32)
+		h.positiveSpanLengths, _ = row[8].([]uint32)
+		h.positiveCounts, _ = row[9].([]float64)
+		h.negativeSpanOffsets, _ = row[10].([]int32)
+		h.negativeSpanLengths, _ = row[11].([]uint32)
+		h.negativeCounts, _ = row[12].([]float64)
+		seriesHistograms[seriesKey] = append(seriesHistograms[seriesKey], h)
+	}
+
+	series := []*prompb.TimeSeries{}
+	for _, seriesKey := range seriesKeys {
+		seriesLabels := map[string]string{}
+		json.Unmarshal([]byte(seriesKey), &seriesLabels)
+		seriesLabels[labels.MetricName] = metricName
+		histograms := seriesHistograms[seriesKey]
+		values := make([]float64, len(histograms))
+		switch metricName {
+		case histogramName + HISTOGRAM_COUNT_SUFFIX:
+			for i, h := range histograms {
+				values[i] = h.count
+			}
+			series = appendHistogramSeries(series, seriesLabels, "", matchers, histograms, values)
+		case histogramName + HISTOGRAM_SUM_SUFFIX:
+			for i, h := range histograms {
+				values[i] = h.sum
+			}
+			series = appendHistogramSeries(series, seriesLabels, "", matchers, histograms, values)
+		default:
+			series = appendHistogramBucketSeries(series, seriesLabels, matchers, histograms)
+		}
+	}
+	return series
+}
+
+// appendHistogramBucketSeries appends the '<name>_bucket' series of a native histogram series. The 'le' labels
+// are the union of the upper bounds of all buckets in the histograms, the value of each 'le' is the cumulative
+// count of the buckets whose upper bounds are not greater than it. The counts are exact if the schema and the
+// zero threshold of the histograms are not changed, otherwise the buckets of different schemas are not aligned,
+// the count of a wider bucket is accounted to the 'le' of its upper bound only.
+func appendHistogramBucketSeries(series []*prompb.TimeSeries, seriesLabels map[string]string, matchers []*labels.Matcher, histograms []*nativeHistogram) []*prompb.TimeSeries {
+	histogramBuckets := make([][]histogramBucket, len(histograms))
+	bounds := []float64{math.Inf(1)}
+	boundSet := map[float64]struct{}{math.Inf(1): {}}
+	for i, h := range histograms {
+		histogramBuckets[i] = h.buckets()
+		for _, b := range histogramBuckets[i] {
+			if _, ok := boundSet[b.upper]; !ok {
+				boundSet[b.upper] = struct{}{}
+				bounds = append(bounds, b.upper)
+			}
+		}
+	}
+	sort.Float64s(bounds)
+
+	// counts[j][i] is the cumulative count of the histogram i at the bound j
+	counts := make([][]float64, len(bounds))
+	for j := range bounds {
+		counts[j] = make([]float64, len(histograms))
+	}
+	for i, h := range histograms {
+		buckets := histogramBuckets[i]
+		sort.Slice(buckets, func(x, y int) bool { return buckets[x].upper < buckets[y].upper })
+		var cumulative float64
+		k := 0
+		for j, bound := range bounds {
+			for ; k < len(buckets) && buckets[k].upper <= bound; k++ {
+				cumulative += buckets[k].count
+			}
+			counts[j][i] = cumulative
+		}
+		// the '+Inf' bucket is the count of the histogram, including the observations out of the buckets
+		counts[len(bounds)-1][i] = h.count
+	}
+	for j, bound := range bounds {
+		series = appendHistogramSeries(series, seriesLabels, formatUpperBound(bound), matchers, histograms, counts[j])
+	}
+	return series
+}
+
+// appendHistogramSeries appends a series whose sample values are the values of each histogram, the series is not
+// appended if it is not matched by the 'le' matchers
+func appendHistogramSeries(series []*prompb.TimeSeries, seriesLabels map[string]string, le string, matchers []*labels.Matcher,
+	histograms []*nativeHistogram, values []float64) []*prompb.TimeSeries {
+	for _, m := range matchers {
+		if m.Name == labels.BucketLabel && !m.Matches(le) {
+			return series
+		}
+	}
+	ts := &prompb.TimeSeries{
+		Labels:  make([]prompb.Label, 0, len(seriesLabels)+1),
+		Samples: make([]prompb.Sample, 0, len(histograms)),
+	}
+	for name, value := range seriesLabels {
+		if value != "" && name != labels.BucketLabel {
+			ts.Labels = append(ts.Labels, prompb.Label{Name: name, Value: value})
+		}
+	}
+	if le != "" {
+		ts.Labels = append(ts.Labels, prompb.Label{Name: labels.BucketLabel, Value: le})
+	}
+	sort.Slice(ts.Labels, func(i, j int) bool { return ts.Labels[i].Name < ts.Labels[j].Name })
+	for i, h := range histograms {
+		ts.Samples = append(ts.Samples, prompb.Sample{Timestamp: h.timestamp, Value: values[i]})
+	}
+	return append(series, ts)
+}
+
+// buckets returns the buckets of the histogram with their upper bounds, the upper bound of the positive bucket
+// index i is 2^(i*2^-schema), the negative bucket index i is the mirror of the positive one, its upper bound is
+// -2^((i-1)*2^-schema), and the upper bound of the zero bucket is the zero threshold.
+func (h *nativeHistogram) buckets() []histogramBucket {
+	buckets := make([]histogramBucket, 0, len(h.positiveCounts)+len(h.negativeCounts)+1)
+	spanBuckets(h.negativeSpanOffsets, h.negativeSpanLengths, h.negativeCounts, func(index int32, count float64) {
+		buckets = append(buckets, histogramBucket{upper: -bucketBound(index-1, h.schema), count: count})
+	})
+	buckets = append(buckets, histogramBucket{upper: h.zeroThreshold, count: h.zeroCount})
+	spanBuckets(h.positiveSpanOffsets, h.positiveSpanLengths, h.positiveCounts, func(index int32, count float64) {
+		buckets = append(buckets, histogramBucket{upper: bucketBound(index, h.schema), count: count})
+	})
+	return buckets
+}
+
+// spanBuckets calls f with the index and the count of every bucket in the spans
+func spanBuckets(offsets []int32, lengths []uint32, counts []float64, f func(index int32, count float64)) {
+	var index int32
+	n := 0
+	for i, offset := range offsets {
+		if i >= len(lengths) {
+			return
+		}
+		if i == 0 {
+			index = offset
+		} else {
+			index += offset
+		}
+		for j := uint32(0); j < lengths[i]; j++ {
+			if n >= len(counts) {
+				return
+			}
+			f(index, counts[n])
+			index++
+			n++
+		}
+	}
+}
+
+// bucketBound returns 2^(index*2^-schema)
+func bucketBound(index int32, schema int32) float64 {
+	if schema <= 0 {
+		return math.Ldexp(1, int(index)<<uint(-schema))
+	}
+	return math.Exp2(float64(index) / float64(int64(1)<<uint(schema)))
+}
+
+func formatUpperBound(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/prompb"
+
+	"github.com/deepflowio/deepflow/server/querier/common"
+)
+
+func TestBucketBound(t *testing.T) {
+	for _, c := range []struct {
+		index    int32
+		schema   int32
+		expected float64
+	}{
+		{0, 0, 1},
+		{3, 0, 8},
+		{-2, 0, 0.25},
+		{1, -1, 4},
+		{-1, -2, 1.0 / 16},
+		{2, 1, 2},
+		{1, 1, math.Sqrt2},
+		{4, 3, math.Sqrt2},
+	} {
+		if bound := bucketBound(c.index, c.schema); math.Abs(bound-c.expected) > 1e-12 {
+			t.Errorf("bound of index %d schema %d is %v, expected %v", c.index, c.schema, bound, c.expected)
+		}
+	}
+}
+
+func TestExpandNativeHistograms(t *testing.T) {
+	seriesLabels := `{"__name__":"latency","job":"app","instance":""}`
+	result := &common.Result{
+		Values: []interface{}{
+			// schema 0, the buckets are [-2,-1), [-0.001,0.001], (0.5,1], (1,2], (2,4], (8,16]
+			[]interface{}{seriesLabels, uint32(10), float64(12), float64(30), int32(0), float64(0.001), float64(1),
+				[]int32{0, 1}, []uint32{3, 1}, []float64{2, 3, 1, 4}, []int32{1}, []uint32{1}, []float64{1}},
+			// the bucket (4,8] is populated, the others are the same
+			[]interface{}{seriesLabels, uint32(20), float64(14), float64(40), int32(0), float64(0.001), float64(1),
+				[]int32{0}, []uint32{4}, []float64{2, 3, 1, 2}, []int32{1}, []uint32{1}, []float64{1}},
+		},
+	}
+
+	values := func(series []*prompb.TimeSeries) map[string][]float64 {
+		m := map[string][]float64{}
+		for _, s := range series {
+			name := ""
+			for _, l := range s.Labels {
+				if l.Name == labels.MetricName {
+					name = l.Value + name
+				} else if l.Name == labels.BucketLabel {
+					name += "{le=" + l.Value + "}"
+				} else if l.Name != "job" || l.Value != "app" {
+					t.Errorf("unexpected label %s=%s", l.Name, l.Value)
+				}
+			}
+			for _, sample := range s.Samples {
+				m[name] = append(m[name], sample.Value)
+			}
+		}
+		return m
+	}
+
+	series := expandNativeHistograms("latency", "latency_bucket", nil, result)
+	expected := map[string][]float64{
+		"latency_bucket{le=-1}":    {1, 1},
+		"latency_bucket{le=0.001}": {2, 2},
+		"latency_bucket{le=1}":     {4, 4},
+		"latency_bucket{le=2}":     {7, 7},
+		"latency_bucket{le=4}":     {8, 8},
+		"latency_bucket{le=8}":     {8, 10},
+		"latency_bucket{le=16}":    {12, 10},
+		"latency_bucket{le=+Inf}":  {12, 14},
+	}
+	if v := values(series); !reflect.DeepEqual(v, expected) {
+		t.Errorf("bucket series are %v, expected %v", v, expected)
+	}
+	if series[0].Samples[0].Timestamp != 10000 || series[0].Samples[1].Timestamp != 20000 {
+		t.Errorf("unexpected timestamps %v", series[0].Samples)
+	}
+
+	matchers := []*labels.Matcher{labels.MustNewMatcher(labels.MatchRegexp, labels.BucketLabel, "1|\\+Inf")}
+	series = expandNativeHistograms("latency", "latency_bucket", matchers, result)
+	expected = map[string][]float64{"latency_bucket{le=1}": {4, 4}, "latency_bucket{le=+Inf}": {12, 14}}
+	if v := values(series); !reflect.DeepEqual(v, expected) {
+		t.Errorf("matched bucket series are %v, expected %v", v, expected)
+	}
+
+	series = expandNativeHistograms("latency", "latency_count", nil, result)
+	if v := values(series); !reflect.DeepEqual(v, map[string][]float64{"latency_count": {12, 14}}) {
+		t.Errorf("count series are %v", v)
+	}
+	series = expandNativeHistograms("latency", "latency_sum", nil, result)
+	if v := values(series); !reflect.DeepEqual(v, map[string][]float64{"latency_sum": {30, 40}}) {
+		t.Errorf("sum series are %v", v)
+	}
+	// the series without the 'le' label do not match the 'le' matcher
+	series = expandNativeHistograms("latency", "latency_count", matchers, result)
+	if len(series) != 0 {
+		t.Errorf("count series %v should not match %v", series, matchers)
+	}
+}
@@ -269,6 +269,7 @@ func (p *prometheusExecutor) offloadRangeQueryExecute(ctx context.Context, args
 		orgID:                   args.OrgID,
 		blockTeamID:             args.BlockTeamID,
 		extraFilters:            args.ExtraFilters,
+		lookbackDelta:           p.lookbackDelta,
 		getExternalTagFromCache: p.convertExternalTagToQuerierAllowTag,
 		addExternalTagToCache:   p.addExtraLabelsToCache,
 	}
@@ -403,6 +404,7 @@ func (p *prometheusExecutor) offloadInstantQueryExecute(ctx context.Context, arg
 		orgID:                   args.OrgID,
 		blockTeamID:             args.BlockTeamID,
 		extraFilters:            args.ExtraFilters,
+		lookbackDelta:           p.lookbackDelta,
 		getExternalTagFromCache: p.convertExternalTagToQuerierAllowTag,
 		addExternalTagToCache:   p.addExtraLabelsToCache,
 	}
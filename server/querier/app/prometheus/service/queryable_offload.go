@@ -18,6 +18,7 @@ package service
 
 import (
 	"context"
+	"math"
 
 	"github.com/deepflowio/deepflow/server/querier/app/prometheus/model"
 	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/common"
@@ -40,6 +41,8 @@ type OffloadQuerierable struct {
 	queryRequest      []model.QueryRequest
 	mapToQueryRequest map[string]model.QueryRequest
 	cachedQueryExprs  map[parser.Expr]func(parser.Expr)
+	// the selectors of pushed down functions, identified by the first label matcher, see `QueryPushdownCall`
+	pushdownSelectors map[*labels.Matcher]string
 }
 
 type OffloadQuerierableOpts func(*OffloadQuerierable)
@@ -60,6 +63,7 @@ func NewOffloadQueriable(args *model.PromQueryParams, opts ...OffloadQuerierable
 		}
 
 		o.cachedQueryExprs = make(map[parser.Expr]func(parser.Expr))
+		o.pushdownSelectors = make(map[*labels.Matcher]string)
 	}
 
 	o.querier = &OffloadQuerier{
@@ -133,7 +137,13 @@ func (o *OffloadQuerierable) changeFunctionAfterOffloadSelected(stmt *parser.Eva
 		case *parser.AggregateExpr:
 			switch n.Op {
 			case parser.COUNT:
-				if !n.Without {
+				// count of the pushed down function results is not offloaded
+				if !n.Without && !o.isPushdownExpr(n.Expr) {
+					o.cachedQueryExprs[n] = parseAggToSum(n, n.Op, parser.SUM)
+				}
+			case parser.STDDEV, parser.STDVAR:
+				// already calculated for each group
+				if o.pushdownSelector(n.Expr, n.Op.String()) != nil {
 					o.cachedQueryExprs[n] = parseAggToSum(n, n.Op, parser.SUM)
 				}
 			}
@@ -145,6 +155,14 @@ func (o *OffloadQuerierable) changeFunctionAfterOffloadSelected(stmt *parser.Eva
 				if queryType == model.Instant {
 					o.cachedQueryExprs[n] = parseCallToLastOverTime(n, n.Func.Name, "last_over_time")
 				}
+			case "idelta", "delta", "deriv", "predict_linear", "changes", "resets":
+				if vs := o.pushdownSelector(n.Args[0], n.Func.Name); vs != nil {
+					o.cachedQueryExprs[n] = parseCallToClampMin(n, vs)
+				}
+			case "histogram_quantile":
+				if vs := o.pushdownSelector(n.Args[1], n.Func.Name); vs != nil {
+					o.cachedQueryExprs[n] = parseCallToClampMin(n, vs)
+				}
 			}
 		}
 		return nil
@@ -170,6 +188,54 @@ func parseCallToLastOverTime(n *parser.Call, oriFunc string, afterFunc string) f
 	}
 }
 
+// the pushed down function is already calculated in database, use `clamp_min(<VectorSelector>, -Inf)` to output the results,
+// it drops the metric name as the original function
+func parseCallToClampMin(n *parser.Call, vs *parser.VectorSelector) func(parser.Expr) {
+	oriFunc, oriArgs := n.Func, n.Args
+	n.Func = parser.Functions["clamp_min"]
+	n.Args = parser.Expressions{vs, &parser.NumberLiteral{Val: math.Inf(-1)}}
+	return func(e parser.Expr) {
+		if a, ok := e.(*parser.Call); ok {
+			a.Func, a.Args = oriFunc, oriArgs
+		}
+	}
+}
+
+// returns the <VectorSelector> in expr if it's selected for the pushed down function `f`
+func (o *OffloadQuerierable) pushdownSelector(e parser.Expr, f string) *parser.VectorSelector {
+	switch n := e.(type) {
+	case *parser.ParenExpr:
+		return o.pushdownSelector(n.Expr, f)
+	case *parser.StepInvariantExpr:
+		return o.pushdownSelector(n.Expr, f)
+	case *parser.MatrixSelector:
+		return o.pushdownSelector(n.VectorSelector, f)
+	case *parser.VectorSelector:
+		if len(n.LabelMatchers) > 0 && o.pushdownSelectors[n.LabelMatchers[0]] == f {
+			return n
+		}
+	}
+	return nil
+}
+
+func (o *OffloadQuerierable) isPushdownExpr(e parser.Expr) bool {
+	switch n := e.(type) {
+	case *parser.ParenExpr:
+		return o.isPushdownExpr(n.Expr)
+	case *parser.StepInvariantExpr:
+		return o.isPushdownExpr(n.Expr)
+	case *parser.AggregateExpr:
+		return o.pushdownSelector(n.Expr, n.Op.String()) != nil
+	case *parser.Call:
+		for _, arg := range n.Args {
+			if o.pushdownSelector(arg, n.Func.Name) != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // why restore: expr would have a cache for the same promql
 func (o *OffloadQuerierable) restoreFunctionAfterQueryFinished() {
 	for expr, restoreFunc := range o.cachedQueryExprs {
@@ -218,6 +284,11 @@ func (o *OffloadQuerier) Select(sortSeries bool, hints *storage.SelectHints, mat
 			log.Error(err)
 			return storage.ErrSeriesSet(err)
 		}
+		if f0 := queryReq.GetFunc()[0]; QueryPushdownCall[f0] != nil {
+			o.querierable.pushdownSelectors[matchers[0]] = f0
+			q := o.querierable.reader.newPushdownQuery(queryReq, o.querierable.queryType, QueryPushdownCall[f0])
+			q.appendStaleMarkers(resp.Results[0])
+		}
 
 		err = o.selectedCallback(o.querierable.queryType)
 		if err != nil {
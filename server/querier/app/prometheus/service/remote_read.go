@@ -44,6 +44,7 @@ type prometheusReader struct {
 	orgID                   string
 	extraFilters            string
 	blockTeamID             []string
+	lookbackDelta           time.Duration
 	interceptPrometheusExpr func(func(e *parser.AggregateExpr) error) error
 	getExternalTagFromCache func(string, string) string
 	addExternalTagToCache   func(string, string, string)
@@ -61,6 +62,21 @@ func (p *prometheusReader) promReaderExecute(ctx context.Context, req *prompb.Re
 	metricName := cache.GetMetricFromLabelMatcher(&req.Queries[0].Matchers)
 	cacheOrgFilterKey := fmt.Sprintf("%s-%s", p.orgID, strings.Join(p.blockTeamID, "-"))
 
+	// the native histograms are expanded into the series of '<name>_bucket', '<name>_count' and '<name>_sum',
+	// if there are no float samples of the metric, only the histograms are queried without the cache
+	histogramMetricName := metricName
+	_, _, isNativeHistogram := nativeHistogramMetric(p.orgID, histogramMetricName)
+	if isNativeHistogram {
+		if _, ok := prometheusOrgMap(p.orgID).MetricNameToID[metricName]; !ok {
+			var series []*prompb.TimeSeries
+			series, err = p.nativeHistogramSeries(ctx, req.Queries[0], histogramMetricName, start, end)
+			if err != nil {
+				return nil, "", "", 0, err
+			}
+			return &prompb.ReadResponse{Results: []*prompb.QueryResult{{Timeseries: series}}}, "", "", 0, nil
+		}
+	}
+
 	var response *prompb.ReadResponse
 	// clear cache if data not found
 	defer func(r *prompb.ReadRequest) {
@@ -183,6 +199,11 @@ func (p *prometheusReader) promReaderExecute(ctx context.Context, req *prompb.Re
 	api_query_start, api_query_end := cache.GetPromRequestQueryTime(req.Queries[0])
 	// response trans to prom resp
 	resp, err = p.respTransToProm(ctx, metricName, api_query_start, api_query_end, result)
+	if err == nil && isNativeHistogram && len(resp.Results) > 0 {
+		var series []*prompb.TimeSeries
+		series, err = p.nativeHistogramSeries(ctx, req.Queries[0], histogramMetricName, start, end)
+		resp.Results[0].Timeseries = append(resp.Results[0].Timeseries, series...)
+	}
 
 	if cacheAvailable {
 		// merge result into cache
@@ -0,0 +1,508 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/strutil"
+
+	"github.com/deepflowio/deepflow/server/controller/election"
+	"github.com/deepflowio/deepflow/server/querier/app/prometheus/model"
+	"github.com/deepflowio/deepflow/server/querier/config"
+)
+
+const (
+	RULE_TYPE_ALERTING  = "alerting"
+	RULE_TYPE_RECORDING = "recording"
+
+	ruleHTTPTimeout         = 10 * time.Second
+	ruleLeaderCheckInterval = 10 * time.Second
+	// the same as the default of Prometheus 'rules.alert.for-outage-tolerance' and 'rules.alert.for-grace-period'
+	ruleOutageTolerance = time.Hour
+	ruleForGracePeriod  = 10 * time.Minute
+)
+
+// ruleManager evaluates the Prometheus-format recording and alerting rules with the PromQL engine of the
+// querier. The results of the recording rules (and the ALERTS series) are written back with Prometheus
+// remote write, and the firing alerts are posted to the Alertmanager compatible webhooks.
+// The rules are only evaluated by the server which is the elected leader of the controllers, so that the
+// replicas do not write the same series or send the same alerts repeatedly. The other servers forward the
+// rules and alerts API to the leader.
+type ruleManager struct {
+	cfg     *config.QuerierConfig
+	service *PrometheusService
+	client  *http.Client
+
+	files    []string
+	interval time.Duration
+
+	mutex sync.RWMutex
+	// created when the server becomes the leader, so that the 'for' state of the alerts is restored from the
+	// ALERTS_FOR_STATE series every time, nil if the server is not the leader
+	manager *rules.Manager
+}
+
+func newRuleManager(s *PrometheusService) (*ruleManager, error) {
+	cfg := config.Cfg
+	if _, err := url.Parse(cfg.Prometheus.Rules.ExternalURL); err != nil {
+		return nil, fmt.Errorf("parse external-url failed: %v", err)
+	}
+	return &ruleManager{
+		cfg:     cfg,
+		service: s,
+		client:  &http.Client{Timeout: ruleHTTPTimeout},
+	}, nil
+}
+
+func (m *ruleManager) newManager() *rules.Manager {
+	rulesCfg := m.cfg.Prometheus.Rules
+	externalURL, _ := url.Parse(rulesCfg.ExternalURL)
+	return rules.NewManager(&rules.ManagerOptions{
+		ExternalURL:     externalURL,
+		QueryFunc:       m.query,
+		NotifyFunc:      m.notify,
+		Context:         context.Background(),
+		Appendable:      &remoteWriteAppendable{url: rulesCfg.RemoteWriteURL, client: m.client},
+		Queryable:       storage.QueryableFunc(m.querier),
+		Logger:          newPrometheusLogger(),
+		OutageTolerance: ruleOutageTolerance,
+		ForGracePeriod:  ruleForGracePeriod,
+		ResendDelay:     time.Duration(rulesCfg.ResendDelay) * time.Second,
+	})
+}
+
+func (m *ruleManager) start() error {
+	rulesCfg := m.cfg.Prometheus.Rules
+	files := []string{}
+	for _, pattern := range rulesCfg.RuleFiles {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid rule file pattern %s: %v", pattern, err)
+		}
+		files = append(files, matches...)
+	}
+	m.files = files
+	m.interval = time.Duration(rulesCfg.EvaluationInterval) * time.Second
+	// check the rule files before waiting for the leadership
+	groups, errs := m.newManager().LoadGroups(m.interval, nil, rulesCfg.ExternalURL, nil, files...)
+	if len(errs) > 0 {
+		return fmt.Errorf("load rule files %v failed: %v", files, errs)
+	}
+	if rulesCfg.RemoteWriteURL == "" {
+		if hasRecordingRules(groups) {
+			return fmt.Errorf("remote-write-url of prometheus rules is not set, results of recording rules in %v can not be stored", files)
+		}
+		log.Warning("remote-write-url of prometheus rules is not set, 'for' state of alerts will not be restored when the leader changes")
+	}
+	log.Infof("prometheus rule manager started, rule files: %v", files)
+	go m.runOnLeader()
+	return nil
+}
+
+func hasRecordingRules(groups map[string]*rules.Group) bool {
+	for _, group := range groups {
+		for _, rule := range group.Rules() {
+			if _, ok := rule.(*rules.RecordingRule); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// runOnLeader loads the rules when the server becomes the leader, and unloads them when it is not the leader
+func (m *ruleManager) runOnLeader() {
+	leading := false
+	ticker := time.NewTicker(ruleLeaderCheckInterval)
+	defer ticker.Stop()
+	for {
+		leading = m.updateLeadership(leading)
+		<-ticker.C
+	}
+}
+
+func (m *ruleManager) updateLeadership(leading bool) bool {
+	isLeader, err := election.IsMasterController()
+	if err != nil {
+		// the rules are not evaluated if it is unknown whether the server is the leader
+		log.Warningf("check leader of prometheus rules failed: %v", err)
+		isLeader = false
+	}
+	if isLeader == leading {
+		return leading
+	}
+	if !isLeader {
+		m.mutex.Lock()
+		manager := m.manager
+		m.manager = nil
+		m.mutex.Unlock()
+		// the new leader sends the alerts again after restoring the 'for' state
+		m.resolveAlerts(manager)
+		manager.Stop()
+		log.Info("stop evaluating prometheus rules because the server is not the leader")
+		return false
+	}
+	manager := m.newManager()
+	if err := manager.Update(m.interval, m.files, nil, m.cfg.Prometheus.Rules.ExternalURL, nil); err != nil {
+		log.Errorf("update prometheus rules failed: %v", err)
+		return leading
+	}
+	go manager.Run()
+	m.mutex.Lock()
+	m.manager = manager
+	m.mutex.Unlock()
+	log.Info("start evaluating prometheus rules as the leader")
+	return true
+}
+
+// resolveAlerts sends the firing alerts as resolved, otherwise they are firing in Alertmanager until expired
+func (m *ruleManager) resolveAlerts(manager *rules.Manager) {
+	now := time.Now()
+	for _, rule := range manager.AlertingRules() {
+		// the active alerts are copies
+		alerts := rule.ActiveAlerts()
+		for _, alert := range alerts {
+			alert.State = rules.StateInactive
+			alert.ResolvedAt = now
+		}
+		m.notify(context.Background(), rule.Query().String(), alerts...)
+	}
+}
+
+// getManager returns nil if the server is not the leader
+func (m *ruleManager) getManager() *rules.Manager {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.manager
+}
+
+// leader returns the IP of the leader if the rules are evaluated by another server
+func (m *ruleManager) leader() (string, error) {
+	isLeader, leaderIP, err := election.IsMasterControllerAndReturnIP()
+	if err != nil || isLeader {
+		return "", err
+	}
+	return leaderIP, nil
+}
+
+func formatRuleTime(ms int64) string {
+	return strconv.FormatFloat(float64(ms)/1000, 'f', -1, 64)
+}
+
+// querier reads the ALERTS_FOR_STATE series written back by the rules, to restore the 'for' state of the alerts
+func (m *ruleManager) querier(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
+	p := m.service.executor
+	args := &model.PromQueryParams{
+		StartTime: formatRuleTime(mint),
+		EndTime:   formatRuleTime(maxt),
+		OrgID:     m.cfg.Prometheus.Rules.OrgID,
+		Slimit:    m.cfg.Prometheus.SeriesLimit,
+		Context:   ctx,
+	}
+	reader := &prometheusReader{
+		orgID:                   args.OrgID,
+		slimit:                  args.Slimit,
+		getExternalTagFromCache: p.convertExternalTagToQuerierAllowTag,
+		addExternalTagToCache:   p.addExtraLabelsToCache,
+	}
+	queryable := &RemoteReadQuerierable{Args: args, Ctx: ctx, reader: reader}
+	q, err := queryable.Querier(ctx, mint, maxt)
+	if err != nil {
+		return nil, err
+	}
+	return &forStateQuerier{Querier: q, mint: mint, maxt: maxt}, nil
+}
+
+// forStateQuerier fills the select hints, which are nil when the rules restore the 'for' state
+type forStateQuerier struct {
+	storage.Querier
+	mint, maxt int64
+}
+
+func (q *forStateQuerier) Select(sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
+	if hints == nil {
+		hints = &storage.SelectHints{Start: q.mint, End: q.maxt}
+	}
+	return q.Querier.Select(sortSeries, hints, matchers...)
+}
+
+func (m *ruleManager) query(ctx context.Context, q string, t time.Time) (promql.Vector, error) {
+	queryTime := formatRuleTime(t.UnixMilli())
+	args := &model.PromQueryParams{
+		Promql:    q,
+		StartTime: queryTime,
+		EndTime:   queryTime,
+		OrgID:     m.cfg.Prometheus.Rules.OrgID,
+		Slimit:    m.cfg.Prometheus.SeriesLimit,
+		Context:   ctx,
+	}
+	result, err := m.service.PromInstantQueryService(args, ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := result.Data.(*model.PromQueryData)
+	if !ok {
+		return nil, fmt.Errorf("rule query result is not a valid data: %v", result.Data)
+	}
+	switch v := data.Result.(type) {
+	case promql.Vector:
+		return v, nil
+	case promql.Scalar:
+		return promql.Vector{promql.Sample{Point: promql.Point{T: v.T, V: v.V}, Metric: labels.Labels{}}}, nil
+	default:
+		return nil, fmt.Errorf("rule result is not a vector or scalar")
+	}
+}
+
+// alertmanagerAlert is the alert of Alertmanager API '/api/v2/alerts'
+type alertmanagerAlert struct {
+	Labels       labels.Labels `json:"labels"`
+	Annotations  labels.Labels `json:"annotations"`
+	StartsAt     time.Time     `json:"startsAt,omitempty"`
+	EndsAt       time.Time     `json:"endsAt,omitempty"`
+	GeneratorURL string        `json:"generatorURL,omitempty"`
+}
+
+func toAlertmanagerAlerts(externalURL, expr string, alerts ...*rules.Alert) []*alertmanagerAlert {
+	result := make([]*alertmanagerAlert, 0, len(alerts))
+	for _, alert := range alerts {
+		// pending alerts are not sent, and FiredAt of them is zero
+		if alert.FiredAt.IsZero() {
+			continue
+		}
+		a := &alertmanagerAlert{
+			Labels:       alert.Labels,
+			Annotations:  alert.Annotations,
+			StartsAt:     alert.FiredAt,
+			GeneratorURL: externalURL + strutil.TableLinkForExpression(expr),
+		}
+		if !alert.ResolvedAt.IsZero() {
+			a.EndsAt = alert.ResolvedAt
+		} else {
+			a.EndsAt = alert.ValidUntil
+		}
+		result = append(result, a)
+	}
+	return result
+}
+
+func (m *ruleManager) notify(ctx context.Context, expr string, alerts ...*rules.Alert) {
+	rulesCfg := m.cfg.Prometheus.Rules
+	if len(rulesCfg.AlertmanagerURLs) == 0 {
+		return
+	}
+	amAlerts := toAlertmanagerAlerts(rulesCfg.ExternalURL, expr, alerts...)
+	if len(amAlerts) == 0 {
+		return
+	}
+	body, err := json.Marshal(amAlerts)
+	if err != nil {
+		log.Errorf("marshal alerts failed: %v", err)
+		return
+	}
+	for _, u := range rulesCfg.AlertmanagerURLs {
+		go func(u string) {
+			if err := postRuleRequest(m.client, u, body, map[string]string{"Content-Type": "application/json"}); err != nil {
+				log.Errorf("send %d alerts to %s failed: %v", len(amAlerts), u, err)
+			}
+		}(u)
+	}
+}
+
+func postRuleRequest(client *http.Client, u string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status code %d: %s", resp.StatusCode, msg)
+	}
+	return nil
+}
+
+// remoteWriteAppendable writes the samples generated by rules to the Prometheus remote write endpoint, the
+// samples of each rule group evaluation are sent in one request when committed.
+type remoteWriteAppendable struct {
+	url    string
+	client *http.Client
+}
+
+func (a *remoteWriteAppendable) Appender(ctx context.Context) storage.Appender {
+	return &remoteWriteAppender{appendable: a}
+}
+
+type remoteWriteAppender struct {
+	appendable *remoteWriteAppendable
+	series     []prompb.TimeSeries
+}
+
+func (a *remoteWriteAppender) Append(ref storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	promLabels := make([]prompb.Label, 0, len(l))
+	for _, label := range l {
+		promLabels = append(promLabels, prompb.Label{Name: label.Name, Value: label.Value})
+	}
+	a.series = append(a.series, prompb.TimeSeries{
+		Labels:  promLabels,
+		Samples: []prompb.Sample{{Value: v, Timestamp: t}},
+	})
+	return 0, nil
+}
+
+func (a *remoteWriteAppender) AppendExemplar(ref storage.SeriesRef, l labels.Labels, e exemplar.Exemplar) (storage.SeriesRef, error) {
+	return 0, nil
+}
+
+func (a *remoteWriteAppender) Commit() error {
+	defer a.Rollback()
+	if len(a.series) == 0 || a.appendable.url == "" {
+		return nil
+	}
+	data, err := (&prompb.WriteRequest{Timeseries: a.series}).Marshal()
+	if err != nil {
+		return err
+	}
+	return postRuleRequest(a.appendable.client, a.appendable.url, snappy.Encode(nil, data), map[string]string{
+		"Content-Encoding":                  "snappy",
+		"Content-Type":                      "application/x-protobuf",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+	})
+}
+
+func (a *remoteWriteAppender) Rollback() error {
+	a.series = nil
+	return nil
+}
+
+// ruleGroups returns the data of '/api/v1/rules', ruleType is 'alert' or 'record' to filter the rules
+func (m *ruleManager) ruleGroups(ruleType string) *model.PromRuleDiscovery {
+	returnAlerts := ruleType == "" || ruleType == "alert"
+	returnRecording := ruleType == "" || ruleType == "record"
+
+	result := &model.PromRuleDiscovery{RuleGroups: []*model.PromRuleGroup{}}
+	manager := m.getManager()
+	if manager == nil {
+		return result
+	}
+	for _, group := range manager.RuleGroups() {
+		g := &model.PromRuleGroup{
+			Name:           group.Name(),
+			File:           group.File(),
+			Rules:          []interface{}{},
+			Interval:       group.Interval().Seconds(),
+			Limit:          group.Limit(),
+			EvaluationTime: group.GetEvaluationTime().Seconds(),
+			LastEvaluation: group.GetLastEvaluation(),
+		}
+		for _, r := range group.Rules() {
+			lastError := ""
+			if r.LastError() != nil {
+				lastError = r.LastError().Error()
+			}
+			switch rule := r.(type) {
+			case *rules.AlertingRule:
+				if !returnAlerts {
+					continue
+				}
+				g.Rules = append(g.Rules, &model.PromAlertingRule{
+					State:          rule.State().String(),
+					Name:           rule.Name(),
+					Query:          rule.Query().String(),
+					Duration:       rule.HoldDuration().Seconds(),
+					Labels:         rule.Labels(),
+					Annotations:    rule.Annotations(),
+					Alerts:         toPromAlerts(rule.ActiveAlerts()),
+					Health:         string(rule.Health()),
+					LastError:      lastError,
+					EvaluationTime: rule.GetEvaluationDuration().Seconds(),
+					LastEvaluation: rule.GetEvaluationTimestamp(),
+					Type:           RULE_TYPE_ALERTING,
+				})
+			case *rules.RecordingRule:
+				if !returnRecording {
+					continue
+				}
+				g.Rules = append(g.Rules, &model.PromRecordingRule{
+					Name:           rule.Name(),
+					Query:          rule.Query().String(),
+					Labels:         rule.Labels(),
+					Health:         string(rule.Health()),
+					LastError:      lastError,
+					EvaluationTime: rule.GetEvaluationDuration().Seconds(),
+					LastEvaluation: rule.GetEvaluationTimestamp(),
+					Type:           RULE_TYPE_RECORDING,
+				})
+			}
+		}
+		result.RuleGroups = append(result.RuleGroups, g)
+	}
+	return result
+}
+
+// alerts returns the data of '/api/v1/alerts'
+func (m *ruleManager) alerts() *model.PromAlertDiscovery {
+	result := &model.PromAlertDiscovery{Alerts: []*model.PromAlert{}}
+	manager := m.getManager()
+	if manager == nil {
+		return result
+	}
+	for _, rule := range manager.AlertingRules() {
+		result.Alerts = append(result.Alerts, toPromAlerts(rule.ActiveAlerts())...)
+	}
+	return result
+}
+
+func toPromAlerts(alerts []*rules.Alert) []*model.PromAlert {
+	result := make([]*model.PromAlert, 0, len(alerts))
+	for _, alert := range alerts {
+		activeAt := alert.ActiveAt
+		result = append(result, &model.PromAlert{
+			Labels:      alert.Labels,
+			Annotations: alert.Annotations,
+			State:       alert.State.String(),
+			ActiveAt:    &activeAt,
+			Value:       strconv.FormatFloat(alert.Value, 'e', -1, 64),
+		})
+	}
+	return result
+}
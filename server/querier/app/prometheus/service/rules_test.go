@@ -0,0 +1,109 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToAlertmanagerAlerts(t *testing.T) {
+	firedAt := time.Unix(1700000000, 0)
+	validUntil := firedAt.Add(4 * time.Minute)
+	resolvedAt := firedAt.Add(time.Minute)
+	alerts := []*rules.Alert{
+		{State: rules.StatePending, Labels: labels.FromStrings("alertname", "pending"), ActiveAt: firedAt},
+		{State: rules.StateFiring, Labels: labels.FromStrings("alertname", "firing"), FiredAt: firedAt, ValidUntil: validUntil},
+		{State: rules.StateInactive, Labels: labels.FromStrings("alertname", "resolved"), FiredAt: firedAt, ResolvedAt: resolvedAt, ValidUntil: validUntil},
+	}
+
+	result := toAlertmanagerAlerts("http://deepflow", `up == 0`, alerts...)
+	assert.Len(t, result, 2)
+	assert.Equal(t, "firing", result[0].Labels.Get("alertname"))
+	assert.Equal(t, firedAt, result[0].StartsAt)
+	assert.Equal(t, validUntil, result[0].EndsAt)
+	assert.Equal(t, "http://deepflow/graph?g0.expr=up+%3D%3D+0&g0.tab=1", result[0].GeneratorURL)
+	assert.Equal(t, "resolved", result[1].Labels.Get("alertname"))
+	assert.Equal(t, resolvedAt, result[1].EndsAt)
+}
+
+func TestRemoteWriteAppender(t *testing.T) {
+	var received prompb.WriteRequest
+	var contentEncoding string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentEncoding = r.Header.Get("Content-Encoding")
+		compressed, _ := io.ReadAll(r.Body)
+		data, err := snappy.Decode(nil, compressed)
+		assert.Nil(t, err)
+		assert.Nil(t, received.Unmarshal(data))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	appendable := &remoteWriteAppendable{url: server.URL, client: server.Client()}
+	appender := appendable.Appender(nil)
+	appender.Append(0, labels.FromStrings("__name__", "job:up:sum", "job", "node"), 1700000000000, 3)
+	appender.Append(0, labels.FromStrings("__name__", "job:up:sum", "job", "mysql"), 1700000000000, 1)
+	assert.Nil(t, appender.Commit())
+
+	assert.Equal(t, "snappy", contentEncoding)
+	assert.Len(t, received.Timeseries, 2)
+	assert.Equal(t, []prompb.Label{{Name: "__name__", Value: "job:up:sum"}, {Name: "job", Value: "node"}}, received.Timeseries[0].Labels)
+	assert.Equal(t, []prompb.Sample{{Value: 1, Timestamp: 1700000000000}}, received.Timeseries[1].Samples)
+
+	// nothing is sent after committed
+	received = prompb.WriteRequest{}
+	assert.Nil(t, appender.Commit())
+	assert.Len(t, received.Timeseries, 0)
+}
+
+func TestHasRecordingRules(t *testing.T) {
+	dir := t.TempDir()
+	alertingFile := filepath.Join(dir, "alerting.yaml")
+	recordingFile := filepath.Join(dir, "recording.yaml")
+	assert.Nil(t, os.WriteFile(alertingFile, []byte(`groups:
+- name: alerting
+  rules:
+  - alert: InstanceDown
+    expr: up == 0
+`), 0644))
+	assert.Nil(t, os.WriteFile(recordingFile, []byte(`groups:
+- name: recording
+  rules:
+  - record: job:up:sum
+    expr: sum by (job) (up)
+`), 0644))
+
+	manager := rules.NewManager(&rules.ManagerOptions{Logger: newPrometheusLogger()})
+	groups, errs := manager.LoadGroups(time.Minute, nil, "", nil, alertingFile)
+	assert.Len(t, errs, 0)
+	assert.False(t, hasRecordingRules(groups))
+	groups, errs = manager.LoadGroups(time.Minute, nil, "", nil, alertingFile, recordingFile)
+	assert.Len(t, errs, 0)
+	assert.True(t, hasRecordingRules(groups))
+}
@@ -19,6 +19,7 @@ package service
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"time"
 
 	logging "github.com/op/go-logging"
@@ -46,6 +47,8 @@ type PrometheusService struct {
 	executor *prometheusExecutor
 	// prometheus query rate limit
 	QPSLeakyBucket *datastructure.LeakyBucket
+	// evaluate recording and alerting rules, nil if rules is disabled
+	ruleManager *ruleManager
 }
 
 func NewPrometheusService() *PrometheusService {
@@ -100,6 +103,68 @@ func (s *PrometheusService) PromSeriesQueryService(args *model.PromQueryParams,
 	return s.executor.series(ctx, args)
 }
 
+func (s *PrometheusService) PromExemplarsQueryService(args *model.PromQueryParams, ctx context.Context) (*model.PromQueryResponse, error) {
+	return s.executor.exemplars(ctx, args)
+}
+
+func (s *PrometheusService) PromLabelNamesService(args *model.PromQueryParams, ctx context.Context) (*model.PromQueryResponse, error) {
+	return s.executor.labelNames(ctx, args)
+}
+
+func (s *PrometheusService) PromMetadataService(orgID string, metric string, limit int) (*model.PromQueryResponse, error) {
+	return s.executor.metadata(orgID, metric, limit)
+}
+
+func (s *PrometheusService) PromFormatQueryService(query string) (*model.PromQueryResponse, error) {
+	return s.executor.formatQuery(query)
+}
+
+func (s *PrometheusService) PromBuildInfoService() *model.PromQueryResponse {
+	return s.executor.buildInfo()
+}
+
+func (s *PrometheusService) PromTargetsService(ctx context.Context, orgID string, state string) (*model.PromQueryResponse, error) {
+	return s.executor.targets(ctx, orgID, state)
+}
+
+func (s *PrometheusService) StartRuleManager() error {
+	m, err := newRuleManager(s)
+	if err != nil {
+		return err
+	}
+	if err := m.start(); err != nil {
+		return err
+	}
+	s.ruleManager = m
+	return nil
+}
+
+// PromRulesLeader returns the IP of the leader if the rules are evaluated by another server, empty if the rules
+// are evaluated by this server or disabled
+func (s *PrometheusService) PromRulesLeader() (string, error) {
+	if s.ruleManager == nil {
+		return "", nil
+	}
+	return s.ruleManager.leader()
+}
+
+func (s *PrometheusService) PromRulesService(ruleType string) (*model.PromQueryResponse, error) {
+	if ruleType != "" && ruleType != "alert" && ruleType != "record" {
+		return nil, fmt.Errorf("invalid rule type %q", ruleType)
+	}
+	if s.ruleManager == nil {
+		return &model.PromQueryResponse{Data: &model.PromRuleDiscovery{RuleGroups: []*model.PromRuleGroup{}}, Status: _SUCCESS}, nil
+	}
+	return &model.PromQueryResponse{Data: s.ruleManager.ruleGroups(ruleType), Status: _SUCCESS}, nil
+}
+
+func (s *PrometheusService) PromAlertsService() (*model.PromQueryResponse, error) {
+	if s.ruleManager == nil {
+		return &model.PromQueryResponse{Data: &model.PromAlertDiscovery{Alerts: []*model.PromAlert{}}, Status: _SUCCESS}, nil
+	}
+	return &model.PromQueryResponse{Data: s.ruleManager.alerts(), Status: _SUCCESS}, nil
+}
+
 func (s *PrometheusService) PromQLAnalysis(ctx context.Context, metric string, targetLabels []string, appLabels []string, startTime string, endTime string, orgID string) (*common.Result, error) {
 	return s.executor.promQLAnalysis(ctx, metric, targetLabels, appLabels, startTime, endTime, orgID)
 }
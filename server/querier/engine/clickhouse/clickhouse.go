@@ -1216,6 +1216,34 @@ func (e *CHEngine) TransWhere(node *sqlparser.Where) error {
 	return err
 }
 
+// TransFilter translates a where condition on e.DB and e.Table to a ClickHouse filter expression, so that
+// callers building their own ClickHouse SQL get the same tag translation and value escaping as the SQL API
+func (e *CHEngine) TransFilter(filter string) (string, error) {
+	if strings.TrimSpace(filter) == "" {
+		return "", nil
+	}
+	stmt, err := sqlparser.Parse(fmt.Sprintf("SELECT 1 FROM `%s` WHERE %s", e.Table, filter))
+	if err != nil {
+		return "", err
+	}
+	pStmt, ok := stmt.(*sqlparser.Select)
+	if !ok || pStmt.Where == nil || pStmt.Limit != nil || pStmt.GroupBy != nil || pStmt.OrderBy != nil || pStmt.Having != nil {
+		return "", errors.New(fmt.Sprintf("invalid filter: %s", filter))
+	}
+	whereStmt := Where{time: e.Model.Time}
+	expr, err := e.parseWhere(pStmt.Where.Expr, &whereStmt, false)
+	if err != nil {
+		return "", err
+	}
+	if len(whereStmt.withs) > 0 {
+		return "", errors.New(fmt.Sprintf("filter with functions is not supported: %s", filter))
+	}
+	if expr == nil {
+		return "", nil
+	}
+	return expr.ToString(), nil
+}
+
 func (e *CHEngine) TransHaving(node *sqlparser.Where) error {
 	// 生成having的statement
 	havingStmt := Having{Where{}}
@@ -697,6 +697,61 @@ func TestGetSql(t *testing.T) {
 	 }
  } */
 
+func TestTransFilter(t *testing.T) {
+	Load()
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockDatasources()
+	mockNativeFields()
+
+	cases := []struct {
+		name     string
+		filter   string
+		contains []string
+		wantErr  bool
+	}{{
+		name:     "tag",
+		filter:   "trace_id='abc' AND response_status!=0",
+		contains: []string{"trace_id", "'abc'", "response_status"},
+	}, {
+		name:     "escaped value",
+		filter:   "trace_id='a\\'b'",
+		contains: []string{"trace_id"},
+	}, {
+		name:    "unbalanced parenthesis",
+		filter:  "1=1) UNION ALL SELECT name FROM system.users WHERE (1=1",
+		wantErr: true,
+	}, {
+		name:    "multiple statements",
+		filter:  "trace_id='abc'; DROP TABLE flow_log.l7_flow_log",
+		wantErr: true,
+	}, {
+		name:    "limit",
+		filter:  "trace_id='abc' LIMIT 1",
+		wantErr: true,
+	}}
+	for _, c := range cases {
+		e := CHEngine{DB: "flow_log", Table: "l7_flow_log"}
+		e.Init()
+		out, err := e.TransFilter(c.filter)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %q", c.name, out)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", c.name, err)
+			continue
+		}
+		for _, s := range c.contains {
+			if !strings.Contains(out, s) {
+				t.Errorf("%s: %q does not contain %q", c.name, out, s)
+			}
+		}
+	}
+}
+
 func Load() error {
 	ServerCfg := config.DefaultConfig()
 	config.Cfg = &ServerCfg.QuerierConfig
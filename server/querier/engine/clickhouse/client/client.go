@@ -112,8 +112,9 @@ func (c *Client) Close() error {
 	return nil
 }
 
-func (c *Client) DoQuery(params *QueryParams) (result *common.Result, err error) {
-	sqlstr, callbacks, query_uuid, columnSchemaMap, simpleSql := params.Sql, params.Callbacks, params.QueryUUID, params.ColumnSchemaMap, params.SimpleSql
+// transSql applies the query cache settings, the database of the organization and the live view to the sql
+func transSql(params *QueryParams) (string, error) {
+	sqlstr, simpleSql := params.Sql, params.SimpleSql
 	queryCacheStr := ""
 	if params.UseQueryCache {
 		queryCacheStr = " SETTINGS use_query_cache = true"
@@ -131,7 +132,7 @@ func (c *Client) DoQuery(params *QueryParams) (result *common.Result, err error)
 	if !simpleSql && params.ORGID != common.DEFAULT_ORG_ID && params.ORGID != "" {
 		orgIDInt, err := strconv.Atoi(params.ORGID)
 		if err != nil {
-			return nil, err
+			return "", err
 		}
 		sqlstr = strings.ReplaceAll(sqlstr, "flow_tag", fmt.Sprintf("%04d_flow_tag", orgIDInt))
 	}
@@ -140,6 +141,15 @@ func (c *Client) DoQuery(params *QueryParams) (result *common.Result, err error)
 		sqlstr = strings.ReplaceAll(sqlstr, "app_label_live_view", "app_label_map")
 		sqlstr = strings.ReplaceAll(sqlstr, "target_label_live_view", "target_label_map")
 	}
+	return sqlstr, nil
+}
+
+func (c *Client) DoQuery(params *QueryParams) (result *common.Result, err error) {
+	callbacks, query_uuid, columnSchemaMap := params.Callbacks, params.QueryUUID, params.ColumnSchemaMap
+	sqlstr, err := transSql(params)
+	if err != nil {
+		return nil, err
+	}
 
 	err = c.init(query_uuid)
 	if err != nil {
@@ -226,6 +236,63 @@ func (c *Client) DoQuery(params *QueryParams) (result *common.Result, err error)
 	return result, nil
 }
 
+// DoQueryRows calls f with every row of the query result instead of loading all of them, the values of the row
+// are reused by the next row, callbacks and column schemas of the params are not supported
+func (c *Client) DoQueryRows(params *QueryParams, f func(row []interface{}) error) error {
+	sqlstr, err := transSql(params)
+	if err != nil {
+		return err
+	}
+	err = c.init(params.QueryUUID)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	start := time.Now()
+	ctx := c.Context
+	if c.Context == nil {
+		ctx = context.Background()
+	}
+	rows, err := c.connection.Query(ctx, sqlstr)
+	c.Debug.Sql = sqlstr
+	if err != nil {
+		log.Errorf("query clickhouse Error: %s, sql: %s, query_uuid: %s", err, sqlstr, c.Debug.QueryUUID)
+		c.Debug.Error = fmt.Sprintf("%s", err)
+		return err
+	}
+	defer rows.Close()
+	columns := rows.ColumnTypes()
+	columnValues := make([]interface{}, len(columns))
+	for i := range columns {
+		columnValues[i] = reflect.New(columns[i].ScanType()).Interface()
+	}
+	record := make([]interface{}, len(columns))
+	rowCount := 0
+	for rows.Next() {
+		if err := rows.Scan(columnValues...); err != nil {
+			c.Debug.Error = fmt.Sprintf("%s", err)
+			return err
+		}
+		for i, rawValue := range columnValues {
+			record[i] = TransType(rawValue)
+		}
+		if err := f(record); err != nil {
+			return err
+		}
+		rowCount++
+	}
+	if err := rows.Err(); err != nil {
+		log.Errorf("query clickhouse Error: %s, sql: %s, query_uuid: %s", err, sqlstr, c.Debug.QueryUUID)
+		c.Debug.Error = fmt.Sprintf("%s", err)
+		return err
+	}
+	queryTime := time.Since(start)
+	c.Debug.QueryTime = fmt.Sprintf("%.9fs", float64(queryTime)/1e9)
+	log.Infof("query_uuid: %s. query rows statistics: %d rows, %d columns, cost %f ms", c.Debug.QueryUUID, rowCount, len(columns), float64(queryTime.Milliseconds()))
+	return nil
+}
+
 func (c *Client) GetVersion() (version string, err error) {
 	defer c.Close()
 	ctx := c.Context
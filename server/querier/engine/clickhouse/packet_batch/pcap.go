@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package packet_batch
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// the packet_batch column of the l7_packet table is a pcap file which is sent by the agent
+// https://datatracker.ietf.org/doc/id/draft-gharris-opsawg-pcap-01.html
+const (
+	PCAP_MAGIC_MICROSECOND = 0xa1b2c3d4
+	PCAP_MAGIC_NANOSECOND  = 0xa1b23c4d
+	PCAP_VERSION_MAJOR     = 2
+	PCAP_VERSION_MINOR     = 4
+	PCAP_HEADER_LEN        = 24
+	PCAP_RECORD_HEADER_LEN = 16
+	PCAP_SNAP_LEN          = 65535
+)
+
+// ParsePcapBatch calls f with every packet record in the pcap packet batch, its byte order and timestamp
+// precision are decided by the magic number in the header, the timestamp passed to f is in nanoseconds
+func ParsePcapBatch(batch []byte, f func(linkType uint32, timestamp int64, origLen uint32, data []byte)) error {
+	if len(batch) < PCAP_HEADER_LEN {
+		return fmt.Errorf("packet batch length %d is less than the pcap header", len(batch))
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	magic := order.Uint32(batch)
+	if magic != PCAP_MAGIC_MICROSECOND && magic != PCAP_MAGIC_NANOSECOND {
+		order = binary.BigEndian
+		magic = order.Uint32(batch)
+		if magic != PCAP_MAGIC_MICROSECOND && magic != PCAP_MAGIC_NANOSECOND {
+			return fmt.Errorf("invalid pcap magic number 0x%08x", binary.LittleEndian.Uint32(batch))
+		}
+	}
+	// the upper 4 bits of the link type field are the FCS information
+	linkType := order.Uint32(batch[20:]) & 0x0fffffff
+
+	for offset := PCAP_HEADER_LEN; offset < len(batch); {
+		if offset+PCAP_RECORD_HEADER_LEN > len(batch) {
+			return fmt.Errorf("packet record header at offset %d is truncated", offset)
+		}
+		seconds, fraction := order.Uint32(batch[offset:]), order.Uint32(batch[offset+4:])
+		capLen, origLen := order.Uint32(batch[offset+8:]), order.Uint32(batch[offset+12:])
+		offset += PCAP_RECORD_HEADER_LEN
+		if int(capLen) > len(batch)-offset {
+			return fmt.Errorf("packet record data at offset %d is truncated", offset)
+		}
+		timestamp := int64(seconds) * 1e9
+		if magic == PCAP_MAGIC_NANOSECOND {
+			timestamp += int64(fraction)
+		} else {
+			timestamp += int64(fraction) * 1e3
+		}
+		f(linkType, timestamp, origLen, batch[offset:offset+int(capLen)])
+		offset += int(capLen)
+	}
+	return nil
+}
@@ -40,6 +40,10 @@ const (
 	VIF_DEVICE_TYPE_POD_GROUP_DAEMON_SET            = 133
 	VIF_DEVICE_TYPE_POD_GROUP_REPLICASET_CONTROLLER = 134
 	VIF_DEVICE_TYPE_POD_GROUP_CLONESET              = 135
+	VIF_DEVICE_TYPE_POD_GROUP_JOB                   = 136
+	VIF_DEVICE_TYPE_POD_GROUP_CRON_JOB              = 137
+	VIF_DEVICE_TYPE_POD_GROUP_ROLLOUT               = 138
+	VIF_DEVICE_TYPE_POD_GROUP_CUSTOM                = 139
 	VIF_DEVICE_TYPE_IP                              = 255
 )
 
@@ -86,10 +90,14 @@ var AutoServiceMap = map[string]int{
 	"daemon_set":             VIF_DEVICE_TYPE_POD_GROUP_DAEMON_SET,
 	"replica_set_controller": VIF_DEVICE_TYPE_POD_GROUP_REPLICASET_CONTROLLER,
 	"clone_set":              VIF_DEVICE_TYPE_POD_GROUP_CLONESET,
+	"job":                    VIF_DEVICE_TYPE_POD_GROUP_JOB,
+	"cron_job":               VIF_DEVICE_TYPE_POD_GROUP_CRON_JOB,
+	"rollout":                VIF_DEVICE_TYPE_POD_GROUP_ROLLOUT,
+	"custom_workload":        VIF_DEVICE_TYPE_POD_GROUP_CUSTOM,
 	"custom_service":         VIF_DEVICE_TYPE_CUSTOM_SERVICE,
 }
 
 var PodGroupTypeSlice = []string{
 	"deployment", "stateful_set", "replication_controller", "daemon_set",
-	"replica_set_controller", "clone_set",
+	"replica_set_controller", "clone_set", "job", "cron_job", "rollout", "custom_workload",
 }
@@ -30,10 +30,21 @@ type Profile struct {
 	Context             context.Context
 	OrgID               string
 	MaxKernelStackDepth *int `json:"max_kernel_stack_depth"` // default: -1
+	// diff mode: the profile of Compare is merged with the profile above (the baseline)
+	Compare *ProfileCompare `json:"compare"`
+}
+
+// ProfileCompare is the comparison profile in diff mode, TimeStart/TimeEnd/TagFilter of the baseline are used if not set,
+// e.g.: compare two time ranges, or compare two versions by TagFilter
+type ProfileCompare struct {
+	TagFilter string `json:"tag_filter"`
+	TimeStart int    `json:"time_start"`
+	TimeEnd   int    `json:"time_end"`
 }
 
 type ProfileGrafana struct {
 	Sql              string `json:"sql" binding:"required"` // profile filter
+	CompareSql       string `json:"compare_sql"`            // profile filter of comparison in diff mode
 	ProfileEventType string `json:"profile_event_type" binding:"required"`
 	Debug            bool   `json:"debug"`
 }
@@ -63,6 +63,7 @@ func profileGrafana(cfg *config.QuerierConfig) gin.HandlerFunc {
 		args := model.ProfileGrafana{}
 		args.Sql = c.PostForm("sql")
 		args.ProfileEventType = c.PostForm("profile_event_type")
+		args.CompareSql = c.PostForm("compare_sql")
 
 		var profileArgs model.Profile
 		profileArgs.Debug, _ = strconv.ParseBool(c.DefaultQuery("debug", "false"))
@@ -72,7 +73,7 @@ func profileGrafana(cfg *config.QuerierConfig) gin.HandlerFunc {
 		var maxKernelStackDepth = common.MAX_KERNEL_STACK_DEPTH_DEFAULT
 		profileArgs.MaxKernelStackDepth = &maxKernelStackDepth
 
-		result, debug, err := service.GrafanaProfile(profileArgs, cfg, args.Sql)
+		result, debug, err := service.GrafanaProfile(profileArgs, cfg, args.Sql, args.CompareSql)
 		if err == nil && !profileArgs.Debug {
 			debug = nil
 		}
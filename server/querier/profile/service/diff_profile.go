@@ -0,0 +1,158 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"math"
+
+	"github.com/deepflowio/deepflow/server/querier/profile/model"
+)
+
+// node value columns of the profile tree in diff mode
+const (
+	DIFF_NODE_FUNCTION_ID = iota
+	DIFF_NODE_PARENT_NODE_ID
+	DIFF_NODE_SELF_VALUE
+	DIFF_NODE_TOTAL_VALUE
+	DIFF_NODE_COMPARE_SELF_VALUE
+	DIFF_NODE_COMPARE_TOTAL_VALUE
+	DIFF_NODE_SELF_DELTA
+	DIFF_NODE_TOTAL_DELTA
+)
+
+type diffNodeKey struct {
+	parentNodeID int
+	locationID   int
+}
+
+// profileTreeMerger merges the nodes of profile trees by the function stack, the nodes with the same parent node
+// and the same function are merged into one node
+type profileTreeMerger struct {
+	locations    []string
+	locationToID map[string]int
+	nodeKeyToID  map[diffNodeKey]int
+	// [function_id, parent_node_id, self_value, total_value, compare_self_value, compare_total_value]
+	nodes [][]int
+}
+
+func newProfileTreeMerger(root string) *profileTreeMerger {
+	return &profileTreeMerger{
+		locations:    []string{root},
+		locationToID: map[string]int{root: 0},
+		nodeKeyToID:  make(map[diffNodeKey]int),
+		nodes:        [][]int{{0, -1, 0, 0, 0, 0}},
+	}
+}
+
+// merge adds the values of tree to the columns which start from valueIndex, the values are multiplied by ratio
+func (m *profileTreeMerger) merge(tree model.ProfileTree, valueIndex int, ratio float64) {
+	nodes := tree.NodeValues.Values
+	// the parent node is appended after the child node in GenerateProfile, so it is mapped recursively
+	mergedIDs := make([]int, len(nodes))
+	for i := range mergedIDs {
+		mergedIDs[i] = -1
+	}
+	var mergedID func(i int) int
+	mergedID = func(i int) int {
+		if i == 0 {
+			return 0 // root
+		}
+		if mergedIDs[i] >= 0 {
+			return mergedIDs[i]
+		}
+		parentID := mergedID(nodes[i][1])
+		location := tree.Functions[nodes[i][0]]
+		locationID, ok := m.locationToID[location]
+		if !ok {
+			locationID = len(m.locations)
+			m.locationToID[location] = locationID
+			m.locations = append(m.locations, location)
+		}
+		key := diffNodeKey{parentNodeID: parentID, locationID: locationID}
+		id, ok := m.nodeKeyToID[key]
+		if !ok {
+			id = len(m.nodes)
+			m.nodeKeyToID[key] = id
+			m.nodes = append(m.nodes, []int{locationID, parentID, 0, 0, 0, 0})
+		}
+		mergedIDs[i] = id
+		return id
+	}
+	for i, node := range nodes {
+		merged := m.nodes[mergedID(i)]
+		merged[valueIndex] += int(math.Round(float64(node[2]) * ratio))
+		merged[valueIndex+1] += int(math.Round(float64(node[3]) * ratio))
+	}
+}
+
+func profileTreeTotalValue(tree model.ProfileTree) int {
+	if len(tree.NodeValues.Values) == 0 {
+		return 0
+	}
+	return tree.NodeValues.Values[0][3]
+}
+
+// DiffProfileTree merges the baseline and the comparison profile trees into one tree, the values of comparison are
+// normalized by the total value (sample count) of baseline, and the deltas are the normalized comparison values minus
+// the baseline values.
+func DiffProfileTree(baseline, comparison model.ProfileTree, profileEventType string) model.ProfileTree {
+	result := model.ProfileTree{}
+	root := ""
+	if len(baseline.Functions) > 0 {
+		root = baseline.Functions[0]
+	} else if len(comparison.Functions) > 0 {
+		root = comparison.Functions[0]
+	}
+	baselineTotal, compareTotal := profileTreeTotalValue(baseline), profileTreeTotalValue(comparison)
+	if baselineTotal == 0 && compareTotal == 0 {
+		return result
+	}
+	ratio := 1.0
+	if baselineTotal > 0 && compareTotal > 0 {
+		ratio = float64(baselineTotal) / float64(compareTotal)
+	}
+
+	merger := newProfileTreeMerger(root)
+	merger.merge(baseline, DIFF_NODE_SELF_VALUE, 1)
+	merger.merge(comparison, DIFF_NODE_COMPARE_SELF_VALUE, ratio)
+
+	functionValues := make([][]int, len(merger.locations))
+	locationValues := make([][]int, len(merger.locations)) // values of both profiles for the location type
+	for i := range functionValues {
+		functionValues[i] = make([]int, 6)
+		locationValues[i] = make([]int, 2)
+	}
+	result.NodeValues.Values = make([][]int, 0, len(merger.nodes))
+	for _, node := range merger.nodes {
+		node = append(node, node[DIFF_NODE_COMPARE_SELF_VALUE]-node[DIFF_NODE_SELF_VALUE], node[DIFF_NODE_COMPARE_TOTAL_VALUE]-node[DIFF_NODE_TOTAL_VALUE])
+		result.NodeValues.Values = append(result.NodeValues.Values, node)
+
+		values := functionValues[node[DIFF_NODE_FUNCTION_ID]]
+		for i := range values {
+			values[i] += node[DIFF_NODE_SELF_VALUE+i]
+		}
+		locationValues[node[DIFF_NODE_FUNCTION_ID]][0] += node[DIFF_NODE_SELF_VALUE] + node[DIFF_NODE_COMPARE_SELF_VALUE]
+		locationValues[node[DIFF_NODE_FUNCTION_ID]][1] += node[DIFF_NODE_TOTAL_VALUE] + node[DIFF_NODE_COMPARE_TOTAL_VALUE]
+	}
+
+	result.Functions = merger.locations
+	result.FunctionTypes = GetLocationType(merger.locations, locationValues, profileEventType)
+	result.FunctionValues.Values = functionValues
+	result.FunctionValues.Columns = []string{"self_value", "total_value", "compare_self_value", "compare_total_value", "self_delta", "total_delta"}
+	result.NodeValues.Columns = []string{"function_id", "parent_node_id", "self_value", "total_value", "compare_self_value", "compare_total_value", "self_delta", "total_delta"}
+	return result
+}
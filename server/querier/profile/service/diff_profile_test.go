@@ -0,0 +1,75 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/deepflowio/deepflow/server/querier/profile/model"
+)
+
+// newTestProfileTree returns the tree in the layout of GenerateProfile, the child node is appended before its parent
+func newTestProfileTree(functions []string, nodes [][]int) model.ProfileTree {
+	tree := model.ProfileTree{Functions: functions}
+	tree.NodeValues.Values = nodes
+	return tree
+}
+
+func TestDiffProfileTree(t *testing.T) {
+	// root(10) -> main(10) -> a(6), b(4)
+	baseline := newTestProfileTree(
+		[]string{"app", "a", "main", "b"},
+		[][]int{{0, -1, 0, 10}, {1, 2, 6, 6}, {2, 0, 0, 10}, {3, 2, 4, 4}},
+	)
+	// root(20) -> main(20) -> b(10), c(10)
+	comparison := newTestProfileTree(
+		[]string{"app", "b", "main", "c"},
+		[][]int{{0, -1, 0, 20}, {1, 2, 10, 10}, {2, 0, 0, 20}, {3, 2, 10, 10}},
+	)
+
+	result := DiffProfileTree(baseline, comparison, "on-cpu")
+	if !reflect.DeepEqual(result.Functions, []string{"app", "main", "a", "b", "c"}) {
+		t.Fatalf("unexpected functions %v", result.Functions)
+	}
+	// the comparison values are normalized by the total value of baseline: 10/20
+	expected := [][]int{
+		{0, -1, 0, 10, 0, 10, 0, 0},
+		{1, 0, 0, 10, 0, 10, 0, 0},
+		{2, 1, 6, 6, 0, 0, -6, -6},
+		{3, 1, 4, 4, 5, 5, 1, 1},
+		{4, 1, 0, 0, 5, 5, 5, 5},
+	}
+	if !reflect.DeepEqual(result.NodeValues.Values, expected) {
+		t.Errorf("expected nodes %v, got %v", expected, result.NodeValues.Values)
+	}
+	if !reflect.DeepEqual(result.FunctionValues.Values[3], []int{4, 4, 5, 5, 1, 1}) {
+		t.Errorf("unexpected function values %v", result.FunctionValues.Values[3])
+	}
+	if len(result.FunctionTypes) != len(result.Functions) || result.FunctionTypes[4] != "A" {
+		t.Errorf("unexpected function types %v", result.FunctionTypes)
+	}
+
+	// the empty baseline
+	result = DiffProfileTree(model.ProfileTree{}, comparison, "on-cpu")
+	if result.NodeValues.Values[0][DIFF_NODE_COMPARE_TOTAL_VALUE] != 20 || result.NodeValues.Values[0][DIFF_NODE_TOTAL_DELTA] != 20 {
+		t.Errorf("unexpected root node %v", result.NodeValues.Values[0])
+	}
+	if result := DiffProfileTree(model.ProfileTree{}, model.ProfileTree{}, "on-cpu"); len(result.NodeValues.Values) != 0 {
+		t.Errorf("expected empty tree, got %v", result.NodeValues.Values)
+	}
+}
@@ -21,7 +21,7 @@ import (
 	"github.com/deepflowio/deepflow/server/querier/profile/model"
 )
 
-func GrafanaProfile(args model.Profile, cfg *config.QuerierConfig, where string) (result *model.GrafanaProfileValue, debug interface{}, err error) {
+func GrafanaProfile(args model.Profile, cfg *config.QuerierConfig, where, compareWhere string) (result *model.GrafanaProfileValue, debug interface{}, err error) {
 	result = &model.GrafanaProfileValue{}
 	result.Columns = []string{"level", "function", "self_value", "total_value"}
 
@@ -30,6 +30,19 @@ func GrafanaProfile(args model.Profile, cfg *config.QuerierConfig, where string)
 	if err != nil {
 		return
 	}
+	if compareWhere != "" {
+		// diff mode, like the diff flame graph of grafana, the values of the node are the sum of baseline and
+		// comparison, and the *_right values are the comparison
+		debugs, _ := generateDebug.(model.ProfileDebug)
+		comparison, compareDebug, compareErr := GenerateProfile(args, cfg, compareWhere, debugs)
+		debug = compareDebug
+		if compareErr != nil {
+			err = compareErr
+			return
+		}
+		tree = DiffProfileTree(tree, comparison, args.ProfileEventType)
+		result.Columns = append(result.Columns, "self_value_right", "total_value_right")
+	}
 	nodes := tree.NodeValues.Values
 	if len(nodes) == 0 {
 		return
@@ -45,6 +58,10 @@ func GrafanaProfile(args model.Profile, cfg *config.QuerierConfig, where string)
 		newNode[2] = node[2]
 		newNode[3] = node[3]
 		newNode[4] = &[]int{}
+		if compareWhere != "" {
+			newNode[2] = node[DIFF_NODE_SELF_VALUE] + node[DIFF_NODE_COMPARE_SELF_VALUE]
+			newNode[3] = node[DIFF_NODE_TOTAL_VALUE] + node[DIFF_NODE_COMPARE_TOTAL_VALUE]
+		}
 		newNodes = append(newNodes, newNode)
 	}
 
@@ -55,7 +72,7 @@ func GrafanaProfile(args model.Profile, cfg *config.QuerierConfig, where string)
 			*childIDs = append(*childIDs, i)
 		}
 	}
-	convertNode(newNodes[0], 0, result, tree.Functions, newNodes)
+	convertNode(0, 0, result, tree.Functions, newNodes, nodes, compareWhere != "")
 	return
 }
 
@@ -74,10 +91,15 @@ such as:
 
 https://play.grafana.org/d/cdl34qv4zzg8wa/flame-graphs?orgId=1
 */
-func convertNode(node [5]interface{}, level int, result *model.GrafanaProfileValue, functions []string, newNodes [][5]interface{}) {
-	result.Values = append(result.Values, []interface{}{level, functions[node[0].(int)], node[2].(int), node[3].(int)})
+func convertNode(nodeID int, level int, result *model.GrafanaProfileValue, functions []string, newNodes [][5]interface{}, nodes [][]int, diff bool) {
+	node := newNodes[nodeID]
+	value := []interface{}{level, functions[node[0].(int)], node[2].(int), node[3].(int)}
+	if diff {
+		value = append(value, nodes[nodeID][DIFF_NODE_COMPARE_SELF_VALUE], nodes[nodeID][DIFF_NODE_COMPARE_TOTAL_VALUE])
+	}
+	result.Values = append(result.Values, value)
 	childIDs := node[4].(*[]int)
 	for _, childID := range *childIDs {
-		convertNode(newNodes[childID], level+1, result, functions, newNodes)
+		convertNode(childID, level+1, result, functions, newNodes, nodes, diff)
 	}
 }
@@ -82,18 +82,20 @@ func GetAppService(args model.Profile, where string, debugs model.ProfileDebug)
 }
 
 func Profile(args model.Profile, cfg *config.QuerierConfig) (model.ProfileTree, interface{}, error) {
-	whereSlice := []string{}
-	whereSlice = append(whereSlice, fmt.Sprintf(" time>=%d", args.TimeStart))
-	whereSlice = append(whereSlice, fmt.Sprintf(" time<=%d", args.TimeEnd))
-	if args.AppService != "" {
-		whereSlice = append(whereSlice, fmt.Sprintf(" app_service='%s'", args.AppService))
-	}
-	whereSlice = append(whereSlice, fmt.Sprintf(" profile_language_type='%s'", args.ProfileLanguageType))
-	whereSlice = append(whereSlice, fmt.Sprintf(" profile_event_type='%s'", args.ProfileEventType))
-	if args.TagFilter != "" {
-		whereSlice = append(whereSlice, " ("+args.TagFilter+")")
+	whereSql := profileWhere(args, args.TimeStart, args.TimeEnd, args.TagFilter)
+	// diff mode, the time range and tag filter of baseline are used if not set in comparison
+	var compare model.ProfileCompare
+	compareWhereSql := ""
+	if args.Compare != nil {
+		compare = *args.Compare
+		if compare.TimeStart == 0 && compare.TimeEnd == 0 {
+			compare.TimeStart, compare.TimeEnd = args.TimeStart, args.TimeEnd
+		}
+		if compare.TagFilter == "" {
+			compare.TagFilter = args.TagFilter
+		}
+		compareWhereSql = profileWhere(args, compare.TimeStart, compare.TimeEnd, compare.TagFilter)
 	}
-	whereSql := strings.Join(whereSlice, " AND")
 	debugs := model.ProfileDebug{}
 	if args.AppService == "" {
 		appServices, appDebugs, err := GetAppService(args, whereSql, debugs)
@@ -107,7 +109,38 @@ func Profile(args model.Profile, cfg *config.QuerierConfig) (model.ProfileTree,
 		}
 		args.AppService = strings.Join(appServices, ", ")
 	}
-	return GenerateProfile(args, cfg, whereSql, debugs)
+	if args.Compare == nil {
+		return GenerateProfile(args, cfg, whereSql, debugs)
+	}
+
+	baseline, baselineDebug, err := GenerateProfile(args, cfg, whereSql, debugs)
+	if err != nil {
+		return baseline, baselineDebug, err
+	}
+	// the app_service of comparison is the same as baseline, it is the root of the merged profile tree
+	compareArgs := args
+	compareArgs.TimeStart, compareArgs.TimeEnd, compareArgs.TagFilter = compare.TimeStart, compare.TimeEnd, compare.TagFilter
+	debugs, _ = baselineDebug.(model.ProfileDebug)
+	comparison, compareDebug, err := GenerateProfile(compareArgs, cfg, compareWhereSql, debugs)
+	if err != nil {
+		return comparison, compareDebug, err
+	}
+	return DiffProfileTree(baseline, comparison, args.ProfileEventType), compareDebug, nil
+}
+
+func profileWhere(args model.Profile, timeStart, timeEnd int, tagFilter string) string {
+	whereSlice := []string{}
+	whereSlice = append(whereSlice, fmt.Sprintf(" time>=%d", timeStart))
+	whereSlice = append(whereSlice, fmt.Sprintf(" time<=%d", timeEnd))
+	if args.AppService != "" {
+		whereSlice = append(whereSlice, fmt.Sprintf(" app_service='%s'", args.AppService))
+	}
+	whereSlice = append(whereSlice, fmt.Sprintf(" profile_language_type='%s'", args.ProfileLanguageType))
+	whereSlice = append(whereSlice, fmt.Sprintf(" profile_event_type='%s'", args.ProfileEventType))
+	if tagFilter != "" {
+		whereSlice = append(whereSlice, " ("+tagFilter+")")
+	}
+	return strings.Join(whereSlice, " AND")
 }
 
 func GenerateProfile(args model.Profile, cfg *config.QuerierConfig, where string, debugs model.ProfileDebug) (result model.ProfileTree, debug interface{}, err error) {